@@ -8,6 +8,7 @@ import (
 
 	"github.com/yeying-community/router/common/config"
 	"github.com/yeying-community/router/common/logger"
+	"github.com/yeying-community/router/common/tracing"
 )
 
 var HTTPClient *http.Client
@@ -45,12 +46,12 @@ func Init() {
 
 	if config.RelayTimeout == 0 {
 		HTTPClient = &http.Client{
-			Transport: transport,
+			Transport: tracing.Transport(transport),
 		}
 	} else {
 		HTTPClient = &http.Client{
 			Timeout:   time.Duration(config.RelayTimeout) * time.Second,
-			Transport: transport,
+			Transport: tracing.Transport(transport),
 		}
 	}
 
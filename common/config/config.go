@@ -9,6 +9,13 @@ import (
 
 var SystemName = "Router"
 var ServerAddress = "http://localhost:3011"
+
+// ShutdownTimeoutSeconds bounds how long shutdown waits for in-flight
+// requests, websocket sessions and background billing; the drain delay is how
+// long readiness reports draining before the listener closes.
+var ShutdownTimeoutSeconds = 30
+var ShutdownDrainDelaySeconds = 5
+
 var Footer = ""
 var Logo = ""
 var TopUpMode = ""
@@ -201,6 +208,15 @@ var MetricSuccessChanSize = 1024
 var MetricFailChanSize = 128
 var MetricAutoRecoverAfterSeconds = 300
 
+var PrometheusEnabled = false
+var PrometheusListenAddr = ""
+var PrometheusAuthToken = ""
+
+var TracingEnabled = false
+var TracingOTLPEndpoint = "http://127.0.0.1:4318"
+var TracingServiceName = "router"
+var TracingSampleRatio = 1.0
+
 var RootWalletAddress = ""
 var RootWalletAddresses []string
 
@@ -218,4 +234,28 @@ var UserContentRequestTimeout = 30
 var UserContentRequestPrivateHostAllowlist = []string{}
 
 var EnforceIncludeUsage = false
+var TextEndpointConversionEnabled = true
+var ResponsesStateStoreEnabled = true
+var ResponsesStateTTLHours = 720
+var StructuredOutputValidationEnabled = false
+var StructuredOutputValidationRetries = 1
+var FilesStorageBackend = "local"
+var FilesStorageDir = "./data/files"
+var FilesMaxUploadSizeMB = 512
+var FilesUserQuotaMB = 10240
+var FilesProviderUploadEnabled = true
+var BatchWorkerCount = 1
+var BatchConcurrency = 4
+var BatchBillingRatio = 1.0
+var ModerationTimeoutSeconds = 10
+var ModerationFailOpen = true
+var ModerationAutoDisableThreshold = 0
+var ChannelSelectionStrategy = "random"
+var SessionAffinityEnabled = false
+var SessionAffinityHeader = "X-Session-Id"
+var SessionAffinityTTLSeconds = 3600
+var HedgeRecordLoserCost = false
+var StreamFailoverBufferBytes = 16384
+var ChannelLimitQueueSize = 200
+var ChannelLimitQueueTimeoutSeconds = 10
 var TestPrompt = "Output only your specific model name with no additional text."
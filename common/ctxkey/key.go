@@ -17,8 +17,12 @@ const (
 	ModelMapping                = "model_mapping"
 	ChannelModelConfigs         = "channel_model_configs"
 	ChannelName                 = "channel_name"
+	ChannelKeyId                = "channel_key_id"
 	TokenId                     = "token_id"
 	TokenName                   = "token_name"
+	TokenRPMLimit               = "token_rpm_limit"
+	TokenTPMLimit               = "token_tpm_limit"
+	RateLimitScopes             = "rate_limit_scopes"
 	EntitlementSourceType       = "entitlement_source_type"
 	EntitlementSourceId         = "entitlement_source_id"
 	EntitlementSourceName       = "entitlement_source_name"
@@ -27,6 +31,11 @@ const (
 	ResponsesPreviousResponseID = "responses_previous_response_id"
 	ResponsesItemIDs            = "responses_item_ids"
 	ResponsesStatefulRequest    = "responses_stateful_request"
+	ResponsesLocalState         = "responses_local_state"
+	ResponsesReplayRequestBody  = "responses_replay_request_body"
+	GeminiAction                = "gemini_action"
+	StructuredOutputTool        = "structured_output_tool"
+	FilesOriginalRequestBody    = "files_original_request_body"
 	KeyRequestBody              = "key_request_body"
 	UpstreamURL                 = "upstream_url"
 	UpstreamStatus              = "upstream_status"
@@ -37,4 +46,14 @@ const (
 	RelayErrorType              = "relay_error_type"
 	RelayErrorCode              = "relay_error_code"
 	RelayTermination            = "relay_termination"
+	ModerationDecision          = "moderation_decision"
+	ModerationReason            = "moderation_reason"
+	RequestFeatures             = "request_features"
+	SessionAffinityKey          = "session_affinity_key"
+	ClientRequestModel          = "client_request_model"
+	ModelFallbackSource         = "model_fallback_source"
+	RelayModelHop               = "relay_model_hop"
+	RelayHedgeWriter            = "relay_hedge_writer"
+	RelayHedgeChannelId         = "relay_hedge_channel_id"
+	RelayTiming                 = "relay_timing"
 )
@@ -0,0 +1,126 @@
+// Package graceful tracks what a shutdown has to wait for: requests in
+// flight (including hijacked websocket sessions, which http.Server.Shutdown
+// does not wait for), background billing and log writes that outlive their
+// request, and long-running workers.
+package graceful
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const waitPollInterval = 50 * time.Millisecond
+
+var (
+	draining atomic.Bool
+	stopOnce sync.Once
+	stopCh   = make(chan struct{})
+	requests counter
+	tasks    counter
+	workers  counter
+)
+
+type counter struct {
+	n atomic.Int64
+}
+
+func (c *counter) begin() func() {
+	c.n.Add(1)
+	var once sync.Once
+	return func() { once.Do(func() { c.n.Add(-1) }) }
+}
+
+// wait polls rather than using a WaitGroup: new requests may still begin
+// while shutdown is waiting.
+func (c *counter) wait(ctx context.Context) error {
+	ticker := time.NewTicker(waitPollInterval)
+	defer ticker.Stop()
+	for c.n.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// BeginRequest marks a request in flight until the returned func is called.
+func BeginRequest() func() {
+	return requests.begin()
+}
+
+// Go runs fn in the background and makes shutdown wait for it.
+func Go(fn func()) {
+	done := tasks.begin()
+	go func() {
+		defer done()
+		fn()
+	}()
+}
+
+// Worker runs a long-running loop that returns once Stopping is closed.
+func Worker(fn func()) {
+	done := workers.begin()
+	go func() {
+		defer done()
+		fn()
+	}()
+}
+
+// Stopping is closed when workers should stop.
+func Stopping() <-chan struct{} {
+	return stopCh
+}
+
+// Sleep pauses a worker for d and reports false when it should stop instead.
+func Sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-stopCh:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Draining reports whether shutdown began; readiness fails from then on.
+func Draining() bool {
+	return draining.Load()
+}
+
+func StartDraining() {
+	draining.Store(true)
+}
+
+func InFlightRequests() int64 {
+	return requests.n.Load()
+}
+
+func PendingTasks() int64 {
+	return tasks.n.Load()
+}
+
+func WaitRequests(ctx context.Context) error {
+	return requests.wait(ctx)
+}
+
+// SignalStop closes Stopping without waiting. Shutdown calls it before waiting
+// for requests, since workers such as the batch runner start requests of their
+// own until they see it.
+func SignalStop() {
+	stopOnce.Do(func() { close(stopCh) })
+}
+
+// StopWorkers signals every worker to stop and waits for them to return.
+func StopWorkers(ctx context.Context) error {
+	SignalStop()
+	return workers.wait(ctx)
+}
+
+func WaitTasks(ctx context.Context) error {
+	return tasks.wait(ctx)
+}
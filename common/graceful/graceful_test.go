@@ -0,0 +1,56 @@
+package graceful
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestShutdownWaitsForRequestsTasksAndWorkers(t *testing.T) {
+	done := BeginRequest()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := WaitRequests(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitRequests = %v, want the deadline while a request is in flight", err)
+	}
+	done()
+	done()
+	if InFlightRequests() != 0 {
+		t.Fatalf("in flight = %d, calling done twice must count once", InFlightRequests())
+	}
+	if err := WaitRequests(context.Background()); err != nil {
+		t.Fatalf("WaitRequests: %v", err)
+	}
+
+	release := make(chan struct{})
+	finished := false
+	Go(func() {
+		<-release
+		finished = true
+	})
+	stopped := false
+	Worker(func() {
+		for Sleep(time.Hour) {
+		}
+		stopped = true
+	})
+
+	if Draining() {
+		t.Fatalf("should not be draining before shutdown")
+	}
+	StartDraining()
+	if !Draining() {
+		t.Fatalf("should be draining")
+	}
+	if err := StopWorkers(context.Background()); err != nil || !stopped {
+		t.Fatalf("StopWorkers = %v, stopped = %v", err, stopped)
+	}
+	if Sleep(time.Hour) {
+		t.Fatalf("Sleep should return false once workers are stopping")
+	}
+	close(release)
+	if err := WaitTasks(context.Background()); err != nil || !finished {
+		t.Fatalf("WaitTasks = %v, finished = %v", err, finished)
+	}
+}
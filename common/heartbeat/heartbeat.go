@@ -0,0 +1,56 @@
+// Package heartbeat lets background workers report that they are still
+// looping, so liveness can tell a stuck worker from an idle one.
+package heartbeat
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// staleGrace is added to twice the expected interval before a worker counts
+// as stale, so one slow iteration is not reported.
+const staleGrace = time.Minute
+
+type beat struct {
+	at     time.Time
+	within time.Duration
+}
+
+var (
+	lock  sync.Mutex
+	beats = map[string]beat{}
+)
+
+type Status struct {
+	Name                  string `json:"name"`
+	LastBeatAt            int64  `json:"last_beat_at"`
+	ExpectedWithinSeconds int64  `json:"expected_within_seconds"`
+	Stale                 bool   `json:"stale"`
+}
+
+// Beat records that worker name is alive and will beat again within the given
+// duration. A worker starting work of unbounded length, such as an async
+// task, beats with within <= 0 and is not reported stale until it beats again.
+func Beat(name string, within time.Duration) {
+	lock.Lock()
+	beats[name] = beat{at: time.Now(), within: within}
+	lock.Unlock()
+}
+
+// Snapshot returns every worker that ever beat, by name.
+func Snapshot(now time.Time) []Status {
+	lock.Lock()
+	statuses := make([]Status, 0, len(beats))
+	for name, last := range beats {
+		statuses = append(statuses, Status{
+			Name:                  name,
+			LastBeatAt:            last.at.Unix(),
+			ExpectedWithinSeconds: int64(last.within / time.Second),
+			Stale:                 last.within > 0 && now.Sub(last.at) > 2*last.within+staleGrace,
+		})
+	}
+	lock.Unlock()
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
@@ -0,0 +1,136 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/yeying-community/router/common"
+	"github.com/yeying-community/router/common/config"
+	"github.com/yeying-community/router/common/logger"
+)
+
+const instrumentationName = "github.com/yeying-community/router"
+
+var (
+	providerLock sync.Mutex
+	provider     *sdktrace.TracerProvider
+)
+
+func init() {
+	// Incoming traceparent headers are honored even when export is off, so
+	// the trace id in logs matches the caller's trace.
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+// Init installs an OTLP/HTTP exporter as the global tracer provider. Without
+// tracing.enabled every span is a no-op.
+func Init() error {
+	if !config.TracingEnabled {
+		return nil
+	}
+	options := []otlptracehttp.Option{}
+	if endpoint := strings.TrimSpace(config.TracingOTLPEndpoint); endpoint != "" {
+		options = append(options, otlptracehttp.WithEndpointURL(endpoint))
+	}
+	exporter, err := otlptracehttp.New(context.Background(), options...)
+	if err != nil {
+		return err
+	}
+	serviceName := strings.TrimSpace(config.TracingServiceName)
+	if serviceName == "" {
+		serviceName = "router"
+	}
+	ratio := config.TracingSampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+	tracerProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", serviceName),
+			attribute.String("service.version", common.Version),
+		)),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(tracerProvider)
+	providerLock.Lock()
+	provider = tracerProvider
+	providerLock.Unlock()
+	logger.SysLogf("tracing enabled, exporting to %s", strings.TrimSpace(config.TracingOTLPEndpoint))
+	return nil
+}
+
+// Shutdown flushes the spans still buffered by the exporter.
+func Shutdown(ctx context.Context) error {
+	providerLock.Lock()
+	tracerProvider := provider
+	provider = nil
+	providerLock.Unlock()
+	if tracerProvider == nil {
+		return nil
+	}
+	return tracerProvider.Shutdown(ctx)
+}
+
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Extract continues the caller's trace from its W3C traceparent header.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+func StartServer(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+}
+
+// StartRequestSpan makes a new span the current one of c's request, for work a
+// middleware or handler does before passing the request on. The returned
+// func ends the span and puts the previous context back; it may be called
+// more than once, so it can be both deferred and called before c.Next().
+func StartRequestSpan(c *gin.Context, name string, attrs ...attribute.KeyValue) (trace.Span, func()) {
+	parent := c.Request.Context()
+	ctx, span := Start(parent, name, attrs...)
+	c.Request = c.Request.WithContext(ctx)
+	ended := false
+	return span, func() {
+		if ended {
+			return
+		}
+		ended = true
+		if c.IsAborted() {
+			status := c.Writer.Status()
+			span.SetAttributes(attribute.Int("http.response.status_code", status))
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		span.End()
+		c.Request = c.Request.WithContext(parent)
+	}
+}
+
+// Fail marks span as failed.
+func Fail(span trace.Span, message string) {
+	span.SetStatus(codes.Error, message)
+}
+
+// TraceID is the id of the trace ctx belongs to, empty when there is none.
+func TraceID(ctx context.Context) string {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.HasTraceID() {
+		return ""
+	}
+	return spanContext.TraceID().String()
+}
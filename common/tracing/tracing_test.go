@@ -0,0 +1,102 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func useRecorderForTest(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return exporter
+}
+
+func TestStartRequestSpanRestoresParentAndMarksAbort(t *testing.T) {
+	exporter := useRecorderForTest(t)
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	request := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0736ff-00f067aa0ba902b7-01")
+	rootCtx, root := StartServer(Extract(request.Context(), request.Header), "POST /v1/chat/completions")
+	c.Request = request.WithContext(rootCtx)
+
+	_, endAuth := StartRequestSpan(c, "relay.auth")
+	endAuth()
+	endAuth()
+	_, endDistribute := StartRequestSpan(c, "relay.distribute")
+	c.AbortWithStatus(http.StatusServiceUnavailable)
+	endDistribute()
+	if trace.SpanFromContext(c.Request.Context()) != root {
+		t.Fatalf("ending a request span should put the server span back")
+	}
+	root.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("spans = %d, want 3", len(spans))
+	}
+	if got := spans[2].SpanContext.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0736ff" {
+		t.Fatalf("server span trace = %s, want the incoming traceparent", got)
+	}
+	for _, span := range spans[:2] {
+		if span.Parent.SpanID() != spans[2].SpanContext.SpanID() {
+			t.Fatalf("%s should be a child of the server span", span.Name)
+		}
+	}
+	if spans[0].Status.Code == codes.Error || spans[1].Status.Code != codes.Error {
+		t.Fatalf("statuses = %v/%v, want only the aborted span failed", spans[0].Status, spans[1].Status)
+	}
+	if TraceID(rootCtx) != "4bf92f3577b34da6a3ce929d0e0736ff" {
+		t.Fatalf("trace id = %s", TraceID(rootCtx))
+	}
+}
+
+func TestTransportRecordsClientSpanWithoutPropagating(t *testing.T) {
+	exporter := useRecorderForTest(t)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("traceparent") != "" {
+			t.Errorf("trace context should not be sent upstream")
+		}
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer upstream.Close()
+	client := &http.Client{Transport: Transport(nil)}
+
+	untraced, _ := http.NewRequest(http.MethodGet, upstream.URL+"/v1/models?key=secret", nil)
+	if resp, err := client.Do(untraced); err == nil {
+		resp.Body.Close()
+	}
+	if len(exporter.GetSpans()) != 0 {
+		t.Fatalf("a request outside a trace should not start one")
+	}
+
+	ctx, parent := Start(untraced.Context(), "relay.attempt")
+	traced, _ := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL+"/v1/models?key=secret", nil)
+	resp, err := client.Do(traced)
+	if err != nil {
+		t.Fatalf("do: %v", err)
+	}
+	resp.Body.Close()
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 || spans[0].Name != "upstream.http GET" || spans[0].Status.Code != codes.Error {
+		t.Fatalf("spans = %+v", spans)
+	}
+	for _, attr := range spans[0].Attributes {
+		if attr.Value.Emit() == upstream.URL+"/v1/models?key=secret" {
+			t.Fatalf("the query string should not be recorded")
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yeying-community/router/common/ctxkey"
+	"github.com/yeying-community/router/common/helper"
+	"github.com/yeying-community/router/common/logger"
+	"github.com/yeying-community/router/internal/admin/model"
+	"github.com/yeying-community/router/internal/relay/batch"
+)
+
+const (
+	defaultBatchesListLimit = 20
+	maxBatchesListLimit     = 100
+)
+
+type createBatchRequest struct {
+	InputFileID      string            `json:"input_file_id"`
+	Endpoint         string            `json:"endpoint"`
+	CompletionWindow string            `json:"completion_window"`
+	Metadata         map[string]string `json:"metadata"`
+}
+
+type batchList struct {
+	Object  string         `json:"object"`
+	Data    []batch.Object `json:"data"`
+	FirstID string         `json:"first_id,omitempty"`
+	LastID  string         `json:"last_id,omitempty"`
+	HasMore bool           `json:"has_more"`
+}
+
+func CreateBatch(c *gin.Context) {
+	request := createBatchRequest{}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		abortWithFileError(c, http.StatusBadRequest, "We could not parse the JSON body of your request.", "", "invalid_json")
+		return
+	}
+	for param, value := range map[string]string{
+		"input_file_id":     request.InputFileID,
+		"endpoint":          request.Endpoint,
+		"completion_window": request.CompletionWindow,
+	} {
+		if strings.TrimSpace(value) == "" {
+			abortWithFileError(c, http.StatusBadRequest, fmt.Sprintf("Missing required parameter: '%s'.", param), param, "missing_required_parameter")
+			return
+		}
+	}
+	row, err := batch.Create(batch.CreateRequest{
+		UserID:           c.GetString(ctxkey.Id),
+		TokenID:          c.GetString(ctxkey.TokenId),
+		ClientIP:         c.ClientIP(),
+		TraceID:          c.GetString(helper.TraceIDKey),
+		InputFileID:      strings.TrimSpace(request.InputFileID),
+		Endpoint:         strings.TrimSpace(request.Endpoint),
+		CompletionWindow: strings.TrimSpace(request.CompletionWindow),
+		Metadata:         request.Metadata,
+	})
+	if err != nil {
+		var invalidErr *batch.InvalidRequestError
+		if errors.As(err, &invalidErr) {
+			abortWithFileError(c, http.StatusBadRequest, invalidErr.Message, invalidErr.Param, invalidErr.Code)
+			return
+		}
+		logger.Errorf(ginRequestContext(c), "[CreateBatch] failed user=%s input_file_id=%s err=%v", c.GetString(ctxkey.Id), request.InputFileID, err)
+		abortWithResponseStateError(c, http.StatusInternalServerError, err.Error(), "server_error", "", "create_batch_failed")
+		return
+	}
+	c.JSON(http.StatusOK, batch.NewObject(row))
+}
+
+func ListBatches(c *gin.Context) {
+	limit := defaultBatchesListLimit
+	if value := strings.TrimSpace(c.Query("limit")); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxBatchesListLimit {
+			abortWithFileError(c, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxBatchesListLimit), "limit", "invalid_limit")
+			return
+		}
+		limit = parsed
+	}
+	rows, hasMore, err := batch.List(model.RelayBatchListQuery{
+		UserID: c.GetString(ctxkey.Id),
+		After:  c.Query("after"),
+		Limit:  limit,
+	})
+	if err != nil {
+		if errors.Is(err, batch.ErrBatchNotFound) {
+			abortBatchNotFound(c, c.Query("after"))
+			return
+		}
+		logger.Errorf(ginRequestContext(c), "[ListBatches] failed user=%s err=%v", c.GetString(ctxkey.Id), err)
+		abortWithResponseStateError(c, http.StatusInternalServerError, err.Error(), "server_error", "", "list_batches_failed")
+		return
+	}
+	result := batchList{Object: "list", Data: make([]batch.Object, 0, len(rows)), HasMore: hasMore}
+	for _, row := range rows {
+		result.Data = append(result.Data, batch.NewObject(row))
+	}
+	if len(result.Data) > 0 {
+		result.FirstID = result.Data[0].ID
+		result.LastID = result.Data[len(result.Data)-1].ID
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func RetrieveBatch(c *gin.Context) {
+	batchID := strings.TrimSpace(c.Param("id"))
+	row, err := batch.Get(batchID, c.GetString(ctxkey.Id))
+	if err != nil {
+		if errors.Is(err, batch.ErrBatchNotFound) {
+			abortBatchNotFound(c, batchID)
+			return
+		}
+		logger.Errorf(ginRequestContext(c), "[RetrieveBatch] failed user=%s batch_id=%s err=%v", c.GetString(ctxkey.Id), batchID, err)
+		abortWithResponseStateError(c, http.StatusInternalServerError, err.Error(), "server_error", "", "load_batch_failed")
+		return
+	}
+	c.JSON(http.StatusOK, batch.NewObject(row))
+}
+
+func CancelBatch(c *gin.Context) {
+	batchID := strings.TrimSpace(c.Param("id"))
+	row, err := batch.Cancel(batchID, c.GetString(ctxkey.Id))
+	switch {
+	case errors.Is(err, batch.ErrBatchNotFound):
+		abortBatchNotFound(c, batchID)
+		return
+	case errors.Is(err, batch.ErrBatchNotCancellable):
+		abortWithResponseStateError(c, http.StatusConflict, err.Error(), "invalid_request_error", "id", "batch_not_cancellable")
+		return
+	case err != nil:
+		logger.Errorf(ginRequestContext(c), "[CancelBatch] failed user=%s batch_id=%s err=%v", c.GetString(ctxkey.Id), batchID, err)
+		abortWithResponseStateError(c, http.StatusInternalServerError, err.Error(), "server_error", "", "cancel_batch_failed")
+		return
+	}
+	c.JSON(http.StatusOK, batch.NewObject(row))
+}
+
+func abortBatchNotFound(c *gin.Context, batchID string) {
+	abortWithFileError(c, http.StatusNotFound, fmt.Sprintf("No batch found with id '%s'.", batchID), "id", "batch_not_found")
+}
@@ -6,6 +6,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/yeying-community/router/common/graceful"
+	"github.com/yeying-community/router/common/heartbeat"
 	"github.com/yeying-community/router/common/logger"
 	"github.com/yeying-community/router/internal/admin/model"
 	"gorm.io/gorm"
@@ -144,16 +146,18 @@ func runChannelHealthProbeScan() {
 
 func StartChannelHealthProbeWorker() {
 	channelHealthProbeWorkerOnce.Do(func() {
-		go func() {
-			timer := time.NewTimer(time.Minute)
-			defer timer.Stop()
-			<-timer.C
-			runChannelHealthProbeScan()
-			ticker := time.NewTicker(channelHealthProbeScanInterval)
-			defer ticker.Stop()
-			for range ticker.C {
+		graceful.Worker(func() {
+			heartbeat.Beat("channel_health_probe", time.Minute)
+			if !graceful.Sleep(time.Minute) {
+				return
+			}
+			for {
+				heartbeat.Beat("channel_health_probe", channelHealthProbeScanInterval)
 				runChannelHealthProbeScan()
+				if !graceful.Sleep(channelHealthProbeScanInterval) {
+					return
+				}
 			}
-		}()
+		})
 	})
 }
@@ -0,0 +1,135 @@
+package channel
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeying-community/router/internal/admin/model"
+	"gorm.io/gorm"
+)
+
+type addChannelKeysRequest struct {
+	Name string   `json:"name"`
+	Key  string   `json:"key"`
+	Keys []string `json:"keys"`
+}
+
+type updateChannelKeyRequest struct {
+	Status int `json:"status"`
+}
+
+func maskChannelKeys(rows []model.ChannelKey) []model.ChannelKey {
+	for i := range rows {
+		rows[i].KeyPreview = maskChannelKeyPreview(rows[i].Key)
+		rows[i].Key = ""
+	}
+	return rows
+}
+
+func GetChannelKeys(c *gin.Context) {
+	channelID := strings.TrimSpace(c.Param("id"))
+	if channelID == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "渠道 ID 无效",
+		})
+		return
+	}
+	rows, err := model.ListChannelKeys(channelID)
+	if err != nil {
+		logChannelAdminWarn(c, "list_keys", stringField("channel_id", channelID), stringField("reason", err.Error()))
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"items": maskChannelKeys(rows),
+			"total": len(rows),
+		},
+	})
+}
+
+// AddChannelKeys adds keys to the channel's pool. "key" accepts one key per
+// line so a batch can be pasted at once.
+func AddChannelKeys(c *gin.Context) {
+	channelID := strings.TrimSpace(c.Param("id"))
+	req := addChannelKeysRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	keys := append(strings.Split(req.Key, "\n"), req.Keys...)
+	rows, err := model.AddChannelKeys(channelID, req.Name, keys)
+	if err != nil {
+		logChannelAdminWarn(c, "add_keys", stringField("channel_id", channelID), stringField("reason", err.Error()))
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"items": maskChannelKeys(rows),
+			"total": len(rows),
+		},
+	})
+}
+
+func UpdateChannelKey(c *gin.Context) {
+	req := updateChannelKeyRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	setChannelKeyStatus(c, req.Status)
+}
+
+// RetireChannelKey takes a key out of the pool for good. The row is kept so
+// request logs stay attributable to it.
+func RetireChannelKey(c *gin.Context) {
+	setChannelKeyStatus(c, model.ChannelKeyStatusRetired)
+}
+
+func setChannelKeyStatus(c *gin.Context, status int) {
+	channelID := strings.TrimSpace(c.Param("id"))
+	keyID := strings.TrimSpace(c.Param("key_id"))
+	if channelID == "" || keyID == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "渠道或密钥 ID 无效",
+		})
+		return
+	}
+	if err := model.UpdateChannelKeyStatus(channelID, keyID, status); err != nil {
+		message := err.Error()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			message = "密钥不存在"
+		}
+		logChannelAdminWarn(c, "update_key", stringField("channel_id", channelID), stringField("key_id", keyID), stringField("reason", message))
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": message,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
@@ -84,6 +84,8 @@ func normalizeChannelModelTypeHint(raw string) string {
 		strings.Contains(lower, "tts"),
 		strings.Contains(lower, "transcription"):
 		return model.ProviderModelTypeAudio
+	case strings.Contains(lower, "rerank"):
+		return model.ProviderModelTypeRerank
 	case strings.Contains(lower, "embedding"),
 		strings.Contains(lower, "embeddings"),
 		strings.Contains(lower, "embed"):
@@ -0,0 +1,97 @@
+package channellimit
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeying-community/router/internal/admin/model"
+	"gorm.io/gorm"
+)
+
+type upsertChannelLimitRequest struct {
+	ChannelID      string `json:"channel_id"`
+	Model          string `json:"model"`
+	MaxConcurrency int    `json:"max_concurrency"`
+	RPM            int    `json:"rpm"`
+	TPM            int    `json:"tpm"`
+	Enabled        *bool  `json:"enabled"`
+}
+
+func GetChannelLimits(c *gin.Context) {
+	rows, err := model.ListChannelLimits(c.Query("channel_id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    rows,
+	})
+}
+
+func SaveChannelLimit(c *gin.Context) {
+	req := upsertChannelLimitRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	row, err := model.SaveChannelLimit(model.ChannelLimit{
+		ChannelID:      req.ChannelID,
+		Model:          req.Model,
+		MaxConcurrency: req.MaxConcurrency,
+		RPM:            req.RPM,
+		TPM:            req.TPM,
+		Enabled:        enabled,
+	})
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    row,
+	})
+}
+
+func DeleteChannelLimit(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "渠道限额 ID 不能为空",
+		})
+		return
+	}
+	if err := model.DeleteChannelLimit(id); err != nil {
+		message := err.Error()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			message = "渠道限额不存在"
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": message,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
@@ -235,6 +235,8 @@ type dashboardPayload struct {
 	UserGrowthTrend      []userGrowthPeriodSummary `json:"user_growth_trend"`
 	ModelSummary         modelSummaryData          `json:"model_summary"`
 	TopModels            []modelHealthItem         `json:"top_models"`
+	ModelLatency         []*model.RelayLatencyStat `json:"model_latency"`
+	ChannelLatency       []*model.RelayLatencyStat `json:"channel_latency"`
 	RecentTasks          []model.AsyncTask         `json:"recent_tasks"`
 	GeneratedAt          int64                     `json:"generated_at"`
 }
@@ -1703,6 +1705,13 @@ func buildModelDashboard(startAt int64, endAt int64, limit int) (modelSummaryDat
 	return summary, items, nil
 }
 
+func limitRelayLatencyStats(stats []*model.RelayLatencyStat, limit int) []*model.RelayLatencyStat {
+	if len(stats) > limit {
+		return stats[:limit]
+	}
+	return stats
+}
+
 func GetDashboard(c *gin.Context) {
 	period := normalizePeriod(c.DefaultQuery("period", periodLast7Days))
 	section := normalizeSection(c.Query("section"))
@@ -1843,6 +1852,12 @@ func GetDashboard(c *gin.Context) {
 		}
 		payload.TopChannels = topChannels
 		payload.ChannelHealthSummary = channelHealthSummary
+		channelLatency, err := model.GetRelayLatencyStats(model.RelayLatencyGroupByChannel, startAt, endAt, "", "")
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		payload.ChannelLatency = limitRelayLatencyStats(channelLatency, channelDashboardListLimit)
 	}
 
 	if section == sectionAll || section == sectionModels {
@@ -1853,6 +1868,12 @@ func GetDashboard(c *gin.Context) {
 		}
 		payload.ModelSummary = modelSummary
 		payload.TopModels = topModels
+		modelLatency, err := model.GetRelayLatencyStats(model.RelayLatencyGroupByModel, startAt, endAt, "", "")
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		payload.ModelLatency = limitRelayLatencyStats(modelLatency, modelTopLimit)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -0,0 +1,231 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yeying-community/router/common/ctxkey"
+	"github.com/yeying-community/router/common/logger"
+	"github.com/yeying-community/router/internal/admin/model"
+	"github.com/yeying-community/router/internal/relay/filestore"
+	relaymodel "github.com/yeying-community/router/internal/relay/model"
+)
+
+const (
+	defaultFilesListLimit      = 10000
+	maxFilesListLimit          = 10000
+	minFileExpiresAfterSeconds = 3600
+	maxFileExpiresAfterSeconds = 30 * 24 * 3600
+	// multipart framing and form fields on top of the file itself
+	fileUploadBodyOverheadBytes = 1 << 20
+)
+
+type fileList struct {
+	Object  string             `json:"object"`
+	Data    []filestore.Object `json:"data"`
+	FirstID string             `json:"first_id,omitempty"`
+	LastID  string             `json:"last_id,omitempty"`
+	HasMore bool               `json:"has_more"`
+}
+
+func UploadFile(c *gin.Context) {
+	userID := c.GetString(ctxkey.Id)
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, filestore.MaxUploadBytes()+fileUploadBodyOverheadBytes)
+	purpose := strings.TrimSpace(c.PostForm("purpose"))
+	if purpose == "" {
+		abortWithFileError(c, http.StatusBadRequest, "Missing required parameter: 'purpose'.", "purpose", "missing_required_parameter")
+		return
+	}
+	if !filestore.ValidPurpose(purpose) {
+		abortWithFileError(c, http.StatusBadRequest, fmt.Sprintf("Invalid value for 'purpose': '%s'.", purpose), "purpose", "invalid_value")
+		return
+	}
+	expiresAfterSeconds, err := parseFileExpiresAfter(c)
+	if err != nil {
+		abortWithFileError(c, http.StatusBadRequest, err.Error(), "expires_after", "invalid_value")
+		return
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			abortWithFileError(c, http.StatusRequestEntityTooLarge, filestore.ErrFileTooLarge.Error(), "file", "file_too_large")
+			return
+		}
+		abortWithFileError(c, http.StatusBadRequest, "Missing required parameter: 'file'.", "file", "missing_required_parameter")
+		return
+	}
+	content, err := fileHeader.Open()
+	if err != nil {
+		abortWithFileError(c, http.StatusBadRequest, err.Error(), "file", "invalid_file")
+		return
+	}
+	defer content.Close()
+
+	row, err := filestore.Create(ginRequestContext(c), filestore.UploadRequest{
+		UserID:              userID,
+		Filename:            fileHeader.Filename,
+		Purpose:             purpose,
+		ContentType:         fileHeader.Header.Get("Content-Type"),
+		Size:                fileHeader.Size,
+		ExpiresAfterSeconds: expiresAfterSeconds,
+		Content:             content,
+	})
+	switch {
+	case errors.Is(err, filestore.ErrFileTooLarge):
+		abortWithFileError(c, http.StatusRequestEntityTooLarge, err.Error(), "file", "file_too_large")
+		return
+	case errors.Is(err, filestore.ErrQuotaExceeded):
+		abortWithFileError(c, http.StatusForbidden, err.Error(), "file", "file_quota_exceeded")
+		return
+	case err != nil:
+		logger.Errorf(ginRequestContext(c), "[UploadFile] failed user=%s filename=%s err=%v", userID, fileHeader.Filename, err)
+		abortWithFileServerError(c, err, "upload_file_failed")
+		return
+	}
+	c.JSON(http.StatusOK, filestore.NewObject(row))
+}
+
+func ListFiles(c *gin.Context) {
+	limit := defaultFilesListLimit
+	if value := strings.TrimSpace(c.Query("limit")); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxFilesListLimit {
+			abortWithFileError(c, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxFilesListLimit), "limit", "invalid_limit")
+			return
+		}
+		limit = parsed
+	}
+	rows, hasMore, err := filestore.List(model.RelayFileListQuery{
+		UserID:  c.GetString(ctxkey.Id),
+		Purpose: c.Query("purpose"),
+		After:   c.Query("after"),
+		Limit:   limit,
+		Desc:    !strings.EqualFold(strings.TrimSpace(c.Query("order")), "asc"),
+	})
+	if err != nil {
+		if errors.Is(err, filestore.ErrFileNotFound) {
+			abortFileNotFound(c, c.Query("after"))
+			return
+		}
+		logger.Errorf(ginRequestContext(c), "[ListFiles] failed user=%s err=%v", c.GetString(ctxkey.Id), err)
+		abortWithFileServerError(c, err, "list_files_failed")
+		return
+	}
+	result := fileList{Object: "list", Data: make([]filestore.Object, 0, len(rows)), HasMore: hasMore}
+	for _, row := range rows {
+		result.Data = append(result.Data, filestore.NewObject(row))
+	}
+	if len(result.Data) > 0 {
+		result.FirstID = result.Data[0].ID
+		result.LastID = result.Data[len(result.Data)-1].ID
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func RetrieveFile(c *gin.Context) {
+	row, ok := loadFileForRequest(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, filestore.NewObject(row))
+}
+
+func RetrieveFileContent(c *gin.Context) {
+	row, ok := loadFileForRequest(c)
+	if !ok {
+		return
+	}
+	content, err := filestore.Open(ginRequestContext(c), row)
+	if err != nil {
+		if errors.Is(err, filestore.ErrFileNotFound) {
+			abortFileNotFound(c, row.Id)
+			return
+		}
+		logger.Errorf(ginRequestContext(c), "[RetrieveFileContent] failed user=%s file_id=%s err=%v", row.UserID, row.Id, err)
+		abortWithFileServerError(c, err, "read_file_failed")
+		return
+	}
+	defer content.Close()
+	contentType := row.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.DataFromReader(http.StatusOK, row.Bytes, contentType, content, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": row.Filename}),
+	})
+}
+
+func DeleteFile(c *gin.Context) {
+	fileID := strings.TrimSpace(c.Param("id"))
+	deleted, err := filestore.Delete(ginRequestContext(c), fileID, c.GetString(ctxkey.Id))
+	if err != nil {
+		logger.Errorf(ginRequestContext(c), "[DeleteFile] failed user=%s file_id=%s err=%v", c.GetString(ctxkey.Id), fileID, err)
+		abortWithFileServerError(c, err, "delete_file_failed")
+		return
+	}
+	if !deleted {
+		abortFileNotFound(c, fileID)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":      fileID,
+		"object":  "file",
+		"deleted": true,
+	})
+}
+
+func loadFileForRequest(c *gin.Context) (model.RelayFile, bool) {
+	fileID := strings.TrimSpace(c.Param("id"))
+	row, err := filestore.Get(fileID, c.GetString(ctxkey.Id))
+	if err != nil {
+		if errors.Is(err, filestore.ErrFileNotFound) {
+			abortFileNotFound(c, fileID)
+			return model.RelayFile{}, false
+		}
+		logger.Errorf(ginRequestContext(c), "[RetrieveFile] failed user=%s file_id=%s err=%v", c.GetString(ctxkey.Id), fileID, err)
+		abortWithFileServerError(c, err, "load_file_failed")
+		return model.RelayFile{}, false
+	}
+	return row, true
+}
+
+// parseFileExpiresAfter reads the expires_after[anchor]/expires_after[seconds]
+// form fields; only the created_at anchor exists.
+func parseFileExpiresAfter(c *gin.Context) (int64, error) {
+	anchor := strings.TrimSpace(c.PostForm("expires_after[anchor]"))
+	secondsValue := strings.TrimSpace(c.PostForm("expires_after[seconds]"))
+	if anchor == "" && secondsValue == "" {
+		return 0, nil
+	}
+	if anchor != "created_at" {
+		return 0, fmt.Errorf("expires_after[anchor] must be 'created_at'")
+	}
+	seconds, err := strconv.ParseInt(secondsValue, 10, 64)
+	if err != nil || seconds < minFileExpiresAfterSeconds || seconds > maxFileExpiresAfterSeconds {
+		return 0, fmt.Errorf("expires_after[seconds] must be between %d and %d", minFileExpiresAfterSeconds, maxFileExpiresAfterSeconds)
+	}
+	return seconds, nil
+}
+
+func abortFileNotFound(c *gin.Context, fileID string) {
+	abortWithFileError(c, http.StatusNotFound, fmt.Sprintf("No such File object: %s", fileID), "id", "file_not_found")
+}
+
+func abortWithFileError(c *gin.Context, status int, message string, param string, code string) {
+	writeFileError(c, status, relaymodel.Error{Message: message, Type: "invalid_request_error", Param: param, Code: code})
+}
+
+func abortWithFileServerError(c *gin.Context, err error, code string) {
+	writeFileError(c, http.StatusInternalServerError, relaymodel.Error{Message: err.Error(), Type: "server_error", Code: code})
+}
+
+func writeFileError(c *gin.Context, status int, fileErr relaymodel.Error) {
+	c.JSON(status, gin.H{"error": fileErr})
+}
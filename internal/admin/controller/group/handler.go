@@ -13,13 +13,16 @@ import (
 )
 
 type upsertGroupRequest struct {
-	Id          string                        `json:"id"`
-	Name        string                        `json:"name"`
-	Description string                        `json:"description"`
-	Enabled     *bool                         `json:"enabled"`
-	SortOrder   int                           `json:"sort_order"`
-	ChannelIDs  []string                      `json:"channel_ids"`
-	Models      []model.GroupModelBindingItem `json:"models"`
+	Id                string                        `json:"id"`
+	Name              string                        `json:"name"`
+	Description       string                        `json:"description"`
+	Enabled           *bool                         `json:"enabled"`
+	SortOrder         int                           `json:"sort_order"`
+	SelectionStrategy *string                       `json:"selection_strategy"`
+	RPMLimitPerUser   *int                          `json:"rpm_limit_per_user"`
+	TPMLimitPerUser   *int                          `json:"tpm_limit_per_user"`
+	ChannelIDs        []string                      `json:"channel_ids"`
+	Models            []model.GroupModelBindingItem `json:"models"`
 }
 
 type updateGroupChannelsRequest struct {
@@ -164,6 +167,15 @@ func CreateGroup(c *gin.Context) {
 		Description: strings.TrimSpace(req.Description),
 		Source:      "manual",
 	}
+	if req.SelectionStrategy != nil {
+		createItem.SelectionStrategy = strings.TrimSpace(*req.SelectionStrategy)
+	}
+	if req.RPMLimitPerUser != nil {
+		createItem.RPMLimitPerUser = *req.RPMLimitPerUser
+	}
+	if req.TPMLimitPerUser != nil {
+		createItem.TPMLimitPerUser = *req.TPMLimitPerUser
+	}
 	row := model.GroupCatalog{}
 	var err error
 	if req.Models != nil {
@@ -215,6 +227,18 @@ func UpdateGroup(c *gin.Context) {
 		Enabled:     enabled,
 		SortOrder:   req.SortOrder,
 	}
+	item.SelectionStrategy = current.SelectionStrategy
+	if req.SelectionStrategy != nil {
+		item.SelectionStrategy = strings.TrimSpace(*req.SelectionStrategy)
+	}
+	item.RPMLimitPerUser = current.RPMLimitPerUser
+	if req.RPMLimitPerUser != nil {
+		item.RPMLimitPerUser = *req.RPMLimitPerUser
+	}
+	item.TPMLimitPerUser = current.TPMLimitPerUser
+	if req.TPMLimitPerUser != nil {
+		item.TPMLimitPerUser = *req.TPMLimitPerUser
+	}
 	row := model.GroupCatalog{}
 	var err error
 	if req.Models != nil {
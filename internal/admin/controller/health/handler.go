@@ -0,0 +1,183 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/yeying-community/router/common"
+	"github.com/yeying-community/router/common/config"
+	"github.com/yeying-community/router/common/graceful"
+	"github.com/yeying-community/router/common/heartbeat"
+	"github.com/yeying-community/router/internal/admin/model"
+)
+
+const (
+	StatusOK       = "ok"
+	StatusFail     = "fail"
+	StatusDisabled = "disabled"
+	StatusDraining = "draining"
+	// degraded components are reported without failing the probe
+	StatusDegraded = "degraded"
+
+	checkTimeout = 2 * time.Second
+	// a channel cache missing this many syncs in a row is stale
+	channelCacheStaleSyncs = 3
+)
+
+type Component struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms,omitempty"`
+	Message   string `json:"message,omitempty"`
+	Details   any    `json:"details,omitempty"`
+}
+
+type Report struct {
+	Status     string               `json:"status"`
+	Components map[string]Component `json:"components"`
+	CheckedAt  int64                `json:"checked_at"`
+}
+
+// GetLiveness answers liveness probes: the process serves requests. Everything
+// else is left to readiness, since restarting does not fix a database outage
+// or a stuck worker. Unlike the admin APIs, probes report through the status
+// code.
+func GetLiveness(c *gin.Context) {
+	respond(c, "", map[string]Component{})
+}
+
+// GetReadiness answers readiness probes: whether this instance should receive
+// traffic. It fails while draining for shutdown and when a dependency needed
+// to relay requests is unavailable. On master nodes it also reports background
+// workers that stopped beating as degraded.
+func GetReadiness(c *gin.Context) {
+	if graceful.Draining() {
+		respond(c, StatusDraining, map[string]Component{
+			"server": {
+				Status:  StatusDraining,
+				Details: gin.H{"in_flight_requests": graceful.InFlightRequests()},
+			},
+		})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), checkTimeout)
+	defer cancel()
+	checks := map[string]func(context.Context) Component{
+		"database":      func(ctx context.Context) Component { return checkDB(ctx, model.DB) },
+		"log_database":  func(ctx context.Context) Component { return checkDB(ctx, model.LOG_DB) },
+		"redis":         checkRedis,
+		"channel_cache": func(context.Context) Component { return checkChannelCache(time.Now()) },
+	}
+	if config.IsMasterNode {
+		checks["workers"] = func(context.Context) Component { return checkWorkers(time.Now()) }
+	}
+	components := make(map[string]Component, len(checks))
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := check(ctx)
+			lock.Lock()
+			components[name] = result
+			lock.Unlock()
+		}()
+	}
+	wg.Wait()
+	respond(c, "", components)
+}
+
+// respond reports 503 when any component failed or status is forced.
+func respond(c *gin.Context, status string, components map[string]Component) {
+	if status == "" {
+		status = StatusOK
+		for _, component := range components {
+			if component.Status == StatusFail {
+				status = StatusFail
+				break
+			}
+			if component.Status == StatusDegraded {
+				status = StatusDegraded
+			}
+		}
+	}
+	code := http.StatusOK
+	if status != StatusOK && status != StatusDegraded {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, Report{Status: status, Components: components, CheckedAt: time.Now().Unix()})
+}
+
+func timed(check func() error) Component {
+	startedAt := time.Now()
+	err := check()
+	component := Component{Status: StatusOK, LatencyMs: time.Since(startedAt).Milliseconds()}
+	if err != nil {
+		component.Status = StatusFail
+		component.Message = err.Error()
+	}
+	return component
+}
+
+func checkDB(ctx context.Context, db *gorm.DB) Component {
+	if db == nil {
+		return Component{Status: StatusFail, Message: "未初始化"}
+	}
+	return timed(func() error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})
+}
+
+func checkRedis(ctx context.Context) Component {
+	if !common.RedisEnabled || common.RDB == nil {
+		return Component{Status: StatusDisabled}
+	}
+	return timed(func() error {
+		return common.RDB.Ping(ctx).Err()
+	})
+}
+
+func checkChannelCache(now time.Time) Component {
+	if !config.MemoryCacheEnabled {
+		return Component{Status: StatusDisabled}
+	}
+	syncedAt := model.ChannelCacheSyncedAt()
+	maxAge := int64(channelCacheStaleSyncs * config.SyncFrequency)
+	details := gin.H{"synced_at": syncedAt, "max_age_seconds": maxAge}
+	if syncedAt <= 0 {
+		return Component{Status: StatusFail, Message: "渠道缓存尚未成功同步", Details: details}
+	}
+	age := now.Unix() - syncedAt
+	details["age_seconds"] = age
+	if age > maxAge {
+		return Component{Status: StatusFail, Message: "渠道缓存同步已过期", Details: details}
+	}
+	return Component{Status: StatusOK, Details: details}
+}
+
+// checkWorkers is degraded when a worker missed its heartbeats. Workers stop
+// while draining, which is expected.
+func checkWorkers(now time.Time) Component {
+	workers := heartbeat.Snapshot(now)
+	if graceful.Draining() {
+		return Component{Status: StatusOK, Message: "停机中，后台任务已停止", Details: workers}
+	}
+	component := Component{Status: StatusOK, Details: workers}
+	for _, worker := range workers {
+		if worker.Stale {
+			component.Status = StatusDegraded
+			component.Message = "后台任务心跳超时: " + worker.Name
+			break
+		}
+	}
+	return component
+}
@@ -0,0 +1,116 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/yeying-community/router/common"
+	"github.com/yeying-community/router/common/config"
+	"github.com/yeying-community/router/common/heartbeat"
+	"github.com/yeying-community/router/internal/admin/model"
+)
+
+func serveProbe(t *testing.T, handler gin.HandlerFunc) (int, Report) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodGet, "/probe", nil)
+	handler(c)
+	var report Report
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode report: %v", err)
+	}
+	return recorder.Code, report
+}
+
+func TestReadinessChecksDatabasesRedisAndChannelCache(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=private"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(
+		&model.Channel{},
+		&model.ChannelModel{},
+		&model.GroupModelChannel{},
+		&model.ChannelModelEndpoint{},
+		&model.ChannelModelEndpointPolicy{},
+	); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+	previousDB, previousLogDB := model.DB, model.LOG_DB
+	previousRedisEnabled, previousMemoryCache := common.RedisEnabled, config.MemoryCacheEnabled
+	model.DB, model.LOG_DB = db, db
+	common.RedisEnabled = false
+	config.MemoryCacheEnabled = true
+	t.Cleanup(func() {
+		model.DB, model.LOG_DB = previousDB, previousLogDB
+		common.RedisEnabled, config.MemoryCacheEnabled = previousRedisEnabled, previousMemoryCache
+	})
+
+	if component := checkChannelCache(time.Now()); component.Status != StatusFail {
+		t.Fatalf("a cache that never synced should fail, got %+v", component)
+	}
+	model.InitChannelCache()
+	code, report := serveProbe(t, GetReadiness)
+	if code != http.StatusOK || report.Status != StatusOK {
+		t.Fatalf("readiness = %d %+v, want ok", code, report)
+	}
+	for name, want := range map[string]string{
+		"database":      StatusOK,
+		"log_database":  StatusOK,
+		"redis":         StatusDisabled,
+		"channel_cache": StatusOK,
+	} {
+		if got := report.Components[name].Status; got != want {
+			t.Fatalf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	stale := checkChannelCache(time.Now().Add(time.Duration(channelCacheStaleSyncs*config.SyncFrequency+1) * time.Second))
+	if stale.Status != StatusFail {
+		t.Fatalf("stale cache = %+v, want fail", stale)
+	}
+
+	sqlDB, _ := db.DB()
+	_ = sqlDB.Close()
+	code, report = serveProbe(t, GetReadiness)
+	if code != http.StatusServiceUnavailable || report.Status != StatusFail || report.Components["database"].Status != StatusFail {
+		t.Fatalf("readiness with a closed database = %d %+v, want 503", code, report)
+	}
+}
+
+func TestWorkerHeartbeatsDegradeReadinessNotLiveness(t *testing.T) {
+	previousMaster := config.IsMasterNode
+	t.Cleanup(func() { config.IsMasterNode = previousMaster })
+	config.IsMasterNode = true
+	heartbeat.Beat("health_test_busy", 0)
+	heartbeat.Beat("health_test_loop", time.Second)
+
+	if code, report := serveProbe(t, GetLiveness); code != http.StatusOK || len(report.Components) != 0 {
+		t.Fatalf("liveness = %d %+v, want ok without components", code, report)
+	}
+	if component := checkWorkers(time.Now()); component.Status != StatusOK {
+		t.Fatalf("workers = %+v, want ok", component)
+	}
+	component := checkWorkers(time.Now().Add(time.Hour))
+	if component.Status != StatusDegraded || component.Message != "后台任务心跳超时: health_test_loop" {
+		t.Fatalf("workers an hour later = %+v, want only the looping worker stale", component)
+	}
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	respond(c, "", map[string]Component{"database": {Status: StatusOK}, "workers": component})
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"status":"degraded"`) {
+		t.Fatalf("readiness with a stale worker = %d %s, want 200 degraded", recorder.Code, recorder.Body.String())
+	}
+}
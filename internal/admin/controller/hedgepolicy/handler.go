@@ -0,0 +1,93 @@
+package hedgepolicy
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeying-community/router/internal/admin/model"
+	"gorm.io/gorm"
+)
+
+type upsertHedgePolicyRequest struct {
+	GroupID string `json:"group_id"`
+	Model   string `json:"model"`
+	DelayMs int    `json:"delay_ms"`
+	Enabled *bool  `json:"enabled"`
+}
+
+func GetHedgePolicies(c *gin.Context) {
+	rows, err := model.ListHedgePolicies(c.Query("group_id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    rows,
+	})
+}
+
+func SaveHedgePolicy(c *gin.Context) {
+	req := upsertHedgePolicyRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	row, err := model.SaveHedgePolicy(model.HedgePolicy{
+		GroupID: req.GroupID,
+		Model:   req.Model,
+		DelayMs: req.DelayMs,
+		Enabled: enabled,
+	})
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    row,
+	})
+}
+
+func DeleteHedgePolicy(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "对冲策略 ID 不能为空",
+		})
+		return
+	}
+	if err := model.DeleteHedgePolicy(id); err != nil {
+		message := err.Error()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			message = "对冲策略不存在"
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": message,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
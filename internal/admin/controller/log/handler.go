@@ -565,6 +565,41 @@ func GetRouteAnomalies(c *gin.Context) {
 	})
 }
 
+func GetRelayLatencyStats(c *gin.Context) {
+	now := int64(0)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	if endTimestamp <= 0 {
+		now = helper.GetTimestamp()
+		endTimestamp = now
+	}
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	if startTimestamp <= 0 {
+		startTimestamp = endTimestamp - 24*60*60
+	}
+	groupBy := model.RelayLatencyGroupByModel
+	if strings.TrimSpace(strings.ToLower(c.Query("group_by"))) == model.RelayLatencyGroupByChannel {
+		groupBy = model.RelayLatencyGroupByChannel
+	}
+	items, err := logsvc.GetRelayLatencyStats(groupBy, startTimestamp, endTimestamp, c.Query("model_name"), c.Query("channel"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "message": "加载延迟统计失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"group_by":           groupBy,
+			"start_timestamp":    startTimestamp,
+			"end_timestamp":      endTimestamp,
+			"items":              items,
+			"total":              len(items),
+			"generated_at":       helper.GetTimestamp(),
+			"used_default_range": now > 0,
+		},
+	})
+}
+
 func GetAllLogs(c *gin.Context) {
 	page, _ := strconv.Atoi(c.Query("page"))
 	if page < 1 {
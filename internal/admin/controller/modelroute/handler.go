@@ -0,0 +1,95 @@
+package modelroute
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeying-community/router/internal/admin/model"
+	"gorm.io/gorm"
+)
+
+type upsertModelRouteRequest struct {
+	GroupID string `json:"group_id"`
+	Model   string `json:"model"`
+	Kind    string `json:"kind"`
+	Enabled *bool  `json:"enabled"`
+	Targets string `json:"targets"`
+}
+
+func GetModelRoutes(c *gin.Context) {
+	rows, err := model.ListModelRoutes(c.Query("group_id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    rows,
+	})
+}
+
+func SaveModelRoute(c *gin.Context) {
+	req := upsertModelRouteRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	row, err := model.SaveModelRoute(model.ModelRoute{
+		GroupID: req.GroupID,
+		Model:   req.Model,
+		Kind:    req.Kind,
+		Enabled: enabled,
+		Targets: req.Targets,
+	})
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    row,
+	})
+}
+
+func DeleteModelRoute(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "模型路由 ID 不能为空",
+		})
+		return
+	}
+	if err := model.DeleteModelRoute(id); err != nil {
+		message := err.Error()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			message = "模型路由不存在"
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": message,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
@@ -0,0 +1,101 @@
+package moderation
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeying-community/router/internal/admin/model"
+	"gorm.io/gorm"
+)
+
+type upsertModerationPolicyRequest struct {
+	Scope     string `json:"scope"`
+	ScopeID   string `json:"scope_id"`
+	Enabled   *bool  `json:"enabled"`
+	Action    string `json:"action"`
+	Keywords  string `json:"keywords"`
+	Patterns  string `json:"patterns"`
+	Model     string `json:"model"`
+	ChannelID string `json:"channel_id"`
+}
+
+func GetModerationPolicies(c *gin.Context) {
+	rows, err := model.ListModerationPolicies(c.Query("scope"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    rows,
+	})
+}
+
+func SaveModerationPolicy(c *gin.Context) {
+	req := upsertModerationPolicyRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	row, err := model.SaveModerationPolicy(model.ModerationPolicy{
+		Scope:     req.Scope,
+		ScopeID:   req.ScopeID,
+		Enabled:   enabled,
+		Action:    req.Action,
+		Keywords:  req.Keywords,
+		Patterns:  req.Patterns,
+		Model:     req.Model,
+		ChannelID: req.ChannelID,
+	})
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    row,
+	})
+}
+
+func DeleteModerationPolicy(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "审核策略 ID 不能为空",
+		})
+		return
+	}
+	if err := model.DeleteModerationPolicy(id); err != nil {
+		message := err.Error()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			message = "审核策略不存在"
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": message,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
@@ -34,6 +34,8 @@ type upsertServicePackageRequest struct {
 	PeriodLimit                *int64   `json:"period_limit"`
 	MaxConcurrencyPerUser      *int     `json:"max_concurrency_per_user"`
 	MaxConcurrencyPerPackage   *int     `json:"max_concurrency_per_package"`
+	RPMLimitPerUser            *int     `json:"rpm_limit_per_user"`
+	TPMLimitPerUser            *int     `json:"tpm_limit_per_user"`
 	AllowBalanceFallback       *bool    `json:"allow_balance_fallback"`
 	VisibilityScope            *string  `json:"visibility_scope"`
 	VisibleUserIDs             []string `json:"visible_user_ids"`
@@ -195,6 +197,8 @@ func CreatePackage(c *gin.Context) {
 		PeriodLimit:                optionalInt64Value(req.PeriodLimit, 0),
 		MaxConcurrencyPerUser:      optionalIntValue(req.MaxConcurrencyPerUser, 0),
 		MaxConcurrencyPerPackage:   optionalIntValue(req.MaxConcurrencyPerPackage, 0),
+		RPMLimitPerUser:            optionalIntValue(req.RPMLimitPerUser, 0),
+		TPMLimitPerUser:            optionalIntValue(req.TPMLimitPerUser, 0),
 		AllowBalanceFallback:       optionalBoolValue(req.AllowBalanceFallback, false),
 		VisibilityScope:            optionalStringValue(req.VisibilityScope, model.ServicePackageVisibilityScopeAll),
 		VisibleUserIDs:             req.VisibleUserIDs,
@@ -264,6 +268,8 @@ func UpdatePackage(c *gin.Context) {
 		PeriodLimit:                optionalInt64Value(req.PeriodLimit, current.PeriodLimit),
 		MaxConcurrencyPerUser:      optionalIntValue(req.MaxConcurrencyPerUser, current.MaxConcurrencyPerUser),
 		MaxConcurrencyPerPackage:   optionalIntValue(req.MaxConcurrencyPerPackage, current.MaxConcurrencyPerPackage),
+		RPMLimitPerUser:            optionalIntValue(req.RPMLimitPerUser, current.RPMLimitPerUser),
+		TPMLimitPerUser:            optionalIntValue(req.TPMLimitPerUser, current.TPMLimitPerUser),
 		AllowBalanceFallback:       optionalBoolValue(req.AllowBalanceFallback, current.AllowBalanceFallback),
 		VisibilityScope:            optionalStringValue(req.VisibilityScope, current.VisibilityScope),
 		VisibleUserIDs:             req.VisibleUserIDs,
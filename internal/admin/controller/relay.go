@@ -13,8 +13,10 @@ import (
 	"github.com/yeying-community/router/common"
 	"github.com/yeying-community/router/common/config"
 	"github.com/yeying-community/router/common/ctxkey"
+	"github.com/yeying-community/router/common/graceful"
 	"github.com/yeying-community/router/common/helper"
 	"github.com/yeying-community/router/common/logger"
+	"github.com/yeying-community/router/common/tracing"
 	adminchannel "github.com/yeying-community/router/internal/admin/controller/channel"
 	dbmodel "github.com/yeying-community/router/internal/admin/model"
 	"github.com/yeying-community/router/internal/admin/monitor"
@@ -23,8 +25,11 @@ import (
 	relaylogging "github.com/yeying-community/router/internal/relay/logging"
 	"github.com/yeying-community/router/internal/relay/model"
 	"github.com/yeying-community/router/internal/relay/relaymode"
+	"github.com/yeying-community/router/internal/relay/responsestate"
 	"github.com/yeying-community/router/internal/relay/routeobs"
+	"github.com/yeying-community/router/internal/relay/timing"
 	"github.com/yeying-community/router/internal/transport/http/middleware"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func relayHelper(c *gin.Context, relayMode int) *model.ErrorWithStatusCode {
@@ -34,6 +39,8 @@ func relayHelper(c *gin.Context, relayMode int) *model.ErrorWithStatusCode {
 		err = controller.RelayImageHelper(c, relayMode)
 	case relaymode.ImagesEdits:
 		err = controller.RelayImageHelper(c, relayMode)
+	case relaymode.ImagesVariations:
+		err = controller.RelayImageHelper(c, relayMode)
 	case relaymode.AudioSpeech:
 		fallthrough
 	case relaymode.AudioTranslation:
@@ -44,6 +51,8 @@ func relayHelper(c *gin.Context, relayMode int) *model.ErrorWithStatusCode {
 		err = controller.RelayRealtimeHelper(c)
 	case relaymode.Videos:
 		err = controller.RelayVideoHelper(c, relayMode)
+	case relaymode.Rerank:
+		err = controller.RelayRerankHelper(c)
 	case relaymode.Proxy:
 		err = controller.RelayProxyHelper(c, relayMode)
 	default:
@@ -52,6 +61,86 @@ func relayHelper(c *gin.Context, relayMode int) *model.ErrorWithStatusCode {
 	return err
 }
 
+// relayChannelAttempt relays once on the channel selected in the context,
+// hedged on a second channel outside excludedChannelIDs when the group's
+// hedge policy asks for it.
+func relayChannelAttempt(c *gin.Context, relayMode int, excludedChannelIDs map[string]struct{}) *model.ErrorWithStatusCode {
+	if delay := hedgeDelay(c, relayMode); delay > 0 {
+		return relayHedgedAttempt(c, relayMode, delay, excludedChannelIDs)
+	}
+	return relaySingleAttempt(c, relayMode)
+}
+
+// relaySingleAttempt relays on the channel selected in the context within
+// the channel's limits and keeps the in-flight count and latency used by
+// channel selection up to date. A successful attempt also pins the request's
+// session to the channel.
+func relaySingleAttempt(c *gin.Context, relayMode int) *model.ErrorWithStatusCode {
+	channelID := c.GetString(ctxkey.ChannelId)
+	span, endSpan := tracing.StartRequestSpan(c, "relay.attempt",
+		attribute.Int("router.attempt", c.GetInt(ctxkey.RelayRetryCount)),
+		attribute.String("router.channel_id", channelID),
+		attribute.String("router.channel_name", c.GetString(ctxkey.ChannelName)),
+		attribute.String("router.channel_key_id", c.GetString(ctxkey.ChannelKeyId)),
+		attribute.String("router.model", c.GetString(ctxkey.OriginalModel)),
+	)
+	defer endSpan()
+	timing.Begin(c)
+	release, reason, ok := dbmodel.AcquireChannelLimit(channelID, c.GetString(ctxkey.OriginalModel))
+	if !ok {
+		// another request took the last slot after this channel was selected
+		return &model.ErrorWithStatusCode{
+			StatusCode: http.StatusTooManyRequests,
+			Error: model.Error{
+				Message: fmt.Sprintf("渠道已达到%s上限", channelLimitReasonLabel(reason)),
+				Type:    "one_api_error",
+				Code:    channelLimitSaturatedCode,
+			},
+		}
+	}
+	defer release()
+	finish := dbmodel.BeginChannelRequest(channelID)
+	success := false
+	defer func() { finish(success) }()
+	keyID := c.GetString(ctxkey.ChannelKeyId)
+	defer dbmodel.BeginChannelKeyRequest(keyID)()
+	bizErr := relayHelper(c, relayMode)
+	success = bizErr == nil
+	span.SetAttributes(attribute.Int("router.upstream_status", c.GetInt(ctxkey.UpstreamStatus)))
+	if bizErr != nil {
+		span.SetAttributes(
+			attribute.Int("http.response.status_code", bizErr.StatusCode),
+			attribute.String("router.error_code", errorCodeString(bizErr.Code)),
+		)
+		tracing.Fail(span, bizErr.Message)
+	}
+	if success {
+		dbmodel.RecordChannelKeyOutcome(keyID, true)
+		responsestate.StoreSessionRoute(c.GetString(ctxkey.SessionAffinityKey), channelID)
+	}
+	return bizErr
+}
+
+const channelLimitSaturatedCode = "channel_limit_saturated"
+
+func channelLimitReasonLabel(reason string) string {
+	switch reason {
+	case "concurrency":
+		return "并发"
+	case "rpm":
+		return "每分钟请求数"
+	case "tpm":
+		return "每分钟 Token 数"
+	}
+	return "限额"
+}
+
+// isChannelLimitRelayError reports whether the attempt was turned away by the
+// router's own channel limits before reaching the upstream.
+func isChannelLimitRelayError(err *model.ErrorWithStatusCode) bool {
+	return err != nil && errorCodeString(err.Code) == channelLimitSaturatedCode
+}
+
 func Relay(c *gin.Context) {
 	ctx := c.Request.Context()
 	c.Set(ctxkey.RelayRetryCount, 0)
@@ -61,6 +150,10 @@ func Relay(c *gin.Context) {
 	c.Set(ctxkey.RelayTermination, "")
 	routeobs.Reset(c)
 	relayMode := getEffectiveRelayMode(c)
+	if bizErr := screenRelayRequest(c); bizErr != nil {
+		abortModerationBlockedRequest(c, bizErr)
+		return
+	}
 	if config.DebugEnabled {
 		requestBody, _ := common.GetRequestBody(c)
 		logger.Debugf(
@@ -73,12 +166,15 @@ func Relay(c *gin.Context) {
 	userId := c.GetString(ctxkey.Id)
 	requestPath := c.Request.URL.Path
 	originalModel := c.GetString(ctxkey.OriginalModel)
-	bizErr := relayHelper(c, relayMode)
+	bizErr := relayChannelAttempt(c, relayMode, nil)
 	if bizErr == nil {
-		clearRuntimeCapabilityFailureWindow(channelId, originalModel, requestPath)
-		monitor.Emit(channelId, true)
+		// a hedged attempt may have been served by another channel
+		servedChannelId := c.GetString(ctxkey.ChannelId)
+		clearRuntimeCapabilityFailureWindow(servedChannelId, originalModel, requestPath)
+		monitor.Emit(servedChannelId, true)
 		return
 	}
+	channelId = c.GetString(ctxkey.ChannelId)
 	lastFailedChannelId := channelId
 	channelName := c.GetString(ctxkey.ChannelName)
 	group := c.GetString(ctxkey.Group)
@@ -89,16 +185,23 @@ func Relay(c *gin.Context) {
 	if trimmedChannelID := strings.TrimSpace(channelId); trimmedChannelID != "" {
 		failedChannelIDs[trimmedChannelID] = struct{}{}
 	}
+	if hedgeChannelID := strings.TrimSpace(c.GetString(ctxkey.RelayHedgeChannelId)); hedgeChannelID != "" {
+		failedChannelIDs[hedgeChannelID] = struct{}{}
+	}
 	appendFallbackFailureAttempt(c, 1, bizErr)
-	go processChannelRelayError(ctx, userId, group, channelId, channelName, originalModel, requestPath, *bizErr)
+	processChannelRelayErrorAsync(ctx, userId, group, channelId, channelName, c.GetString(ctxkey.ChannelKeyId), originalModel, requestPath, *bizErr)
 	traceID := c.GetString(helper.TraceIDKey)
-	retryAllRemainingCandidates := config.RetryTimes > 0 || monitor.IsHardChannelFailure(&bizErr.Error, bizErr.StatusCode)
+	retryAllRemainingCandidates := config.RetryTimes > 0 || monitor.IsHardChannelFailure(&bizErr.Error, bizErr.StatusCode) || isChannelLimitRelayError(bizErr)
 	retryCount := 0
+	servedModel := originalModel
+	fallbackModels := middleware.RemainingFallbackModels(c, group, originalModel)
 	retryable := shouldRetry(c, bizErr)
 	if !retryable {
 		skipReason := "status_not_retryable"
 		if isStatefulResponsesRequest(c) {
 			skipReason = "stateful_responses_request"
+		} else if controller.IsStructuredOutputValidationError(bizErr) {
+			skipReason = "structured_output_invalid"
 		}
 		logger.RelayWarnf(ctx, relaylogging.NewFields("RETRY").
 			String("decision", "skip").
@@ -114,13 +217,13 @@ func Relay(c *gin.Context) {
 		retryAllRemainingCandidates = false
 	}
 	for retryAllRemainingCandidates {
-		channel, selectionStats, err := dbmodel.CacheSelectRandomSatisfiedChannelForRequestExcluding(group, originalModel, requestPath, false, failedChannelIDs)
+		channel, selectionStats, err := dbmodel.CacheSelectRandomSatisfiedChannelForRequestExcluding(group, servedModel, requestPath, middleware.RequestFeatures(c), false, failedChannelIDs)
 		if err != nil {
 			fields := relaylogging.NewFields("RETRY").
 				String("decision", "select_failed").
 				String("user_id", userId).
 				String("group", group).
-				String("model", originalModel).
+				String("model", servedModel).
 				String("endpoint", requestPath).
 				String("reason", resolveRetrySelectionFailureReason(selectionStats)).
 				String("selection_scope", selectionStats.SelectionScope).
@@ -135,7 +238,24 @@ func Relay(c *gin.Context) {
 			} else {
 				logger.RelayWarnf(ctx, fields.Build())
 			}
-			break
+			nextModel, ok := nextFallbackModel(c, &fallbackModels)
+			if !ok {
+				break
+			}
+			logger.RelayWarnf(ctx, relaylogging.NewFields("RETRY").
+				String("decision", "model_hop").
+				String("user_id", userId).
+				String("group", group).
+				String("endpoint", requestPath).
+				String("from_model", servedModel).
+				String("to_model", nextModel).
+				Int("model_hop", c.GetInt(ctxkey.RelayModelHop)).
+				Build())
+			servedModel = nextModel
+			// Failures were specific to the previous model, so every channel
+			// gets a fresh chance to serve the next one.
+			failedChannelIDs = map[string]struct{}{}
+			continue
 		}
 		retryCount++
 		c.Set(ctxkey.RelayRetryCount, retryCount)
@@ -144,26 +264,28 @@ func Relay(c *gin.Context) {
 			Int("attempt", retryCount).
 			String("user_id", userId).
 			String("group", group).
-			String("model", originalModel).
+			String("model", servedModel).
 			String("endpoint", requestPath).
 			String("from_channel_id", lastFailedChannelId).
 			String("to_channel_id", channel.Id).
 			String("to_channel_name", channel.DisplayName()).
 			String("selection_scope", selectionStats.SelectionScope).
+			String("selection_strategy", selectionStats.SelectionStrategy).
 			Int("selected_priority", priorityToInt(selectionStats.SelectedPriority)).
 			Int("tier_candidates", selectionStats.SelectedTierCandidates).
 			Int("remaining_candidates", selectionStats.RemainingCandidates).
 			Int("total_candidates", selectionStats.TotalCandidates).
 			Int("failed_channels", len(failedChannelIDs)).
 			Build())
-		middleware.SetupContextForSelectedChannel(c, channel, originalModel)
+		middleware.SetupContextForSelectedChannel(c, channel, servedModel)
 		requestBody, err := common.GetRequestBody(c)
 		c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
 		relayMode = getEffectiveRelayMode(c)
-		bizErr = relayHelper(c, relayMode)
+		bizErr = relayChannelAttempt(c, relayMode, failedChannelIDs)
 		if bizErr == nil {
-			clearRuntimeCapabilityFailureWindow(channel.Id, originalModel, requestPath)
-			monitor.Emit(channel.Id, true)
+			servedChannelId := c.GetString(ctxkey.ChannelId)
+			clearRuntimeCapabilityFailureWindow(servedChannelId, servedModel, requestPath)
+			monitor.Emit(servedChannelId, true)
 			return
 		}
 		if markClientAbortIfNeeded(c, bizErr) {
@@ -175,8 +297,11 @@ func Relay(c *gin.Context) {
 		if trimmedChannelID := strings.TrimSpace(channelId); trimmedChannelID != "" {
 			failedChannelIDs[trimmedChannelID] = struct{}{}
 		}
+		if hedgeChannelID := strings.TrimSpace(c.GetString(ctxkey.RelayHedgeChannelId)); hedgeChannelID != "" {
+			failedChannelIDs[hedgeChannelID] = struct{}{}
+		}
 		appendFallbackFailureAttempt(c, retryCount+1, bizErr)
-		go processChannelRelayError(ctx, userId, group, channelId, channelName, originalModel, requestPath, *bizErr)
+		processChannelRelayErrorAsync(ctx, userId, group, channelId, channelName, c.GetString(ctxkey.ChannelKeyId), servedModel, requestPath, *bizErr)
 	}
 	if bizErr != nil {
 		normalizeFinalRelayError(bizErr)
@@ -215,42 +340,55 @@ func buildRelayFailureLog(c *gin.Context, bizErr *model.ErrorWithStatusCode, ret
 	if requestModel == "" {
 		requestModel = strings.TrimSpace(c.GetString(ctxkey.RequestModel))
 	}
+	clientModel := strings.TrimSpace(c.GetString(ctxkey.ClientRequestModel))
+	if clientModel == "" {
+		clientModel = requestModel
+	}
 	channelID := strings.TrimSpace(c.GetString(ctxkey.ChannelId))
 	return &dbmodel.Log{
-		UserId:            userID,
-		GroupId:           strings.TrimSpace(c.GetString(ctxkey.Group)),
-		ChannelId:         channelID,
-		ModelName:         requestModel,
-		TokenName:         strings.TrimSpace(c.GetString(ctxkey.TokenName)),
-		Quota:             0,
-		BillingSource:     "",
-		Content:           "relay request failed before settlement",
-		RequestModelName:  requestModel,
-		ActualModelName:   requestModel,
-		UpstreamEndpoint:  c.Request.URL.Path,
-		UpstreamProtocol:  relayProtocolName(c),
-		RouteDecision:     routeobs.FinalizedRouteDecisionJSON(c),
-		FallbackCount:     retryCount,
-		FallbackAttempts:  strings.TrimSpace(c.GetString(ctxkey.RelayFallbackAttempts)),
-		RelayErrorType:    strings.TrimSpace(bizErr.Error.Type),
-		RelayErrorCode:    errorCodeString(bizErr.Error.Code),
-		RelayErrorMessage: strings.TrimSpace(bizErr.Error.Message),
-		ElapsedTime:       0,
-		IsStream:          false,
+		UserId:             userID,
+		GroupId:            strings.TrimSpace(c.GetString(ctxkey.Group)),
+		ChannelId:          channelID,
+		ModelName:          requestModel,
+		TokenName:          strings.TrimSpace(c.GetString(ctxkey.TokenName)),
+		Quota:              0,
+		BillingSource:      "",
+		Content:            "relay request failed before settlement",
+		RequestModelName:   clientModel,
+		ActualModelName:    requestModel,
+		UpstreamEndpoint:   c.Request.URL.Path,
+		UpstreamProtocol:   relayProtocolName(c),
+		RouteDecision:      routeobs.FinalizedRouteDecisionJSON(c),
+		FallbackCount:      retryCount,
+		FallbackAttempts:   strings.TrimSpace(c.GetString(ctxkey.RelayFallbackAttempts)),
+		RelayErrorType:     strings.TrimSpace(bizErr.Error.Type),
+		RelayErrorCode:     errorCodeString(bizErr.Error.Code),
+		RelayErrorMessage:  strings.TrimSpace(bizErr.Error.Message),
+		ModerationDecision: strings.TrimSpace(c.GetString(ctxkey.ModerationDecision)),
+		ModerationReason:   strings.TrimSpace(c.GetString(ctxkey.ModerationReason)),
+		ElapsedTime:        0,
+		IsStream:           false,
 	}
 }
 
 func appendFallbackFailureAttempt(c *gin.Context, attempt int, bizErr *model.ErrorWithStatusCode) {
-	if c == nil || bizErr == nil {
+	appendFallbackFailureAttemptFrom(c, c, attempt, bizErr)
+}
+
+// appendFallbackFailureAttemptFrom records on c a failure of the attempt that
+// ran in source, which differs from c for hedged attempts.
+func appendFallbackFailureAttemptFrom(c *gin.Context, source *gin.Context, attempt int, bizErr *model.ErrorWithStatusCode) {
+	if c == nil || source == nil || bizErr == nil {
 		return
 	}
 	routeobs.AppendFallbackAttempt(c, routeobs.FallbackAttempt{
 		Attempt:     attempt,
-		ChannelID:   c.GetString(ctxkey.ChannelId),
-		ChannelName: c.GetString(ctxkey.ChannelName),
-		Model:       c.GetString(ctxkey.OriginalModel),
-		Endpoint:    c.Request.URL.Path,
-		Protocol:    relayProtocolName(c),
+		ChannelID:   source.GetString(ctxkey.ChannelId),
+		ChannelName: source.GetString(ctxkey.ChannelName),
+		Model:       source.GetString(ctxkey.OriginalModel),
+		ModelHop:    source.GetInt(ctxkey.RelayModelHop),
+		Endpoint:    source.Request.URL.Path,
+		Protocol:    relayProtocolName(source),
 		Status:      bizErr.StatusCode,
 		ErrorType:   bizErr.Error.Type,
 		ErrorCode:   errorCodeString(bizErr.Error.Code),
@@ -258,6 +396,25 @@ func appendFallbackFailureAttempt(c *gin.Context, attempt int, bizErr *model.Err
 	})
 }
 
+// nextFallbackModel switches the request to the next usable model of the
+// fallback chain once every channel of the current model has failed. The
+// chain ends early when the request body cannot carry another model.
+func nextFallbackModel(c *gin.Context, fallbackModels *[]string) (string, bool) {
+	for len(*fallbackModels) > 0 {
+		next := (*fallbackModels)[0]
+		*fallbackModels = (*fallbackModels)[1:]
+		if !middleware.ModelAllowedForToken(c, next) {
+			continue
+		}
+		if !middleware.RewriteRequestModel(c, next) {
+			return "", false
+		}
+		c.Set(ctxkey.RelayModelHop, c.GetInt(ctxkey.RelayModelHop)+1)
+		return next, true
+	}
+	return "", false
+}
+
 func relayProtocolName(c *gin.Context) string {
 	if c == nil {
 		return ""
@@ -282,7 +439,7 @@ func shouldRetry(c *gin.Context, bizErr *model.ErrorWithStatusCode) bool {
 		return false
 	}
 	switch getEffectiveRelayMode(c) {
-	case relaymode.ImagesGenerations, relaymode.ImagesEdits:
+	case relaymode.ImagesGenerations, relaymode.ImagesEdits, relaymode.ImagesVariations:
 		return false
 	}
 	if isStatefulResponsesRequest(c) {
@@ -294,6 +451,9 @@ func shouldRetry(c *gin.Context, bizErr *model.ErrorWithStatusCode) bool {
 	if controller.IsGroupDailyQuotaExceededError(bizErr) {
 		return false
 	}
+	if controller.IsStructuredOutputValidationError(bizErr) {
+		return false
+	}
 	if isRelayCapabilityError(bizErr) {
 		return true
 	}
@@ -410,6 +570,23 @@ func isLocalQuotaRelayError(err *model.ErrorWithStatusCode) bool {
 	}
 }
 
+// isChannelKeyRelayError reports whether the error counts against the pool key
+// that served the request: rejected credentials, rate limits, upstream
+// failures and transport errors. Other 4xx are caused by the client request.
+func isChannelKeyRelayError(err *model.ErrorWithStatusCode) bool {
+	if err == nil {
+		return false
+	}
+	switch {
+	case err.StatusCode == http.StatusUnauthorized,
+		err.StatusCode == http.StatusForbidden,
+		err.StatusCode == http.StatusTooManyRequests:
+		return true
+	default:
+		return err.StatusCode == 0 || err.StatusCode >= http.StatusInternalServerError
+	}
+}
+
 func isUpstreamQuotaRelayError(err *model.ErrorWithStatusCode) bool {
 	if err == nil {
 		return false
@@ -487,7 +664,23 @@ func resolveRetrySelectionFailureReason(stats dbmodel.SatisfiedChannelSelectionS
 	return "selector_error"
 }
 
-func processChannelRelayError(ctx context.Context, userId string, groupID string, channelId string, channelName string, requestModel string, requestPath string, err model.ErrorWithStatusCode) {
+// processChannelRelayErrorAsync processes err in the background, with the
+// arguments taken now; shutdown waits for it.
+func processChannelRelayErrorAsync(ctx context.Context, userId string, groupID string, channelId string, channelName string, keyID string, requestModel string, requestPath string, err model.ErrorWithStatusCode) {
+	graceful.Go(func() {
+		processChannelRelayError(ctx, userId, groupID, channelId, channelName, keyID, requestModel, requestPath, err)
+	})
+}
+
+func processChannelRelayError(ctx context.Context, userId string, groupID string, channelId string, channelName string, keyID string, requestModel string, requestPath string, err model.ErrorWithStatusCode) {
+	if isChannelLimitRelayError(&err) {
+		// the upstream never saw the request
+		return
+	}
+	if controller.IsStructuredOutputValidationError(&err) {
+		// the channel answered; the output just did not match the schema
+		return
+	}
 	msg := relaylogging.NewFields("UPSTREAM_ERR").
 		String("channel_id", channelId).
 		String("channel_name", channelName).
@@ -522,6 +715,15 @@ func processChannelRelayError(ctx context.Context, userId string, groupID string
 			Build())
 		return
 	}
+	if keyID != "" && !isRelayContextCanceledError(&err) {
+		if isChannelKeyRelayError(&err) {
+			dbmodel.RecordChannelKeyOutcome(keyID, false)
+		}
+		if monitor.IsInsufficientBalanceError(&err.Error, err.StatusCode) && disableUpstreamQuotaChannelKey(ctx, channelId, channelName, keyID, err) {
+			// the rest of the pool still serves the channel
+			return
+		}
+	}
 	if isUpstreamQuotaRelayError(&err) {
 		disableUpstreamQuotaChannelModelEndpoint(ctx, channelId, channelName, requestModel, requestPath, err)
 		return
@@ -558,6 +760,30 @@ func processChannelRelayError(ctx context.Context, userId string, groupID string
 	}
 }
 
+// disableUpstreamQuotaChannelKey takes the pool key whose upstream account ran
+// out of balance out of rotation and reports whether other keys remain.
+func disableUpstreamQuotaChannelKey(ctx context.Context, channelId string, channelName string, keyID string, err model.ErrorWithStatusCode) bool {
+	reason := "上游余额不足，自动禁用该密钥：" + strings.TrimSpace(err.Message)
+	if disableErr := dbmodel.DisableChannelKey(keyID, reason); disableErr != nil {
+		logger.RelayErrorf(ctx, relaylogging.NewFields("CHANNEL_KEY_DISABLE").
+			String("channel_id", channelId).
+			String("channel_name", channelName).
+			String("key_id", keyID).
+			String("error", disableErr.Error()).
+			Build())
+		return false
+	}
+	remaining := dbmodel.ChannelKeyPoolAvailable(channelId, keyID)
+	logger.RelayWarnf(ctx, relaylogging.NewFields("CHANNEL_KEY_DISABLE").
+		String("channel_id", channelId).
+		String("channel_name", channelName).
+		String("key_id", keyID).
+		String("reason", reason).
+		String("pool_available", fmt.Sprint(remaining)).
+		Build())
+	return remaining
+}
+
 func disableUpstreamQuotaChannelModelEndpoint(ctx context.Context, channelId string, channelName string, requestModel string, requestPath string, err model.ErrorWithStatusCode) {
 	normalizedEndpoint := dbmodel.NormalizeRequestedChannelModelEndpoint(requestPath)
 	reason := upstreamQuotaEndpointDisableReason(err)
@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yeying-community/router/common"
+	"github.com/yeying-community/router/common/ctxkey"
+	"github.com/yeying-community/router/common/logger"
+	relaymodel "github.com/yeying-community/router/internal/relay/model"
+	"github.com/yeying-community/router/internal/relay/relaymode"
+	"github.com/yeying-community/router/internal/tokenestimate"
+	"github.com/yeying-community/router/internal/transport/http/middleware"
+)
+
+// RelayGemini serves the native Gemini routes. GeminiIngress has already
+// rewritten the request into its OpenAI form, so everything except
+// countTokens goes through the regular relay.
+func RelayGemini(c *gin.Context) {
+	if c.GetString(ctxkey.GeminiAction) != middleware.GeminiActionCountTokens {
+		Relay(c)
+		return
+	}
+	requestBody, err := common.GetRequestBody(c)
+	if err != nil {
+		abortGeminiCountTokens(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	request := &relaymodel.GeneralOpenAIRequest{}
+	if err := json.Unmarshal(requestBody, request); err != nil {
+		abortGeminiCountTokens(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	result, err := tokenestimate.Estimate(tokenestimate.EstimateRequest{
+		RelayMode: relaymode.ChatCompletions,
+		Model:     request.Model,
+		RawBody:   requestBody,
+		Request:   request,
+	})
+	if err != nil {
+		logger.Errorf(c.Request.Context(), "[RelayGemini] count tokens failed model=%s err=%v", request.Model, err)
+		abortGeminiCountTokens(c, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"totalTokens": result.PromptTokens})
+}
+
+func abortGeminiCountTokens(c *gin.Context, statusCode int, errorType string, message string) {
+	c.JSON(statusCode, gin.H{
+		"error": relaymodel.Error{
+			Message: message,
+			Type:    errorType,
+			Code:    "count_tokens_failed",
+		},
+	})
+}
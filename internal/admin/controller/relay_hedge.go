@@ -0,0 +1,197 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeying-community/router/common"
+	"github.com/yeying-community/router/common/ctxkey"
+	"github.com/yeying-community/router/common/logger"
+	dbmodel "github.com/yeying-community/router/internal/admin/model"
+	"github.com/yeying-community/router/internal/relay/hedge"
+	relaylogging "github.com/yeying-community/router/internal/relay/logging"
+	"github.com/yeying-community/router/internal/relay/model"
+	"github.com/yeying-community/router/internal/relay/relaymode"
+	"github.com/yeying-community/router/internal/transport/http/middleware"
+)
+
+const (
+	hedgeRolePrimary = "primary"
+	hedgeRoleHedge   = "hedge"
+)
+
+// hedgeDelay returns how long the attempt on the selected channel may go
+// without starting its response before it is hedged, 0 when it is not.
+// Only stateless text relays can be replayed on a second channel.
+func hedgeDelay(c *gin.Context, relayMode int) time.Duration {
+	switch relayMode {
+	case relaymode.ChatCompletions, relaymode.Completions, relaymode.Messages, relaymode.Responses:
+	default:
+		return 0
+	}
+	if isStatefulResponsesRequest(c) {
+		return 0
+	}
+	if _, ok := c.Get(ctxkey.SpecificChannelId); ok {
+		return 0
+	}
+	delayMs := dbmodel.ResolveHedgeDelayMs(c.GetString(ctxkey.Group), c.GetString(ctxkey.OriginalModel))
+	return time.Duration(delayMs) * time.Millisecond
+}
+
+type hedgeAttempt struct {
+	role   string
+	ctx    *gin.Context
+	writer *hedge.Writer
+	bizErr *model.ErrorWithStatusCode
+}
+
+// newHedgeAttempt forks the request so the attempt can run next to another
+// one: it gets its own keys, headers, body, cancelable context and writer.
+func newHedgeAttempt(c *gin.Context, race *hedge.Race, role string) *hedgeAttempt {
+	attemptCtx := c.Copy()
+	requestCtx, cancel := context.WithCancel(c.Request.Context())
+	attemptCtx.Request = c.Request.Clone(requestCtx)
+	requestBody, _ := common.GetRequestBody(c)
+	attemptCtx.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+	writer := race.NewWriter(cancel)
+	attemptCtx.Writer = writer
+	attemptCtx.Set(ctxkey.RelayHedgeWriter, writer)
+	return &hedgeAttempt{role: role, ctx: attemptCtx, writer: writer}
+}
+
+func (attempt *hedgeAttempt) run(relayMode int, results chan<- *hedgeAttempt) {
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				logger.Errorf(attempt.ctx.Request.Context(), fmt.Sprintf("panic detected in hedged attempt: %v", err))
+				logger.Errorf(attempt.ctx.Request.Context(), fmt.Sprintf("stacktrace from panic: %s", string(debug.Stack())))
+				attempt.bizErr = &model.ErrorWithStatusCode{
+					StatusCode: http.StatusInternalServerError,
+					Error: model.Error{
+						Message: fmt.Sprintf("hedged attempt panicked: %v", err),
+						Type:    "one_api_panic",
+						Code:    "hedge_attempt_panic",
+					},
+				}
+			}
+			results <- attempt
+		}()
+		attempt.bizErr = relaySingleAttempt(attempt.ctx, relayMode)
+	}()
+}
+
+// adoptHedgeAttempt carries the state of the attempt whose outcome is
+// returned, e.g. the channel that served it, back into the request.
+func adoptHedgeAttempt(c *gin.Context, attempt *hedgeAttempt) {
+	for key, value := range attempt.ctx.Keys {
+		if key == ctxkey.RelayHedgeWriter {
+			continue
+		}
+		c.Set(key, value)
+	}
+}
+
+// relayHedgedAttempt relays on the selected channel and, when no response has
+// started after delay, sends the same request to a second candidate. The
+// first attempt to write is streamed to the client and the other one is
+// canceled; a canceled loser is neither billed nor counted as a failure.
+// When the hedge channel fails on its own, it is left in
+// ctxkey.RelayHedgeChannelId so the retry loop skips it as well.
+func relayHedgedAttempt(c *gin.Context, relayMode int, delay time.Duration, excludedChannelIDs map[string]struct{}) *model.ErrorWithStatusCode {
+	ctx := c.Request.Context()
+	c.Set(ctxkey.RelayHedgeChannelId, "")
+	race := hedge.NewRace(c.Writer)
+	defer race.Close()
+	results := make(chan *hedgeAttempt, 2)
+	primary := newHedgeAttempt(c, race, hedgeRolePrimary)
+	primary.run(relayMode, results)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case attempt := <-results:
+		adoptHedgeAttempt(c, attempt)
+		return attempt.bizErr
+	case <-timer.C:
+	}
+	if race.Winner() != nil {
+		attempt := <-results
+		adoptHedgeAttempt(c, attempt)
+		return attempt.bizErr
+	}
+
+	userID := c.GetString(ctxkey.Id)
+	group := c.GetString(ctxkey.Group)
+	modelName := c.GetString(ctxkey.OriginalModel)
+	requestPath := c.Request.URL.Path
+	primaryChannelID := c.GetString(ctxkey.ChannelId)
+	hedgeExcluded := make(map[string]struct{}, len(excludedChannelIDs)+1)
+	for channelID := range excludedChannelIDs {
+		hedgeExcluded[channelID] = struct{}{}
+	}
+	hedgeExcluded[strings.TrimSpace(primaryChannelID)] = struct{}{}
+	channel, _, err := dbmodel.CacheSelectRandomSatisfiedChannelForRequestExcluding(group, modelName, requestPath, middleware.RequestFeatures(c), false, hedgeExcluded)
+	if err != nil {
+		logger.RelayWarnf(ctx, relaylogging.NewFields("HEDGE").
+			String("decision", "skip").
+			String("reason", "no_candidate").
+			String("user_id", userID).
+			String("group", group).
+			String("model", modelName).
+			String("endpoint", requestPath).
+			String("channel_id", primaryChannelID).
+			String("error", err.Error()).
+			Build())
+		attempt := <-results
+		adoptHedgeAttempt(c, attempt)
+		return attempt.bizErr
+	}
+	secondary := newHedgeAttempt(c, race, hedgeRoleHedge)
+	middleware.SetupContextForSelectedChannel(secondary.ctx, channel, modelName)
+	logger.RelayWarnf(ctx, relaylogging.NewFields("HEDGE").
+		String("decision", "fire").
+		Int("delay_ms", int(delay/time.Millisecond)).
+		String("user_id", userID).
+		String("group", group).
+		String("model", modelName).
+		String("endpoint", requestPath).
+		String("from_channel_id", primaryChannelID).
+		String("to_channel_id", channel.Id).
+		String("to_channel_name", channel.DisplayName()).
+		Build())
+	secondary.run(relayMode, results)
+	<-results
+	<-results
+
+	winner, other := primary, secondary
+	if race.Winner() == secondary.writer {
+		winner, other = secondary, primary
+	}
+	adoptHedgeAttempt(c, winner)
+	logger.RelayInfof(ctx, relaylogging.NewFields("HEDGE").
+		String("decision", "settle").
+		String("winner", winner.role).
+		String("user_id", userID).
+		String("group", group).
+		String("model", modelName).
+		String("channel_id", winner.ctx.GetString(ctxkey.ChannelId)).
+		String("other_channel_id", other.ctx.GetString(ctxkey.ChannelId)).
+		Build())
+	if other.bizErr != nil && !other.writer.Lost() {
+		// the other attempt failed before the race was decided
+		appendFallbackFailureAttemptFrom(c, other.ctx, c.GetInt(ctxkey.RelayRetryCount)+1, other.bizErr)
+		processChannelRelayErrorAsync(ctx, userID, group, other.ctx.GetString(ctxkey.ChannelId), other.ctx.GetString(ctxkey.ChannelName), other.ctx.GetString(ctxkey.ChannelKeyId), modelName, requestPath, *other.bizErr)
+		if other == secondary {
+			c.Set(ctxkey.RelayHedgeChannelId, channel.Id)
+		}
+	}
+	return winner.bizErr
+}
@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeying-community/router/common/ctxkey"
+	"github.com/yeying-community/router/internal/relay/hedge"
+	"github.com/yeying-community/router/internal/relay/relaymode"
+)
+
+func TestHedgeAttemptIsolatesAndAdoptsRequestState(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"gpt-4o"}`))
+	c.Request.Header.Set("Authorization", "Bearer primary-key")
+	c.Set(ctxkey.KeyRequestBody, []byte(`{"model":"gpt-4o"}`))
+	c.Set(ctxkey.ChannelId, "channel-1")
+
+	race := hedge.NewRace(c.Writer)
+	defer race.Close()
+	attempt := newHedgeAttempt(c, race, hedgeRoleHedge)
+	attempt.ctx.Request.Header.Set("Authorization", "Bearer hedge-key")
+	attempt.ctx.Set(ctxkey.ChannelId, "channel-2")
+	body, err := io.ReadAll(attempt.ctx.Request.Body)
+	if err != nil || string(body) != `{"model":"gpt-4o"}` {
+		t.Fatalf("attempt body = %q, err = %v", body, err)
+	}
+	if got := c.Request.Header.Get("Authorization"); got != "Bearer primary-key" {
+		t.Fatalf("attempt leaked its headers into the request: %q", got)
+	}
+	if got := c.GetString(ctxkey.ChannelId); got != "channel-1" {
+		t.Fatalf("attempt leaked its keys into the request: %q", got)
+	}
+
+	adoptHedgeAttempt(c, attempt)
+	if got := c.GetString(ctxkey.ChannelId); got != "channel-2" {
+		t.Fatalf("adopted channel = %q, want channel-2", got)
+	}
+	if _, ok := c.Get(ctxkey.RelayHedgeWriter); ok {
+		t.Fatalf("the attempt writer must not be adopted")
+	}
+}
+
+func TestHedgeDelaySkipsPinnedAndNonTextRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/images/generations", nil)
+	if got := hedgeDelay(c, relaymode.ImagesGenerations); got != 0 {
+		t.Fatalf("image relay delay = %s, want 0", got)
+	}
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	c.Set(ctxkey.SpecificChannelId, "channel-1")
+	if got := hedgeDelay(c, relaymode.ChatCompletions); got != 0 {
+		t.Fatalf("pinned channel delay = %s, want 0", got)
+	}
+}
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeying-community/router/common"
+	"github.com/yeying-community/router/common/config"
+	"github.com/yeying-community/router/common/ctxkey"
+	"github.com/yeying-community/router/common/graceful"
+	"github.com/yeying-community/router/common/helper"
+	"github.com/yeying-community/router/common/logger"
+	dbmodel "github.com/yeying-community/router/internal/admin/model"
+	relaylogging "github.com/yeying-community/router/internal/relay/logging"
+	"github.com/yeying-community/router/internal/relay/model"
+	"github.com/yeying-community/router/internal/relay/moderation"
+)
+
+const moderationBlockedCode = "content_policy_violation"
+
+// screenRelayRequest applies the moderation policy of the token, or of its
+// group, before any channel is tried. The decision is kept on the context so
+// the request log records it; a blocked request gets an error back.
+func screenRelayRequest(c *gin.Context) *model.ErrorWithStatusCode {
+	policy, ok := dbmodel.GetEffectiveModerationPolicy(c.GetString(ctxkey.TokenId), c.GetString(ctxkey.Group))
+	if !ok || !strings.Contains(c.ContentType(), "json") {
+		return nil
+	}
+	requestBody, err := common.GetRequestBody(c)
+	if err != nil {
+		return nil
+	}
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+	ctx := c.Request.Context()
+	group := c.GetString(ctxkey.Group)
+	decision := moderation.Screen(ctx, policy, group, moderation.ExtractText(requestBody))
+	c.Set(ctxkey.ModerationDecision, decision.Action)
+	c.Set(ctxkey.ModerationReason, moderationReason(decision))
+	if decision.Action == dbmodel.ModerationDecisionPass {
+		return nil
+	}
+	userID := c.GetString(ctxkey.Id)
+	logger.RelayWarnf(ctx, relaylogging.NewFields("MODERATION").
+		String("decision", decision.Action).
+		String("source", decision.Source).
+		String("reason", decision.Reason).
+		String("policy_scope", policy.Scope).
+		String("user_id", userID).
+		String("group", group).
+		String("endpoint", c.Request.URL.Path).
+		Build())
+	if decision.Violation() {
+		graceful.Go(func() { recordModerationViolation(ctx, userID) })
+	}
+	if !decision.Blocked() {
+		return nil
+	}
+	return &model.ErrorWithStatusCode{
+		StatusCode: http.StatusBadRequest,
+		Error: model.Error{
+			Message: "request blocked by content moderation policy",
+			Type:    "invalid_request_error",
+			Code:    moderationBlockedCode,
+		},
+	}
+}
+
+func moderationReason(decision moderation.Decision) string {
+	if decision.Source == "" {
+		return ""
+	}
+	if decision.Reason == "" {
+		return decision.Source
+	}
+	return decision.Source + ":" + decision.Reason
+}
+
+func recordModerationViolation(ctx context.Context, userID string) {
+	count, disabled, err := dbmodel.RecordUserModerationViolation(userID, config.ModerationAutoDisableThreshold)
+	if err != nil {
+		logger.Errorf(ctx, "record moderation violation failed user_id=%s err=%v", userID, err)
+		return
+	}
+	if disabled {
+		logger.Warnf(ctx, "user %s disabled after %d moderation violations", userID, count)
+	}
+}
+
+func abortModerationBlockedRequest(c *gin.Context, bizErr *model.ErrorWithStatusCode) {
+	c.Set(ctxkey.RelayError, bizErr.Error.Message)
+	c.Set(ctxkey.RelayErrorType, bizErr.Error.Type)
+	c.Set(ctxkey.RelayErrorCode, moderationBlockedCode)
+	recordRelayFailureLog(c, bizErr, 0)
+	bizErr.Error.Message = helper.MessageWithTraceID(bizErr.Error.Message, c.GetString(helper.TraceIDKey))
+	c.JSON(bizErr.StatusCode, gin.H{
+		"error": bizErr.Error,
+	})
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -8,6 +9,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/yeying-community/router/common/ctxkey"
+	dbmodel "github.com/yeying-community/router/internal/admin/model"
 	relaymodel "github.com/yeying-community/router/internal/relay/model"
 	"github.com/yeying-community/router/internal/relay/routeobs"
 )
@@ -53,6 +55,24 @@ func TestShouldRetrySkipsStatefulResponses(t *testing.T) {
 	}
 }
 
+func TestStructuredOutputValidationErrorStaysOnTheChannel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	err := relaymodel.ErrorWithStatusCode{
+		StatusCode: http.StatusBadGateway,
+		Error:      relaymodel.Error{Code: "structured_output_validation_failed", Message: "schema mismatch"},
+	}
+
+	if shouldRetry(c, &err) {
+		t.Fatal("shouldRetry returned true for a structured output validation error, want false")
+	}
+	processChannelRelayError(context.Background(), "user-1", "default", "channel-structured", "primary", "", "gpt-5", "/v1/chat/completions", err)
+	if stat := dbmodel.GetChannelSelectionStat("channel-structured"); stat.Samples != 0 {
+		t.Fatalf("channel stat = %+v, want the validation error kept out of channel health", stat)
+	}
+}
+
 func TestBuildRelayFailureLogCapturesRouteFields(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -115,3 +135,21 @@ func TestBuildRelayFailureLogCapturesRouteFields(t *testing.T) {
 		t.Fatalf("unexpected relay error fields: %+v", got)
 	}
 }
+
+func TestIsChannelKeyRelayErrorIgnoresClientErrors(t *testing.T) {
+	for status, want := range map[int]bool{
+		0:                                true,
+		http.StatusBadRequest:            false,
+		http.StatusUnauthorized:          true,
+		http.StatusForbidden:             true,
+		http.StatusNotFound:              false,
+		http.StatusRequestEntityTooLarge: false,
+		http.StatusTooManyRequests:       true,
+		http.StatusInternalServerError:   true,
+		http.StatusBadGateway:            true,
+	} {
+		if got := isChannelKeyRelayError(&relaymodel.ErrorWithStatusCode{StatusCode: status}); got != want {
+			t.Fatalf("isChannelKeyRelayError(%d) = %t, want %t", status, got, want)
+		}
+	}
+}
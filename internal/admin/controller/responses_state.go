@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yeying-community/router/common/ctxkey"
+	"github.com/yeying-community/router/common/logger"
+	relaymodel "github.com/yeying-community/router/internal/relay/model"
+	"github.com/yeying-community/router/internal/relay/responsestate"
+)
+
+const (
+	defaultResponseInputItemsLimit = 20
+	maxResponseInputItemsLimit     = 100
+)
+
+func RetrieveResponse(c *gin.Context) {
+	record, ok := loadResponseStateForRequest(c)
+	if !ok {
+		return
+	}
+	c.Data(http.StatusOK, "application/json", record.Response)
+}
+
+func ListResponseInputItems(c *gin.Context) {
+	record, ok := loadResponseStateForRequest(c)
+	if !ok {
+		return
+	}
+	items, err := responseInputItemsPage(record.InputItems, c.Query("order"), c.Query("after"), c.Query("limit"))
+	if err != nil {
+		abortWithResponseStateError(c, http.StatusBadRequest, err.Error(), "invalid_request_error", "limit", "invalid_limit")
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+func DeleteResponse(c *gin.Context) {
+	responseID := strings.TrimSpace(c.Param("id"))
+	deleted, err := responsestate.Delete(responseID, c.GetString(ctxkey.Id))
+	if err != nil {
+		logger.Errorf(ginRequestContext(c), "[DeleteResponse] failed user=%s response_id=%s err=%v", c.GetString(ctxkey.Id), responseID, err)
+		abortWithResponseStateError(c, http.StatusInternalServerError, err.Error(), "server_error", "", "delete_response_failed")
+		return
+	}
+	if !deleted {
+		abortResponseNotFound(c, responseID)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":      responseID,
+		"object":  "response.deleted",
+		"deleted": true,
+	})
+}
+
+func loadResponseStateForRequest(c *gin.Context) (responsestate.Record, bool) {
+	responseID := strings.TrimSpace(c.Param("id"))
+	record, err := responsestate.Load(responseID, c.GetString(ctxkey.Id))
+	if err != nil {
+		if errors.Is(err, responsestate.ErrStateNotFound) {
+			abortResponseNotFound(c, responseID)
+			return responsestate.Record{}, false
+		}
+		logger.Errorf(ginRequestContext(c), "[RetrieveResponse] failed user=%s response_id=%s err=%v", c.GetString(ctxkey.Id), responseID, err)
+		abortWithResponseStateError(c, http.StatusInternalServerError, err.Error(), "server_error", "", "load_response_failed")
+		return responsestate.Record{}, false
+	}
+	return record, true
+}
+
+type responseInputItemsList struct {
+	Object  string            `json:"object"`
+	Data    []json.RawMessage `json:"data"`
+	FirstID string            `json:"first_id,omitempty"`
+	LastID  string            `json:"last_id,omitempty"`
+	HasMore bool              `json:"has_more"`
+}
+
+func responseInputItemsPage(items []json.RawMessage, order string, after string, limitValue string) (responseInputItemsList, error) {
+	limit := defaultResponseInputItemsLimit
+	if strings.TrimSpace(limitValue) != "" {
+		parsed, err := strconv.Atoi(strings.TrimSpace(limitValue))
+		if err != nil || parsed < 1 || parsed > maxResponseInputItemsLimit {
+			return responseInputItemsList{}, fmt.Errorf("limit must be between 1 and %d", maxResponseInputItemsLimit)
+		}
+		limit = parsed
+	}
+	ordered := make([]json.RawMessage, 0, len(items))
+	if strings.EqualFold(strings.TrimSpace(order), "asc") {
+		ordered = append(ordered, items...)
+	} else {
+		for index := len(items) - 1; index >= 0; index-- {
+			ordered = append(ordered, items[index])
+		}
+	}
+	if after = strings.TrimSpace(after); after != "" {
+		for index, item := range ordered {
+			if responseItemID(item) == after {
+				ordered = ordered[index+1:]
+				break
+			}
+		}
+	}
+	result := responseInputItemsList{Object: "list", Data: ordered}
+	if len(ordered) > limit {
+		result.Data = ordered[:limit]
+		result.HasMore = true
+	}
+	if len(result.Data) > 0 {
+		result.FirstID = responseItemID(result.Data[0])
+		result.LastID = responseItemID(result.Data[len(result.Data)-1])
+	}
+	return result, nil
+}
+
+func responseItemID(item json.RawMessage) string {
+	payload := struct {
+		ID string `json:"id"`
+	}{}
+	if err := json.Unmarshal(item, &payload); err != nil {
+		return ""
+	}
+	return payload.ID
+}
+
+func abortResponseNotFound(c *gin.Context, responseID string) {
+	abortWithResponseStateError(c, http.StatusNotFound, fmt.Sprintf("No response found with id '%s'.", responseID), "invalid_request_error", "id", "response_not_found")
+}
+
+func abortWithResponseStateError(c *gin.Context, status int, message string, errorType string, param string, code string) {
+	c.JSON(status, gin.H{
+		"error": relaymodel.Error{
+			Message: message,
+			Type:    errorType,
+			Param:   param,
+			Code:    code,
+		},
+	})
+}
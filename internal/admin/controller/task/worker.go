@@ -2,16 +2,22 @@ package task
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/yeying-community/router/common/config"
+	"github.com/yeying-community/router/common/graceful"
+	"github.com/yeying-community/router/common/heartbeat"
 	"github.com/yeying-community/router/common/helper"
 	"github.com/yeying-community/router/common/logger"
 	channel "github.com/yeying-community/router/internal/admin/controller/channel"
 	"github.com/yeying-community/router/internal/admin/model"
+	"github.com/yeying-community/router/internal/relay/batch"
 )
 
 const (
@@ -24,6 +30,7 @@ const (
 )
 
 var startAsyncTaskWorkersOnce sync.Once
+var startBatchWorkersOnce sync.Once
 var runningAsyncTaskCancels sync.Map
 
 func registerRunningAsyncTaskCancel(taskID string, cancel context.CancelFunc) {
@@ -68,18 +75,60 @@ func StartAsyncTaskWorkers() {
 			logger.Info(context.Background(), fmt.Sprintf("[async-task] recovered %d stale running tasks", rows))
 		}
 		for idx := 0; idx < asyncTaskWorkerCount; idx++ {
-			go asyncTaskWorkerLoop(idx + 1)
+			graceful.Worker(func() {
+				asyncTaskWorkerLoop(idx+1, func() (*model.AsyncTask, error) {
+					return model.ClaimNextPendingAsyncTaskWithDB(model.DB)
+				}, channel.ExecuteAsyncTask)
+			})
+		}
+		graceful.Worker(runtimeCapabilityRecoveryProbeLoop)
+		graceful.Worker(channelRecoveryProbeLoop)
+	})
+}
+
+// StartBatchWorkers runs relay batches on their own workers so long batches
+// never hold up channel tests. handler is the HTTP engine batch lines are
+// replayed through.
+func StartBatchWorkers(handler http.Handler) {
+	startBatchWorkersOnce.Do(func() {
+		errorsJSON, _ := json.Marshal([]batch.ErrorItem{{
+			Code:    "batch_interrupted",
+			Message: "The batch was interrupted by a service restart.",
+		}})
+		rows, err := model.FailInterruptedRelayBatchesWithDB(model.DB, string(errorsJSON))
+		if err != nil {
+			logger.Warn(context.Background(), fmt.Sprintf("[async-task] recover_batches_failed error=%q", err.Error()))
+		} else if rows > 0 {
+			logger.Info(context.Background(), fmt.Sprintf("[async-task] closed %d interrupted batches", rows))
+		}
+		workerCount := config.BatchWorkerCount
+		if workerCount <= 0 {
+			workerCount = 1
+		}
+		execute := func(ctx context.Context, taskRow *model.AsyncTask) (string, error) {
+			return batch.ExecuteTask(ctx, handler, taskRow)
+		}
+		for idx := 0; idx < workerCount; idx++ {
+			graceful.Worker(func() {
+				asyncTaskWorkerLoop(asyncTaskWorkerCount+idx+1, func() (*model.AsyncTask, error) {
+					return model.ClaimNextPendingAsyncTaskByTypeWithDB(model.DB, model.AsyncTaskTypeRelayBatch)
+				}, execute)
+			})
 		}
-		go runtimeCapabilityRecoveryProbeLoop()
-		go channelRecoveryProbeLoop()
 	})
 }
 
 func runtimeCapabilityRecoveryProbeLoop() {
+	heartbeat.Beat("capability_recovery_probe", 30*time.Second)
 	timer := time.NewTimer(30 * time.Second)
 	defer timer.Stop()
 	for {
-		<-timer.C
+		select {
+		case <-graceful.Stopping():
+			return
+		case <-timer.C:
+		}
+		heartbeat.Beat("capability_recovery_probe", runtimeCapabilityRecoveryProbeInterval)
 		created, err := channel.EnqueueRuntimeDisabledCapabilityRecoveryTests(runtimeCapabilityRecoveryProbeBatchSize)
 		if err != nil {
 			logger.Warn(context.Background(), fmt.Sprintf("[async-task] runtime_capability_recovery_probe_failed error=%q", err.Error()))
@@ -91,10 +140,16 @@ func runtimeCapabilityRecoveryProbeLoop() {
 }
 
 func channelRecoveryProbeLoop() {
+	heartbeat.Beat("channel_recovery_probe", 30*time.Second)
 	timer := time.NewTimer(30 * time.Second)
 	defer timer.Stop()
 	for {
-		<-timer.C
+		select {
+		case <-graceful.Stopping():
+			return
+		case <-timer.C:
+		}
+		heartbeat.Beat("channel_recovery_probe", channelRecoveryProbeInterval)
 		created, err := channel.EnqueueInsufficientBalanceChannelRecoveryTests(channelRecoveryProbeBatchSize)
 		if err != nil {
 			logger.Warn(context.Background(), fmt.Sprintf("[async-task] channel_recovery_probe_failed error=%q", err.Error()))
@@ -105,28 +160,48 @@ func channelRecoveryProbeLoop() {
 	}
 }
 
-func asyncTaskWorkerLoop(workerIndex int) {
+func asyncTaskWorkerLoop(workerIndex int, claim func() (*model.AsyncTask, error), execute func(context.Context, *model.AsyncTask) (string, error)) {
+	// the running task is finished before stopping unless it stops itself
+	// with model.ErrAsyncTaskInterrupted to be requeued; tasks still running
+	// at the shutdown deadline are failed by the next start
+	heartbeatName := fmt.Sprintf("async_task_%d", workerIndex)
 	for {
-		taskRow, err := model.ClaimNextPendingAsyncTaskWithDB(model.DB)
+		select {
+		case <-graceful.Stopping():
+			return
+		default:
+		}
+		heartbeat.Beat(heartbeatName, asyncTaskPollInterval)
+		taskRow, err := claim()
 		if err != nil {
 			logger.Warn(context.Background(), fmt.Sprintf("[async-task] worker=%d claim_failed error=%q", workerIndex, err.Error()))
-			time.Sleep(asyncTaskPollInterval)
+			graceful.Sleep(asyncTaskPollInterval)
 			continue
 		}
 		if taskRow == nil {
-			time.Sleep(asyncTaskPollInterval)
+			graceful.Sleep(asyncTaskPollInterval)
 			continue
 		}
 		ctx := context.Background()
 		if traceID := strings.TrimSpace(taskRow.TraceID); traceID != "" {
 			ctx = helper.SetTraceID(ctx, traceID)
 		}
+		// a task may run for any length of time
+		heartbeat.Beat(heartbeatName, 0)
 		execCtx, cancel := context.WithCancel(ctx)
 		registerRunningAsyncTaskCancel(taskRow.Id, cancel)
 		logger.Info(ctx, fmt.Sprintf("[async-task] worker=%d task_id=%s type=%s status=running", workerIndex, taskRow.Id, taskRow.Type))
-		result, execErr := channel.ExecuteAsyncTask(execCtx, taskRow)
+		result, execErr := execute(execCtx, taskRow)
 		unregisterRunningAsyncTaskCancel(taskRow.Id)
 		cancel()
+		if errors.Is(execErr, model.ErrAsyncTaskInterrupted) {
+			if err := model.RequeueAsyncTaskWithDB(model.DB, taskRow.Id); err != nil {
+				logger.Warn(ctx, fmt.Sprintf("[async-task] worker=%d task_id=%s requeue_failed error=%q", workerIndex, taskRow.Id, err.Error()))
+			} else {
+				logger.Info(ctx, fmt.Sprintf("[async-task] worker=%d task_id=%s type=%s status=requeued", workerIndex, taskRow.Id, taskRow.Type))
+			}
+			continue
+		}
 		finalStatus := model.AsyncTaskStatusSucceeded
 		errorMessage := ""
 		if execErr != nil {
@@ -146,7 +221,7 @@ func asyncTaskWorkerLoop(workerIndex int) {
 		finishErr := model.FinishAsyncTaskWithDB(model.DB, taskRow.Id, finalStatus, result, errorMessage)
 		if finishErr != nil {
 			logger.Warn(ctx, fmt.Sprintf("[async-task] worker=%d task_id=%s finish_failed error=%q", workerIndex, taskRow.Id, finishErr.Error()))
-			time.Sleep(asyncTaskPollInterval)
+			graceful.Sleep(asyncTaskPollInterval)
 			continue
 		}
 		if execErr != nil {
@@ -213,6 +213,9 @@ func validateToken(c *gin.Context, token model.Token) error {
 	if token.RemainRequestCount < 0 {
 		return fmt.Errorf("请求次数不能为负数")
 	}
+	if token.RPMLimit < 0 || token.TPMLimit < 0 {
+		return fmt.Errorf("RPM 与 TPM 限制不能为负数")
+	}
 	if token.Subnet != nil && *token.Subnet != "" {
 		err := network.IsValidSubnets(*token.Subnet)
 		if err != nil {
@@ -307,6 +310,8 @@ func AddToken(c *gin.Context) {
 		UnlimitedRequestCount: token.UnlimitedRequestCount,
 		Models:                token.Models,
 		Subnet:                token.Subnet,
+		RPMLimit:              token.RPMLimit,
+		TPMLimit:              token.TPMLimit,
 	}
 	err = tokensvc.Create(&cleanToken)
 	if err != nil {
@@ -422,6 +427,8 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.UnlimitedRequestCount = token.UnlimitedRequestCount
 		cleanToken.Models = token.Models
 		cleanToken.Subnet = token.Subnet
+		cleanToken.RPMLimit = token.RPMLimit
+		cleanToken.TPMLimit = token.TPMLimit
 		cleanToken.UpdatedTime = helper.GetTimestamp()
 	}
 	err = tokensvc.Update(cleanToken)
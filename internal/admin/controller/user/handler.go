@@ -2140,6 +2140,11 @@ func ManageUser(c *gin.Context) {
 		})
 		return
 	}
+	if req.Action == "enable" {
+		if err := model.ResetUserModerationViolations(user.Id); err != nil {
+			logger.SysError("failed to reset moderation violations: " + err.Error())
+		}
+	}
 	clearUser := exposedUser(&model.User{Role: user.Role, Status: user.Status, WalletAddress: user.WalletAddress})
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -11,6 +11,7 @@ type SatisfiedChannelSelectionStats struct {
 	SelectedPriority       int64
 	SelectedTierCandidates int
 	SelectionScope         string
+	SelectionStrategy      string
 }
 
 func SelectRandomSatisfiedChannel(channels []*Channel, ignoreFirstPriority bool, excludedChannelIDs map[string]struct{}) *Channel {
@@ -19,12 +20,22 @@ func SelectRandomSatisfiedChannel(channels []*Channel, ignoreFirstPriority bool,
 }
 
 func SelectRandomSatisfiedChannelWithStats(channels []*Channel, ignoreFirstPriority bool, excludedChannelIDs map[string]struct{}) (*Channel, SatisfiedChannelSelectionStats) {
+	return SelectSatisfiedChannelWithStrategy(channels, ChannelSelectionStrategyWeightedRandom, ignoreFirstPriority, excludedChannelIDs)
+}
+
+// SelectSatisfiedChannelWithStrategy picks from the first remaining priority
+// tier using the given selection strategy.
+func SelectSatisfiedChannelWithStrategy(channels []*Channel, strategy string, ignoreFirstPriority bool, excludedChannelIDs map[string]struct{}) (*Channel, SatisfiedChannelSelectionStats) {
 	stats := SatisfiedChannelSelectionStats{
-		TotalCandidates: len(channels),
+		TotalCandidates:   len(channels),
+		SelectionStrategy: strategy,
 	}
 	if len(channels) == 0 {
 		return nil, stats
 	}
+	if NormalizeChannelSelectionStrategy(strategy) == ChannelSelectionStrategyCostOptimized {
+		return selectCostOrderedSatisfiedChannel(channels, ignoreFirstPriority, excludedChannelIDs, stats)
+	}
 	targets := channels
 	if ignoreFirstPriority {
 		startIdx := nextPriorityStartIndex(channels)
@@ -62,7 +73,23 @@ func SelectRandomSatisfiedChannelWithStats(channels []*Channel, ignoreFirstPrior
 	default:
 		stats.SelectionScope = "same_priority"
 	}
-	return selectWeightedChannel(filtered[:endIdx]), stats
+	return SelectChannelInTier(strategy, filtered[:endIdx]), stats
+}
+
+// selectCostOrderedSatisfiedChannel ignores priority tiers: the candidates are
+// already ordered cheapest first, so the first one not yet tried wins.
+func selectCostOrderedSatisfiedChannel(channels []*Channel, ignoreFirst bool, excludedChannelIDs map[string]struct{}, stats SatisfiedChannelSelectionStats) (*Channel, SatisfiedChannelSelectionStats) {
+	filtered := filterExcludedChannels(channels, excludedChannelIDs)
+	stats.RemainingCandidates = len(filtered)
+	stats.SelectionScope = "cost_order"
+	channel := SelectCostOrderedChannel(filtered, ignoreFirst)
+	if channel == nil {
+		stats.SelectionScope = "candidate_exhausted"
+		return nil, stats
+	}
+	stats.SelectedPriority = channel.GetPriority()
+	stats.SelectedTierCandidates = 1
+	return channel, stats
 }
 
 func selectWeightedChannel(channels []*Channel) *Channel {
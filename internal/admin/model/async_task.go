@@ -2,6 +2,7 @@ package model
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -17,6 +18,7 @@ const (
 	AsyncTaskTypeChannelModelTest      = "channel_model_test"
 	AsyncTaskTypeChannelRefreshModels  = "channel_refresh_models"
 	AsyncTaskTypeChannelRefreshBilling = "channel_refresh_billing"
+	AsyncTaskTypeRelayBatch            = "relay_batch"
 
 	AsyncTaskStatusPending   = "pending"
 	AsyncTaskStatusRunning   = "running"
@@ -64,6 +66,8 @@ func NormalizeAsyncTaskType(value string) string {
 		return AsyncTaskTypeChannelRefreshModels
 	case AsyncTaskTypeChannelRefreshBilling:
 		return AsyncTaskTypeChannelRefreshBilling
+	case AsyncTaskTypeRelayBatch:
+		return AsyncTaskTypeRelayBatch
 	default:
 		return ""
 	}
@@ -318,16 +322,34 @@ func CreateOrReuseAsyncTaskWithDB(db *gorm.DB, task AsyncTask) (AsyncTask, bool,
 	return created, reused, nil
 }
 
+// ClaimNextPendingAsyncTaskWithDB claims the oldest pending admin task.
+// Relay batches are long running and are claimed only by the batch workers.
 func ClaimNextPendingAsyncTaskWithDB(db *gorm.DB) (*AsyncTask, error) {
+	return claimNextPendingAsyncTaskWithDB(db, func(query *gorm.DB) *gorm.DB {
+		return query.Where("type <> ?", AsyncTaskTypeRelayBatch)
+	})
+}
+
+func ClaimNextPendingAsyncTaskByTypeWithDB(db *gorm.DB, taskType string) (*AsyncTask, error) {
+	normalizedType := NormalizeAsyncTaskType(taskType)
+	if normalizedType == "" {
+		return nil, fmt.Errorf("任务类型不能为空")
+	}
+	return claimNextPendingAsyncTaskWithDB(db, func(query *gorm.DB) *gorm.DB {
+		return query.Where("type = ?", normalizedType)
+	})
+}
+
+func claimNextPendingAsyncTaskWithDB(db *gorm.DB, scope func(*gorm.DB) *gorm.DB) (*AsyncTask, error) {
 	if db == nil {
 		return nil, fmt.Errorf("database handle is nil")
 	}
 	var claimed *AsyncTask
 	err := db.Transaction(func(tx *gorm.DB) error {
 		row := AsyncTask{}
-		result := tx.
+		result := scope(tx.
 			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
-			Where("status = ?", AsyncTaskStatusPending).
+			Where("status = ?", AsyncTaskStatusPending)).
 			Order("created_at asc").
 			Limit(1).
 			Find(&row)
@@ -442,6 +464,24 @@ func RetryAsyncTaskWithDB(db *gorm.DB, taskID string) (AsyncTask, bool, error) {
 	return CreateOrReuseAsyncTaskWithDB(db, nextTask)
 }
 
+// ErrAsyncTaskInterrupted is returned by a task that stopped for a shutdown
+// and saved what it needs to resume; the worker puts it back in the queue.
+var ErrAsyncTaskInterrupted = errors.New("async task interrupted by shutdown")
+
+// RequeueAsyncTaskWithDB returns a running task to the queue to be claimed
+// again.
+func RequeueAsyncTaskWithDB(db *gorm.DB, taskID string) error {
+	if db == nil {
+		return fmt.Errorf("database handle is nil")
+	}
+	return db.Model(&AsyncTask{}).
+		Where("id = ? AND status = ?", strings.TrimSpace(taskID), AsyncTaskStatusRunning).
+		Updates(map[string]any{
+			"status":     AsyncTaskStatusPending,
+			"started_at": 0,
+		}).Error
+}
+
 func FailRunningAsyncTasksWithDB(db *gorm.DB, errorMessage string) (int64, error) {
 	if db == nil {
 		return 0, fmt.Errorf("database handle is nil")
@@ -463,6 +503,27 @@ func FailRunningAsyncTasksWithDB(db *gorm.DB, errorMessage string) (int64, error
 	return result.RowsAffected, nil
 }
 
+type AsyncTaskQueueDepth struct {
+	Type   string `json:"type"`
+	Status string `json:"status"`
+	Count  int64  `json:"count"`
+}
+
+// CountActiveAsyncTasksWithDB reports how many tasks of each type are still
+// pending or running.
+func CountActiveAsyncTasksWithDB(db *gorm.DB) ([]AsyncTaskQueueDepth, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database handle is nil")
+	}
+	rows := make([]AsyncTaskQueueDepth, 0)
+	err := db.Model(&AsyncTask{}).
+		Select("type, status, COUNT(*) AS count").
+		Where("status IN ?", []string{AsyncTaskStatusPending, AsyncTaskStatusRunning}).
+		Group("type, status").
+		Scan(&rows).Error
+	return rows, err
+}
+
 func hydrateAsyncTaskChannelNames(db *gorm.DB, rows []*AsyncTask) error {
 	if db == nil || len(rows) == 0 {
 		return nil
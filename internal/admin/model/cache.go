@@ -9,11 +9,13 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/yeying-community/router/common"
 	"github.com/yeying-community/router/common/config"
 	"github.com/yeying-community/router/common/logger"
+	"github.com/yeying-community/router/internal/relay/capability"
 )
 
 var (
@@ -237,15 +239,28 @@ func CacheGetGroupModels(ctx context.Context, group string) ([]string, error) {
 }
 
 var group2model2channels map[string]map[string][]*Channel
+var channelID2channel map[string]*Channel
 var group2model2channel2upstream map[string]map[string]map[string]string
 var channel2model2endpointEnabled map[string]map[string]map[string]bool
 var channel2model2endpointBaseURL map[string]map[string]map[string]string
 var channel2model2endpointPolicy map[string]map[string]map[string][]ChannelModelEndpointPolicy
+var channel2model2endpointCapabilities map[string]map[string]map[string]endpointCapabilities
 var channelSyncLock sync.RWMutex
 
+// channelCacheSyncedAt is the unix time of the last sync that could read the
+// channels table, for readiness to tell a stale cache.
+var channelCacheSyncedAt atomic.Int64
+
+func ChannelCacheSyncedAt() int64 {
+	return channelCacheSyncedAt.Load()
+}
+
 func InitChannelCache() {
 	var channels []*Channel
-	DB.Where("status IN ?", []int{ChannelStatusEnabled, ChannelStatusHalfOpen}).Find(&channels)
+	loadErr := DB.Where("status IN ?", []int{ChannelStatusEnabled, ChannelStatusHalfOpen}).Find(&channels).Error
+	if loadErr != nil {
+		logger.SysError("failed to load channels for cache: " + loadErr.Error())
+	}
 	if err := HydrateChannelsWithModels(DB, channels); err != nil {
 		logger.SysError("failed to hydrate channel models for cache: " + err.Error())
 	}
@@ -272,6 +287,7 @@ func InitChannelCache() {
 	newChannel2model2endpointEnabled := buildChannelModelEndpointSupportCache(endpointRows)
 	newChannel2model2endpointBaseURL := buildChannelModelEndpointBaseURLCache(endpointRows)
 	newChannel2model2endpointPolicy := buildChannelModelEndpointPolicyCache(policyRows)
+	newChannel2model2endpointCapabilities := buildChannelModelEndpointCapabilitiesCache(newChannel2model2endpointPolicy)
 	for group := range groups {
 		newGroup2model2channels[group] = make(map[string][]*Channel)
 		newGroup2model2channel2upstream[group] = make(map[string]map[string]string)
@@ -332,14 +348,37 @@ func InitChannelCache() {
 
 	channelSyncLock.Lock()
 	group2model2channels = newGroup2model2channels
+	channelID2channel = channelByID
 	group2model2channel2upstream = newGroup2model2channel2upstream
 	channel2model2endpointEnabled = newChannel2model2endpointEnabled
 	channel2model2endpointBaseURL = newChannel2model2endpointBaseURL
 	channel2model2endpointPolicy = newChannel2model2endpointPolicy
+	channel2model2endpointCapabilities = newChannel2model2endpointCapabilities
 	channelSyncLock.Unlock()
+	if loadErr == nil {
+		channelCacheSyncedAt.Store(time.Now().Unix())
+		pruneChannelSelectionStats(channelByID)
+	}
+	refreshChannelRoutingCostCache(DB, newGroup2model2channels)
 	logger.SysLog("channels synced from database")
 }
 
+// CacheGetChannelByID returns an enabled or half-open channel. Without the
+// memory cache it reads the channel from the database whatever its status.
+func CacheGetChannelByID(channelID string) (*Channel, error) {
+	normalizedChannelID := strings.TrimSpace(channelID)
+	if !config.MemoryCacheEnabled {
+		return GetChannelById(normalizedChannelID)
+	}
+	channelSyncLock.RLock()
+	channel, ok := channelID2channel[normalizedChannelID]
+	channelSyncLock.RUnlock()
+	if !ok {
+		return nil, errors.New("channel not found")
+	}
+	return channel, nil
+}
+
 func resolveRuntimeChannelPriority(channel *Channel, priority int64) int64 {
 	if channel != nil && channel.Status == ChannelStatusHalfOpen {
 		return ChannelHalfOpenPriority
@@ -363,9 +402,21 @@ func CacheListSatisfiedChannels(group string, model string) ([]*Channel, error)
 }
 
 type ChannelCandidateStats struct {
-	ListedCount           int
-	EndpointFilteredCount int
-	FilteredCandidates    []ChannelCandidateFilter
+	ListedCount              int
+	EndpointFilteredCount    int
+	CapabilityFilteredCount  int
+	MarginGuardFilteredCount int
+	// LimitFilteredCount counts the channels dropped because their limits
+	// are exhausted, CandidateCount those left after every filter.
+	LimitFilteredCount int
+	CandidateCount     int
+	FilteredCandidates []ChannelCandidateFilter
+}
+
+// AllLimitSaturated reports whether no candidate is left only because the
+// channel limits of all the remaining ones are exhausted.
+func (stats ChannelCandidateStats) AllLimitSaturated() bool {
+	return stats.LimitFilteredCount > 0 && stats.CandidateCount == 0
 }
 
 type ChannelCandidateFilter struct {
@@ -373,7 +424,21 @@ type ChannelCandidateFilter struct {
 	Reason    string
 }
 
-func CacheListSatisfiedChannelsForRequestWithStats(group string, model string, requestPath string) ([]*Channel, ChannelCandidateStats, error) {
+// CacheListSatisfiedChannelsForRequestWithStats lists the channels able to
+// serve the request right now, leaving out those whose limits are exhausted.
+func CacheListSatisfiedChannelsForRequestWithStats(group string, model string, requestPath string, features capability.Features) ([]*Channel, ChannelCandidateStats, error) {
+	channels, stats, err := cacheListEligibleChannelsForRequest(group, model, requestPath, features)
+	if err != nil {
+		return nil, stats, err
+	}
+	channels, limitFiltered := filterSaturatedChannels(channels, model)
+	stats.FilteredCandidates = append(stats.FilteredCandidates, limitFiltered...)
+	stats.LimitFilteredCount = len(limitFiltered)
+	stats.CandidateCount = len(channels)
+	return channels, stats, nil
+}
+
+func cacheListEligibleChannelsForRequest(group string, model string, requestPath string, features capability.Features) ([]*Channel, ChannelCandidateStats, error) {
 	channels, err := CacheListSatisfiedChannels(group, model)
 	if err != nil {
 		return nil, ChannelCandidateStats{}, err
@@ -404,18 +469,58 @@ func CacheListSatisfiedChannelsForRequestWithStats(group string, model string, r
 			})
 		}
 	}
+	endpointFilteredCount := len(filtered)
+	filtered, capabilityFiltered := filterChannelsByRequestCapabilities(group, filtered, model, requestPath, features)
+	filteredCandidates = append(filteredCandidates, capabilityFiltered...)
+	capabilityFilteredCount := len(filtered)
+	if ResolveGroupChannelSelectionStrategy(group) == ChannelSelectionStrategyCostOptimized {
+		var marginFiltered []ChannelCandidateFilter
+		filtered, marginFiltered = orderChannelsByRoutingCost(group, model, filtered)
+		filteredCandidates = append(filteredCandidates, marginFiltered...)
+	}
 	return filtered, ChannelCandidateStats{
-		ListedCount:           len(channels),
-		EndpointFilteredCount: len(filtered),
-		FilteredCandidates:    filteredCandidates,
+		ListedCount:              len(channels),
+		EndpointFilteredCount:    endpointFilteredCount,
+		CapabilityFilteredCount:  capabilityFilteredCount,
+		MarginGuardFilteredCount: len(filtered),
+		FilteredCandidates:       filteredCandidates,
 	}, nil
 }
 
+// CacheListSatisfiedChannelsForRequest lists the channels eligible for the
+// request regardless of their current load.
 func CacheListSatisfiedChannelsForRequest(group string, model string, requestPath string) ([]*Channel, error) {
-	channels, _, err := CacheListSatisfiedChannelsForRequestWithStats(group, model, requestPath)
+	channels, _, err := cacheListEligibleChannelsForRequest(group, model, requestPath, capability.Features{})
 	return channels, err
 }
 
+// filterChannelsByRequestCapabilities drops channels whose endpoint policies
+// declare capabilities that do not cover the detected request features.
+func filterChannelsByRequestCapabilities(group string, channels []*Channel, modelName string, requestPath string, features capability.Features) ([]*Channel, []ChannelCandidateFilter) {
+	if features == (capability.Features{}) || NormalizeRequestedChannelModelEndpoint(requestPath) == "" {
+		return channels, nil
+	}
+	result := make([]*Channel, 0, len(channels))
+	filtered := make([]ChannelCandidateFilter, 0)
+	for _, channel := range channels {
+		if channel == nil {
+			continue
+		}
+		channelID := strings.TrimSpace(channel.Id)
+		candidates := endpointLookupCandidatesForChannel(group, strings.TrimSpace(modelName), channelID, nil)
+		missing := cacheGetChannelModelEndpointCapabilities(channelID, requestPath, candidates...).unsupported(features)
+		if len(missing) > 0 {
+			filtered = append(filtered, ChannelCandidateFilter{
+				ChannelID: channelID,
+				Reason:    "capability_unsupported:" + strings.Join(missing, ","),
+			})
+			continue
+		}
+		result = append(result, channel)
+	}
+	return result, filtered
+}
+
 func CacheGetChannelModelEndpointSupport(channelID string, modelCandidates ...string) map[string]bool {
 	normalizedChannelID := strings.TrimSpace(channelID)
 	normalizedCandidates := normalizeTrimmedValuesPreserveOrder(modelCandidates)
@@ -587,6 +692,41 @@ func buildChannelModelEndpointPolicyCache(rows []ChannelModelEndpointPolicy) map
 	return result
 }
 
+// buildChannelModelEndpointCapabilitiesCache parses the capabilities of every
+// cached policy once per sync instead of once per request.
+func buildChannelModelEndpointCapabilitiesCache(policies map[string]map[string]map[string][]ChannelModelEndpointPolicy) map[string]map[string]map[string]endpointCapabilities {
+	result := make(map[string]map[string]map[string]endpointCapabilities, len(policies))
+	for channelID, modelMap := range policies {
+		result[channelID] = make(map[string]map[string]endpointCapabilities, len(modelMap))
+		for modelName, endpointMap := range modelMap {
+			result[channelID][modelName] = make(map[string]endpointCapabilities, len(endpointMap))
+			for endpoint, rows := range endpointMap {
+				result[channelID][modelName][endpoint] = mergeEndpointCapabilities(rows)
+			}
+		}
+	}
+	return result
+}
+
+// cacheGetChannelModelEndpointCapabilities looks the candidates up in the same
+// order as CacheGetChannelModelEndpointPolicies. A cached channel without
+// policies for the endpoint declares nothing.
+func cacheGetChannelModelEndpointCapabilities(channelID string, endpoint string, modelCandidates ...string) endpointCapabilities {
+	if !config.MemoryCacheEnabled {
+		return mergeEndpointCapabilities(CacheGetChannelModelEndpointPolicies(channelID, endpoint, modelCandidates...))
+	}
+	normalizedEndpoint := NormalizeRequestedChannelModelEndpoint(endpoint)
+	channelSyncLock.RLock()
+	defer channelSyncLock.RUnlock()
+	modelMap := channel2model2endpointCapabilities[strings.TrimSpace(channelID)]
+	for _, modelName := range normalizeTrimmedValuesPreserveOrder(modelCandidates) {
+		if capabilities, ok := modelMap[modelName][normalizedEndpoint]; ok {
+			return capabilities
+		}
+	}
+	return endpointCapabilities{}
+}
+
 func CacheGetGroupModelMapping(group string, modelName string, channelID string) map[string]string {
 	group = strings.TrimSpace(group)
 	modelName = strings.TrimSpace(modelName)
@@ -686,7 +826,7 @@ func CacheGetRandomSatisfiedChannelExcluding(group string, model string, ignoreF
 }
 
 func CacheGetRandomSatisfiedChannelForRequestExcluding(group string, model string, requestPath string, ignoreFirstPriority bool, excludedChannelIDs map[string]struct{}) (*Channel, error) {
-	channel, _, err := CacheSelectRandomSatisfiedChannelForRequestExcluding(group, model, requestPath, ignoreFirstPriority, excludedChannelIDs)
+	channel, _, err := CacheSelectRandomSatisfiedChannelForRequestExcluding(group, model, requestPath, capability.Features{}, ignoreFirstPriority, excludedChannelIDs)
 	return channel, err
 }
 
@@ -696,7 +836,7 @@ func CacheSelectRandomSatisfiedChannelExcluding(group string, model string, igno
 		if err != nil {
 			return nil, SatisfiedChannelSelectionStats{}, err
 		}
-		channel, stats := SelectRandomSatisfiedChannelWithStats(channels, ignoreFirstPriority, excludedChannelIDs)
+		channel, stats := SelectSatisfiedChannelWithStrategy(channels, ResolveGroupChannelSelectionStrategy(group), ignoreFirstPriority, excludedChannelIDs)
 		if channel == nil {
 			return nil, stats, errors.New("channel not found")
 		}
@@ -708,19 +848,19 @@ func CacheSelectRandomSatisfiedChannelExcluding(group string, model string, igno
 	if len(channels) == 0 {
 		return nil, SatisfiedChannelSelectionStats{}, errors.New("channel not found")
 	}
-	channel, stats := SelectRandomSatisfiedChannelWithStats(channels, ignoreFirstPriority, excludedChannelIDs)
+	channel, stats := SelectSatisfiedChannelWithStrategy(channels, ResolveGroupChannelSelectionStrategy(group), ignoreFirstPriority, excludedChannelIDs)
 	if channel == nil {
 		return nil, stats, errors.New("channel not found")
 	}
 	return channel, stats, nil
 }
 
-func CacheSelectRandomSatisfiedChannelForRequestExcluding(group string, model string, requestPath string, ignoreFirstPriority bool, excludedChannelIDs map[string]struct{}) (*Channel, SatisfiedChannelSelectionStats, error) {
-	channels, err := CacheListSatisfiedChannelsForRequest(group, model, requestPath)
+func CacheSelectRandomSatisfiedChannelForRequestExcluding(group string, model string, requestPath string, features capability.Features, ignoreFirstPriority bool, excludedChannelIDs map[string]struct{}) (*Channel, SatisfiedChannelSelectionStats, error) {
+	channels, _, err := CacheListSatisfiedChannelsForRequestWithStats(group, model, requestPath, features)
 	if err != nil {
 		return nil, SatisfiedChannelSelectionStats{}, err
 	}
-	channel, stats := SelectRandomSatisfiedChannelWithStats(channels, ignoreFirstPriority, excludedChannelIDs)
+	channel, stats := SelectSatisfiedChannelWithStrategy(channels, ResolveGroupChannelSelectionStrategy(group), ignoreFirstPriority, excludedChannelIDs)
 	if channel == nil {
 		return nil, stats, errors.New("channel not found")
 	}
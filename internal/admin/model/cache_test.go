@@ -0,0 +1,124 @@
+package model
+
+import (
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/yeying-community/router/common/config"
+	"github.com/yeying-community/router/internal/relay/capability"
+)
+
+func useChannelCacheTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=private"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(
+		&Channel{},
+		&ChannelModel{},
+		&ChannelModelEndpoint{},
+		&ChannelModelEndpointTestResult{},
+		&ChannelModelEndpointPolicy{},
+		&GroupModelChannel{},
+		&ChannelProcurementBatch{},
+	); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	previousDB, previousMemoryCache := DB, config.MemoryCacheEnabled
+	DB, config.MemoryCacheEnabled = db, true
+	t.Cleanup(func() {
+		DB, config.MemoryCacheEnabled = previousDB, previousMemoryCache
+		channelSyncLock.Lock()
+		group2model2channels, channelID2channel, channel2model2endpointCapabilities = nil, nil, nil
+		channelSyncLock.Unlock()
+		channelRoutingCostCacheLock.Lock()
+		channelRoutingCostCache = map[string]ChannelRoutingCost{}
+		channelRoutingCostCacheLock.Unlock()
+	})
+	return db
+}
+
+func TestCacheGetChannelByIDServesEnabledChannelsFromMemory(t *testing.T) {
+	db := useChannelCacheTestDB(t)
+	for _, channel := range []Channel{
+		{Id: "channel-on", Name: "on", Protocol: "openai", Key: "sk-on", Status: ChannelStatusEnabled},
+		{Id: "channel-off", Name: "off", Protocol: "openai", Key: "sk-off", Status: ChannelStatusManuallyDisabled},
+	} {
+		if err := db.Create(&channel).Error; err != nil {
+			t.Fatalf("create channel: %v", err)
+		}
+	}
+	InitChannelCache()
+	// reads after the sync must not reach the database
+	if err := db.Migrator().DropTable(&Channel{}); err != nil {
+		t.Fatalf("drop channels: %v", err)
+	}
+
+	channel, err := CacheGetChannelByID(" channel-on ")
+	if err != nil || channel.Key != "sk-on" {
+		t.Fatalf("CacheGetChannelByID(channel-on) = %+v, %v; want the cached channel", channel, err)
+	}
+	if _, err := CacheGetChannelByID("channel-off"); err == nil {
+		t.Fatal("CacheGetChannelByID(channel-off) returned a disabled channel")
+	}
+}
+
+func TestInitChannelCacheParsesEndpointCapabilitiesOnce(t *testing.T) {
+	db := useChannelCacheTestDB(t)
+	if err := db.Create(&ChannelModelEndpointPolicy{
+		ID:           "policy-1",
+		ChannelId:    "channel-1",
+		Model:        "gpt-4o",
+		Endpoint:     ChannelModelEndpointChat,
+		Enabled:      true,
+		Capabilities: `{"stream":true}`,
+	}).Error; err != nil {
+		t.Fatalf("create policy: %v", err)
+	}
+	InitChannelCache()
+	// filtering after the sync must use the parsed form, not the stored rows
+	if err := db.Migrator().DropTable(&ChannelModelEndpointPolicy{}); err != nil {
+		t.Fatalf("drop policies: %v", err)
+	}
+
+	got := cacheGetChannelModelEndpointCapabilities("channel-1", "/v1/chat/completions", "gpt-4o")
+	if !got.declared || !got.merged.Stream || got.merged.Tools {
+		t.Fatalf("cached capabilities = %+v, want stream only", got)
+	}
+	if missing := got.unsupported(capability.Features{Tools: true}); len(missing) != 1 || missing[0] != "tools" {
+		t.Fatalf("unsupported = %v, want [tools]", missing)
+	}
+	if got := cacheGetChannelModelEndpointCapabilities("channel-2", "/v1/chat/completions", "gpt-4o"); got.declared {
+		t.Fatalf("uncached channel declared capabilities: %+v", got)
+	}
+}
+
+func TestInitChannelCachePrunesSelectionStatsOfUnroutableChannels(t *testing.T) {
+	db := useChannelCacheTestDB(t)
+	resetChannelSelectionStatsForTest(t)
+	for _, channel := range []Channel{
+		{Id: "channel-on", Name: "on", Protocol: "openai", Key: "sk-on", Status: ChannelStatusEnabled},
+		{Id: "channel-off", Name: "off", Protocol: "openai", Key: "sk-off", Status: ChannelStatusManuallyDisabled},
+	} {
+		if err := db.Create(&channel).Error; err != nil {
+			t.Fatalf("create channel: %v", err)
+		}
+	}
+	for _, channelID := range []string{"channel-on", "channel-off", "channel-deleted"} {
+		RecordChannelOutcome(channelID, true)
+	}
+
+	InitChannelCache()
+
+	if got := GetChannelSelectionStat("channel-on"); got.Samples != 1 {
+		t.Fatalf("enabled channel stat = %+v, want it kept", got)
+	}
+	channelSelectionStatsLock.Lock()
+	defer channelSelectionStatsLock.Unlock()
+	if len(channelSelectionStats) != 1 {
+		t.Fatalf("selection stats after sync = %v, want only channel-on", channelSelectionStats)
+	}
+}
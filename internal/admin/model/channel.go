@@ -68,6 +68,9 @@ type ChannelConfig struct {
 	APIBaseURL        string `json:"api_base_url,omitempty"`
 	VertexAIProjectID string `json:"vertex_ai_project_id,omitempty"`
 	VertexAIADC       string `json:"vertex_ai_adc,omitempty"`
+	// KeyStrategy picks among the channel's pool keys: round_robin (default),
+	// random or least_used
+	KeyStrategy string `json:"key_strategy,omitempty"`
 }
 
 func normalizeConfiguredBaseURL(raw string) string {
@@ -0,0 +1,424 @@
+package model
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yeying-community/router/common/config"
+	"github.com/yeying-community/router/common/helper"
+	"github.com/yeying-community/router/common/logger"
+	"github.com/yeying-community/router/common/random"
+	"gorm.io/gorm"
+)
+
+const (
+	ChannelKeyStatusEnabled          = 1
+	ChannelKeyStatusManuallyDisabled = 2
+	ChannelKeyStatusAutoDisabled     = 3
+	ChannelKeyStatusRetired          = 4
+
+	ChannelKeyStrategyRoundRobin = "round_robin"
+	ChannelKeyStrategyRandom     = "random"
+	ChannelKeyStrategyLeastUsed  = "least_used"
+
+	// channelKeyBreakerThreshold consecutive failures open a key's breaker for
+	// channelKeyBreakerCooldown; the next attempt after that probes the key.
+	channelKeyBreakerThreshold = 3
+	channelKeyBreakerCooldown  = time.Minute
+)
+
+// ChannelKey is one upstream secret in a channel's key pool. When a channel
+// has enabled keys, relays rotate over them and Channel.Key is only used
+// when every pool key is unavailable. Retired keys stay for log attribution.
+type ChannelKey struct {
+	Id             string `json:"id" gorm:"type:char(36);primaryKey"`
+	ChannelID      string `json:"channel_id" gorm:"column:channel_id;type:char(36);not null;index"`
+	Name           string `json:"name" gorm:"type:varchar(64);not null;default:''"`
+	Key            string `json:"key,omitempty" gorm:"type:text"`
+	KeyPreview     string `json:"key_preview,omitempty" gorm:"-"`
+	Status         int    `json:"status" gorm:"not null;default:1;index"`
+	DisabledReason string `json:"disabled_reason" gorm:"type:text"`
+	RequestCount   int64  `json:"request_count" gorm:"bigint;not null;default:0"`
+	UsedQuota      int64  `json:"used_quota" gorm:"bigint;not null;default:0"`
+	LastUsedAt     int64  `json:"last_used_at" gorm:"bigint;not null;default:0"`
+	CreatedAt      int64  `json:"created_at" gorm:"bigint"`
+	UpdatedAt      int64  `json:"updated_at" gorm:"bigint"`
+}
+
+func (ChannelKey) TableName() string {
+	return "channel_keys"
+}
+
+func NormalizeChannelKeyStrategy(raw string) string {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case ChannelKeyStrategyRandom:
+		return ChannelKeyStrategyRandom
+	case ChannelKeyStrategyLeastUsed:
+		return ChannelKeyStrategyLeastUsed
+	}
+	return ChannelKeyStrategyRoundRobin
+}
+
+// channelKeyHealth is the in-process view of one key used for selection.
+type channelKeyHealth struct {
+	inflight            int64
+	requests            int64
+	consecutiveFailures int
+	openUntil           time.Time
+}
+
+func (health *channelKeyHealth) available(now time.Time) bool {
+	return health.openUntil.IsZero() || !now.Before(health.openUntil)
+}
+
+var (
+	channelKeyLock    sync.Mutex
+	channelKeyRuntime = map[string][]ChannelKey{}
+	channelKeyHealths = map[string]*channelKeyHealth{}
+	channelKeyCursors = map[string]int{}
+)
+
+func setChannelKeysRuntime(rows []ChannelKey) {
+	keys := map[string][]ChannelKey{}
+	enabled := map[string]struct{}{}
+	for _, row := range rows {
+		if row.Status != ChannelKeyStatusEnabled || strings.TrimSpace(row.Key) == "" {
+			continue
+		}
+		keys[row.ChannelID] = append(keys[row.ChannelID], row)
+		enabled[row.Id] = struct{}{}
+	}
+	channelKeyLock.Lock()
+	defer channelKeyLock.Unlock()
+	channelKeyRuntime = keys
+	for keyID, health := range channelKeyHealths {
+		if _, ok := enabled[keyID]; !ok && health.inflight == 0 {
+			delete(channelKeyHealths, keyID)
+		}
+	}
+}
+
+func SyncChannelKeysRuntimeWithDB(db *gorm.DB) error {
+	if db == nil {
+		return nil
+	}
+	rows := make([]ChannelKey, 0)
+	if err := db.Where("status = ?", ChannelKeyStatusEnabled).Order("created_at ASC, id ASC").Find(&rows).Error; err != nil {
+		return err
+	}
+	setChannelKeysRuntime(rows)
+	return nil
+}
+
+func channelKeyHealthLocked(keyID string) *channelKeyHealth {
+	health, ok := channelKeyHealths[keyID]
+	if !ok {
+		health = &channelKeyHealth{}
+		channelKeyHealths[keyID] = health
+	}
+	return health
+}
+
+// SelectChannelKey picks the key a relay attempt on the channel should use
+// and returns its id, empty for Channel.Key. Keys whose breaker is open are
+// skipped; when all are, Channel.Key is used if set, otherwise the key whose
+// breaker closes first.
+func SelectChannelKey(channel *Channel, strategy string) (string, string) {
+	if channel == nil {
+		return "", ""
+	}
+	now := time.Now()
+	channelKeyLock.Lock()
+	defer channelKeyLock.Unlock()
+	keys := channelKeyRuntime[channel.Id]
+	if len(keys) == 0 {
+		return "", channel.Key
+	}
+	available := make([]ChannelKey, 0, len(keys))
+	for _, key := range keys {
+		if channelKeyHealthLocked(key.Id).available(now) {
+			available = append(available, key)
+		}
+	}
+	if len(available) == 0 {
+		if strings.TrimSpace(channel.Key) != "" {
+			return "", channel.Key
+		}
+		soonest := keys[0]
+		for _, key := range keys[1:] {
+			if channelKeyHealthLocked(key.Id).openUntil.Before(channelKeyHealthLocked(soonest.Id).openUntil) {
+				soonest = key
+			}
+		}
+		return soonest.Id, soonest.Key
+	}
+	var selected ChannelKey
+	switch NormalizeChannelKeyStrategy(strategy) {
+	case ChannelKeyStrategyRandom:
+		selected = available[rand.Intn(len(available))]
+	case ChannelKeyStrategyLeastUsed:
+		selected = available[0]
+		for _, key := range available[1:] {
+			health, best := channelKeyHealthLocked(key.Id), channelKeyHealthLocked(selected.Id)
+			if health.inflight < best.inflight || (health.inflight == best.inflight && health.requests < best.requests) {
+				selected = key
+			}
+		}
+	default:
+		cursor := channelKeyCursors[channel.Id]
+		selected = available[cursor%len(available)]
+		channelKeyCursors[channel.Id] = cursor + 1
+	}
+	return selected.Id, selected.Key
+}
+
+// BeginChannelKeyRequest counts an attempt as in flight on the pool key. The
+// returned function must be called once the attempt finishes.
+func BeginChannelKeyRequest(keyID string) func() {
+	normalizedKeyID := strings.TrimSpace(keyID)
+	if normalizedKeyID == "" {
+		return func() {}
+	}
+	channelKeyLock.Lock()
+	health := channelKeyHealthLocked(normalizedKeyID)
+	health.inflight++
+	health.requests++
+	channelKeyLock.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			channelKeyLock.Lock()
+			if health.inflight > 0 {
+				health.inflight--
+			}
+			channelKeyLock.Unlock()
+		})
+	}
+}
+
+// RecordChannelKeyOutcome feeds the key's breaker with an upstream outcome:
+// consecutive failures open it, a success closes it.
+func RecordChannelKeyOutcome(keyID string, success bool) {
+	normalizedKeyID := strings.TrimSpace(keyID)
+	if normalizedKeyID == "" {
+		return
+	}
+	channelKeyLock.Lock()
+	defer channelKeyLock.Unlock()
+	health := channelKeyHealthLocked(normalizedKeyID)
+	if success {
+		health.consecutiveFailures = 0
+		health.openUntil = time.Time{}
+		return
+	}
+	health.consecutiveFailures++
+	if health.consecutiveFailures >= channelKeyBreakerThreshold {
+		health.openUntil = time.Now().Add(channelKeyBreakerCooldown)
+	}
+}
+
+// ChannelKeyPoolAvailable reports whether the channel still has a pool key
+// other than exceptKeyID to relay with.
+func ChannelKeyPoolAvailable(channelID string, exceptKeyID string) bool {
+	now := time.Now()
+	channelKeyLock.Lock()
+	defer channelKeyLock.Unlock()
+	for _, key := range channelKeyRuntime[strings.TrimSpace(channelID)] {
+		if key.Id != exceptKeyID && channelKeyHealthLocked(key.Id).available(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenChannelKeyBreakers counts, per channel, the pool keys whose breaker is
+// currently open.
+func OpenChannelKeyBreakers() map[string]int {
+	now := time.Now()
+	result := map[string]int{}
+	channelKeyLock.Lock()
+	defer channelKeyLock.Unlock()
+	for channelID, keys := range channelKeyRuntime {
+		for _, key := range keys {
+			if health, ok := channelKeyHealths[key.Id]; ok && !health.available(now) {
+				result[channelID]++
+			}
+		}
+	}
+	return result
+}
+
+// DisableChannelKey takes a key out of its pool, e.g. after the upstream
+// reported the account behind it out of balance.
+func DisableChannelKey(keyID string, reason string) error {
+	normalizedKeyID := strings.TrimSpace(keyID)
+	if normalizedKeyID == "" || DB == nil {
+		return nil
+	}
+	err := DB.Model(&ChannelKey{}).
+		Where("id = ? AND status = ?", normalizedKeyID, ChannelKeyStatusEnabled).
+		Updates(map[string]any{
+			"status":          ChannelKeyStatusAutoDisabled,
+			"disabled_reason": strings.TrimSpace(reason),
+			"updated_at":      helper.GetTimestamp(),
+		}).Error
+	if err != nil {
+		return err
+	}
+	return SyncChannelKeysRuntimeWithDB(DB)
+}
+
+// UpdateChannelKeyUsage adds a settled request and its quota to the key's
+// usage counters.
+func UpdateChannelKeyUsage(keyID string, quota int64) {
+	normalizedKeyID := strings.TrimSpace(keyID)
+	if normalizedKeyID == "" {
+		return
+	}
+	if config.BatchUpdateEnabled {
+		AddBatchUpdateRecord(BatchUpdateTypeChannelKeyUsedQuota, normalizedKeyID, quota)
+		AddBatchUpdateRecord(BatchUpdateTypeChannelKeyRequestCount, normalizedKeyID, 1)
+		return
+	}
+	updateChannelKeyUsage(normalizedKeyID, quota, 1)
+}
+
+func updateChannelKeyUsage(keyID string, quota int64, requests int64) {
+	if DB == nil {
+		return
+	}
+	err := DB.Model(&ChannelKey{}).Where("id = ?", keyID).Updates(map[string]any{
+		"used_quota":    gorm.Expr("used_quota + ?", quota),
+		"request_count": gorm.Expr("request_count + ?", requests),
+		"last_used_at":  helper.GetTimestamp(),
+	}).Error
+	if err != nil {
+		logger.SysError("failed to update channel key usage: " + err.Error())
+	}
+}
+
+func ListChannelKeysWithDB(db *gorm.DB, channelID string) ([]ChannelKey, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database handle is nil")
+	}
+	rows := make([]ChannelKey, 0)
+	if err := db.Where("channel_id = ?", strings.TrimSpace(channelID)).Order("created_at ASC, id ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// AddChannelKeysWithDB adds keys to the channel's pool, skipping blank lines
+// and keys the pool already has.
+func AddChannelKeysWithDB(db *gorm.DB, channelID string, name string, keys []string) ([]ChannelKey, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database handle is nil")
+	}
+	normalizedChannelID := strings.TrimSpace(channelID)
+	channelCount := int64(0)
+	if err := db.Model(&Channel{}).Where("id = ?", normalizedChannelID).Count(&channelCount).Error; err != nil {
+		return nil, err
+	}
+	if normalizedChannelID == "" || channelCount == 0 {
+		return nil, fmt.Errorf("渠道不存在")
+	}
+	existing := make([]string, 0)
+	if err := db.Model(&ChannelKey{}).Where("channel_id = ? AND status <> ?", normalizedChannelID, ChannelKeyStatusRetired).Pluck("key", &existing).Error; err != nil {
+		return nil, err
+	}
+	seen := make(map[string]struct{}, len(existing)+len(keys))
+	for _, key := range existing {
+		seen[key] = struct{}{}
+	}
+	now := helper.GetTimestamp()
+	rows := make([]ChannelKey, 0, len(keys))
+	for _, key := range keys {
+		normalizedKey := strings.TrimSpace(key)
+		if normalizedKey == "" {
+			continue
+		}
+		if _, ok := seen[normalizedKey]; ok {
+			continue
+		}
+		seen[normalizedKey] = struct{}{}
+		rows = append(rows, ChannelKey{
+			Id:        random.GetUUID(),
+			ChannelID: normalizedChannelID,
+			Name:      strings.TrimSpace(name),
+			Key:       normalizedKey,
+			Status:    ChannelKeyStatusEnabled,
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("没有可添加的新密钥")
+	}
+	if err := db.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// UpdateChannelKeyStatusWithDB enables, disables or retires a key. Retired
+// keys cannot be enabled again.
+func UpdateChannelKeyStatusWithDB(db *gorm.DB, channelID string, keyID string, status int) error {
+	if db == nil {
+		return fmt.Errorf("database handle is nil")
+	}
+	switch status {
+	case ChannelKeyStatusEnabled, ChannelKeyStatusManuallyDisabled, ChannelKeyStatusRetired:
+	default:
+		return fmt.Errorf("无效的密钥状态")
+	}
+	row := ChannelKey{}
+	if err := db.Where("id = ? AND channel_id = ?", strings.TrimSpace(keyID), strings.TrimSpace(channelID)).First(&row).Error; err != nil {
+		return err
+	}
+	if row.Status == ChannelKeyStatusRetired && status != ChannelKeyStatusRetired {
+		return fmt.Errorf("密钥已退役，不能重新启用")
+	}
+	updates := map[string]any{
+		"status":     status,
+		"updated_at": helper.GetTimestamp(),
+	}
+	if status == ChannelKeyStatusEnabled {
+		updates["disabled_reason"] = ""
+	}
+	return db.Model(&ChannelKey{}).Where("id = ?", row.Id).Updates(updates).Error
+}
+
+func DeleteChannelKeysByChannelIDsWithDB(db *gorm.DB, channelIDs []string) error {
+	if db == nil {
+		return fmt.Errorf("database handle is nil")
+	}
+	if len(channelIDs) == 0 {
+		return nil
+	}
+	return db.Where("channel_id IN ?", channelIDs).Delete(&ChannelKey{}).Error
+}
+
+func ListChannelKeys(channelID string) ([]ChannelKey, error) {
+	return ListChannelKeysWithDB(DB, channelID)
+}
+
+func AddChannelKeys(channelID string, name string, keys []string) ([]ChannelKey, error) {
+	rows, err := AddChannelKeysWithDB(DB, channelID, name, keys)
+	if err != nil {
+		return nil, err
+	}
+	if err := SyncChannelKeysRuntimeWithDB(DB); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func UpdateChannelKeyStatus(channelID string, keyID string, status int) error {
+	if err := UpdateChannelKeyStatusWithDB(DB, channelID, keyID, status); err != nil {
+		return err
+	}
+	return SyncChannelKeysRuntimeWithDB(DB)
+}
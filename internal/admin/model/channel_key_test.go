@@ -0,0 +1,123 @@
+package model
+
+import (
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func useChannelKeysForTest(t *testing.T, rows []ChannelKey) {
+	t.Helper()
+	setChannelKeysRuntime(rows)
+	t.Cleanup(func() {
+		channelKeyLock.Lock()
+		channelKeyHealths = map[string]*channelKeyHealth{}
+		channelKeyCursors = map[string]int{}
+		channelKeyLock.Unlock()
+		setChannelKeysRuntime(nil)
+	})
+}
+
+func TestSelectChannelKeyRotatesAndSkipsOpenBreakers(t *testing.T) {
+	useChannelKeysForTest(t, []ChannelKey{
+		{Id: "key-a", ChannelID: "channel-1", Key: "sk-a", Status: ChannelKeyStatusEnabled},
+		{Id: "key-b", ChannelID: "channel-1", Key: "sk-b", Status: ChannelKeyStatusEnabled},
+		{Id: "key-c", ChannelID: "channel-1", Key: "sk-c", Status: ChannelKeyStatusManuallyDisabled},
+	})
+	channel := &Channel{Id: "channel-1", Key: "sk-primary"}
+
+	picked := []string{}
+	for i := 0; i < 4; i++ {
+		_, key := SelectChannelKey(channel, "")
+		picked = append(picked, key)
+	}
+	if picked[0] != "sk-a" || picked[1] != "sk-b" || picked[2] != "sk-a" || picked[3] != "sk-b" {
+		t.Fatalf("round robin picked %v", picked)
+	}
+
+	for i := 0; i < channelKeyBreakerThreshold; i++ {
+		RecordChannelKeyOutcome("key-a", false)
+	}
+	for i := 0; i < 3; i++ {
+		if keyID, _ := SelectChannelKey(channel, ChannelKeyStrategyRandom); keyID != "key-b" {
+			t.Fatalf("open breaker should skip key-a, got %s", keyID)
+		}
+	}
+	if !ChannelKeyPoolAvailable("channel-1", "key-a") || ChannelKeyPoolAvailable("channel-1", "key-b") {
+		t.Fatalf("only key-b should remain available")
+	}
+	for i := 0; i < channelKeyBreakerThreshold; i++ {
+		RecordChannelKeyOutcome("key-b", false)
+	}
+	if keyID, key := SelectChannelKey(channel, ""); keyID != "" || key != "sk-primary" {
+		t.Fatalf("all breakers open should fall back to the channel key, got %s/%s", keyID, key)
+	}
+	RecordChannelKeyOutcome("key-a", true)
+	if keyID, _ := SelectChannelKey(channel, ""); keyID != "key-a" {
+		t.Fatalf("a success should close key-a's breaker, got %s", keyID)
+	}
+
+	if keyID, key := SelectChannelKey(&Channel{Id: "channel-2", Key: "sk-single"}, ""); keyID != "" || key != "sk-single" {
+		t.Fatalf("channel without a pool = %s/%s", keyID, key)
+	}
+}
+
+func TestSelectChannelKeyLeastUsedPrefersIdleKey(t *testing.T) {
+	useChannelKeysForTest(t, []ChannelKey{
+		{Id: "key-a", ChannelID: "channel-1", Key: "sk-a", Status: ChannelKeyStatusEnabled},
+		{Id: "key-b", ChannelID: "channel-1", Key: "sk-b", Status: ChannelKeyStatusEnabled},
+	})
+	channel := &Channel{Id: "channel-1"}
+	release := BeginChannelKeyRequest("key-a")
+	if keyID, _ := SelectChannelKey(channel, ChannelKeyStrategyLeastUsed); keyID != "key-b" {
+		t.Fatalf("least used picked %s, want the idle key", keyID)
+	}
+	release()
+	release()
+	finish := BeginChannelKeyRequest("key-b")
+	finish()
+	if keyID, _ := SelectChannelKey(channel, ChannelKeyStrategyLeastUsed); keyID != "key-a" {
+		t.Fatalf("least used picked %s, want the key with fewer requests", keyID)
+	}
+}
+
+func TestAddChannelKeysDeduplicatesAndRetiredKeysStayRetired(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=private"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&ChannelKey{}, &Channel{}); err != nil {
+		t.Fatalf("migrate channel keys: %v", err)
+	}
+	if err := db.Create(&Channel{Id: "channel-1", Name: "primary"}).Error; err != nil {
+		t.Fatalf("create channel: %v", err)
+	}
+	if _, err := AddChannelKeysWithDB(db, "missing", "", []string{"sk-a"}); err == nil {
+		t.Fatalf("keys for a missing channel should be rejected")
+	}
+	rows, err := AddChannelKeysWithDB(db, "channel-1", "batch", []string{" sk-a ", "", "sk-b", "sk-a"})
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("rows = %#v, err = %v", rows, err)
+	}
+	if _, err := AddChannelKeysWithDB(db, "channel-1", "", []string{"sk-b"}); err == nil {
+		t.Fatalf("a key already in the pool should not be added again")
+	}
+
+	if err := UpdateChannelKeyStatusWithDB(db, "channel-1", rows[0].Id, ChannelKeyStatusRetired); err != nil {
+		t.Fatalf("retire: %v", err)
+	}
+	if err := UpdateChannelKeyStatusWithDB(db, "channel-1", rows[0].Id, ChannelKeyStatusEnabled); err == nil {
+		t.Fatalf("a retired key should not be enabled again")
+	}
+	if err := UpdateChannelKeyStatusWithDB(db, "channel-2", rows[1].Id, ChannelKeyStatusManuallyDisabled); err == nil {
+		t.Fatalf("a key should only be updated through its own channel")
+	}
+	if err := SyncChannelKeysRuntimeWithDB(db); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	t.Cleanup(func() { setChannelKeysRuntime(nil) })
+	if keyID, _ := SelectChannelKey(&Channel{Id: "channel-1"}, ""); keyID != rows[1].Id {
+		t.Fatalf("runtime pool picked %s, want the remaining enabled key", keyID)
+	}
+}
@@ -0,0 +1,262 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/yeying-community/router/common/helper"
+	"github.com/yeying-community/router/common/random"
+	"gorm.io/gorm"
+)
+
+// ChannelLimit caps the load the router sends to a channel, either for one
+// model or, with an empty Model, for the whole channel. Both apply when a
+// model has its own limit. Zero limits are not enforced.
+type ChannelLimit struct {
+	Id             string `json:"id" gorm:"type:char(36);primaryKey"`
+	ChannelID      string `json:"channel_id" gorm:"column:channel_id;type:char(36);not null;uniqueIndex:idx_channel_limit_channel_model,priority:1"`
+	Model          string `json:"model" gorm:"type:varchar(191);not null;default:'';uniqueIndex:idx_channel_limit_channel_model,priority:2"`
+	MaxConcurrency int    `json:"max_concurrency" gorm:"not null;default:0"`
+	RPM            int    `json:"rpm" gorm:"column:rpm;not null;default:0"`
+	TPM            int    `json:"tpm" gorm:"column:tpm;not null;default:0"`
+	Enabled        bool   `json:"enabled" gorm:"default:false"`
+	CreatedAt      int64  `json:"created_at" gorm:"bigint"`
+	UpdatedAt      int64  `json:"updated_at" gorm:"bigint"`
+}
+
+func (ChannelLimit) TableName() string {
+	return "channel_limits"
+}
+
+func normalizeChannelLimit(limit *ChannelLimit) error {
+	limit.ChannelID = strings.TrimSpace(limit.ChannelID)
+	limit.Model = strings.TrimSpace(limit.Model)
+	if limit.ChannelID == "" {
+		return fmt.Errorf("渠道限额的渠道不能为空")
+	}
+	if limit.MaxConcurrency < 0 || limit.RPM < 0 || limit.TPM < 0 {
+		return fmt.Errorf("并发、RPM 与 TPM 限额不能为负数")
+	}
+	if limit.MaxConcurrency == 0 && limit.RPM == 0 && limit.TPM == 0 {
+		return fmt.Errorf("并发、RPM 与 TPM 限额至少需要设置一项")
+	}
+	return nil
+}
+
+var (
+	channelLimitLock    sync.RWMutex
+	channelLimitRuntime = map[string]map[string]ChannelLimit{}
+)
+
+func setChannelLimitsRuntime(rows []ChannelLimit) {
+	limits := map[string]map[string]ChannelLimit{}
+	for _, row := range rows {
+		if !row.Enabled {
+			continue
+		}
+		if limits[row.ChannelID] == nil {
+			limits[row.ChannelID] = map[string]ChannelLimit{}
+		}
+		limits[row.ChannelID][row.Model] = row
+	}
+	channelLimitLock.Lock()
+	channelLimitRuntime = limits
+	channelLimitLock.Unlock()
+}
+
+func SyncChannelLimitsRuntimeWithDB(db *gorm.DB) error {
+	if db == nil {
+		return nil
+	}
+	rows := make([]ChannelLimit, 0)
+	if err := db.Find(&rows).Error; err != nil {
+		return err
+	}
+	setChannelLimitsRuntime(rows)
+	return nil
+}
+
+// channelLimitScopes returns the channel-wide and model limits that apply to
+// a request for modelName on the channel.
+func channelLimitScopes(channelID string, modelName string) []usageLimitScope {
+	normalizedChannelID := strings.TrimSpace(channelID)
+	normalizedModel := strings.TrimSpace(modelName)
+	channelLimitLock.RLock()
+	limits := channelLimitRuntime[normalizedChannelID]
+	channelLimitLock.RUnlock()
+	if len(limits) == 0 {
+		return nil
+	}
+	scopes := make([]usageLimitScope, 0, 2)
+	keys := []string{""}
+	if normalizedModel != "" {
+		keys = append(keys, normalizedModel)
+	}
+	for _, key := range keys {
+		limit, ok := limits[key]
+		if !ok {
+			continue
+		}
+		scope := usageLimitScope{
+			Key:            "channel:" + normalizedChannelID + ":" + key,
+			MaxConcurrency: limit.MaxConcurrency,
+			RPM:            limit.RPM,
+			TPM:            limit.TPM,
+		}
+		if scope.enforced() {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// ChannelLimitSaturated reports whether the channel has no room left for
+// another request for modelName, and which limit is exhausted.
+func ChannelLimitSaturated(channelID string, modelName string) (string, bool) {
+	scopes := channelLimitScopes(channelID, modelName)
+	if len(scopes) == 0 {
+		return "", false
+	}
+	return currentUsageLimitCounter().saturated(scopes)
+}
+
+// AcquireChannelLimit takes a concurrency slot and counts the request in the
+// RPM window of the channel. The returned function frees the slot and lets a
+// queued request retry; it must be called once the attempt finishes.
+func AcquireChannelLimit(channelID string, modelName string) (func(), string, bool) {
+	release, reason, ok := acquireUsageLimits(channelLimitScopes(channelID, modelName))
+	if !ok {
+		return nil, reason, false
+	}
+	return func() {
+		release()
+		notifyChannelLimitWaiter()
+	}, "", true
+}
+
+// RecordChannelLimitTokens counts the tokens a request used on the channel
+// towards its TPM windows.
+func RecordChannelLimitTokens(channelID string, modelName string, tokens int) {
+	if tokens <= 0 {
+		return
+	}
+	scopes := channelLimitScopes(channelID, modelName)
+	if len(scopes) == 0 {
+		return
+	}
+	currentUsageLimitCounter().addTokens(scopes, int64(tokens))
+}
+
+// filterSaturatedChannels drops the channels whose limits leave no room for
+// another request for modelName.
+func filterSaturatedChannels(channels []*Channel, modelName string) ([]*Channel, []ChannelCandidateFilter) {
+	result := make([]*Channel, 0, len(channels))
+	var filtered []ChannelCandidateFilter
+	for _, channel := range channels {
+		if channel == nil {
+			continue
+		}
+		if reason, saturated := ChannelLimitSaturated(channel.Id, modelName); saturated {
+			filtered = append(filtered, ChannelCandidateFilter{
+				ChannelID: strings.TrimSpace(channel.Id),
+				Reason:    "limit_saturated:" + reason,
+			})
+			continue
+		}
+		result = append(result, channel)
+	}
+	return result, filtered
+}
+
+func ListChannelLimitsWithDB(db *gorm.DB, channelID string) ([]ChannelLimit, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database handle is nil")
+	}
+	query := db.Model(&ChannelLimit{})
+	if normalizedChannelID := strings.TrimSpace(channelID); normalizedChannelID != "" {
+		query = query.Where("channel_id = ?", normalizedChannelID)
+	}
+	rows := make([]ChannelLimit, 0)
+	if err := query.Order("channel_id ASC, model ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// SaveChannelLimitWithDB creates the limit or replaces the existing one for
+// the same channel and model.
+func SaveChannelLimitWithDB(db *gorm.DB, limit ChannelLimit) (ChannelLimit, error) {
+	if db == nil {
+		return ChannelLimit{}, fmt.Errorf("database handle is nil")
+	}
+	if err := normalizeChannelLimit(&limit); err != nil {
+		return ChannelLimit{}, err
+	}
+	channelCount := int64(0)
+	if err := db.Model(&Channel{}).Where("id = ?", limit.ChannelID).Count(&channelCount).Error; err != nil {
+		return ChannelLimit{}, err
+	}
+	if channelCount == 0 {
+		return ChannelLimit{}, fmt.Errorf("渠道不存在")
+	}
+	now := helper.GetTimestamp()
+	existing := ChannelLimit{}
+	err := db.Where("channel_id = ? AND model = ?", limit.ChannelID, limit.Model).First(&existing).Error
+	switch {
+	case err == nil:
+		limit.Id = existing.Id
+		limit.CreatedAt = existing.CreatedAt
+		limit.UpdatedAt = now
+		if err := db.Select("*").Save(&limit).Error; err != nil {
+			return ChannelLimit{}, err
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		limit.Id = random.GetUUID()
+		limit.CreatedAt = now
+		limit.UpdatedAt = now
+		if err := db.Create(&limit).Error; err != nil {
+			return ChannelLimit{}, err
+		}
+	default:
+		return ChannelLimit{}, err
+	}
+	return limit, nil
+}
+
+func DeleteChannelLimitWithDB(db *gorm.DB, id string) error {
+	if db == nil {
+		return fmt.Errorf("database handle is nil")
+	}
+	result := db.Where("id = ?", strings.TrimSpace(id)).Delete(&ChannelLimit{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func ListChannelLimits(channelID string) ([]ChannelLimit, error) {
+	return ListChannelLimitsWithDB(DB, channelID)
+}
+
+func SaveChannelLimit(limit ChannelLimit) (ChannelLimit, error) {
+	row, err := SaveChannelLimitWithDB(DB, limit)
+	if err != nil {
+		return ChannelLimit{}, err
+	}
+	if err := SyncChannelLimitsRuntimeWithDB(DB); err != nil {
+		return ChannelLimit{}, err
+	}
+	return row, nil
+}
+
+func DeleteChannelLimit(id string) error {
+	if err := DeleteChannelLimitWithDB(DB, id); err != nil {
+		return err
+	}
+	return SyncChannelLimitsRuntimeWithDB(DB)
+}
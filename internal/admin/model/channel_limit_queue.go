@@ -0,0 +1,142 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/yeying-community/router/common/config"
+)
+
+// channelLimitQueuePollInterval is how often the head of the queue checks for
+// room freed by other nodes or by a new RPM/TPM window.
+const channelLimitQueuePollInterval = 200 * time.Millisecond
+
+var (
+	ErrChannelLimitQueueFull    = errors.New("channel limit queue is full")
+	ErrChannelLimitQueueTimeout = errors.New("timed out waiting for channel capacity")
+)
+
+// ChannelLimitWaiter holds a request's place in the local queue of requests
+// waiting for a saturated channel. Lower priorities are served first, equal
+// priorities in arrival order.
+type ChannelLimitWaiter struct {
+	priority int
+	seq      uint64
+	deadline time.Time
+	ready    chan struct{}
+}
+
+type channelLimitQueue struct {
+	mu      sync.Mutex
+	seq     uint64
+	waiters []*ChannelLimitWaiter
+}
+
+var channelLimitWaiters = &channelLimitQueue{}
+
+func NewChannelLimitWaiter(priority int) *ChannelLimitWaiter {
+	channelLimitWaiters.mu.Lock()
+	channelLimitWaiters.seq++
+	seq := channelLimitWaiters.seq
+	channelLimitWaiters.mu.Unlock()
+	return &ChannelLimitWaiter{
+		priority: priority,
+		seq:      seq,
+		deadline: time.Now().Add(time.Duration(config.ChannelLimitQueueTimeoutSeconds) * time.Second),
+	}
+}
+
+// Wait blocks until a channel may have room for the request: a slot was
+// released while it was first in line, or it is first in line on the next
+// poll. The caller selects a channel again and waits once more if all are
+// still saturated; the deadline covers all waits of the request.
+func (w *ChannelLimitWaiter) Wait(ctx context.Context) error {
+	remaining := time.Until(w.deadline)
+	if remaining <= 0 {
+		return ErrChannelLimitQueueTimeout
+	}
+	if !channelLimitWaiters.push(w) {
+		return ErrChannelLimitQueueFull
+	}
+	defer channelLimitWaiters.remove(w)
+	timer := time.NewTimer(remaining)
+	defer timer.Stop()
+	ticker := time.NewTicker(channelLimitQueuePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.ready:
+			return nil
+		case <-ticker.C:
+			if channelLimitWaiters.head() == w {
+				return nil
+			}
+		case <-timer.C:
+			return ErrChannelLimitQueueTimeout
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (q *channelLimitQueue) push(w *ChannelLimitWaiter) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.waiters) >= config.ChannelLimitQueueSize {
+		return false
+	}
+	w.ready = make(chan struct{})
+	index := len(q.waiters)
+	for i, queued := range q.waiters {
+		if w.priority < queued.priority || (w.priority == queued.priority && w.seq < queued.seq) {
+			index = i
+			break
+		}
+	}
+	q.waiters = append(q.waiters, nil)
+	copy(q.waiters[index+1:], q.waiters[index:])
+	q.waiters[index] = w
+	return true
+}
+
+func (q *channelLimitQueue) remove(w *ChannelLimitWaiter) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, queued := range q.waiters {
+		if queued == w {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+func (q *channelLimitQueue) head() *ChannelLimitWaiter {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.waiters) == 0 {
+		return nil
+	}
+	return q.waiters[0]
+}
+
+func (q *channelLimitQueue) size() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.waiters)
+}
+
+// notifyChannelLimitWaiter wakes the first request in line after a slot was
+// released on this node.
+func notifyChannelLimitWaiter() {
+	q := channelLimitWaiters
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.waiters) == 0 {
+		return
+	}
+	head := q.waiters[0]
+	q.waiters = q.waiters[1:]
+	close(head.ready)
+}
@@ -0,0 +1,179 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yeying-community/router/common"
+	"github.com/yeying-community/router/common/config"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func useMemoryChannelLimitsForTest(t *testing.T, rows []ChannelLimit) {
+	t.Helper()
+	previousRedisEnabled := common.RedisEnabled
+	previousCounter := memoryUsageLimits
+	common.RedisEnabled = false
+	memoryUsageLimits = &memoryUsageLimitCounter{usage: map[string]*usageLimitState{}}
+	setChannelLimitsRuntime(rows)
+	t.Cleanup(func() {
+		common.RedisEnabled = previousRedisEnabled
+		memoryUsageLimits = previousCounter
+		setChannelLimitsRuntime(nil)
+	})
+}
+
+func TestSaveChannelLimitValidatesAndUpsertsByChannelModel(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=private"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&ChannelLimit{}, &Channel{}); err != nil {
+		t.Fatalf("migrate channel limits: %v", err)
+	}
+	if err := db.Create(&Channel{Id: "channel-1", Name: "primary"}).Error; err != nil {
+		t.Fatalf("create channel: %v", err)
+	}
+	invalid := []ChannelLimit{
+		{ChannelID: "channel-1"},
+		{ChannelID: "channel-1", RPM: -1},
+		{ChannelID: "", RPM: 60},
+		{ChannelID: "missing", RPM: 60},
+	}
+	for _, limit := range invalid {
+		if _, err := SaveChannelLimitWithDB(db, limit); err == nil {
+			t.Fatalf("limit %+v should be rejected", limit)
+		}
+	}
+
+	first, err := SaveChannelLimitWithDB(db, ChannelLimit{ChannelID: " channel-1 ", Model: " gpt-4o ", RPM: 60, Enabled: true})
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if first.ChannelID != "channel-1" || first.Model != "gpt-4o" {
+		t.Fatalf("first = %#v, want trimmed channel and model", first)
+	}
+	second, err := SaveChannelLimitWithDB(db, ChannelLimit{ChannelID: "channel-1", Model: "gpt-4o", MaxConcurrency: 4, Enabled: true})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if second.Id != first.Id {
+		t.Fatalf("update created a new row: %s != %s", second.Id, first.Id)
+	}
+	rows, err := ListChannelLimitsWithDB(db, "channel-1")
+	if err != nil || len(rows) != 1 || rows[0].MaxConcurrency != 4 || rows[0].RPM != 0 {
+		t.Fatalf("rows = %#v, err = %v", rows, err)
+	}
+}
+
+func TestAcquireChannelLimitEnforcesChannelAndModelLimits(t *testing.T) {
+	useMemoryChannelLimitsForTest(t, []ChannelLimit{
+		{ChannelID: "channel-1", MaxConcurrency: 2, Enabled: true},
+		{ChannelID: "channel-1", Model: "gpt-4o", MaxConcurrency: 1, Enabled: true},
+		{ChannelID: "channel-2", RPM: 1, Enabled: false},
+	})
+
+	release, _, ok := AcquireChannelLimit("channel-1", "gpt-4o")
+	if !ok {
+		t.Fatalf("first gpt-4o request should get a slot")
+	}
+	if reason, ok := ChannelLimitSaturated("channel-1", "gpt-4o"); !ok || reason != usageLimitReasonConcurrency {
+		t.Fatalf("gpt-4o saturated = %v (%s), want concurrency", ok, reason)
+	}
+	if _, _, ok := AcquireChannelLimit("channel-1", "gpt-4o"); ok {
+		t.Fatalf("model limit should reject a second gpt-4o request")
+	}
+	otherRelease, _, ok := AcquireChannelLimit("channel-1", "o3")
+	if !ok {
+		t.Fatalf("another model should still fit in the channel limit")
+	}
+	if _, reason, ok := AcquireChannelLimit("channel-1", "o3"); ok || reason != usageLimitReasonConcurrency {
+		t.Fatalf("channel limit should reject a third request, reason = %s", reason)
+	}
+	release()
+	release()
+	otherRelease()
+	if _, ok := ChannelLimitSaturated("channel-1", "gpt-4o"); ok {
+		t.Fatalf("released slots should free the channel")
+	}
+	for i := 0; i < 3; i++ {
+		if _, _, ok := AcquireChannelLimit("channel-2", "gpt-4o"); !ok {
+			t.Fatalf("disabled limit must not be enforced")
+		}
+	}
+}
+
+func TestChannelLimitCountsRequestsAndTokensPerMinute(t *testing.T) {
+	useMemoryChannelLimitsForTest(t, []ChannelLimit{
+		{ChannelID: "channel-1", RPM: 2, Enabled: true},
+		{ChannelID: "channel-2", TPM: 1000, Enabled: true},
+	})
+	for i := 0; i < 2; i++ {
+		release, _, ok := AcquireChannelLimit("channel-1", "gpt-4o")
+		if !ok {
+			t.Fatalf("request %d should fit in the RPM limit", i+1)
+		}
+		release()
+	}
+	if _, reason, ok := AcquireChannelLimit("channel-1", "gpt-4o"); ok || reason != usageLimitReasonRPM {
+		t.Fatalf("third request in the minute should hit the RPM limit, reason = %s", reason)
+	}
+
+	RecordChannelLimitTokens("channel-2", "gpt-4o", 1200)
+	channels, filtered := filterSaturatedChannels([]*Channel{{Id: "channel-1"}, {Id: "channel-2"}, {Id: "channel-3"}}, "gpt-4o")
+	if len(channels) != 1 || channels[0].Id != "channel-3" {
+		t.Fatalf("channels = %v, want only channel-3", channelIDsForTest(channels))
+	}
+	if len(filtered) != 2 || filtered[0].Reason != "limit_saturated:rpm" || filtered[1].Reason != "limit_saturated:tpm" {
+		t.Fatalf("filtered = %#v", filtered)
+	}
+}
+
+func TestChannelLimitWaiterServesLowerPriorityFirst(t *testing.T) {
+	previousSize, previousTimeout := config.ChannelLimitQueueSize, config.ChannelLimitQueueTimeoutSeconds
+	config.ChannelLimitQueueSize, config.ChannelLimitQueueTimeoutSeconds = 2, 5
+	t.Cleanup(func() {
+		config.ChannelLimitQueueSize, config.ChannelLimitQueueTimeoutSeconds = previousSize, previousTimeout
+	})
+
+	balance := NewChannelLimitWaiter(20)
+	pkg := NewChannelLimitWaiter(10)
+	woken := make(chan *ChannelLimitWaiter, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, waiter := range []*ChannelLimitWaiter{balance, pkg} {
+		waiter := waiter
+		go func() {
+			if err := waiter.Wait(ctx); err == nil {
+				woken <- waiter
+			}
+		}()
+	}
+	deadline := time.Now().Add(time.Second)
+	for channelLimitWaiters.size() < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if channelLimitWaiters.head() != pkg {
+		t.Fatalf("the package request should be first in line")
+	}
+	if err := NewChannelLimitWaiter(0).Wait(ctx); !errors.Is(err, ErrChannelLimitQueueFull) {
+		t.Fatalf("third waiter err = %v, want queue full", err)
+	}
+	notifyChannelLimitWaiter()
+	if got := <-woken; got != pkg {
+		t.Fatalf("released slot woke the wrong waiter")
+	}
+	if got := <-woken; got != balance {
+		t.Fatalf("remaining waiter should be served next")
+	}
+}
+
+func TestChannelLimitWaiterTimesOut(t *testing.T) {
+	waiter := &ChannelLimitWaiter{deadline: time.Now().Add(-time.Millisecond)}
+	if err := waiter.Wait(context.Background()); !errors.Is(err, ErrChannelLimitQueueTimeout) {
+		t.Fatalf("err = %v, want timeout", err)
+	}
+}
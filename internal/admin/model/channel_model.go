@@ -1754,6 +1754,8 @@ func normalizeExplicitChannelModelType(raw string) string {
 		return ProviderModelTypeVideo
 	case ProviderModelTypeEmbedding:
 		return ProviderModelTypeEmbedding
+	case ProviderModelTypeRerank:
+		return ProviderModelTypeRerank
 	default:
 		return ""
 	}
@@ -1834,6 +1836,7 @@ const (
 	ChannelModelEndpointRealtime   = "/v1/realtime"
 	ChannelModelEndpointBatches    = "/v1/batches"
 	ChannelModelEndpointEmbeddings = "/v1/embeddings"
+	ChannelModelEndpointRerank     = "/v1/rerank"
 	ChannelModelEndpointImages     = "/v1/images/generations"
 	ChannelModelEndpointImageEdit  = "/v1/images/edits"
 	ChannelModelEndpointAudio      = "/v1/audio/speech"
@@ -1858,6 +1861,8 @@ func channelModelEndpointSortRank(endpoint string) int {
 		return 60
 	case ChannelModelEndpointEmbeddings:
 		return 65
+	case ChannelModelEndpointRerank:
+		return 67
 	case ChannelModelEndpointAudio:
 		return 70
 	case ChannelModelEndpointVideos:
@@ -1877,6 +1882,8 @@ func DefaultChannelModelEndpoint(modelType string) string {
 		return ChannelModelEndpointVideos
 	case ProviderModelTypeEmbedding:
 		return ChannelModelEndpointEmbeddings
+	case ProviderModelTypeRerank:
+		return ChannelModelEndpointRerank
 	default:
 		return ChannelModelEndpointResponses
 	}
@@ -1930,6 +1937,8 @@ func NormalizeChannelModelEndpoint(modelType string, endpoint string) string {
 			return ChannelModelEndpointEmbeddings
 		}
 		return ChannelModelEndpointEmbeddings
+	case ProviderModelTypeRerank:
+		return ChannelModelEndpointRerank
 	default:
 		switch normalizedEndpoint {
 		case ChannelModelEndpointChat:
@@ -46,8 +46,14 @@ func NormalizeRequestedChannelModelEndpoint(path string) string {
 		return ChannelModelEndpointBatches
 	case strings.HasPrefix(normalizedPath, ChannelModelEndpointEmbeddings):
 		return ChannelModelEndpointEmbeddings
+	case strings.HasPrefix(normalizedPath, ChannelModelEndpointRerank):
+		return ChannelModelEndpointRerank
 	case strings.HasPrefix(normalizedPath, ChannelModelEndpointImageEdit):
 		return ChannelModelEndpointImageEdit
+	// Variations are served by channels that accept image input, either
+	// natively or through image-to-image emulation.
+	case strings.HasPrefix(normalizedPath, "/v1/images/variations"):
+		return ChannelModelEndpointImageEdit
 	case strings.HasPrefix(normalizedPath, ChannelModelEndpointImages):
 		return ChannelModelEndpointImages
 	case strings.HasPrefix(normalizedPath, "/v1/audio/"):
@@ -85,6 +91,8 @@ func IsChannelModelEndpointRouteSupported(channelProtocol int, modelType string,
 		return normalizedEndpoint == ChannelModelEndpointVideos
 	case ProviderModelTypeEmbedding:
 		return normalizedEndpoint == ChannelModelEndpointEmbeddings
+	case ProviderModelTypeRerank:
+		return normalizedEndpoint == ChannelModelEndpointRerank
 	default:
 		switch normalizedEndpoint {
 		case ChannelModelEndpointChat, ChannelModelEndpointResponses:
@@ -803,10 +811,14 @@ func IsChannelModelRequestEndpointSupportedByEndpointMap(endpointMap map[string]
 	if normalizedEndpoint == ChannelModelEndpointChat ||
 		normalizedEndpoint == ChannelModelEndpointResponses ||
 		normalizedEndpoint == ChannelModelEndpointMessages {
-		enabled, ok := endpointMap[normalizedEndpoint]
-		if ok {
+		// an endpoint switched off explicitly stays off; only missing ones are
+		// served by converting from another enabled text endpoint
+		if enabled, ok := endpointMap[normalizedEndpoint]; ok {
 			return enabled, true
 		}
+		if config.TextEndpointConversionEnabled && hasEnabledTextEndpoint(endpointMap) {
+			return true, true
+		}
 		if hasTextEndpoint {
 			return false, true
 		}
@@ -818,3 +830,11 @@ func IsChannelModelRequestEndpointSupportedByEndpointMap(endpointMap map[string]
 	}
 	return enabled, true
 }
+
+// hasEnabledTextEndpoint reports whether any text endpoint is enabled, which
+// lets the relay convert a request for one text protocol into another.
+func hasEnabledTextEndpoint(endpointMap map[string]bool) bool {
+	return endpointMap[ChannelModelEndpointChat] ||
+		endpointMap[ChannelModelEndpointResponses] ||
+		endpointMap[ChannelModelEndpointMessages]
+}
@@ -7,7 +7,9 @@ import (
 
 	"github.com/yeying-community/router/common/config"
 	"github.com/yeying-community/router/common/helper"
+	"github.com/yeying-community/router/common/logger"
 	"github.com/yeying-community/router/common/random"
+	"github.com/yeying-community/router/internal/relay/capability"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -161,6 +163,86 @@ func (row ChannelModelEndpointPolicy) ParseCapabilities() (ChannelModelEndpointC
 	return capabilities, nil
 }
 
+// UnsupportedRequestFeatures returns the capability keys of features that the
+// enabled policies of one channel endpoint do not declare. Endpoints without
+// declared capabilities accept everything; stream and non_stream only
+// restrict when at least one of them is declared.
+func UnsupportedRequestFeatures(rows []ChannelModelEndpointPolicy, features capability.Features) []string {
+	return mergeEndpointCapabilities(rows).unsupported(features)
+}
+
+// endpointCapabilities is the parsed union of the capabilities the enabled
+// policies of one channel endpoint declare.
+type endpointCapabilities struct {
+	declared         bool
+	merged           ChannelModelEndpointCapabilities
+	imageURLToBase64 bool
+}
+
+func mergeEndpointCapabilities(rows []ChannelModelEndpointPolicy) endpointCapabilities {
+	result := endpointCapabilities{}
+	for _, row := range rows {
+		if !row.Enabled {
+			continue
+		}
+		if strings.TrimSpace(row.Capabilities) != "" {
+			capabilities, err := row.ParseCapabilities()
+			if err != nil {
+				logger.SysError("parse channel model endpoint capabilities failed: " + err.Error())
+				continue
+			}
+			result.declared = true
+			result.merged.InputImageURL = result.merged.InputImageURL || capabilities.InputImageURL
+			result.merged.InputImageBase64 = result.merged.InputImageBase64 || capabilities.InputImageBase64
+			result.merged.InputPDFURL = result.merged.InputPDFURL || capabilities.InputPDFURL
+			result.merged.InputPDFFile = result.merged.InputPDFFile || capabilities.InputPDFFile
+			result.merged.Tools = result.merged.Tools || capabilities.Tools
+			result.merged.Stream = result.merged.Stream || capabilities.Stream
+			result.merged.NonStream = result.merged.NonStream || capabilities.NonStream
+		}
+		if requestPolicy, err := row.ParseRequestPolicy(); err == nil {
+			for _, action := range requestPolicy.Actions {
+				if action.Type == ChannelEndpointPolicyActionImageURLToBase64 {
+					result.imageURLToBase64 = true
+				}
+			}
+		}
+	}
+	return result
+}
+
+func (c endpointCapabilities) unsupported(features capability.Features) []string {
+	if !c.declared {
+		return nil
+	}
+	merged := c.merged
+	missing := make([]string, 0)
+	if features.ImageURL && !merged.InputImageURL && !(merged.InputImageBase64 && c.imageURLToBase64) {
+		missing = append(missing, "input_image_url")
+	}
+	if features.ImageBase64 && !merged.InputImageBase64 {
+		missing = append(missing, "input_image_base64")
+	}
+	if features.PDFURL && !merged.InputPDFURL {
+		missing = append(missing, "input_pdf_url")
+	}
+	if features.PDFFile && !merged.InputPDFFile {
+		missing = append(missing, "input_pdf_file")
+	}
+	if features.Tools && !merged.Tools {
+		missing = append(missing, "tools")
+	}
+	if merged.Stream || merged.NonStream {
+		if features.Stream && !merged.Stream {
+			missing = append(missing, "stream")
+		}
+		if features.NonStream && !merged.NonStream {
+			missing = append(missing, "non_stream")
+		}
+	}
+	return missing
+}
+
 func ParseEndpointPolicyJSON(raw string) error {
 	trimmed := strings.TrimSpace(raw)
 	if trimmed == "" {
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/yeying-community/router/common/config"
+	"github.com/yeying-community/router/internal/relay/capability"
+)
+
+func TestUnsupportedRequestFeatures(t *testing.T) {
+	rows := []ChannelModelEndpointPolicy{
+		{Enabled: true, Capabilities: `{"input_text":true,"input_image_base64":true,"stream":true}`},
+		{Enabled: true, RequestPolicy: `{"actions":[{"type":"image_url_to_base64"}]}`},
+		{Enabled: false, Capabilities: `{"tools":true,"input_pdf_file":true}`},
+	}
+	features := capability.Features{ImageURL: true, PDFFile: true, Tools: true, NonStream: true}
+	want := []string{"input_pdf_file", "tools", "non_stream"}
+	if got := UnsupportedRequestFeatures(rows, features); !reflect.DeepEqual(got, want) {
+		t.Fatalf("UnsupportedRequestFeatures() = %v, want %v", got, want)
+	}
+	if got := UnsupportedRequestFeatures(rows[1:2], features); len(got) != 0 {
+		t.Fatalf("undeclared capabilities should not restrict, got %v", got)
+	}
+	onlyInputs := []ChannelModelEndpointPolicy{{Enabled: true, Capabilities: `{"tools":true}`}}
+	if got := UnsupportedRequestFeatures(onlyInputs, capability.Features{Tools: true, Stream: true}); len(got) != 0 {
+		t.Fatalf("stream should be unrestricted without stream declarations, got %v", got)
+	}
+}
+
+func TestFilterChannelsByRequestCapabilities(t *testing.T) {
+	previousEnabled, previousPolicies, previousCapabilities := config.MemoryCacheEnabled, channel2model2endpointPolicy, channel2model2endpointCapabilities
+	t.Cleanup(func() {
+		config.MemoryCacheEnabled = previousEnabled
+		channelSyncLock.Lock()
+		channel2model2endpointPolicy = previousPolicies
+		channel2model2endpointCapabilities = previousCapabilities
+		channelSyncLock.Unlock()
+	})
+	config.MemoryCacheEnabled = true
+	channelSyncLock.Lock()
+	channel2model2endpointPolicy = buildChannelModelEndpointPolicyCache([]ChannelModelEndpointPolicy{
+		{ChannelId: "no-tools", Model: "gpt-4o", Endpoint: ChannelModelEndpointChat, Enabled: true, Capabilities: `{"stream":true,"non_stream":true}`},
+		{ChannelId: "full", Model: "gpt-4o", Endpoint: ChannelModelEndpointChat, Enabled: true, Capabilities: `{"tools":true,"stream":true}`},
+	})
+	channel2model2endpointCapabilities = buildChannelModelEndpointCapabilitiesCache(channel2model2endpointPolicy)
+	channelSyncLock.Unlock()
+
+	channels := []*Channel{{Id: "no-tools"}, {Id: "full"}, {Id: "undeclared"}}
+	kept, filtered := filterChannelsByRequestCapabilities("", channels, "gpt-4o", "/v1/chat/completions", capability.Features{Tools: true, Stream: true})
+	if len(kept) != 2 || kept[0].Id != "full" || kept[1].Id != "undeclared" {
+		t.Fatalf("kept = %v, want full and undeclared", channelIDsForTest(kept))
+	}
+	if len(filtered) != 1 || filtered[0].ChannelID != "no-tools" || filtered[0].Reason != "capability_unsupported:tools" {
+		t.Fatalf("filtered = %#v", filtered)
+	}
+	if kept, _ := filterChannelsByRequestCapabilities("", channels, "gpt-4o", "/v1/chat/completions", capability.Features{}); len(kept) != 3 {
+		t.Fatalf("uninspected requests should keep every channel, got %d", len(kept))
+	}
+}
+
+func channelIDsForTest(channels []*Channel) []string {
+	ids := make([]string, 0, len(channels))
+	for _, channel := range channels {
+		ids = append(ids, channel.Id)
+	}
+	return ids
+}
@@ -3,6 +3,7 @@ package model
 import (
 	"testing"
 
+	"github.com/yeying-community/router/common/config"
 	relaychannel "github.com/yeying-community/router/internal/relay/channel"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -453,6 +454,12 @@ func TestNormalizeRequestedChannelModelEndpointMessagesMapsToMessages(t *testing
 	}
 }
 
+func TestNormalizeRequestedChannelModelEndpointImageVariationsMapsToImageEdit(t *testing.T) {
+	if got := NormalizeRequestedChannelModelEndpoint("/v1/images/variations"); got != ChannelModelEndpointImageEdit {
+		t.Fatalf("NormalizeRequestedChannelModelEndpoint(/v1/images/variations)=%q, want %q", got, ChannelModelEndpointImageEdit)
+	}
+}
+
 func TestNormalizeRequestedChannelModelEndpointRealtimeMapsToRealtime(t *testing.T) {
 	if got := NormalizeRequestedChannelModelEndpoint("/v1/realtime"); got != ChannelModelEndpointRealtime {
 		t.Fatalf("NormalizeRequestedChannelModelEndpoint(/v1/realtime)=%q, want %q", got, ChannelModelEndpointRealtime)
@@ -472,6 +479,10 @@ func TestNormalizeRequestedChannelModelEndpointEmbeddingsMapsToEmbeddings(t *tes
 }
 
 func TestIsChannelModelRequestEndpointSupportedByEndpointMapNoBridgeCompatibility(t *testing.T) {
+	previous := config.TextEndpointConversionEnabled
+	config.TextEndpointConversionEnabled = false
+	t.Cleanup(func() { config.TextEndpointConversionEnabled = previous })
+
 	endpointMap := map[string]bool{
 		ChannelModelEndpointResponses: true,
 	}
@@ -483,6 +494,29 @@ func TestIsChannelModelRequestEndpointSupportedByEndpointMapNoBridgeCompatibilit
 	}
 }
 
+func TestIsChannelModelRequestEndpointSupportedByEndpointMapBridgesTextEndpoints(t *testing.T) {
+	previous := config.TextEndpointConversionEnabled
+	config.TextEndpointConversionEnabled = true
+	t.Cleanup(func() { config.TextEndpointConversionEnabled = previous })
+
+	endpointMap := map[string]bool{
+		ChannelModelEndpointResponses: true,
+		ChannelModelEndpointChat:      false,
+	}
+	if supported, explicit := IsChannelModelRequestEndpointSupportedByEndpointMap(endpointMap, ChannelModelEndpointChat); !explicit || supported {
+		t.Fatalf("disabled chat request support via responses endpoint = (%t, %t), want (false, true)", supported, explicit)
+	}
+	if supported, explicit := IsChannelModelRequestEndpointSupportedByEndpointMap(map[string]bool{ChannelModelEndpointResponses: true}, ChannelModelEndpointChat); !explicit || !supported {
+		t.Fatalf("chat request support via responses endpoint = (%t, %t), want (true, true)", supported, explicit)
+	}
+	if supported, explicit := IsChannelModelRequestEndpointSupportedByEndpointMap(endpointMap, ChannelModelEndpointMessages); !explicit || !supported {
+		t.Fatalf("messages request support via responses endpoint = (%t, %t), want (true, true)", supported, explicit)
+	}
+	if supported, _ := IsChannelModelRequestEndpointSupportedByEndpointMap(map[string]bool{ChannelModelEndpointChat: false}, ChannelModelEndpointResponses); supported {
+		t.Fatalf("responses request support via disabled chat endpoint = true, want false")
+	}
+}
+
 func TestReplaceChannelModelsWithDBSyncsEndpointsFromStoredRows(t *testing.T) {
 	db, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
 	if err != nil {
@@ -0,0 +1,292 @@
+package model
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/yeying-community/router/common/config"
+	"github.com/yeying-community/router/common/helper"
+	"github.com/yeying-community/router/common/logger"
+	"gorm.io/gorm"
+)
+
+const (
+	ChannelRoutingCostSourceProcurement  = "procurement"
+	ChannelRoutingCostSourceChannelPrice = "channel_price"
+)
+
+// ChannelRoutingCost estimates what one reference request on a channel costs
+// and sells for, both in the CNY base currency. The reference request is one
+// price unit of input plus one of output for token and char pricing, and one
+// unit otherwise.
+type ChannelRoutingCost struct {
+	CostBaseAmount float64
+	SellBaseAmount float64
+	Source         string
+}
+
+// Known reports whether a cost could be estimated at all.
+func (cost ChannelRoutingCost) Known() bool {
+	return cost.Source != ""
+}
+
+// ExceedsSellPrice is the margin guard: only procurement costs are trusted
+// enough to drop a channel, and free groups are never guarded.
+func (cost ChannelRoutingCost) ExceedsSellPrice() bool {
+	return cost.Source == ChannelRoutingCostSourceProcurement && cost.SellBaseAmount > 0 && cost.CostBaseAmount > cost.SellBaseAmount
+}
+
+var (
+	channelRoutingCostCacheLock sync.RWMutex
+	channelRoutingCostCache     = map[string]ChannelRoutingCost{}
+)
+
+func channelRoutingCostCacheKey(group string, modelName string, channelID string) string {
+	return strings.Join([]string{strings.TrimSpace(group), strings.TrimSpace(modelName), strings.TrimSpace(channelID)}, "|")
+}
+
+// EstimateChannelRoutingCost returns the estimate built by the last channel
+// cache sync for routing modelName of group to channel. A channel the sync has
+// not seen is priced from its channel price alone while the memory cache is
+// on, and from the database otherwise.
+func EstimateChannelRoutingCost(group string, modelName string, channel *Channel) ChannelRoutingCost {
+	if channel == nil {
+		return ChannelRoutingCost{}
+	}
+	channelRoutingCostCacheLock.RLock()
+	cost, ok := channelRoutingCostCache[channelRoutingCostCacheKey(group, modelName, channel.Id)]
+	channelRoutingCostCacheLock.RUnlock()
+	if ok {
+		return cost
+	}
+	if config.MemoryCacheEnabled {
+		return estimateChannelRoutingCost(group, modelName, channel, nil, helper.GetTimestamp())
+	}
+	return estimateChannelRoutingCostWithDB(DB, group, modelName, channel)
+}
+
+// refreshChannelRoutingCostCache rebuilds the estimates of every cached route
+// with one procurement query, replacing the entries of the previous sync.
+func refreshChannelRoutingCostCache(db *gorm.DB, group2model2channels map[string]map[string][]*Channel) {
+	now := helper.GetTimestamp()
+	batches, err := listRoutableProcurementBatchesWithDB(db, now)
+	if err != nil {
+		logger.SysError("failed to load procurement batches for routing costs: " + err.Error())
+	}
+	costs := make(map[string]ChannelRoutingCost)
+	for group, model2channels := range group2model2channels {
+		for modelName, channels := range model2channels {
+			for _, channel := range channels {
+				if channel == nil {
+					continue
+				}
+				costs[channelRoutingCostCacheKey(group, modelName, channel.Id)] = estimateChannelRoutingCost(group, modelName, channel, batches[strings.TrimSpace(channel.Id)], now)
+			}
+		}
+	}
+	channelRoutingCostCacheLock.Lock()
+	channelRoutingCostCache = costs
+	channelRoutingCostCacheLock.Unlock()
+}
+
+// listRoutableProcurementBatchesWithDB returns the active procurement batches
+// keyed by channel, in consume order. Passing channel ids narrows the query.
+func listRoutableProcurementBatchesWithDB(db *gorm.DB, now int64, channelIDs ...string) (map[string][]ChannelProcurementBatch, error) {
+	if db == nil {
+		return nil, nil
+	}
+	query := db.
+		Where("cost_status = ?", ProcurementCostStatusActive).
+		Where("cost_source IN ?", []string{ProcurementCostSourceActual, ProcurementCostSourceEstimated, ProcurementCostSourceZeroCost}).
+		Where("capacity_remaining > 0").
+		Where("(valid_from = 0 OR valid_from <= ?)", now).
+		Where("(expire_at = 0 OR expire_at > ?)", now)
+	if len(channelIDs) > 0 {
+		query = query.Where("channel_id IN ?", channelIDs)
+	}
+	rows := make([]ChannelProcurementBatch, 0)
+	if err := query.Order(procurementBatchConsumeOrderSQL()).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[string][]ChannelProcurementBatch)
+	for _, row := range rows {
+		channelID := strings.TrimSpace(row.ChannelId)
+		result[channelID] = append(result[channelID], row)
+	}
+	return result, nil
+}
+
+func estimateChannelRoutingCostWithDB(db *gorm.DB, group string, modelName string, channel *Channel) ChannelRoutingCost {
+	now := helper.GetTimestamp()
+	batches, err := listRoutableProcurementBatchesWithDB(db, now, strings.TrimSpace(channel.Id))
+	if err != nil {
+		logger.SysError("failed to load procurement batches for routing cost: " + err.Error())
+	}
+	return estimateChannelRoutingCost(group, modelName, channel, batches[strings.TrimSpace(channel.Id)], now)
+}
+
+// estimateChannelRoutingCost prices the reference request from the channel
+// price, replaced by the procurement cost when batches of the channel cover it.
+func estimateChannelRoutingCost(group string, modelName string, channel *Channel, batches []ChannelProcurementBatch, now int64) ChannelRoutingCost {
+	pricing, err := ResolveChannelModelPricing(channel.GetChannelProtocol(), channel.GetSelectedChannelModels(), modelName)
+	if err != nil {
+		return ChannelRoutingCost{}
+	}
+	anchorAmount, priceUnits := channelRoutingReferenceAmount(pricing)
+	if anchorAmount <= 0 {
+		return ChannelRoutingCost{}
+	}
+	anchorBaseAmount := convertPricingAmountToBaseAmount(anchorAmount, pricing.Currency)
+	if anchorBaseAmount <= 0 {
+		return ChannelRoutingCost{}
+	}
+	result := ChannelRoutingCost{
+		CostBaseAmount: anchorBaseAmount,
+		SellBaseAmount: anchorBaseAmount * GetRouteBillingRatio(group, modelName, channel.Id).EffectiveRatio,
+		Source:         ChannelRoutingCostSourceChannelPrice,
+	}
+	if len(batches) == 0 {
+		return result
+	}
+	inputs := []ProcurementConsumeInput{
+		{CapacityUnit: normalizePricingCapacityUnit(pricing.PriceUnit), Quantity: channelRoutingCapacityQuantity(priceUnits, pricing.PriceUnit)},
+	}
+	if currency := strings.TrimSpace(strings.ToLower(pricing.Currency)); currency != "" {
+		inputs = append(inputs, ProcurementConsumeInput{CapacityUnit: currency + "_equivalent", Quantity: anchorAmount})
+	}
+	scopeValue := strings.TrimSpace(modelName)
+	for _, input := range inputs {
+		if input.Quantity <= 0 {
+			continue
+		}
+		matched := make([]ChannelProcurementBatch, 0, len(batches))
+		for _, batch := range batches {
+			if strings.TrimSpace(batch.CapacityUnit) != input.CapacityUnit {
+				continue
+			}
+			if batch.ScopeType != "global" && !(batch.ScopeType == "model" && batch.ScopeValue == scopeValue) {
+				continue
+			}
+			matched = append(matched, batch)
+		}
+		estimate := estimateProcurementCostFromBatches(matched, input.Quantity, now)
+		if estimate.CoveredQuantity <= 0 {
+			continue
+		}
+		result.CostBaseAmount = estimate.TotalCostAmount * input.Quantity / estimate.CoveredQuantity
+		result.Source = ChannelRoutingCostSourceProcurement
+		return result
+	}
+	return result
+}
+
+// channelRoutingReferenceAmount prices the reference request in the pricing
+// currency and reports how many price units it spans.
+func channelRoutingReferenceAmount(pricing ResolvedModelPricing) (float64, float64) {
+	switch normalizePricingCapacityUnit(pricing.PriceUnit) {
+	case "token", "char":
+		return pricing.InputPrice + pricing.OutputPrice, 2
+	}
+	if pricing.InputPrice > 0 {
+		return pricing.InputPrice, 1
+	}
+	return pricing.OutputPrice, 1
+}
+
+// channelRoutingCapacityQuantity converts price units into the procurement
+// capacity unit, which counts single tokens or chars.
+func channelRoutingCapacityQuantity(priceUnits float64, priceUnit string) float64 {
+	switch strings.TrimSpace(strings.ToLower(priceUnit)) {
+	case ProviderPriceUnitPer1KTokens, ProviderPriceUnitPer1KChars:
+		return priceUnits * 1000
+	default:
+		return priceUnits
+	}
+}
+
+func convertPricingAmountToBaseAmount(amount float64, currency string) float64 {
+	if isCNY(currency) {
+		return amount
+	}
+	sourceChargeRate, err := GetBillingCurrencyChargeRate(currency)
+	if err != nil || sourceChargeRate <= 0 {
+		return 0
+	}
+	cnyChargeRate, err := GetBillingCurrencyChargeRate(BillingCurrencyCodeCNY)
+	if err != nil || cnyChargeRate <= 0 {
+		return 0
+	}
+	return amount * sourceChargeRate / cnyChargeRate
+}
+
+// orderChannelsByRoutingCost implements the cost_optimized strategy: healthy
+// channels first, then cheapest estimated cost, then the configured priority
+// order. Channels whose procurement cost exceeds the sell price are dropped.
+func orderChannelsByRoutingCost(group string, modelName string, channels []*Channel) ([]*Channel, []ChannelCandidateFilter) {
+	type rankedChannel struct {
+		channel   *Channel
+		cost      ChannelRoutingCost
+		unhealthy bool
+		index     int
+	}
+	ranked := make([]rankedChannel, 0, len(channels))
+	filtered := make([]ChannelCandidateFilter, 0)
+	for index, channel := range channels {
+		if channel == nil {
+			continue
+		}
+		cost := EstimateChannelRoutingCost(group, modelName, channel)
+		if cost.ExceedsSellPrice() {
+			filtered = append(filtered, ChannelCandidateFilter{
+				ChannelID: strings.TrimSpace(channel.Id),
+				Reason:    "margin_guard",
+			})
+			continue
+		}
+		ranked = append(ranked, rankedChannel{
+			channel:   channel,
+			cost:      cost,
+			unhealthy: GetChannelSelectionStat(channel.Id).Unhealthy(),
+			index:     index,
+		})
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		left, right := ranked[i], ranked[j]
+		if left.unhealthy != right.unhealthy {
+			return !left.unhealthy
+		}
+		leftCost, rightCost := math.Inf(1), math.Inf(1)
+		if left.cost.Known() {
+			leftCost = left.cost.CostBaseAmount
+		}
+		if right.cost.Known() {
+			rightCost = right.cost.CostBaseAmount
+		}
+		if leftCost != rightCost {
+			return leftCost < rightCost
+		}
+		return left.index < right.index
+	})
+	result := make([]*Channel, 0, len(ranked))
+	for _, item := range ranked {
+		result = append(result, item.channel)
+	}
+	return result, filtered
+}
+
+// SelectCostOrderedChannel picks the cheapest remaining channel from a list
+// already ordered for the cost_optimized strategy.
+func SelectCostOrderedChannel(channels []*Channel, ignoreFirst bool) *Channel {
+	if ignoreFirst {
+		if len(channels) < 2 {
+			return nil
+		}
+		return channels[1]
+	}
+	if len(channels) == 0 {
+		return nil
+	}
+	return channels[0]
+}
@@ -0,0 +1,166 @@
+package model
+
+import (
+	"math"
+	"testing"
+)
+
+func newRoutingCostTestChannel(id string, inputPrice float64, outputPrice float64) *Channel {
+	return &Channel{
+		Id: id,
+		ChannelModels: []ChannelModel{{
+			ChannelId:      id,
+			Model:          "gpt-5",
+			UpstreamModel:  "gpt-5",
+			Selected:       true,
+			PublishEnabled: true,
+			PublishStatus:  ChannelModelPublishStatusPublished,
+			InputPrice:     &inputPrice,
+			OutputPrice:    &outputPrice,
+			PriceUnit:      ProviderPriceUnitPer1KTokens,
+			Currency:       BillingCurrencyCodeCNY,
+		}},
+	}
+}
+
+func seedChannelRoutingCostCache(t *testing.T, group string, modelName string, costs map[string]ChannelRoutingCost) {
+	t.Helper()
+	channelRoutingCostCacheLock.Lock()
+	previous := channelRoutingCostCache
+	channelRoutingCostCache = map[string]ChannelRoutingCost{}
+	for channelID, cost := range costs {
+		channelRoutingCostCache[channelRoutingCostCacheKey(group, modelName, channelID)] = cost
+	}
+	channelRoutingCostCacheLock.Unlock()
+	t.Cleanup(func() {
+		channelRoutingCostCacheLock.Lock()
+		channelRoutingCostCache = previous
+		channelRoutingCostCacheLock.Unlock()
+	})
+}
+
+func TestEstimateChannelRoutingCostUsesProcurementBatch(t *testing.T) {
+	db := newProcurementTestDB(t)
+	if _, err := CreateChannelProcurementBatchWithDB(db, ChannelProcurementBatch{
+		ChannelId:          "channel-1",
+		ResourceType:       "quota",
+		QuotaType:          "total",
+		ScopeType:          "model",
+		ScopeValue:         "gpt-5",
+		CapacityUnit:       "token",
+		CapacityTotal:      1000000,
+		CapacityEffective:  1000000,
+		CapacityRemaining:  1000000,
+		PurchaseCostAmount: 10,
+		CostPerUnitAmount:  0.00001,
+		CostSource:         ProcurementCostSourceActual,
+		CostStatus:         ProcurementCostStatusActive,
+	}); err != nil {
+		t.Fatalf("create batch: %v", err)
+	}
+
+	cost := estimateChannelRoutingCostWithDB(db, "default", "gpt-5", newRoutingCostTestChannel("channel-1", 0.01, 0.03))
+	if cost.Source != ChannelRoutingCostSourceProcurement {
+		t.Fatalf("source = %q, want procurement", cost.Source)
+	}
+	if math.Abs(cost.CostBaseAmount-0.02) > 1e-9 {
+		t.Fatalf("cost = %v, want 0.02 for 2000 tokens", cost.CostBaseAmount)
+	}
+	if math.Abs(cost.SellBaseAmount-0.04) > 1e-9 {
+		t.Fatalf("sell = %v, want 0.04", cost.SellBaseAmount)
+	}
+
+	fallback := estimateChannelRoutingCostWithDB(db, "default", "gpt-5", newRoutingCostTestChannel("channel-2", 0.01, 0.03))
+	if fallback.Source != ChannelRoutingCostSourceChannelPrice || math.Abs(fallback.CostBaseAmount-0.04) > 1e-9 {
+		t.Fatalf("fallback = %+v, want channel price 0.04", fallback)
+	}
+}
+
+func TestRefreshChannelRoutingCostCacheRebuildsEveryRoute(t *testing.T) {
+	db := newProcurementTestDB(t)
+	if _, err := CreateChannelProcurementBatchWithDB(db, ChannelProcurementBatch{
+		ChannelId:          "channel-1",
+		ResourceType:       "quota",
+		QuotaType:          "total",
+		ScopeType:          "global",
+		CapacityUnit:       "token",
+		CapacityTotal:      1000000,
+		CapacityEffective:  1000000,
+		CapacityRemaining:  1000000,
+		PurchaseCostAmount: 10,
+		CostPerUnitAmount:  0.00001,
+		CostSource:         ProcurementCostSourceActual,
+		CostStatus:         ProcurementCostStatusActive,
+	}); err != nil {
+		t.Fatalf("create batch: %v", err)
+	}
+	seedChannelRoutingCostCache(t, "default", "gpt-5", map[string]ChannelRoutingCost{
+		"deleted": {CostBaseAmount: 0.01, Source: ChannelRoutingCostSourceChannelPrice},
+	})
+
+	refreshChannelRoutingCostCache(db, map[string]map[string][]*Channel{
+		"default": {"gpt-5": {
+			newRoutingCostTestChannel("channel-1", 0.01, 0.03),
+			newRoutingCostTestChannel("channel-2", 0.01, 0.03),
+		}},
+	})
+	// lookups after the sync must not reach the database
+	if err := db.Migrator().DropTable(&ChannelProcurementBatch{}); err != nil {
+		t.Fatalf("drop batches: %v", err)
+	}
+
+	channelRoutingCostCacheLock.RLock()
+	_, stale := channelRoutingCostCache[channelRoutingCostCacheKey("default", "gpt-5", "deleted")]
+	entries := len(channelRoutingCostCache)
+	channelRoutingCostCacheLock.RUnlock()
+	if stale || entries != 2 {
+		t.Fatalf("cache has %d entries (stale=%v), want only the two synced routes", entries, stale)
+	}
+	procured := EstimateChannelRoutingCost("default", "gpt-5", &Channel{Id: "channel-1"})
+	if procured.Source != ChannelRoutingCostSourceProcurement || math.Abs(procured.CostBaseAmount-0.02) > 1e-9 {
+		t.Fatalf("channel-1 cost = %+v, want procurement 0.02", procured)
+	}
+	priced := EstimateChannelRoutingCost("default", "gpt-5", &Channel{Id: "channel-2"})
+	if priced.Source != ChannelRoutingCostSourceChannelPrice || math.Abs(priced.CostBaseAmount-0.04) > 1e-9 {
+		t.Fatalf("channel-2 cost = %+v, want channel price 0.04", priced)
+	}
+}
+
+func TestOrderChannelsByRoutingCostPrefersCheapestHealthyChannel(t *testing.T) {
+	resetChannelSelectionStatsForTest(t)
+	seedChannelRoutingCostCache(t, "default", "gpt-5", map[string]ChannelRoutingCost{
+		"expensive": {CostBaseAmount: 0.03, SellBaseAmount: 0.04, Source: ChannelRoutingCostSourceProcurement},
+		"cheap":     {CostBaseAmount: 0.01, SellBaseAmount: 0.04, Source: ChannelRoutingCostSourceProcurement},
+		"flaky":     {CostBaseAmount: 0.005, SellBaseAmount: 0.04, Source: ChannelRoutingCostSourceProcurement},
+		"loss":      {CostBaseAmount: 0.05, SellBaseAmount: 0.04, Source: ChannelRoutingCostSourceProcurement},
+		"unpriced":  {},
+	})
+	for i := 0; i < channelUnhealthyMinSamples; i++ {
+		RecordChannelOutcome("flaky", false)
+	}
+	channels := []*Channel{{Id: "unpriced"}, {Id: "expensive"}, {Id: "loss"}, {Id: "flaky"}, {Id: "cheap"}}
+
+	ordered, filtered := orderChannelsByRoutingCost("default", "gpt-5", channels)
+
+	got := channelIDsForTest(ordered)
+	want := []string{"cheap", "expensive", "unpriced", "flaky"}
+	if len(got) != len(want) {
+		t.Fatalf("ordered = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("ordered = %v, want %v", got, want)
+		}
+	}
+	if len(filtered) != 1 || filtered[0].ChannelID != "loss" || filtered[0].Reason != "margin_guard" {
+		t.Fatalf("filtered = %+v, want loss dropped by margin guard", filtered)
+	}
+
+	selected, stats := SelectSatisfiedChannelWithStrategy(ordered, ChannelSelectionStrategyCostOptimized, false, map[string]struct{}{"cheap": {}})
+	if selected == nil || selected.Id != "expensive" {
+		t.Fatalf("retry selected = %+v, want expensive", selected)
+	}
+	if stats.SelectionScope != "cost_order" {
+		t.Fatalf("selection scope = %q, want cost_order", stats.SelectionScope)
+	}
+}
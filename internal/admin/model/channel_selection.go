@@ -0,0 +1,343 @@
+package model
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yeying-community/router/common/config"
+	"gorm.io/gorm"
+)
+
+// Channel selection strategies decide which channel of the first priority
+// tier serves a request. A group may override the configured default.
+const (
+	ChannelSelectionStrategyRandom           = "random"
+	ChannelSelectionStrategyWeightedRandom   = "weighted_random"
+	ChannelSelectionStrategyLeastOutstanding = "least_outstanding"
+	ChannelSelectionStrategyEWMA             = "ewma"
+	ChannelSelectionStrategyCostOptimized    = "cost_optimized"
+)
+
+const (
+	channelSelectionEWMADecay = 0.2
+	// A channel whose recent error rate reaches this level after enough
+	// samples is considered unhealthy by cost ordering and session affinity.
+	channelUnhealthyErrorRate  = 0.5
+	channelUnhealthyMinSamples = 5
+)
+
+var (
+	groupSelectionStrategyLock sync.RWMutex
+	groupSelectionStrategyMap  = map[string]string{}
+
+	channelSelectionStatsLock sync.Mutex
+	channelSelectionStats     = map[string]*ChannelSelectionStat{}
+)
+
+// ChannelSelectionStat is the in-memory load and health view of one channel
+// on this node, fed by relay attempts.
+type ChannelSelectionStat struct {
+	Outstanding int64   `json:"outstanding"`
+	LatencyMs   float64 `json:"latency_ms"`
+	ErrorRate   float64 `json:"error_rate"`
+	Samples     int64   `json:"samples"`
+}
+
+// Unhealthy reports whether the channel is failing often enough that sticky
+// or cost-ordered routing should stop preferring it.
+func (stat ChannelSelectionStat) Unhealthy() bool {
+	return stat.Samples >= channelUnhealthyMinSamples && stat.ErrorRate >= channelUnhealthyErrorRate
+}
+
+// NormalizeChannelSelectionStrategy returns the canonical strategy name, or an
+// empty string when value is not a known strategy.
+func NormalizeChannelSelectionStrategy(value string) string {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case ChannelSelectionStrategyRandom:
+		return ChannelSelectionStrategyRandom
+	case ChannelSelectionStrategyWeightedRandom, "weighted":
+		return ChannelSelectionStrategyWeightedRandom
+	case ChannelSelectionStrategyLeastOutstanding, "least_requests":
+		return ChannelSelectionStrategyLeastOutstanding
+	case ChannelSelectionStrategyEWMA:
+		return ChannelSelectionStrategyEWMA
+	case ChannelSelectionStrategyCostOptimized, "cheapest":
+		return ChannelSelectionStrategyCostOptimized
+	default:
+		return ""
+	}
+}
+
+// normalizeGroupSelectionStrategyInput accepts an empty value, which means the
+// group follows the configured default.
+func normalizeGroupSelectionStrategyInput(value string) (string, error) {
+	if strings.TrimSpace(value) == "" {
+		return "", nil
+	}
+	strategy := NormalizeChannelSelectionStrategy(value)
+	if strategy == "" {
+		return "", fmt.Errorf("不支持的渠道选择策略: %s", strings.TrimSpace(value))
+	}
+	return strategy, nil
+}
+
+func defaultChannelSelectionStrategy() string {
+	if strategy := NormalizeChannelSelectionStrategy(config.ChannelSelectionStrategy); strategy != "" {
+		return strategy
+	}
+	return ChannelSelectionStrategyRandom
+}
+
+// ResolveGroupChannelSelectionStrategy returns the strategy configured on the
+// group, falling back to relay.channel_selection_strategy.
+func ResolveGroupChannelSelectionStrategy(group string) string {
+	groupID := strings.TrimSpace(group)
+	if groupID != "" {
+		groupSelectionStrategyLock.RLock()
+		strategy := groupSelectionStrategyMap[groupID]
+		groupSelectionStrategyLock.RUnlock()
+		if strategy != "" {
+			return strategy
+		}
+	}
+	return defaultChannelSelectionStrategy()
+}
+
+func setGroupSelectionStrategiesRuntime(strategies map[string]string) {
+	groupSelectionStrategyLock.Lock()
+	groupSelectionStrategyMap = strategies
+	groupSelectionStrategyLock.Unlock()
+}
+
+func syncGroupSelectionStrategiesRuntimeWithDB(db *gorm.DB) error {
+	if db == nil {
+		return nil
+	}
+	rows := make([]GroupCatalog, 0)
+	if err := db.Find(&rows).Error; err != nil {
+		return err
+	}
+	setGroupSelectionStrategiesRuntime(buildGroupSelectionStrategyMap(rows))
+	return nil
+}
+
+func buildGroupSelectionStrategyMap(rows []GroupCatalog) map[string]string {
+	strategies := make(map[string]string)
+	for _, row := range rows {
+		strategy := NormalizeChannelSelectionStrategy(row.SelectionStrategy)
+		if strategy == "" {
+			continue
+		}
+		for _, ref := range buildGroupReferenceValues(row) {
+			strategies[ref] = strategy
+		}
+	}
+	return strategies
+}
+
+// SelectChannelInTier picks one channel of a single priority tier with the
+// given strategy. Unknown strategies fall back to weighted random, and so does
+// cost_optimized, which orders candidates across tiers instead.
+func SelectChannelInTier(strategy string, channels []*Channel) *Channel {
+	if len(channels) == 0 {
+		return nil
+	}
+	if len(channels) == 1 {
+		return channels[0]
+	}
+	switch NormalizeChannelSelectionStrategy(strategy) {
+	case ChannelSelectionStrategyRandom:
+		return channels[rand.Intn(len(channels))]
+	case ChannelSelectionStrategyLeastOutstanding:
+		return selectLeastOutstandingChannel(channels)
+	case ChannelSelectionStrategyEWMA:
+		return selectEWMAChannel(channels)
+	default:
+		return selectWeightedChannel(channels)
+	}
+}
+
+// selectLeastOutstandingChannel prefers the channel with the fewest in-flight
+// requests per unit of weight; ties are broken by weighted random.
+func selectLeastOutstandingChannel(channels []*Channel) *Channel {
+	stats := snapshotChannelSelectionStats(channels)
+	best := make([]*Channel, 0, len(channels))
+	bestLoad := 0.0
+	for _, channel := range channels {
+		if channel == nil {
+			continue
+		}
+		load := float64(stats[channel.Id].Outstanding) / float64(channel.GetWeight())
+		switch {
+		case len(best) == 0 || load < bestLoad:
+			best = append(best[:0], channel)
+			bestLoad = load
+		case load == bestLoad:
+			best = append(best, channel)
+		}
+	}
+	return selectWeightedChannel(best)
+}
+
+// selectEWMAChannel scores each channel by its expected time to a successful
+// response, scaled by the requests already in flight, and picks randomly with
+// probability proportional to weight/score. Channels without samples are
+// scored with the tier average so new channels still receive traffic.
+func selectEWMAChannel(channels []*Channel) *Channel {
+	stats := snapshotChannelSelectionStats(channels)
+	var knownLatency float64
+	var knownCount int
+	for _, channel := range channels {
+		if channel == nil {
+			continue
+		}
+		if stat := stats[channel.Id]; stat.Samples > 0 && stat.LatencyMs > 0 {
+			knownLatency += stat.LatencyMs
+			knownCount++
+		}
+	}
+	defaultLatency := 1.0
+	if knownCount > 0 {
+		defaultLatency = knownLatency / float64(knownCount)
+	}
+	scores := make([]float64, len(channels))
+	var total float64
+	for i, channel := range channels {
+		if channel == nil {
+			continue
+		}
+		stat := stats[channel.Id]
+		latency := stat.LatencyMs
+		if latency <= 0 {
+			latency = defaultLatency
+		}
+		successRate := 1 - stat.ErrorRate
+		if successRate < 0.05 {
+			successRate = 0.05
+		}
+		cost := (latency + 1) * float64(1+stat.Outstanding) / successRate
+		scores[i] = float64(channel.GetWeight()) / cost
+		total += scores[i]
+	}
+	if total <= 0 {
+		return selectWeightedChannel(channels)
+	}
+	target := rand.Float64() * total
+	var cumulative float64
+	for i, channel := range channels {
+		if channel == nil {
+			continue
+		}
+		cumulative += scores[i]
+		if target < cumulative {
+			return channel
+		}
+	}
+	return channels[len(channels)-1]
+}
+
+func snapshotChannelSelectionStats(channels []*Channel) map[string]ChannelSelectionStat {
+	result := make(map[string]ChannelSelectionStat, len(channels))
+	channelSelectionStatsLock.Lock()
+	defer channelSelectionStatsLock.Unlock()
+	for _, channel := range channels {
+		if channel == nil {
+			continue
+		}
+		if stat, ok := channelSelectionStats[channel.Id]; ok {
+			result[channel.Id] = *stat
+		}
+	}
+	return result
+}
+
+func channelSelectionStatLocked(channelID string) *ChannelSelectionStat {
+	stat, ok := channelSelectionStats[channelID]
+	if !ok {
+		stat = &ChannelSelectionStat{}
+		channelSelectionStats[channelID] = stat
+	}
+	return stat
+}
+
+// pruneChannelSelectionStats drops the stats of channels that are no longer
+// routable, so deleted and disabled channels do not accumulate across syncs.
+func pruneChannelSelectionStats(keep map[string]*Channel) {
+	channelSelectionStatsLock.Lock()
+	defer channelSelectionStatsLock.Unlock()
+	for channelID := range channelSelectionStats {
+		if _, ok := keep[channelID]; !ok {
+			delete(channelSelectionStats, channelID)
+		}
+	}
+}
+
+// GetChannelSelectionStat returns the current selection stats of a channel.
+func GetChannelSelectionStat(channelID string) ChannelSelectionStat {
+	normalizedChannelID := strings.TrimSpace(channelID)
+	channelSelectionStatsLock.Lock()
+	defer channelSelectionStatsLock.Unlock()
+	if stat, ok := channelSelectionStats[normalizedChannelID]; ok {
+		return *stat
+	}
+	return ChannelSelectionStat{}
+}
+
+// BeginChannelRequest counts a relay attempt as outstanding on the channel.
+// The returned function must be called once the attempt finishes; successful
+// attempts also feed the latency average.
+func BeginChannelRequest(channelID string) func(success bool) {
+	normalizedChannelID := strings.TrimSpace(channelID)
+	if normalizedChannelID == "" {
+		return func(bool) {}
+	}
+	startedAt := time.Now()
+	channelSelectionStatsLock.Lock()
+	channelSelectionStatLocked(normalizedChannelID).Outstanding++
+	channelSelectionStatsLock.Unlock()
+	var once sync.Once
+	return func(success bool) {
+		once.Do(func() {
+			latencyMs := float64(time.Since(startedAt).Milliseconds())
+			channelSelectionStatsLock.Lock()
+			defer channelSelectionStatsLock.Unlock()
+			stat := channelSelectionStatLocked(normalizedChannelID)
+			if stat.Outstanding > 0 {
+				stat.Outstanding--
+			}
+			if !success {
+				return
+			}
+			if stat.LatencyMs <= 0 {
+				stat.LatencyMs = latencyMs
+			} else {
+				stat.LatencyMs += channelSelectionEWMADecay * (latencyMs - stat.LatencyMs)
+			}
+		})
+	}
+}
+
+// RecordChannelOutcome feeds the error-rate average with a relay outcome, as
+// reported to monitor.Emit.
+func RecordChannelOutcome(channelID string, success bool) {
+	normalizedChannelID := strings.TrimSpace(channelID)
+	if normalizedChannelID == "" {
+		return
+	}
+	sample := 1.0
+	if success {
+		sample = 0
+	}
+	channelSelectionStatsLock.Lock()
+	defer channelSelectionStatsLock.Unlock()
+	stat := channelSelectionStatLocked(normalizedChannelID)
+	if stat.Samples == 0 {
+		stat.ErrorRate = sample
+	} else {
+		stat.ErrorRate += channelSelectionEWMADecay * (sample - stat.ErrorRate)
+	}
+	stat.Samples++
+}
@@ -0,0 +1,137 @@
+package model
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/yeying-community/router/common/config"
+)
+
+func resetChannelSelectionStatsForTest(t *testing.T) {
+	t.Helper()
+	channelSelectionStatsLock.Lock()
+	previous := channelSelectionStats
+	channelSelectionStats = map[string]*ChannelSelectionStat{}
+	channelSelectionStatsLock.Unlock()
+	t.Cleanup(func() {
+		channelSelectionStatsLock.Lock()
+		channelSelectionStats = previous
+		channelSelectionStatsLock.Unlock()
+	})
+}
+
+func TestNormalizeChannelSelectionStrategy(t *testing.T) {
+	cases := map[string]string{
+		"":                    "",
+		"random":              ChannelSelectionStrategyRandom,
+		" Weighted_Random ":   ChannelSelectionStrategyWeightedRandom,
+		"least_outstanding":   ChannelSelectionStrategyLeastOutstanding,
+		"EWMA":                ChannelSelectionStrategyEWMA,
+		"round_robin_unknown": "",
+	}
+	for input, want := range cases {
+		if got := NormalizeChannelSelectionStrategy(input); got != want {
+			t.Fatalf("NormalizeChannelSelectionStrategy(%q) = %q, want %q", input, got, want)
+		}
+	}
+	if _, err := normalizeGroupSelectionStrategyInput("fastest"); err == nil {
+		t.Fatalf("expected unknown strategy to be rejected")
+	}
+}
+
+func TestResolveGroupChannelSelectionStrategyFallsBackToConfig(t *testing.T) {
+	previousDefault := config.ChannelSelectionStrategy
+	t.Cleanup(func() {
+		config.ChannelSelectionStrategy = previousDefault
+		setGroupSelectionStrategiesRuntime(map[string]string{})
+	})
+	config.ChannelSelectionStrategy = "least_outstanding"
+	setGroupSelectionStrategiesRuntime(buildGroupSelectionStrategyMap([]GroupCatalog{
+		{Id: "group-ewma", Name: "fast", SelectionStrategy: "ewma"},
+		{Id: "group-default", Name: "plain"},
+	}))
+
+	if got := ResolveGroupChannelSelectionStrategy("fast"); got != ChannelSelectionStrategyEWMA {
+		t.Fatalf("group name strategy = %q, want ewma", got)
+	}
+	if got := ResolveGroupChannelSelectionStrategy("group-ewma"); got != ChannelSelectionStrategyEWMA {
+		t.Fatalf("group id strategy = %q, want ewma", got)
+	}
+	if got := ResolveGroupChannelSelectionStrategy("plain"); got != ChannelSelectionStrategyLeastOutstanding {
+		t.Fatalf("default strategy = %q, want least_outstanding", got)
+	}
+	config.ChannelSelectionStrategy = "bogus"
+	if got := ResolveGroupChannelSelectionStrategy("plain"); got != ChannelSelectionStrategyRandom {
+		t.Fatalf("invalid default strategy = %q, want random", got)
+	}
+}
+
+func TestSelectChannelInTierLeastOutstandingPrefersIdleChannel(t *testing.T) {
+	resetChannelSelectionStatsForTest(t)
+	channels := []*Channel{{Id: "busy"}, {Id: "idle"}}
+	finishBusy := BeginChannelRequest("busy")
+	defer finishBusy(true)
+
+	for i := 0; i < 50; i++ {
+		if got := SelectChannelInTier(ChannelSelectionStrategyLeastOutstanding, channels); got.Id != "idle" {
+			t.Fatalf("least_outstanding picked %q, want idle", got.Id)
+		}
+	}
+	finishBusy(true)
+	if stat := GetChannelSelectionStat("busy"); stat.Outstanding != 0 {
+		t.Fatalf("outstanding after finish = %d, want 0", stat.Outstanding)
+	}
+}
+
+func TestSelectChannelInTierEWMAFavorsHealthyChannel(t *testing.T) {
+	resetChannelSelectionStatsForTest(t)
+	rand.Seed(3)
+	channelSelectionStatsLock.Lock()
+	channelSelectionStats["slow"] = &ChannelSelectionStat{LatencyMs: 4000, Samples: 10}
+	channelSelectionStats["fast"] = &ChannelSelectionStat{LatencyMs: 400, Samples: 10}
+	channelSelectionStatsLock.Unlock()
+	for i := 0; i < 10; i++ {
+		RecordChannelOutcome("slow", false)
+		RecordChannelOutcome("fast", true)
+	}
+	channels := []*Channel{{Id: "slow"}, {Id: "fast"}}
+	counts := map[string]int{}
+	for i := 0; i < 2000; i++ {
+		counts[SelectChannelInTier(ChannelSelectionStrategyEWMA, channels).Id]++
+	}
+	if counts["fast"] <= counts["slow"]*10 {
+		t.Fatalf("ewma selection did not favor healthy channel: fast=%d slow=%d", counts["fast"], counts["slow"])
+	}
+	if counts["slow"] == 0 {
+		t.Fatalf("ewma selection starved the degraded channel")
+	}
+}
+
+func TestRecordChannelOutcomeTracksErrorRate(t *testing.T) {
+	resetChannelSelectionStatsForTest(t)
+	RecordChannelOutcome("channel-1", false)
+	if stat := GetChannelSelectionStat("channel-1"); stat.ErrorRate != 1 || stat.Samples != 1 {
+		t.Fatalf("first failure stat = %+v", stat)
+	}
+	RecordChannelOutcome("channel-1", true)
+	stat := GetChannelSelectionStat("channel-1")
+	if stat.ErrorRate >= 1 || stat.ErrorRate <= 0 {
+		t.Fatalf("error rate after success = %v, want between 0 and 1", stat.ErrorRate)
+	}
+}
+
+func TestSelectSatisfiedChannelWithStrategyReportsStrategy(t *testing.T) {
+	resetChannelSelectionStatsForTest(t)
+	priority := int64(5)
+	channels := []*Channel{{Id: "a", Priority: &priority}, {Id: "b", Priority: &priority}}
+	finish := BeginChannelRequest("a")
+	defer finish(true)
+
+	got, stats := SelectSatisfiedChannelWithStrategy(channels, ChannelSelectionStrategyLeastOutstanding, false, nil)
+	if got == nil || got.Id != "b" {
+		t.Fatalf("selected = %+v, want b", got)
+	}
+	if stats.SelectionStrategy != ChannelSelectionStrategyLeastOutstanding {
+		t.Fatalf("selection strategy = %q", stats.SelectionStrategy)
+	}
+}
@@ -12,15 +12,18 @@ import (
 )
 
 type GroupCatalog struct {
-	Id          string             `json:"id" gorm:"primaryKey;type:char(36)"`
-	Name        string             `json:"name" gorm:"type:varchar(64);not null;uniqueIndex"`
-	Description string             `json:"description" gorm:"type:varchar(255);default:''"`
-	Source      string             `json:"source" gorm:"type:varchar(32);default:'system'"`
-	Enabled     bool               `json:"enabled" gorm:"index"`
-	SortOrder   int                `json:"sort_order" gorm:"default:0;index"`
-	CreatedAt   int64              `json:"created_at" gorm:"bigint;index"`
-	UpdatedAt   int64              `json:"updated_at" gorm:"bigint;index"`
-	Channels    []GroupChannelItem `json:"channels,omitempty" gorm:"-"`
+	Id                string             `json:"id" gorm:"primaryKey;type:char(36)"`
+	Name              string             `json:"name" gorm:"type:varchar(64);not null;uniqueIndex"`
+	Description       string             `json:"description" gorm:"type:varchar(255);default:''"`
+	Source            string             `json:"source" gorm:"type:varchar(32);default:'system'"`
+	Enabled           bool               `json:"enabled" gorm:"index"`
+	SortOrder         int                `json:"sort_order" gorm:"default:0;index"`
+	SelectionStrategy string             `json:"selection_strategy" gorm:"type:varchar(32);default:''"`
+	RPMLimitPerUser   int                `json:"rpm_limit_per_user" gorm:"column:rpm_limit_per_user;not null;default:0"`
+	TPMLimitPerUser   int                `json:"tpm_limit_per_user" gorm:"column:tpm_limit_per_user;not null;default:0"`
+	CreatedAt         int64              `json:"created_at" gorm:"bigint;index"`
+	UpdatedAt         int64              `json:"updated_at" gorm:"bigint;index"`
+	Channels          []GroupChannelItem `json:"channels,omitempty" gorm:"-"`
 }
 
 func (GroupCatalog) TableName() string {
@@ -413,20 +416,27 @@ func createGroupCatalogWithDB(db *gorm.DB, item GroupCatalog) (GroupCatalog, err
 		return GroupCatalog{}, err
 	}
 
+	selectionStrategy, err := normalizeGroupSelectionStrategyInput(item.SelectionStrategy)
+	if err != nil {
+		return GroupCatalog{}, err
+	}
 	maxSortOrder := 0
 	if err := db.Model(&GroupCatalog{}).Select("COALESCE(MAX(sort_order), 0)").Scan(&maxSortOrder).Error; err != nil {
 		return GroupCatalog{}, err
 	}
 	now := helper.GetTimestamp()
 	row := GroupCatalog{
-		Id:          strings.TrimSpace(item.Id),
-		Name:        item.Identifier(),
-		Description: strings.TrimSpace(item.Description),
-		Source:      strings.TrimSpace(item.Source),
-		Enabled:     true,
-		SortOrder:   maxSortOrder + 1,
-		CreatedAt:   now,
-		UpdatedAt:   now,
+		Id:                strings.TrimSpace(item.Id),
+		Name:              item.Identifier(),
+		Description:       strings.TrimSpace(item.Description),
+		Source:            strings.TrimSpace(item.Source),
+		Enabled:           true,
+		SortOrder:         maxSortOrder + 1,
+		SelectionStrategy: selectionStrategy,
+		RPMLimitPerUser:   normalizeRateLimitValue(item.RPMLimitPerUser),
+		TPMLimitPerUser:   normalizeRateLimitValue(item.TPMLimitPerUser),
+		CreatedAt:         now,
+		UpdatedAt:         now,
 	}
 	row.EnsureID()
 	if row.Source == "" {
@@ -466,8 +476,15 @@ func updateGroupCatalogWithDB(db *gorm.DB, item GroupCatalog) (GroupCatalog, err
 			return GroupCatalog{}, err
 		}
 	}
+	selectionStrategy, err := normalizeGroupSelectionStrategyInput(item.SelectionStrategy)
+	if err != nil {
+		return GroupCatalog{}, err
+	}
 	row.Name = nextName
 	row.Description = strings.TrimSpace(item.Description)
+	row.SelectionStrategy = selectionStrategy
+	row.RPMLimitPerUser = normalizeRateLimitValue(item.RPMLimitPerUser)
+	row.TPMLimitPerUser = normalizeRateLimitValue(item.TPMLimitPerUser)
 	row.Enabled = item.Enabled
 	if item.SortOrder > 0 {
 		row.SortOrder = item.SortOrder
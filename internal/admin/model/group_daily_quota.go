@@ -45,6 +45,12 @@ func syncGroupRuntimeCachesWithDB(db *gorm.DB) error {
 	if db == nil {
 		return nil
 	}
+	if err := syncGroupSelectionStrategiesRuntimeWithDB(db); err != nil {
+		return err
+	}
+	if err := syncGroupRateLimitsRuntimeWithDB(db); err != nil {
+		return err
+	}
 	return syncGroupBillingRatiosRuntimeWithDB(db)
 }
 
@@ -0,0 +1,183 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/yeying-community/router/common/helper"
+	"github.com/yeying-community/router/common/random"
+	"gorm.io/gorm"
+)
+
+// hedgePolicyMaxDelayMs bounds the hedge delay; waiting longer than this for
+// a first byte is better handled by the regular retry loop.
+const hedgePolicyMaxDelayMs = 60000
+
+// HedgePolicy enables hedged requests for a group, either for one model or,
+// with an empty Model, for every model of the group. Once the first attempt
+// has not started its response after DelayMs, the same request is also sent
+// to a second channel and the first one to respond is streamed.
+type HedgePolicy struct {
+	Id        string `json:"id" gorm:"type:char(36);primaryKey"`
+	GroupID   string `json:"group_id" gorm:"column:group_id;type:varchar(64);not null;uniqueIndex:idx_hedge_policy_group_model,priority:1"`
+	Model     string `json:"model" gorm:"type:varchar(191);not null;default:'';uniqueIndex:idx_hedge_policy_group_model,priority:2"`
+	DelayMs   int    `json:"delay_ms" gorm:"not null;default:0"`
+	Enabled   bool   `json:"enabled" gorm:"default:false"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint"`
+	UpdatedAt int64  `json:"updated_at" gorm:"bigint"`
+}
+
+func (HedgePolicy) TableName() string {
+	return "hedge_policies"
+}
+
+func normalizeHedgePolicy(policy *HedgePolicy) error {
+	policy.GroupID = strings.TrimSpace(policy.GroupID)
+	policy.Model = strings.TrimSpace(policy.Model)
+	if policy.GroupID == "" {
+		return fmt.Errorf("对冲策略分组不能为空")
+	}
+	if policy.DelayMs <= 0 || policy.DelayMs > hedgePolicyMaxDelayMs {
+		return fmt.Errorf("对冲延迟必须在 1 到 %d 毫秒之间", hedgePolicyMaxDelayMs)
+	}
+	return nil
+}
+
+var (
+	hedgePolicyLock    sync.RWMutex
+	hedgePolicyRuntime = map[string]map[string]int{}
+)
+
+func setHedgePoliciesRuntime(rows []HedgePolicy) {
+	policies := map[string]map[string]int{}
+	for _, row := range rows {
+		if !row.Enabled || row.DelayMs <= 0 {
+			continue
+		}
+		if policies[row.GroupID] == nil {
+			policies[row.GroupID] = map[string]int{}
+		}
+		policies[row.GroupID][row.Model] = row.DelayMs
+	}
+	hedgePolicyLock.Lock()
+	hedgePolicyRuntime = policies
+	hedgePolicyLock.Unlock()
+}
+
+func SyncHedgePoliciesRuntimeWithDB(db *gorm.DB) error {
+	if db == nil {
+		return nil
+	}
+	rows := make([]HedgePolicy, 0)
+	if err := db.Find(&rows).Error; err != nil {
+		return err
+	}
+	setHedgePoliciesRuntime(rows)
+	return nil
+}
+
+// ResolveHedgeDelayMs returns the hedge delay of modelName in the group, 0
+// when hedging is off. A model-specific policy wins over the group-wide one.
+func ResolveHedgeDelayMs(groupID string, modelName string) int {
+	hedgePolicyLock.RLock()
+	defer hedgePolicyLock.RUnlock()
+	policies := hedgePolicyRuntime[strings.TrimSpace(groupID)]
+	if delay, ok := policies[strings.TrimSpace(modelName)]; ok {
+		return delay
+	}
+	return policies[""]
+}
+
+func ListHedgePoliciesWithDB(db *gorm.DB, groupID string) ([]HedgePolicy, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database handle is nil")
+	}
+	query := db.Model(&HedgePolicy{})
+	if normalizedGroupID := strings.TrimSpace(groupID); normalizedGroupID != "" {
+		query = query.Where("group_id = ?", normalizedGroupID)
+	}
+	rows := make([]HedgePolicy, 0)
+	if err := query.Order("group_id ASC, model ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// SaveHedgePolicyWithDB creates the policy or replaces the existing one for
+// the same group and model. The group may be given by id or name.
+func SaveHedgePolicyWithDB(db *gorm.DB, policy HedgePolicy) (HedgePolicy, error) {
+	if db == nil {
+		return HedgePolicy{}, fmt.Errorf("database handle is nil")
+	}
+	if err := normalizeHedgePolicy(&policy); err != nil {
+		return HedgePolicy{}, err
+	}
+	group, err := resolveGroupCatalogByReferenceWithDB(db, policy.GroupID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return HedgePolicy{}, fmt.Errorf("分组不存在")
+		}
+		return HedgePolicy{}, err
+	}
+	policy.GroupID = strings.TrimSpace(group.Id)
+	now := helper.GetTimestamp()
+	existing := HedgePolicy{}
+	err = db.Where("group_id = ? AND model = ?", policy.GroupID, policy.Model).First(&existing).Error
+	switch {
+	case err == nil:
+		policy.Id = existing.Id
+		policy.CreatedAt = existing.CreatedAt
+		policy.UpdatedAt = now
+		if err := db.Select("*").Save(&policy).Error; err != nil {
+			return HedgePolicy{}, err
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		policy.Id = random.GetUUID()
+		policy.CreatedAt = now
+		policy.UpdatedAt = now
+		if err := db.Create(&policy).Error; err != nil {
+			return HedgePolicy{}, err
+		}
+	default:
+		return HedgePolicy{}, err
+	}
+	return policy, nil
+}
+
+func DeleteHedgePolicyWithDB(db *gorm.DB, id string) error {
+	if db == nil {
+		return fmt.Errorf("database handle is nil")
+	}
+	result := db.Where("id = ?", strings.TrimSpace(id)).Delete(&HedgePolicy{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func ListHedgePolicies(groupID string) ([]HedgePolicy, error) {
+	return ListHedgePoliciesWithDB(DB, groupID)
+}
+
+func SaveHedgePolicy(policy HedgePolicy) (HedgePolicy, error) {
+	row, err := SaveHedgePolicyWithDB(DB, policy)
+	if err != nil {
+		return HedgePolicy{}, err
+	}
+	if err := SyncHedgePoliciesRuntimeWithDB(DB); err != nil {
+		return HedgePolicy{}, err
+	}
+	return row, nil
+}
+
+func DeleteHedgePolicy(id string) error {
+	if err := DeleteHedgePolicyWithDB(DB, id); err != nil {
+		return err
+	}
+	return SyncHedgePoliciesRuntimeWithDB(DB)
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newHedgePolicyTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=private"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&HedgePolicy{}, &GroupCatalog{}); err != nil {
+		t.Fatalf("migrate hedge policies: %v", err)
+	}
+	if err := db.Create(&GroupCatalog{Id: "group-1", Name: "vip", Enabled: true}).Error; err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	return db
+}
+
+func TestSaveHedgePolicyValidatesAndUpsertsByGroupModel(t *testing.T) {
+	db := newHedgePolicyTestDB(t)
+	invalid := []HedgePolicy{
+		{GroupID: "vip", Model: "gpt-4o", DelayMs: 0},
+		{GroupID: "vip", Model: "gpt-4o", DelayMs: hedgePolicyMaxDelayMs + 1},
+		{GroupID: "", Model: "gpt-4o", DelayMs: 500},
+		{GroupID: "missing", Model: "gpt-4o", DelayMs: 500},
+	}
+	for _, policy := range invalid {
+		if _, err := SaveHedgePolicyWithDB(db, policy); err == nil {
+			t.Fatalf("policy %+v should be rejected", policy)
+		}
+	}
+
+	first, err := SaveHedgePolicyWithDB(db, HedgePolicy{GroupID: "vip", Model: " gpt-4o ", DelayMs: 800, Enabled: true})
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if first.GroupID != "group-1" || first.Model != "gpt-4o" {
+		t.Fatalf("first = %#v, want group id and trimmed model", first)
+	}
+	second, err := SaveHedgePolicyWithDB(db, HedgePolicy{GroupID: "group-1", Model: "gpt-4o", DelayMs: 300, Enabled: true})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if second.Id != first.Id {
+		t.Fatalf("update created a new row: %s != %s", second.Id, first.Id)
+	}
+	rows, err := ListHedgePoliciesWithDB(db, "group-1")
+	if err != nil || len(rows) != 1 || rows[0].DelayMs != 300 {
+		t.Fatalf("rows = %#v, err = %v", rows, err)
+	}
+}
+
+func TestResolveHedgeDelayMsPrefersModelPolicy(t *testing.T) {
+	t.Cleanup(func() { setHedgePoliciesRuntime(nil) })
+	setHedgePoliciesRuntime([]HedgePolicy{
+		{GroupID: "group-1", Model: "", DelayMs: 1500, Enabled: true},
+		{GroupID: "group-1", Model: "gpt-4o", DelayMs: 400, Enabled: true},
+		{GroupID: "group-1", Model: "o3", DelayMs: 900, Enabled: false},
+	})
+
+	if got := ResolveHedgeDelayMs("group-1", "gpt-4o"); got != 400 {
+		t.Fatalf("model delay = %d, want 400", got)
+	}
+	if got := ResolveHedgeDelayMs("group-1", "o3"); got != 1500 {
+		t.Fatalf("disabled model policy should fall back to the group delay, got %d", got)
+	}
+	if got := ResolveHedgeDelayMs("group-2", "gpt-4o"); got != 0 {
+		t.Fatalf("unconfigured group delay = %d, want 0", got)
+	}
+}
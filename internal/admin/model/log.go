@@ -84,6 +84,7 @@ type Log struct {
 	CompletionTokens                 int     `json:"completion_tokens" gorm:"default:0"`
 	ChannelId                        string  `json:"channel" gorm:"type:varchar(64);index"`
 	ChannelName                      string  `json:"channel_name,omitempty" gorm:"-"`
+	ChannelKeyId                     string  `json:"channel_key_id" gorm:"type:varchar(64);index;default:''"`
 	RequestModelName                 string  `json:"request_model_name" gorm:"type:varchar(191);index;default:''"`
 	ActualModelName                  string  `json:"actual_model_name" gorm:"type:varchar(191);index;default:''"`
 	UpstreamEndpoint                 string  `json:"upstream_endpoint" gorm:"type:varchar(191);index;default:''"`
@@ -94,8 +95,14 @@ type Log struct {
 	RelayErrorType                   string  `json:"relay_error_type" gorm:"type:varchar(64);default:''"`
 	RelayErrorCode                   string  `json:"relay_error_code" gorm:"type:varchar(128);default:''"`
 	RelayErrorMessage                string  `json:"relay_error_message" gorm:"type:text"`
+	ModerationDecision               string  `json:"moderation_decision" gorm:"type:varchar(16);index;default:''"`
+	ModerationReason                 string  `json:"moderation_reason" gorm:"type:text"`
 	TraceID                          string  `json:"trace_id" gorm:"column:trace_id;default:''"`
 	ElapsedTime                      int64   `json:"elapsed_time" gorm:"default:0"`
+	FirstByteMs                      int64   `json:"first_byte_ms" gorm:"default:0"`
+	FirstTokenMs                     int64   `json:"first_token_ms" gorm:"default:0"`
+	UpstreamLatencyMs                int64   `json:"upstream_latency_ms" gorm:"default:0"`
+	OutputTokensPerSecond            float64 `json:"output_tokens_per_second" gorm:"type:double precision;default:0"`
 	IsStream                         bool    `json:"is_stream" gorm:"default:false"`
 }
 
@@ -297,6 +304,35 @@ type LogStatistic struct {
 	CompletionTokens int    `gorm:"column:completion_tokens"`
 }
 
+const (
+	RelayLatencyGroupByModel   = "model"
+	RelayLatencyGroupByChannel = "channel"
+)
+
+// LatencyPercentiles summarizes one timing metric; Count is how many logs
+// carried it, e.g. only stream requests have a first token time.
+type LatencyPercentiles struct {
+	Count int64   `json:"count"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+}
+
+type RelayLatencyStat struct {
+	Model                 string             `json:"model,omitempty"`
+	ChannelId             string             `json:"channel_id,omitempty"`
+	ChannelName           string             `json:"channel_name,omitempty"`
+	RequestCount          int64              `json:"request_count"`
+	FirstByteMs           LatencyPercentiles `json:"first_byte_ms"`
+	FirstTokenMs          LatencyPercentiles `json:"first_token_ms"`
+	UpstreamLatencyMs     LatencyPercentiles `json:"upstream_latency_ms"`
+	OutputTokensPerSecond LatencyPercentiles `json:"output_tokens_per_second"`
+}
+
+func GetRelayLatencyStats(groupBy string, startTimestamp int64, endTimestamp int64, modelName string, channel string) ([]*RelayLatencyStat, error) {
+	return mustLogRepo().GetRelayLatencyStats(groupBy, startTimestamp, endTimestamp, modelName, channel)
+}
+
 func SearchLogsByPeriodAndModel(userId string, start, end int, granularity string, models []string) ([]*LogStatistic, error) {
 	return mustLogRepo().SearchLogsByPeriodAndModel(userId, start, end, granularity, models)
 }
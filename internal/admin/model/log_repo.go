@@ -21,6 +21,7 @@ type LogRepository struct {
 	DeleteOldLog                          func(targetTimestamp int64) (int64, error)
 	SearchLogsByPeriodAndModel            func(userId string, start int, end int, granularity string, models []string) ([]*LogStatistic, error)
 	SearchLogModelsByPeriod               func(userId string, start int, end int) ([]string, error)
+	GetRelayLatencyStats                  func(groupBy string, startTimestamp int64, endTimestamp int64, modelName string, channel string) ([]*RelayLatencyStat, error)
 }
 
 var logRepo LogRepository
@@ -9,6 +9,7 @@ import (
 	"github.com/yeying-community/router/common"
 	"github.com/yeying-community/router/common/config"
 	"github.com/yeying-community/router/common/logger"
+	"github.com/yeying-community/router/common/tracing"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -26,7 +27,11 @@ func chooseDB(dsn string) (*gorm.DB, error) {
 	if trimmed == "" {
 		return nil, errors.New("database.sql_dsn is required and only PostgreSQL is supported")
 	}
-	return openPostgreSQL(trimmed, true)
+	db, err := openPostgreSQL(trimmed, true)
+	if err != nil {
+		return nil, err
+	}
+	return db, tracing.RegisterGormCallbacks(db)
 }
 
 func chooseMigrationDB(dsn string) (*gorm.DB, error) {
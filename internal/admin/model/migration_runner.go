@@ -1976,6 +1976,90 @@ func runMainVersionedMigrations(db *gorm.DB) error {
 				return replaceProviderMigrationSeedsWithDB(tx)
 			},
 		},
+		{
+			Version:     "202610171000_response_states",
+			Description: "add local responses state store for previous_response_id replay",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&ResponseState{})
+			},
+		},
+		{
+			Version:     "202610171100_relay_files",
+			Description: "add gateway files api storage and per-channel provider file ids",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&RelayFile{}, &RelayFileUpstream{})
+			},
+		},
+		{
+			Version:     "202610171200_relay_batches",
+			Description: "add relay batches executed by the gateway task runner",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&RelayBatch{})
+			},
+		},
+		{
+			Version:     "202610171300_moderation_policies",
+			Description: "add pre-relay moderation policies and per-user violation counters",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&ModerationPolicy{}, &User{})
+			},
+		},
+		{
+			Version:     "202610171310_log_moderation_decision",
+			Description: "add moderation decision to request logs",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&Log{})
+			},
+		},
+		{
+			Version:     "202610171400_group_selection_strategy",
+			Description: "add per-group channel selection strategy",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&GroupCatalog{})
+			},
+		},
+		{
+			Version:     "202610171500_model_routes",
+			Description: "add per-group model fallback chains and aliases",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&ModelRoute{})
+			},
+		},
+		{
+			Version:     "202610171600_hedge_policies",
+			Description: "add per-group hedged request policies",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&HedgePolicy{})
+			},
+		},
+		{
+			Version:     "202610171700_channel_limits",
+			Description: "add per-channel concurrency, RPM and TPM limits",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&ChannelLimit{})
+			},
+		},
+		{
+			Version:     "202610171800_rate_limits",
+			Description: "add per-token and per-user RPM/TPM limits",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&Token{}, &ServicePackage{}, &GroupCatalog{})
+			},
+		},
+		{
+			Version:     "202610181000_channel_keys",
+			Description: "add per-channel upstream key pools",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&ChannelKey{})
+			},
+		},
+		{
+			Version:     "202610181100_relay_batch_lines",
+			Description: "save finished batch lines so batches interrupted by a shutdown resume",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&RelayBatchLine{})
+			},
+		},
 	}
 	return runVersionedMigrations(db, migrationScopeMain, migrations)
 }
@@ -3412,6 +3496,27 @@ func runLogVersionedMigrations(db *gorm.DB) error {
 				return tx.AutoMigrate(&Log{})
 			},
 		},
+		{
+			Version:     "202610171310_log_moderation_decision",
+			Description: "add moderation decision to request logs",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&Log{})
+			},
+		},
+		{
+			Version:     "202610181000_log_channel_key",
+			Description: "attribute request logs to the channel pool key used",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&Log{})
+			},
+		},
+		{
+			Version:     "202610181200_log_relay_timing",
+			Description: "record first byte, first token, upstream latency and output throughput on request logs",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&Log{})
+			},
+		},
 	}
 	return runVersionedMigrations(db, migrationScopeLog, migrations)
 }
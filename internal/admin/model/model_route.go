@@ -0,0 +1,310 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/yeying-community/router/common/helper"
+	"github.com/yeying-community/router/common/random"
+	"gorm.io/gorm"
+)
+
+const (
+	// ModelRouteKindFallback lists the models tried in order once every
+	// channel of the source model has failed or none is available.
+	ModelRouteKindFallback = "fallback"
+	// ModelRouteKindAlias makes the source model a virtual name that is
+	// served by one of its targets, picked by weight per request.
+	ModelRouteKindAlias = "alias"
+)
+
+// ModelRoute is a per-group cross-model routing rule.
+type ModelRoute struct {
+	Id        string `json:"id" gorm:"type:char(36);primaryKey"`
+	GroupID   string `json:"group_id" gorm:"column:group_id;type:varchar(64);not null;uniqueIndex:idx_model_route_group_model,priority:1"`
+	Model     string `json:"model" gorm:"type:varchar(191);not null;uniqueIndex:idx_model_route_group_model,priority:2"`
+	Kind      string `json:"kind" gorm:"type:varchar(16);not null;default:'fallback'"`
+	Enabled   bool   `json:"enabled" gorm:"default:false"`
+	Targets   string `json:"targets" gorm:"type:text"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint"`
+	UpdatedAt int64  `json:"updated_at" gorm:"bigint"`
+}
+
+func (ModelRoute) TableName() string {
+	return "model_routes"
+}
+
+// ModelRouteTarget is one line of ModelRoute.Targets: a model name optionally
+// followed by a weight, which only aliases use.
+type ModelRouteTarget struct {
+	Model  string `json:"model"`
+	Weight int    `json:"weight"`
+}
+
+// TargetList parses the targets, one per line, skipping blank lines.
+func (route ModelRoute) TargetList() []ModelRouteTarget {
+	lines := strings.Split(strings.ReplaceAll(route.Targets, "\r\n", "\n"), "\n")
+	targets := make([]ModelRouteTarget, 0, len(lines))
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		target := ModelRouteTarget{Model: fields[0], Weight: 1}
+		if len(fields) > 1 {
+			weight, err := strconv.Atoi(fields[1])
+			if err != nil {
+				weight = 0
+			}
+			target.Weight = weight
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+func normalizeModelRoute(route *ModelRoute) error {
+	route.GroupID = strings.TrimSpace(route.GroupID)
+	route.Model = strings.TrimSpace(route.Model)
+	route.Kind = strings.ToLower(strings.TrimSpace(route.Kind))
+	if route.Kind == "" {
+		route.Kind = ModelRouteKindFallback
+	}
+	switch route.Kind {
+	case ModelRouteKindFallback, ModelRouteKindAlias:
+	default:
+		return fmt.Errorf("模型路由类型不合法")
+	}
+	if route.GroupID == "" {
+		return fmt.Errorf("模型路由分组不能为空")
+	}
+	if route.Model == "" {
+		return fmt.Errorf("模型路由源模型不能为空")
+	}
+	targets := route.TargetList()
+	if len(targets) == 0 {
+		return fmt.Errorf("模型路由至少需要一个目标模型")
+	}
+	lines := make([]string, 0, len(targets))
+	seen := make(map[string]struct{}, len(targets))
+	for _, target := range targets {
+		if target.Model == route.Model {
+			return fmt.Errorf("目标模型不能与源模型相同: %s", target.Model)
+		}
+		if _, ok := seen[target.Model]; ok {
+			return fmt.Errorf("目标模型重复: %s", target.Model)
+		}
+		seen[target.Model] = struct{}{}
+		if route.Kind == ModelRouteKindFallback {
+			lines = append(lines, target.Model)
+			continue
+		}
+		if target.Weight <= 0 {
+			return fmt.Errorf("别名目标权重必须为正整数: %s", target.Model)
+		}
+		lines = append(lines, target.Model+" "+strconv.Itoa(target.Weight))
+	}
+	route.Targets = strings.Join(lines, "\n")
+	return nil
+}
+
+var (
+	modelRouteLock    sync.RWMutex
+	modelRouteRuntime = map[string]map[string]ModelRoute{}
+)
+
+func setModelRoutesRuntime(rows []ModelRoute) {
+	routes := map[string]map[string]ModelRoute{}
+	for _, row := range rows {
+		if !row.Enabled {
+			continue
+		}
+		if routes[row.GroupID] == nil {
+			routes[row.GroupID] = map[string]ModelRoute{}
+		}
+		routes[row.GroupID][row.Model] = row
+	}
+	modelRouteLock.Lock()
+	modelRouteRuntime = routes
+	modelRouteLock.Unlock()
+}
+
+func SyncModelRoutesRuntimeWithDB(db *gorm.DB) error {
+	if db == nil {
+		return nil
+	}
+	rows := make([]ModelRoute, 0)
+	if err := db.Find(&rows).Error; err != nil {
+		return err
+	}
+	setModelRoutesRuntime(rows)
+	return nil
+}
+
+func getModelRoute(groupID string, modelName string, kind string) (ModelRoute, bool) {
+	modelRouteLock.RLock()
+	defer modelRouteLock.RUnlock()
+	route, ok := modelRouteRuntime[strings.TrimSpace(groupID)][strings.TrimSpace(modelName)]
+	if !ok || route.Kind != kind {
+		return ModelRoute{}, false
+	}
+	return route, true
+}
+
+// ResolveModelFallbacks returns the fallback chain of modelName in the group,
+// in order. Chains are not followed transitively.
+func ResolveModelFallbacks(groupID string, modelName string) []string {
+	route, ok := getModelRoute(groupID, modelName, ModelRouteKindFallback)
+	if !ok {
+		return nil
+	}
+	targets := route.TargetList()
+	models := make([]string, 0, len(targets))
+	for _, target := range targets {
+		models = append(models, target.Model)
+	}
+	return models
+}
+
+// ResolveModelAlias picks the real model serving an alias in the group,
+// randomly by target weight.
+func ResolveModelAlias(groupID string, modelName string) (string, bool) {
+	route, ok := getModelRoute(groupID, modelName, ModelRouteKindAlias)
+	if !ok {
+		return "", false
+	}
+	return pickModelRouteTarget(route.TargetList())
+}
+
+func pickModelRouteTarget(targets []ModelRouteTarget) (string, bool) {
+	total := 0
+	for _, target := range targets {
+		if target.Weight > 0 {
+			total += target.Weight
+		}
+	}
+	if total <= 0 {
+		return "", false
+	}
+	pick := rand.Intn(total)
+	for _, target := range targets {
+		if target.Weight <= 0 {
+			continue
+		}
+		if pick < target.Weight {
+			return target.Model, true
+		}
+		pick -= target.Weight
+	}
+	return "", false
+}
+
+func ListModelRoutesWithDB(db *gorm.DB, groupID string) ([]ModelRoute, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database handle is nil")
+	}
+	query := db.Model(&ModelRoute{})
+	if normalizedGroupID := strings.TrimSpace(groupID); normalizedGroupID != "" {
+		query = query.Where("group_id = ?", normalizedGroupID)
+	}
+	rows := make([]ModelRoute, 0)
+	if err := query.Order("group_id ASC, model ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// SaveModelRouteWithDB creates the route or replaces the existing one for the
+// same group and source model. The group may be given by id or name.
+func SaveModelRouteWithDB(db *gorm.DB, route ModelRoute) (ModelRoute, error) {
+	if db == nil {
+		return ModelRoute{}, fmt.Errorf("database handle is nil")
+	}
+	if err := normalizeModelRoute(&route); err != nil {
+		return ModelRoute{}, err
+	}
+	group, err := resolveGroupCatalogByReferenceWithDB(db, route.GroupID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ModelRoute{}, fmt.Errorf("分组不存在")
+		}
+		return ModelRoute{}, err
+	}
+	route.GroupID = strings.TrimSpace(group.Id)
+	now := helper.GetTimestamp()
+	existing := ModelRoute{}
+	err = db.Where("group_id = ? AND model = ?", route.GroupID, route.Model).First(&existing).Error
+	switch {
+	case err == nil:
+		route.Id = existing.Id
+		route.CreatedAt = existing.CreatedAt
+		route.UpdatedAt = now
+		if err := db.Select("*").Save(&route).Error; err != nil {
+			return ModelRoute{}, err
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		route.Id = random.GetUUID()
+		route.CreatedAt = now
+		route.UpdatedAt = now
+		if err := db.Create(&route).Error; err != nil {
+			return ModelRoute{}, err
+		}
+	default:
+		return ModelRoute{}, err
+	}
+	return route, nil
+}
+
+func DeleteModelRouteWithDB(db *gorm.DB, id string) error {
+	if db == nil {
+		return fmt.Errorf("database handle is nil")
+	}
+	result := db.Where("id = ?", strings.TrimSpace(id)).Delete(&ModelRoute{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func ListModelRoutes(groupID string) ([]ModelRoute, error) {
+	return ListModelRoutesWithDB(DB, groupID)
+}
+
+func SaveModelRoute(route ModelRoute) (ModelRoute, error) {
+	row, err := SaveModelRouteWithDB(DB, route)
+	if err != nil {
+		return ModelRoute{}, err
+	}
+	if err := SyncModelRoutesRuntimeWithDB(DB); err != nil {
+		return ModelRoute{}, err
+	}
+	return row, nil
+}
+
+func DeleteModelRoute(id string) error {
+	if err := DeleteModelRouteWithDB(DB, id); err != nil {
+		return err
+	}
+	return SyncModelRoutesRuntimeWithDB(DB)
+}
+
+// ModelAliasConfigured reports whether any group defines modelName as an
+// alias, so callers can skip alias resolution for ordinary models.
+func ModelAliasConfigured(modelName string) bool {
+	normalizedModel := strings.TrimSpace(modelName)
+	modelRouteLock.RLock()
+	defer modelRouteLock.RUnlock()
+	for _, routes := range modelRouteRuntime {
+		if route, ok := routes[normalizedModel]; ok && route.Kind == ModelRouteKindAlias {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package model
+
+import (
+	"math/rand"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newModelRouteTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=private"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&ModelRoute{}, &GroupCatalog{}); err != nil {
+		t.Fatalf("migrate model routes: %v", err)
+	}
+	if err := db.Create(&GroupCatalog{Id: "group-1", Name: "vip", Enabled: true}).Error; err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	return db
+}
+
+func TestSaveModelRouteValidatesAndUpsertsByGroupModel(t *testing.T) {
+	db := newModelRouteTestDB(t)
+	invalid := []ModelRoute{
+		{GroupID: "vip", Model: "gpt-4o", Targets: ""},
+		{GroupID: "vip", Model: "gpt-4o", Targets: "gpt-4o"},
+		{GroupID: "vip", Model: "gpt-4o", Targets: "gpt-4.1\ngpt-4.1"},
+		{GroupID: "vip", Model: "ab", Kind: "alias", Targets: "gpt-4o 0"},
+		{GroupID: "vip", Model: "gpt-4o", Kind: "mirror", Targets: "gpt-4.1"},
+		{GroupID: "missing", Model: "gpt-4o", Targets: "gpt-4.1"},
+	}
+	for _, route := range invalid {
+		if _, err := SaveModelRouteWithDB(db, route); err == nil {
+			t.Fatalf("route %+v should be rejected", route)
+		}
+	}
+
+	first, err := SaveModelRouteWithDB(db, ModelRoute{GroupID: "vip", Model: " gpt-4o ", Targets: " gpt-4.1 \n\n claude-sonnet ", Enabled: true})
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if first.GroupID != "group-1" || first.Kind != ModelRouteKindFallback || first.Targets != "gpt-4.1\nclaude-sonnet" {
+		t.Fatalf("first = %#v, want group id, fallback kind and trimmed targets", first)
+	}
+	second, err := SaveModelRouteWithDB(db, ModelRoute{GroupID: "group-1", Model: "gpt-4o", Targets: "claude-sonnet", Enabled: true})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if second.Id != first.Id {
+		t.Fatalf("update created a new row: %s != %s", second.Id, first.Id)
+	}
+	rows, err := ListModelRoutesWithDB(db, "group-1")
+	if err != nil || len(rows) != 1 || rows[0].Targets != "claude-sonnet" {
+		t.Fatalf("rows = %#v, err = %v", rows, err)
+	}
+}
+
+func TestResolveModelRoutesFromRuntime(t *testing.T) {
+	t.Cleanup(func() { setModelRoutesRuntime(nil) })
+	setModelRoutesRuntime([]ModelRoute{
+		{GroupID: "group-1", Model: "gpt-4o", Kind: ModelRouteKindFallback, Enabled: true, Targets: "gpt-4.1\nclaude-sonnet"},
+		{GroupID: "group-1", Model: "ab", Kind: ModelRouteKindAlias, Enabled: true, Targets: "gpt-4o 3\ngpt-4.1 1"},
+		{GroupID: "group-1", Model: "off", Kind: ModelRouteKindAlias, Enabled: false, Targets: "gpt-4o"},
+	})
+
+	fallbacks := ResolveModelFallbacks("group-1", "gpt-4o")
+	if len(fallbacks) != 2 || fallbacks[0] != "gpt-4.1" || fallbacks[1] != "claude-sonnet" {
+		t.Fatalf("fallbacks = %v", fallbacks)
+	}
+	if got := ResolveModelFallbacks("group-2", "gpt-4o"); len(got) != 0 {
+		t.Fatalf("other group fallbacks = %v, want none", got)
+	}
+	if _, ok := ResolveModelAlias("group-1", "gpt-4o"); ok {
+		t.Fatal("fallback route must not resolve as alias")
+	}
+	if !ModelAliasConfigured("ab") || ModelAliasConfigured("off") || ModelAliasConfigured("gpt-4o") {
+		t.Fatal("ModelAliasConfigured should only report enabled aliases")
+	}
+
+	rand.Seed(7)
+	counts := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		target, ok := ResolveModelAlias("group-1", "ab")
+		if !ok {
+			t.Fatal("alias did not resolve")
+		}
+		counts[target]++
+	}
+	if counts["gpt-4o"] < counts["gpt-4.1"]*2 || counts["gpt-4.1"] == 0 {
+		t.Fatalf("alias split = %v, want roughly 3:1", counts)
+	}
+}
@@ -0,0 +1,298 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/yeying-community/router/common"
+	"github.com/yeying-community/router/common/blacklist"
+	"github.com/yeying-community/router/common/helper"
+	"github.com/yeying-community/router/common/logger"
+	"github.com/yeying-community/router/common/random"
+	"gorm.io/gorm"
+)
+
+const (
+	ModerationPolicyScopeGroup = "group"
+	ModerationPolicyScopeToken = "token"
+
+	ModerationActionBlock = "block"
+	ModerationActionFlag  = "flag"
+	ModerationActionAllow = "allow"
+
+	// ModerationDecisionPass marks a request that was screened and matched
+	// nothing; the other decisions reuse the action names.
+	ModerationDecisionPass = "pass"
+)
+
+// ModerationPolicy screens prompts before they are relayed. A token policy
+// takes precedence over the policy of the token's group.
+type ModerationPolicy struct {
+	Id        string `json:"id" gorm:"type:char(36);primaryKey"`
+	Scope     string `json:"scope" gorm:"type:varchar(16);not null;uniqueIndex:idx_moderation_policy_scope,priority:1"`
+	ScopeID   string `json:"scope_id" gorm:"column:scope_id;type:varchar(64);not null;uniqueIndex:idx_moderation_policy_scope,priority:2"`
+	Enabled   bool   `json:"enabled" gorm:"default:false"`
+	Action    string `json:"action" gorm:"type:varchar(16);not null;default:'block'"`
+	Keywords  string `json:"keywords" gorm:"type:text"`
+	Patterns  string `json:"patterns" gorm:"type:text"`
+	Model     string `json:"model" gorm:"type:varchar(191);default:''"`
+	ChannelID string `json:"channel_id" gorm:"column:channel_id;type:varchar(64);default:''"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint"`
+	UpdatedAt int64  `json:"updated_at" gorm:"bigint"`
+}
+
+func (ModerationPolicy) TableName() string {
+	return "moderation_policies"
+}
+
+// KeywordList returns the non-empty keywords, one per line.
+func (policy ModerationPolicy) KeywordList() []string {
+	return splitModerationPolicyLines(policy.Keywords)
+}
+
+// PatternList returns the non-empty regular expressions, one per line.
+func (policy ModerationPolicy) PatternList() []string {
+	return splitModerationPolicyLines(policy.Patterns)
+}
+
+func splitModerationPolicyLines(value string) []string {
+	lines := strings.Split(strings.ReplaceAll(value, "\r\n", "\n"), "\n")
+	items := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if trimmed := strings.TrimSpace(line); trimmed != "" {
+			items = append(items, trimmed)
+		}
+	}
+	return items
+}
+
+func normalizeModerationPolicy(policy *ModerationPolicy) error {
+	policy.Scope = strings.ToLower(strings.TrimSpace(policy.Scope))
+	policy.ScopeID = strings.TrimSpace(policy.ScopeID)
+	policy.Action = strings.ToLower(strings.TrimSpace(policy.Action))
+	policy.Model = strings.TrimSpace(policy.Model)
+	policy.ChannelID = strings.TrimSpace(policy.ChannelID)
+	policy.Keywords = strings.Join(policy.KeywordList(), "\n")
+	policy.Patterns = strings.Join(policy.PatternList(), "\n")
+	switch policy.Scope {
+	case ModerationPolicyScopeGroup, ModerationPolicyScopeToken:
+	default:
+		return fmt.Errorf("审核策略作用范围不合法")
+	}
+	if policy.ScopeID == "" {
+		return fmt.Errorf("审核策略作用对象不能为空")
+	}
+	if policy.Action == "" {
+		policy.Action = ModerationActionBlock
+	}
+	switch policy.Action {
+	case ModerationActionBlock, ModerationActionFlag, ModerationActionAllow:
+	default:
+		return fmt.Errorf("审核动作不合法")
+	}
+	if policy.Keywords == "" && policy.Patterns == "" && policy.Model == "" {
+		return fmt.Errorf("审核策略至少需要关键词、正则或审核模型之一")
+	}
+	for _, pattern := range policy.PatternList() {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("正则表达式不合法: %s", pattern)
+		}
+	}
+	return nil
+}
+
+var (
+	moderationPolicyLock    sync.RWMutex
+	moderationPolicyRuntime = map[string]map[string]ModerationPolicy{}
+)
+
+func setModerationPoliciesRuntime(rows []ModerationPolicy) {
+	policies := map[string]map[string]ModerationPolicy{}
+	for _, row := range rows {
+		if !row.Enabled {
+			continue
+		}
+		if policies[row.Scope] == nil {
+			policies[row.Scope] = map[string]ModerationPolicy{}
+		}
+		policies[row.Scope][row.ScopeID] = row
+	}
+	moderationPolicyLock.Lock()
+	moderationPolicyRuntime = policies
+	moderationPolicyLock.Unlock()
+}
+
+func SyncModerationPoliciesRuntimeWithDB(db *gorm.DB) error {
+	if db == nil {
+		return nil
+	}
+	rows := make([]ModerationPolicy, 0)
+	if err := db.Find(&rows).Error; err != nil {
+		return err
+	}
+	setModerationPoliciesRuntime(rows)
+	return nil
+}
+
+// GetEffectiveModerationPolicy returns the enabled policy for the token, or
+// the policy of its group when the token has none.
+func GetEffectiveModerationPolicy(tokenID string, groupID string) (ModerationPolicy, bool) {
+	moderationPolicyLock.RLock()
+	defer moderationPolicyLock.RUnlock()
+	if policy, ok := moderationPolicyRuntime[ModerationPolicyScopeToken][strings.TrimSpace(tokenID)]; ok {
+		return policy, true
+	}
+	if policy, ok := moderationPolicyRuntime[ModerationPolicyScopeGroup][strings.TrimSpace(groupID)]; ok {
+		return policy, true
+	}
+	return ModerationPolicy{}, false
+}
+
+func ListModerationPoliciesWithDB(db *gorm.DB, scope string) ([]ModerationPolicy, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database handle is nil")
+	}
+	query := db.Model(&ModerationPolicy{})
+	if normalizedScope := strings.ToLower(strings.TrimSpace(scope)); normalizedScope != "" {
+		query = query.Where("scope = ?", normalizedScope)
+	}
+	rows := make([]ModerationPolicy, 0)
+	if err := query.Order("scope ASC, scope_id ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// SaveModerationPolicyWithDB creates the policy or replaces the existing one
+// for the same scope and scope id.
+func SaveModerationPolicyWithDB(db *gorm.DB, policy ModerationPolicy) (ModerationPolicy, error) {
+	if db == nil {
+		return ModerationPolicy{}, fmt.Errorf("database handle is nil")
+	}
+	if err := normalizeModerationPolicy(&policy); err != nil {
+		return ModerationPolicy{}, err
+	}
+	now := helper.GetTimestamp()
+	existing := ModerationPolicy{}
+	err := db.Where("scope = ? AND scope_id = ?", policy.Scope, policy.ScopeID).First(&existing).Error
+	switch {
+	case err == nil:
+		policy.Id = existing.Id
+		policy.CreatedAt = existing.CreatedAt
+		policy.UpdatedAt = now
+		if err := db.Select("*").Save(&policy).Error; err != nil {
+			return ModerationPolicy{}, err
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		policy.Id = random.GetUUID()
+		policy.CreatedAt = now
+		policy.UpdatedAt = now
+		if err := db.Create(&policy).Error; err != nil {
+			return ModerationPolicy{}, err
+		}
+	default:
+		return ModerationPolicy{}, err
+	}
+	return policy, nil
+}
+
+func DeleteModerationPolicyWithDB(db *gorm.DB, id string) error {
+	if db == nil {
+		return fmt.Errorf("database handle is nil")
+	}
+	result := db.Where("id = ?", strings.TrimSpace(id)).Delete(&ModerationPolicy{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func ListModerationPolicies(scope string) ([]ModerationPolicy, error) {
+	return ListModerationPoliciesWithDB(DB, scope)
+}
+
+func SaveModerationPolicy(policy ModerationPolicy) (ModerationPolicy, error) {
+	row, err := SaveModerationPolicyWithDB(DB, policy)
+	if err != nil {
+		return ModerationPolicy{}, err
+	}
+	if err := SyncModerationPoliciesRuntimeWithDB(DB); err != nil {
+		return ModerationPolicy{}, err
+	}
+	return row, nil
+}
+
+func DeleteModerationPolicy(id string) error {
+	if err := DeleteModerationPolicyWithDB(DB, id); err != nil {
+		return err
+	}
+	return SyncModerationPoliciesRuntimeWithDB(DB)
+}
+
+// RecordUserModerationViolationWithDB counts a flagged or blocked request
+// against the user and disables the account once the count reaches
+// threshold. A threshold of zero or less only counts.
+func RecordUserModerationViolationWithDB(db *gorm.DB, userID string, threshold int) (count int, disabled bool, err error) {
+	if db == nil {
+		return 0, false, fmt.Errorf("database handle is nil")
+	}
+	normalizedUserID := strings.TrimSpace(userID)
+	if normalizedUserID == "" {
+		return 0, false, nil
+	}
+	user := User{}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&User{}).Where("id = ?", normalizedUserID).
+			UpdateColumn("moderation_violations", gorm.Expr("moderation_violations + ?", 1)).Error; err != nil {
+			return err
+		}
+		if err := tx.Select("id", "username", "role", "status", "wallet_address", "moderation_violations").
+			Where("id = ?", normalizedUserID).First(&user).Error; err != nil {
+			return err
+		}
+		if threshold <= 0 || user.ModerationViolations < threshold || user.Status != UserStatusEnabled || IsProtectedRootUser(&user) {
+			return nil
+		}
+		result := tx.Model(&User{}).Where("id = ? AND status = ?", normalizedUserID, UserStatusEnabled).
+			Updates(map[string]any{"status": UserStatusDisabled, "updated_at": helper.GetTimestamp()})
+		if result.Error != nil {
+			return result.Error
+		}
+		disabled = result.RowsAffected > 0
+		return nil
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	return user.ModerationViolations, disabled, nil
+}
+
+func RecordUserModerationViolation(userID string, threshold int) (int, bool, error) {
+	count, disabled, err := RecordUserModerationViolationWithDB(DB, userID, threshold)
+	if err != nil || !disabled {
+		return count, disabled, err
+	}
+	blacklist.BanUser(userID)
+	if common.RedisEnabled {
+		if err := common.RedisDel(fmt.Sprintf("user_enabled:%s", userID)); err != nil {
+			logger.SysError("Redis delete user enabled error: " + err.Error())
+		}
+	}
+	return count, disabled, nil
+}
+
+// ResetUserModerationViolations clears the counter, e.g. when an operator
+// re-enables an automatically disabled user.
+func ResetUserModerationViolations(userID string) error {
+	if DB == nil {
+		return fmt.Errorf("database handle is nil")
+	}
+	return DB.Model(&User{}).Where("id = ?", strings.TrimSpace(userID)).
+		UpdateColumn("moderation_violations", 0).Error
+}
@@ -0,0 +1,82 @@
+package model
+
+import (
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newModerationPolicyTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=private"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&ModerationPolicy{}, &User{}); err != nil {
+		t.Fatalf("migrate moderation policies: %v", err)
+	}
+	return db
+}
+
+func TestSaveModerationPolicyUpsertsByScope(t *testing.T) {
+	db := newModerationPolicyTestDB(t)
+	if _, err := SaveModerationPolicyWithDB(db, ModerationPolicy{Scope: "group", ScopeID: "g1", Enabled: true}); err == nil {
+		t.Fatalf("policy without keywords, patterns or model should be rejected")
+	}
+	if _, err := SaveModerationPolicyWithDB(db, ModerationPolicy{Scope: "group", ScopeID: "g1", Patterns: "(", Enabled: true}); err == nil {
+		t.Fatalf("invalid pattern should be rejected")
+	}
+
+	first, err := SaveModerationPolicyWithDB(db, ModerationPolicy{Scope: " Group ", ScopeID: "g1", Keywords: " a \n\n b ", Enabled: true})
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if first.Action != ModerationActionBlock || first.Keywords != "a\nb" {
+		t.Fatalf("first = %#v, want default block action and trimmed keywords", first)
+	}
+	second, err := SaveModerationPolicyWithDB(db, ModerationPolicy{Scope: "group", ScopeID: "g1", Action: "flag", Model: "omni-moderation-latest"})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	rows, err := ListModerationPoliciesWithDB(db, "group")
+	if err != nil || len(rows) != 1 || second.Id != first.Id || rows[0].Action != "flag" || rows[0].Keywords != "" || rows[0].Enabled {
+		t.Fatalf("rows = %#v, %v; want one replaced and disabled policy", rows, err)
+	}
+}
+
+func TestGetEffectiveModerationPolicyPrefersToken(t *testing.T) {
+	previous := moderationPolicyRuntime
+	t.Cleanup(func() { moderationPolicyRuntime = previous })
+	setModerationPoliciesRuntime([]ModerationPolicy{
+		{Scope: ModerationPolicyScopeGroup, ScopeID: "g1", Enabled: true, Action: "flag"},
+		{Scope: ModerationPolicyScopeToken, ScopeID: "t1", Enabled: true, Action: "block"},
+		{Scope: ModerationPolicyScopeToken, ScopeID: "t2", Enabled: false, Action: "allow"},
+	})
+	for tokenID, want := range map[string]string{"t1": "block", "t2": "flag", "t3": "flag"} {
+		policy, ok := GetEffectiveModerationPolicy(tokenID, "g1")
+		if !ok || policy.Action != want {
+			t.Fatalf("token %s policy = %#v, %t; want %s", tokenID, policy, ok, want)
+		}
+	}
+	if _, ok := GetEffectiveModerationPolicy("t3", "g2"); ok {
+		t.Fatalf("unexpected policy for unconfigured group")
+	}
+}
+
+func TestRecordUserModerationViolationDisablesAtThreshold(t *testing.T) {
+	db := newModerationPolicyTestDB(t)
+	if err := db.Create(&User{Id: "user-1", Username: "u1", Password: "x", AccessToken: "a1", AffCode: "c1", Status: UserStatusEnabled}).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	for attempt := 1; attempt <= 3; attempt++ {
+		count, disabled, err := RecordUserModerationViolationWithDB(db, "user-1", 2)
+		if err != nil || count != attempt || disabled != (attempt == 2) {
+			t.Fatalf("attempt %d = %d, %t, %v", attempt, count, disabled, err)
+		}
+	}
+	user := User{}
+	if err := db.Where("id = ?", "user-1").First(&user).Error; err != nil || user.Status != UserStatusDisabled {
+		t.Fatalf("user = %#v, %v; want disabled", user, err)
+	}
+}
@@ -83,6 +83,24 @@ func InitOptionMap() {
 	if err := SyncBillingCurrencyCatalogWithDB(DB); err != nil {
 		logger.SysError("failed to sync billing currencies from database: " + err.Error())
 	}
+	if err := SyncModerationPoliciesRuntimeWithDB(DB); err != nil {
+		logger.SysError("failed to sync moderation policies from database: " + err.Error())
+	}
+	if err := SyncModelRoutesRuntimeWithDB(DB); err != nil {
+		logger.SysError("failed to sync model routes from database: " + err.Error())
+	}
+	if err := SyncHedgePoliciesRuntimeWithDB(DB); err != nil {
+		logger.SysError("failed to sync hedge policies from database: " + err.Error())
+	}
+	if err := SyncChannelLimitsRuntimeWithDB(DB); err != nil {
+		logger.SysError("failed to sync channel limits from database: " + err.Error())
+	}
+	if err := SyncChannelKeysRuntimeWithDB(DB); err != nil {
+		logger.SysError("failed to sync channel keys from database: " + err.Error())
+	}
+	if err := SyncPackageRateLimitsRuntimeWithDB(DB); err != nil {
+		logger.SysError("failed to sync package rate limits from database: " + err.Error())
+	}
 }
 
 func loadOptionsFromDatabase() {
@@ -106,6 +124,24 @@ func SyncOptions(frequency int) {
 		if err := SyncBillingCurrencyCatalogWithDB(DB); err != nil {
 			logger.SysError("failed to sync billing currencies from database: " + err.Error())
 		}
+		if err := SyncModerationPoliciesRuntimeWithDB(DB); err != nil {
+			logger.SysError("failed to sync moderation policies from database: " + err.Error())
+		}
+		if err := SyncModelRoutesRuntimeWithDB(DB); err != nil {
+			logger.SysError("failed to sync model routes from database: " + err.Error())
+		}
+		if err := SyncHedgePoliciesRuntimeWithDB(DB); err != nil {
+			logger.SysError("failed to sync hedge policies from database: " + err.Error())
+		}
+		if err := SyncChannelLimitsRuntimeWithDB(DB); err != nil {
+			logger.SysError("failed to sync channel limits from database: " + err.Error())
+		}
+		if err := SyncChannelKeysRuntimeWithDB(DB); err != nil {
+			logger.SysError("failed to sync channel keys from database: " + err.Error())
+		}
+		if err := SyncPackageRateLimitsRuntimeWithDB(DB); err != nil {
+			logger.SysError("failed to sync package rate limits from database: " + err.Error())
+		}
 	}
 }
 
@@ -898,33 +898,39 @@ func EstimateChannelProcurementCostWithDB(db *gorm.DB, input ProcurementConsumeI
 	if err := query.Order(procurementBatchConsumeOrderSQL()).Find(&rows).Error; err != nil {
 		return ProcurementEstimateResult{}, err
 	}
-	result := ProcurementEstimateResult{MissingQuantity: input.Quantity}
-	remaining := input.Quantity
+	return estimateProcurementCostFromBatches(rows, input.Quantity, now), nil
+}
+
+// estimateProcurementCostFromBatches prices quantity against active batches
+// that already match the channel, capacity unit and scope, in consume order.
+func estimateProcurementCostFromBatches(rows []ChannelProcurementBatch, quantity float64, now int64) ProcurementEstimateResult {
+	result := ProcurementEstimateResult{MissingQuantity: quantity}
+	remaining := quantity
 	for _, group := range buildProcurementConstraintGroups(rows, now) {
 		if remaining <= 0 {
 			break
 		}
-		quantity := math.Min(remaining, group.Available)
-		if quantity <= 0 {
+		covered := math.Min(remaining, group.Available)
+		if covered <= 0 {
 			continue
 		}
 		row := group.Rows[group.CostRow]
-		result.CoveredQuantity += quantity
-		result.TotalCostAmount += quantity * row.CostPerUnitAmount
+		result.CoveredQuantity += covered
+		result.TotalCostAmount += covered * row.CostPerUnitAmount
 		if result.CostSource == "" {
 			result.CostSource = row.CostSource
 		} else if result.CostSource != row.CostSource {
 			result.CostSource = ProcurementCostSourceEstimated
 		}
-		remaining -= quantity
+		remaining -= covered
 	}
 	if result.CoveredQuantity <= 0 {
 		result.CostSource = ProcurementCostSourceNone
-		result.MissingQuantity = input.Quantity
-		return result, nil
+		result.MissingQuantity = quantity
+		return result
 	}
 	result.MissingQuantity = math.Max(remaining, 0)
-	return result, nil
+	return result
 }
 
 func procurementBatchConsumeOrderSQL() string {
@@ -189,6 +189,8 @@ func DefaultProviderModelSupportedEndpoints(provider string, modelType string, m
 		return []string{ChannelModelEndpointVideos}
 	case ProviderModelTypeEmbedding:
 		return []string{ChannelModelEndpointEmbeddings}
+	case ProviderModelTypeRerank:
+		return []string{ChannelModelEndpointRerank}
 	}
 	switch normalizedProvider {
 	case "anthropic":
@@ -14,12 +14,14 @@ const (
 	ProviderModelTypeAudio     = "audio"
 	ProviderModelTypeVideo     = "video"
 	ProviderModelTypeEmbedding = "embedding"
+	ProviderModelTypeRerank    = "rerank"
 
 	ProviderModelTagText             = ProviderModelTypeText
 	ProviderModelTagImage            = ProviderModelTypeImage
 	ProviderModelTagAudio            = ProviderModelTypeAudio
 	ProviderModelTagVideo            = ProviderModelTypeVideo
 	ProviderModelTagEmbedding        = ProviderModelTypeEmbedding
+	ProviderModelTagRerank           = ProviderModelTypeRerank
 	ProviderModelTagToolCalling      = "tool_calling"
 	ProviderModelTagReasoning        = "reasoning"
 	ProviderModelTagVision           = "vision"
@@ -35,6 +37,9 @@ const (
 	ProviderPriceUnitPerSecond   = "per_second"
 	ProviderPriceUnitPerRequest  = "per_request"
 	ProviderPriceUnitPerTask     = "per_task"
+	// Cohere bills rerank per search unit: one query over up to 100 documents.
+	ProviderPriceUnitPerSearchUnit = "per_search_unit"
+	ProviderPriceUnitPerDocument   = "per_document"
 
 	ProviderPriceCurrencyUSD = "USD"
 
@@ -221,6 +226,7 @@ func NormalizeProviderModelTags(tags []string) []string {
 		ProviderModelTagAudio:            30,
 		ProviderModelTagVideo:            40,
 		ProviderModelTagEmbedding:        50,
+		ProviderModelTagRerank:           55,
 		ProviderModelTagToolCalling:      60,
 		ProviderModelTagReasoning:        70,
 		ProviderModelTagVision:           80,
@@ -272,7 +278,8 @@ func ProviderModelTypeFromTags(tags []string) string {
 			ProviderModelTagImage,
 			ProviderModelTagAudio,
 			ProviderModelTagVideo,
-			ProviderModelTagEmbedding:
+			ProviderModelTagEmbedding,
+			ProviderModelTagRerank:
 			return tag
 		}
 	}
@@ -454,6 +461,8 @@ func IsChannelModelEndpointAllowedForModel(modelType string, modelName string, e
 		return normalizedEndpoint == ChannelModelEndpointVideos
 	case ProviderModelTypeEmbedding:
 		return normalizedEndpoint == ChannelModelEndpointEmbeddings
+	case ProviderModelTypeRerank:
+		return normalizedEndpoint == ChannelModelEndpointRerank
 	default:
 		switch normalizedEndpoint {
 		case ChannelModelEndpointChat, ChannelModelEndpointResponses, ChannelModelEndpointMessages:
@@ -564,7 +573,7 @@ func inferProviderByModel(modelName string, channelProtocol int, hasChannelProto
 func normalizeModelType(raw string, modelName string) string {
 	trimmed := strings.TrimSpace(strings.ToLower(raw))
 	switch trimmed {
-	case ProviderModelTypeText, ProviderModelTypeImage, ProviderModelTypeAudio, ProviderModelTypeVideo, ProviderModelTypeEmbedding:
+	case ProviderModelTypeText, ProviderModelTypeImage, ProviderModelTypeAudio, ProviderModelTypeVideo, ProviderModelTypeEmbedding, ProviderModelTypeRerank:
 		return trimmed
 	}
 	lower := strings.ToLower(strings.TrimSpace(modelName))
@@ -572,6 +581,8 @@ func normalizeModelType(raw string, modelName string) string {
 		return ProviderModelTypeText
 	}
 	switch {
+	case strings.Contains(lower, "rerank"):
+		return ProviderModelTypeRerank
 	case strings.Contains(lower, "embedding"),
 		strings.HasPrefix(lower, "text-embedding"),
 		strings.HasPrefix(lower, "seed1.6-embedding"):
@@ -655,6 +666,11 @@ func defaultPriceUnitByType(modelType string, modelName string) string {
 		return ProviderPriceUnitPerVideo
 	case ProviderModelTypeEmbedding:
 		return ProviderPriceUnitPer1KTokens
+	case ProviderModelTypeRerank:
+		if strings.HasPrefix(strings.ToLower(strings.TrimSpace(modelName)), "rerank-") {
+			return ProviderPriceUnitPerSearchUnit
+		}
+		return ProviderPriceUnitPer1KTokens
 	case ProviderModelTypeAudio:
 		if strings.HasPrefix(strings.ToLower(strings.TrimSpace(modelName)), "tts-") {
 			return ProviderPriceUnitPer1KChars
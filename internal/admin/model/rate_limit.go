@@ -0,0 +1,448 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/yeying-community/router/common"
+	"github.com/yeying-community/router/common/helper"
+	"github.com/yeying-community/router/common/logger"
+	"github.com/yeying-community/router/common/random"
+	"gorm.io/gorm"
+)
+
+const (
+	RateLimitReasonRequests = "requests"
+	RateLimitReasonTokens   = "tokens"
+
+	rateLimitWindow = time.Minute
+)
+
+// RateLimitScope is one caller-side RPM/TPM limit, such as a token or a user.
+// Zero limits are not enforced.
+type RateLimitScope struct {
+	Key string
+	RPM int
+	TPM int
+}
+
+// RateLimitUsage is what is left of the tightest request or token limit.
+type RateLimitUsage struct {
+	Limit     int
+	Remaining int
+	Reset     time.Duration
+}
+
+// RateLimitDecision is the outcome of counting a request against its scopes.
+// Requests and Tokens are nil when no scope limits them.
+type RateLimitDecision struct {
+	Allowed    bool
+	Reason     string
+	RetryAfter time.Duration
+	Requests   *RateLimitUsage
+	Tokens     *RateLimitUsage
+}
+
+func normalizeRateLimitValue(value int) int {
+	if value < 0 {
+		return 0
+	}
+	return value
+}
+
+type groupRateLimit struct {
+	RPM int
+	TPM int
+}
+
+var (
+	groupRateLimitLock sync.RWMutex
+	groupRateLimitMap  = map[string]groupRateLimit{}
+)
+
+func setGroupRateLimitsRuntime(limits map[string]groupRateLimit) {
+	groupRateLimitLock.Lock()
+	groupRateLimitMap = limits
+	groupRateLimitLock.Unlock()
+}
+
+func syncGroupRateLimitsRuntimeWithDB(db *gorm.DB) error {
+	if db == nil {
+		return nil
+	}
+	rows := make([]GroupCatalog, 0)
+	if err := db.Find(&rows).Error; err != nil {
+		return err
+	}
+	limits := make(map[string]groupRateLimit)
+	for _, row := range rows {
+		if row.RPMLimitPerUser <= 0 && row.TPMLimitPerUser <= 0 {
+			continue
+		}
+		for _, ref := range buildGroupReferenceValues(row) {
+			limits[ref] = groupRateLimit{RPM: row.RPMLimitPerUser, TPM: row.TPMLimitPerUser}
+		}
+	}
+	setGroupRateLimitsRuntime(limits)
+	return nil
+}
+
+var (
+	packageRateLimitLock sync.RWMutex
+	// packageRateLimitMap holds the limits of active subscriptions whose
+	// package sets them, by subscription id.
+	packageRateLimitMap = map[string]groupRateLimit{}
+)
+
+func SyncPackageRateLimitsRuntimeWithDB(db *gorm.DB) error {
+	if db == nil {
+		return nil
+	}
+	rows := make([]struct {
+		SubscriptionID  string
+		RPMLimitPerUser int
+		TPMLimitPerUser int
+	}, 0)
+	now := helper.GetTimestamp()
+	if err := db.Table(UserPackageSubscriptionsTableName+" AS s").
+		Select("s.id AS subscription_id", "p.rpm_limit_per_user", "p.tpm_limit_per_user").
+		Joins("JOIN "+ServicePackagesTableName+" p ON p.id = s.package_id").
+		Where("s.status = ? AND (s.expires_at = 0 OR s.expires_at > ?)", UserPackageSubscriptionStatusActive, now).
+		Where("p.rpm_limit_per_user > 0 OR p.tpm_limit_per_user > 0").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+	limits := make(map[string]groupRateLimit, len(rows))
+	for _, row := range rows {
+		limits[row.SubscriptionID] = groupRateLimit{RPM: row.RPMLimitPerUser, TPM: row.TPMLimitPerUser}
+	}
+	packageRateLimitLock.Lock()
+	packageRateLimitMap = limits
+	packageRateLimitLock.Unlock()
+	return nil
+}
+
+// cachePackageRateLimit makes the limits of a new subscription apply on this
+// node before the next sync.
+func cachePackageRateLimit(subscriptionID string, servicePackage ServicePackage) {
+	if servicePackage.RPMLimitPerUser <= 0 && servicePackage.TPMLimitPerUser <= 0 {
+		return
+	}
+	packageRateLimitLock.Lock()
+	packageRateLimitMap[strings.TrimSpace(subscriptionID)] = groupRateLimit{RPM: servicePackage.RPMLimitPerUser, TPM: servicePackage.TPMLimitPerUser}
+	packageRateLimitLock.Unlock()
+}
+
+// ResolveUserRateLimit returns the per-user RPM/TPM limits for a request
+// served from the entitlement source: the package limits when the source is
+// a package subscription that sets them, otherwise the group defaults.
+func ResolveUserRateLimit(groupID string, sourceType string, sourceID string) (int, int) {
+	if sourceType == UserEntitlementSourcePackage {
+		packageRateLimitLock.RLock()
+		limit, ok := packageRateLimitMap[strings.TrimSpace(sourceID)]
+		packageRateLimitLock.RUnlock()
+		if ok {
+			return limit.RPM, limit.TPM
+		}
+	}
+	groupRateLimitLock.RLock()
+	limit := groupRateLimitMap[strings.TrimSpace(groupID)]
+	groupRateLimitLock.RUnlock()
+	return limit.RPM, limit.TPM
+}
+
+// TakeRateLimits counts the request in the sliding one-minute window of every
+// scope, or in none of them when one is exhausted.
+func TakeRateLimits(scopes []RateLimitScope) RateLimitDecision {
+	enforced := make([]RateLimitScope, 0, len(scopes))
+	for _, scope := range scopes {
+		if scope.RPM > 0 || scope.TPM > 0 {
+			enforced = append(enforced, scope)
+		}
+	}
+	if len(enforced) == 0 {
+		return RateLimitDecision{Allowed: true}
+	}
+	return currentRateLimitWindow().take(enforced, time.Now())
+}
+
+// RecordRateLimitTokens counts the tokens a request used towards the TPM
+// windows of its scopes.
+func RecordRateLimitTokens(scopes []RateLimitScope, tokens int) {
+	if tokens <= 0 {
+		return
+	}
+	limited := make([]RateLimitScope, 0, len(scopes))
+	for _, scope := range scopes {
+		if scope.TPM > 0 {
+			limited = append(limited, scope)
+		}
+	}
+	if len(limited) == 0 {
+		return
+	}
+	currentRateLimitWindow().addTokens(limited, int64(tokens), time.Now())
+}
+
+// rateLimitState is the content of one scope's window, excluding the request
+// being counted.
+type rateLimitState struct {
+	requests      []time.Time
+	tokens        []rateLimitTokenEntry
+	oldestRequest time.Time
+	requestCount  int
+}
+
+type rateLimitTokenEntry struct {
+	at     time.Time
+	tokens int64
+}
+
+// reject records an exhausted limit. The request may be retried once every
+// exhausted limit has room again; the first one is reported as the reason.
+func (decision *RateLimitDecision) reject(reason string, retryAfter time.Duration) {
+	if decision.Allowed {
+		decision.Allowed = false
+		decision.Reason = reason
+	}
+	if retryAfter > decision.RetryAfter {
+		decision.RetryAfter = retryAfter
+	}
+}
+
+// decideRateLimits applies the limits to the window contents. Reset is the
+// time until the oldest counted request or token entry leaves the window;
+// RetryAfter is the time until the exhausted limit has room again.
+func decideRateLimits(scopes []RateLimitScope, states []rateLimitState, now time.Time) RateLimitDecision {
+	decision := RateLimitDecision{Allowed: true}
+	for i, scope := range scopes {
+		state := states[i]
+		if scope.RPM > 0 {
+			oldest := now
+			if state.requestCount > 0 {
+				oldest = state.oldestRequest
+			}
+			reset := oldest.Add(rateLimitWindow).Sub(now)
+			remaining := scope.RPM - state.requestCount - 1
+			if state.requestCount >= scope.RPM {
+				remaining = 0
+				decision.reject(RateLimitReasonRequests, reset)
+			}
+			if decision.Requests == nil || remaining < decision.Requests.Remaining {
+				decision.Requests = &RateLimitUsage{Limit: scope.RPM, Remaining: remaining, Reset: reset}
+			}
+		}
+		if scope.TPM > 0 {
+			entries := state.tokens
+			sort.Slice(entries, func(a, b int) bool { return entries[a].at.Before(entries[b].at) })
+			used := int64(0)
+			for _, entry := range entries {
+				used += entry.tokens
+			}
+			reset := time.Duration(0)
+			if len(entries) > 0 {
+				reset = entries[0].at.Add(rateLimitWindow).Sub(now)
+			}
+			remaining := int(int64(scope.TPM) - used)
+			if remaining <= 0 {
+				remaining = 0
+				retryAfter := reset
+				left := used
+				for _, entry := range entries {
+					left -= entry.tokens
+					retryAfter = entry.at.Add(rateLimitWindow).Sub(now)
+					if left < int64(scope.TPM) {
+						break
+					}
+				}
+				decision.reject(RateLimitReasonTokens, retryAfter)
+			}
+			if decision.Tokens == nil || remaining < decision.Tokens.Remaining {
+				decision.Tokens = &RateLimitUsage{Limit: scope.TPM, Remaining: remaining, Reset: reset}
+			}
+		}
+	}
+	return decision
+}
+
+type rateLimitWindowStore interface {
+	take(scopes []RateLimitScope, now time.Time) RateLimitDecision
+	addTokens(scopes []RateLimitScope, tokens int64, now time.Time)
+}
+
+func currentRateLimitWindow() rateLimitWindowStore {
+	if common.RedisEnabled && common.RDB != nil {
+		return redisRateLimits
+	}
+	return memoryRateLimits
+}
+
+var memoryRateLimits = &memoryRateLimitWindow{windows: map[string]*rateLimitState{}}
+
+type memoryRateLimitWindow struct {
+	mu        sync.Mutex
+	windows   map[string]*rateLimitState
+	lastSweep time.Time
+}
+
+func (store *memoryRateLimitWindow) stateLocked(key string, now time.Time) *rateLimitState {
+	cutoff := now.Add(-rateLimitWindow)
+	if now.Sub(store.lastSweep) >= rateLimitWindow {
+		store.lastSweep = now
+		for stateKey, state := range store.windows {
+			pruneRateLimitState(state, cutoff)
+			if len(state.requests) == 0 && len(state.tokens) == 0 {
+				delete(store.windows, stateKey)
+			}
+		}
+	}
+	state, ok := store.windows[key]
+	if !ok {
+		state = &rateLimitState{}
+		store.windows[key] = state
+	}
+	pruneRateLimitState(state, cutoff)
+	return state
+}
+
+func pruneRateLimitState(state *rateLimitState, cutoff time.Time) {
+	requests := 0
+	for requests < len(state.requests) && !state.requests[requests].After(cutoff) {
+		requests++
+	}
+	state.requests = state.requests[requests:]
+	tokens := 0
+	for tokens < len(state.tokens) && !state.tokens[tokens].at.After(cutoff) {
+		tokens++
+	}
+	state.tokens = state.tokens[tokens:]
+	state.requestCount = len(state.requests)
+	if state.requestCount > 0 {
+		state.oldestRequest = state.requests[0]
+	}
+}
+
+func (store *memoryRateLimitWindow) take(scopes []RateLimitScope, now time.Time) RateLimitDecision {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	states := make([]rateLimitState, len(scopes))
+	for i, scope := range scopes {
+		state := store.stateLocked(scope.Key, now)
+		states[i] = rateLimitState{
+			requestCount:  state.requestCount,
+			oldestRequest: state.oldestRequest,
+			tokens:        append([]rateLimitTokenEntry(nil), state.tokens...),
+		}
+	}
+	decision := decideRateLimits(scopes, states, now)
+	if decision.Allowed {
+		for _, scope := range scopes {
+			if scope.RPM > 0 {
+				state := store.windows[scope.Key]
+				state.requests = append(state.requests, now)
+			}
+		}
+	}
+	return decision
+}
+
+func (store *memoryRateLimitWindow) addTokens(scopes []RateLimitScope, tokens int64, now time.Time) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	for _, scope := range scopes {
+		state := store.stateLocked(scope.Key, now)
+		state.tokens = append(state.tokens, rateLimitTokenEntry{at: now, tokens: tokens})
+	}
+}
+
+var redisRateLimits = &redisRateLimitWindow{}
+
+// redisRateLimitWindow keeps each window in a sorted set scored by time in
+// milliseconds. A request is added first and removed again when it overshoots
+// the limit; Redis failures let the request through.
+type redisRateLimitWindow struct{}
+
+func redisRateLimitKey(kind string, key string) string {
+	return fmt.Sprintf("rate_limit:%s:%s", kind, key)
+}
+
+func (redisRateLimitWindow) take(scopes []RateLimitScope, now time.Time) RateLimitDecision {
+	ctx := context.Background()
+	nowMs := now.UnixMilli()
+	cutoff := strconv.FormatInt(now.Add(-rateLimitWindow).UnixMilli(), 10)
+	member := random.GetUUID()
+	type pending struct {
+		count  *redis.IntCmd
+		oldest *redis.ZSliceCmd
+		tokens *redis.ZSliceCmd
+	}
+	pipe := common.RDB.TxPipeline()
+	cmds := make([]pending, len(scopes))
+	for i, scope := range scopes {
+		if scope.RPM > 0 {
+			key := redisRateLimitKey(RateLimitReasonRequests, scope.Key)
+			pipe.ZRemRangeByScore(ctx, key, "-inf", cutoff)
+			pipe.ZAdd(ctx, key, &redis.Z{Score: float64(nowMs), Member: member})
+			cmds[i].count = pipe.ZCard(ctx, key)
+			cmds[i].oldest = pipe.ZRangeWithScores(ctx, key, 0, 0)
+			pipe.Expire(ctx, key, rateLimitWindow+time.Minute)
+		}
+		if scope.TPM > 0 {
+			key := redisRateLimitKey(RateLimitReasonTokens, scope.Key)
+			pipe.ZRemRangeByScore(ctx, key, "-inf", cutoff)
+			cmds[i].tokens = pipe.ZRangeWithScores(ctx, key, 0, -1)
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		logger.SysError("check rate limit window failed: " + err.Error())
+		return RateLimitDecision{Allowed: true}
+	}
+	states := make([]rateLimitState, len(scopes))
+	for i := range scopes {
+		if cmds[i].count != nil {
+			// The count includes the request being checked.
+			states[i].requestCount = int(cmds[i].count.Val()) - 1
+			if oldest := cmds[i].oldest.Val(); len(oldest) > 0 {
+				states[i].oldestRequest = time.UnixMilli(int64(oldest[0].Score))
+			}
+		}
+		if cmds[i].tokens != nil {
+			for _, entry := range cmds[i].tokens.Val() {
+				value, _ := entry.Member.(string)
+				tokens, err := strconv.ParseInt(value[strings.LastIndex(value, ":")+1:], 10, 64)
+				if err != nil {
+					continue
+				}
+				states[i].tokens = append(states[i].tokens, rateLimitTokenEntry{at: time.UnixMilli(int64(entry.Score)), tokens: tokens})
+			}
+		}
+	}
+	decision := decideRateLimits(scopes, states, now)
+	if !decision.Allowed {
+		for _, scope := range scopes {
+			if scope.RPM > 0 {
+				common.RDB.ZRem(ctx, redisRateLimitKey(RateLimitReasonRequests, scope.Key), member)
+			}
+		}
+	}
+	return decision
+}
+
+func (redisRateLimitWindow) addTokens(scopes []RateLimitScope, tokens int64, now time.Time) {
+	ctx := context.Background()
+	member := random.GetUUID() + ":" + strconv.FormatInt(tokens, 10)
+	for _, scope := range scopes {
+		key := redisRateLimitKey(RateLimitReasonTokens, scope.Key)
+		if err := common.RDB.ZAdd(ctx, key, &redis.Z{Score: float64(now.UnixMilli()), Member: member}).Err(); err != nil {
+			logger.SysError("record rate limit tokens failed: " + err.Error())
+			continue
+		}
+		common.RDB.Expire(ctx, key, rateLimitWindow+time.Minute)
+	}
+}
@@ -0,0 +1,127 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yeying-community/router/common"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func useMemoryRateLimitsForTest(t *testing.T) {
+	t.Helper()
+	previousRedisEnabled := common.RedisEnabled
+	previousStore := memoryRateLimits
+	common.RedisEnabled = false
+	memoryRateLimits = &memoryRateLimitWindow{windows: map[string]*rateLimitState{}}
+	t.Cleanup(func() {
+		common.RedisEnabled = previousRedisEnabled
+		memoryRateLimits = previousStore
+	})
+}
+
+func TestMemoryRateLimitWindowSlidesRequests(t *testing.T) {
+	useMemoryRateLimitsForTest(t)
+	scopes := []RateLimitScope{{Key: "token:1", RPM: 2}}
+	start := time.Unix(1_700_000_000, 0)
+
+	first := memoryRateLimits.take(scopes, start)
+	if !first.Allowed || first.Requests == nil || first.Requests.Remaining != 1 || first.Requests.Reset != time.Minute {
+		t.Fatalf("first = %+v (%+v), want allowed with 1 remaining", first, first.Requests)
+	}
+	if second := memoryRateLimits.take(scopes, start.Add(20*time.Second)); !second.Allowed || second.Requests.Remaining != 0 {
+		t.Fatalf("second = %+v, want allowed with 0 remaining", second)
+	}
+	third := memoryRateLimits.take(scopes, start.Add(30*time.Second))
+	if third.Allowed || third.Reason != RateLimitReasonRequests || third.RetryAfter != 30*time.Second {
+		t.Fatalf("third = %+v, want rejected until the first request leaves the window", third)
+	}
+	if fourth := memoryRateLimits.take(scopes, start.Add(61*time.Second)); !fourth.Allowed || fourth.Requests.Remaining != 0 {
+		t.Fatalf("fourth = %+v, want allowed once the first request slid out", fourth)
+	}
+}
+
+func TestMemoryRateLimitWindowLimitsTokensAndReportsTightestScope(t *testing.T) {
+	useMemoryRateLimitsForTest(t)
+	token := RateLimitScope{Key: "token:1", RPM: 100, TPM: 1000}
+	user := RateLimitScope{Key: "user:1", RPM: 10}
+	start := time.Unix(1_700_000_000, 0)
+
+	memoryRateLimits.addTokens([]RateLimitScope{token}, 600, start)
+	memoryRateLimits.addTokens([]RateLimitScope{token}, 500, start.Add(10*time.Second))
+	decision := memoryRateLimits.take([]RateLimitScope{token, user}, start.Add(20*time.Second))
+	if decision.Allowed || decision.Reason != RateLimitReasonTokens {
+		t.Fatalf("decision = %+v, want rejected by TPM", decision)
+	}
+	// Dropping the 600-token entry brings usage back under the limit.
+	if decision.RetryAfter != 40*time.Second {
+		t.Fatalf("retry after = %s, want 40s", decision.RetryAfter)
+	}
+	if decision.Requests.Limit != 10 || decision.Requests.Remaining != 9 {
+		t.Fatalf("requests = %+v, want the user limit", decision.Requests)
+	}
+	if decision.Tokens.Limit != 1000 || decision.Tokens.Remaining != 0 {
+		t.Fatalf("tokens = %+v", decision.Tokens)
+	}
+	// The rejected request is not counted.
+	if allowed := memoryRateLimits.take([]RateLimitScope{user}, start.Add(21*time.Second)); allowed.Requests.Remaining != 9 {
+		t.Fatalf("user requests = %+v, want rejected request uncounted", allowed.Requests)
+	}
+	if allowed := memoryRateLimits.take([]RateLimitScope{token}, start.Add(61*time.Second)); !allowed.Allowed || allowed.Tokens.Remaining != 500 {
+		t.Fatalf("after slide = %+v (%+v), want 500 tokens left", allowed, allowed.Tokens)
+	}
+}
+
+func TestResolveUserRateLimitPrefersPackageOverGroupDefault(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=private"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&GroupCatalog{}, &ServicePackage{}, &UserPackageSubscription{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		setGroupRateLimitsRuntime(map[string]groupRateLimit{})
+		packageRateLimitLock.Lock()
+		packageRateLimitMap = map[string]groupRateLimit{}
+		packageRateLimitLock.Unlock()
+	})
+	if err := db.Create(&GroupCatalog{Id: "group-1", Name: "default", RPMLimitPerUser: 60, TPMLimitPerUser: 10000}).Error; err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	if err := db.Create(&ServicePackage{Id: "package-1", Name: "pro", GroupID: "group-1", RPMLimitPerUser: 600}).Error; err != nil {
+		t.Fatalf("create package: %v", err)
+	}
+	if err := db.Create(&ServicePackage{Id: "package-2", Name: "basic", GroupID: "group-1"}).Error; err != nil {
+		t.Fatalf("create package: %v", err)
+	}
+	for _, sub := range []UserPackageSubscription{
+		{Id: "sub-1", UserID: "user-1", PackageID: "package-1", GroupID: "group-1"},
+		{Id: "sub-2", UserID: "user-1", PackageID: "package-2", GroupID: "group-1"},
+		{Id: "sub-3", UserID: "user-1", PackageID: "package-1", GroupID: "group-1", ExpiresAt: 1},
+	} {
+		if err := db.Create(&sub).Error; err != nil {
+			t.Fatalf("create subscription: %v", err)
+		}
+	}
+	if err := syncGroupRateLimitsRuntimeWithDB(db); err != nil {
+		t.Fatalf("sync group limits: %v", err)
+	}
+	if err := SyncPackageRateLimitsRuntimeWithDB(db); err != nil {
+		t.Fatalf("sync package limits: %v", err)
+	}
+
+	if rpm, tpm := ResolveUserRateLimit("group-1", UserEntitlementSourcePackage, "sub-1"); rpm != 600 || tpm != 0 {
+		t.Fatalf("package limit = (%d, %d), want (600, 0)", rpm, tpm)
+	}
+	if rpm, tpm := ResolveUserRateLimit("group-1", UserEntitlementSourcePackage, "sub-2"); rpm != 60 || tpm != 10000 {
+		t.Fatalf("package without limits = (%d, %d), want group default", rpm, tpm)
+	}
+	if rpm, tpm := ResolveUserRateLimit("group-1", UserEntitlementSourcePackage, "sub-3"); rpm != 60 || tpm != 10000 {
+		t.Fatalf("expired subscription = (%d, %d), want group default", rpm, tpm)
+	}
+	if rpm, tpm := ResolveUserRateLimit("default", UserEntitlementSourceTopup, ""); rpm != 60 || tpm != 10000 {
+		t.Fatalf("group name lookup = (%d, %d), want group default", rpm, tpm)
+	}
+}
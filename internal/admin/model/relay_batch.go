@@ -0,0 +1,294 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yeying-community/router/common/helper"
+	"gorm.io/gorm"
+)
+
+const (
+	RelayBatchesTableName    = "relay_batches"
+	RelayBatchLinesTableName = "relay_batch_lines"
+
+	RelayBatchStatusValidating = "validating"
+	RelayBatchStatusInProgress = "in_progress"
+	RelayBatchStatusFinalizing = "finalizing"
+	RelayBatchStatusCompleted  = "completed"
+	RelayBatchStatusFailed     = "failed"
+	RelayBatchStatusExpired    = "expired"
+	RelayBatchStatusCancelling = "cancelling"
+	RelayBatchStatusCancelled  = "cancelled"
+)
+
+// RelayBatch is an OpenAI-compatible batch executed by the gateway itself.
+// Each status transition stamps the matching *_at column.
+type RelayBatch struct {
+	Id               string `json:"id" gorm:"primaryKey;type:varchar(64)"`
+	UserID           string `json:"user_id" gorm:"type:char(36);index"`
+	TokenID          string `json:"token_id" gorm:"type:char(36);default:''"`
+	ClientIP         string `json:"client_ip" gorm:"type:varchar(64);default:''"`
+	Endpoint         string `json:"endpoint" gorm:"type:varchar(64);default:''"`
+	InputFileID      string `json:"input_file_id" gorm:"type:varchar(64);default:''"`
+	CompletionWindow string `json:"completion_window" gorm:"type:varchar(16);default:''"`
+	Status           string `json:"status" gorm:"type:varchar(32);index"`
+	OutputFileID     string `json:"output_file_id" gorm:"type:varchar(64);default:''"`
+	ErrorFileID      string `json:"error_file_id" gorm:"type:varchar(64);default:''"`
+	TotalCount       int    `json:"total_count" gorm:"default:0"`
+	CompletedCount   int    `json:"completed_count" gorm:"default:0"`
+	FailedCount      int    `json:"failed_count" gorm:"default:0"`
+	Metadata         string `json:"metadata" gorm:"type:text"`
+	Errors           string `json:"errors" gorm:"type:text"`
+	TaskID           string `json:"task_id" gorm:"type:char(36);default:''"`
+	CreatedAt        int64  `json:"created_at" gorm:"bigint;index"`
+	InProgressAt     int64  `json:"in_progress_at" gorm:"bigint;default:0"`
+	FinalizingAt     int64  `json:"finalizing_at" gorm:"bigint;default:0"`
+	CompletedAt      int64  `json:"completed_at" gorm:"bigint;default:0"`
+	FailedAt         int64  `json:"failed_at" gorm:"bigint;default:0"`
+	ExpiredAt        int64  `json:"expired_at" gorm:"bigint;default:0"`
+	CancellingAt     int64  `json:"cancelling_at" gorm:"bigint;default:0"`
+	CancelledAt      int64  `json:"cancelled_at" gorm:"bigint;default:0"`
+	ExpiresAt        int64  `json:"expires_at" gorm:"bigint;default:0"`
+}
+
+func (RelayBatch) TableName() string {
+	return RelayBatchesTableName
+}
+
+// RelayBatchLine is the output of one finished line of a batch interrupted by
+// a shutdown, so the resumed run does not send, and bill, it again.
+type RelayBatchLine struct {
+	BatchID   string `json:"batch_id" gorm:"primaryKey;type:varchar(64)"`
+	LineIndex int    `json:"line_index" gorm:"primaryKey;autoIncrement:false"`
+	Result    string `json:"result" gorm:"type:text"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint"`
+}
+
+func (RelayBatchLine) TableName() string {
+	return RelayBatchLinesTableName
+}
+
+type RelayBatchListQuery struct {
+	UserID string
+	After  string
+	Limit  int
+}
+
+var relayBatchStatusTimeColumns = map[string]string{
+	RelayBatchStatusInProgress: "in_progress_at",
+	RelayBatchStatusFinalizing: "finalizing_at",
+	RelayBatchStatusCompleted:  "completed_at",
+	RelayBatchStatusFailed:     "failed_at",
+	RelayBatchStatusExpired:    "expired_at",
+	RelayBatchStatusCancelling: "cancelling_at",
+	RelayBatchStatusCancelled:  "cancelled_at",
+}
+
+func IsRelayBatchTerminalStatus(status string) bool {
+	switch strings.TrimSpace(status) {
+	case RelayBatchStatusCompleted, RelayBatchStatusFailed, RelayBatchStatusExpired, RelayBatchStatusCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+func normalizeRelayBatchRow(row *RelayBatch) {
+	if row == nil {
+		return
+	}
+	row.Id = strings.TrimSpace(row.Id)
+	row.UserID = strings.TrimSpace(row.UserID)
+	row.TokenID = strings.TrimSpace(row.TokenID)
+	row.Endpoint = strings.TrimSpace(row.Endpoint)
+	row.InputFileID = strings.TrimSpace(row.InputFileID)
+	row.Status = strings.TrimSpace(strings.ToLower(row.Status))
+	row.OutputFileID = strings.TrimSpace(row.OutputFileID)
+	row.ErrorFileID = strings.TrimSpace(row.ErrorFileID)
+	row.TaskID = strings.TrimSpace(row.TaskID)
+}
+
+func CreateRelayBatchWithDB(db *gorm.DB, row RelayBatch) error {
+	if db == nil {
+		return fmt.Errorf("database handle is nil")
+	}
+	normalizeRelayBatchRow(&row)
+	if row.Id == "" {
+		return fmt.Errorf("batch id cannot be empty")
+	}
+	if row.UserID == "" {
+		return fmt.Errorf("batch user id cannot be empty")
+	}
+	if row.Status == "" {
+		row.Status = RelayBatchStatusValidating
+	}
+	if row.CreatedAt == 0 {
+		row.CreatedAt = helper.GetTimestamp()
+	}
+	return db.Create(&row).Error
+}
+
+// GetRelayBatchWithDB returns the batch owned by userID; an empty userID is
+// only used by the runner and matches any owner.
+func GetRelayBatchWithDB(db *gorm.DB, batchID string, userID string) (RelayBatch, error) {
+	if db == nil {
+		return RelayBatch{}, fmt.Errorf("database handle is nil")
+	}
+	normalizedBatchID := strings.TrimSpace(batchID)
+	if normalizedBatchID == "" {
+		return RelayBatch{}, gorm.ErrRecordNotFound
+	}
+	tx := db.Where("id = ?", normalizedBatchID)
+	if normalizedUserID := strings.TrimSpace(userID); normalizedUserID != "" {
+		tx = tx.Where("user_id = ?", normalizedUserID)
+	}
+	row := RelayBatch{}
+	if err := tx.First(&row).Error; err != nil {
+		return RelayBatch{}, err
+	}
+	normalizeRelayBatchRow(&row)
+	return row, nil
+}
+
+// ListRelayBatchesWithDB pages through a user's batches newest first and
+// fetches one extra row so callers can report has_more.
+func ListRelayBatchesWithDB(db *gorm.DB, query RelayBatchListQuery) ([]RelayBatch, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database handle is nil")
+	}
+	userID := strings.TrimSpace(query.UserID)
+	tx := db.Model(&RelayBatch{}).Where("user_id = ?", userID)
+	if after := strings.TrimSpace(query.After); after != "" {
+		cursor := RelayBatch{}
+		if err := db.Select("id", "created_at").Where("id = ? AND user_id = ?", after, userID).First(&cursor).Error; err != nil {
+			return nil, err
+		}
+		tx = tx.Where("created_at < ? OR (created_at = ? AND id < ?)", cursor.CreatedAt, cursor.CreatedAt, cursor.Id)
+	}
+	limit := query.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	rows := make([]RelayBatch, 0, limit+1)
+	if err := tx.Order("created_at DESC").Order("id DESC").Limit(limit + 1).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		normalizeRelayBatchRow(&rows[i])
+	}
+	return rows, nil
+}
+
+// TransitionRelayBatchWithDB moves a batch to status when it is currently in
+// one of fromStatuses, stamping the status time and applying extra updates.
+// It reports whether the transition happened.
+func TransitionRelayBatchWithDB(db *gorm.DB, batchID string, fromStatuses []string, status string, updates map[string]any) (bool, error) {
+	if db == nil {
+		return false, fmt.Errorf("database handle is nil")
+	}
+	values := make(map[string]any, len(updates)+2)
+	for key, value := range updates {
+		values[key] = value
+	}
+	values["status"] = status
+	if column, ok := relayBatchStatusTimeColumns[status]; ok {
+		values[column] = helper.GetTimestamp()
+	}
+	result := db.Model(&RelayBatch{}).
+		Where("id = ? AND status IN ?", strings.TrimSpace(batchID), fromStatuses).
+		Updates(values)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func UpdateRelayBatchCountsWithDB(db *gorm.DB, batchID string, completed int, failed int) error {
+	if db == nil {
+		return fmt.Errorf("database handle is nil")
+	}
+	return db.Model(&RelayBatch{}).
+		Where("id = ?", strings.TrimSpace(batchID)).
+		Updates(map[string]any{
+			"completed_count": completed,
+			"failed_count":    failed,
+		}).Error
+}
+
+// FailInterruptedRelayBatchesWithDB closes batches whose runner went away,
+// i.e. active batches without a pending task to pick them up again.
+func FailInterruptedRelayBatchesWithDB(db *gorm.DB, errorsJSON string) (int64, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database handle is nil")
+	}
+	pendingTasks := db.Model(&AsyncTask{}).
+		Select("id").
+		Where("type = ? AND status = ?", AsyncTaskTypeRelayBatch, AsyncTaskStatusPending)
+	now := helper.GetTimestamp()
+	cancelled := db.Model(&RelayBatch{}).
+		Where("status = ? AND task_id NOT IN (?)", RelayBatchStatusCancelling, pendingTasks).
+		Updates(map[string]any{
+			"status":       RelayBatchStatusCancelled,
+			"cancelled_at": now,
+		})
+	if cancelled.Error != nil {
+		return 0, cancelled.Error
+	}
+	failed := db.Model(&RelayBatch{}).
+		Where("status IN ? AND task_id NOT IN (?)", []string{RelayBatchStatusValidating, RelayBatchStatusInProgress, RelayBatchStatusFinalizing}, pendingTasks).
+		Updates(map[string]any{
+			"status":    RelayBatchStatusFailed,
+			"failed_at": now,
+			"errors":    errorsJSON,
+		})
+	if failed.Error != nil {
+		return 0, failed.Error
+	}
+	return cancelled.RowsAffected + failed.RowsAffected, nil
+}
+
+// SaveRelayBatchLinesWithDB replaces the saved line outputs of the batch with
+// results, keyed by line index.
+func SaveRelayBatchLinesWithDB(db *gorm.DB, batchID string, results map[int]string) error {
+	if db == nil {
+		return fmt.Errorf("database handle is nil")
+	}
+	normalizedBatchID := strings.TrimSpace(batchID)
+	now := helper.GetTimestamp()
+	rows := make([]RelayBatchLine, 0, len(results))
+	for index, result := range results {
+		rows = append(rows, RelayBatchLine{BatchID: normalizedBatchID, LineIndex: index, Result: result, CreatedAt: now})
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("batch_id = ?", normalizedBatchID).Delete(&RelayBatchLine{}).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(rows, 500).Error
+	})
+}
+
+func ListRelayBatchLinesWithDB(db *gorm.DB, batchID string) (map[int]string, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database handle is nil")
+	}
+	rows := make([]RelayBatchLine, 0)
+	if err := db.Where("batch_id = ?", strings.TrimSpace(batchID)).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	results := make(map[int]string, len(rows))
+	for _, row := range rows {
+		results[row.LineIndex] = row.Result
+	}
+	return results, nil
+}
+
+func DeleteRelayBatchLinesWithDB(db *gorm.DB, batchID string) error {
+	if db == nil {
+		return fmt.Errorf("database handle is nil")
+	}
+	return db.Where("batch_id = ?", strings.TrimSpace(batchID)).Delete(&RelayBatchLine{}).Error
+}
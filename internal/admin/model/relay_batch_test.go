@@ -0,0 +1,93 @@
+package model
+
+import (
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newRelayBatchTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=private"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&RelayBatch{}, &AsyncTask{}); err != nil {
+		t.Fatalf("migrate relay batches: %v", err)
+	}
+	return db
+}
+
+func TestClaimNextPendingAsyncTaskSeparatesBatches(t *testing.T) {
+	db := newRelayBatchTestDB(t)
+	for _, task := range []AsyncTask{
+		{Type: AsyncTaskTypeRelayBatch, DedupeKey: "relay_batch:a", CreatedAt: 100},
+		{Type: AsyncTaskTypeChannelRefreshModels, DedupeKey: "channel_refresh_models:a", CreatedAt: 200},
+	} {
+		if _, _, err := CreateOrReuseAsyncTaskWithDB(db, task); err != nil {
+			t.Fatalf("create task: %v", err)
+		}
+	}
+
+	claimed, err := ClaimNextPendingAsyncTaskWithDB(db)
+	if err != nil || claimed == nil || claimed.Type != AsyncTaskTypeChannelRefreshModels {
+		t.Fatalf("ClaimNextPendingAsyncTaskWithDB = %#v, %v; want the channel task", claimed, err)
+	}
+	if claimed, err := ClaimNextPendingAsyncTaskWithDB(db); err != nil || claimed != nil {
+		t.Fatalf("second claim = %#v, %v; want batch tasks skipped", claimed, err)
+	}
+	claimed, err = ClaimNextPendingAsyncTaskByTypeWithDB(db, AsyncTaskTypeRelayBatch)
+	if err != nil || claimed == nil || claimed.DedupeKey != "relay_batch:a" || claimed.Status != AsyncTaskStatusRunning {
+		t.Fatalf("ClaimNextPendingAsyncTaskByTypeWithDB = %#v, %v; want the running batch task", claimed, err)
+	}
+}
+
+func TestRelayBatchTransitionsAndRecovery(t *testing.T) {
+	db := newRelayBatchTestDB(t)
+	pending, _, err := CreateOrReuseAsyncTaskWithDB(db, AsyncTask{Type: AsyncTaskTypeRelayBatch, DedupeKey: "relay_batch:queued"})
+	if err != nil {
+		t.Fatalf("create task: %v", err)
+	}
+	for _, row := range []RelayBatch{
+		{Id: "batch_queued", UserID: "user-1", Status: RelayBatchStatusValidating, TaskID: pending.Id, CreatedAt: 100},
+		{Id: "batch_running", UserID: "user-1", Status: RelayBatchStatusValidating, TaskID: "lost-task", CreatedAt: 200},
+		{Id: "batch_cancelling", UserID: "user-1", Status: RelayBatchStatusCancelling, TaskID: "lost-task", CreatedAt: 300},
+	} {
+		if err := CreateRelayBatchWithDB(db, row); err != nil {
+			t.Fatalf("create %s: %v", row.Id, err)
+		}
+	}
+
+	moved, err := TransitionRelayBatchWithDB(db, "batch_running", []string{RelayBatchStatusValidating}, RelayBatchStatusInProgress, map[string]any{"total_count": 5})
+	if err != nil || !moved {
+		t.Fatalf("transition = %t, %v; want moved", moved, err)
+	}
+	if moved, err := TransitionRelayBatchWithDB(db, "batch_running", []string{RelayBatchStatusValidating}, RelayBatchStatusInProgress, nil); err != nil || moved {
+		t.Fatalf("repeated transition = %t, %v; want not moved", moved, err)
+	}
+	row, err := GetRelayBatchWithDB(db, "batch_running", "user-1")
+	if err != nil || row.InProgressAt == 0 || row.TotalCount != 5 {
+		t.Fatalf("row = %#v, %v; want in_progress_at and total_count set", row, err)
+	}
+
+	closed, err := FailInterruptedRelayBatchesWithDB(db, `[{"code":"batch_interrupted"}]`)
+	if err != nil || closed != 2 {
+		t.Fatalf("FailInterruptedRelayBatchesWithDB = %d, %v; want 2", closed, err)
+	}
+	for id, want := range map[string]string{
+		"batch_queued":     RelayBatchStatusValidating,
+		"batch_running":    RelayBatchStatusFailed,
+		"batch_cancelling": RelayBatchStatusCancelled,
+	} {
+		row, err := GetRelayBatchWithDB(db, id, "")
+		if err != nil || row.Status != want {
+			t.Fatalf("%s = %#v, %v; want status %s", id, row, err, want)
+		}
+	}
+
+	rows, err := ListRelayBatchesWithDB(db, RelayBatchListQuery{UserID: "user-1", After: "batch_cancelling", Limit: 1})
+	if err != nil || len(rows) != 2 || rows[0].Id != "batch_running" {
+		t.Fatalf("list = %#v, %v; want batches after the cursor newest first", rows, err)
+	}
+}
@@ -0,0 +1,282 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yeying-community/router/common/helper"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	RelayFilesTableName         = "relay_files"
+	RelayFileUpstreamsTableName = "relay_file_upstreams"
+)
+
+// RelayFile is a file uploaded through the gateway Files API. The content
+// lives in the configured storage backend under StorageKey.
+type RelayFile struct {
+	Id             string `json:"id" gorm:"primaryKey;type:varchar(64)"`
+	UserID         string `json:"user_id" gorm:"type:char(36);index"`
+	Filename       string `json:"filename" gorm:"type:varchar(255);default:''"`
+	Purpose        string `json:"purpose" gorm:"type:varchar(64);default:'';index"`
+	Bytes          int64  `json:"bytes" gorm:"bigint;default:0"`
+	ContentType    string `json:"content_type" gorm:"type:varchar(255);default:''"`
+	StorageBackend string `json:"storage_backend" gorm:"type:varchar(32);default:''"`
+	StorageKey     string `json:"storage_key" gorm:"type:varchar(512);default:''"`
+	Status         string `json:"status" gorm:"type:varchar(32);default:''"`
+	CreatedAt      int64  `json:"created_at" gorm:"bigint;index"`
+	ExpiresAt      int64  `json:"expires_at" gorm:"bigint;index"`
+}
+
+func (RelayFile) TableName() string {
+	return RelayFilesTableName
+}
+
+// RelayFileUpstream caches the id a provider assigned to a gateway file after
+// it was uploaded through a specific channel.
+type RelayFileUpstream struct {
+	FileID         string `json:"file_id" gorm:"primaryKey;type:varchar(64)"`
+	ChannelID      string `json:"channel_id" gorm:"primaryKey;type:char(36)"`
+	UpstreamFileID string `json:"upstream_file_id" gorm:"type:varchar(255);default:''"`
+	CreatedAt      int64  `json:"created_at" gorm:"bigint"`
+}
+
+func (RelayFileUpstream) TableName() string {
+	return RelayFileUpstreamsTableName
+}
+
+type RelayFileListQuery struct {
+	UserID  string
+	Purpose string
+	After   string
+	Limit   int
+	Desc    bool
+}
+
+func normalizeRelayFileRow(row *RelayFile) {
+	if row == nil {
+		return
+	}
+	row.Id = strings.TrimSpace(row.Id)
+	row.UserID = strings.TrimSpace(row.UserID)
+	row.Filename = strings.TrimSpace(row.Filename)
+	row.Purpose = strings.TrimSpace(row.Purpose)
+	row.ContentType = strings.TrimSpace(row.ContentType)
+	row.StorageBackend = strings.TrimSpace(row.StorageBackend)
+	row.StorageKey = strings.TrimSpace(row.StorageKey)
+	row.Status = strings.TrimSpace(strings.ToLower(row.Status))
+}
+
+func CreateRelayFileWithDB(db *gorm.DB, row RelayFile) error {
+	if db == nil {
+		return fmt.Errorf("database handle is nil")
+	}
+	normalizeRelayFileRow(&row)
+	if row.Id == "" {
+		return fmt.Errorf("file id cannot be empty")
+	}
+	if row.UserID == "" {
+		return fmt.Errorf("file user id cannot be empty")
+	}
+	if row.CreatedAt == 0 {
+		row.CreatedAt = helper.GetTimestamp()
+	}
+	return db.Create(&row).Error
+}
+
+// CreateRelayFileWithinQuotaWithDB records the file unless it takes the bytes
+// stored by its owner over quotaBytes. The user row stays locked from the sum
+// to the insert, so concurrent uploads of one user are checked in turn.
+func CreateRelayFileWithinQuotaWithDB(db *gorm.DB, row RelayFile, quotaBytes int64) (bool, error) {
+	if db == nil {
+		return false, fmt.Errorf("database handle is nil")
+	}
+	if quotaBytes <= 0 {
+		return true, CreateRelayFileWithDB(db, row)
+	}
+	created := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").
+			Where("id = ?", strings.TrimSpace(row.UserID)).
+			Take(&User{}).Error; err != nil {
+			return err
+		}
+		usedBytes, err := SumRelayFileBytesWithDB(tx, row.UserID)
+		if err != nil {
+			return err
+		}
+		if usedBytes+row.Bytes > quotaBytes {
+			return nil
+		}
+		created = true
+		return CreateRelayFileWithDB(tx, row)
+	})
+	if err != nil {
+		return false, err
+	}
+	return created, nil
+}
+
+// GetRelayFileWithDB returns a live file owned by userID.
+func GetRelayFileWithDB(db *gorm.DB, fileID string, userID string) (RelayFile, error) {
+	if db == nil {
+		return RelayFile{}, fmt.Errorf("database handle is nil")
+	}
+	normalizedFileID := strings.TrimSpace(fileID)
+	normalizedUserID := strings.TrimSpace(userID)
+	if normalizedFileID == "" || normalizedUserID == "" {
+		return RelayFile{}, gorm.ErrRecordNotFound
+	}
+	row := RelayFile{}
+	if err := db.Where("id = ? AND user_id = ?", normalizedFileID, normalizedUserID).First(&row).Error; err != nil {
+		return RelayFile{}, err
+	}
+	if row.ExpiresAt > 0 && row.ExpiresAt < helper.GetTimestamp() {
+		return RelayFile{}, gorm.ErrRecordNotFound
+	}
+	normalizeRelayFileRow(&row)
+	return row, nil
+}
+
+// ListRelayFilesWithDB pages through a user's files ordered by creation time.
+// It fetches one extra row so callers can report has_more.
+func ListRelayFilesWithDB(db *gorm.DB, query RelayFileListQuery) ([]RelayFile, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database handle is nil")
+	}
+	tx := db.Model(&RelayFile{}).
+		Where("user_id = ?", strings.TrimSpace(query.UserID)).
+		Where("expires_at = 0 OR expires_at >= ?", helper.GetTimestamp())
+	if purpose := strings.TrimSpace(query.Purpose); purpose != "" {
+		tx = tx.Where("purpose = ?", purpose)
+	}
+	direction := "ASC"
+	comparator := ">"
+	if query.Desc {
+		direction = "DESC"
+		comparator = "<"
+	}
+	if after := strings.TrimSpace(query.After); after != "" {
+		cursor := RelayFile{}
+		err := db.Select("id", "created_at").
+			Where("id = ? AND user_id = ?", after, strings.TrimSpace(query.UserID)).
+			First(&cursor).Error
+		if err != nil {
+			return nil, err
+		}
+		tx = tx.Where(
+			fmt.Sprintf("created_at %s ? OR (created_at = ? AND id %s ?)", comparator, comparator),
+			cursor.CreatedAt, cursor.CreatedAt, cursor.Id,
+		)
+	}
+	limit := query.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	rows := make([]RelayFile, 0, limit+1)
+	err := tx.Order("created_at " + direction).
+		Order("id " + direction).
+		Limit(limit + 1).
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		normalizeRelayFileRow(&rows[i])
+	}
+	return rows, nil
+}
+
+func SumRelayFileBytesWithDB(db *gorm.DB, userID string) (int64, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database handle is nil")
+	}
+	var total int64
+	err := db.Model(&RelayFile{}).
+		Select("COALESCE(SUM(bytes), 0)").
+		Where("user_id = ?", strings.TrimSpace(userID)).
+		Scan(&total).Error
+	return total, err
+}
+
+// DeleteRelayFileWithDB removes a file owned by userID together with its
+// cached provider ids and returns the deleted row.
+func DeleteRelayFileWithDB(db *gorm.DB, fileID string, userID string) (RelayFile, bool, error) {
+	if db == nil {
+		return RelayFile{}, false, fmt.Errorf("database handle is nil")
+	}
+	row := RelayFile{}
+	deleted := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("id = ? AND user_id = ?", strings.TrimSpace(fileID), strings.TrimSpace(userID)).First(&row).Error
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil
+			}
+			return err
+		}
+		if err := tx.Where("file_id = ?", row.Id).Delete(&RelayFileUpstream{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("id = ?", row.Id).Delete(&RelayFile{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected > 0
+		return nil
+	})
+	if err != nil {
+		return RelayFile{}, false, err
+	}
+	normalizeRelayFileRow(&row)
+	return row, deleted, nil
+}
+
+func ListExpiredRelayFilesWithDB(db *gorm.DB, now int64, limit int) ([]RelayFile, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database handle is nil")
+	}
+	if limit <= 0 {
+		limit = 500
+	}
+	rows := make([]RelayFile, 0)
+	err := db.Where("expires_at > 0 AND expires_at < ?", now).
+		Order("expires_at ASC").
+		Limit(limit).
+		Find(&rows).Error
+	return rows, err
+}
+
+func GetRelayFileUpstreamIDWithDB(db *gorm.DB, fileID string, channelID string) (string, error) {
+	if db == nil {
+		return "", fmt.Errorf("database handle is nil")
+	}
+	row := RelayFileUpstream{}
+	err := db.Where("file_id = ? AND channel_id = ?", strings.TrimSpace(fileID), strings.TrimSpace(channelID)).First(&row).Error
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(row.UpstreamFileID), nil
+}
+
+func UpsertRelayFileUpstreamWithDB(db *gorm.DB, row RelayFileUpstream) error {
+	if db == nil {
+		return fmt.Errorf("database handle is nil")
+	}
+	row.FileID = strings.TrimSpace(row.FileID)
+	row.ChannelID = strings.TrimSpace(row.ChannelID)
+	row.UpstreamFileID = strings.TrimSpace(row.UpstreamFileID)
+	if row.FileID == "" || row.ChannelID == "" || row.UpstreamFileID == "" {
+		return fmt.Errorf("file id, channel id and upstream file id are required")
+	}
+	if row.CreatedAt == 0 {
+		row.CreatedAt = helper.GetTimestamp()
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "file_id"}, {Name: "channel_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"upstream_file_id", "created_at"}),
+	}).Create(&row).Error
+}
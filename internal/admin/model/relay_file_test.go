@@ -0,0 +1,121 @@
+package model
+
+import (
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newRelayFileTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=private"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&RelayFile{}, &RelayFileUpstream{}); err != nil {
+		t.Fatalf("migrate relay files: %v", err)
+	}
+	return db
+}
+
+func TestListRelayFilesWithDBPagesPerUser(t *testing.T) {
+	db := newRelayFileTestDB(t)
+	for _, row := range []RelayFile{
+		{Id: "file-a", UserID: "user-1", Purpose: "batch", Bytes: 10, CreatedAt: 100},
+		{Id: "file-b", UserID: "user-1", Purpose: "vision", Bytes: 20, CreatedAt: 200},
+		{Id: "file-c", UserID: "user-1", Purpose: "batch", Bytes: 30, CreatedAt: 300},
+		{Id: "file-d", UserID: "user-2", Purpose: "batch", Bytes: 40, CreatedAt: 400},
+	} {
+		if err := CreateRelayFileWithDB(db, row); err != nil {
+			t.Fatalf("create %s: %v", row.Id, err)
+		}
+	}
+
+	rows, err := ListRelayFilesWithDB(db, RelayFileListQuery{UserID: "user-1", Limit: 1, Desc: true})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(rows) != 2 || rows[0].Id != "file-c" {
+		t.Fatalf("rows = %#v, want newest first plus one look-ahead row", rows)
+	}
+	rows, err = ListRelayFilesWithDB(db, RelayFileListQuery{UserID: "user-1", After: "file-c", Limit: 10, Desc: true})
+	if err != nil {
+		t.Fatalf("list after: %v", err)
+	}
+	if len(rows) != 2 || rows[0].Id != "file-b" || rows[1].Id != "file-a" {
+		t.Fatalf("rows = %#v, want files after cursor", rows)
+	}
+	rows, err = ListRelayFilesWithDB(db, RelayFileListQuery{UserID: "user-1", Purpose: "batch", Limit: 10})
+	if err != nil {
+		t.Fatalf("list by purpose: %v", err)
+	}
+	if len(rows) != 2 || rows[0].Id != "file-a" || rows[1].Id != "file-c" {
+		t.Fatalf("rows = %#v, want batch files oldest first", rows)
+	}
+
+	total, err := SumRelayFileBytesWithDB(db, "user-1")
+	if err != nil || total != 60 {
+		t.Fatalf("SumRelayFileBytesWithDB = %d, %v; want 60", total, err)
+	}
+	if _, err := GetRelayFileWithDB(db, "file-d", "user-1"); err != gorm.ErrRecordNotFound {
+		t.Fatalf("GetRelayFileWithDB other user's file err = %v, want not found", err)
+	}
+}
+
+func TestCreateRelayFileWithinQuotaWithDB(t *testing.T) {
+	db := newRelayFileTestDB(t)
+	if err := db.AutoMigrate(&User{}); err != nil {
+		t.Fatalf("migrate users: %v", err)
+	}
+	if err := db.Create(&User{Id: "user-1", Username: "user-1", Password: "password"}).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	for _, tc := range []struct {
+		row  RelayFile
+		want bool
+	}{
+		{RelayFile{Id: "file-a", UserID: "user-1", Bytes: 60}, true},
+		{RelayFile{Id: "file-b", UserID: "user-1", Bytes: 50}, false},
+		{RelayFile{Id: "file-c", UserID: "user-1", Bytes: 40}, true},
+	} {
+		created, err := CreateRelayFileWithinQuotaWithDB(db, tc.row, 100)
+		if err != nil || created != tc.want {
+			t.Fatalf("create %s = %t, %v; want %t", tc.row.Id, created, err, tc.want)
+		}
+	}
+	if total, err := SumRelayFileBytesWithDB(db, "user-1"); err != nil || total != 100 {
+		t.Fatalf("SumRelayFileBytesWithDB = %d, %v; want 100", total, err)
+	}
+	if _, err := CreateRelayFileWithinQuotaWithDB(db, RelayFile{Id: "file-d", UserID: "user-2", Bytes: 1}, 100); err != gorm.ErrRecordNotFound {
+		t.Fatalf("create for a missing user err = %v, want not found", err)
+	}
+}
+
+func TestDeleteRelayFileWithDBDropsUpstreamIDs(t *testing.T) {
+	db := newRelayFileTestDB(t)
+	if err := CreateRelayFileWithDB(db, RelayFile{Id: "file-a", UserID: "user-1", StorageKey: "user-1/file-a"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := UpsertRelayFileUpstreamWithDB(db, RelayFileUpstream{FileID: "file-a", ChannelID: "channel-1", UpstreamFileID: "file-up-1"}); err != nil {
+		t.Fatalf("upsert upstream: %v", err)
+	}
+	if err := UpsertRelayFileUpstreamWithDB(db, RelayFileUpstream{FileID: "file-a", ChannelID: "channel-1", UpstreamFileID: "file-up-2"}); err != nil {
+		t.Fatalf("upsert upstream again: %v", err)
+	}
+	if upstreamID, err := GetRelayFileUpstreamIDWithDB(db, "file-a", "channel-1"); err != nil || upstreamID != "file-up-2" {
+		t.Fatalf("GetRelayFileUpstreamIDWithDB = %q, %v; want file-up-2", upstreamID, err)
+	}
+
+	if _, deleted, err := DeleteRelayFileWithDB(db, "file-a", "user-2"); err != nil || deleted {
+		t.Fatalf("delete by other user = %t, %v; want not deleted", deleted, err)
+	}
+	row, deleted, err := DeleteRelayFileWithDB(db, "file-a", "user-1")
+	if err != nil || !deleted || row.StorageKey != "user-1/file-a" {
+		t.Fatalf("delete = %#v, %t, %v; want deleted row", row, deleted, err)
+	}
+	if _, err := GetRelayFileUpstreamIDWithDB(db, "file-a", "channel-1"); err != gorm.ErrRecordNotFound {
+		t.Fatalf("upstream id after delete err = %v, want not found", err)
+	}
+}
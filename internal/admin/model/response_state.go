@@ -0,0 +1,116 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yeying-community/router/common/helper"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const ResponseStatesTableName = "response_states"
+
+// ResponseState persists a Responses API object together with the input and
+// output items needed to replay the conversation on any channel.
+type ResponseState struct {
+	ResponseID         string `json:"response_id" gorm:"primaryKey;type:varchar(255)"`
+	UserID             string `json:"user_id" gorm:"type:char(36);index"`
+	TokenID            string `json:"token_id" gorm:"type:varchar(64);default:''"`
+	ChannelID          string `json:"channel_id" gorm:"type:char(36);default:'';index"`
+	Model              string `json:"model" gorm:"type:varchar(255);default:''"`
+	PreviousResponseID string `json:"previous_response_id" gorm:"type:varchar(255);default:'';index"`
+	Status             string `json:"status" gorm:"type:varchar(32);default:''"`
+	InputItems         string `json:"input_items" gorm:"type:text"`
+	OutputItems        string `json:"output_items" gorm:"type:text"`
+	Response           string `json:"response" gorm:"type:text"`
+	CreatedAt          int64  `json:"created_at" gorm:"bigint;index"`
+	ExpiresAt          int64  `json:"expires_at" gorm:"bigint;index"`
+}
+
+func (ResponseState) TableName() string {
+	return ResponseStatesTableName
+}
+
+func normalizeResponseStateRow(row *ResponseState) {
+	if row == nil {
+		return
+	}
+	row.ResponseID = strings.TrimSpace(row.ResponseID)
+	row.UserID = strings.TrimSpace(row.UserID)
+	row.TokenID = strings.TrimSpace(row.TokenID)
+	row.ChannelID = strings.TrimSpace(row.ChannelID)
+	row.Model = strings.TrimSpace(row.Model)
+	row.PreviousResponseID = strings.TrimSpace(row.PreviousResponseID)
+	row.Status = strings.TrimSpace(strings.ToLower(row.Status))
+}
+
+func UpsertResponseStateWithDB(db *gorm.DB, row ResponseState) error {
+	if db == nil {
+		return fmt.Errorf("database handle is nil")
+	}
+	normalizeResponseStateRow(&row)
+	if row.ResponseID == "" {
+		return fmt.Errorf("response id cannot be empty")
+	}
+	if row.CreatedAt == 0 {
+		row.CreatedAt = helper.GetTimestamp()
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "response_id"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"channel_id":   row.ChannelID,
+			"model":        row.Model,
+			"status":       row.Status,
+			"output_items": row.OutputItems,
+			"response":     row.Response,
+			"expires_at":   row.ExpiresAt,
+		}),
+	}).Create(&row).Error
+}
+
+func GetResponseStateWithDB(db *gorm.DB, responseID string) (ResponseState, error) {
+	if db == nil {
+		return ResponseState{}, fmt.Errorf("database handle is nil")
+	}
+	normalizedResponseID := strings.TrimSpace(responseID)
+	if normalizedResponseID == "" {
+		return ResponseState{}, gorm.ErrRecordNotFound
+	}
+	row := ResponseState{}
+	if err := db.Where("response_id = ?", normalizedResponseID).First(&row).Error; err != nil {
+		return ResponseState{}, err
+	}
+	if row.ExpiresAt > 0 && row.ExpiresAt < helper.GetTimestamp() {
+		return ResponseState{}, gorm.ErrRecordNotFound
+	}
+	normalizeResponseStateRow(&row)
+	return row, nil
+}
+
+func DeleteResponseStateWithDB(db *gorm.DB, responseID string, userID string) (bool, error) {
+	if db == nil {
+		return false, fmt.Errorf("database handle is nil")
+	}
+	result := db.Where("response_id = ? AND user_id = ?", strings.TrimSpace(responseID), strings.TrimSpace(userID)).
+		Delete(&ResponseState{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func DeleteExpiredResponseStatesWithDB(db *gorm.DB, now int64, limit int) (int64, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database handle is nil")
+	}
+	if limit <= 0 {
+		limit = 500
+	}
+	expired := db.Model(&ResponseState{}).
+		Select("response_id").
+		Where("expires_at > 0 AND expires_at < ?", now).
+		Limit(limit)
+	result := db.Where("response_id IN (?)", expired).Delete(&ResponseState{})
+	return result.RowsAffected, result.Error
+}
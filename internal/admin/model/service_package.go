@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/yeying-community/router/common/helper"
+	"github.com/yeying-community/router/common/logger"
 	"github.com/yeying-community/router/common/random"
 	"gorm.io/gorm"
 )
@@ -52,6 +53,8 @@ type ServicePackage struct {
 	PeriodLimit                int64                              `json:"period_limit" gorm:"type:bigint;not null;default:0"`
 	MaxConcurrencyPerUser      int                                `json:"max_concurrency_per_user" gorm:"type:int;not null;default:0"`
 	MaxConcurrencyPerPackage   int                                `json:"max_concurrency_per_package" gorm:"type:int;not null;default:0"`
+	RPMLimitPerUser            int                                `json:"rpm_limit_per_user" gorm:"column:rpm_limit_per_user;type:int;not null;default:0"`
+	TPMLimitPerUser            int                                `json:"tpm_limit_per_user" gorm:"column:tpm_limit_per_user;type:int;not null;default:0"`
 	AllowBalanceFallback       bool                               `json:"allow_balance_fallback" gorm:"not null;default:false"`
 	VisibilityScope            string                             `json:"visibility_scope" gorm:"type:varchar(32);not null;default:'all';index"`
 	SalePrice                  float64                            `json:"sale_price" gorm:"type:decimal(10,2);not null;default:0"`
@@ -714,6 +717,8 @@ func createServicePackageWithDB(db *gorm.DB, item ServicePackage) (ServicePackag
 		PeriodLimit:                normalizedItem.PeriodLimit,
 		MaxConcurrencyPerUser:      normalizedItem.MaxConcurrencyPerUser,
 		MaxConcurrencyPerPackage:   normalizedItem.MaxConcurrencyPerPackage,
+		RPMLimitPerUser:            normalizeRateLimitValue(item.RPMLimitPerUser),
+		TPMLimitPerUser:            normalizeRateLimitValue(item.TPMLimitPerUser),
 		AllowBalanceFallback:       normalizedItem.AllowBalanceFallback,
 		VisibilityScope:            visibilityScope,
 		SalePrice:                  normalizeServicePackageSalePrice(item.SalePrice),
@@ -819,6 +824,8 @@ func updateServicePackageWithDB(db *gorm.DB, item ServicePackage) (ServicePackag
 	row.PeriodLimit = item.PeriodLimit
 	row.MaxConcurrencyPerUser = item.MaxConcurrencyPerUser
 	row.MaxConcurrencyPerPackage = item.MaxConcurrencyPerPackage
+	row.RPMLimitPerUser = normalizeRateLimitValue(item.RPMLimitPerUser)
+	row.TPMLimitPerUser = normalizeRateLimitValue(item.TPMLimitPerUser)
 	row.AllowBalanceFallback = item.AllowBalanceFallback
 	row.VisibilityScope = visibilityScope
 	row.SalePrice = normalizeServicePackageSalePrice(item.SalePrice)
@@ -859,6 +866,9 @@ func updateServicePackageWithDB(db *gorm.DB, item ServicePackage) (ServicePackag
 	}); err != nil {
 		return ServicePackage{}, err
 	}
+	if err := SyncPackageRateLimitsRuntimeWithDB(db); err != nil {
+		logger.SysError("failed to sync package rate limits from database: " + err.Error())
+	}
 	row.GroupName = resolveServicePackageGroupNameWithDB(db, row.GroupID)
 	row.VisibleUserIDs = visibleUserIDs
 	row.VisibleUsers, _ = resolveServicePackageVisibleUsersWithDB(db, visibleUserIDs)
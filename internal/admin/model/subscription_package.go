@@ -291,6 +291,7 @@ func assignServicePackageToUserWithExpiresAtDB(db *gorm.DB, packageID string, us
 		return UserPackageSubscription{}, err
 	}
 	RefreshUserGroupCaches(normalizedUserID)
+	cachePackageRateLimit(subscription.Id, servicePackage)
 	return subscription, nil
 }
 
@@ -39,6 +39,8 @@ type Token struct {
 	UsedRequestCount      int64   `json:"used_request_count" gorm:"bigint;default:0"`
 	Models                *string `json:"models" gorm:"type:text"`
 	Subnet                *string `json:"subnet" gorm:"default:''"`
+	RPMLimit              int     `json:"rpm_limit" gorm:"column:rpm_limit;default:0"`
+	TPMLimit              int     `json:"tpm_limit" gorm:"column:tpm_limit;default:0"`
 }
 
 func (Token) TableName() string {
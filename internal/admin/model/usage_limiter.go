@@ -0,0 +1,330 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/yeying-community/router/common"
+	"github.com/yeying-community/router/common/logger"
+	"github.com/yeying-community/router/common/random"
+)
+
+const (
+	usageLimitReasonConcurrency = "concurrency"
+	usageLimitReasonRPM         = "rpm"
+	usageLimitReasonTPM         = "tpm"
+
+	// usageLimitInflightTTL bounds how long an in-flight slot leaked by a
+	// crashed node keeps counting in Redis. Every slot is its own lease, so a
+	// busy scope does not keep leaked slots alive.
+	usageLimitInflightTTL = 10 * time.Minute
+	usageLimitWindowTTL   = 2 * time.Minute
+)
+
+// usageLimitScope is one set of limits and the counters it is enforced on.
+// Zero limits are not enforced.
+type usageLimitScope struct {
+	Key            string
+	MaxConcurrency int
+	RPM            int
+	TPM            int
+}
+
+func (scope usageLimitScope) enforced() bool {
+	return scope.MaxConcurrency > 0 || scope.RPM > 0 || scope.TPM > 0
+}
+
+// usageLimitCounter keeps in-flight requests and fixed one-minute request and
+// token windows per scope key. acquire returns the slot id that release frees.
+type usageLimitCounter interface {
+	saturated(scopes []usageLimitScope) (string, bool)
+	acquire(scopes []usageLimitScope) (string, string, bool)
+	release(scopes []usageLimitScope, slotID string)
+	addTokens(scopes []usageLimitScope, tokens int64)
+}
+
+func currentUsageLimitCounter() usageLimitCounter {
+	if common.RedisEnabled && common.RDB != nil {
+		return redisUsageLimits
+	}
+	return memoryUsageLimits
+}
+
+func usageLimitMinute(now time.Time) int64 {
+	return now.Unix() / 60
+}
+
+// acquireUsageLimits takes a slot in every scope, or in none of them when one
+// is saturated. The returned release function frees the slots once.
+func acquireUsageLimits(scopes []usageLimitScope) (func(), string, bool) {
+	if len(scopes) == 0 {
+		return func() {}, "", true
+	}
+	counter := currentUsageLimitCounter()
+	slotID, reason, ok := counter.acquire(scopes)
+	if !ok {
+		return nil, reason, false
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() { counter.release(scopes, slotID) })
+	}, "", true
+}
+
+var memoryUsageLimits = &memoryUsageLimitCounter{usage: map[string]*usageLimitState{}}
+
+type usageLimitState struct {
+	inflight int64
+	minute   int64
+	requests int64
+	tokens   int64
+}
+
+func (state *usageLimitState) roll(minute int64) {
+	if state.minute != minute {
+		state.minute = minute
+		state.requests = 0
+		state.tokens = 0
+	}
+}
+
+func (state *usageLimitState) saturatedBy(scope usageLimitScope) (string, bool) {
+	switch {
+	case scope.MaxConcurrency > 0 && state.inflight >= int64(scope.MaxConcurrency):
+		return usageLimitReasonConcurrency, true
+	case scope.RPM > 0 && state.requests >= int64(scope.RPM):
+		return usageLimitReasonRPM, true
+	case scope.TPM > 0 && state.tokens >= int64(scope.TPM):
+		return usageLimitReasonTPM, true
+	}
+	return "", false
+}
+
+type memoryUsageLimitCounter struct {
+	mu          sync.Mutex
+	usage       map[string]*usageLimitState
+	sweptMinute int64
+}
+
+func (counter *memoryUsageLimitCounter) stateLocked(key string, minute int64) *usageLimitState {
+	if counter.sweptMinute != minute {
+		counter.sweptMinute = minute
+		for stateKey, state := range counter.usage {
+			if state.inflight <= 0 && state.minute < minute {
+				delete(counter.usage, stateKey)
+			}
+		}
+	}
+	state, ok := counter.usage[key]
+	if !ok {
+		state = &usageLimitState{minute: minute}
+		counter.usage[key] = state
+	}
+	state.roll(minute)
+	return state
+}
+
+func (counter *memoryUsageLimitCounter) saturated(scopes []usageLimitScope) (string, bool) {
+	minute := usageLimitMinute(time.Now())
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+	for _, scope := range scopes {
+		if reason, saturated := counter.stateLocked(scope.Key, minute).saturatedBy(scope); saturated {
+			return reason, true
+		}
+	}
+	return "", false
+}
+
+func (counter *memoryUsageLimitCounter) acquire(scopes []usageLimitScope) (string, string, bool) {
+	minute := usageLimitMinute(time.Now())
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+	for _, scope := range scopes {
+		if reason, saturated := counter.stateLocked(scope.Key, minute).saturatedBy(scope); saturated {
+			return "", reason, false
+		}
+	}
+	for _, scope := range scopes {
+		state := counter.stateLocked(scope.Key, minute)
+		state.inflight++
+		state.requests++
+	}
+	return "", "", true
+}
+
+func (counter *memoryUsageLimitCounter) release(scopes []usageLimitScope, _ string) {
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+	for _, scope := range scopes {
+		if state, ok := counter.usage[scope.Key]; ok && state.inflight > 0 {
+			state.inflight--
+		}
+	}
+}
+
+func (counter *memoryUsageLimitCounter) addTokens(scopes []usageLimitScope, tokens int64) {
+	minute := usageLimitMinute(time.Now())
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+	for _, scope := range scopes {
+		counter.stateLocked(scope.Key, minute).tokens += tokens
+	}
+}
+
+var redisUsageLimits = &redisUsageLimitCounter{}
+
+// redisUsageLimitCounter shares the counters across nodes. In-flight slots are
+// leases in a sorted set scored by their expiry; request windows are checked by
+// incrementing first and rolling back on overshoot. Redis failures let the
+// request through rather than block relaying.
+type redisUsageLimitCounter struct{}
+
+// redisUsageLimitAcquireSlot drops expired leases and adds one unless the
+// scope is already at its limit.
+var redisUsageLimitAcquireSlot = redis.NewScript(`
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[1])
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[2]) then
+	return 0
+end
+redis.call('ZADD', KEYS[1], ARGV[3], ARGV[4])
+redis.call('PEXPIRE', KEYS[1], ARGV[5])
+return 1
+`)
+
+func redisUsageLimitKey(kind string, key string, minute int64) string {
+	if kind == usageLimitReasonConcurrency {
+		return fmt.Sprintf("usage_limit:%s_leases:%s", kind, key)
+	}
+	return fmt.Sprintf("usage_limit:%s:%s:%d", kind, key, minute)
+}
+
+func (redisUsageLimitCounter) saturated(scopes []usageLimitScope) (string, bool) {
+	ctx := context.Background()
+	minute := usageLimitMinute(time.Now())
+	for _, scope := range scopes {
+		checks := []struct {
+			kind  string
+			limit int
+		}{
+			{usageLimitReasonConcurrency, scope.MaxConcurrency},
+			{usageLimitReasonRPM, scope.RPM},
+			{usageLimitReasonTPM, scope.TPM},
+		}
+		for _, check := range checks {
+			if check.limit <= 0 {
+				continue
+			}
+			redisKey := redisUsageLimitKey(check.kind, scope.Key, minute)
+			var value int64
+			var err error
+			if check.kind == usageLimitReasonConcurrency {
+				value, err = common.RDB.ZCount(ctx, redisKey, fmt.Sprintf("(%d", time.Now().UnixMilli()), "+inf").Result()
+			} else {
+				value, err = common.RDB.Get(ctx, redisKey).Int64()
+			}
+			if err != nil {
+				if !errors.Is(err, redis.Nil) {
+					logger.SysError("read usage limit counter failed: " + err.Error())
+				}
+				continue
+			}
+			if value >= int64(check.limit) {
+				return check.kind, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (counter redisUsageLimitCounter) acquire(scopes []usageLimitScope) (string, string, bool) {
+	ctx := context.Background()
+	now := time.Now()
+	minute := usageLimitMinute(now)
+	slotID := random.GetUUID()
+	leased := make([]string, 0, len(scopes))
+	undo := make([]string, 0, len(scopes))
+	rollback := func() {
+		for _, key := range leased {
+			common.RDB.ZRem(ctx, key, slotID)
+		}
+		for _, key := range undo {
+			common.RDB.Decr(ctx, key)
+		}
+	}
+	lease := func(key string, limit int) bool {
+		redisKey := redisUsageLimitKey(usageLimitReasonConcurrency, key, minute)
+		acquired, err := redisUsageLimitAcquireSlot.Run(ctx, common.RDB, []string{redisKey},
+			now.UnixMilli(), limit, now.Add(usageLimitInflightTTL).UnixMilli(), slotID, usageLimitInflightTTL.Milliseconds()).Int()
+		if err != nil {
+			logger.SysError("acquire usage limit slot failed: " + err.Error())
+			return true
+		}
+		if acquired != 1 {
+			return false
+		}
+		leased = append(leased, redisKey)
+		return true
+	}
+	increment := func(key string, limit int) bool {
+		redisKey := redisUsageLimitKey(usageLimitReasonRPM, key, minute)
+		value, err := common.RDB.Incr(ctx, redisKey).Result()
+		if err != nil {
+			logger.SysError("increment usage limit counter failed: " + err.Error())
+			return true
+		}
+		common.RDB.Expire(ctx, redisKey, usageLimitWindowTTL)
+		undo = append(undo, redisKey)
+		return value <= int64(limit)
+	}
+	for _, scope := range scopes {
+		if scope.TPM > 0 {
+			if reason, saturated := counter.saturated([]usageLimitScope{{Key: scope.Key, TPM: scope.TPM}}); saturated {
+				rollback()
+				return "", reason, false
+			}
+		}
+		if scope.MaxConcurrency > 0 && !lease(scope.Key, scope.MaxConcurrency) {
+			rollback()
+			return "", usageLimitReasonConcurrency, false
+		}
+		if scope.RPM > 0 && !increment(scope.Key, scope.RPM) {
+			rollback()
+			return "", usageLimitReasonRPM, false
+		}
+	}
+	return slotID, "", true
+}
+
+func (redisUsageLimitCounter) release(scopes []usageLimitScope, slotID string) {
+	ctx := context.Background()
+	for _, scope := range scopes {
+		if scope.MaxConcurrency <= 0 {
+			continue
+		}
+		key := redisUsageLimitKey(usageLimitReasonConcurrency, scope.Key, 0)
+		if err := common.RDB.ZRem(ctx, key, slotID).Err(); err != nil {
+			logger.SysError("release usage limit slot failed: " + err.Error())
+		}
+	}
+}
+
+func (redisUsageLimitCounter) addTokens(scopes []usageLimitScope, tokens int64) {
+	ctx := context.Background()
+	minute := usageLimitMinute(time.Now())
+	for _, scope := range scopes {
+		if scope.TPM <= 0 {
+			continue
+		}
+		key := redisUsageLimitKey(usageLimitReasonTPM, scope.Key, minute)
+		if err := common.RDB.IncrBy(ctx, key, tokens).Err(); err != nil {
+			logger.SysError("record usage limit tokens failed: " + err.Error())
+			continue
+		}
+		common.RDB.Expire(ctx, key, usageLimitWindowTTL)
+	}
+}
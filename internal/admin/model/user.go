@@ -48,6 +48,7 @@ type User struct {
 	AffCode                    string `json:"aff_code" gorm:"type:varchar(32);column:aff_code;uniqueIndex"`
 	InviterId                  string `json:"inviter_id" gorm:"type:char(36);column:inviter_id;index"`
 	HasPassword                bool   `json:"has_password" gorm:"column:has_password;default:false"`
+	ModerationViolations       int    `json:"moderation_violations" gorm:"column:moderation_violations;default:0"`
 	CreatedAt                  int64  `json:"created_at" gorm:"bigint;index"`
 	UpdatedAt                  int64  `json:"updated_at" gorm:"bigint;index"`
 	CanManageUsers             bool   `json:"can_manage_users" gorm:"-"`
@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/yeying-community/router/common/config"
+	"github.com/yeying-community/router/common/graceful"
+	"github.com/yeying-community/router/common/heartbeat"
 	"github.com/yeying-community/router/common/logger"
 )
 
@@ -13,6 +15,8 @@ const (
 	BatchUpdateTypeUsedQuota
 	BatchUpdateTypeChannelUsedQuota
 	BatchUpdateTypeRequestCount
+	BatchUpdateTypeChannelKeyUsedQuota
+	BatchUpdateTypeChannelKeyRequestCount
 	BatchUpdateTypeCount // if you add a new type, you need to add a new map and a new lock
 )
 
@@ -27,12 +31,20 @@ func init() {
 }
 
 func InitBatchUpdater() {
-	go func() {
-		for {
-			time.Sleep(time.Duration(config.BatchUpdateInterval) * time.Second)
+	interval := time.Duration(config.BatchUpdateInterval) * time.Second
+	graceful.Worker(func() {
+		heartbeat.Beat("batch_updater", interval)
+		for graceful.Sleep(interval) {
 			batchUpdate()
+			heartbeat.Beat("batch_updater", interval)
 		}
-	}()
+	})
+}
+
+// FlushBatchUpdate writes the records gathered since the last batch update;
+// shutdown calls it once nothing can add more.
+func FlushBatchUpdate() {
+	batchUpdate()
 }
 
 func AddBatchUpdateRecord(type_ int, id string, value int64) {
@@ -66,6 +78,10 @@ func batchUpdate() {
 				updateUserRequestCount(key, int(value))
 			case BatchUpdateTypeChannelUsedQuota:
 				updateChannelUsedQuota(key, value)
+			case BatchUpdateTypeChannelKeyUsedQuota:
+				updateChannelKeyUsage(key, value, 0)
+			case BatchUpdateTypeChannelKeyRequestCount:
+				updateChannelKeyUsage(key, 0, value)
 			}
 		}
 	}
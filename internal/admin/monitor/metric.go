@@ -120,6 +120,7 @@ func StartMetricMonitor() {
 }
 
 func Emit(channelId string, success bool) {
+	model.RecordChannelOutcome(channelId, success)
 	if !config.EnableMetric {
 		return
 	}
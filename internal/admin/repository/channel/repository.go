@@ -419,6 +419,9 @@ func Delete(channel *model.Channel) error {
 		if err := model.DeleteChannelTestsByChannelIDWithDB(tx, channel.Id); err != nil {
 			return err
 		}
+		if err := model.DeleteChannelKeysByChannelIDsWithDB(tx, []string{strings.TrimSpace(channel.Id)}); err != nil {
+			return err
+		}
 		if err := tx.Where("channel_id = ?", strings.TrimSpace(channel.Id)).Delete(&model.GroupModelChannel{}).Error; err != nil {
 			return err
 		}
@@ -498,6 +501,9 @@ func deleteChannelsByQuery(query *gorm.DB) (int64, error) {
 		if err := model.DeleteChannelTestsByChannelIDsWithDB(tx, channelIDs); err != nil {
 			return err
 		}
+		if err := model.DeleteChannelKeysByChannelIDsWithDB(tx, channelIDs); err != nil {
+			return err
+		}
 		if err := tx.Where("channel_id IN ?", channelIDs).Delete(&model.GroupModelChannel{}).Error; err != nil {
 			return err
 		}
@@ -0,0 +1,145 @@
+package log
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/yeying-community/router/internal/admin/model"
+	"gorm.io/gorm"
+)
+
+// relayLatencySampleLimit bounds how many of the most recent consume logs in
+// the range feed the percentiles, so a wide range stays cheap to compute.
+const relayLatencySampleLimit = 50000
+
+type relayLatencyRow struct {
+	ModelName             string  `gorm:"column:model_name"`
+	ChannelId             string  `gorm:"column:channel_id"`
+	FirstByteMs           int64   `gorm:"column:first_byte_ms"`
+	FirstTokenMs          int64   `gorm:"column:first_token_ms"`
+	UpstreamLatencyMs     int64   `gorm:"column:upstream_latency_ms"`
+	OutputTokensPerSecond float64 `gorm:"column:output_tokens_per_second"`
+}
+
+type relayLatencySamples struct {
+	stat                  *model.RelayLatencyStat
+	firstByteMs           []float64
+	firstTokenMs          []float64
+	upstreamLatencyMs     []float64
+	outputTokensPerSecond []float64
+}
+
+func GetRelayLatencyStats(groupBy string, startTimestamp int64, endTimestamp int64, modelName string, channel string) ([]*model.RelayLatencyStat, error) {
+	stats, err := GetRelayLatencyStatsWithDB(model.LOG_DB, groupBy, startTimestamp, endTimestamp, modelName, channel)
+	if err != nil || groupBy != model.RelayLatencyGroupByChannel || len(stats) == 0 {
+		return stats, err
+	}
+	channelIDs := make([]string, 0, len(stats))
+	for _, stat := range stats {
+		channelIDs = append(channelIDs, stat.ChannelId)
+	}
+	var channels []*model.Channel
+	if err := model.DB.Select("id", "name").Where("id IN ?", channelIDs).Find(&channels).Error; err != nil {
+		return nil, err
+	}
+	channelNameByID := make(map[string]string, len(channels))
+	for _, channel := range channels {
+		channelNameByID[channel.Id] = channel.DisplayName()
+	}
+	for _, stat := range stats {
+		stat.ChannelName = channelNameByID[stat.ChannelId]
+	}
+	return stats, nil
+}
+
+// GetRelayLatencyStatsWithDB computes p50/p90/p99 of the relay timings of
+// consume logs, grouped by model or by channel and busiest first. Zero values
+// mean the metric was not measured for that request and are left out.
+func GetRelayLatencyStatsWithDB(db *gorm.DB, groupBy string, startTimestamp int64, endTimestamp int64, modelName string, channel string) ([]*model.RelayLatencyStat, error) {
+	query := db.Table(model.EventLogsTableName).
+		Select(adminVisibleModelNameExpr+" AS model_name, channel_id, first_byte_ms, first_token_ms, upstream_latency_ms, output_tokens_per_second").
+		Where("type = ?", model.LogTypeConsume).
+		Where("created_at BETWEEN ? AND ?", startTimestamp, endTimestamp).
+		Where("upstream_latency_ms > 0")
+	if modelName = strings.TrimSpace(modelName); modelName != "" {
+		query = query.Where(adminVisibleModelNameExpr+" = ?", modelName)
+	}
+	if channel = strings.TrimSpace(channel); channel != "" {
+		query = query.Where("channel_id = ?", channel)
+	}
+	var rows []relayLatencyRow
+	if err := query.Order("created_at DESC").Limit(relayLatencySampleLimit).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string]*relayLatencySamples)
+	for _, row := range rows {
+		key := strings.TrimSpace(row.ModelName)
+		if groupBy == model.RelayLatencyGroupByChannel {
+			key = strings.TrimSpace(row.ChannelId)
+		}
+		if key == "" {
+			continue
+		}
+		samples, ok := groups[key]
+		if !ok {
+			samples = &relayLatencySamples{stat: &model.RelayLatencyStat{}}
+			if groupBy == model.RelayLatencyGroupByChannel {
+				samples.stat.ChannelId = key
+			} else {
+				samples.stat.Model = key
+			}
+			groups[key] = samples
+		}
+		samples.stat.RequestCount++
+		samples.upstreamLatencyMs = append(samples.upstreamLatencyMs, float64(row.UpstreamLatencyMs))
+		if row.FirstByteMs > 0 {
+			samples.firstByteMs = append(samples.firstByteMs, float64(row.FirstByteMs))
+		}
+		if row.FirstTokenMs > 0 {
+			samples.firstTokenMs = append(samples.firstTokenMs, float64(row.FirstTokenMs))
+		}
+		if row.OutputTokensPerSecond > 0 {
+			samples.outputTokensPerSecond = append(samples.outputTokensPerSecond, row.OutputTokensPerSecond)
+		}
+	}
+
+	stats := make([]*model.RelayLatencyStat, 0, len(groups))
+	for _, samples := range groups {
+		samples.stat.FirstByteMs = latencyPercentiles(samples.firstByteMs)
+		samples.stat.FirstTokenMs = latencyPercentiles(samples.firstTokenMs)
+		samples.stat.UpstreamLatencyMs = latencyPercentiles(samples.upstreamLatencyMs)
+		samples.stat.OutputTokensPerSecond = latencyPercentiles(samples.outputTokensPerSecond)
+		stats = append(stats, samples.stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].RequestCount != stats[j].RequestCount {
+			return stats[i].RequestCount > stats[j].RequestCount
+		}
+		return stats[i].Model+stats[i].ChannelId < stats[j].Model+stats[j].ChannelId
+	})
+	return stats, nil
+}
+
+// latencyPercentiles uses the nearest-rank method, so every reported value is
+// one that was actually observed.
+func latencyPercentiles(values []float64) model.LatencyPercentiles {
+	if len(values) == 0 {
+		return model.LatencyPercentiles{}
+	}
+	sort.Float64s(values)
+	rank := func(p float64) float64 {
+		index := int(math.Ceil(p*float64(len(values)))) - 1
+		if index < 0 {
+			index = 0
+		}
+		return values[index]
+	}
+	return model.LatencyPercentiles{
+		Count: int64(len(values)),
+		P50:   rank(0.5),
+		P90:   rank(0.9),
+		P99:   rank(0.99),
+	}
+}
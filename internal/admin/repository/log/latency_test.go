@@ -0,0 +1,75 @@
+package log
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/yeying-community/router/internal/admin/model"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestGetRelayLatencyStatsWithDBComputesPercentiles(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=private"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&model.Log{}); err != nil {
+		t.Fatalf("AutoMigrate: %v", err)
+	}
+	logs := make([]model.Log, 0, 104)
+	for i := 1; i <= 100; i++ {
+		entry := model.Log{
+			Id:                fmt.Sprintf("log-%d", i),
+			Type:              model.LogTypeConsume,
+			CreatedAt:         1000 + int64(i),
+			ModelName:         "gpt-4o",
+			ChannelId:         "channel-a",
+			FirstByteMs:       int64(i),
+			UpstreamLatencyMs: int64(i * 10),
+		}
+		if i%2 == 0 {
+			entry.FirstTokenMs = int64(i * 2)
+			entry.OutputTokensPerSecond = float64(i)
+		}
+		logs = append(logs, entry)
+	}
+	logs = append(logs,
+		model.Log{Id: "other-model", Type: model.LogTypeConsume, CreatedAt: 1050, ModelName: "claude", ChannelId: "channel-b", UpstreamLatencyMs: 700},
+		model.Log{Id: "not-measured", Type: model.LogTypeConsume, CreatedAt: 1050, ModelName: "gpt-4o", ChannelId: "channel-a"},
+		model.Log{Id: "failure", Type: model.LogTypeRelayFailure, CreatedAt: 1050, ModelName: "gpt-4o", ChannelId: "channel-a", UpstreamLatencyMs: 99999},
+		model.Log{Id: "out-of-range", Type: model.LogTypeConsume, CreatedAt: 5000, ModelName: "gpt-4o", ChannelId: "channel-a", UpstreamLatencyMs: 99999},
+	)
+	if err := db.Create(&logs).Error; err != nil {
+		t.Fatalf("create logs: %v", err)
+	}
+
+	stats, err := GetRelayLatencyStatsWithDB(db, model.RelayLatencyGroupByModel, 1000, 2000, "", "")
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if len(stats) != 2 || stats[0].Model != "gpt-4o" || stats[1].Model != "claude" {
+		t.Fatalf("stats = %+v, want gpt-4o then claude", stats)
+	}
+	gpt := stats[0]
+	if gpt.RequestCount != 100 {
+		t.Fatalf("request count = %d, want 100", gpt.RequestCount)
+	}
+	if got := gpt.UpstreamLatencyMs; got.Count != 100 || got.P50 != 500 || got.P90 != 900 || got.P99 != 990 {
+		t.Fatalf("upstream latency = %+v", got)
+	}
+	if got := gpt.FirstTokenMs; got.Count != 50 || got.P50 != 100 || got.P99 != 200 {
+		t.Fatalf("first token = %+v, want only the 50 measured logs", got)
+	}
+	if got := gpt.OutputTokensPerSecond; got.Count != 50 || got.P90 != 90 {
+		t.Fatalf("throughput = %+v", got)
+	}
+
+	stats, err = GetRelayLatencyStatsWithDB(db, model.RelayLatencyGroupByChannel, 1000, 2000, "claude", "")
+	if err != nil {
+		t.Fatalf("stats by channel: %v", err)
+	}
+	if len(stats) != 1 || stats[0].ChannelId != "channel-b" || stats[0].Model != "" || stats[0].UpstreamLatencyMs.P50 != 700 {
+		t.Fatalf("channel stats = %+v", stats)
+	}
+}
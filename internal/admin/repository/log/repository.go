@@ -114,6 +114,7 @@ func init() {
 		DeleteOldLog:                          DeleteOld,
 		SearchLogsByPeriodAndModel:            SearchLogsByPeriodAndModel,
 		SearchLogModelsByPeriod:               SearchLogModelsByPeriod,
+		GetRelayLatencyStats:                  GetRelayLatencyStats,
 	})
 }
 
@@ -124,7 +125,9 @@ func recordLogHelper(ctx context.Context, log *model.Log) {
 	normalizeLogRouteModelNames(log)
 	traceID := helper.GetTraceID(ctx)
 	log.TraceID = traceID
-	err := model.LOG_DB.Create(log).Error
+	// The request may be over by the time its log is written; only its trace
+	// is carried over.
+	err := model.LOG_DB.WithContext(context.WithoutCancel(ctx)).Create(log).Error
 	if err != nil {
 		logger.Error(ctx, "failed to record log: "+err.Error())
 		return
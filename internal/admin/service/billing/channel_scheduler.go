@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/yeying-community/router/common/config"
+	"github.com/yeying-community/router/common/graceful"
+	"github.com/yeying-community/router/common/heartbeat"
 	"github.com/yeying-community/router/common/helper"
 	"github.com/yeying-community/router/common/logger"
 	"github.com/yeying-community/router/internal/admin/model"
@@ -23,7 +25,7 @@ var startChannelBillingAutoRefreshWorkerOnce sync.Once
 
 func StartChannelBillingAutoRefreshWorker() {
 	startChannelBillingAutoRefreshWorkerOnce.Do(func() {
-		go runChannelBillingAutoRefreshWorker()
+		graceful.Worker(runChannelBillingAutoRefreshWorker)
 	})
 }
 
@@ -33,10 +35,15 @@ func runChannelBillingAutoRefreshWorker() {
 	defer ticker.Stop()
 
 	for {
+		heartbeat.Beat("channel_billing_refresh", channelBillingSchedulerTickSeconds*time.Second)
 		if shouldRunChannelBillingAutoRefreshNow() {
 			runChannelBillingAutoRefreshOnce()
 		}
-		<-ticker.C
+		select {
+		case <-graceful.Stopping():
+			return
+		case <-ticker.C:
+		}
 	}
 }
 
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/yeying-community/router/common/config"
+	"github.com/yeying-community/router/common/graceful"
+	"github.com/yeying-community/router/common/heartbeat"
 	"github.com/yeying-community/router/common/helper"
 	"github.com/yeying-community/router/common/logger"
 )
@@ -20,7 +22,7 @@ var startFXAutoSyncWorkerOnce sync.Once
 
 func StartFXAutoSyncWorker() {
 	startFXAutoSyncWorkerOnce.Do(func() {
-		go runFXAutoSyncWorker()
+		graceful.Worker(runFXAutoSyncWorker)
 	})
 }
 
@@ -30,10 +32,15 @@ func runFXAutoSyncWorker() {
 	defer ticker.Stop()
 
 	for {
+		heartbeat.Beat("fx_auto_sync", fxAutoSyncLoopIntervalSeconds*time.Second)
 		if shouldRunFXAutoSyncNow() {
 			runFXAutoSyncOnce()
 		}
-		<-ticker.C
+		select {
+		case <-graceful.Stopping():
+			return
+		case <-ticker.C:
+		}
 	}
 }
 
@@ -6,6 +6,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/yeying-community/router/common/graceful"
+	"github.com/yeying-community/router/common/heartbeat"
 	"github.com/yeying-community/router/common/helper"
 	"github.com/yeying-community/router/common/logger"
 	"github.com/yeying-community/router/internal/admin/model"
@@ -21,7 +23,7 @@ const (
 var startProcurementRetryWorkerOnce sync.Once
 
 func StartProcurementRetryWorker() {
-	startProcurementRetryWorkerOnce.Do(func() { go runProcurementRetryWorker() })
+	startProcurementRetryWorkerOnce.Do(func() { graceful.Worker(runProcurementRetryWorker) })
 }
 
 func runProcurementRetryWorker() {
@@ -29,8 +31,13 @@ func runProcurementRetryWorker() {
 	ticker := time.NewTicker(procurementRetryLoopIntervalSeconds * time.Second)
 	defer ticker.Stop()
 	for {
+		heartbeat.Beat("procurement_retry", procurementRetryLoopIntervalSeconds*time.Second)
 		runProcurementRetryOnce()
-		<-ticker.C
+		select {
+		case <-graceful.Stopping():
+			return
+		case <-ticker.C:
+		}
 	}
 }
 
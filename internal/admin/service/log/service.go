@@ -52,3 +52,7 @@ func MinLogTimestampByUserId(userId string, logTypes []int) (int64, error) {
 func DeleteOld(targetTimestamp int64) (int64, error) {
 	return logrepo.DeleteOld(targetTimestamp)
 }
+
+func GetRelayLatencyStats(groupBy string, startTimestamp int64, endTimestamp int64, modelName string, channel string) ([]*model.RelayLatencyStat, error) {
+	return logrepo.GetRelayLatencyStats(groupBy, startTimestamp, endTimestamp, modelName, channel)
+}
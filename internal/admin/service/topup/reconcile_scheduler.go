@@ -5,6 +5,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/yeying-community/router/common/graceful"
+	"github.com/yeying-community/router/common/heartbeat"
 	"github.com/yeying-community/router/common/helper"
 	"github.com/yeying-community/router/common/logger"
 	"github.com/yeying-community/router/internal/admin/model"
@@ -20,7 +22,7 @@ var startTopupReconcileWorkerOnce sync.Once
 
 func StartTopupReconcileWorker() {
 	startTopupReconcileWorkerOnce.Do(func() {
-		go runTopupReconcileWorker()
+		graceful.Worker(runTopupReconcileWorker)
 	})
 }
 
@@ -30,8 +32,13 @@ func runTopupReconcileWorker() {
 	defer ticker.Stop()
 
 	for {
+		heartbeat.Beat("topup_reconcile", topupReconcileLoopIntervalSeconds*time.Second)
 		runTopupReconcileOnce()
-		<-ticker.C
+		select {
+		case <-graceful.Stopping():
+			return
+		case <-ticker.C:
+		}
 	}
 }
 
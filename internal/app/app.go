@@ -2,6 +2,7 @@ package app
 
 import (
 	"fmt"
+	"net/http"
 	"strconv"
 	"strings"
 
@@ -15,6 +16,7 @@ import (
 	"github.com/yeying-community/router/common/config"
 	"github.com/yeying-community/router/common/i18n"
 	"github.com/yeying-community/router/common/logger"
+	"github.com/yeying-community/router/common/tracing"
 	channelcontroller "github.com/yeying-community/router/internal/admin/controller/channel"
 	task "github.com/yeying-community/router/internal/admin/controller/task"
 	"github.com/yeying-community/router/internal/admin/model"
@@ -22,7 +24,10 @@ import (
 	_ "github.com/yeying-community/router/internal/admin/repository/bootstrap"
 	billingsvc "github.com/yeying-community/router/internal/admin/service/billing"
 	topupsvc "github.com/yeying-community/router/internal/admin/service/topup"
+	"github.com/yeying-community/router/internal/metrics"
 	"github.com/yeying-community/router/internal/relay/adaptor/openai"
+	"github.com/yeying-community/router/internal/relay/filestore"
+	"github.com/yeying-community/router/internal/relay/responsestate"
 	"github.com/yeying-community/router/internal/transport/http/middleware"
 	"github.com/yeying-community/router/internal/transport/http/router"
 )
@@ -38,6 +43,9 @@ func Run() {
 	if config.DebugEnabled {
 		logger.SysLog("running in debug mode")
 	}
+	if err := tracing.Init(); err != nil {
+		logger.FatalLog("failed to initialize tracing: " + err.Error())
+	}
 
 	// Initialize SQL Database
 	model.InitDB()
@@ -80,6 +88,13 @@ func Run() {
 		logger.SysLog("metric enabled, will disable channel if too much request failed")
 		monitor.StartMetricMonitor()
 	}
+	var metricsServer *http.Server
+	if config.PrometheusEnabled {
+		metrics.Register()
+		if config.PrometheusListenAddr != "" {
+			metricsServer = metrics.Serve(config.PrometheusListenAddr)
+		}
+	}
 	openai.InitTokenEncoders()
 	client.Init()
 	if config.IsMasterNode {
@@ -89,6 +104,8 @@ func Run() {
 		billingsvc.StartChannelBillingAutoRefreshWorker()
 		topupsvc.StartTopupReconcileWorker()
 		billingsvc.StartProcurementRetryWorker()
+		responsestate.StartStatePruneWorker()
+		filestore.StartFilePruneWorker()
 	}
 
 	// Initialize i18n
@@ -99,8 +116,10 @@ func Run() {
 	// Initialize HTTP server
 	server := gin.New()
 	server.Use(gin.Recovery())
+	server.Use(middleware.InFlight())
 	// This will cause SSE not to work!!!
 	//server.Use(gzip.Gzip(gzip.DefaultCompression))
+	server.Use(middleware.Tracing())
 	server.Use(middleware.TraceID())
 	server.Use(middleware.Language())
 	middleware.SetUpLogger(server)
@@ -109,12 +128,12 @@ func Run() {
 	server.Use(sessions.Sessions("session", store))
 
 	router.SetRouter(server, rootapp.BuildFS)
+	if config.IsMasterNode {
+		task.StartBatchWorkers(server)
+	}
 	var port = strconv.Itoa(*common.Port)
 	logger.SysLogf("server started on http://localhost:%s", port)
-	err = server.Run(":" + port)
-	if err != nil {
-		logger.FatalLog("failed to start HTTP server: " + err.Error())
-	}
+	serve(":"+port, server, metricsServer)
 }
 
 func validateStartupAuthConfig() {
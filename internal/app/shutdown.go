@@ -0,0 +1,90 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/yeying-community/router/common/config"
+	"github.com/yeying-community/router/common/graceful"
+	"github.com/yeying-community/router/common/logger"
+	"github.com/yeying-community/router/common/tracing"
+	"github.com/yeying-community/router/internal/admin/model"
+)
+
+// finalFlushTimeout bounds the writes made after the drain deadline, so a
+// slow exporter cannot hold the process.
+const finalFlushTimeout = 5 * time.Second
+
+// serve runs handler on addr until SIGINT or SIGTERM, then shuts down along
+// with metricsServer, which may be nil. A second signal exits at once.
+func serve(addr string, handler http.Handler, metricsServer *http.Server) {
+	server := &http.Server{Addr: addr, Handler: handler}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-serveErr:
+		logger.FatalLog("failed to start HTTP server: " + err.Error())
+	case sig := <-signals:
+		logger.SysLogf("received %s, draining", sig)
+	}
+	go func() {
+		sig := <-signals
+		logger.SysErrorf("received %s again, exiting without draining", sig)
+		os.Exit(1)
+	}()
+	shutdown(server, metricsServer)
+}
+
+// shutdown reports not ready, lets load balancers notice, then tells workers
+// to stop, stops accepting connections and waits, up to
+// server.shutdown_timeout_seconds, for requests and websocket sessions,
+// workers and background billing. Workers are told first because batch lines
+// are requests too. The batch updater and spans are flushed last, whether or
+// not the deadline passed.
+func shutdown(server *http.Server, metricsServer *http.Server) {
+	graceful.StartDraining()
+	if delay := config.ShutdownDrainDelaySeconds; delay > 0 {
+		time.Sleep(time.Duration(delay) * time.Second)
+	}
+	graceful.SignalStop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.ShutdownTimeoutSeconds)*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		logger.SysErrorf("http server shutdown failed: %s", err.Error())
+	}
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(ctx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			logger.SysErrorf("metrics server shutdown failed: %s", err.Error())
+		}
+	}
+	if err := graceful.WaitRequests(ctx); err != nil {
+		logger.SysErrorf("shutdown deadline reached with %d requests in flight", graceful.InFlightRequests())
+	}
+	if err := graceful.StopWorkers(ctx); err != nil {
+		logger.SysError("shutdown deadline reached before all workers stopped")
+	}
+	if err := graceful.WaitTasks(ctx); err != nil {
+		logger.SysErrorf("shutdown deadline reached with %d billing and log writes pending", graceful.PendingTasks())
+	}
+
+	if config.BatchUpdateEnabled {
+		model.FlushBatchUpdate()
+	}
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), finalFlushTimeout)
+	defer cancelFlush()
+	if err := tracing.Shutdown(flushCtx); err != nil {
+		logger.SysErrorf("flush spans failed: %s", err.Error())
+	}
+	logger.SysLog("shutdown complete")
+}
@@ -0,0 +1,65 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yeying-community/router/common/config"
+	"github.com/yeying-community/router/common/graceful"
+	"github.com/yeying-community/router/internal/transport/http/middleware"
+)
+
+func TestShutdownStopsBatchWorkersBeforeWaitingForRequests(t *testing.T) {
+	previousDelay, previousTimeout := config.ShutdownDrainDelaySeconds, config.ShutdownTimeoutSeconds
+	t.Cleanup(func() {
+		config.ShutdownDrainDelaySeconds, config.ShutdownTimeoutSeconds = previousDelay, previousTimeout
+	})
+	config.ShutdownDrainDelaySeconds, config.ShutdownTimeoutSeconds = 0, 3
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(middleware.InFlight())
+	engine.POST("/v1/chat/completions", func(c *gin.Context) {
+		time.Sleep(10 * time.Millisecond)
+		c.Status(http.StatusOK)
+	})
+
+	// like the batch runner: send lines through the engine until told to
+	// stop, then save what is left
+	var lines atomic.Int64
+	var saved atomic.Bool
+	started := make(chan struct{})
+	graceful.Worker(func() {
+		close(started)
+		for {
+			select {
+			case <-graceful.Stopping():
+				saved.Store(true)
+				return
+			default:
+			}
+			request := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			engine.ServeHTTP(httptest.NewRecorder(), request)
+			lines.Add(1)
+		}
+	})
+	<-started
+
+	startedAt := time.Now()
+	shutdown(&http.Server{}, nil)
+
+	if elapsed := time.Since(startedAt); elapsed >= time.Duration(config.ShutdownTimeoutSeconds)*time.Second {
+		t.Fatalf("shutdown took %s, want the batch to stop before the deadline", elapsed)
+	}
+	if !saved.Load() {
+		t.Fatal("the batch worker did not save before shutdown returned")
+	}
+	if graceful.InFlightRequests() != 0 {
+		t.Fatalf("in flight = %d after shutdown", graceful.InFlightRequests())
+	}
+}
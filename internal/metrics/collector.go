@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/yeying-community/router/common"
+	"github.com/yeying-community/router/common/logger"
+	"github.com/yeying-community/router/internal/admin/model"
+	"gorm.io/gorm"
+)
+
+// stateCollector reads channel health, task queues and connection pools at
+// scrape time, so nothing has to be kept in sync between scrapes.
+type stateCollector struct {
+	channelBreaker    *prometheus.Desc
+	channelKeyBreaker *prometheus.Desc
+	asyncTasks        *prometheus.Desc
+	dbOpen            *prometheus.Desc
+	dbInUse           *prometheus.Desc
+	dbIdle            *prometheus.Desc
+	dbMaxOpen         *prometheus.Desc
+	dbWaitCount       *prometheus.Desc
+	dbWaitSeconds     *prometheus.Desc
+	redisTotal        *prometheus.Desc
+	redisIdle         *prometheus.Desc
+	redisHits         *prometheus.Desc
+	redisMisses       *prometheus.Desc
+	redisTimeouts     *prometheus.Desc
+}
+
+type redisPoolStatter interface {
+	PoolStats() *redis.PoolStats
+}
+
+func newStateCollector() *stateCollector {
+	desc := func(subsystem string, name string, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, subsystem, name), help, labels, nil)
+	}
+	return &stateCollector{
+		channelBreaker:    desc("channel", "circuit_breaker_state", "Channels whose circuit breaker is open or half-open (1 per channel and state).", "channel", "state"),
+		channelKeyBreaker: desc("channel", "key_breakers_open", "Pool keys of the channel whose breaker is open.", "channel"),
+		asyncTasks:        desc("async_task", "queue_depth", "Async tasks waiting or running, by type.", "type", "status"),
+		dbOpen:            desc("db", "open_connections", "Open connections, in use or idle.", "db"),
+		dbInUse:           desc("db", "in_use_connections", "Connections currently in use.", "db"),
+		dbIdle:            desc("db", "idle_connections", "Idle connections.", "db"),
+		dbMaxOpen:         desc("db", "max_open_connections", "Configured connection limit.", "db"),
+		dbWaitCount:       desc("db", "wait_count_total", "Connections waited for.", "db"),
+		dbWaitSeconds:     desc("db", "wait_duration_seconds_total", "Time spent waiting for a connection.", "db"),
+		redisTotal:        desc("redis", "pool_total_connections", "Connections in the Redis pool."),
+		redisIdle:         desc("redis", "pool_idle_connections", "Idle connections in the Redis pool."),
+		redisHits:         desc("redis", "pool_hits_total", "Times a free connection was found in the pool."),
+		redisMisses:       desc("redis", "pool_misses_total", "Times a free connection was not found in the pool."),
+		redisTimeouts:     desc("redis", "pool_timeouts_total", "Times a wait for a connection timed out."),
+	}
+}
+
+func (s *stateCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		s.channelBreaker, s.channelKeyBreaker, s.asyncTasks,
+		s.dbOpen, s.dbInUse, s.dbIdle, s.dbMaxOpen, s.dbWaitCount, s.dbWaitSeconds,
+		s.redisTotal, s.redisIdle, s.redisHits, s.redisMisses, s.redisTimeouts,
+	} {
+		ch <- d
+	}
+}
+
+func (s *stateCollector) Collect(ch chan<- prometheus.Metric) {
+	s.collectChannels(ch)
+	s.collectAsyncTasks(ch)
+	s.collectDB(ch, "main", model.DB)
+	if model.LOG_DB != nil && model.LOG_DB != model.DB {
+		s.collectDB(ch, "log", model.LOG_DB)
+	}
+	s.collectRedis(ch)
+}
+
+func (s *stateCollector) collectChannels(ch chan<- prometheus.Metric) {
+	if model.DB != nil {
+		open, err := model.ListOpenChannelCircuitBreakerStates()
+		if err != nil {
+			logger.SysError("metrics: list open circuit breakers failed: " + err.Error())
+		}
+		halfOpen, err := model.ListHalfOpenChannelCircuitBreakerStates()
+		if err != nil {
+			logger.SysError("metrics: list half-open circuit breakers failed: " + err.Error())
+		}
+		for _, row := range append(open, halfOpen...) {
+			ch <- prometheus.MustNewConstMetric(s.channelBreaker, prometheus.GaugeValue, 1, row.ChannelId, row.State)
+		}
+	}
+	for channelID, count := range model.OpenChannelKeyBreakers() {
+		ch <- prometheus.MustNewConstMetric(s.channelKeyBreaker, prometheus.GaugeValue, float64(count), channelID)
+	}
+}
+
+func (s *stateCollector) collectAsyncTasks(ch chan<- prometheus.Metric) {
+	if model.DB == nil {
+		return
+	}
+	rows, err := model.CountActiveAsyncTasksWithDB(model.DB)
+	if err != nil {
+		logger.SysError("metrics: count async tasks failed: " + err.Error())
+		return
+	}
+	for _, row := range rows {
+		ch <- prometheus.MustNewConstMetric(s.asyncTasks, prometheus.GaugeValue, float64(row.Count), row.Type, row.Status)
+	}
+}
+
+func (s *stateCollector) collectDB(ch chan<- prometheus.Metric, name string, db *gorm.DB) {
+	if db == nil {
+		return
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return
+	}
+	stats := sqlDB.Stats()
+	ch <- prometheus.MustNewConstMetric(s.dbOpen, prometheus.GaugeValue, float64(stats.OpenConnections), name)
+	ch <- prometheus.MustNewConstMetric(s.dbInUse, prometheus.GaugeValue, float64(stats.InUse), name)
+	ch <- prometheus.MustNewConstMetric(s.dbIdle, prometheus.GaugeValue, float64(stats.Idle), name)
+	ch <- prometheus.MustNewConstMetric(s.dbMaxOpen, prometheus.GaugeValue, float64(stats.MaxOpenConnections), name)
+	ch <- prometheus.MustNewConstMetric(s.dbWaitCount, prometheus.CounterValue, float64(stats.WaitCount), name)
+	ch <- prometheus.MustNewConstMetric(s.dbWaitSeconds, prometheus.CounterValue, stats.WaitDuration.Seconds(), name)
+}
+
+func (s *stateCollector) collectRedis(ch chan<- prometheus.Metric) {
+	if !common.RedisEnabled || common.RDB == nil {
+		return
+	}
+	client, ok := common.RDB.(redisPoolStatter)
+	if !ok {
+		return
+	}
+	stats := client.PoolStats()
+	if stats == nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(s.redisTotal, prometheus.GaugeValue, float64(stats.TotalConns))
+	ch <- prometheus.MustNewConstMetric(s.redisIdle, prometheus.GaugeValue, float64(stats.IdleConns))
+	ch <- prometheus.MustNewConstMetric(s.redisHits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(s.redisMisses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(s.redisTimeouts, prometheus.CounterValue, float64(stats.Timeouts))
+}
@@ -0,0 +1,189 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/yeying-community/router/common/config"
+	"github.com/yeying-community/router/common/logger"
+)
+
+const namespace = "router"
+
+var registry = prometheus.NewRegistry()
+
+var (
+	relayRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "relay",
+		Name:      "requests_total",
+		Help:      "Relay requests by model, endpoint, final channel, group and HTTP status.",
+	}, []string{"model", "endpoint", "channel", "group", "status"})
+	relayDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "relay",
+		Name:      "request_duration_seconds",
+		Help:      "Relay request latency from ingress to the last byte written.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"model", "endpoint", "channel", "group", "status"})
+	relayTTFT = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "relay",
+		Name:      "time_to_first_token_seconds",
+		Help:      "Time until the first byte of a streamed response reached the client.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 4, 8, 15, 30, 60},
+	}, []string{"model", "endpoint", "channel", "group"})
+	relayFallbacks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "relay",
+		Name:      "fallback_attempts_total",
+		Help:      "Extra channel attempts made after the initially selected channel failed.",
+	}, []string{"model", "endpoint", "group"})
+	quotaConsumed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "billing",
+		Name:      "quota_consumed_total",
+		Help:      "Quota settled for relay requests.",
+	}, []string{"model", "channel", "group"})
+	quotaPreConsumed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "billing",
+		Name:      "quota_pre_consumed_total",
+		Help:      "Quota reserved before relaying, to compare with the settled amount.",
+	}, []string{"model", "channel", "group"})
+	quotaSettleDelta = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "billing",
+		Name:      "settle_delta_quota",
+		Help:      "Settled minus pre-consumed quota per request; negative values are refunds.",
+		Buckets:   []float64{-100000, -10000, -1000, -100, -1, 0, 1, 100, 1000, 10000, 100000},
+	}, []string{"model", "group"})
+)
+
+var registerOnce sync.Once
+
+// Register adds the relay metrics and the scrape-time collectors to the
+// registry served by Handler. It is safe to call more than once.
+func Register() {
+	registerOnce.Do(func() {
+		registry.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+			relayRequests,
+			relayDuration,
+			relayTTFT,
+			relayFallbacks,
+			quotaConsumed,
+			quotaPreConsumed,
+			quotaSettleDelta,
+			newStateCollector(),
+		)
+	})
+}
+
+// RelayObservation is the outcome of one relay request as seen by the
+// ingress middleware, after any fallback attempts.
+type RelayObservation struct {
+	Model     string
+	Endpoint  string
+	Channel   string
+	Group     string
+	Status    string
+	Duration  time.Duration
+	FirstByte time.Duration
+	Retries   int
+}
+
+func ObserveRelay(o RelayObservation) {
+	if !config.PrometheusEnabled {
+		return
+	}
+	model := labelValue(o.Model)
+	endpoint := labelValue(o.Endpoint)
+	channel := labelValue(o.Channel)
+	group := labelValue(o.Group)
+	status := labelValue(o.Status)
+	relayRequests.WithLabelValues(model, endpoint, channel, group, status).Inc()
+	relayDuration.WithLabelValues(model, endpoint, channel, group, status).Observe(o.Duration.Seconds())
+	if o.FirstByte > 0 {
+		relayTTFT.WithLabelValues(model, endpoint, channel, group).Observe(o.FirstByte.Seconds())
+	}
+	if o.Retries > 0 {
+		relayFallbacks.WithLabelValues(model, endpoint, group).Add(float64(o.Retries))
+	}
+}
+
+// ObserveQuota records a settled request. preConsumed is what was reserved
+// before relaying; quota is the final charge.
+func ObserveQuota(model string, channel string, group string, preConsumed int64, quota int64) {
+	if !config.PrometheusEnabled {
+		return
+	}
+	model = labelValue(model)
+	channel = labelValue(channel)
+	group = labelValue(group)
+	if quota > 0 {
+		quotaConsumed.WithLabelValues(model, channel, group).Add(float64(quota))
+	}
+	if preConsumed > 0 {
+		quotaPreConsumed.WithLabelValues(model, channel, group).Add(float64(preConsumed))
+	}
+	quotaSettleDelta.WithLabelValues(model, group).Observe(float64(quota - preConsumed))
+}
+
+// Handler serves the registry. When config.PrometheusAuthToken is set the
+// scrape must carry it as a bearer token.
+func Handler() http.Handler {
+	Register()
+	next := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r, config.PrometheusAuthToken) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Serve exposes Handler on a listener of its own so scrapes stay off the
+// public port. The caller shuts the returned server down.
+func Serve(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		logger.SysLogf("metrics server started on %s", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.SysError("metrics server stopped: " + err.Error())
+		}
+	}()
+	return server
+}
+
+func authorized(r *http.Request, token string) bool {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return true
+	}
+	provided := strings.TrimSpace(r.Header.Get("Authorization"))
+	if len(provided) > 7 && strings.EqualFold(provided[:7], "bearer ") {
+		provided = strings.TrimSpace(provided[7:])
+	}
+	return subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
+
+func labelValue(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "unknown"
+	}
+	return value
+}
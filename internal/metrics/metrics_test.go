@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yeying-community/router/common/config"
+)
+
+func scrape(t *testing.T, handler http.Handler, authorization string) (int, string) {
+	t.Helper()
+	request := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if authorization != "" {
+		request.Header.Set("Authorization", authorization)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	body, _ := io.ReadAll(recorder.Body)
+	return recorder.Code, string(body)
+}
+
+func TestHandlerRequiresTokenAndExposesRelayMetrics(t *testing.T) {
+	previousEnabled, previousToken := config.PrometheusEnabled, config.PrometheusAuthToken
+	config.PrometheusEnabled = true
+	config.PrometheusAuthToken = "scrape-secret"
+	t.Cleanup(func() {
+		config.PrometheusEnabled = previousEnabled
+		config.PrometheusAuthToken = previousToken
+	})
+
+	ObserveRelay(RelayObservation{
+		Model:     "gpt-4o",
+		Endpoint:  "chat_completions",
+		Channel:   "channel-1",
+		Group:     "default",
+		Status:    "200",
+		Duration:  1500 * time.Millisecond,
+		FirstByte: 300 * time.Millisecond,
+		Retries:   2,
+	})
+	ObserveQuota("gpt-4o", "channel-1", "default", 1000, 400)
+
+	handler := Handler()
+	if code, _ := scrape(t, handler, ""); code != http.StatusUnauthorized {
+		t.Fatalf("scrape without token = %d, want 401", code)
+	}
+	if code, _ := scrape(t, handler, "Bearer wrong"); code != http.StatusUnauthorized {
+		t.Fatalf("scrape with a wrong token = %d, want 401", code)
+	}
+	code, body := scrape(t, handler, "Bearer scrape-secret")
+	if code != http.StatusOK {
+		t.Fatalf("scrape = %d, want 200", code)
+	}
+	for _, want := range []string{
+		`router_relay_requests_total{channel="channel-1",endpoint="chat_completions",group="default",model="gpt-4o",status="200"} 1`,
+		`router_relay_time_to_first_token_seconds_count{channel="channel-1",endpoint="chat_completions",group="default",model="gpt-4o"} 1`,
+		`router_relay_fallback_attempts_total{endpoint="chat_completions",group="default",model="gpt-4o"} 2`,
+		`router_billing_quota_consumed_total{channel="channel-1",group="default",model="gpt-4o"} 400`,
+		`router_billing_quota_pre_consumed_total{channel="channel-1",group="default",model="gpt-4o"} 1000`,
+		`router_billing_settle_delta_quota_bucket{group="default",model="gpt-4o",le="-100"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("scrape is missing %q", want)
+		}
+	}
+}
+
+func TestObserveIsNoopWhenDisabled(t *testing.T) {
+	previousEnabled := config.PrometheusEnabled
+	config.PrometheusEnabled = false
+	t.Cleanup(func() { config.PrometheusEnabled = previousEnabled })
+
+	ObserveRelay(RelayObservation{Model: "disabled-model", Status: "200"})
+	_, body := scrape(t, Handler(), "")
+	if strings.Contains(body, "disabled-model") {
+		t.Fatalf("disabled metrics should not record observations")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"strings"
 
@@ -14,6 +15,7 @@ import (
 	"github.com/yeying-community/router/internal/relay/meta"
 	"github.com/yeying-community/router/internal/relay/model"
 	"github.com/yeying-community/router/internal/relay/relaymode"
+	"github.com/yeying-community/router/internal/relay/structuredoutput"
 )
 
 type Adaptor struct {
@@ -29,6 +31,8 @@ func (a *Adaptor) GetRequestURL(meta *meta.Meta) (string, error) {
 	switch meta.Mode {
 	case relaymode.Embeddings:
 		fullRequestURL = fmt.Sprintf("%s/compatible-mode/v1/embeddings", meta.BaseURL)
+	case relaymode.Rerank:
+		fullRequestURL = fmt.Sprintf("%s/api/v1/services/rerank/text-rerank/text-rerank", meta.BaseURL)
 	case relaymode.Responses:
 		fullRequestURL = fmt.Sprintf("%s/compatible-mode/v1/responses", meta.BaseURL)
 	case relaymode.Realtime:
@@ -47,6 +51,12 @@ func (a *Adaptor) GetRequestURL(meta *meta.Meta) (string, error) {
 		} else {
 			fullRequestURL = openaiadaptor.GetFullRequestURL(meta.BaseURL, meta.RequestURLPath, relaychannel.OpenAI)
 		}
+	case relaymode.ImagesVariations:
+		if isQwenImageModel(meta.ActualModelName) {
+			fullRequestURL = fmt.Sprintf("%s/api/v1/services/aigc/multimodal-generation/generation", meta.BaseURL)
+		} else {
+			fullRequestURL = fmt.Sprintf("%s/api/v1/services/aigc/image2image/image-synthesis", meta.BaseURL)
+		}
 	case relaymode.Completions:
 		fullRequestURL = fmt.Sprintf("%s/compatible-mode/v1/completions", meta.BaseURL)
 	default:
@@ -58,7 +68,7 @@ func (a *Adaptor) GetRequestURL(meta *meta.Meta) (string, error) {
 
 func (a *Adaptor) SetupRequestHeader(c *gin.Context, req *http.Request, meta *meta.Meta) error {
 	adaptor.SetupCommonRequestHeader(c, req, meta)
-	if isQwenImageModel(meta.ActualModelName) && (meta.Mode == relaymode.ImagesGenerations || meta.Mode == relaymode.ImagesEdits) {
+	if meta.Mode == relaymode.ImagesVariations || (isQwenImageModel(meta.ActualModelName) && (meta.Mode == relaymode.ImagesGenerations || meta.Mode == relaymode.ImagesEdits)) {
 		req.Header.Set("Content-Type", "application/json")
 	}
 	if meta.IsStream {
@@ -67,7 +77,7 @@ func (a *Adaptor) SetupRequestHeader(c *gin.Context, req *http.Request, meta *me
 	}
 	req.Header.Set("Authorization", "Bearer "+meta.APIKey)
 
-	if meta.Mode == relaymode.ImagesGenerations && !isQwenImageModel(meta.ActualModelName) {
+	if (meta.Mode == relaymode.ImagesGenerations || meta.Mode == relaymode.ImagesVariations) && !isQwenImageModel(meta.ActualModelName) {
 		req.Header.Set("X-DashScope-Async", "enable")
 	}
 	if a.meta.Config.Plugin != "" {
@@ -85,6 +95,10 @@ func (a *Adaptor) ConvertRequest(c *gin.Context, relayMode int, request *model.G
 		aliRequest := ConvertRequest(*request)
 		return aliRequest, nil
 	default:
+		if relayMode == relaymode.ChatCompletions {
+			// DashScope compatible mode only offers json_object.
+			structuredoutput.DowngradeToJSONObject(request)
+		}
 		compatibleAdaptor := openaiadaptor.Adaptor{}
 		return compatibleAdaptor.ConvertRequest(c, relayMode, request)
 	}
@@ -101,13 +115,27 @@ func (a *Adaptor) ConvertImageRequest(request *model.ImageRequest) (any, error)
 	return ConvertImageRequest(*request), nil
 }
 
+func (a *Adaptor) ConvertImageVariationRequest(request *model.ImageRequest, image *multipart.FileHeader) (any, error) {
+	if request == nil {
+		return nil, errors.New("request is nil")
+	}
+	imageDataURI, err := adaptor.ReadMultipartImageDataURI(image)
+	if err != nil {
+		return nil, err
+	}
+	if isQwenImageModel(request.Model) || (a.meta != nil && isQwenImageModel(a.meta.ActualModelName)) {
+		return ConvertQwenImageVariationRequest(*request, imageDataURI), nil
+	}
+	return ConvertImageVariationRequest(*request, imageDataURI), nil
+}
+
 func (a *Adaptor) DoRequest(c *gin.Context, meta *meta.Meta, requestBody io.Reader) (*http.Response, error) {
 	return adaptor.DoRequestHelper(a, c, meta, requestBody)
 }
 
 func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, meta *meta.Meta) (usage *model.Usage, err *model.ErrorWithStatusCode) {
 	switch meta.Mode {
-	case relaymode.ImagesGenerations, relaymode.ImagesEdits:
+	case relaymode.ImagesGenerations, relaymode.ImagesEdits, relaymode.ImagesVariations:
 		if isQwenImageModel(meta.ActualModelName) {
 			err, usage = QwenImageHandler(c, resp)
 		} else {
@@ -128,6 +156,17 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, meta *meta.Met
 	return
 }
 
+func (a *Adaptor) ConvertRerankRequest(request *model.RerankRequest) (any, error) {
+	if request == nil {
+		return nil, errors.New("request is nil")
+	}
+	return ConvertRerankRequest(*request)
+}
+
+func (a *Adaptor) DoRerankResponse(resp *http.Response, meta *meta.Meta) (*model.RerankResponse, *model.ErrorWithStatusCode) {
+	return RerankHandler(resp)
+}
+
 func (a *Adaptor) GetModelList() []string {
 	return ModelList
 }
@@ -7,6 +7,7 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -205,6 +206,55 @@ func TestConvertQwenImageEditRequestUsesDataURIImageContent(t *testing.T) {
 	}
 }
 
+func TestConvertImageVariationRequestUsesImage2ImageEndpoint(t *testing.T) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("image", "test.png")
+	if err != nil {
+		t.Fatalf("CreateFormFile(image) error = %v", err)
+	}
+	if _, err := part.Write([]byte{0x89, 0x50, 0x4e, 0x47}); err != nil {
+		t.Fatalf("Write(image) error = %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("writer.Close() error = %v", err)
+	}
+	form, err := multipart.NewReader(bytes.NewReader(body.Bytes()), writer.Boundary()).ReadForm(32 << 20)
+	if err != nil {
+		t.Fatalf("ReadForm() error = %v", err)
+	}
+	defer form.RemoveAll()
+
+	variationMeta := &meta.Meta{
+		Mode:            relaymode.ImagesVariations,
+		BaseURL:         "https://dashscope.aliyuncs.com",
+		ActualModelName: "wanx2.1-imageedit",
+	}
+	adaptor := &Adaptor{}
+	adaptor.Init(variationMeta)
+	got, err := adaptor.GetRequestURL(variationMeta)
+	if err != nil {
+		t.Fatalf("GetRequestURL() error = %v", err)
+	}
+	if want := "https://dashscope.aliyuncs.com/api/v1/services/aigc/image2image/image-synthesis"; got != want {
+		t.Fatalf("GetRequestURL() = %q, want %q", got, want)
+	}
+	converted, err := adaptor.ConvertImageVariationRequest(&relaymodel.ImageRequest{Model: "wanx2.1-imageedit", N: 2}, form.File["image"][0])
+	if err != nil {
+		t.Fatalf("ConvertImageVariationRequest() error = %v", err)
+	}
+	variation, ok := converted.(*ImageVariationRequest)
+	if !ok {
+		t.Fatalf("converted type = %T, want *ImageVariationRequest", converted)
+	}
+	if variation.Input.Function != "description_edit" || variation.Input.Prompt != relaymodel.ImageVariationPrompt || variation.Parameters.N != 2 {
+		t.Fatalf("variation = %#v", variation)
+	}
+	if !strings.HasPrefix(variation.Input.BaseImageURL, "data:") || !strings.Contains(variation.Input.BaseImageURL, ";base64,") {
+		t.Fatalf("base_image_url = %q, want data uri", variation.Input.BaseImageURL)
+	}
+}
+
 func TestQwenImageHandlerWritesOpenAIImageResponse(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	recorder := httptest.NewRecorder()
@@ -299,3 +349,45 @@ func TestHandlerWritesActualModelName(t *testing.T) {
 		t.Fatalf("handler payload model = %q, want %q", payload.Model, "qwen-turbo-latest")
 	}
 }
+
+func TestRerankUsesDashScopeTextRerank(t *testing.T) {
+	adaptor := &Adaptor{}
+	got, err := adaptor.GetRequestURL(&meta.Meta{
+		Mode:    relaymode.Rerank,
+		BaseURL: "https://dashscope.aliyuncs.com",
+	})
+	if err != nil {
+		t.Fatalf("GetRequestURL() error = %v", err)
+	}
+	if want := "https://dashscope.aliyuncs.com/api/v1/services/rerank/text-rerank/text-rerank"; got != want {
+		t.Fatalf("GetRequestURL() = %q, want %q", got, want)
+	}
+
+	returnDocuments := true
+	converted, err := adaptor.ConvertRerankRequest(&relaymodel.RerankRequest{
+		Model:           "gte-rerank",
+		Query:           "router",
+		Documents:       []any{"a", map[string]any{"text": "b"}},
+		TopN:            1,
+		ReturnDocuments: &returnDocuments,
+	})
+	if err != nil {
+		t.Fatalf("ConvertRerankRequest() error = %v", err)
+	}
+	body, _ := json.Marshal(converted)
+	if want := `{"model":"gte-rerank","input":{"query":"router","documents":["a","b"]},"parameters":{"top_n":1,"return_documents":true}}`; string(body) != want {
+		t.Fatalf("converted body = %s, want %s", body, want)
+	}
+
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewBufferString(`{"request_id":"req_1","output":{"results":[{"index":1,"relevance_score":0.9}]},"usage":{"total_tokens":12}}`)),
+	}
+	rerankResponse, respErr := adaptor.DoRerankResponse(resp, &meta.Meta{})
+	if respErr != nil {
+		t.Fatalf("DoRerankResponse() error = %+v", respErr)
+	}
+	if rerankResponse.ID != "req_1" || len(rerankResponse.Results) != 1 || rerankResponse.Results[0].Index != 1 || rerankResponse.Usage == nil || rerankResponse.Usage.TotalTokens != 12 {
+		t.Fatalf("rerank response = %+v", rerankResponse)
+	}
+}
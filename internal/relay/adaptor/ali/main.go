@@ -3,10 +3,8 @@ package ali
 import (
 	"bufio"
 	"bytes"
-	"encoding/base64"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
@@ -19,6 +17,7 @@ import (
 	"github.com/yeying-community/router/common"
 	"github.com/yeying-community/router/common/helper"
 	"github.com/yeying-community/router/common/logger"
+	"github.com/yeying-community/router/internal/relay/adaptor"
 	"github.com/yeying-community/router/internal/relay/adaptor/openai"
 	"github.com/yeying-community/router/internal/relay/model"
 )
@@ -107,7 +106,7 @@ func ConvertQwenImageEditRequest(request model.ImageRequest, form *multipart.For
 	if len(files) == 0 {
 		return nil, errors.New("image file is required")
 	}
-	imageDataURI, err := readMultipartImageDataURI(files[0])
+	imageDataURI, err := adaptor.ReadMultipartImageDataURI(files[0])
 	if err != nil {
 		return nil, err
 	}
@@ -128,24 +127,34 @@ func ConvertQwenImageEditRequest(request model.ImageRequest, form *multipart.For
 	return &imageRequest, nil
 }
 
-func readMultipartImageDataURI(fileHeader *multipart.FileHeader) (string, error) {
-	if fileHeader == nil {
-		return "", errors.New("image file is required")
-	}
-	file, err := fileHeader.Open()
-	if err != nil {
-		return "", err
-	}
-	defer file.Close()
-	data, err := io.ReadAll(file)
-	if err != nil {
-		return "", err
-	}
-	contentType := strings.TrimSpace(fileHeader.Header.Get("Content-Type"))
-	if contentType == "" {
-		contentType = http.DetectContentType(data)
+// ConvertImageVariationRequest emulates a variation with the wanx
+// description_edit function, using the uploaded image as the base image.
+func ConvertImageVariationRequest(request model.ImageRequest, imageDataURI string) *ImageVariationRequest {
+	var imageRequest ImageVariationRequest
+	imageRequest.Model = request.Model
+	imageRequest.Input.Function = "description_edit"
+	imageRequest.Input.Prompt = model.ImageVariationPrompt
+	imageRequest.Input.BaseImageURL = imageDataURI
+	imageRequest.Parameters.N = request.N
+	imageRequest.ResponseFormat = request.ResponseFormat
+	return &imageRequest
+}
+
+func ConvertQwenImageVariationRequest(request model.ImageRequest, imageDataURI string) *QwenImageRequest {
+	var imageRequest QwenImageRequest
+	imageRequest.Model = request.Model
+	imageRequest.Input.Messages = []QwenImageMessage{
+		{
+			Role: "user",
+			Content: []QwenImageContent{
+				{Image: imageDataURI},
+				{Text: model.ImageVariationPrompt},
+			},
+		},
 	}
-	return fmt.Sprintf("data:%s;base64,%s", contentType, base64.StdEncoding.EncodeToString(data)), nil
+	imageRequest.Parameters.Size = strings.Replace(request.Size, "x", "*", -1)
+	imageRequest.ResponseFormat = request.ResponseFormat
+	return &imageRequest
 }
 
 func EmbeddingHandler(c *gin.Context, resp *http.Response) (*model.ErrorWithStatusCode, *model.Usage) {
@@ -0,0 +1,71 @@
+package ali
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/yeying-community/router/internal/relay/adaptor/openai"
+	"github.com/yeying-community/router/internal/relay/model"
+)
+
+type RerankRequest struct {
+	Model string `json:"model"`
+	Input struct {
+		Query     string   `json:"query"`
+		Documents []string `json:"documents"`
+	} `json:"input"`
+	Parameters struct {
+		TopN            int  `json:"top_n,omitempty"`
+		ReturnDocuments bool `json:"return_documents,omitempty"`
+	} `json:"parameters"`
+}
+
+type RerankResponse struct {
+	Output struct {
+		Results []model.RerankResult `json:"results"`
+	} `json:"output"`
+	Usage Usage `json:"usage"`
+	Error
+}
+
+func ConvertRerankRequest(request model.RerankRequest) (*RerankRequest, error) {
+	documents, err := request.DocumentTexts()
+	if err != nil {
+		return nil, err
+	}
+	aliRequest := &RerankRequest{Model: request.Model}
+	aliRequest.Input.Query = request.Query
+	aliRequest.Input.Documents = documents
+	aliRequest.Parameters.TopN = request.TopN
+	aliRequest.Parameters.ReturnDocuments = request.ShouldReturnDocuments()
+	return aliRequest, nil
+}
+
+func RerankHandler(resp *http.Response) (*model.RerankResponse, *model.ErrorWithStatusCode) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, openai.ErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError)
+	}
+	if err := resp.Body.Close(); err != nil {
+		return nil, openai.ErrorWrapper(err, "close_response_body_failed", http.StatusInternalServerError)
+	}
+	var aliResponse RerankResponse
+	if err := json.Unmarshal(responseBody, &aliResponse); err != nil {
+		return nil, openai.ErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError)
+	}
+	if aliResponse.Code != "" {
+		return nil, openai.ErrorWrapper(fmt.Errorf("%s: %s", aliResponse.Code, aliResponse.Message), aliResponse.Code, resp.StatusCode)
+	}
+	rerankResponse := &model.RerankResponse{
+		ID:      aliResponse.RequestId,
+		Results: aliResponse.Output.Results,
+		Usage: &model.RerankUsage{
+			PromptTokens: aliResponse.Usage.InputTokens,
+			TotalTokens:  aliResponse.Usage.TotalTokens,
+		},
+	}
+	rerankResponse.Normalize()
+	return rerankResponse, nil
+}
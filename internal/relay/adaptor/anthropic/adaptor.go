@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/yeying-community/router/common/ctxkey"
 	"github.com/yeying-community/router/common/logger"
 	"github.com/yeying-community/router/internal/relay/adaptor"
 	"github.com/yeying-community/router/internal/relay/adaptor/openai"
@@ -84,7 +85,11 @@ func (a *Adaptor) ConvertRequest(c *gin.Context, relayMode int, request *model.G
 	if relayMode != relaymode.Messages {
 		return request, nil
 	}
-	return ConvertRequest(*request), nil
+	claudeRequest := ConvertRequest(*request)
+	if toolName := claudeRequest.StructuredOutputTool(); toolName != "" {
+		c.Set(ctxkey.StructuredOutputTool, toolName)
+	}
+	return claudeRequest, nil
 }
 
 func (a *Adaptor) ConvertImageRequest(request *model.ImageRequest) (any, error) {
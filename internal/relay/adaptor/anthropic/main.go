@@ -12,11 +12,13 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/yeying-community/router/common"
+	"github.com/yeying-community/router/common/ctxkey"
 	"github.com/yeying-community/router/common/helper"
 	"github.com/yeying-community/router/common/image"
 	"github.com/yeying-community/router/common/logger"
 	"github.com/yeying-community/router/internal/relay/adaptor/openai"
 	"github.com/yeying-community/router/internal/relay/model"
+	"github.com/yeying-community/router/internal/relay/timing"
 )
 
 const anthropicScannerMaxTokenSize = 8 * 1024 * 1024
@@ -27,6 +29,11 @@ func newAnthropicStreamScanner(body io.Reader) *bufio.Scanner {
 	return scanner
 }
 
+type toolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
 func stopReasonClaude2OpenAI(reason *string) string {
 	if reason == nil {
 		return ""
@@ -75,6 +82,7 @@ func ConvertRequest(textRequest model.GeneralOpenAIRequest) *Request {
 				Properties: schemaProperties,
 				Required:   schemaRequired,
 			},
+			CacheControl: tool.CacheControl,
 		})
 	}
 
@@ -88,10 +96,7 @@ func ConvertRequest(textRequest model.GeneralOpenAIRequest) *Request {
 		Tools:       claudeTools,
 	}
 	if len(claudeTools) > 0 {
-		claudeToolChoice := struct {
-			Type string `json:"type"`
-			Name string `json:"name,omitempty"`
-		}{Type: "auto"}
+		claudeToolChoice := toolChoice{Type: "auto"}
 		if choice, ok := textRequest.ToolChoice.(map[string]any); ok {
 			if function, ok := choice["function"].(map[string]any); ok {
 				functionName := strings.TrimSpace(fmt.Sprint(function["name"]))
@@ -117,20 +122,26 @@ func ConvertRequest(textRequest model.GeneralOpenAIRequest) *Request {
 		}
 		claudeRequest.ToolChoice = claudeToolChoice
 	}
+	applyStructuredOutput(&claudeRequest, textRequest.ResponseFormat)
 	if stopSequences := parseStopSequences(textRequest.Stop); len(stopSequences) > 0 {
 		claudeRequest.StopSequences = stopSequences
 	}
+	maxTokensSet := claudeRequest.MaxTokens > 0
 	if claudeRequest.MaxTokens == 0 {
 		claudeRequest.MaxTokens = 4096
 	}
+	applyThinking(&claudeRequest, resolveThinking(textRequest), maxTokensSet)
 	for _, message := range textRequest.Messages {
-		if message.Role == "system" && claudeRequest.System == "" {
-			claudeRequest.System = message.StringContent()
+		if message.Role == "system" && claudeRequest.System == nil {
+			claudeRequest.System = convertSystem(message)
 			continue
 		}
 		claudeMessage := Message{
 			Role: message.Role,
 		}
+		if message.Role == "assistant" {
+			claudeMessage.Content = thinkingBlocksToContent(message.ThinkingBlocks)
+		}
 		var content Content
 		if message.IsStringContent() {
 			content.Type = "text"
@@ -156,10 +167,10 @@ func ConvertRequest(textRequest model.GeneralOpenAIRequest) *Request {
 			claudeRequest.Messages = append(claudeRequest.Messages, claudeMessage)
 			continue
 		}
-		var contents []Content
+		contents := claudeMessage.Content
 		openaiContent := message.ParseContent()
 		for _, part := range openaiContent {
-			var content Content
+			content := Content{CacheControl: part.CacheControl}
 			if part.Type == model.ContentTypeText {
 				content.Type = "text"
 				content.Text = part.Text
@@ -171,6 +182,17 @@ func ConvertRequest(textRequest model.GeneralOpenAIRequest) *Request {
 				mimeType, data, _ := image.GetImageFromUrl(part.ImageURL.Url)
 				content.Source.MediaType = mimeType
 				content.Source.Data = data
+			} else if part.Type == model.ContentTypeFile {
+				mediaType, data, ok := part.File.InlineData()
+				if !ok {
+					continue
+				}
+				content.Type = "document"
+				content.Source = &ImageSource{
+					Type:      "base64",
+					MediaType: mediaType,
+					Data:      data,
+				}
 			}
 			contents = append(contents, content)
 		}
@@ -180,6 +202,34 @@ func ConvertRequest(textRequest model.GeneralOpenAIRequest) *Request {
 	return &claudeRequest
 }
 
+func convertSystem(message model.Message) any {
+	if message.IsStringContent() {
+		if text := message.StringContent(); text != "" {
+			return text
+		}
+		return nil
+	}
+	parts := message.ParseContent()
+	blocks := make([]Content, 0, len(parts))
+	cached := false
+	for _, part := range parts {
+		if part.Type != model.ContentTypeText {
+			continue
+		}
+		if part.CacheControl != nil {
+			cached = true
+		}
+		blocks = append(blocks, Content{Type: "text", Text: part.Text, CacheControl: part.CacheControl})
+	}
+	if cached {
+		return blocks
+	}
+	if text := message.StringContent(); text != "" {
+		return text
+	}
+	return nil
+}
+
 func parseStopSequences(stop any) []string {
 	switch value := stop.(type) {
 	case string:
@@ -216,8 +266,10 @@ func parseStopSequences(stop any) []string {
 func StreamResponseClaude2OpenAI(claudeResponse *StreamResponse) (*openai.ChatCompletionsStreamResponse, *Response) {
 	var response *Response
 	var responseText string
+	var reasoningText string
 	var stopReason string
 	tools := make([]model.Tool, 0)
+	thinkingBlocks := make([]model.ThinkingBlock, 0)
 
 	switch claudeResponse.Type {
 	case "message_start":
@@ -225,6 +277,12 @@ func StreamResponseClaude2OpenAI(claudeResponse *StreamResponse) (*openai.ChatCo
 	case "content_block_start":
 		if claudeResponse.ContentBlock != nil {
 			responseText = claudeResponse.ContentBlock.Text
+			if claudeResponse.ContentBlock.Type == "redacted_thinking" {
+				thinkingBlocks = append(thinkingBlocks, model.ThinkingBlock{
+					Type: "redacted_thinking",
+					Data: claudeResponse.ContentBlock.Data,
+				})
+			}
 			if claudeResponse.ContentBlock.Type == "tool_use" {
 				tools = append(tools, model.Tool{
 					Id:   claudeResponse.ContentBlock.Id,
@@ -239,6 +297,15 @@ func StreamResponseClaude2OpenAI(claudeResponse *StreamResponse) (*openai.ChatCo
 	case "content_block_delta":
 		if claudeResponse.Delta != nil {
 			responseText = claudeResponse.Delta.Text
+			switch claudeResponse.Delta.Type {
+			case "thinking_delta":
+				reasoningText = claudeResponse.Delta.Thinking
+			case "signature_delta":
+				thinkingBlocks = append(thinkingBlocks, model.ThinkingBlock{
+					Type:      "thinking",
+					Signature: claudeResponse.Delta.Signature,
+				})
+			}
 			if claudeResponse.Delta.Type == "input_json_delta" {
 				tools = append(tools, model.Tool{
 					Function: model.Function{
@@ -259,6 +326,12 @@ func StreamResponseClaude2OpenAI(claudeResponse *StreamResponse) (*openai.ChatCo
 	}
 	var choice openai.ChatCompletionsStreamResponseChoice
 	choice.Delta.Content = responseText
+	if reasoningText != "" {
+		choice.Delta.ReasoningContent = reasoningText
+	}
+	if len(thinkingBlocks) > 0 {
+		choice.Delta.ThinkingBlocks = thinkingBlocks
+	}
 	if len(tools) > 0 {
 		choice.Delta.Content = nil // compatible with other OpenAI derivative applications, like LobeOpenAICompatibleFactory ...
 		choice.Delta.ToolCalls = tools
@@ -276,11 +349,26 @@ func StreamResponseClaude2OpenAI(claudeResponse *StreamResponse) (*openai.ChatCo
 
 func ResponseClaude2OpenAI(claudeResponse *Response) *openai.TextResponse {
 	var responseText string
-	if len(claudeResponse.Content) > 0 {
-		responseText = claudeResponse.Content[0].Text
-	}
+	var reasoningText string
+	thinkingBlocks := make([]model.ThinkingBlock, 0)
 	tools := make([]model.Tool, 0)
 	for _, v := range claudeResponse.Content {
+		switch v.Type {
+		case "text":
+			responseText += v.Text
+		case "thinking":
+			reasoningText += v.Thinking
+			thinkingBlocks = append(thinkingBlocks, model.ThinkingBlock{
+				Type:      "thinking",
+				Thinking:  v.Thinking,
+				Signature: v.Signature,
+			})
+		case "redacted_thinking":
+			thinkingBlocks = append(thinkingBlocks, model.ThinkingBlock{
+				Type: "redacted_thinking",
+				Data: v.Data,
+			})
+		}
 		if v.Type == "tool_use" {
 			args, _ := json.Marshal(v.Input)
 			tools = append(tools, model.Tool{
@@ -303,6 +391,12 @@ func ResponseClaude2OpenAI(claudeResponse *Response) *openai.TextResponse {
 		},
 		FinishReason: stopReasonClaude2OpenAI(claudeResponse.StopReason),
 	}
+	if reasoningText != "" {
+		choice.Message.ReasoningContent = reasoningText
+	}
+	if len(thinkingBlocks) > 0 {
+		choice.Message.ThinkingBlocks = thinkingBlocks
+	}
 	fullTextResponse := openai.TextResponse{
 		Id:      fmt.Sprintf("chatcmpl-%s", claudeResponse.Id),
 		Model:   claudeResponse.Model,
@@ -331,10 +425,12 @@ func StreamHandler(c *gin.Context, resp *http.Response) (*model.ErrorWithStatusC
 
 	common.SetEventStreamHeaders(c)
 
-	var usage model.Usage
+	var claudeUsage Usage
 	var modelName string
 	var id string
 	var lastToolCallChoice openai.ChatCompletionsStreamResponseChoice
+	structuredOutput := NewStructuredOutputStream(c.GetString(ctxkey.StructuredOutputTool))
+	thinking := NewThinkingStream()
 
 	for scanner.Scan() {
 		data := scanner.Text()
@@ -353,12 +449,7 @@ func StreamHandler(c *gin.Context, resp *http.Response) (*model.ErrorWithStatusC
 
 		response, meta := StreamResponseClaude2OpenAI(&claudeResponse)
 		if meta != nil {
-			if meta.Usage.InputTokens > usage.PromptTokens {
-				usage.PromptTokens = meta.Usage.InputTokens
-			}
-			if meta.Usage.OutputTokens > usage.CompletionTokens {
-				usage.CompletionTokens = meta.Usage.OutputTokens
-			}
+			mergeUsage(&claudeUsage, meta.Usage)
 			if len(meta.Id) > 0 { // only message_start has an id, otherwise it's a finish_reason event.
 				modelName = meta.Model
 				id = fmt.Sprintf("chatcmpl-%s", meta.Id)
@@ -377,6 +468,8 @@ func StreamHandler(c *gin.Context, resp *http.Response) (*model.ErrorWithStatusC
 		if response == nil {
 			continue
 		}
+		structuredOutput.Rewrite(&claudeResponse, response)
+		thinking.Rewrite(&claudeResponse, response)
 
 		response.Id = id
 		response.Model = modelName
@@ -387,6 +480,9 @@ func StreamHandler(c *gin.Context, resp *http.Response) (*model.ErrorWithStatusC
 				lastToolCallChoice = choice
 			}
 		}
+		if response.HasOutput() {
+			timing.MarkFirstToken(c)
+		}
 		err = render.ObjectData(c, response)
 		if err != nil {
 			logger.SysError(err.Error())
@@ -408,7 +504,7 @@ func StreamHandler(c *gin.Context, resp *http.Response) (*model.ErrorWithStatusC
 	if err != nil {
 		return openai.ErrorWrapper(err, "close_response_body_failed", http.StatusInternalServerError), nil
 	}
-	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	usage := UsageClaude2OpenAI(claudeUsage)
 	return nil, &usage
 }
 
@@ -438,12 +534,9 @@ func Handler(c *gin.Context, resp *http.Response, promptTokens int, modelName st
 		}, nil
 	}
 	fullTextResponse := ResponseClaude2OpenAI(&claudeResponse)
+	UnwrapStructuredOutput(fullTextResponse, c.GetString(ctxkey.StructuredOutputTool))
 	fullTextResponse.Model = modelName
-	usage := model.Usage{
-		PromptTokens:     claudeResponse.Usage.InputTokens,
-		CompletionTokens: claudeResponse.Usage.OutputTokens,
-		TotalTokens:      claudeResponse.Usage.InputTokens + claudeResponse.Usage.OutputTokens,
-	}
+	usage := UsageClaude2OpenAI(claudeResponse.Usage)
 	fullTextResponse.Usage = usage
 	jsonResponse, err := json.Marshal(fullTextResponse)
 	if err != nil {
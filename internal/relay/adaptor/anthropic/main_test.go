@@ -0,0 +1,27 @@
+package anthropic
+
+import (
+	"testing"
+
+	"github.com/yeying-community/router/internal/relay/model"
+)
+
+func TestConvertRequestMapsInlineFilesToDocuments(t *testing.T) {
+	request := ConvertRequest(model.GeneralOpenAIRequest{
+		Model: "claude-sonnet-4-5",
+		Messages: []model.Message{{Role: "user", Content: []any{
+			map[string]any{"type": "text", "text": "summarize"},
+			map[string]any{"type": "file", "file": map[string]any{"filename": "a.pdf", "file_data": "data:application/pdf;base64,JVBERi0="}},
+			map[string]any{"type": "file", "file": map[string]any{"file_id": "file-unresolved"}},
+		}}},
+	})
+
+	content := request.Messages[0].Content
+	if len(content) != 2 {
+		t.Fatalf("content = %#v, want text and document only", content)
+	}
+	document := content[1]
+	if document.Type != "document" || document.Source == nil || document.Source.MediaType != "application/pdf" || document.Source.Data != "JVBERi0=" {
+		t.Fatalf("document = %#v, want base64 pdf document", document)
+	}
+}
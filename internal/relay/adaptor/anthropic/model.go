@@ -20,6 +20,11 @@ type Content struct {
 	Input     any    `json:"input,omitempty"`
 	Content   string `json:"content,omitempty"`
 	ToolUseId string `json:"tool_use_id,omitempty"`
+	// thinking / redacted_thinking
+	Thinking     string `json:"thinking,omitempty"`
+	Signature    string `json:"signature,omitempty"`
+	Data         string `json:"data,omitempty"`
+	CacheControl any    `json:"cache_control,omitempty"`
 }
 
 type Message struct {
@@ -28,21 +33,30 @@ type Message struct {
 }
 
 type Tool struct {
-	Name        string      `json:"name"`
-	Description string      `json:"description,omitempty"`
-	InputSchema InputSchema `json:"input_schema"`
+	Name         string      `json:"name"`
+	Description  string      `json:"description,omitempty"`
+	InputSchema  InputSchema `json:"input_schema"`
+	CacheControl any         `json:"cache_control,omitempty"`
 }
 
 type InputSchema struct {
-	Type       string `json:"type"`
-	Properties any    `json:"properties,omitempty"`
-	Required   any    `json:"required,omitempty"`
+	Type                 string `json:"type"`
+	Properties           any    `json:"properties,omitempty"`
+	Required             any    `json:"required,omitempty"`
+	AdditionalProperties any    `json:"additionalProperties,omitempty"`
+	Defs                 any    `json:"$defs,omitempty"`
+	Definitions          any    `json:"definitions,omitempty"`
+}
+
+type Thinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens,omitempty"`
 }
 
 type Request struct {
 	Model         string    `json:"model"`
 	Messages      []Message `json:"messages"`
-	System        string    `json:"system,omitempty"`
+	System        any       `json:"system,omitempty"` // string, or []Content when cache_control is set
 	MaxTokens     int       `json:"max_tokens,omitempty"`
 	StopSequences []string  `json:"stop_sequences,omitempty"`
 	Stream        bool      `json:"stream,omitempty"`
@@ -51,12 +65,17 @@ type Request struct {
 	TopK          int       `json:"top_k,omitempty"`
 	Tools         []Tool    `json:"tools,omitempty"`
 	ToolChoice    any       `json:"tool_choice,omitempty"`
+	Thinking      *Thinking `json:"thinking,omitempty"`
 	//Metadata    `json:"metadata,omitempty"`
+
+	structuredOutputTool string
 }
 
 type Usage struct {
-	InputTokens  int `json:"input_tokens"`
-	OutputTokens int `json:"output_tokens"`
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
 }
 
 type Error struct {
@@ -80,6 +99,8 @@ type Delta struct {
 	Type         string  `json:"type"`
 	Text         string  `json:"text"`
 	PartialJson  string  `json:"partial_json,omitempty"`
+	Thinking     string  `json:"thinking,omitempty"`
+	Signature    string  `json:"signature,omitempty"`
 	StopReason   *string `json:"stop_reason"`
 	StopSequence *string `json:"stop_sequence"`
 }
@@ -0,0 +1,133 @@
+package anthropic
+
+import (
+	"regexp"
+
+	"github.com/yeying-community/router/internal/relay/adaptor/openai"
+	"github.com/yeying-community/router/internal/relay/model"
+	"github.com/yeying-community/router/internal/relay/structuredoutput"
+)
+
+var invalidToolNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// StructuredOutputTool returns the name of the tool that carries a
+// response_format json_schema, or "" when the request has none.
+func (r *Request) StructuredOutputTool() string {
+	return r.structuredOutputTool
+}
+
+// applyStructuredOutput emulates response_format json_schema, which Claude
+// has no native field for, by forcing a tool whose input_schema is the
+// requested schema. The tool call is unwrapped back into message content on
+// the way out.
+func applyStructuredOutput(claudeRequest *Request, format *model.ResponseFormat) {
+	schema := structuredoutput.Schema(format)
+	if schema == nil {
+		return
+	}
+	name := invalidToolNameChars.ReplaceAllString(structuredoutput.SchemaName(format), "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	description := "Respond to the user with a JSON object matching this schema."
+	if format.JsonSchema.Description != "" {
+		description = format.JsonSchema.Description
+	}
+	inputSchema := InputSchema{
+		Type:                 "object",
+		Properties:           schema["properties"],
+		Required:             schema["required"],
+		AdditionalProperties: schema["additionalProperties"],
+		Defs:                 schema["$defs"],
+		Definitions:          schema["definitions"],
+	}
+	userTools := len(claudeRequest.Tools)
+	claudeRequest.Tools = append(claudeRequest.Tools, Tool{
+		Name:        name,
+		Description: description,
+		InputSchema: inputSchema,
+	})
+	claudeRequest.structuredOutputTool = name
+	if userTools == 0 {
+		claudeRequest.ToolChoice = toolChoice{Type: "tool", Name: name}
+		return
+	}
+	if choice, ok := claudeRequest.ToolChoice.(toolChoice); !ok || choice.Type != "tool" {
+		claudeRequest.ToolChoice = toolChoice{Type: "any"}
+	}
+}
+
+// UnwrapStructuredOutput moves the arguments of the structured output tool
+// call into the message content, so clients see a regular json_schema reply.
+func UnwrapStructuredOutput(response *openai.TextResponse, toolName string) {
+	if response == nil || toolName == "" {
+		return
+	}
+	for i := range response.Choices {
+		choice := &response.Choices[i]
+		remaining := make([]model.Tool, 0, len(choice.Message.ToolCalls))
+		for _, tool := range choice.Message.ToolCalls {
+			if tool.Function.Name != toolName {
+				remaining = append(remaining, tool)
+				continue
+			}
+			choice.Message.Content = tool.Function.Arguments
+		}
+		if len(remaining) == len(choice.Message.ToolCalls) {
+			continue
+		}
+		choice.Message.ToolCalls = remaining
+		if len(remaining) == 0 && choice.FinishReason == "tool_calls" {
+			choice.FinishReason = "stop"
+		}
+	}
+}
+
+// StructuredOutputStream does the same as UnwrapStructuredOutput for
+// streamed responses, where the tool arguments arrive as input_json_delta
+// events addressed by content block index.
+type StructuredOutputStream struct {
+	toolName   string
+	blockIndex int
+	active     bool
+	otherTools bool
+}
+
+func NewStructuredOutputStream(toolName string) *StructuredOutputStream {
+	if toolName == "" {
+		return nil
+	}
+	return &StructuredOutputStream{toolName: toolName, blockIndex: -1}
+}
+
+func (s *StructuredOutputStream) Rewrite(event *StreamResponse, response *openai.ChatCompletionsStreamResponse) {
+	if s == nil || event == nil || response == nil || len(response.Choices) == 0 {
+		return
+	}
+	choice := &response.Choices[len(response.Choices)-1]
+	switch event.Type {
+	case "content_block_start":
+		if event.ContentBlock == nil || event.ContentBlock.Type != "tool_use" {
+			return
+		}
+		if event.ContentBlock.Name != s.toolName {
+			s.otherTools = true
+			return
+		}
+		s.blockIndex = event.Index
+		s.active = true
+		choice.Delta.ToolCalls = nil
+		choice.Delta.Content = ""
+	case "content_block_delta":
+		if !s.active || event.Index != s.blockIndex || event.Delta == nil || event.Delta.Type != "input_json_delta" {
+			return
+		}
+		choice.Delta.ToolCalls = nil
+		choice.Delta.Content = event.Delta.PartialJson
+	case "message_delta":
+		if s.active && !s.otherTools && choice.FinishReason != nil && *choice.FinishReason == "tool_calls" {
+			finishReason := "stop"
+			choice.FinishReason = &finishReason
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package anthropic
+
+import (
+	"testing"
+
+	"github.com/yeying-community/router/internal/relay/adaptor/openai"
+	"github.com/yeying-community/router/internal/relay/model"
+)
+
+func TestConvertRequestForcesStructuredOutputTool(t *testing.T) {
+	request := ConvertRequest(model.GeneralOpenAIRequest{
+		Model:    "claude-sonnet-4-5",
+		Messages: []model.Message{{Role: "user", Content: "hi"}},
+		ResponseFormat: &model.ResponseFormat{
+			Type: "json_schema",
+			JsonSchema: &model.JSONSchema{
+				Name: "person.v1",
+				Schema: map[string]any{
+					"type":                 "object",
+					"properties":           map[string]any{"name": map[string]any{"type": "string"}},
+					"required":             []any{"name"},
+					"additionalProperties": false,
+				},
+			},
+		},
+	})
+
+	if request.StructuredOutputTool() != "person_v1" {
+		t.Fatalf("StructuredOutputTool() = %q, want sanitized schema name", request.StructuredOutputTool())
+	}
+	if len(request.Tools) != 1 || request.Tools[0].InputSchema.AdditionalProperties != false {
+		t.Fatalf("tools = %#v, want schema tool", request.Tools)
+	}
+	if choice, ok := request.ToolChoice.(toolChoice); !ok || choice.Type != "tool" || choice.Name != "person_v1" {
+		t.Fatalf("tool_choice = %#v, want forced schema tool", request.ToolChoice)
+	}
+}
+
+func TestUnwrapStructuredOutputMovesArgumentsIntoContent(t *testing.T) {
+	response := &openai.TextResponse{Choices: []openai.TextResponseChoice{{
+		Message: model.Message{
+			Role:      "assistant",
+			Content:   "",
+			ToolCalls: []model.Tool{{Id: "toolu_1", Type: "function", Function: model.Function{Name: "person", Arguments: `{"name":"x"}`}}},
+		},
+		FinishReason: "tool_calls",
+	}}}
+
+	UnwrapStructuredOutput(response, "person")
+
+	choice := response.Choices[0]
+	if choice.Message.Content != `{"name":"x"}` || len(choice.Message.ToolCalls) != 0 || choice.FinishReason != "stop" {
+		t.Fatalf("choice = %#v, want JSON content and stop", choice)
+	}
+}
+
+func TestStructuredOutputStreamRewritesToolDeltas(t *testing.T) {
+	stream := NewStructuredOutputStream("person")
+	events := []*StreamResponse{
+		{Type: "content_block_start", Index: 0, ContentBlock: &Content{Type: "tool_use", Id: "toolu_1", Name: "person"}},
+		{Type: "content_block_delta", Index: 0, Delta: &Delta{Type: "input_json_delta", PartialJson: `{"name":`}},
+		{Type: "message_delta", Delta: &Delta{StopReason: stringPtr("tool_use")}},
+	}
+
+	var content string
+	var finishReason string
+	for _, event := range events {
+		response, _ := StreamResponseClaude2OpenAI(event)
+		stream.Rewrite(event, response)
+		delta := response.Choices[0].Delta
+		if len(delta.ToolCalls) != 0 {
+			t.Fatalf("delta = %#v, want schema tool hidden", delta)
+		}
+		if text, ok := delta.Content.(string); ok {
+			content += text
+		}
+		if response.Choices[0].FinishReason != nil {
+			finishReason = *response.Choices[0].FinishReason
+		}
+	}
+	if content != `{"name":` || finishReason != "stop" {
+		t.Fatalf("content = %q finish_reason = %q, want streamed JSON and stop", content, finishReason)
+	}
+}
+
+func stringPtr(value string) *string {
+	return &value
+}
@@ -0,0 +1,179 @@
+package anthropic
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yeying-community/router/internal/relay/adaptor/openai"
+	"github.com/yeying-community/router/internal/relay/model"
+)
+
+const (
+	thinkingMinBudgetTokens     = 1024
+	thinkingDefaultAnswerTokens = 4096
+)
+
+var reasoningEffortBudgetTokens = map[string]int{
+	"minimal": 1024,
+	"low":     2048,
+	"medium":  8192,
+	"high":    24576,
+}
+
+// resolveThinking maps an explicit Claude `thinking` object or an OpenAI
+// `reasoning_effort` onto Claude's extended thinking config.
+func resolveThinking(textRequest model.GeneralOpenAIRequest) *Thinking {
+	if thinking, ok := textRequest.Thinking.(map[string]any); ok {
+		thinkingType := strings.ToLower(strings.TrimSpace(fmt.Sprint(thinking["type"])))
+		switch thinkingType {
+		case "disabled":
+			return nil
+		case "enabled":
+			budget := 0
+			switch value := thinking["budget_tokens"].(type) {
+			case float64:
+				budget = int(value)
+			case int:
+				budget = value
+			}
+			if budget < thinkingMinBudgetTokens {
+				budget = thinkingMinBudgetTokens
+			}
+			return &Thinking{Type: "enabled", BudgetTokens: budget}
+		}
+	}
+	if textRequest.ReasoningEffort == nil {
+		return nil
+	}
+	budget, ok := reasoningEffortBudgetTokens[strings.ToLower(strings.TrimSpace(*textRequest.ReasoningEffort))]
+	if !ok {
+		return nil
+	}
+	return &Thinking{Type: "enabled", BudgetTokens: budget}
+}
+
+// applyThinking enables extended thinking on the request and adjusts the
+// parameters Claude rejects alongside it: max_tokens must exceed the budget,
+// sampling overrides are not allowed and tools cannot be forced.
+func applyThinking(claudeRequest *Request, thinking *Thinking, maxTokensSet bool) {
+	if thinking == nil {
+		return
+	}
+	if choice, ok := claudeRequest.ToolChoice.(toolChoice); ok && (choice.Type == "any" || choice.Type == "tool") {
+		return
+	}
+	claudeRequest.Thinking = thinking
+	if claudeRequest.MaxTokens <= thinking.BudgetTokens {
+		answerTokens := thinkingDefaultAnswerTokens
+		if maxTokensSet {
+			answerTokens = claudeRequest.MaxTokens
+		}
+		claudeRequest.MaxTokens = thinking.BudgetTokens + answerTokens
+	}
+	claudeRequest.Temperature = nil
+	claudeRequest.TopP = nil
+	claudeRequest.TopK = 0
+}
+
+func thinkingBlocksToContent(blocks []model.ThinkingBlock) []Content {
+	contents := make([]Content, 0, len(blocks))
+	for _, block := range blocks {
+		switch block.Type {
+		case "thinking":
+			if block.Signature == "" {
+				continue
+			}
+			contents = append(contents, Content{Type: "thinking", Thinking: block.Thinking, Signature: block.Signature})
+		case "redacted_thinking":
+			if block.Data == "" {
+				continue
+			}
+			contents = append(contents, Content{Type: "redacted_thinking", Data: block.Data})
+		}
+	}
+	return contents
+}
+
+// ThinkingStream collects the thinking deltas of each streamed block so the
+// block emitted with its signature carries the full text; Anthropic rejects a
+// signed thinking block replayed without it.
+type ThinkingStream struct {
+	thinking map[int]*strings.Builder
+}
+
+func NewThinkingStream() *ThinkingStream {
+	return &ThinkingStream{thinking: map[int]*strings.Builder{}}
+}
+
+func (s *ThinkingStream) Rewrite(event *StreamResponse, response *openai.ChatCompletionsStreamResponse) {
+	if s == nil || event == nil {
+		return
+	}
+	switch event.Type {
+	case "content_block_start":
+		if event.ContentBlock != nil && event.ContentBlock.Type == "thinking" {
+			builder := &strings.Builder{}
+			builder.WriteString(event.ContentBlock.Thinking)
+			s.thinking[event.Index] = builder
+		}
+	case "content_block_delta":
+		if event.Delta == nil {
+			return
+		}
+		switch event.Delta.Type {
+		case "thinking_delta":
+			builder, ok := s.thinking[event.Index]
+			if !ok {
+				builder = &strings.Builder{}
+				s.thinking[event.Index] = builder
+			}
+			builder.WriteString(event.Delta.Thinking)
+		case "signature_delta":
+			if response == nil || len(response.Choices) == 0 {
+				return
+			}
+			blocks := response.Choices[len(response.Choices)-1].Delta.ThinkingBlocks
+			if builder, ok := s.thinking[event.Index]; ok && len(blocks) > 0 {
+				blocks[len(blocks)-1].Thinking = builder.String()
+			}
+		}
+	case "content_block_stop":
+		delete(s.thinking, event.Index)
+	}
+}
+
+// UsageClaude2OpenAI folds Claude's separately reported cache tokens back
+// into prompt_tokens so billing can price them as cache components.
+func UsageClaude2OpenAI(usage Usage) model.Usage {
+	promptTokens := usage.InputTokens + usage.CacheCreationInputTokens + usage.CacheReadInputTokens
+	result := model.Usage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: usage.OutputTokens,
+		TotalTokens:      promptTokens + usage.OutputTokens,
+	}
+	if usage.CacheCreationInputTokens > 0 || usage.CacheReadInputTokens > 0 {
+		result.PromptTokensDetails = &model.PromptTokensDetails{
+			CachedTokens:        usage.CacheReadInputTokens,
+			CacheReadTokens:     usage.CacheReadInputTokens,
+			CacheCreationTokens: usage.CacheCreationInputTokens,
+		}
+	}
+	return result
+}
+
+// mergeUsage keeps the largest value seen for every counter; Claude streams
+// report cumulative usage across message_start and message_delta.
+func mergeUsage(target *Usage, usage Usage) {
+	if usage.InputTokens > target.InputTokens {
+		target.InputTokens = usage.InputTokens
+	}
+	if usage.OutputTokens > target.OutputTokens {
+		target.OutputTokens = usage.OutputTokens
+	}
+	if usage.CacheCreationInputTokens > target.CacheCreationInputTokens {
+		target.CacheCreationInputTokens = usage.CacheCreationInputTokens
+	}
+	if usage.CacheReadInputTokens > target.CacheReadInputTokens {
+		target.CacheReadInputTokens = usage.CacheReadInputTokens
+	}
+}
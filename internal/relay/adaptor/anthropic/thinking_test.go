@@ -0,0 +1,165 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/yeying-community/router/internal/relay/model"
+)
+
+func TestConvertRequestMapsReasoningEffortToThinking(t *testing.T) {
+	effort := "medium"
+	temperature := 0.3
+	request := ConvertRequest(model.GeneralOpenAIRequest{
+		Model:           "claude-sonnet-4-5",
+		MaxTokens:       1000,
+		ReasoningEffort: &effort,
+		Temperature:     &temperature,
+		Messages:        []model.Message{{Role: "user", Content: "hi"}},
+	})
+
+	if request.Thinking == nil || request.Thinking.Type != "enabled" || request.Thinking.BudgetTokens != 8192 {
+		t.Fatalf("thinking = %#v, want enabled with medium budget", request.Thinking)
+	}
+	if request.MaxTokens != 8192+1000 {
+		t.Fatalf("max_tokens = %d, want budget plus requested answer tokens", request.MaxTokens)
+	}
+	if request.Temperature != nil {
+		t.Fatalf("temperature = %v, want dropped while thinking", *request.Temperature)
+	}
+}
+
+func TestConvertRequestKeepsExplicitThinkingAndSkipsForcedTools(t *testing.T) {
+	request := ConvertRequest(model.GeneralOpenAIRequest{
+		Model:    "claude-sonnet-4-5",
+		Thinking: map[string]any{"type": "enabled", "budget_tokens": float64(2000)},
+		Messages: []model.Message{{Role: "user", Content: "hi"}},
+	})
+	if request.Thinking == nil || request.Thinking.BudgetTokens != 2000 || request.MaxTokens != 4096 {
+		t.Fatalf("thinking = %#v max_tokens = %d, want explicit budget under default max_tokens", request.Thinking, request.MaxTokens)
+	}
+
+	effort := "high"
+	forced := ConvertRequest(model.GeneralOpenAIRequest{
+		Model:           "claude-sonnet-4-5",
+		ReasoningEffort: &effort,
+		Tools:           []model.Tool{{Type: "function", Function: model.Function{Name: "f"}}},
+		ToolChoice:      "required",
+		Messages:        []model.Message{{Role: "user", Content: "hi"}},
+	})
+	if forced.Thinking != nil {
+		t.Fatalf("thinking = %#v, want disabled when a tool is forced", forced.Thinking)
+	}
+}
+
+func TestConvertRequestReplaysSignedThinkingAndCacheControl(t *testing.T) {
+	request := ConvertRequest(model.GeneralOpenAIRequest{
+		Model: "claude-sonnet-4-5",
+		Messages: []model.Message{
+			{Role: "system", Content: []any{map[string]any{"type": "text", "text": "long prompt", "cache_control": map[string]any{"type": "ephemeral"}}}},
+			{Role: "user", Content: "hi"},
+			{
+				Role:             "assistant",
+				Content:          "hello",
+				ReasoningContent: "thought",
+				ThinkingBlocks: []model.ThinkingBlock{
+					{Type: "thinking", Thinking: "thought", Signature: "sig"},
+					{Type: "thinking", Thinking: "unsigned"},
+				},
+			},
+		},
+		Tools: []model.Tool{{Type: "function", Function: model.Function{Name: "f"}, CacheControl: map[string]any{"type": "ephemeral"}}},
+	})
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		t.Fatalf("marshal request: %v", err)
+	}
+	if !strings.Contains(string(body), `"system":[{"type":"text","text":"long prompt","cache_control":{"type":"ephemeral"}}]`) {
+		t.Fatalf("body = %s, want cached system block", body)
+	}
+	if !strings.Contains(string(body), `"input_schema":{"type":"object"},"cache_control":{"type":"ephemeral"}`) {
+		t.Fatalf("body = %s, want cached tool", body)
+	}
+	assistant := request.Messages[1]
+	if len(assistant.Content) != 2 || assistant.Content[0].Type != "thinking" || assistant.Content[0].Signature != "sig" || assistant.Content[1].Text != "hello" {
+		t.Fatalf("assistant content = %#v, want signed thinking block before text", assistant.Content)
+	}
+}
+
+func TestResponseClaude2OpenAISurfacesThinking(t *testing.T) {
+	response := ResponseClaude2OpenAI(&Response{
+		Id: "msg_1",
+		Content: []Content{
+			{Type: "thinking", Thinking: "let me think", Signature: "sig"},
+			{Type: "redacted_thinking", Data: "opaque"},
+			{Type: "text", Text: "answer"},
+		},
+	})
+
+	message := response.Choices[0].Message
+	if message.Content != "answer" || message.ReasoningContent != "let me think" {
+		t.Fatalf("message = %#v, want text content and reasoning_content", message)
+	}
+	if len(message.ThinkingBlocks) != 2 || message.ThinkingBlocks[0].Signature != "sig" || message.ThinkingBlocks[1].Data != "opaque" {
+		t.Fatalf("thinking_blocks = %#v, want signed and redacted blocks", message.ThinkingBlocks)
+	}
+}
+
+func TestStreamResponseClaude2OpenAIConvertsThinkingDeltas(t *testing.T) {
+	thinking, _ := StreamResponseClaude2OpenAI(&StreamResponse{
+		Type:  "content_block_delta",
+		Delta: &Delta{Type: "thinking_delta", Thinking: "hmm"},
+	})
+	if thinking.Choices[0].Delta.ReasoningContent != "hmm" {
+		t.Fatalf("delta = %#v, want reasoning_content", thinking.Choices[0].Delta)
+	}
+
+	signature, _ := StreamResponseClaude2OpenAI(&StreamResponse{
+		Type:  "content_block_delta",
+		Delta: &Delta{Type: "signature_delta", Signature: "sig"},
+	})
+	blocks := signature.Choices[0].Delta.ThinkingBlocks
+	if len(blocks) != 1 || blocks[0].Type != "thinking" || blocks[0].Signature != "sig" {
+		t.Fatalf("thinking_blocks = %#v, want signature block", blocks)
+	}
+}
+
+func TestThinkingStreamSignsTheCollectedThinkingBlock(t *testing.T) {
+	stream := NewThinkingStream()
+	var blocks []model.ThinkingBlock
+	for _, event := range []*StreamResponse{
+		{Type: "content_block_start", Index: 0, ContentBlock: &Content{Type: "thinking"}},
+		{Type: "content_block_delta", Index: 0, Delta: &Delta{Type: "thinking_delta", Thinking: "let me "}},
+		{Type: "content_block_delta", Index: 0, Delta: &Delta{Type: "thinking_delta", Thinking: "think"}},
+		{Type: "content_block_delta", Index: 0, Delta: &Delta{Type: "signature_delta", Signature: "sig"}},
+		{Type: "content_block_stop", Index: 0},
+	} {
+		response, _ := StreamResponseClaude2OpenAI(event)
+		stream.Rewrite(event, response)
+		blocks = append(blocks, response.Choices[0].Delta.ThinkingBlocks...)
+	}
+	if len(blocks) != 1 || blocks[0].Thinking != "let me think" || blocks[0].Signature != "sig" {
+		t.Fatalf("thinking_blocks = %#v, want one signed block with the streamed text", blocks)
+	}
+	if content := thinkingBlocksToContent(blocks); len(content) != 1 || content[0].Thinking != "let me think" {
+		t.Fatalf("replayed content = %#v, want the thinking text", content)
+	}
+}
+
+func TestUsageClaude2OpenAIIncludesCacheTokens(t *testing.T) {
+	usage := UsageClaude2OpenAI(Usage{
+		InputTokens:              10,
+		OutputTokens:             5,
+		CacheCreationInputTokens: 100,
+		CacheReadInputTokens:     300,
+	})
+
+	if usage.PromptTokens != 410 || usage.TotalTokens != 415 {
+		t.Fatalf("usage = %#v, want cache tokens folded into prompt tokens", usage)
+	}
+	if usage.PromptTokensDetails == nil || usage.PromptTokensDetails.CacheReadTokens != 300 || usage.PromptTokensDetails.CacheCreationTokens != 100 {
+		t.Fatalf("prompt_tokens_details = %#v, want cache read/write split", usage.PromptTokensDetails)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/yeying-community/router/internal/relay/adaptor/aws/utils"
 	"github.com/yeying-community/router/internal/relay/adaptor/openai"
 	relaymodel "github.com/yeying-community/router/internal/relay/model"
+	"github.com/yeying-community/router/internal/relay/timing"
 )
 
 var AwsModelIDMap = map[string]string{
@@ -74,10 +75,12 @@ func Handler(c *gin.Context, awsCli *bedrockruntime.Client, modelName string) (*
 		return utils.WrapErr(errors.Wrap(err, "marshal request")), nil
 	}
 
+	timing.MarkRequestSent(c)
 	awsResp, err := awsCli.InvokeModel(c.Request.Context(), awsReq)
 	if err != nil {
 		return utils.WrapErr(errors.Wrap(err, "InvokeModel")), nil
 	}
+	timing.MarkFirstByte(c)
 
 	claudeResponse := new(anthropic.Response)
 	err = json.Unmarshal(awsResp.Body, claudeResponse)
@@ -86,12 +89,9 @@ func Handler(c *gin.Context, awsCli *bedrockruntime.Client, modelName string) (*
 	}
 
 	openaiResp := anthropic.ResponseClaude2OpenAI(claudeResponse)
+	anthropic.UnwrapStructuredOutput(openaiResp, claudeReq.StructuredOutputTool())
 	openaiResp.Model = modelName
-	usage := relaymodel.Usage{
-		PromptTokens:     claudeResponse.Usage.InputTokens,
-		CompletionTokens: claudeResponse.Usage.OutputTokens,
-		TotalTokens:      claudeResponse.Usage.InputTokens + claudeResponse.Usage.OutputTokens,
-	}
+	usage := anthropic.UsageClaude2OpenAI(claudeResponse.Usage)
 	openaiResp.Usage = usage
 
 	c.JSON(http.StatusOK, openaiResp)
@@ -128,10 +128,12 @@ func StreamHandler(c *gin.Context, awsCli *bedrockruntime.Client) (*relaymodel.E
 		return utils.WrapErr(errors.Wrap(err, "marshal request")), nil
 	}
 
+	timing.MarkRequestSent(c)
 	awsResp, err := awsCli.InvokeModelWithResponseStream(c.Request.Context(), awsReq)
 	if err != nil {
 		return utils.WrapErr(errors.Wrap(err, "InvokeModelWithResponseStream")), nil
 	}
+	timing.MarkFirstByte(c)
 	stream := awsResp.GetStream()
 	defer stream.Close()
 
@@ -139,6 +141,8 @@ func StreamHandler(c *gin.Context, awsCli *bedrockruntime.Client) (*relaymodel.E
 	var usage relaymodel.Usage
 	var id string
 	var lastToolCallChoice openai.ChatCompletionsStreamResponseChoice
+	structuredOutput := anthropic.NewStructuredOutputStream(claudeReq.StructuredOutputTool())
+	thinking := anthropic.NewThinkingStream()
 
 	c.Stream(func(w io.Writer) bool {
 		event, ok := <-stream.Events()
@@ -177,6 +181,8 @@ func StreamHandler(c *gin.Context, awsCli *bedrockruntime.Client) (*relaymodel.E
 			if response == nil {
 				return true
 			}
+			structuredOutput.Rewrite(claudeResp, response)
+			thinking.Rewrite(claudeResp, response)
 			response.Id = id
 			response.Model = c.GetString(ctxkey.OriginalModel)
 			response.Created = createdTime
@@ -186,6 +192,9 @@ func StreamHandler(c *gin.Context, awsCli *bedrockruntime.Client) (*relaymodel.E
 					lastToolCallChoice = choice
 				}
 			}
+			if response.HasOutput() {
+				timing.MarkFirstToken(c)
+			}
 			jsonStr, err := json.Marshal(response)
 			if err != nil {
 				logger.SysError("error marshalling stream response: " + err.Error())
@@ -23,6 +23,7 @@ import (
 	"github.com/yeying-community/router/internal/relay/adaptor/aws/utils"
 	"github.com/yeying-community/router/internal/relay/adaptor/openai"
 	relaymodel "github.com/yeying-community/router/internal/relay/model"
+	"github.com/yeying-community/router/internal/relay/timing"
 )
 
 // Only support llama-3-8b and llama-3-70b instruction models.
@@ -90,10 +91,12 @@ func Handler(c *gin.Context, awsCli *bedrockruntime.Client, modelName string) (*
 		return utils.WrapErr(errors.Wrap(err, "marshal request")), nil
 	}
 
+	timing.MarkRequestSent(c)
 	awsResp, err := awsCli.InvokeModel(c.Request.Context(), awsReq)
 	if err != nil {
 		return utils.WrapErr(errors.Wrap(err, "InvokeModel")), nil
 	}
+	timing.MarkFirstByte(c)
 
 	var llamaResponse Response
 	err = json.Unmarshal(awsResp.Body, &llamaResponse)
@@ -160,10 +163,12 @@ func StreamHandler(c *gin.Context, awsCli *bedrockruntime.Client) (*relaymodel.E
 		return utils.WrapErr(errors.Wrap(err, "marshal request")), nil
 	}
 
+	timing.MarkRequestSent(c)
 	awsResp, err := awsCli.InvokeModelWithResponseStream(c.Request.Context(), awsReq)
 	if err != nil {
 		return utils.WrapErr(errors.Wrap(err, "InvokeModelWithResponseStream")), nil
 	}
+	timing.MarkFirstByte(c)
 	stream := awsResp.GetStream()
 	defer stream.Close()
 
@@ -193,6 +198,9 @@ func StreamHandler(c *gin.Context, awsCli *bedrockruntime.Client) (*relaymodel.E
 				usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
 			}
 			response := StreamResponseLlama2OpenAI(&llamaResp)
+			if response.HasOutput() {
+				timing.MarkFirstToken(c)
+			}
 			response.Id = fmt.Sprintf("chatcmpl-%s", random.GetUUID())
 			response.Model = c.GetString(ctxkey.OriginalModel)
 			response.Created = createdTime
@@ -8,8 +8,10 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/yeying-community/router/internal/relay/adaptor"
+	"github.com/yeying-community/router/internal/relay/adaptor/openai"
 	"github.com/yeying-community/router/internal/relay/meta"
 	"github.com/yeying-community/router/internal/relay/model"
+	"github.com/yeying-community/router/internal/relay/relaymode"
 )
 
 type Adaptor struct{}
@@ -26,6 +28,9 @@ func (a *Adaptor) Init(meta *meta.Meta) {
 }
 
 func (a *Adaptor) GetRequestURL(meta *meta.Meta) (string, error) {
+	if meta.Mode == relaymode.Rerank {
+		return fmt.Sprintf("%s/v1/rerank", meta.BaseURL), nil
+	}
 	return fmt.Sprintf("%s/v1/chat", meta.BaseURL), nil
 }
 
@@ -55,6 +60,18 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, meta *meta.Met
 	return
 }
 
+func (a *Adaptor) ConvertRerankRequest(request *model.RerankRequest) (any, error) {
+	if request == nil {
+		return nil, errors.New("request is nil")
+	}
+	return ConvertRerankRequest(*request)
+}
+
+func (a *Adaptor) DoRerankResponse(resp *http.Response, meta *meta.Meta) (*model.RerankResponse, *model.ErrorWithStatusCode) {
+	// Cohere answers in the common rerank shape, billed in meta.billed_units.
+	return openai.RerankHandler(resp)
+}
+
 func (a *Adaptor) GetModelList() []string {
 	return ModelList
 }
@@ -6,9 +6,15 @@ var ModelList = []string{
 	"command-r", "command-r-plus",
 }
 
+var RerankModelList = []string{
+	"rerank-v3.5",
+	"rerank-english-v3.0", "rerank-multilingual-v3.0",
+}
+
 func init() {
 	num := len(ModelList)
 	for i := 0; i < num; i++ {
 		ModelList = append(ModelList, ModelList[i]+"-internet")
 	}
+	ModelList = append(ModelList, RerankModelList...)
 }
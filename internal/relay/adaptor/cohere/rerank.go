@@ -0,0 +1,29 @@
+package cohere
+
+import (
+	"github.com/yeying-community/router/internal/relay/model"
+)
+
+type RerankRequest struct {
+	Model           string   `json:"model"`
+	Query           string   `json:"query"`
+	Documents       []string `json:"documents"`
+	TopN            int      `json:"top_n,omitempty"`
+	ReturnDocuments bool     `json:"return_documents,omitempty"`
+	MaxChunksPerDoc int      `json:"max_chunks_per_doc,omitempty"`
+}
+
+func ConvertRerankRequest(request model.RerankRequest) (*RerankRequest, error) {
+	documents, err := request.DocumentTexts()
+	if err != nil {
+		return nil, err
+	}
+	return &RerankRequest{
+		Model:           request.Model,
+		Query:           request.Query,
+		Documents:       documents,
+		TopN:            request.TopN,
+		ReturnDocuments: request.ShouldReturnDocuments(),
+		MaxChunksPerDoc: request.MaxChunksPerDoc,
+	}, nil
+}
@@ -3,10 +3,12 @@ package adaptor
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"strings"
 
@@ -16,6 +18,7 @@ import (
 	"github.com/yeying-community/router/common/logger"
 	relaylogging "github.com/yeying-community/router/internal/relay/logging"
 	"github.com/yeying-community/router/internal/relay/meta"
+	"github.com/yeying-community/router/internal/relay/timing"
 )
 
 func SetupCommonRequestHeader(c *gin.Context, req *http.Request, meta *meta.Meta) {
@@ -176,6 +179,7 @@ func isRequestContextCanceled(err error) bool {
 }
 
 func DoRequest(c *gin.Context, req *http.Request) (*http.Response, error) {
+	timing.MarkRequestSent(c)
 	resp, err := client.HTTPClient.Do(req)
 	if err != nil {
 		return nil, err
@@ -183,7 +187,30 @@ func DoRequest(c *gin.Context, req *http.Request) (*http.Response, error) {
 	if resp == nil {
 		return nil, errors.New("resp is nil")
 	}
+	timing.MarkFirstByte(c)
 	_ = req.Body.Close()
 	_ = c.Request.Body.Close()
 	return resp, nil
 }
+
+// ReadMultipartImageDataURI inlines an uploaded image as a base64 data URI for
+// upstreams that only accept images in JSON bodies.
+func ReadMultipartImageDataURI(fileHeader *multipart.FileHeader) (string, error) {
+	if fileHeader == nil {
+		return "", errors.New("image file is required")
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+	contentType := strings.TrimSpace(fileHeader.Header.Get("Content-Type"))
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	return fmt.Sprintf("data:%s;base64,%s", contentType, base64.StdEncoding.EncodeToString(data)), nil
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/yeying-community/router/internal/relay/meta"
+	"github.com/yeying-community/router/internal/relay/model"
 	"github.com/yeying-community/router/internal/relay/relaymode"
 )
 
@@ -17,13 +18,33 @@ func GetRequestURL(meta *meta.Meta) (string, error) {
 		return fmt.Sprintf("%s/api/v3/responses", baseURL), nil
 	case relaymode.Embeddings:
 		return fmt.Sprintf("%s/api/v3/embeddings", baseURL), nil
-	case relaymode.ImagesGenerations:
+	case relaymode.ImagesGenerations, relaymode.ImagesVariations:
 		return fmt.Sprintf("%s/api/v3/images/generations", baseURL), nil
 	default:
 	}
 	return "", fmt.Errorf("unsupported relay mode %d for doubao", meta.Mode)
 }
 
+// ImageVariationRequest is a Seedream image-to-image generation request that
+// stands in for /v1/images/variations.
+type ImageVariationRequest struct {
+	Model          string `json:"model"`
+	Prompt         string `json:"prompt"`
+	Image          string `json:"image"`
+	Size           string `json:"size,omitempty"`
+	ResponseFormat string `json:"response_format,omitempty"`
+}
+
+func ConvertImageVariationRequest(request model.ImageRequest, imageDataURI string) *ImageVariationRequest {
+	return &ImageVariationRequest{
+		Model:          request.Model,
+		Prompt:         model.ImageVariationPrompt,
+		Image:          imageDataURI,
+		Size:           request.Size,
+		ResponseFormat: request.ResponseFormat,
+	}
+}
+
 func normalizeArkBaseURL(raw string) string {
 	baseURL := strings.TrimRight(strings.TrimSpace(raw), "/")
 	lower := strings.ToLower(baseURL)
@@ -44,6 +44,12 @@ func TestGetRequestURLSupportsOfficialVolcengineEndpoints(t *testing.T) {
 			mode:    relaymode.ImagesGenerations,
 			want:    "https://ark.cn-beijing.volces.com/api/v3/images/generations",
 		},
+		{
+			name:    "image variations emulated by generation",
+			baseURL: "https://ark.cn-beijing.volces.com/api/v3",
+			mode:    relaymode.ImagesVariations,
+			want:    "https://ark.cn-beijing.volces.com/api/v3/images/generations",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -19,6 +19,7 @@ import (
 	"github.com/yeying-community/router/internal/relay/adaptor/openai"
 	"github.com/yeying-community/router/internal/relay/constant"
 	"github.com/yeying-community/router/internal/relay/model"
+	"github.com/yeying-community/router/internal/relay/timing"
 
 	"github.com/gin-gonic/gin"
 )
@@ -69,7 +70,7 @@ func ConvertRequest(textRequest model.GeneralOpenAIRequest) *ChatRequest {
 			geminiRequest.GenerationConfig.ResponseMimeType = mimeType
 		}
 		if textRequest.ResponseFormat.JsonSchema != nil {
-			geminiRequest.GenerationConfig.ResponseSchema = textRequest.ResponseFormat.JsonSchema.Schema
+			geminiRequest.GenerationConfig.ResponseSchema = convertResponseSchema(textRequest.ResponseFormat.JsonSchema.Schema)
 			geminiRequest.GenerationConfig.ResponseMimeType = mimeTypeMap["json_object"]
 		}
 	}
@@ -120,6 +121,15 @@ func ConvertRequest(textRequest model.GeneralOpenAIRequest) *ChatRequest {
 						Data:     data,
 					},
 				})
+			} else if part.Type == model.ContentTypeFile {
+				if mimeType, data, ok := part.File.InlineData(); ok {
+					parts = append(parts, Part{
+						InlineData: &InlineData{
+							MimeType: mimeType,
+							Data:     data,
+						},
+					})
+				}
 			}
 		}
 		content.Parts = parts
@@ -333,6 +343,9 @@ func StreamHandler(c *gin.Context, resp *http.Response) (*model.ErrorWithStatusC
 		}
 
 		responseText += response.Choices[0].Delta.StringContent()
+		if response.HasOutput() {
+			timing.MarkFirstToken(c)
+		}
 
 		err = render.ObjectData(c, response)
 		if err != nil {
@@ -0,0 +1,146 @@
+package gemini
+
+import "strings"
+
+// responseSchemaKeys is the OpenAPI subset Gemini accepts in responseSchema;
+// anything else (additionalProperties, $schema, strict, ...) is rejected with
+// a 400, so it is dropped instead.
+var responseSchemaKeys = map[string]bool{
+	"type":             true,
+	"format":           true,
+	"title":            true,
+	"description":      true,
+	"nullable":         true,
+	"enum":             true,
+	"properties":       true,
+	"required":         true,
+	"propertyOrdering": true,
+	"items":            true,
+	"minItems":         true,
+	"maxItems":         true,
+	"minimum":          true,
+	"maximum":          true,
+	"minLength":        true,
+	"maxLength":        true,
+	"pattern":          true,
+	"anyOf":            true,
+}
+
+const maxResponseSchemaRefDepth = 32
+
+// convertResponseSchema turns an OpenAI json_schema into a Gemini
+// responseSchema: local $refs are inlined, ["T","null"] unions become
+// nullable and unsupported keywords are removed.
+func convertResponseSchema(schema map[string]any) any {
+	if schema == nil {
+		return nil
+	}
+	return convertResponseSchemaNode(schema, schema, 0)
+}
+
+func convertResponseSchemaNode(node map[string]any, root map[string]any, depth int) map[string]any {
+	if ref, ok := node["$ref"].(string); ok {
+		if resolved := resolveResponseSchemaRef(ref, root); resolved != nil && depth < maxResponseSchemaRefDepth {
+			return convertResponseSchemaNode(resolved, root, depth+1)
+		}
+		return map[string]any{"type": "object"}
+	}
+	result := make(map[string]any, len(node))
+	for key, value := range node {
+		if !responseSchemaKeys[key] {
+			continue
+		}
+		switch key {
+		case "type":
+			schemaType, nullable := convertResponseSchemaType(value)
+			if schemaType != "" {
+				result["type"] = schemaType
+			}
+			if nullable {
+				result["nullable"] = true
+			}
+		case "properties":
+			properties, ok := value.(map[string]any)
+			if !ok {
+				continue
+			}
+			converted := make(map[string]any, len(properties))
+			for name, property := range properties {
+				if child, ok := property.(map[string]any); ok {
+					converted[name] = convertResponseSchemaNode(child, root, depth)
+				}
+			}
+			result[key] = converted
+		case "items":
+			if child, ok := value.(map[string]any); ok {
+				result[key] = convertResponseSchemaNode(child, root, depth)
+			}
+		case "anyOf":
+			items, ok := value.([]any)
+			if !ok {
+				continue
+			}
+			converted := make([]any, 0, len(items))
+			for _, item := range items {
+				child, ok := item.(map[string]any)
+				if !ok {
+					continue
+				}
+				if child["type"] == "null" && len(child) == 1 {
+					result["nullable"] = true
+					continue
+				}
+				converted = append(converted, convertResponseSchemaNode(child, root, depth))
+			}
+			if len(converted) == 1 {
+				for childKey, childValue := range converted[0].(map[string]any) {
+					result[childKey] = childValue
+				}
+			} else if len(converted) > 1 {
+				result[key] = converted
+			}
+		default:
+			result[key] = value
+		}
+	}
+	return result
+}
+
+func convertResponseSchemaType(value any) (string, bool) {
+	switch typed := value.(type) {
+	case string:
+		return typed, false
+	case []any:
+		schemaType := ""
+		nullable := false
+		for _, item := range typed {
+			name, _ := item.(string)
+			if name == "null" {
+				nullable = true
+				continue
+			}
+			if schemaType == "" {
+				schemaType = name
+			}
+		}
+		return schemaType, nullable
+	default:
+		return "", false
+	}
+}
+
+func resolveResponseSchemaRef(ref string, root map[string]any) map[string]any {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil
+	}
+	var current any = root
+	for _, segment := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil
+		}
+		current = object[segment]
+	}
+	resolved, _ := current.(map[string]any)
+	return resolved
+}
@@ -2,6 +2,7 @@ package adaptor
 
 import (
 	"io"
+	"mime/multipart"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -20,3 +21,18 @@ type Adaptor interface {
 	GetModelList() []string
 	GetChannelName() string
 }
+
+// RerankAdaptor is implemented by adaptors whose upstream can serve /v1/rerank.
+// GetRequestURL must resolve the rerank endpoint when meta.Mode is rerank.
+type RerankAdaptor interface {
+	ConvertRerankRequest(request *model.RerankRequest) (any, error)
+	DoRerankResponse(resp *http.Response, meta *meta.Meta) (*model.RerankResponse, *model.ErrorWithStatusCode)
+}
+
+// ImageVariationAdaptor is implemented by adaptors whose upstream has no
+// variations endpoint and emulates /v1/images/variations with image-to-image
+// generation. GetRequestURL must resolve that endpoint when meta.Mode is
+// image variations.
+type ImageVariationAdaptor interface {
+	ConvertImageVariationRequest(request *model.ImageRequest, image *multipart.FileHeader) (any, error)
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"strings"
 
@@ -20,6 +21,7 @@ import (
 	"github.com/yeying-community/router/internal/relay/meta"
 	"github.com/yeying-community/router/internal/relay/model"
 	"github.com/yeying-community/router/internal/relay/relaymode"
+	"github.com/yeying-community/router/internal/relay/timing"
 )
 
 type Adaptor struct {
@@ -123,6 +125,22 @@ func (a *Adaptor) ConvertImageRequest(request *model.ImageRequest) (any, error)
 	return request, nil
 }
 
+// ConvertImageVariationRequest emulates variations for compatible upstreams
+// without a variations endpoint; other channels receive the multipart upload.
+func (a *Adaptor) ConvertImageVariationRequest(request *model.ImageRequest, image *multipart.FileHeader) (any, error) {
+	if request == nil {
+		return nil, errors.New("request is nil")
+	}
+	if a.ChannelProtocol != relaychannel.VolcEngine {
+		return nil, fmt.Errorf("image variations are relayed natively for channel protocol %d", a.ChannelProtocol)
+	}
+	imageDataURI, err := adaptor.ReadMultipartImageDataURI(image)
+	if err != nil {
+		return nil, err
+	}
+	return doubao.ConvertImageVariationRequest(*request, imageDataURI), nil
+}
+
 func (a *Adaptor) DoRequest(c *gin.Context, meta *meta.Meta, requestBody io.Reader) (*http.Response, error) {
 	return adaptor.DoRequestHelper(a, c, meta, requestBody)
 }
@@ -174,7 +192,7 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, meta *meta.Met
 		}
 	} else {
 		switch meta.Mode {
-		case relaymode.ImagesGenerations, relaymode.ImagesEdits:
+		case relaymode.ImagesGenerations, relaymode.ImagesEdits, relaymode.ImagesVariations:
 			err, _ = ImageHandler(c, resp)
 		default:
 			err, usage = Handler(c, resp, meta.PromptTokens, meta.ActualModelName)
@@ -320,6 +338,9 @@ func relayMessagesStreamResponse(c *gin.Context, resp *http.Response, promptToke
 			}
 		}
 		if deltaPayload, ok := payload["delta"].(map[string]any); ok {
+			if fmt.Sprint(payload["type"]) == "content_block_delta" {
+				timing.MarkFirstToken(c)
+			}
 			if textDelta, ok := deltaPayload["text"].(string); ok && strings.TrimSpace(textDelta) != "" {
 				completionText.WriteString(textDelta)
 			}
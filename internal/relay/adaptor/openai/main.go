@@ -18,6 +18,7 @@ import (
 	"github.com/yeying-community/router/common/logger"
 	"github.com/yeying-community/router/internal/relay/model"
 	"github.com/yeying-community/router/internal/relay/relaymode"
+	"github.com/yeying-community/router/internal/relay/timing"
 )
 
 const (
@@ -34,6 +35,17 @@ func newOpenAIStreamScanner(body io.Reader) *bufio.Scanner {
 	return scanner
 }
 
+// HasOutput reports whether the chunk carries generated content, reasoning or
+// tool calls rather than only a role, finish reason or usage.
+func (r *ChatCompletionsStreamResponse) HasOutput() bool {
+	for _, choice := range r.Choices {
+		if conv.AsString(choice.Delta.Content) != "" || conv.AsString(choice.Delta.ReasoningContent) != "" || len(choice.Delta.ToolCalls) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 func StreamHandler(c *gin.Context, resp *http.Response, relayMode int) (*model.ErrorWithStatusCode, string, *model.Usage) {
 	responseText := ""
 	scanner := newOpenAIStreamScanner(resp.Body)
@@ -69,6 +81,9 @@ func StreamHandler(c *gin.Context, resp *http.Response, relayMode int) (*model.E
 				continue // just ignore empty choice
 			}
 			render.StringData(c, data)
+			if streamResponse.HasOutput() {
+				timing.MarkFirstToken(c)
+			}
 			for _, choice := range streamResponse.Choices {
 				responseText += conv.AsString(choice.Delta.Content)
 			}
@@ -84,6 +99,9 @@ func StreamHandler(c *gin.Context, resp *http.Response, relayMode int) (*model.E
 				continue
 			}
 			for _, choice := range streamResponse.Choices {
+				if choice.Text != "" {
+					timing.MarkFirstToken(c)
+				}
 				responseText += choice.Text
 			}
 		}
@@ -180,6 +198,10 @@ func StreamResponsesHandler(c *gin.Context, resp *http.Response, modelName strin
 		if err := json.Unmarshal([]byte(data), &textPayload); err != nil {
 			continue
 		}
+		// Text, reasoning and function call arguments all arrive as *.delta.
+		if strings.HasSuffix(currentEvent, ".delta") && textPayload.Delta != "" {
+			timing.MarkFirstToken(c)
+		}
 		switch currentEvent {
 		case "response.output_text.delta":
 			if textPayload.Delta != "" {
@@ -0,0 +1,49 @@
+package openai
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/yeying-community/router/internal/relay/meta"
+	"github.com/yeying-community/router/internal/relay/model"
+)
+
+// ConvertRerankRequest targets Jina style /v1/rerank servers such as
+// SiliconFlow, Jina, vLLM and Xinference, which all accept string documents.
+func (a *Adaptor) ConvertRerankRequest(request *model.RerankRequest) (any, error) {
+	if request == nil {
+		return nil, errors.New("request is nil")
+	}
+	documents, err := request.DocumentTexts()
+	if err != nil {
+		return nil, err
+	}
+	converted := *request
+	converted.Documents = make([]any, 0, len(documents))
+	for _, document := range documents {
+		converted.Documents = append(converted.Documents, document)
+	}
+	return &converted, nil
+}
+
+func (a *Adaptor) DoRerankResponse(resp *http.Response, meta *meta.Meta) (*model.RerankResponse, *model.ErrorWithStatusCode) {
+	return RerankHandler(resp)
+}
+
+func RerankHandler(resp *http.Response) (*model.RerankResponse, *model.ErrorWithStatusCode) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, ErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError)
+	}
+	if err := resp.Body.Close(); err != nil {
+		return nil, ErrorWrapper(err, "close_response_body_failed", http.StatusInternalServerError)
+	}
+	rerankResponse := &model.RerankResponse{}
+	if err := json.Unmarshal(responseBody, rerankResponse); err != nil {
+		return nil, ErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError)
+	}
+	rerankResponse.Normalize()
+	return rerankResponse, nil
+}
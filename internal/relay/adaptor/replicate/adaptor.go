@@ -3,6 +3,7 @@ package replicate
 import (
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"slices"
 	"strings"
@@ -39,6 +40,23 @@ func (*Adaptor) ConvertImageRequest(request *model.ImageRequest) (any, error) {
 	}, nil
 }
 
+// ConvertImageVariationRequest emulates a variation by passing the uploaded
+// image as flux image_prompt.
+func (a *Adaptor) ConvertImageVariationRequest(request *model.ImageRequest, image *multipart.FileHeader) (any, error) {
+	imageDataURI, err := adaptor.ReadMultipartImageDataURI(image)
+	if err != nil {
+		return nil, err
+	}
+	converted, err := a.ConvertImageRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	drawRequest := converted.(DrawImageRequest)
+	drawRequest.Input.Prompt = model.ImageVariationPrompt
+	drawRequest.Input.ImagePrompt = imageDataURI
+	return drawRequest, nil
+}
+
 func (a *Adaptor) ConvertRequest(c *gin.Context, relayMode int, request *model.GeneralOpenAIRequest) (any, error) {
 	if !request.Stream {
 		// TODO: support non-stream mode
@@ -116,7 +134,7 @@ func (a *Adaptor) DoRequest(c *gin.Context, meta *meta.Meta, requestBody io.Read
 
 func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, meta *meta.Meta) (usage *model.Usage, err *model.ErrorWithStatusCode) {
 	switch meta.Mode {
-	case relaymode.ImagesGenerations:
+	case relaymode.ImagesGenerations, relaymode.ImagesVariations:
 		err, usage = ImageHandler(c, resp)
 	case relaymode.ChatCompletions:
 		err, usage = ChatHandler(c, resp)
@@ -43,10 +43,15 @@ func (a *Adaptor) ConvertRequest(c *gin.Context, relayMode int, request *model.G
 		TopK:        claudeReq.TopK,
 		Stream:      claudeReq.Stream,
 		Tools:       claudeReq.Tools,
+		ToolChoice:  claudeReq.ToolChoice,
+		Thinking:    claudeReq.Thinking,
 	}
 
 	c.Set(ctxkey.RequestModel, request.Model)
 	c.Set(ctxkey.ConvertedRequest, req)
+	if toolName := claudeReq.StructuredOutputTool(); toolName != "" {
+		c.Set(ctxkey.StructuredOutputTool, toolName)
+	}
 	return req, nil
 }
 
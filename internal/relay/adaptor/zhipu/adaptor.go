@@ -15,6 +15,7 @@ import (
 	"github.com/yeying-community/router/internal/relay/meta"
 	"github.com/yeying-community/router/internal/relay/model"
 	"github.com/yeying-community/router/internal/relay/relaymode"
+	"github.com/yeying-community/router/internal/relay/structuredoutput"
 )
 
 type Adaptor struct {
@@ -101,6 +102,8 @@ func (a *Adaptor) ConvertRequest(c *gin.Context, relayMode int, request *model.G
 		// Temperature [0.0, 1.0]
 		request.Temperature = helper.Float64PtrMax(request.Temperature, 1)
 		request.Temperature = helper.Float64PtrMin(request.Temperature, 0)
+		// GLM only offers json_object.
+		structuredoutput.DowngradeToJSONObject(request)
 		a.SetVersionByModeName(request.Model)
 		if a.APIVersion == "v4" {
 			return request, nil
@@ -0,0 +1,326 @@
+package batch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/yeying-community/router/common/helper"
+	"github.com/yeying-community/router/common/random"
+	"github.com/yeying-community/router/internal/admin/model"
+	"github.com/yeying-community/router/internal/relay/filestore"
+)
+
+const (
+	IDPrefix         = "batch_"
+	CompletionWindow = "24h"
+	InputPurpose     = "batch"
+	OutputPurpose    = "batch_output"
+
+	completionWindowDuration = 24 * time.Hour
+	maxMetadataPairs         = 16
+	maxMetadataKeyLength     = 64
+	maxMetadataValueLength   = 512
+)
+
+var (
+	ErrBatchNotFound       = errors.New("batch not found")
+	ErrBatchNotCancellable = errors.New("batch cannot be cancelled in its current status")
+)
+
+var supportedEndpoints = map[string]struct{}{
+	"/v1/chat/completions": {},
+	"/v1/completions":      {},
+	"/v1/embeddings":       {},
+	"/v1/responses":        {},
+}
+
+// InvalidRequestError is a client error reported back with the offending
+// request parameter.
+type InvalidRequestError struct {
+	Param   string
+	Code    string
+	Message string
+}
+
+func (e *InvalidRequestError) Error() string {
+	return e.Message
+}
+
+// Object is the OpenAI batch object returned by the Batch API.
+type Object struct {
+	ID               string            `json:"id"`
+	Object           string            `json:"object"`
+	Endpoint         string            `json:"endpoint"`
+	Errors           *ErrorList        `json:"errors"`
+	InputFileID      string            `json:"input_file_id"`
+	CompletionWindow string            `json:"completion_window"`
+	Status           string            `json:"status"`
+	OutputFileID     *string           `json:"output_file_id"`
+	ErrorFileID      *string           `json:"error_file_id"`
+	CreatedAt        int64             `json:"created_at"`
+	InProgressAt     *int64            `json:"in_progress_at"`
+	ExpiresAt        *int64            `json:"expires_at"`
+	FinalizingAt     *int64            `json:"finalizing_at"`
+	CompletedAt      *int64            `json:"completed_at"`
+	FailedAt         *int64            `json:"failed_at"`
+	ExpiredAt        *int64            `json:"expired_at"`
+	CancellingAt     *int64            `json:"cancelling_at"`
+	CancelledAt      *int64            `json:"cancelled_at"`
+	RequestCounts    RequestCounts     `json:"request_counts"`
+	Metadata         map[string]string `json:"metadata"`
+}
+
+type RequestCounts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+type ErrorList struct {
+	Object string      `json:"object"`
+	Data   []ErrorItem `json:"data"`
+}
+
+type ErrorItem struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Param   string `json:"param,omitempty"`
+	Line    *int   `json:"line,omitempty"`
+}
+
+func NewObject(row model.RelayBatch) Object {
+	object := Object{
+		ID:               row.Id,
+		Object:           "batch",
+		Endpoint:         row.Endpoint,
+		InputFileID:      row.InputFileID,
+		CompletionWindow: row.CompletionWindow,
+		Status:           row.Status,
+		OutputFileID:     optionalString(row.OutputFileID),
+		ErrorFileID:      optionalString(row.ErrorFileID),
+		CreatedAt:        row.CreatedAt,
+		InProgressAt:     optionalTime(row.InProgressAt),
+		ExpiresAt:        optionalTime(row.ExpiresAt),
+		FinalizingAt:     optionalTime(row.FinalizingAt),
+		CompletedAt:      optionalTime(row.CompletedAt),
+		FailedAt:         optionalTime(row.FailedAt),
+		ExpiredAt:        optionalTime(row.ExpiredAt),
+		CancellingAt:     optionalTime(row.CancellingAt),
+		CancelledAt:      optionalTime(row.CancelledAt),
+		RequestCounts: RequestCounts{
+			Total:     row.TotalCount,
+			Completed: row.CompletedCount,
+			Failed:    row.FailedCount,
+		},
+	}
+	if row.Errors != "" {
+		items := make([]ErrorItem, 0)
+		if err := json.Unmarshal([]byte(row.Errors), &items); err == nil && len(items) > 0 {
+			object.Errors = &ErrorList{Object: "list", Data: items}
+		}
+	}
+	if row.Metadata != "" {
+		metadata := map[string]string{}
+		if err := json.Unmarshal([]byte(row.Metadata), &metadata); err == nil && len(metadata) > 0 {
+			object.Metadata = metadata
+		}
+	}
+	return object
+}
+
+type CreateRequest struct {
+	UserID           string
+	TokenID          string
+	ClientIP         string
+	TraceID          string
+	InputFileID      string
+	Endpoint         string
+	CompletionWindow string
+	Metadata         map[string]string
+}
+
+func SupportedEndpoint(endpoint string) bool {
+	_, ok := supportedEndpoints[strings.TrimSpace(endpoint)]
+	return ok
+}
+
+// Create records a batch for an uploaded input file and queues it on the
+// master node's task worker. The input lines are validated by the runner.
+func Create(request CreateRequest) (model.RelayBatch, error) {
+	if !SupportedEndpoint(request.Endpoint) {
+		return model.RelayBatch{}, &InvalidRequestError{
+			Param:   "endpoint",
+			Code:    "invalid_value",
+			Message: fmt.Sprintf("Invalid value for 'endpoint': '%s'.", request.Endpoint),
+		}
+	}
+	if request.CompletionWindow != CompletionWindow {
+		return model.RelayBatch{}, &InvalidRequestError{
+			Param:   "completion_window",
+			Code:    "invalid_value",
+			Message: fmt.Sprintf("Invalid value for 'completion_window': '%s'. Supported values are: '%s'.", request.CompletionWindow, CompletionWindow),
+		}
+	}
+	if err := validateMetadata(request.Metadata); err != nil {
+		return model.RelayBatch{}, err
+	}
+	inputFile, err := filestore.Get(request.InputFileID, request.UserID)
+	if err != nil {
+		if errors.Is(err, filestore.ErrFileNotFound) {
+			return model.RelayBatch{}, &InvalidRequestError{
+				Param:   "input_file_id",
+				Code:    "file_not_found",
+				Message: fmt.Sprintf("No such File object: %s", request.InputFileID),
+			}
+		}
+		return model.RelayBatch{}, err
+	}
+	if inputFile.Purpose != InputPurpose {
+		return model.RelayBatch{}, &InvalidRequestError{
+			Param:   "input_file_id",
+			Code:    "invalid_value",
+			Message: fmt.Sprintf("File %s has purpose '%s', but batches require purpose '%s'.", inputFile.Id, inputFile.Purpose, InputPurpose),
+		}
+	}
+	metadata := ""
+	if len(request.Metadata) > 0 {
+		encoded, err := json.Marshal(request.Metadata)
+		if err != nil {
+			return model.RelayBatch{}, err
+		}
+		metadata = string(encoded)
+	}
+
+	now := helper.GetTimestamp()
+	row := model.RelayBatch{
+		Id:               IDPrefix + strings.ReplaceAll(random.GetUUID(), "-", ""),
+		UserID:           request.UserID,
+		TokenID:          request.TokenID,
+		ClientIP:         request.ClientIP,
+		Endpoint:         request.Endpoint,
+		InputFileID:      inputFile.Id,
+		CompletionWindow: request.CompletionWindow,
+		Status:           model.RelayBatchStatusValidating,
+		Metadata:         metadata,
+		CreatedAt:        now,
+		ExpiresAt:        now + int64(completionWindowDuration/time.Second),
+	}
+	payload, err := json.Marshal(taskPayload{BatchID: row.Id})
+	if err != nil {
+		return model.RelayBatch{}, err
+	}
+	err = model.DB.Transaction(func(tx *gorm.DB) error {
+		task, _, err := model.CreateOrReuseAsyncTaskWithDB(tx, model.AsyncTask{
+			Type:      model.AsyncTaskTypeRelayBatch,
+			DedupeKey: model.AsyncTaskTypeRelayBatch + ":" + row.Id,
+			Endpoint:  row.Endpoint,
+			Payload:   string(payload),
+			CreatedBy: row.UserID,
+			TraceID:   request.TraceID,
+		})
+		if err != nil {
+			return err
+		}
+		row.TaskID = task.Id
+		return model.CreateRelayBatchWithDB(tx, row)
+	})
+	if err != nil {
+		return model.RelayBatch{}, err
+	}
+	return row, nil
+}
+
+func Get(batchID string, userID string) (model.RelayBatch, error) {
+	row, err := model.GetRelayBatchWithDB(model.DB, batchID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.RelayBatch{}, ErrBatchNotFound
+		}
+		return model.RelayBatch{}, err
+	}
+	return row, nil
+}
+
+// List returns one page of the user's batches and whether more follow.
+func List(query model.RelayBatchListQuery) ([]model.RelayBatch, bool, error) {
+	rows, err := model.ListRelayBatchesWithDB(model.DB, query)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, ErrBatchNotFound
+		}
+		return nil, false, err
+	}
+	if query.Limit > 0 && len(rows) > query.Limit {
+		return rows[:query.Limit], true, nil
+	}
+	return rows, false, nil
+}
+
+// Cancel stops a batch. A batch still waiting for the worker is cancelled
+// right away; a running one moves to cancelling and the runner finishes it.
+func Cancel(batchID string, userID string) (model.RelayBatch, error) {
+	row, err := Get(batchID, userID)
+	if err != nil {
+		return model.RelayBatch{}, err
+	}
+	switch row.Status {
+	case model.RelayBatchStatusCancelling, model.RelayBatchStatusCancelled:
+		return row, nil
+	case model.RelayBatchStatusValidating, model.RelayBatchStatusInProgress:
+	default:
+		return model.RelayBatch{}, ErrBatchNotCancellable
+	}
+	if row.Status == model.RelayBatchStatusValidating && row.TaskID != "" {
+		if _, err := model.CancelAsyncTaskWithDB(model.DB, row.TaskID); err == nil {
+			if _, err := model.TransitionRelayBatchWithDB(model.DB, row.Id, []string{model.RelayBatchStatusValidating}, model.RelayBatchStatusCancelled, map[string]any{
+				"cancelling_at": helper.GetTimestamp(),
+			}); err != nil {
+				return model.RelayBatch{}, err
+			}
+			return Get(row.Id, userID)
+		}
+	}
+	if _, err := model.TransitionRelayBatchWithDB(model.DB, row.Id, []string{model.RelayBatchStatusValidating, model.RelayBatchStatusInProgress}, model.RelayBatchStatusCancelling, nil); err != nil {
+		return model.RelayBatch{}, err
+	}
+	return Get(row.Id, userID)
+}
+
+func validateMetadata(metadata map[string]string) error {
+	if len(metadata) > maxMetadataPairs {
+		return &InvalidRequestError{
+			Param:   "metadata",
+			Code:    "invalid_value",
+			Message: fmt.Sprintf("metadata can have at most %d key-value pairs", maxMetadataPairs),
+		}
+	}
+	for key, value := range metadata {
+		if len(key) > maxMetadataKeyLength || len(value) > maxMetadataValueLength {
+			return &InvalidRequestError{
+				Param:   "metadata",
+				Code:    "invalid_value",
+				Message: fmt.Sprintf("metadata keys are limited to %d characters and values to %d characters", maxMetadataKeyLength, maxMetadataValueLength),
+			}
+		}
+	}
+	return nil
+}
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func optionalTime(value int64) *int64 {
+	if value <= 0 {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,34 @@
+package batch
+
+import (
+	"context"
+
+	"github.com/yeying-community/router/common/config"
+	"github.com/yeying-community/router/internal/admin/model"
+)
+
+type requestContextKey struct{}
+
+// WithBatchRequest marks a request the runner dispatches on behalf of a batch.
+// Only in-process requests can carry the marker, clients cannot forge it.
+func WithBatchRequest(ctx context.Context, batchID string) context.Context {
+	return context.WithValue(ctx, requestContextKey{}, batchID)
+}
+
+func BatchIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	batchID, _ := ctx.Value(requestContextKey{}).(string)
+	return batchID
+}
+
+// ApplyBillingRatio stacks the configured batch discount on top of the route
+// billing ratio for requests executed by a batch.
+func ApplyBillingRatio(ctx context.Context, ratio model.BillingRatioBreakdown) model.BillingRatioBreakdown {
+	if BatchIDFromContext(ctx) == "" || config.BatchBillingRatio <= 0 || config.BatchBillingRatio == 1 {
+		return ratio
+	}
+	ratio.EffectiveRatio *= config.BatchBillingRatio
+	return ratio
+}
@@ -0,0 +1,560 @@
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yeying-community/router/common/config"
+	"github.com/yeying-community/router/common/graceful"
+	"github.com/yeying-community/router/common/helper"
+	"github.com/yeying-community/router/common/logger"
+	"github.com/yeying-community/router/common/random"
+	"github.com/yeying-community/router/internal/admin/model"
+	"github.com/yeying-community/router/internal/relay/filestore"
+)
+
+const maxBatchRequests = 50000
+
+// outcomeInterrupted is the dispatch outcome of a run stopped by a shutdown;
+// it is not a batch status, the batch stays in progress.
+const outcomeInterrupted = "interrupted"
+
+var (
+	progressInterval = 2 * time.Second
+	getTokenFunc     = model.GetTokenById
+	stoppingFunc     = graceful.Stopping
+)
+
+type taskPayload struct {
+	BatchID string `json:"batch_id"`
+}
+
+type inputLine struct {
+	CustomID string          `json:"custom_id"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+}
+
+type outputLine struct {
+	ID       string          `json:"id"`
+	CustomID string          `json:"custom_id"`
+	Response *outputResponse `json:"response"`
+	Error    *outputError    `json:"error"`
+}
+
+type outputResponse struct {
+	StatusCode int             `json:"status_code"`
+	RequestID  string          `json:"request_id"`
+	Body       json.RawMessage `json:"body"`
+}
+
+type outputError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type runResult struct {
+	BatchID   string `json:"batch_id"`
+	Status    string `json:"status"`
+	Completed int    `json:"completed"`
+	Failed    int    `json:"failed"`
+}
+
+// ExecuteTask runs the batch referenced by an admin task payload.
+func ExecuteTask(ctx context.Context, handler http.Handler, task *model.AsyncTask) (string, error) {
+	if task == nil {
+		return "", fmt.Errorf("任务不能为空")
+	}
+	payload := taskPayload{}
+	if err := json.Unmarshal([]byte(task.Payload), &payload); err != nil {
+		return "", err
+	}
+	return Run(ctx, handler, payload.BatchID)
+}
+
+// Run validates the batch input file, replays every line through handler as
+// the batch owner and stores the output and error files. Cancelling ctx or
+// the batch stops dispatching new lines; requests already sent are allowed to
+// finish so they are billed and reported consistently. A shutdown stops it the
+// same way, but saves the finished lines and returns
+// model.ErrAsyncTaskInterrupted so the batch resumes from them.
+func Run(ctx context.Context, handler http.Handler, batchID string) (string, error) {
+	if handler == nil {
+		return "", fmt.Errorf("batch handler is not configured")
+	}
+	row, err := model.GetRelayBatchWithDB(model.DB, batchID, "")
+	if err != nil {
+		return "", err
+	}
+	switch row.Status {
+	case model.RelayBatchStatusValidating, model.RelayBatchStatusInProgress:
+	case model.RelayBatchStatusCancelling:
+		return finishRun(row, model.RelayBatchStatusCancelling, model.RelayBatchStatusCancelled, nil)
+	default:
+		return marshalRunResult(row, row.Status)
+	}
+
+	lines, validationErrors, err := loadInputLines(ctx, row)
+	if err != nil {
+		return "", err
+	}
+	if len(validationErrors) > 0 {
+		return failRun(row, row.Status, validationErrors)
+	}
+	token, err := getTokenFunc(row.TokenID)
+	if err != nil || token == nil {
+		return failRun(row, row.Status, []ErrorItem{{
+			Code:    "token_not_found",
+			Message: "The API key that created this batch no longer exists.",
+		}})
+	}
+	results := make([]*outputLine, len(lines))
+	if row.Status == model.RelayBatchStatusInProgress {
+		if results, err = loadSavedLines(row, len(lines)); err != nil {
+			return "", err
+		}
+		logger.Infof(ctx, "[batch] resumed batch_id=%s endpoint=%s requests=%d", row.Id, row.Endpoint, len(lines))
+	} else {
+		started, err := model.TransitionRelayBatchWithDB(model.DB, row.Id, []string{model.RelayBatchStatusValidating}, model.RelayBatchStatusInProgress, map[string]any{
+			"total_count": len(lines),
+		})
+		if err != nil {
+			return "", err
+		}
+		if !started {
+			return finishRun(row, model.RelayBatchStatusCancelling, model.RelayBatchStatusCancelled, nil)
+		}
+		logger.Infof(ctx, "[batch] started batch_id=%s endpoint=%s requests=%d", row.Id, row.Endpoint, len(lines))
+	}
+	row.TotalCount = len(lines)
+
+	outcome := dispatchLines(ctx, handler, row, "sk-"+token.Key, lines, results)
+	if outcome == outcomeInterrupted {
+		return "", interruptRun(ctx, row, results)
+	}
+
+	completed, failed := 0, 0
+	var output, errorOutput bytes.Buffer
+	for index, result := range results {
+		if result == nil {
+			if outcome != model.RelayBatchStatusExpired {
+				continue
+			}
+			result = &outputLine{
+				ID:       newRequestID(),
+				CustomID: lines[index].CustomID,
+				Error: &outputError{
+					Code:    "batch_expired",
+					Message: "This request could not be executed before the completion window expired.",
+				},
+			}
+		}
+		target := &output
+		if result.Error != nil || result.Response == nil || result.Response.StatusCode >= http.StatusBadRequest {
+			target = &errorOutput
+			failed++
+		} else {
+			completed++
+		}
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			return "", err
+		}
+		target.Write(encoded)
+		target.WriteByte('\n')
+	}
+	row.CompletedCount, row.FailedCount = completed, failed
+	if err := model.UpdateRelayBatchCountsWithDB(model.DB, row.Id, completed, failed); err != nil {
+		return "", err
+	}
+
+	fromStatus := model.RelayBatchStatusCancelling
+	if outcome == model.RelayBatchStatusCancelled {
+		// the task itself may have been cancelled without going through the API
+		if _, err := model.TransitionRelayBatchWithDB(model.DB, row.Id, []string{model.RelayBatchStatusInProgress}, model.RelayBatchStatusCancelling, nil); err != nil {
+			return "", err
+		}
+	} else {
+		finalizing, err := model.TransitionRelayBatchWithDB(model.DB, row.Id, []string{model.RelayBatchStatusInProgress}, model.RelayBatchStatusFinalizing, nil)
+		if err != nil {
+			return "", err
+		}
+		if finalizing {
+			fromStatus = model.RelayBatchStatusFinalizing
+		} else {
+			outcome = model.RelayBatchStatusCancelled
+		}
+	}
+	storeCtx := context.WithoutCancel(ctx)
+	updates := map[string]any{}
+	if output.Len() > 0 {
+		file, err := storeOutputFile(storeCtx, row, "output", output.Bytes())
+		if err != nil {
+			return failRun(row, fromStatus, []ErrorItem{{Code: "output_file_failed", Message: err.Error()}})
+		}
+		updates["output_file_id"] = file.Id
+	}
+	if errorOutput.Len() > 0 {
+		file, err := storeOutputFile(storeCtx, row, "error", errorOutput.Bytes())
+		if err != nil {
+			return failRun(row, fromStatus, []ErrorItem{{Code: "output_file_failed", Message: err.Error()}})
+		}
+		updates["error_file_id"] = file.Id
+	}
+	logger.Infof(ctx, "[batch] finished batch_id=%s status=%s completed=%d failed=%d", row.Id, outcome, completed, failed)
+	return finishRun(row, fromStatus, outcome, updates)
+}
+
+// dispatchLines executes the lines without a result yet with the configured
+// concurrency until all are done, the batch is cancelled, the completion
+// window ends or the service shuts down. Lines that were never sent are left
+// nil in results.
+func dispatchLines(ctx context.Context, handler http.Handler, row model.RelayBatch, apiKey string, lines []inputLine, results []*outputLine) string {
+	runCtx, cancelRun := context.WithDeadline(ctx, time.Unix(row.ExpiresAt, 0))
+	defer cancelRun()
+	requestCtx := WithBatchRequest(context.WithoutCancel(ctx), row.Id)
+
+	var completed, failed atomic.Int64
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		if lineSucceeded(result) {
+			completed.Add(1)
+		} else {
+			failed.Add(1)
+		}
+	}
+	var cancelled, interrupted atomic.Bool
+	monitorDone := make(chan struct{})
+	monitorStopped := make(chan struct{})
+	go func() {
+		defer close(monitorStopped)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-monitorDone:
+				return
+			case <-ticker.C:
+			}
+			if err := model.UpdateRelayBatchCountsWithDB(model.DB, row.Id, int(completed.Load()), int(failed.Load())); err != nil {
+				logger.Warnf(ctx, "[batch] progress update failed batch_id=%s err=%v", row.Id, err)
+			}
+			current, err := model.GetRelayBatchWithDB(model.DB, row.Id, "")
+			if err == nil && current.Status == model.RelayBatchStatusCancelling {
+				cancelled.Store(true)
+				cancelRun()
+				return
+			}
+		}
+	}()
+
+	concurrency := config.BatchConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < concurrency; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				result := executeLine(requestCtx, handler, row, apiKey, lines[index])
+				if lineSucceeded(result) {
+					completed.Add(1)
+				} else {
+					failed.Add(1)
+				}
+				results[index] = result
+			}
+		}()
+	}
+dispatch:
+	for index := range lines {
+		if results[index] != nil {
+			continue
+		}
+		// a shutdown wins over an idle worker
+		select {
+		case <-stoppingFunc():
+			interrupted.Store(true)
+			break dispatch
+		default:
+		}
+		select {
+		case <-runCtx.Done():
+			break dispatch
+		case <-stoppingFunc():
+			interrupted.Store(true)
+			break dispatch
+		case jobs <- index:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(monitorDone)
+	<-monitorStopped
+
+	switch {
+	case cancelled.Load() || ctx.Err() != nil:
+		return model.RelayBatchStatusCancelled
+	case interrupted.Load():
+		return outcomeInterrupted
+	case errors.Is(runCtx.Err(), context.DeadlineExceeded):
+		for _, result := range results {
+			if result == nil {
+				return model.RelayBatchStatusExpired
+			}
+		}
+	}
+	return model.RelayBatchStatusCompleted
+}
+
+func lineSucceeded(result *outputLine) bool {
+	return result.Error == nil && result.Response != nil && result.Response.StatusCode < http.StatusBadRequest
+}
+
+// interruptRun saves the finished lines of a batch stopped by a shutdown. The
+// batch stays in progress for the requeued task to resume.
+func interruptRun(ctx context.Context, row model.RelayBatch, results []*outputLine) error {
+	saved := make(map[int]string)
+	completed, failed := 0, 0
+	for index, result := range results {
+		if result == nil {
+			continue
+		}
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		saved[index] = string(encoded)
+		if lineSucceeded(result) {
+			completed++
+		} else {
+			failed++
+		}
+	}
+	if err := model.SaveRelayBatchLinesWithDB(model.DB, row.Id, saved); err != nil {
+		return err
+	}
+	if err := model.UpdateRelayBatchCountsWithDB(model.DB, row.Id, completed, failed); err != nil {
+		return err
+	}
+	logger.Infof(ctx, "[batch] interrupted batch_id=%s finished=%d remaining=%d", row.Id, len(saved), len(results)-len(saved))
+	return model.ErrAsyncTaskInterrupted
+}
+
+// loadSavedLines restores the lines a previous run of the batch finished
+// before it was interrupted.
+func loadSavedLines(row model.RelayBatch, count int) ([]*outputLine, error) {
+	saved, err := model.ListRelayBatchLinesWithDB(model.DB, row.Id)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*outputLine, count)
+	for index, encoded := range saved {
+		if index < 0 || index >= count {
+			continue
+		}
+		result := &outputLine{}
+		if err := json.Unmarshal([]byte(encoded), result); err != nil {
+			return nil, err
+		}
+		results[index] = result
+	}
+	return results, nil
+}
+
+func executeLine(ctx context.Context, handler http.Handler, row model.RelayBatch, apiKey string, line inputLine) *outputLine {
+	result := &outputLine{ID: newRequestID(), CustomID: line.CustomID}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, line.URL, bytes.NewReader(line.Body))
+	if err != nil {
+		result.Error = &outputError{Code: "invalid_request", Message: err.Error()}
+		return result
+	}
+	request.Header.Set("Authorization", "Bearer "+apiKey)
+	request.Header.Set("Content-Type", "application/json")
+	clientIP := row.ClientIP
+	if clientIP == "" {
+		clientIP = "127.0.0.1"
+	}
+	request.RemoteAddr = net.JoinHostPort(clientIP, "0")
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	body := bytes.TrimSpace(recorder.Body.Bytes())
+	if !json.Valid(body) {
+		body, _ = json.Marshal(string(body))
+	}
+	result.Response = &outputResponse{
+		StatusCode: recorder.Code,
+		RequestID:  recorder.Header().Get(helper.TraceIDKey),
+		Body:       body,
+	}
+	return result
+}
+
+// loadInputLines parses the JSONL input file. Validation problems are
+// returned as batch errors rather than as an error.
+func loadInputLines(ctx context.Context, row model.RelayBatch) ([]inputLine, []ErrorItem, error) {
+	inputFile, err := filestore.Get(row.InputFileID, row.UserID)
+	if err != nil {
+		if errors.Is(err, filestore.ErrFileNotFound) {
+			return nil, []ErrorItem{{
+				Code:    "file_not_found",
+				Message: fmt.Sprintf("No such File object: %s", row.InputFileID),
+				Param:   "input_file_id",
+			}}, nil
+		}
+		return nil, nil, err
+	}
+	content, err := filestore.ReadAll(ctx, inputFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	return parseInputLines(content, row.Endpoint)
+}
+
+func parseInputLines(content []byte, endpoint string) ([]inputLine, []ErrorItem, error) {
+	lines := make([]inputLine, 0)
+	validationErrors := make([]ErrorItem, 0)
+	customIDs := make(map[string]struct{})
+	addError := func(lineNumber int, param string, code string, message string) {
+		number := lineNumber
+		validationErrors = append(validationErrors, ErrorItem{Code: code, Message: message, Param: param, Line: &number})
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(content)+1)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		line := inputLine{}
+		if err := json.Unmarshal(raw, &line); err != nil {
+			addError(lineNumber, "", "invalid_json_line", "This line is not parseable as valid JSON.")
+			continue
+		}
+		line.CustomID = strings.TrimSpace(line.CustomID)
+		switch {
+		case line.CustomID == "":
+			addError(lineNumber, "custom_id", "missing_required_parameter", "Missing required parameter: 'custom_id'.")
+			continue
+		case !strings.EqualFold(strings.TrimSpace(line.Method), http.MethodPost):
+			addError(lineNumber, "method", "invalid_value", "Only the POST method is supported.")
+			continue
+		case strings.TrimSpace(line.URL) != endpoint:
+			addError(lineNumber, "url", "mismatched_endpoint", fmt.Sprintf("The URL provided for this request does not match the batch endpoint %s.", endpoint))
+			continue
+		}
+		if _, ok := customIDs[line.CustomID]; ok {
+			addError(lineNumber, "custom_id", "duplicate_custom_id", fmt.Sprintf("The custom_id '%s' is used more than once.", line.CustomID))
+			continue
+		}
+		customIDs[line.CustomID] = struct{}{}
+		body, err := normalizeLineBody(line.Body)
+		if err != nil {
+			addError(lineNumber, "body", "invalid_value", err.Error())
+			continue
+		}
+		line.URL = endpoint
+		line.Body = body
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	if len(validationErrors) > 0 {
+		return nil, validationErrors, nil
+	}
+	switch {
+	case len(lines) == 0:
+		return nil, []ErrorItem{{Code: "empty_file", Message: "The input file does not contain any requests.", Param: "input_file_id"}}, nil
+	case len(lines) > maxBatchRequests:
+		return nil, []ErrorItem{{Code: "too_many_requests", Message: fmt.Sprintf("A batch can contain at most %d requests.", maxBatchRequests), Param: "input_file_id"}}, nil
+	}
+	return lines, nil, nil
+}
+
+// normalizeLineBody drops streaming options: batch output records a single
+// JSON response per request.
+func normalizeLineBody(raw json.RawMessage) (json.RawMessage, error) {
+	body := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &body); err != nil || body == nil {
+		return nil, fmt.Errorf("The request body must be a JSON object.")
+	}
+	_, hasStream := body["stream"]
+	_, hasStreamOptions := body["stream_options"]
+	if !hasStream && !hasStreamOptions {
+		return raw, nil
+	}
+	delete(body, "stream")
+	delete(body, "stream_options")
+	return json.Marshal(body)
+}
+
+func storeOutputFile(ctx context.Context, row model.RelayBatch, kind string, content []byte) (model.RelayFile, error) {
+	return filestore.Create(ctx, filestore.UploadRequest{
+		UserID:      row.UserID,
+		Filename:    fmt.Sprintf("%s_%s.jsonl", row.Id, kind),
+		Purpose:     OutputPurpose,
+		ContentType: "application/jsonl",
+		Size:        int64(len(content)),
+		Content:     bytes.NewReader(content),
+	})
+}
+
+func failRun(row model.RelayBatch, fromStatus string, items []ErrorItem) (string, error) {
+	encoded, err := json.Marshal(items)
+	if err != nil {
+		return "", err
+	}
+	return finishRun(row, fromStatus, model.RelayBatchStatusFailed, map[string]any{"errors": string(encoded)})
+}
+
+func finishRun(row model.RelayBatch, fromStatus string, status string, updates map[string]any) (string, error) {
+	if _, err := model.TransitionRelayBatchWithDB(model.DB, row.Id, []string{fromStatus}, status, updates); err != nil {
+		return "", err
+	}
+	// only a resumed run was loaded in progress and may have saved lines
+	if row.Status == model.RelayBatchStatusInProgress {
+		if err := model.DeleteRelayBatchLinesWithDB(model.DB, row.Id); err != nil {
+			logger.Warnf(context.Background(), "[batch] delete saved lines failed batch_id=%s err=%v", row.Id, err)
+		}
+	}
+	return marshalRunResult(row, status)
+}
+
+func marshalRunResult(row model.RelayBatch, status string) (string, error) {
+	encoded, err := json.Marshal(runResult{
+		BatchID:   row.Id,
+		Status:    status,
+		Completed: row.CompletedCount,
+		Failed:    row.FailedCount,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+func newRequestID() string {
+	return "batch_req_" + strings.ReplaceAll(random.GetUUID(), "-", "")
+}
@@ -0,0 +1,300 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/yeying-community/router/common/config"
+	"github.com/yeying-community/router/internal/admin/model"
+	"github.com/yeying-community/router/internal/relay/filestore"
+)
+
+func useTestBatchStore(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=private"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&model.User{}, &model.RelayFile{}, &model.RelayFileUpstream{}, &model.RelayBatch{}, &model.RelayBatchLine{}, &model.AsyncTask{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := db.Create(&model.User{Id: "user-1", Username: "user-1", Password: "password"}).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	previousDB, previousGetToken, previousStopping := model.DB, getTokenFunc, stoppingFunc
+	previousBackend, previousDir := config.FilesStorageBackend, config.FilesStorageDir
+	previousConcurrency, previousRatio := config.BatchConcurrency, config.BatchBillingRatio
+	t.Cleanup(func() {
+		model.DB, getTokenFunc, stoppingFunc = previousDB, previousGetToken, previousStopping
+		config.FilesStorageBackend, config.FilesStorageDir = previousBackend, previousDir
+		config.BatchConcurrency, config.BatchBillingRatio = previousConcurrency, previousRatio
+	})
+	model.DB = db
+	config.FilesStorageBackend = filestore.StorageBackendLocal
+	config.FilesStorageDir = t.TempDir()
+	config.BatchConcurrency = 2
+	getTokenFunc = func(id string) (*model.Token, error) {
+		if id != "token-1" {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return &model.Token{Id: id, Key: "batchkey"}, nil
+	}
+}
+
+func createTestBatch(t *testing.T, endpoint string, input string) model.RelayBatch {
+	t.Helper()
+	file, err := filestore.Create(context.Background(), filestore.UploadRequest{
+		UserID:   "user-1",
+		Filename: "input.jsonl",
+		Purpose:  InputPurpose,
+		Size:     -1,
+		Content:  strings.NewReader(input),
+	})
+	if err != nil {
+		t.Fatalf("upload input: %v", err)
+	}
+	row, err := Create(CreateRequest{
+		UserID:           "user-1",
+		TokenID:          "token-1",
+		InputFileID:      file.Id,
+		Endpoint:         endpoint,
+		CompletionWindow: CompletionWindow,
+		Metadata:         map[string]string{"job": "eval"},
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return row
+}
+
+func readOutputLines(t *testing.T, fileID string) []outputLine {
+	t.Helper()
+	if fileID == "" {
+		return nil
+	}
+	file, err := filestore.Get(fileID, "user-1")
+	if err != nil {
+		t.Fatalf("get output file: %v", err)
+	}
+	if file.Purpose != OutputPurpose {
+		t.Fatalf("output purpose = %q, want %q", file.Purpose, OutputPurpose)
+	}
+	content, err := filestore.ReadAll(context.Background(), file)
+	if err != nil {
+		t.Fatalf("read output file: %v", err)
+	}
+	lines := make([]outputLine, 0)
+	for _, raw := range bytes.Split(bytes.TrimSpace(content), []byte("\n")) {
+		line := outputLine{}
+		if err := json.Unmarshal(raw, &line); err != nil {
+			t.Fatalf("unmarshal output line %s: %v", raw, err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestRunReplaysLinesAndWritesOutputFiles(t *testing.T) {
+	useTestBatchStore(t)
+	row := createTestBatch(t, "/v1/chat/completions", strings.Join([]string{
+		`{"custom_id":"ok-1","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o-mini","stream":true,"messages":[]}}`,
+		`{"custom_id":"bad-1","method":"POST","url":"/v1/chat/completions","body":{"model":"missing","messages":[]}}`,
+		`{"custom_id":"ok-2","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o-mini","messages":[]}}`,
+	}, "\n"))
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Authorization") != "Bearer sk-batchkey" || BatchIDFromContext(r.Context()) != row.Id {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if bytes.Contains(body, []byte(`"stream"`)) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if bytes.Contains(body, []byte("missing")) {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":{"message":"model not found"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"object":"chat.completion"}`)
+	})
+
+	if _, err := Run(context.Background(), handler, row.Id); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	row, err := Get(row.Id, "user-1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if row.Status != model.RelayBatchStatusCompleted || row.TotalCount != 3 || row.CompletedCount != 2 || row.FailedCount != 1 {
+		t.Fatalf("batch = %#v, want completed with 2 ok and 1 failed", row)
+	}
+	object := NewObject(row)
+	if object.InProgressAt == nil || object.FinalizingAt == nil || object.CompletedAt == nil || object.Metadata["job"] != "eval" {
+		t.Fatalf("object = %#v, want status timestamps and metadata", object)
+	}
+
+	output := readOutputLines(t, row.OutputFileID)
+	if len(output) != 2 || output[0].CustomID != "ok-1" || output[1].CustomID != "ok-2" {
+		t.Fatalf("output = %#v, want both successful lines in input order", output)
+	}
+	if output[0].Response.StatusCode != http.StatusOK || string(output[0].Response.Body) != `{"object":"chat.completion"}` {
+		t.Fatalf("output response = %#v", output[0].Response)
+	}
+	errorsOut := readOutputLines(t, row.ErrorFileID)
+	if len(errorsOut) != 1 || errorsOut[0].CustomID != "bad-1" || errorsOut[0].Response.StatusCode != http.StatusNotFound {
+		t.Fatalf("errors = %#v, want the failed request", errorsOut)
+	}
+}
+
+func TestRunResumesBatchInterruptedByShutdown(t *testing.T) {
+	useTestBatchStore(t)
+	config.BatchConcurrency = 1
+	row := createTestBatch(t, "/v1/embeddings", strings.Join([]string{
+		`{"custom_id":"a","method":"POST","url":"/v1/embeddings","body":{"model":"m","input":"a"}}`,
+		`{"custom_id":"b","method":"POST","url":"/v1/embeddings","body":{"model":"m","input":"b"}}`,
+		`{"custom_id":"c","method":"POST","url":"/v1/embeddings","body":{"model":"m","input":"c"}}`,
+	}, "\n"))
+	stopping := make(chan struct{})
+	stoppingFunc = func() <-chan struct{} { return stopping }
+	calls := map[string]int{}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		calls[string(body)]++
+		if len(calls) == 1 {
+			close(stopping)
+		}
+		fmt.Fprint(w, `{"object":"list"}`)
+	})
+
+	if _, err := Run(context.Background(), handler, row.Id); !errors.Is(err, model.ErrAsyncTaskInterrupted) {
+		t.Fatalf("Run err = %v, want ErrAsyncTaskInterrupted", err)
+	}
+	row, err := Get(row.Id, "user-1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	saved, err := model.ListRelayBatchLinesWithDB(model.DB, row.Id)
+	if err != nil || len(saved) != 1 || row.Status != model.RelayBatchStatusInProgress || row.CompletedCount != 1 {
+		t.Fatalf("batch = %#v saved = %v err = %v, want in progress with the first line saved", row, saved, err)
+	}
+
+	stoppingFunc = func() <-chan struct{} { return nil }
+	if _, err := Run(context.Background(), handler, row.Id); err != nil {
+		t.Fatalf("resumed Run: %v", err)
+	}
+	row, err = Get(row.Id, "user-1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if row.Status != model.RelayBatchStatusCompleted || row.CompletedCount != 3 || len(calls) != 3 {
+		t.Fatalf("batch = %#v calls = %v, want completed with every line sent", row, calls)
+	}
+	for body, count := range calls {
+		if count != 1 {
+			t.Fatalf("line %s sent %d times, want once", body, count)
+		}
+	}
+	output := readOutputLines(t, row.OutputFileID)
+	if len(output) != 3 || output[0].CustomID != "a" || output[2].CustomID != "c" {
+		t.Fatalf("output = %#v, want every line in input order", output)
+	}
+	if saved, err := model.ListRelayBatchLinesWithDB(model.DB, row.Id); err != nil || len(saved) != 0 {
+		t.Fatalf("saved = %v err = %v, want saved lines removed", saved, err)
+	}
+}
+
+func TestRunFailsInvalidInput(t *testing.T) {
+	useTestBatchStore(t)
+	row := createTestBatch(t, "/v1/embeddings", strings.Join([]string{
+		`{"custom_id":"a","method":"POST","url":"/v1/embeddings","body":{"model":"m","input":"x"}}`,
+		`{"custom_id":"a","method":"POST","url":"/v1/embeddings","body":{"model":"m","input":"y"}}`,
+		`{"custom_id":"b","method":"POST","url":"/v1/chat/completions","body":{}}`,
+		`not json`,
+	}, "\n"))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatalf("handler called for an invalid batch")
+	})
+
+	if _, err := Run(context.Background(), handler, row.Id); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	row, err := Get(row.Id, "user-1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	object := NewObject(row)
+	if row.Status != model.RelayBatchStatusFailed || object.Errors == nil || len(object.Errors.Data) != 3 {
+		t.Fatalf("batch = %#v errors = %#v, want failed with three line errors", row, object.Errors)
+	}
+	codes := []string{}
+	for _, item := range object.Errors.Data {
+		codes = append(codes, fmt.Sprintf("%s@%d", item.Code, *item.Line))
+	}
+	if strings.Join(codes, ",") != "duplicate_custom_id@2,mismatched_endpoint@3,invalid_json_line@4" {
+		t.Fatalf("codes = %v", codes)
+	}
+}
+
+func TestCancelPendingBatch(t *testing.T) {
+	useTestBatchStore(t)
+	row := createTestBatch(t, "/v1/responses", `{"custom_id":"a","method":"POST","url":"/v1/responses","body":{"model":"m","input":"x"}}`)
+
+	cancelled, err := Cancel(row.Id, "user-1")
+	if err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if cancelled.Status != model.RelayBatchStatusCancelled || cancelled.CancellingAt == 0 || cancelled.CancelledAt == 0 {
+		t.Fatalf("batch = %#v, want cancelled right away", cancelled)
+	}
+	task, err := model.GetAsyncTaskByIDWithDB(model.DB, row.TaskID)
+	if err != nil || task.Status != model.AsyncTaskStatusCanceled {
+		t.Fatalf("task = %#v, %v; want canceled task", task, err)
+	}
+	if _, err := Cancel(row.Id, "user-2"); err != ErrBatchNotFound {
+		t.Fatalf("Cancel by other user err = %v, want ErrBatchNotFound", err)
+	}
+}
+
+func TestCreateRejectsFilesWithOtherPurposes(t *testing.T) {
+	useTestBatchStore(t)
+	file, err := filestore.Create(context.Background(), filestore.UploadRequest{
+		UserID:  "user-1",
+		Purpose: "vision",
+		Size:    -1,
+		Content: strings.NewReader("x"),
+	})
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	_, err = Create(CreateRequest{UserID: "user-1", InputFileID: file.Id, Endpoint: "/v1/chat/completions", CompletionWindow: CompletionWindow})
+	invalidErr, ok := err.(*InvalidRequestError)
+	if !ok || invalidErr.Param != "input_file_id" {
+		t.Fatalf("Create err = %v, want input_file_id error", err)
+	}
+}
+
+func TestApplyBillingRatioOnlyForBatchRequests(t *testing.T) {
+	previous := config.BatchBillingRatio
+	t.Cleanup(func() { config.BatchBillingRatio = previous })
+	config.BatchBillingRatio = 0.5
+	ratio := model.BillingRatioBreakdown{GroupChannelRatio: 2, ModelChannelRatio: 1, EffectiveRatio: 2}
+
+	if got := ApplyBillingRatio(context.Background(), ratio); got.EffectiveRatio != 2 {
+		t.Fatalf("interactive ratio = %v, want 2", got.EffectiveRatio)
+	}
+	if got := ApplyBillingRatio(WithBatchRequest(context.Background(), "batch_1"), ratio); got.EffectiveRatio != 1 {
+		t.Fatalf("batch ratio = %v, want 1", got.EffectiveRatio)
+	}
+}
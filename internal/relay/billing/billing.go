@@ -5,6 +5,7 @@ import (
 	"math"
 	"strings"
 
+	"github.com/yeying-community/router/common/graceful"
 	"github.com/yeying-community/router/common/logger"
 	"github.com/yeying-community/router/internal/admin/model"
 )
@@ -13,7 +14,7 @@ func ReturnPreConsumedQuota(ctx context.Context, preConsumedQuota int64, tokenId
 	if preConsumedQuota == 0 {
 		return
 	}
-	go func(ctx context.Context) {
+	graceful.Go(func() {
 		if strings.TrimSpace(tokenId) != "" {
 			var err error
 			if chargeUserBalance {
@@ -35,7 +36,7 @@ func ReturnPreConsumedQuota(ctx context.Context, preConsumedQuota int64, tokenId
 		}
 		_ = model.CacheUpdateUserQuota(ctx, userId)
 		_ = model.CacheUpdateUserQuotaForGroup(ctx, userId, groupID)
-	}(ctx)
+	})
 }
 
 type LogRouteObserver func(entry *model.Log)
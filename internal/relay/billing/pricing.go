@@ -446,6 +446,17 @@ func ComputeVideoBillingSnapshot(quantity float64, pricing model.ResolvedModelPr
 	return buildSingleSidedBillingSnapshot(quantity, primaryUnitPrice(pricing), pricing, groupRatio)
 }
 
+func ComputeRerankBillingSnapshot(quantity float64, pricing model.ResolvedModelPricing, groupRatio float64) (BillingSnapshot, error) {
+	if quantity <= 0 {
+		return BillingSnapshot{
+			PriceUnit:      normalizePriceUnit(pricing.PriceUnit),
+			Currency:       normalizeCurrency(pricing.Currency),
+			EffectiveRatio: groupRatio,
+		}, nil
+	}
+	return buildSingleSidedBillingSnapshot(quantity, primaryUnitPrice(pricing), pricing, groupRatio)
+}
+
 func FormatPricingLog(pricing model.ResolvedModelPricing, groupRatio float64) string {
 	source := strings.TrimSpace(pricing.Source)
 	if source == "" {
@@ -485,7 +496,9 @@ func chargeAmountFromPrice(price float64, priceUnit string, currency string, qua
 		model.ProviderPriceUnitPerSecond,
 		model.ProviderPriceUnitPerMinute,
 		model.ProviderPriceUnitPerRequest,
-		model.ProviderPriceUnitPerTask:
+		model.ProviderPriceUnitPerTask,
+		model.ProviderPriceUnitPerSearchUnit,
+		model.ProviderPriceUnitPerDocument:
 		return quantity * price * chargeRate * groupRatio, nil
 	default:
 		return quantity * price * chargeRate / 1000 * groupRatio, nil
@@ -647,6 +660,10 @@ func procurementCapacityUnitFromSnapshot(snapshot *BillingSnapshot) string {
 		return "minute"
 	case model.ProviderPriceUnitPerVideo:
 		return "video"
+	case model.ProviderPriceUnitPerSearchUnit:
+		return "search_unit"
+	case model.ProviderPriceUnitPerDocument:
+		return "document"
 	case "", model.ProviderPriceUnitPer1KTokens:
 		return "token"
 	default:
@@ -695,7 +712,9 @@ func billingAmountFromPrice(price float64, priceUnit string, quantity float64) f
 		model.ProviderPriceUnitPerSecond,
 		model.ProviderPriceUnitPerMinute,
 		model.ProviderPriceUnitPerRequest,
-		model.ProviderPriceUnitPerTask:
+		model.ProviderPriceUnitPerTask,
+		model.ProviderPriceUnitPerSearchUnit,
+		model.ProviderPriceUnitPerDocument:
 		return quantity * price
 	default:
 		return quantity * price / 1000
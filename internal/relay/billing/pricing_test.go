@@ -164,6 +164,26 @@ func TestComputeTokenBasedBillingSnapshot(t *testing.T) {
 	}
 }
 
+func TestComputeRerankBillingSnapshotPerSearchUnit(t *testing.T) {
+	pricing := adminmodel.ResolvedModelPricing{
+		Model:      "rerank-v3.5",
+		PriceUnit:  adminmodel.ProviderPriceUnitPerSearchUnit,
+		InputPrice: 0.002,
+		Currency:   adminmodel.ProviderPriceCurrencyUSD,
+	}
+
+	snapshot, err := ComputeRerankBillingSnapshot(3, pricing, 1)
+	if err != nil {
+		t.Fatalf("ComputeRerankBillingSnapshot() error = %v", err)
+	}
+	if math.Abs(snapshot.Amount-0.006) > 1e-9 {
+		t.Fatalf("Amount = %v, want 0.006", snapshot.Amount)
+	}
+	if got := procurementCapacityUnitFromSnapshot(&snapshot); got != "search_unit" {
+		t.Fatalf("capacity unit = %q, want search_unit", got)
+	}
+}
+
 func TestComputeTextBillingSnapshotWithUsageSplitsCachePricing(t *testing.T) {
 	pricing := adminmodel.ResolvedModelPricing{
 		Model:       "gpt-5.4",
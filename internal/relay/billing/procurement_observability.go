@@ -185,7 +185,9 @@ func inferSettlementTruthMode(logRow *model.Log) string {
 		model.ProviderPriceUnitPer1KChars,
 		model.ProviderPriceUnitPerSecond,
 		model.ProviderPriceUnitPerMinute,
-		model.ProviderPriceUnitPerVideo:
+		model.ProviderPriceUnitPerVideo,
+		model.ProviderPriceUnitPerSearchUnit,
+		model.ProviderPriceUnitPerDocument:
 		return SettlementTruthModeUnitBasedFinal
 	default:
 		return SettlementTruthModeHybridUsageFinal
@@ -228,6 +230,10 @@ func procurementCapacityUnit(logRow *model.Log) string {
 		return "minute"
 	case model.ProviderPriceUnitPerVideo:
 		return "video"
+	case model.ProviderPriceUnitPerSearchUnit:
+		return "search_unit"
+	case model.ProviderPriceUnitPerDocument:
+		return "document"
 	case model.ProviderPriceUnitPer1KTokens, "":
 		return "token"
 	default:
@@ -0,0 +1,155 @@
+package capability
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Features are the request traits a channel endpoint may declare support for
+// through ChannelModelEndpointCapabilities. The zero value means the request
+// was not inspected, so it never restricts channel selection.
+type Features struct {
+	ImageURL    bool
+	ImageBase64 bool
+	PDFURL      bool
+	PDFFile     bool
+	Tools       bool
+	Stream      bool
+	NonStream   bool
+}
+
+// Names lists the detected features using the capability json keys, which is
+// also how they appear in filter reasons.
+func (f Features) Names() []string {
+	names := make([]string, 0, 7)
+	if f.ImageURL {
+		names = append(names, "input_image_url")
+	}
+	if f.ImageBase64 {
+		names = append(names, "input_image_base64")
+	}
+	if f.PDFURL {
+		names = append(names, "input_pdf_url")
+	}
+	if f.PDFFile {
+		names = append(names, "input_pdf_file")
+	}
+	if f.Tools {
+		names = append(names, "tools")
+	}
+	if f.Stream {
+		names = append(names, "stream")
+	}
+	if f.NonStream {
+		names = append(names, "non_stream")
+	}
+	return names
+}
+
+// Detect inspects a JSON relay request in the OpenAI chat, Responses or
+// Anthropic messages shape. Bodies that are not JSON objects yield the zero
+// value.
+func Detect(body []byte) Features {
+	payload := map[string]any{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return Features{}
+	}
+	features := Features{}
+	if stream, _ := payload["stream"].(bool); stream {
+		features.Stream = true
+	} else {
+		features.NonStream = true
+	}
+	for _, key := range []string{"tools", "functions"} {
+		if tools, ok := payload[key].([]any); ok && len(tools) > 0 {
+			features.Tools = true
+		}
+	}
+	for _, key := range []string{"messages", "input", "system"} {
+		if value, ok := payload[key]; ok {
+			walk(value, &features)
+		}
+	}
+	return features
+}
+
+func walk(value any, features *Features) {
+	switch typed := value.(type) {
+	case []any:
+		for _, item := range typed {
+			walk(item, features)
+		}
+	case map[string]any:
+		detectContentPart(typed, features)
+		for _, child := range typed {
+			walk(child, features)
+		}
+	}
+}
+
+func detectContentPart(node map[string]any, features *Features) {
+	switch stringField(node, "type") {
+	case "image_url":
+		rawURL := stringField(node, "image_url")
+		if nested, ok := node["image_url"].(map[string]any); ok {
+			rawURL = stringField(nested, "url")
+		}
+		markImage(rawURL, features)
+	case "input_image":
+		markImage(stringField(node, "image_url"), features)
+	case "image":
+		if source, ok := node["source"].(map[string]any); ok {
+			switch stringField(source, "type") {
+			case "url":
+				features.ImageURL = true
+			case "base64":
+				features.ImageBase64 = true
+			}
+		}
+	case "document":
+		if source, ok := node["source"].(map[string]any); ok {
+			switch stringField(source, "type") {
+			case "url":
+				features.PDFURL = true
+			case "base64", "file":
+				features.PDFFile = true
+			}
+		}
+	case "file":
+		if file, ok := node["file"].(map[string]any); ok && (stringField(file, "file_data") != "" || stringField(file, "file_id") != "") {
+			features.PDFFile = true
+		}
+	case "input_file":
+		if stringField(node, "file_url") != "" {
+			features.PDFURL = true
+		}
+		if stringField(node, "file_data") != "" || stringField(node, "file_id") != "" {
+			features.PDFFile = true
+		}
+	}
+}
+
+func markImage(rawURL string, features *Features) {
+	switch {
+	case rawURL == "":
+	case strings.HasPrefix(strings.ToLower(rawURL), "data:"):
+		features.ImageBase64 = true
+	default:
+		features.ImageURL = true
+	}
+}
+
+func stringField(node map[string]any, key string) string {
+	value, ok := node[key]
+	if !ok || value == nil {
+		return ""
+	}
+	if text, ok := value.(string); ok {
+		return strings.TrimSpace(text)
+	}
+	if _, ok := value.(map[string]any); ok {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprint(value))
+}
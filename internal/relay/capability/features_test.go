@@ -0,0 +1,54 @@
+package capability
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	cases := []struct {
+		name string
+		body string
+		want Features
+	}{
+		{
+			name: "chat with tools and remote image",
+			body: `{"stream":true,"tools":[{"type":"function"}],"messages":[{"role":"user","content":[{"type":"text","text":"hi"},{"type":"image_url","image_url":{"url":"https://example.com/a.png"}}]}]}`,
+			want: Features{ImageURL: true, Tools: true, Stream: true},
+		},
+		{
+			name: "chat with inline image and pdf file",
+			body: `{"messages":[{"role":"user","content":[{"type":"image_url","image_url":"data:image/png;base64,AAAA"},{"type":"file","file":{"file_data":"data:application/pdf;base64,AAAA"}}]}]}`,
+			want: Features{ImageBase64: true, PDFFile: true, NonStream: true},
+		},
+		{
+			name: "responses input file url",
+			body: `{"input":[{"role":"user","content":[{"type":"input_file","file_url":"https://example.com/a.pdf"},{"type":"input_image","image_url":"https://example.com/b.png"}]}]}`,
+			want: Features{ImageURL: true, PDFURL: true, NonStream: true},
+		},
+		{
+			name: "anthropic document and image blocks",
+			body: `{"stream":false,"messages":[{"role":"user","content":[{"type":"document","source":{"type":"base64","media_type":"application/pdf","data":"AAAA"}},{"type":"image","source":{"type":"url","url":"https://example.com/a.png"}}]}]}`,
+			want: Features{ImageURL: true, PDFFile: true, NonStream: true},
+		},
+		{
+			name: "not json",
+			body: `model=x`,
+			want: Features{},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Detect([]byte(tc.body)); got != tc.want {
+				t.Fatalf("Detect() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFeaturesNames(t *testing.T) {
+	got := Features{PDFFile: true, Tools: true, Stream: true}.Names()
+	if want := []string{"input_pdf_file", "tools", "stream"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Names() = %v, want %v", got, want)
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/yeying-community/router/common/client"
 	"github.com/yeying-community/router/common/config"
 	"github.com/yeying-community/router/common/ctxkey"
+	"github.com/yeying-community/router/common/graceful"
 	"github.com/yeying-community/router/common/logger"
 	"github.com/yeying-community/router/internal/admin/model"
 	adminmodel "github.com/yeying-community/router/internal/admin/model"
@@ -277,27 +278,29 @@ func RelayAudioHelper(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatus
 	billingSnapshot.ChargeAmount = quota
 	billingSnapshot.SetBillingRatioBreakdown(billingRatio)
 	defer func(ctx context.Context) {
-		go billing.PostConsumeQuota(
-			ctx,
-			tokenId,
-			quotaDelta,
-			quota,
-			userId,
-			group,
-			channelId,
-			pricing,
-			groupRatio,
-			audioModel,
-			tokenName,
-			billingPlan.ChargeUserBalance(),
-			billingPlan.ChargeTokenQuota(),
-			billingPlan.PackageReservation,
-			billingSnapshot,
-			func(entry *model.Log) {
-				applyRouteObservabilityToLog(entry, meta, audioModel)
-				annotateAudioPreConsumeLogFields(entry, estimatedQuantity, estimatedChargeAmount)
-			},
-		)
+		graceful.Go(func() {
+			billing.PostConsumeQuota(
+				ctx,
+				tokenId,
+				quotaDelta,
+				quota,
+				userId,
+				group,
+				channelId,
+				pricing,
+				groupRatio,
+				audioModel,
+				tokenName,
+				billingPlan.ChargeUserBalance(),
+				billingPlan.ChargeTokenQuota(),
+				billingPlan.PackageReservation,
+				billingSnapshot,
+				func(entry *model.Log) {
+					applyRouteObservabilityToLog(entry, meta, audioModel)
+					annotateInputPreConsumeLogFields(entry, estimatedQuantity, estimatedChargeAmount)
+				},
+			)
+		})
 		if billingPlan.UsesRequestPackage() {
 			settleRelayBillingPlan(ctx, billingPlan, quota)
 		}
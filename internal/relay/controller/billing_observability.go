@@ -20,12 +20,14 @@ const (
 	billingEstimateSourceAudioTTSInputChars     = "audio_tts_input_chars"
 	billingEstimateSourceAudioPreconsumeQuota   = "audio_preconsume_quota"
 	billingEstimateSourceVideoRequestRule       = "video_request_rule"
+	billingEstimateSourceRerankDocuments        = "rerank_request_documents"
 	billingEstimateSourceRealtimeUpstreamUsage  = "realtime_upstream_usage"
 	billingEstimateSourceRealtimeUnmeteredProxy = "realtime_unmetered_proxy"
 	billingSettlementModeUsageFinal             = "usage_final"
 	billingSettlementModeAudioRequestFinal      = "audio_request_final"
 	billingSettlementModeAudioResponseTextFinal = "audio_response_text_final"
 	billingSettlementModeVideoTaskCreated       = "video_task_created"
+	billingSettlementModeRerankRequestFinal     = "rerank_request_final"
 	billingSettlementModeRealtimeUsageFinal     = "realtime_usage_final"
 	billingSettlementModeRealtimeUnmeteredProxy = "realtime_unmetered_proxy"
 	billingSettlementModeResponsesImagePending  = "responses_image_tool_pending"
@@ -104,6 +106,21 @@ func annotateVideoBillingSnapshot(snapshot *billing.BillingSnapshot, pricingSour
 	snapshot.SettlementMode = billingSettlementModeVideoTaskCreated
 }
 
+func annotateRerankBillingSnapshot(snapshot *billing.BillingSnapshot, pricingSource string, upstreamReported bool) {
+	if snapshot == nil {
+		return
+	}
+	snapshot.PricingSource = strings.TrimSpace(pricingSource)
+	snapshot.EstimateSource = billingEstimateSourceRerankDocuments
+	if upstreamReported {
+		snapshot.UsageSource = billingUsageSourceUpstreamUsage
+		snapshot.SettlementMode = billingSettlementModeUsageFinal
+		return
+	}
+	snapshot.UsageSource = billingUsageSourceRequestPayload
+	snapshot.SettlementMode = billingSettlementModeRerankRequestFinal
+}
+
 func annotateTextEstimateLogFields(logRow *adminmodel.Log, result tokenestimate.EstimateResult) {
 	if logRow == nil {
 		return
@@ -125,7 +142,9 @@ func annotateTextPreConsumeLogFields(logRow *adminmodel.Log, estimatedPromptToke
 	logRow.BillingChargeDeltaAmount = logRow.BillingChargeAmount - estimatedChargeAmount
 }
 
-func annotateAudioPreConsumeLogFields(logRow *adminmodel.Log, estimatedQuantity int, estimatedChargeAmount int64) {
+// annotateInputPreConsumeLogFields records the estimate of requests billed on
+// a single input quantity, such as audio or rerank.
+func annotateInputPreConsumeLogFields(logRow *adminmodel.Log, estimatedQuantity int, estimatedChargeAmount int64) {
 	if logRow == nil {
 		return
 	}
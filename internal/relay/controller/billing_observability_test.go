@@ -155,13 +155,13 @@ func TestAnnotateTextPreConsumeLogFields(t *testing.T) {
 	}
 }
 
-func TestAnnotateAudioPreConsumeLogFields(t *testing.T) {
+func TestAnnotateInputPreConsumeLogFields(t *testing.T) {
 	logRow := &adminmodel.Log{
 		PromptTokens:          28,
 		BillingChargeAmount:   9,
 		EstimatedPromptTokens: 1,
 	}
-	annotateAudioPreConsumeLogFields(logRow, 40, 13)
+	annotateInputPreConsumeLogFields(logRow, 40, 13)
 	if logRow.EstimatedPromptTokens != 40 {
 		t.Fatalf("EstimatedPromptTokens = %d, want 40", logRow.EstimatedPromptTokens)
 	}
@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yeying-community/router/common"
+	"github.com/yeying-community/router/common/config"
+	"github.com/yeying-community/router/common/ctxkey"
+	"github.com/yeying-community/router/internal/relay/adaptor/openai"
+	relaychannel "github.com/yeying-community/router/internal/relay/channel"
+	"github.com/yeying-community/router/internal/relay/filestore"
+	"github.com/yeying-community/router/internal/relay/meta"
+)
+
+// prepareFileReferences rewrites gateway file ids in the request body for the
+// selected channel. The original body is kept so a retry on another channel
+// resolves the references again instead of reusing provider ids that belong
+// to the failed channel.
+func prepareFileReferences(c *gin.Context, meta *meta.Meta) error {
+	rawBody, err := common.GetRequestBody(c)
+	if err != nil {
+		return err
+	}
+	if original, ok := c.Get(ctxkey.FilesOriginalRequestBody); ok {
+		if body, castOK := original.([]byte); castOK && len(body) > 0 {
+			rawBody = body
+		}
+	}
+	resolved, changed, err := filestore.ResolveReferences(c.Request.Context(), rawBody, fileReferenceTarget(meta))
+	if err != nil {
+		return err
+	}
+	if !changed {
+		return nil
+	}
+	c.Set(ctxkey.FilesOriginalRequestBody, rawBody)
+	c.Set(ctxkey.KeyRequestBody, resolved)
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(resolved))
+	return nil
+}
+
+func fileReferenceTarget(meta *meta.Meta) filestore.Target {
+	target := filestore.Target{
+		UserID:    meta.UserId,
+		ChannelID: meta.ChannelId,
+	}
+	// only OpenAI channels are known to expose a compatible /v1/files; every
+	// other upstream gets the content inline.
+	if config.FilesProviderUploadEnabled && meta.ChannelProtocol == relaychannel.OpenAI {
+		endpoint := openai.GetFullRequestURL(meta.BaseURL, "/v1/files", meta.ChannelProtocol)
+		target.Uploader = filestore.NewOpenAIUploader(endpoint, meta.APIKey)
+	}
+	return target
+}
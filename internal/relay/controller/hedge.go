@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/yeying-community/router/common/helper"
+	"github.com/yeying-community/router/common/logger"
+	adminmodel "github.com/yeying-community/router/internal/admin/model"
+	"github.com/yeying-community/router/internal/relay/billing"
+	"github.com/yeying-community/router/internal/relay/meta"
+	"github.com/yeying-community/router/internal/relay/model"
+)
+
+// recordHedgeLoserCost logs an unbilled entry for a hedged attempt that lost
+// its race, so the upstream spend is still attributed to the channel's
+// procurement batches. Without usage, the prompt estimate stands in for the
+// tokens the upstream had already processed when it was canceled.
+func recordHedgeLoserCost(ctx context.Context, usage *model.Usage, meta *meta.Meta, textRequest *model.GeneralOpenAIRequest, pricing adminmodel.ResolvedModelPricing, billingRatio adminmodel.BillingRatioBreakdown, promptTokens int) {
+	if usage == nil {
+		usage = &model.Usage{PromptTokens: promptTokens}
+	}
+	if usage.PromptTokens+usage.CompletionTokens == 0 {
+		return
+	}
+	adminmodel.RecordChannelLimitTokens(meta.ChannelId, meta.OriginModelName, usage.PromptTokens+usage.CompletionTokens)
+	settlementPricing := adminmodel.ResolveTextUsagePricing(pricing, meta.UpstreamRequestPath, usage.PromptTokens, usage.CompletionTokens)
+	billingSnapshot, err := billing.ComputeTextBillingSnapshotWithUsage(*usage, settlementPricing, billingRatio.EffectiveRatio)
+	if err != nil {
+		logger.Error(ctx, "calculate hedge loser billing snapshot failed: "+err.Error())
+		return
+	}
+	billingSnapshot.ChargeAmount = 0
+	billingSnapshot.SetBillingRatioBreakdown(billingRatio)
+	entry := &adminmodel.Log{
+		UserId:           meta.UserId,
+		GroupId:          meta.Group,
+		ChannelId:        meta.ChannelId,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		ModelName:        textRequest.Model,
+		TokenName:        meta.TokenName,
+		Quota:            0,
+		Content:          "hedged attempt lost the race, recorded for procurement cost only",
+		IsStream:         meta.IsStream,
+		ElapsedTime:      helper.CalcElapsedTime(meta.StartTime),
+	}
+	applyRouteObservabilityToLog(entry, meta, textRequest.Model)
+	billingSnapshot.ApplyToLog(entry)
+	billing.ApplyProcurementCostObservation(entry)
+	adminmodel.RecordConsumeLog(ctx, entry)
+	billing.RecordProcurementConsumptionObservation(ctx, entry)
+}
@@ -13,7 +13,9 @@ import (
 	"github.com/yeying-community/router/common"
 	"github.com/yeying-community/router/common/config"
 	"github.com/yeying-community/router/common/logger"
+	"github.com/yeying-community/router/common/tracing"
 	"github.com/yeying-community/router/internal/admin/model"
+	"github.com/yeying-community/router/internal/metrics"
 	"github.com/yeying-community/router/internal/relay/adaptor/anthropic"
 	"github.com/yeying-community/router/internal/relay/adaptor/openai"
 	"github.com/yeying-community/router/internal/relay/billing"
@@ -23,6 +25,7 @@ import (
 	relaymodel "github.com/yeying-community/router/internal/relay/model"
 	"github.com/yeying-community/router/internal/relay/relaymode"
 	"github.com/yeying-community/router/internal/tokenestimate"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func getAndValidateTextRequest(c *gin.Context, relayMode int) (*relaymodel.GeneralOpenAIRequest, []byte, error) {
@@ -106,7 +109,15 @@ func getAvailableUserBalanceForBilling(ctx context.Context, userID string, group
 	return userBalanceAmount, nil
 }
 
-func preConsumeQuota(ctx context.Context, preConsumedQuota int64, meta *meta.Meta, billingPlan relayBillingPlan) (int64, *relaymodel.ErrorWithStatusCode) {
+func preConsumeQuota(ctx context.Context, preConsumedQuota int64, meta *meta.Meta, billingPlan relayBillingPlan) (reserved int64, bizErr *relaymodel.ErrorWithStatusCode) {
+	ctx, span := tracing.Start(ctx, "billing.pre_consume", attribute.Int64("router.quota", preConsumedQuota))
+	defer func() {
+		span.SetAttributes(attribute.Int64("router.reserved_quota", reserved))
+		if bizErr != nil {
+			tracing.Fail(span, bizErr.Message)
+		}
+		span.End()
+	}()
 	var err error
 	chargeUserBalance := billingPlan.ChargeUserBalance()
 	chargeTokenQuota := billingPlan.ChargeTokenQuota()
@@ -221,6 +232,8 @@ func consumeTokenRequestCount(ctx context.Context, tokenID string, requestCount
 }
 
 func postConsumeQuota(ctx context.Context, usage *relaymodel.Usage, meta *meta.Meta, textRequest *relaymodel.GeneralOpenAIRequest, pricing model.ResolvedModelPricing, preConsumedQuota int64, estimatedOutputTokens int, estimatedChargeAmount int64, billingRatio model.BillingRatioBreakdown, estimateResult tokenestimate.EstimateResult, responsesImageTools []responsesImageToolSpec, systemPromptReset bool, billingPlan relayBillingPlan) {
+	ctx, span := tracing.Start(ctx, "billing.post_consume", attribute.Int64("router.pre_consumed_quota", preConsumedQuota))
+	defer span.End()
 	if usage == nil {
 		logger.Error(ctx, "usage is nil, which is unexpected")
 		releaseRelayBillingPlan(ctx, billingPlan)
@@ -232,6 +245,8 @@ func postConsumeQuota(ctx context.Context, usage *relaymodel.Usage, meta *meta.M
 	promptTokens := usage.PromptTokens
 	completionTokens := usage.CompletionTokens
 	quota := preConsumedQuota
+	model.RecordChannelLimitTokens(meta.ChannelId, meta.OriginModelName, promptTokens+completionTokens)
+	model.RecordRateLimitTokens(meta.RateLimitScopes, promptTokens+completionTokens)
 	settlementPricing := model.ResolveTextUsagePricing(pricing, meta.UpstreamRequestPath, promptTokens, completionTokens)
 	billingSnapshot, snapshotErr := billing.ComputeTextBillingSnapshotWithUsage(*usage, settlementPricing, groupRatio)
 	if snapshotErr != nil {
@@ -258,6 +273,8 @@ func postConsumeQuota(ctx context.Context, usage *relaymodel.Usage, meta *meta.M
 	}
 	var err error
 	quotaDelta := quota - preConsumedQuota
+	metrics.ObserveQuota(meta.OriginModelName, meta.ChannelId, meta.Group, preConsumedQuota, quota)
+	span.SetAttributes(attribute.Int64("router.quota", quota))
 	if strings.TrimSpace(meta.TokenId) != "" && chargeTokenQuota {
 		if chargeUserBalance {
 			err = model.PostConsumeTokenQuota(meta.TokenId, quotaDelta)
@@ -324,6 +341,7 @@ func postConsumeQuota(ctx context.Context, usage *relaymodel.Usage, meta *meta.M
 	billing.RecordProcurementConsumptionObservation(ctx, entry)
 	model.UpdateUserUsedQuotaAndRequestCount(meta.UserId, quota)
 	model.UpdateChannelUsedQuota(meta.ChannelId, quota)
+	model.UpdateChannelKeyUsage(meta.ChannelKeyID, quota)
 	consumeTokenRequestCount(ctx, meta.TokenId, 1)
 }
 
@@ -25,6 +25,7 @@ import (
 	"github.com/yeying-community/router/internal/admin/model"
 	adminmodel "github.com/yeying-community/router/internal/admin/model"
 	"github.com/yeying-community/router/internal/relay"
+	relayadaptor "github.com/yeying-community/router/internal/relay/adaptor"
 	aliadaptor "github.com/yeying-community/router/internal/relay/adaptor/ali"
 	"github.com/yeying-community/router/internal/relay/adaptor/openai"
 	"github.com/yeying-community/router/internal/relay/billing"
@@ -419,6 +420,33 @@ func getImageEditRequest(c *gin.Context) (*relaymodel.ImageRequest, *multipart.F
 	return imageRequest, form, nil
 }
 
+// getImageVariationRequest reads a variations upload. Variations carry no
+// prompt, so only the image file is required.
+func getImageVariationRequest(c *gin.Context) (*relaymodel.ImageRequest, *multipart.Form, error) {
+	imageRequest, form, err := getImageEditRequest(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	if imageRequest.Model == "" {
+		imageRequest.Model = "dall-e-2"
+	}
+	if imageRequest.Size == "" {
+		imageRequest.Size = "1024x1024"
+	}
+	return imageRequest, form, nil
+}
+
+// usesImageVariationEmulation reports whether the channel protocol lacks a
+// variations endpoint and goes through adaptor.ImageVariationAdaptor instead.
+func usesImageVariationEmulation(channelProtocol int) bool {
+	switch channelProtocol {
+	case relaychannel.Ali, relaychannel.Replicate, relaychannel.VolcEngine:
+		return true
+	default:
+		return false
+	}
+}
+
 func buildMultipartImageEditBody(form *multipart.Form, imageRequest *relaymodel.ImageRequest) (*bytes.Buffer, string, error) {
 	if form == nil {
 		return nil, "", errors.New("multipart form is required")
@@ -531,9 +559,9 @@ func getImageSizeRatio(model string, size string) float64 {
 	return 1
 }
 
-func validateImageRequest(imageRequest *relaymodel.ImageRequest, _ *meta.Meta) *relaymodel.ErrorWithStatusCode {
+func validateImageRequest(imageRequest *relaymodel.ImageRequest, requestMeta *meta.Meta) *relaymodel.ErrorWithStatusCode {
 	// check prompt length
-	if imageRequest.Prompt == "" {
+	if imageRequest.Prompt == "" && (requestMeta == nil || requestMeta.Mode != relaymode.ImagesVariations) {
 		return openai.ErrorWrapper(errors.New("prompt is required"), "prompt_missing", http.StatusBadRequest)
 	}
 
@@ -587,9 +615,12 @@ func RelayImageHelper(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatus
 		form         *multipart.Form
 		err          error
 	)
-	if relayMode == relaymode.ImagesEdits {
+	switch relayMode {
+	case relaymode.ImagesEdits:
 		imageRequest, form, err = getImageEditRequest(c)
-	} else {
+	case relaymode.ImagesVariations:
+		imageRequest, form, err = getImageVariationRequest(c)
+	default:
 		imageRequest, err = getImageRequest(c, meta.Mode)
 	}
 	if err != nil {
@@ -663,6 +694,15 @@ func RelayImageHelper(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatus
 			c.Request.Header.Set("Content-Type", contentType)
 			requestBody = bytes.NewBuffer(requestBodyBuffer.Bytes())
 		}
+	} else if relayMode == relaymode.ImagesVariations {
+		if !usesImageVariationEmulation(meta.ChannelProtocol) {
+			requestBodyBuffer, contentType, buildErr := buildMultipartImageEditBody(form, imageRequest)
+			if buildErr != nil {
+				return openai.ErrorWrapper(buildErr, "marshal_image_request_failed", http.StatusInternalServerError)
+			}
+			c.Request.Header.Set("Content-Type", contentType)
+			requestBody = bytes.NewBuffer(requestBodyBuffer.Bytes())
+		}
 	} else if isModelMapped || meta.ChannelProtocol == relaychannel.Azure { // make Azure channel request body
 		jsonStr, err := json.Marshal(imageRequest)
 		if err != nil {
@@ -680,7 +720,22 @@ func RelayImageHelper(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatus
 	adaptor.Init(meta)
 
 	// these adaptors need to convert the request
-	if relayMode != relaymode.ImagesEdits {
+	if relayMode == relaymode.ImagesVariations && usesImageVariationEmulation(meta.ChannelProtocol) {
+		variationAdaptor, ok := adaptor.(relayadaptor.ImageVariationAdaptor)
+		if !ok {
+			return openai.ErrorWrapper(fmt.Errorf("image variations are not supported by channel %s", adaptor.GetChannelName()), "image_variations_not_supported", http.StatusBadRequest)
+		}
+		finalRequest, err := variationAdaptor.ConvertImageVariationRequest(imageRequest, form.File["image"][0])
+		if err != nil {
+			return openai.ErrorWrapper(err, "convert_image_request_failed", http.StatusInternalServerError)
+		}
+		jsonStr, err := json.Marshal(finalRequest)
+		if err != nil {
+			return openai.ErrorWrapper(err, "marshal_image_request_failed", http.StatusInternalServerError)
+		}
+		c.Request.Header.Set("Content-Type", "application/json")
+		requestBody = bytes.NewBuffer(jsonStr)
+	} else if relayMode == relaymode.ImagesGenerations {
 		switch meta.ChannelProtocol {
 		case relaychannel.Zhipu,
 			relaychannel.Ali,
@@ -938,6 +993,7 @@ func RelayImageHelper(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatus
 		model.UpdateUserUsedQuotaAndRequestCount(meta.UserId, quota)
 		channelId := c.GetString(ctxkey.ChannelId)
 		model.UpdateChannelUsedQuota(channelId, quota)
+		model.UpdateChannelKeyUsage(meta.ChannelKeyID, quota)
 		consumeTokenRequestCount(ctx, meta.TokenId, 1)
 	}(c.Request.Context())
 	groupQuotaSettled = true
@@ -11,7 +11,10 @@ import (
 
 	"github.com/gin-gonic/gin"
 	adminmodel "github.com/yeying-community/router/internal/admin/model"
+	relaychannel "github.com/yeying-community/router/internal/relay/channel"
+	"github.com/yeying-community/router/internal/relay/meta"
 	relaymodel "github.com/yeying-community/router/internal/relay/model"
+	"github.com/yeying-community/router/internal/relay/relaymode"
 )
 
 func TestGetImageRequestAppliesDefaults(t *testing.T) {
@@ -83,6 +86,55 @@ func TestGetImageEditRequestParsesMultipartForm(t *testing.T) {
 	}
 }
 
+func TestGetImageVariationRequestAllowsMissingPrompt(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("image", "test.png")
+	if err != nil {
+		t.Fatalf("CreateFormFile(image) error = %v", err)
+	}
+	if _, err := part.Write([]byte("png-bytes")); err != nil {
+		t.Fatalf("part.Write() error = %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("writer.Close() error = %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Request = httptest.NewRequest("POST", "/v1/images/variations", body)
+	ctx.Request.Header.Set("Content-Type", writer.FormDataContentType())
+
+	req, form, err := getImageVariationRequest(ctx)
+	if err != nil {
+		t.Fatalf("getImageVariationRequest() error = %v", err)
+	}
+	if req.Model != "dall-e-2" || req.Size != "1024x1024" || req.N != 1 {
+		t.Fatalf("request = %#v, want dall-e-2 1024x1024 defaults", req)
+	}
+	if form == nil || len(form.File["image"]) != 1 {
+		t.Fatalf("image file count = %d, want 1", len(form.File["image"]))
+	}
+	if err := validateImageRequest(req, &meta.Meta{Mode: relaymode.ImagesVariations}); err != nil {
+		t.Fatalf("validateImageRequest() error = %v, want variations without prompt accepted", err)
+	}
+}
+
+func TestUsesImageVariationEmulation(t *testing.T) {
+	for protocol, want := range map[int]bool{
+		relaychannel.OpenAI:     false,
+		relaychannel.Azure:      false,
+		relaychannel.Ali:        true,
+		relaychannel.Replicate:  true,
+		relaychannel.VolcEngine: true,
+	} {
+		if got := usesImageVariationEmulation(protocol); got != want {
+			t.Fatalf("usesImageVariationEmulation(%d) = %t, want %t", protocol, got, want)
+		}
+	}
+}
+
 func TestBuildMultipartImageEditBodyRewritesModelField(t *testing.T) {
 	form := &multipart.Form{
 		Value: map[string][]string{
@@ -202,6 +202,7 @@ func recordRealtimeProxyLog(c *gin.Context, relayMeta *meta.Meta, upstreamURL st
 	billing.RecordProcurementConsumptionObservation(c.Request.Context(), entry)
 	adminmodel.UpdateUserUsedQuotaAndRequestCount(relayMeta.UserId, int64(entry.Quota))
 	adminmodel.UpdateChannelUsedQuota(relayMeta.ChannelId, int64(entry.Quota))
+	adminmodel.UpdateChannelKeyUsage(relayMeta.ChannelKeyID, int64(entry.Quota))
 	consumeTokenRequestCount(c.Request.Context(), relayMeta.TokenId, 1)
 }
 
@@ -0,0 +1,220 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yeying-community/router/common"
+	"github.com/yeying-community/router/common/graceful"
+	"github.com/yeying-community/router/common/logger"
+	adminmodel "github.com/yeying-community/router/internal/admin/model"
+	"github.com/yeying-community/router/internal/relay"
+	"github.com/yeying-community/router/internal/relay/adaptor"
+	"github.com/yeying-community/router/internal/relay/adaptor/openai"
+	"github.com/yeying-community/router/internal/relay/batch"
+	"github.com/yeying-community/router/internal/relay/billing"
+	"github.com/yeying-community/router/internal/relay/meta"
+	relaymodel "github.com/yeying-community/router/internal/relay/model"
+)
+
+// rerankDocumentsPerSearchUnit follows Cohere: one search unit covers a
+// query over up to 100 documents.
+const rerankDocumentsPerSearchUnit = 100
+
+func RelayRerankHelper(c *gin.Context) *relaymodel.ErrorWithStatusCode {
+	ctx := c.Request.Context()
+	meta := meta.GetByContext(c)
+	rerankRequest := &relaymodel.RerankRequest{}
+	if err := common.UnmarshalBodyReusable(c, rerankRequest); err != nil {
+		return openai.ErrorWrapper(err, "invalid_rerank_request", http.StatusBadRequest)
+	}
+	if err := rerankRequest.Validate(); err != nil {
+		return openai.ErrorWrapper(err, "invalid_rerank_request", http.StatusBadRequest)
+	}
+	documents, _ := rerankRequest.DocumentTexts()
+
+	meta.OriginModelName = rerankRequest.Model
+	rerankRequest.Model, _ = getMappedModelName(rerankRequest.Model, meta.ModelMapping)
+	meta.ActualModelName = rerankRequest.Model
+	meta.EndpointPolicies = adminmodel.CacheGetChannelModelEndpointPolicies(meta.ChannelId, adminmodel.ChannelModelEndpointRerank, meta.OriginModelName, rerankRequest.Model)
+	meta.EndpointPolicy = adminmodel.CacheGetChannelModelEndpointPolicy(meta.ChannelId, adminmodel.ChannelModelEndpointRerank, meta.OriginModelName, rerankRequest.Model)
+	if err := ApplyEndpointAccessPolicies(c, meta); err != nil {
+		return openai.ErrorWrapper(err, "endpoint_policy_failed", http.StatusInternalServerError)
+	}
+
+	relayAdaptor := relay.GetAdaptor(meta.APIType)
+	if relayAdaptor == nil {
+		return openai.ErrorWrapper(fmt.Errorf("invalid api type: %d", meta.APIType), "invalid_api_type", http.StatusBadRequest)
+	}
+	rerankAdaptor, ok := relayAdaptor.(adaptor.RerankAdaptor)
+	if !ok {
+		return openai.ErrorWrapper(fmt.Errorf("channel %s does not support rerank", relayAdaptor.GetChannelName()), "unsupported_channel_endpoint", http.StatusBadRequest)
+	}
+	relayAdaptor.Init(meta)
+
+	billingRatio := batch.ApplyBillingRatio(ctx, adminmodel.GetRouteBillingRatio(meta.Group, meta.OriginModelName, meta.ChannelId))
+	groupRatio := billingRatio.EffectiveRatio
+	pricing, err := adminmodel.ResolveChannelModelPricing(meta.ChannelProtocol, meta.ChannelModelConfigs, rerankRequest.Model)
+	if err != nil {
+		if groupRatio == 0 {
+			pricing = adminmodel.ResolvedModelPricing{
+				Model:     rerankRequest.Model,
+				Type:      adminmodel.ProviderModelTypeRerank,
+				PriceUnit: adminmodel.ProviderPriceUnitPer1KTokens,
+				Currency:  adminmodel.ProviderPriceCurrencyUSD,
+				Source:    "group_free",
+			}
+		} else {
+			return openai.ErrorWrapper(err, "model_pricing_not_configured", http.StatusServiceUnavailable)
+		}
+	}
+	estimatedTokens := estimateRerankTokens(rerankRequest.Query, documents, rerankRequest.Model)
+	estimatedQuantity, _ := rerankBillingQuantity(pricing.PriceUnit, len(documents), estimatedTokens, nil)
+	preConsumedSnapshot, err := billing.ComputeRerankBillingSnapshot(estimatedQuantity, pricing, groupRatio)
+	if err != nil {
+		return openai.ErrorWrapper(err, "calculate_rerank_quota_failed", http.StatusInternalServerError)
+	}
+	preConsumedSnapshot.SetBillingRatioBreakdown(billingRatio)
+	if err := billing.ApplyEstimatedProcurementCostFloor(&preConsumedSnapshot, meta.ChannelId, rerankRequest.Model); err != nil {
+		return openai.ErrorWrapper(err, "calculate_rerank_quota_failed", http.StatusInternalServerError)
+	}
+	estimatedChargeAmount := preConsumedSnapshot.ChargeAmount
+	billingPlan, quotaErr := reserveRelayQuota(ctx, meta, estimatedChargeAmount)
+	if quotaErr != nil {
+		return quotaErr
+	}
+	groupQuotaSettled := false
+	defer func() {
+		if !groupQuotaSettled {
+			releaseRelayBillingPlan(ctx, billingPlan)
+		}
+	}()
+	preConsumedQuota, bizErr := preConsumeQuota(ctx, estimatedChargeAmount, meta, billingPlan)
+	if bizErr != nil {
+		return bizErr
+	}
+	preConsumedQuotaSettled := false
+	defer func() {
+		if !preConsumedQuotaSettled && preConsumedQuota > 0 {
+			billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId, meta.UserId, meta.Group, billingPlan.ChargeUserBalance() && billingPlan.ChargeTokenQuota())
+		}
+	}()
+
+	upstreamRequest, err := rerankAdaptor.ConvertRerankRequest(rerankRequest)
+	if err != nil {
+		return openai.ErrorWrapper(err, "convert_request_failed", http.StatusBadRequest)
+	}
+	requestBody, err := json.Marshal(upstreamRequest)
+	if err != nil {
+		return openai.ErrorWrapper(err, "marshal_request_failed", http.StatusInternalServerError)
+	}
+	resp, err := relayAdaptor.DoRequest(c, meta, bytes.NewReader(requestBody))
+	if err != nil {
+		return openai.ErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+	}
+	if isErrorHappened(meta, resp) {
+		return RelayErrorHandler(meta, resp)
+	}
+	rerankResponse, respErr := rerankAdaptor.DoRerankResponse(resp, meta)
+	if respErr != nil {
+		return respErr
+	}
+	fillRerankResultDocuments(rerankResponse, documents, rerankRequest.ShouldReturnDocuments())
+	rerankResponse.Model = meta.OriginModelName
+
+	quantity, upstreamReported := rerankBillingQuantity(pricing.PriceUnit, len(documents), estimatedTokens, rerankResponse.Usage)
+	billingSnapshot, err := billing.ComputeRerankBillingSnapshot(quantity, pricing, groupRatio)
+	if err != nil {
+		logger.Errorf(ctx, "calculate rerank billing snapshot failed: %s", err.Error())
+		billingSnapshot = preConsumedSnapshot
+	} else {
+		billingSnapshot.SetBillingRatioBreakdown(billingRatio)
+		if err := billing.ApplyEstimatedProcurementCostFloor(&billingSnapshot, meta.ChannelId, rerankRequest.Model); err != nil {
+			logger.Errorf(ctx, "estimate procurement cost for rerank settlement failed: %s", err.Error())
+		}
+	}
+	annotateRerankBillingSnapshot(&billingSnapshot, pricing.Source, upstreamReported)
+	quota := billingSnapshot.ChargeAmount
+	graceful.Go(func() {
+		billing.PostConsumeQuota(
+			ctx,
+			meta.TokenId,
+			quota-preConsumedQuota,
+			quota,
+			meta.UserId,
+			meta.Group,
+			meta.ChannelId,
+			pricing,
+			groupRatio,
+			rerankRequest.Model,
+			meta.TokenName,
+			billingPlan.ChargeUserBalance(),
+			billingPlan.ChargeTokenQuota(),
+			billingPlan.PackageReservation,
+			billingSnapshot,
+			func(entry *adminmodel.Log) {
+				applyRouteObservabilityToLog(entry, meta, rerankRequest.Model)
+				annotateInputPreConsumeLogFields(entry, int(math.Round(estimatedQuantity)), estimatedChargeAmount)
+			},
+		)
+		if billingPlan.UsesRequestPackage() {
+			settleRelayBillingPlan(ctx, billingPlan, quota)
+		}
+	})
+	preConsumedQuotaSettled = true
+	groupQuotaSettled = true
+
+	c.JSON(http.StatusOK, rerankResponse)
+	return nil
+}
+
+// rerankBillingQuantity returns the billed quantity for the price unit and
+// whether it came from usage the upstream reported.
+func rerankBillingQuantity(priceUnit string, documentCount int, estimatedTokens int, usage *relaymodel.RerankUsage) (float64, bool) {
+	switch strings.TrimSpace(strings.ToLower(priceUnit)) {
+	case adminmodel.ProviderPriceUnitPerSearchUnit:
+		if usage != nil && usage.SearchUnits > 0 {
+			return float64(usage.SearchUnits), true
+		}
+		return math.Max(1, math.Ceil(float64(documentCount)/rerankDocumentsPerSearchUnit)), false
+	case adminmodel.ProviderPriceUnitPerDocument:
+		return float64(documentCount), false
+	case adminmodel.ProviderPriceUnitPerRequest, adminmodel.ProviderPriceUnitPerTask:
+		return 1, false
+	default:
+		if usage != nil && usage.TotalTokens > 0 {
+			return float64(usage.TotalTokens), true
+		}
+		return float64(estimatedTokens), false
+	}
+}
+
+// estimateRerankTokens counts the query once per document, which is how
+// cross-encoder rerankers consume it.
+func estimateRerankTokens(query string, documents []string, modelName string) int {
+	queryTokens := openai.CountTokenText(query, modelName)
+	total := 0
+	for _, document := range documents {
+		total += queryTokens + openai.CountTokenText(document, modelName)
+	}
+	return total
+}
+
+func fillRerankResultDocuments(response *relaymodel.RerankResponse, documents []string, returnDocuments bool) {
+	for i := range response.Results {
+		result := &response.Results[i]
+		if !returnDocuments {
+			result.Document = nil
+			continue
+		}
+		if result.Document == nil && result.Index >= 0 && result.Index < len(documents) {
+			result.Document = &relaymodel.RerankDocument{Text: documents[result.Index]}
+		}
+	}
+}
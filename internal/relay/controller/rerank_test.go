@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"testing"
+
+	adminmodel "github.com/yeying-community/router/internal/admin/model"
+	relaymodel "github.com/yeying-community/router/internal/relay/model"
+)
+
+func TestRerankBillingQuantity(t *testing.T) {
+	cases := []struct {
+		name         string
+		priceUnit    string
+		documents    int
+		usage        *relaymodel.RerankUsage
+		wantQuantity float64
+		wantReported bool
+	}{
+		{name: "search units reported", priceUnit: adminmodel.ProviderPriceUnitPerSearchUnit, documents: 250, usage: &relaymodel.RerankUsage{SearchUnits: 2}, wantQuantity: 2, wantReported: true},
+		{name: "search units estimated", priceUnit: adminmodel.ProviderPriceUnitPerSearchUnit, documents: 250, wantQuantity: 3},
+		{name: "search units minimum", priceUnit: adminmodel.ProviderPriceUnitPerSearchUnit, documents: 1, wantQuantity: 1},
+		{name: "documents", priceUnit: adminmodel.ProviderPriceUnitPerDocument, documents: 7, usage: &relaymodel.RerankUsage{TotalTokens: 90}, wantQuantity: 7},
+		{name: "tokens reported", priceUnit: adminmodel.ProviderPriceUnitPer1KTokens, documents: 7, usage: &relaymodel.RerankUsage{TotalTokens: 90}, wantQuantity: 90, wantReported: true},
+		{name: "tokens estimated", priceUnit: "", documents: 7, wantQuantity: 40},
+	}
+	for _, tc := range cases {
+		quantity, reported := rerankBillingQuantity(tc.priceUnit, tc.documents, 40, tc.usage)
+		if quantity != tc.wantQuantity || reported != tc.wantReported {
+			t.Fatalf("%s: rerankBillingQuantity() = %v, %t; want %v, %t", tc.name, quantity, reported, tc.wantQuantity, tc.wantReported)
+		}
+	}
+}
+
+func TestFillRerankResultDocuments(t *testing.T) {
+	response := &relaymodel.RerankResponse{Results: []relaymodel.RerankResult{
+		{Index: 1, RelevanceScore: 0.9},
+		{Index: 0, RelevanceScore: 0.1, Document: &relaymodel.RerankDocument{Text: "upstream"}},
+	}}
+	fillRerankResultDocuments(response, []string{"a", "b"}, true)
+	if response.Results[0].Document == nil || response.Results[0].Document.Text != "b" || response.Results[1].Document.Text != "upstream" {
+		t.Fatalf("results = %+v, want documents filled from the request", response.Results)
+	}
+	fillRerankResultDocuments(response, []string{"a", "b"}, false)
+	if response.Results[0].Document != nil || response.Results[1].Document != nil {
+		t.Fatalf("results = %+v, want documents dropped", response.Results)
+	}
+}
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yeying-community/router/common"
+	"github.com/yeying-community/router/common/ctxkey"
+	"github.com/yeying-community/router/common/logger"
+	"github.com/yeying-community/router/internal/relay/filestore"
+	"github.com/yeying-community/router/internal/relay/meta"
+	"github.com/yeying-community/router/internal/relay/relaymode"
+	"github.com/yeying-community/router/internal/relay/responsestate"
+)
+
+// prepareResponsesReplayBody expands previous_response_id from the local state
+// store unless the request goes back to the channel that holds the upstream
+// state. The expanded body is kept per attempt so retries on other channels
+// recompute it.
+func prepareResponsesReplayBody(c *gin.Context, meta *meta.Meta) ([]byte, error) {
+	c.Set(ctxkey.ResponsesReplayRequestBody, []byte(nil))
+	if meta.Mode != relaymode.Responses || !c.GetBool(ctxkey.ResponsesLocalState) {
+		return nil, nil
+	}
+	previousResponseID := strings.TrimSpace(c.GetString(ctxkey.ResponsesPreviousResponseID))
+	if previousResponseID == "" {
+		return nil, nil
+	}
+	if meta.UpstreamMode == relaymode.Responses {
+		if channelID, ok := responsestate.LookupRoute(previousResponseID); ok && channelID == meta.ChannelId {
+			return nil, nil
+		}
+	}
+	rawBody, err := common.GetRequestBody(c)
+	if err != nil {
+		return nil, err
+	}
+	expanded, err := responsestate.ExpandRequestBody(rawBody, meta.UserId)
+	if err != nil {
+		return nil, err
+	}
+	// replayed turns are stored with gateway file ids
+	expanded, _, err = filestore.ResolveReferences(c.Request.Context(), expanded, fileReferenceTarget(meta))
+	if err != nil {
+		return nil, err
+	}
+	logger.Debugf(c.Request.Context(), "[responses_state] replay previous_response_id=%s channel_id=%s upstream=%s", previousResponseID, meta.ChannelId, relayModeLabel(meta.UpstreamMode))
+	c.Set(ctxkey.ResponsesReplayRequestBody, expanded)
+	return expanded, nil
+}
+
+func getResponsesRequestBody(c *gin.Context) ([]byte, error) {
+	if replayBody, ok := c.Get(ctxkey.ResponsesReplayRequestBody); ok {
+		if body, castOK := replayBody.([]byte); castOK && len(body) > 0 {
+			return body, nil
+		}
+	}
+	return common.GetRequestBody(c)
+}
+
+func saveResponsesState(c *gin.Context, meta *meta.Meta, responseBody []byte) {
+	if len(responseBody) == 0 {
+		return
+	}
+	requestBody, err := common.GetRequestBody(c)
+	if err != nil {
+		return
+	}
+	if original, ok := c.Get(ctxkey.FilesOriginalRequestBody); ok {
+		if body, castOK := original.([]byte); castOK && len(body) > 0 {
+			// keep gateway file ids so replays resolve them for their channel
+			requestBody = body
+		}
+	}
+	record, ok := responsestate.NewRecord(requestBody, responseBody)
+	if !ok {
+		return
+	}
+	record.UserID = meta.UserId
+	record.TokenID = meta.TokenId
+	record.ChannelID = meta.ChannelId
+	if err := responsestate.Save(record); err != nil {
+		logger.Errorf(c.Request.Context(), "[responses_state] save response_id=%s failed: %s", record.ID, err.Error())
+	}
+}
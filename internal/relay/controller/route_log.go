@@ -12,7 +12,10 @@ func applyRouteObservabilityToLog(entry *adminmodel.Log, meta *relaymeta.Meta, a
 	if entry == nil || meta == nil {
 		return
 	}
-	requestModel := strings.TrimSpace(meta.OriginModelName)
+	requestModel := strings.TrimSpace(meta.ClientModelName)
+	if requestModel == "" {
+		requestModel = strings.TrimSpace(meta.OriginModelName)
+	}
 	if requestModel == "" {
 		requestModel = strings.TrimSpace(entry.ModelName)
 	}
@@ -27,6 +30,7 @@ func applyRouteObservabilityToLog(entry *adminmodel.Log, meta *relaymeta.Meta, a
 	if upstreamEndpoint == "" {
 		upstreamEndpoint = strings.TrimSpace(meta.RequestURLPath)
 	}
+	entry.ChannelKeyId = strings.TrimSpace(meta.ChannelKeyID)
 	entry.RequestModelName = requestModel
 	entry.ActualModelName = finalModel
 	entry.UpstreamEndpoint = upstreamEndpoint
@@ -37,4 +41,11 @@ func applyRouteObservabilityToLog(entry *adminmodel.Log, meta *relaymeta.Meta, a
 	entry.RelayErrorType = strings.TrimSpace(meta.RelayErrorType)
 	entry.RelayErrorCode = strings.TrimSpace(meta.RelayErrorCode)
 	entry.RelayErrorMessage = strings.TrimSpace(meta.RelayErrorMessage)
+	entry.ModerationDecision = strings.TrimSpace(meta.ModerationDecision)
+	entry.ModerationReason = strings.TrimSpace(meta.ModerationReason)
+	stats := meta.Timing.Stats(entry.CompletionTokens)
+	entry.FirstByteMs = stats.FirstByteMs
+	entry.FirstTokenMs = stats.FirstTokenMs
+	entry.UpstreamLatencyMs = stats.UpstreamLatencyMs
+	entry.OutputTokensPerSecond = stats.OutputTokensPerSecond
 }
@@ -0,0 +1,194 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yeying-community/router/common/config"
+	"github.com/yeying-community/router/common/logger"
+	"github.com/yeying-community/router/internal/relay/adaptor/openai"
+	"github.com/yeying-community/router/internal/relay/meta"
+	"github.com/yeying-community/router/internal/relay/model"
+	"github.com/yeying-community/router/internal/relay/relaymode"
+	"github.com/yeying-community/router/internal/relay/structuredoutput"
+)
+
+const structuredOutputValidationErrorCode = "structured_output_validation_failed"
+
+// structuredOutputGuard validates non-streaming chat completions against the
+// requested response_format json_schema before anything reaches the client,
+// retrying the upstream call when the output does not conform.
+type structuredOutputGuard struct {
+	schema  map[string]any
+	retries int
+}
+
+func newStructuredOutputGuard(meta *meta.Meta, request *model.GeneralOpenAIRequest) *structuredOutputGuard {
+	if !config.StructuredOutputValidationEnabled || meta == nil || request == nil {
+		return nil
+	}
+	if meta.Mode != relaymode.ChatCompletions || meta.IsStream {
+		return nil
+	}
+	schema := structuredoutput.Schema(request.ResponseFormat)
+	if schema == nil {
+		return nil
+	}
+	retries := config.StructuredOutputValidationRetries
+	if retries < 0 {
+		retries = 0
+	}
+	return &structuredOutputGuard{schema: schema, retries: retries}
+}
+
+func (g *structuredOutputGuard) relay(c *gin.Context, requestBody io.Reader, attempt func(io.Reader) (*model.Usage, *model.ErrorWithStatusCode)) (*model.Usage, *model.ErrorWithStatusCode) {
+	body, err := io.ReadAll(requestBody)
+	if err != nil {
+		return nil, openai.ErrorWrapper(err, "read_request_body_failed", http.StatusInternalServerError)
+	}
+	var total *model.Usage
+	var validationErr *model.ErrorWithStatusCode
+	for i := 0; i <= g.retries; i++ {
+		writer := newBufferedResponseWriter(c.Writer)
+		c.Writer = writer
+		usage, respErr := attempt(bytes.NewReader(body))
+		c.Writer = writer.ResponseWriter
+		if respErr != nil {
+			if validationErr != nil {
+				// earlier attempts already produced billable output
+				return total, validationErr
+			}
+			return usage, respErr
+		}
+		total = addUsage(total, usage)
+		err := g.validate(writer.body.Bytes())
+		if err == nil {
+			if err := writer.flush(); err != nil {
+				logger.Errorf(c.Request.Context(), "[structured_output] write validated response failed: %s", err.Error())
+			}
+			return total, nil
+		}
+		logger.Warnf(c.Request.Context(), "[structured_output] attempt=%d/%d schema validation failed: %s", i+1, g.retries+1, err.Error())
+		validationErr = openai.ErrorWrapper(fmt.Errorf("upstream output does not match response_format json_schema: %w", err), structuredOutputValidationErrorCode, http.StatusBadGateway)
+	}
+	return total, validationErr
+}
+
+func (g *structuredOutputGuard) validate(responseBody []byte) error {
+	var response struct {
+		Choices []struct {
+			Message struct {
+				Content   *string `json:"content"`
+				Refusal   string  `json:"refusal"`
+				ToolCalls []any   `json:"tool_calls"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(responseBody, &response); err != nil {
+		return fmt.Errorf("response is not a chat completion: %w", err)
+	}
+	for _, choice := range response.Choices {
+		message := choice.Message
+		// refusals and tool calls are legitimate answers without JSON content
+		if message.Refusal != "" || (len(message.ToolCalls) > 0 && (message.Content == nil || *message.Content == "")) {
+			continue
+		}
+		content := ""
+		if message.Content != nil {
+			content = *message.Content
+		}
+		if err := structuredoutput.Validate(g.schema, []byte(content)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsStructuredOutputValidationError reports an answer that still failed the
+// schema after the guard retried it on the same channel. The channel served the
+// request, so the error is returned to the client instead of trying another
+// channel.
+func IsStructuredOutputValidationError(err *model.ErrorWithStatusCode) bool {
+	return err != nil && err.Code == structuredOutputValidationErrorCode
+}
+
+func addUsage(total *model.Usage, usage *model.Usage) *model.Usage {
+	if usage == nil {
+		return total
+	}
+	if total == nil {
+		copied := *usage
+		return &copied
+	}
+	total.PromptTokens += usage.PromptTokens
+	total.CompletionTokens += usage.CompletionTokens
+	total.TotalTokens += usage.TotalTokens
+	if usage.PromptTokensDetails != nil {
+		if total.PromptTokensDetails == nil {
+			total.PromptTokensDetails = &model.PromptTokensDetails{}
+		}
+		total.PromptTokensDetails.CachedTokens += usage.PromptTokensDetails.CachedTokens
+		total.PromptTokensDetails.CacheReadTokens += usage.PromptTokensDetails.CacheReadTokens
+		total.PromptTokensDetails.CacheCreationTokens += usage.PromptTokensDetails.CacheCreationTokens
+	}
+	if usage.CompletionTokensDetails != nil {
+		if total.CompletionTokensDetails == nil {
+			total.CompletionTokensDetails = &model.CompletionTokensDetails{}
+		}
+		total.CompletionTokensDetails.ReasoningTokens += usage.CompletionTokensDetails.ReasoningTokens
+	}
+	return total
+}
+
+// bufferedResponseWriter holds a complete response back so it can be
+// discarded when validation fails.
+type bufferedResponseWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedResponseWriter(writer gin.ResponseWriter) *bufferedResponseWriter {
+	return &bufferedResponseWriter{ResponseWriter: writer, status: http.StatusOK}
+}
+
+func (w *bufferedResponseWriter) WriteHeader(code int) {
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *bufferedResponseWriter) WriteHeaderNow() {}
+
+func (w *bufferedResponseWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *bufferedResponseWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedResponseWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedResponseWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *bufferedResponseWriter) Written() bool {
+	return w.body.Len() > 0
+}
+
+func (w *bufferedResponseWriter) Flush() {}
+
+func (w *bufferedResponseWriter) flush() error {
+	w.ResponseWriter.WriteHeader(w.status)
+	_, err := w.ResponseWriter.Write(w.body.Bytes())
+	return err
+}
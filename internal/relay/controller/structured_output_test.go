@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yeying-community/router/internal/relay/model"
+)
+
+func newStructuredOutputTestContext() (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	return ctx, recorder
+}
+
+func structuredOutputAttempt(c *gin.Context, bodies []string, calls *int) func(io.Reader) (*model.Usage, *model.ErrorWithStatusCode) {
+	return func(requestBody io.Reader) (*model.Usage, *model.ErrorWithStatusCode) {
+		body := bodies[*calls]
+		*calls++
+		c.Writer.WriteHeader(http.StatusOK)
+		_, _ = c.Writer.Write([]byte(body))
+		return &model.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}, nil
+	}
+}
+
+func TestStructuredOutputGuardRetriesUntilOutputConforms(t *testing.T) {
+	ctx, recorder := newStructuredOutputTestContext()
+	guard := &structuredOutputGuard{
+		schema:  map[string]any{"type": "object", "required": []any{"name"}},
+		retries: 1,
+	}
+	calls := 0
+	attempt := structuredOutputAttempt(ctx, []string{
+		`{"choices":[{"message":{"role":"assistant","content":"{}"}}]}`,
+		`{"choices":[{"message":{"role":"assistant","content":"{\"name\":\"x\"}"}}]}`,
+	}, &calls)
+
+	usage, respErr := guard.relay(ctx, bytes.NewBufferString(`{}`), attempt)
+	if respErr != nil {
+		t.Fatalf("relay returned error: %+v", respErr)
+	}
+	if calls != 2 || usage.TotalTokens != 30 {
+		t.Fatalf("calls = %d usage = %#v, want two billed attempts", calls, usage)
+	}
+	if body := recorder.Body.String(); body != `{"choices":[{"message":{"role":"assistant","content":"{\"name\":\"x\"}"}}]}` {
+		t.Fatalf("body = %s, want only the conforming response", body)
+	}
+}
+
+func TestStructuredOutputGuardReturnsErrorWhenRetriesAreExhausted(t *testing.T) {
+	ctx, recorder := newStructuredOutputTestContext()
+	guard := &structuredOutputGuard{schema: map[string]any{"type": "object"}}
+	calls := 0
+	attempt := structuredOutputAttempt(ctx, []string{
+		`{"choices":[{"message":{"role":"assistant","content":"not json"}}]}`,
+	}, &calls)
+
+	usage, respErr := guard.relay(ctx, bytes.NewBufferString(`{}`), attempt)
+	if !IsStructuredOutputValidationError(respErr) || respErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("respErr = %+v, want structured output validation error", respErr)
+	}
+	if usage == nil || usage.TotalTokens != 15 {
+		t.Fatalf("usage = %#v, want the failed attempt to stay billable", usage)
+	}
+	if recorder.Body.Len() != 0 {
+		t.Fatalf("body = %s, want nothing written before the error", recorder.Body.String())
+	}
+}
@@ -13,17 +13,25 @@ import (
 
 	"github.com/yeying-community/router/common"
 	"github.com/yeying-community/router/common/config"
+	"github.com/yeying-community/router/common/graceful"
 	"github.com/yeying-community/router/common/logger"
 	adminmodel "github.com/yeying-community/router/internal/admin/model"
 	"github.com/yeying-community/router/internal/relay"
 	"github.com/yeying-community/router/internal/relay/adaptor"
 	"github.com/yeying-community/router/internal/relay/adaptor/openai"
 	"github.com/yeying-community/router/internal/relay/apitype"
+	"github.com/yeying-community/router/internal/relay/batch"
 	"github.com/yeying-community/router/internal/relay/billing"
 	relaychannel "github.com/yeying-community/router/internal/relay/channel"
+	"github.com/yeying-community/router/internal/relay/filestore"
+	"github.com/yeying-community/router/internal/relay/hedge"
 	"github.com/yeying-community/router/internal/relay/meta"
 	"github.com/yeying-community/router/internal/relay/model"
 	"github.com/yeying-community/router/internal/relay/relaymode"
+	"github.com/yeying-community/router/internal/relay/responsestate"
+	"github.com/yeying-community/router/internal/relay/streamguard"
+	"github.com/yeying-community/router/internal/relay/textconv"
+	"github.com/yeying-community/router/internal/relay/timing"
 	"github.com/yeying-community/router/internal/tokenestimate"
 )
 
@@ -58,6 +66,13 @@ func logTextStreamAcceptConflict(c *gin.Context, meta *meta.Meta) {
 func RelayTextHelper(c *gin.Context) *model.ErrorWithStatusCode {
 	ctx := c.Request.Context()
 	meta := meta.GetByContext(c)
+	if err := prepareFileReferences(c, meta); err != nil {
+		if errors.Is(err, filestore.ErrFileNotFound) {
+			return openai.ErrorWrapper(err, "file_not_found", http.StatusBadRequest)
+		}
+		logger.Errorf(ctx, "prepareFileReferences failed: %s", err.Error())
+		return openai.ErrorWrapper(err, "resolve_file_reference_failed", http.StatusInternalServerError)
+	}
 	// get & validate textRequest
 	textRequest, validatedRawBody, err := getAndValidateTextRequest(c, meta.Mode)
 	if err != nil {
@@ -81,6 +96,13 @@ func RelayTextHelper(c *gin.Context) *model.ErrorWithStatusCode {
 	}
 	meta.UpstreamMode = upstreamMode
 	meta.UpstreamRequestPath = upstreamPath
+	replayBody, err := prepareResponsesReplayBody(c, meta)
+	if err != nil {
+		return openai.ErrorWrapper(err, "responses_state_replay_failed", http.StatusBadRequest)
+	}
+	if replayBody != nil {
+		validatedRawBody = replayBody
+	}
 	meta.EndpointPolicies = adminmodel.CacheGetChannelModelEndpointPolicies(meta.ChannelId, upstreamPath, meta.OriginModelName, textRequest.Model)
 	meta.EndpointPolicy = adminmodel.CacheGetChannelModelEndpointPolicy(meta.ChannelId, upstreamPath, meta.OriginModelName, textRequest.Model)
 	if err := ApplyEndpointAccessPolicies(c, meta); err != nil {
@@ -98,7 +120,7 @@ func RelayTextHelper(c *gin.Context) *model.ErrorWithStatusCode {
 			strings.TrimSpace(meta.ActualModelName),
 		)
 	}
-	billingRatio := adminmodel.GetRouteBillingRatio(meta.Group, meta.OriginModelName, meta.ChannelId)
+	billingRatio := batch.ApplyBillingRatio(ctx, adminmodel.GetRouteBillingRatio(meta.Group, meta.OriginModelName, meta.ChannelId))
 	groupRatio := billingRatio.EffectiveRatio
 	pricing, err := adminmodel.ResolveChannelModelPricing(meta.ChannelProtocol, meta.ChannelModelConfigs, textRequest.Model)
 	if err != nil {
@@ -196,19 +218,37 @@ func RelayTextHelper(c *gin.Context) *model.ErrorWithStatusCode {
 		}
 	}()
 
-	upstreamRequest, err := convertTextRequestForUpstream(textRequest, meta.Mode, upstreamMode)
+	downstreamRawBody := validatedRawBody
+	if len(downstreamRawBody) == 0 && textconv.NeedsConversion(meta.Mode, upstreamMode) {
+		downstreamRawBody, err = common.GetRequestBody(c)
+		if err != nil {
+			return openai.ErrorWrapper(err, "read_request_body_failed", http.StatusBadRequest)
+		}
+	}
+	upstreamRequest, convertedBody, err := convertTextRequestForUpstream(textRequest, downstreamRawBody, meta.Mode, upstreamMode)
 	if err != nil {
 		return openai.ErrorWrapper(err, "convert_request_failed", http.StatusBadRequest)
 	}
+	// adaptors only speak their upstream protocol, so converted requests are
+	// relayed with a meta that looks native to them.
+	adaptorMeta := meta
+	if convertedBody != nil {
+		adaptorMeta = meta.UpstreamView()
+	}
 
 	adaptor := relay.GetAdaptor(meta.APIType)
 	if adaptor == nil {
 		return openai.ErrorWrapper(fmt.Errorf("invalid api type: %d", meta.APIType), "invalid_api_type", http.StatusBadRequest)
 	}
-	adaptor.Init(meta)
+	adaptor.Init(adaptorMeta)
 
 	// get request body
-	requestBody, err := getRequestBody(c, meta, upstreamRequest, adaptor, rawRequestBody)
+	var requestBody io.Reader
+	if convertedBody != nil {
+		requestBody, err = getConvertedRequestBody(c, adaptorMeta, upstreamRequest, adaptor, convertedBody, meta.Mode)
+	} else {
+		requestBody, err = getRequestBody(c, meta, upstreamRequest, adaptor, rawRequestBody)
+	}
 	if err != nil {
 		var policyErr *endpointPolicyError
 		if errors.As(err, &policyErr) {
@@ -217,25 +257,137 @@ func RelayTextHelper(c *gin.Context) *model.ErrorWithStatusCode {
 		return openai.ErrorWrapper(err, "convert_request_failed", http.StatusInternalServerError)
 	}
 
+	attempt := func(requestBody io.Reader) (*model.Usage, *model.ErrorWithStatusCode) {
+		return relayTextAttempt(c, meta, adaptorMeta, adaptor, requestBody, upstreamMode, convertedBody != nil)
+	}
+	var usage *model.Usage
+	var respErr *model.ErrorWithStatusCode
+	if guard := newStructuredOutputGuard(meta, textRequest); guard != nil {
+		usage, respErr = guard.relay(c, requestBody, attempt)
+	} else {
+		usage, respErr = attempt(requestBody)
+	}
+	if hedge.Lost(c) {
+		// the winning attempt is billed; the loser only refunds its reservation
+		if config.HedgeRecordLoserCost {
+			graceful.Go(func() { recordHedgeLoserCost(ctx, usage, meta, upstreamRequest, pricing, billingRatio, promptTokens) })
+		}
+		return openai.ErrorWrapper(hedge.ErrLost, "hedge_lost", http.StatusServiceUnavailable)
+	}
+	if respErr != nil {
+		if usage != nil && IsStructuredOutputValidationError(respErr) {
+			// the upstream did answer, so its tokens are billed; the error
+			// is final and no other channel is tried after it
+			graceful.Go(func() {
+				postConsumeQuota(ctx, usage, meta, upstreamRequest, pricing, preConsumedQuota, int(preConsumedSnapshot.OutputQuantity), groupReservedQuota, billingRatio, estimateResult, responsesImageTools, false, billingPlan)
+			})
+			preConsumedQuotaSettled = true
+			groupQuotaSettled = true
+		}
+		return respErr
+	}
+	// post-consume quota
+	graceful.Go(func() {
+		postConsumeQuota(ctx, usage, meta, upstreamRequest, pricing, preConsumedQuota, int(preConsumedSnapshot.OutputQuantity), groupReservedQuota, billingRatio, estimateResult, responsesImageTools, false, billingPlan)
+	})
+	preConsumedQuotaSettled = true
+	groupQuotaSettled = true
+	return nil
+}
+
+func relayTextAttempt(c *gin.Context, meta *meta.Meta, adaptorMeta *meta.Meta, adaptor adaptor.Adaptor, requestBody io.Reader, upstreamMode int, converted bool) (*model.Usage, *model.ErrorWithStatusCode) {
 	// do request
-	resp, err := adaptor.DoRequest(c, meta, requestBody)
+	resp, err := adaptor.DoRequest(c, adaptorMeta, requestBody)
 	if err != nil {
-		return openai.ErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+		return nil, openai.ErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
 	}
 	if isErrorHappened(meta, resp) {
-		return RelayErrorHandler(meta, resp)
+		return nil, RelayErrorHandler(meta, resp)
 	}
 
 	// do response
-	usage, respErr := adaptor.DoResponse(c, resp, meta)
-	if respErr != nil {
+	var streamGuard *streamguard.Writer
+	if meta.IsStream && config.StreamFailoverBufferBytes > 0 {
+		streamGuard = streamguard.New(c.Writer, config.StreamFailoverBufferBytes)
+		c.Writer = streamGuard
+		resp.Body = streamGuard.WrapBody(resp.Body)
+	}
+	var stateRecorder *responsestate.Recorder
+	if meta.Mode == relaymode.Responses && responsestate.StoreEnabled() {
+		stateRecorder = responsestate.NewRecorder(c.Writer, meta.IsStream)
+		c.Writer = stateRecorder
+	}
+	var responseConverter *textconv.ResponseWriter
+	if converted {
+		responseConverter = textconv.NewResponseWriter(c.Writer, upstreamMode, meta.Mode, meta.IsStream)
+		c.Writer = responseConverter
+	}
+	usage, respErr := adaptor.DoResponse(c, resp, adaptorMeta)
+	timing.MarkFinished(c)
+	if responseConverter != nil {
+		c.Writer = responseConverter.ResponseWriter
+		if respErr == nil {
+			if err := responseConverter.Finish(); err != nil {
+				logger.Errorf(c.Request.Context(), "write converted response failed: %s", err.Error())
+			}
+		}
+	}
+	if stateRecorder != nil {
+		c.Writer = stateRecorder.ResponseWriter
+	}
+	if streamGuard != nil {
+		c.Writer = streamGuard.ResponseWriter
+		respErr = settleStreamGuard(c, meta, streamGuard, respErr)
+	}
+	if stateRecorder != nil && respErr == nil {
+		saveResponsesState(c, meta, stateRecorder.Response())
+	}
+	return usage, respErr
+}
+
+// settleStreamGuard releases a held-back stream, or discards it when the
+// upstream failed before its first token so the relay can retry the request
+// on another channel with nothing sent to the client yet.
+func settleStreamGuard(c *gin.Context, meta *meta.Meta, guard *streamguard.Writer, respErr *model.ErrorWithStatusCode) *model.ErrorWithStatusCode {
+	if guard.Committed() {
 		return respErr
 	}
-	// post-consume quota
-	go postConsumeQuota(ctx, usage, meta, upstreamRequest, pricing, preConsumedQuota, int(preConsumedSnapshot.OutputQuantity), groupReservedQuota, billingRatio, estimateResult, responsesImageTools, false, billingPlan)
-	preConsumedQuotaSettled = true
-	groupQuotaSettled = true
-	return nil
+	failure := respErr
+	if failure == nil {
+		if upstreamErr := guard.UpstreamError(); upstreamErr != nil {
+			statusCode := upstreamErr.StatusCode
+			if statusCode == 0 {
+				statusCode = http.StatusBadGateway
+			}
+			code := upstreamErr.Code
+			if code == nil || code == "" {
+				code = "upstream_stream_error"
+			}
+			errorType := upstreamErr.Type
+			if errorType == "" {
+				errorType = "upstream_error"
+			}
+			failure = &model.ErrorWithStatusCode{
+				StatusCode: statusCode,
+				Error: model.Error{
+					Message: upstreamErr.Message,
+					Type:    errorType,
+					Code:    code,
+				},
+			}
+		} else if err := guard.BodyError(); err != nil {
+			failure = openai.ErrorWrapper(err, "read_response_body_failed", http.StatusBadGateway)
+		}
+	}
+	if failure == nil {
+		if err := guard.Commit(); err != nil {
+			logger.Errorf(c.Request.Context(), "write held-back stream failed: %s", err.Error())
+		}
+		return nil
+	}
+	logger.RelayWarnf(c.Request.Context(), "STREAM decision=discard reason=failed_before_first_token channel_id=%s model=%s status=%d buffered_bytes=%d error=%q", meta.ChannelId, meta.ActualModelName, failure.StatusCode, guard.Buffered(), failure.Error.Message)
+	guard.Discard()
+	return failure
 }
 
 func prepareTextBillingRequestBody(c *gin.Context, meta *meta.Meta, rawRequestBody []byte) ([]byte, error) {
@@ -331,7 +483,7 @@ func getRequestBody(c *gin.Context, meta *meta.Meta, textRequest *model.GeneralO
 		return bytes.NewBuffer(jsonData), nil
 	}
 	if meta.Mode == relaymode.Responses && upstreamMode == relaymode.Responses {
-		rawBody, err := common.GetRequestBody(c)
+		rawBody, err := getResponsesRequestBody(c)
 		if err != nil {
 			return nil, err
 		}
@@ -411,6 +563,37 @@ func getRequestBody(c *gin.Context, meta *meta.Meta, textRequest *model.GeneralO
 	return requestBody, nil
 }
 
+func getConvertedRequestBody(c *gin.Context, meta *meta.Meta, textRequest *model.GeneralOpenAIRequest, adaptor adaptor.Adaptor, convertedBody []byte, downstreamMode int) (io.Reader, error) {
+	jsonData := convertedBody
+	if meta.Mode == relaymode.ChatCompletions {
+		convertedRequest, err := adaptor.ConvertRequest(c, relaymode.ChatCompletions, textRequest)
+		if err != nil {
+			logger.Debugf(c.Request.Context(), "converted request failed: %s\n", err.Error())
+			return nil, err
+		}
+		jsonData, err = json.Marshal(convertedRequest)
+		if err != nil {
+			logger.Debugf(c.Request.Context(), "converted request json_marshal_failed: %s\n", err.Error())
+			return nil, err
+		}
+	}
+	jsonData, err := applyEndpointRequestPolicy(c, meta, jsonData)
+	if err != nil {
+		return nil, err
+	}
+	if config.DebugEnabled {
+		logger.Debugf(
+			c.Request.Context(),
+			"[converted_request_body] downstream=%s upstream=%s len=%d body=%s",
+			relayModeLabel(downstreamMode),
+			relayModeLabel(meta.Mode),
+			len(jsonData),
+			sanitizePayloadForRelayDebug(jsonData),
+		)
+	}
+	return bytes.NewBuffer(jsonData), nil
+}
+
 func relayModeLabel(mode int) string {
 	switch mode {
 	case relaymode.ChatCompletions:
@@ -423,10 +606,14 @@ func relayModeLabel(mode int) string {
 		return "completions"
 	case relaymode.Embeddings:
 		return "embeddings"
+	case relaymode.Rerank:
+		return "rerank"
 	case relaymode.Moderations:
 		return "moderations"
 	case relaymode.ImagesGenerations:
 		return "images_generations"
+	case relaymode.ImagesVariations:
+		return "images_variations"
 	case relaymode.Edits:
 		return "edits"
 	case relaymode.AudioSpeech:
@@ -5,12 +5,14 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/yeying-community/router/common/config"
 	adminmodel "github.com/yeying-community/router/internal/admin/model"
 	"github.com/yeying-community/router/internal/relay/apitype"
 	relaychannel "github.com/yeying-community/router/internal/relay/channel"
 	"github.com/yeying-community/router/internal/relay/meta"
 	relaymodel "github.com/yeying-community/router/internal/relay/model"
 	"github.com/yeying-community/router/internal/relay/relaymode"
+	"github.com/yeying-community/router/internal/relay/textconv"
 )
 
 func normalizeMessagesRequestBody(raw []byte, modelName string) ([]byte, error) {
@@ -201,18 +203,19 @@ func resolveChannelTextUpstream(meta *meta.Meta, originModelName string, actualM
 		supportsChat, supportsResponses, supportsMessagesDirect := resolveSelectedModelDirectTextEndpointSupport(meta, row, originModelName, actualModelName)
 		supportsMessages := supportsMessagesDirect && supportsMessagesUpstream(meta)
 
-		switch requestEndpoint {
-		case adminmodel.ChannelModelEndpointChat:
-			if supportsChat {
-				return relaymode.ChatCompletions, adminmodel.ChannelModelEndpointChat, nil
-			}
-		case adminmodel.ChannelModelEndpointMessages:
-			if supportsMessages {
-				return relaymode.Messages, adminmodel.ChannelModelEndpointMessages, nil
-			}
-		case adminmodel.ChannelModelEndpointResponses:
-			if supportsResponses {
-				return relaymode.Responses, adminmodel.ChannelModelEndpointResponses, nil
+		supported := map[string]bool{
+			adminmodel.ChannelModelEndpointChat:      supportsChat,
+			adminmodel.ChannelModelEndpointMessages:  supportsMessages,
+			adminmodel.ChannelModelEndpointResponses: supportsResponses,
+		}
+		if supported[requestEndpoint] {
+			return relayModeByTextEndpoint(requestEndpoint), requestEndpoint, nil
+		}
+		if config.TextEndpointConversionEnabled {
+			for _, endpoint := range textConversionEndpointCandidates(requestEndpoint) {
+				if supported[endpoint] {
+					return relayModeByTextEndpoint(endpoint), endpoint, nil
+				}
 			}
 		}
 
@@ -235,19 +238,56 @@ func resolveChannelTextUpstream(meta *meta.Meta, originModelName string, actualM
 	return 0, "", fmt.Errorf("channel does not have selected model endpoint config for %s", requestEndpoint)
 }
 
-func convertTextRequestForUpstream(req *relaymodel.GeneralOpenAIRequest, downstreamMode int, upstreamMode int) (*relaymodel.GeneralOpenAIRequest, error) {
-	cloned, err := cloneGeneralOpenAIRequest(req)
-	if err != nil {
-		return nil, err
+func relayModeByTextEndpoint(endpoint string) int {
+	switch endpoint {
+	case adminmodel.ChannelModelEndpointMessages:
+		return relaymode.Messages
+	case adminmodel.ChannelModelEndpointResponses:
+		return relaymode.Responses
+	default:
+		return relaymode.ChatCompletions
 	}
-	if downstreamMode != upstreamMode &&
-		(downstreamMode == relaymode.ChatCompletions || downstreamMode == relaymode.Messages || downstreamMode == relaymode.Responses) &&
-		(upstreamMode == relaymode.ChatCompletions || upstreamMode == relaymode.Messages || upstreamMode == relaymode.Responses) {
-		return nil, fmt.Errorf(
+}
+
+// textConversionEndpointCandidates lists the upstream text endpoints a request
+// may be converted to, in order of preference.
+func textConversionEndpointCandidates(requestEndpoint string) []string {
+	switch requestEndpoint {
+	case adminmodel.ChannelModelEndpointMessages:
+		return []string{adminmodel.ChannelModelEndpointChat, adminmodel.ChannelModelEndpointResponses}
+	case adminmodel.ChannelModelEndpointResponses:
+		return []string{adminmodel.ChannelModelEndpointChat, adminmodel.ChannelModelEndpointMessages}
+	case adminmodel.ChannelModelEndpointChat:
+		return []string{adminmodel.ChannelModelEndpointResponses, adminmodel.ChannelModelEndpointMessages}
+	default:
+		return nil
+	}
+}
+
+// convertTextRequestForUpstream returns the request handed to the adaptor and,
+// when the downstream and upstream text protocols differ, the converted body.
+func convertTextRequestForUpstream(req *relaymodel.GeneralOpenAIRequest, rawBody []byte, downstreamMode int, upstreamMode int) (*relaymodel.GeneralOpenAIRequest, []byte, error) {
+	if !textconv.NeedsConversion(downstreamMode, upstreamMode) {
+		cloned, err := cloneGeneralOpenAIRequest(req)
+		if err != nil {
+			return nil, nil, err
+		}
+		return cloned, nil, nil
+	}
+	if !config.TextEndpointConversionEnabled {
+		return nil, nil, fmt.Errorf(
 			"text endpoint conversion is not allowed: downstream=%s upstream=%s",
 			relayModeLabel(downstreamMode),
 			relayModeLabel(upstreamMode),
 		)
 	}
-	return cloned, nil
+	modelName := ""
+	if req != nil {
+		modelName = req.Model
+	}
+	convertedBody, chatRequest, err := textconv.ConvertRequest(rawBody, downstreamMode, upstreamMode, modelName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return chatRequest, convertedBody, nil
 }
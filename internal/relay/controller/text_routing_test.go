@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"testing"
 
+	"github.com/yeying-community/router/common/config"
 	adminmodel "github.com/yeying-community/router/internal/admin/model"
 	"github.com/yeying-community/router/internal/relay/apitype"
 	relaychannel "github.com/yeying-community/router/internal/relay/channel"
@@ -211,43 +212,96 @@ func TestSupportsMessagesUpstreamZhipu(t *testing.T) {
 	}
 }
 
-func TestConvertTextRequestForUpstreamRejectsChatToResponses(t *testing.T) {
-	req := &relaymodel.GeneralOpenAIRequest{
-		Model: "gpt-4.1",
-		Messages: []relaymodel.Message{{
-			Role:    "user",
-			Content: "hello",
-		}},
-		MaxTokens: 128,
+func TestConvertTextRequestForUpstreamConvertsChatToResponses(t *testing.T) {
+	req := &relaymodel.GeneralOpenAIRequest{Model: "gpt-4.1"}
+	raw := []byte(`{"model":"gpt-4.1-alias","messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hello"}],"max_tokens":128}`)
+
+	pivot, body, err := convertTextRequestForUpstream(req, raw, relaymode.ChatCompletions, relaymode.Responses)
+	if err != nil {
+		t.Fatalf("convertTextRequestForUpstream returned error: %v", err)
+	}
+	if pivot == nil || len(pivot.Messages) != 2 {
+		t.Fatalf("pivot = %#v, want chat request with 2 messages", pivot)
+	}
+	payload := map[string]any{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("json.Unmarshal converted body returned error: %v", err)
+	}
+	if payload["model"] != "gpt-4.1" || payload["instructions"] != "be brief" || payload["max_output_tokens"] != float64(128) {
+		t.Fatalf("converted body = %s, want responses request with mapped model, instructions and max_output_tokens", body)
 	}
+}
 
-	if _, err := convertTextRequestForUpstream(req, relaymode.ChatCompletions, relaymode.Responses); err == nil {
-		t.Fatalf("convertTextRequestForUpstream returned nil error, want endpoint-conversion error")
+func TestConvertTextRequestForUpstreamConvertsResponsesToChat(t *testing.T) {
+	req := &relaymodel.GeneralOpenAIRequest{Model: "gpt-4.1"}
+	raw := []byte(`{"model":"gpt-4.1","input":"hello","max_output_tokens":256,"stream":true}`)
+
+	_, body, err := convertTextRequestForUpstream(req, raw, relaymode.Responses, relaymode.ChatCompletions)
+	if err != nil {
+		t.Fatalf("convertTextRequestForUpstream returned error: %v", err)
+	}
+	converted := relaymodel.GeneralOpenAIRequest{}
+	if err := json.Unmarshal(body, &converted); err != nil {
+		t.Fatalf("json.Unmarshal converted body returned error: %v", err)
+	}
+	if len(converted.Messages) != 1 || converted.Messages[0].StringContent() != "hello" || converted.MaxTokens != 256 {
+		t.Fatalf("converted = %#v, want single user message with max_tokens", converted)
+	}
+	if converted.StreamOptions == nil || !converted.StreamOptions.IncludeUsage {
+		t.Fatalf("converted.StreamOptions = %#v, want include_usage", converted.StreamOptions)
 	}
 }
 
-func TestConvertTextRequestForUpstreamRejectsResponsesToChat(t *testing.T) {
-	req := &relaymodel.GeneralOpenAIRequest{
-		Model:           "gpt-4.1",
-		Input:           "hello",
-		MaxOutputTokens: func() *int { value := 256; return &value }(),
+func TestConvertTextRequestForUpstreamConvertsResponsesToMessages(t *testing.T) {
+	req := &relaymodel.GeneralOpenAIRequest{Model: "claude-sonnet-4-6"}
+	raw := []byte(`{"model":"claude-sonnet-4-6","input":"hello from responses input","instructions":"reply in haiku form","max_output_tokens":320}`)
+
+	_, body, err := convertTextRequestForUpstream(req, raw, relaymode.Responses, relaymode.Messages)
+	if err != nil {
+		t.Fatalf("convertTextRequestForUpstream returned error: %v", err)
+	}
+	payload := struct {
+		System    []map[string]any `json:"system"`
+		Messages  []map[string]any `json:"messages"`
+		MaxTokens int              `json:"max_tokens"`
+	}{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("json.Unmarshal converted body returned error: %v", err)
 	}
+	if len(payload.System) != 1 || payload.System[0]["text"] != "reply in haiku form" {
+		t.Fatalf("payload.System = %#v, want instructions as system block", payload.System)
+	}
+	if len(payload.Messages) != 1 || payload.MaxTokens != 320 {
+		t.Fatalf("converted body = %s, want one message and max_tokens=320", body)
+	}
+}
+
+func TestConvertTextRequestForUpstreamRejectsWhenConversionDisabled(t *testing.T) {
+	previous := config.TextEndpointConversionEnabled
+	config.TextEndpointConversionEnabled = false
+	t.Cleanup(func() { config.TextEndpointConversionEnabled = previous })
 
-	if _, err := convertTextRequestForUpstream(req, relaymode.Responses, relaymode.ChatCompletions); err == nil {
+	req := &relaymodel.GeneralOpenAIRequest{Model: "gpt-4.1"}
+	raw := []byte(`{"model":"gpt-4.1","messages":[{"role":"user","content":"hello"}]}`)
+	if _, _, err := convertTextRequestForUpstream(req, raw, relaymode.ChatCompletions, relaymode.Responses); err == nil {
 		t.Fatalf("convertTextRequestForUpstream returned nil error, want endpoint-conversion error")
 	}
 }
 
-func TestConvertTextRequestForUpstreamRejectsResponsesToMessages(t *testing.T) {
-	req := &relaymodel.GeneralOpenAIRequest{
-		Model:           "claude-sonnet-4-6",
-		Input:           "hello from responses input",
-		Instructions:    "reply in haiku form",
-		MaxOutputTokens: func() *int { value := 320; return &value }(),
+func TestConvertTextRequestForUpstreamRejectsPreviousResponseIDOnChatUpstream(t *testing.T) {
+	req := &relaymodel.GeneralOpenAIRequest{Model: "gpt-4.1"}
+	raw := []byte(`{"model":"gpt-4.1","input":"next","previous_response_id":"resp_1"}`)
+	if _, _, err := convertTextRequestForUpstream(req, raw, relaymode.Responses, relaymode.ChatCompletions); err == nil {
+		t.Fatalf("convertTextRequestForUpstream returned nil error, want stateful request error")
 	}
+}
 
-	if _, err := convertTextRequestForUpstream(req, relaymode.Responses, relaymode.Messages); err == nil {
-		t.Fatalf("convertTextRequestForUpstream returned nil error, want endpoint-conversion error")
+func TestTextConversionEndpointCandidatesPreferChatForNonChatRequests(t *testing.T) {
+	for _, endpoint := range []string{adminmodel.ChannelModelEndpointMessages, adminmodel.ChannelModelEndpointResponses} {
+		candidates := textConversionEndpointCandidates(endpoint)
+		if len(candidates) != 2 || candidates[0] != adminmodel.ChannelModelEndpointChat {
+			t.Fatalf("textConversionEndpointCandidates(%s) = %#v, want chat first", endpoint, candidates)
+		}
 	}
 }
 
@@ -261,13 +315,16 @@ func TestConvertTextRequestForUpstreamSameModePassesThrough(t *testing.T) {
 		MaxTokens: 128,
 	}
 
-	converted, err := convertTextRequestForUpstream(req, relaymode.ChatCompletions, relaymode.ChatCompletions)
+	converted, body, err := convertTextRequestForUpstream(req, nil, relaymode.ChatCompletions, relaymode.ChatCompletions)
 	if err != nil {
 		t.Fatalf("convertTextRequestForUpstream returned error: %v", err)
 	}
 	if len(converted.Messages) != 1 || converted.Messages[0].StringContent() != "hello" {
 		t.Fatalf("converted.Messages = %#v, want original chat message", converted.Messages)
 	}
+	if body != nil {
+		t.Fatalf("converted body = %s, want nil for same-mode request", body)
+	}
 }
 
 func TestNormalizeMessagesRequestBodyUpdatesModel(t *testing.T) {
@@ -1,11 +1,13 @@
 package controller
 
 import (
+	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/yeying-community/router/internal/relay/meta"
+	"github.com/yeying-community/router/internal/relay/streamguard"
 )
 
 func TestLogTextStreamAcceptConflictIgnoresAlignedStreamAndAccept(t *testing.T) {
@@ -26,3 +28,36 @@ func TestLogTextStreamAcceptConflictIgnoresEmptyAccept(t *testing.T) {
 
 	logTextStreamAcceptConflict(ctx, &meta.Meta{IsStream: false})
 }
+
+func TestSettleStreamGuardTurnsEarlyUpstreamErrorIntoRetryableFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	guard := streamguard.New(ctx.Writer, 1<<20)
+	_, _ = guard.WriteString(`data: {"error":{"message":"upstream overloaded","type":"server_error"}}` + "\n\n")
+
+	failure := settleStreamGuard(ctx, &meta.Meta{IsStream: true}, guard, nil)
+	if failure == nil || failure.StatusCode != http.StatusBadGateway || failure.Code != "upstream_stream_error" {
+		t.Fatalf("failure = %#v, want a 502 upstream_stream_error", failure)
+	}
+	if recorder.Body.Len() != 0 {
+		t.Fatalf("nothing may reach the client before a retry, got %q", recorder.Body.String())
+	}
+}
+
+func TestSettleStreamGuardReleasesCleanShortStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	guard := streamguard.New(ctx.Writer, 1<<20)
+	_, _ = guard.WriteString("data: [DONE]\n\n")
+
+	if failure := settleStreamGuard(ctx, &meta.Meta{IsStream: true}, guard, nil); failure != nil {
+		t.Fatalf("unexpected failure %#v", failure)
+	}
+	if recorder.Body.String() != "data: [DONE]\n\n" {
+		t.Fatalf("body = %q, want the held-back stream", recorder.Body.String())
+	}
+}
@@ -497,6 +497,7 @@ func RelayVideoHelper(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatus
 		billing.RecordProcurementConsumptionObservation(ctx, entry)
 		model.UpdateUserUsedQuotaAndRequestCount(meta.UserId, quota)
 		model.UpdateChannelUsedQuota(meta.ChannelId, quota)
+		model.UpdateChannelKeyUsage(meta.ChannelKeyID, quota)
 		consumeTokenRequestCount(ctx, meta.TokenId, 1)
 	}(c.Request.Context())
 	groupQuotaSettled = true
@@ -0,0 +1,271 @@
+package filestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/yeying-community/router/common/logger"
+	"github.com/yeying-community/router/internal/admin/model"
+)
+
+// Uploader pushes a gateway file to the target provider and returns the id
+// the provider assigned to it.
+type Uploader func(ctx context.Context, file model.RelayFile, content []byte) (string, error)
+
+// Target is the channel a request referencing gateway files is relayed to.
+// Without an Uploader file content is inlined as base64.
+type Target struct {
+	UserID    string
+	ChannelID string
+	Uploader  Uploader
+}
+
+var (
+	getFileFunc        = Get
+	readFileFunc       = ReadAll
+	getUpstreamIDFunc  = getUpstreamIDFromDB
+	saveUpstreamIDFunc = saveUpstreamIDToDB
+)
+
+// ResolveReferences rewrites gateway file ids found in chat (`file`),
+// Responses (`input_file`, `input_image`) and Messages (`document`, `image`
+// with a file source) content parts so the target upstream can read them.
+func ResolveReferences(ctx context.Context, raw []byte, target Target) ([]byte, bool, error) {
+	if !bytes.Contains(raw, []byte(`"`+FileIDPrefix)) {
+		return raw, false, nil
+	}
+	var payload any
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return raw, false, nil
+	}
+	resolver := referenceResolver{ctx: ctx, target: target, files: map[string]*resolvedFile{}}
+	if err := resolver.walk(payload); err != nil {
+		return nil, false, err
+	}
+	if !resolver.changed {
+		return raw, false, nil
+	}
+	resolved, err := json.Marshal(payload)
+	if err != nil {
+		return nil, false, err
+	}
+	return resolved, true, nil
+}
+
+type resolvedFile struct {
+	row         model.RelayFile
+	content     []byte
+	upstreamID  string
+	uploadTried bool
+}
+
+type referenceResolver struct {
+	ctx     context.Context
+	target  Target
+	files   map[string]*resolvedFile
+	changed bool
+}
+
+func (r *referenceResolver) walk(node any) error {
+	switch typed := node.(type) {
+	case []any:
+		for _, item := range typed {
+			if err := r.walk(item); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		handled, err := r.rewritePart(typed)
+		if err != nil || handled {
+			return err
+		}
+		for _, value := range typed {
+			if err := r.walk(value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *referenceResolver) rewritePart(part map[string]any) (bool, error) {
+	partType, _ := part["type"].(string)
+	switch partType {
+	case "file":
+		file, _ := part["file"].(map[string]any)
+		fileID, _ := file["file_id"].(string)
+		if !IsLocalFileID(fileID) {
+			return false, nil
+		}
+		resolved, upstreamID, err := r.resolve(fileID, true)
+		if err != nil {
+			return true, err
+		}
+		if resolved == nil {
+			return true, nil
+		}
+		r.changed = true
+		if upstreamID != "" {
+			file["file_id"] = upstreamID
+			return true, nil
+		}
+		if isImage(resolved.row) {
+			delete(part, "file")
+			part["type"] = "image_url"
+			part["image_url"] = map[string]any{"url": dataURL(resolved)}
+			return true, nil
+		}
+		part["file"] = map[string]any{
+			"filename":  resolved.row.Filename,
+			"file_data": dataURL(resolved),
+		}
+		return true, nil
+	case "input_file", "input_image":
+		fileID, _ := part["file_id"].(string)
+		if !IsLocalFileID(fileID) {
+			return false, nil
+		}
+		resolved, upstreamID, err := r.resolve(fileID, true)
+		if err != nil {
+			return true, err
+		}
+		if resolved == nil {
+			return true, nil
+		}
+		r.changed = true
+		if upstreamID != "" {
+			part["file_id"] = upstreamID
+			return true, nil
+		}
+		delete(part, "file_id")
+		if partType == "input_image" {
+			part["image_url"] = dataURL(resolved)
+			return true, nil
+		}
+		part["filename"] = resolved.row.Filename
+		part["file_data"] = dataURL(resolved)
+		return true, nil
+	case "document", "image":
+		source, _ := part["source"].(map[string]any)
+		sourceType, _ := source["type"].(string)
+		fileID, _ := source["file_id"].(string)
+		if sourceType != "file" || !IsLocalFileID(fileID) {
+			return false, nil
+		}
+		// Messages requests always get inline content; provider ids are only
+		// cached for OpenAI-style file references.
+		resolved, _, err := r.resolve(fileID, false)
+		if err != nil {
+			return true, err
+		}
+		if resolved == nil {
+			return true, nil
+		}
+		r.changed = true
+		if partType == "document" && strings.HasPrefix(resolved.row.ContentType, "text/") {
+			part["source"] = map[string]any{"type": "text", "media_type": "text/plain", "data": string(resolved.content)}
+			return true, nil
+		}
+		part["source"] = map[string]any{
+			"type":       "base64",
+			"media_type": resolved.row.ContentType,
+			"data":       base64.StdEncoding.EncodeToString(resolved.content),
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// resolve loads a referenced file, or returns nil when the id is not a gateway
+// file of the user. It returns the provider file id when allowUpload is set
+// and the target has an uploader that succeeded; otherwise the file content
+// is loaded for inlining.
+func (r *referenceResolver) resolve(fileID string, allowUpload bool) (*resolvedFile, string, error) {
+	resolved, ok := r.files[fileID]
+	if !ok {
+		row, err := getFileFunc(fileID, r.target.UserID)
+		if err != nil {
+			if errors.Is(err, ErrFileNotFound) {
+				// OpenAI ids share the prefix; leave unknown ids to the upstream
+				return nil, "", nil
+			}
+			return nil, "", err
+		}
+		resolved = &resolvedFile{row: row}
+		r.files[fileID] = resolved
+	}
+	if allowUpload && r.target.Uploader != nil {
+		if !resolved.uploadTried {
+			resolved.uploadTried = true
+			upstreamID, err := r.upstreamID(resolved)
+			if err != nil {
+				logger.Warnf(r.ctx, "[files] provider upload file_id=%s channel_id=%s failed, inlining instead: %s", fileID, r.target.ChannelID, err.Error())
+			}
+			resolved.upstreamID = upstreamID
+		}
+		if resolved.upstreamID != "" {
+			return resolved, resolved.upstreamID, nil
+		}
+	}
+	if err := r.loadContent(resolved); err != nil {
+		return nil, "", err
+	}
+	return resolved, "", nil
+}
+
+func (r *referenceResolver) upstreamID(resolved *resolvedFile) (string, error) {
+	if cached, err := getUpstreamIDFunc(resolved.row.Id, r.target.ChannelID); err == nil && cached != "" {
+		return cached, nil
+	}
+	if err := r.loadContent(resolved); err != nil {
+		return "", err
+	}
+	upstreamID, err := r.target.Uploader(r.ctx, resolved.row, resolved.content)
+	if err != nil {
+		return "", err
+	}
+	if err := saveUpstreamIDFunc(resolved.row.Id, r.target.ChannelID, upstreamID); err != nil {
+		logger.Warnf(r.ctx, "[files] cache provider file id file_id=%s channel_id=%s failed: %s", resolved.row.Id, r.target.ChannelID, err.Error())
+	}
+	return upstreamID, nil
+}
+
+func (r *referenceResolver) loadContent(resolved *resolvedFile) error {
+	if resolved.content != nil {
+		return nil
+	}
+	content, err := readFileFunc(r.ctx, resolved.row)
+	if err != nil {
+		return err
+	}
+	resolved.content = content
+	return nil
+}
+
+func isImage(row model.RelayFile) bool {
+	return strings.HasPrefix(row.ContentType, "image/")
+}
+
+func dataURL(resolved *resolvedFile) string {
+	contentType := resolved.row.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(resolved.content)
+}
+
+func getUpstreamIDFromDB(fileID string, channelID string) (string, error) {
+	return model.GetRelayFileUpstreamIDWithDB(model.DB, fileID, channelID)
+}
+
+func saveUpstreamIDToDB(fileID string, channelID string, upstreamID string) error {
+	return model.UpsertRelayFileUpstreamWithDB(model.DB, model.RelayFileUpstream{
+		FileID:         fileID,
+		ChannelID:      channelID,
+		UpstreamFileID: upstreamID,
+	})
+}
@@ -0,0 +1,129 @@
+package filestore
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/yeying-community/router/internal/admin/model"
+)
+
+func useMemoryFiles(t *testing.T, rows ...model.RelayFile) map[string]string {
+	t.Helper()
+	previousGet, previousRead := getFileFunc, readFileFunc
+	previousGetUpstream, previousSaveUpstream := getUpstreamIDFunc, saveUpstreamIDFunc
+	t.Cleanup(func() {
+		getFileFunc, readFileFunc = previousGet, previousRead
+		getUpstreamIDFunc, saveUpstreamIDFunc = previousGetUpstream, previousSaveUpstream
+	})
+	files := map[string]model.RelayFile{}
+	for _, row := range rows {
+		files[row.Id] = row
+	}
+	upstreamIDs := map[string]string{}
+	getFileFunc = func(fileID string, userID string) (model.RelayFile, error) {
+		row, ok := files[fileID]
+		if !ok || row.UserID != userID {
+			return model.RelayFile{}, ErrFileNotFound
+		}
+		return row, nil
+	}
+	readFileFunc = func(_ context.Context, row model.RelayFile) ([]byte, error) {
+		return []byte("content of " + row.Id), nil
+	}
+	getUpstreamIDFunc = func(fileID string, channelID string) (string, error) {
+		return upstreamIDs[fileID+"@"+channelID], nil
+	}
+	saveUpstreamIDFunc = func(fileID string, channelID string, upstreamID string) error {
+		upstreamIDs[fileID+"@"+channelID] = upstreamID
+		return nil
+	}
+	return upstreamIDs
+}
+
+func resolveForTest(t *testing.T, raw string, target Target) map[string]any {
+	t.Helper()
+	resolved, changed, err := ResolveReferences(context.Background(), []byte(raw), target)
+	if err != nil {
+		t.Fatalf("ResolveReferences: %v", err)
+	}
+	if !changed {
+		t.Fatalf("ResolveReferences changed = false, want true")
+	}
+	payload := map[string]any{}
+	if err := json.Unmarshal(resolved, &payload); err != nil {
+		t.Fatalf("unmarshal resolved body: %v", err)
+	}
+	return payload
+}
+
+func firstContentPart(t *testing.T, payload map[string]any, key string) map[string]any {
+	t.Helper()
+	items := payload[key].([]any)
+	content := items[0].(map[string]any)["content"].([]any)
+	return content[0].(map[string]any)
+}
+
+func TestResolveReferencesInlinesChatFilesForUser(t *testing.T) {
+	useMemoryFiles(t,
+		model.RelayFile{Id: "file-pdf", UserID: "user-1", Filename: "a.pdf", ContentType: "application/pdf"},
+		model.RelayFile{Id: "file-png", UserID: "user-1", Filename: "a.png", ContentType: "image/png"},
+	)
+	payload := resolveForTest(t, `{"messages":[{"role":"user","content":[
+		{"type":"file","file":{"file_id":"file-pdf"}},
+		{"type":"file","file":{"file_id":"file-png"}}
+	]}]}`, Target{UserID: "user-1", ChannelID: "channel-1"})
+
+	content := payload["messages"].([]any)[0].(map[string]any)["content"].([]any)
+	pdf := content[0].(map[string]any)["file"].(map[string]any)
+	if pdf["filename"] != "a.pdf" || pdf["file_data"] != "data:application/pdf;base64,Y29udGVudCBvZiBmaWxlLXBkZg==" {
+		t.Fatalf("pdf part = %#v, want inline file_data", pdf)
+	}
+	image := content[1].(map[string]any)
+	if image["type"] != "image_url" || image["image_url"].(map[string]any)["url"] != "data:image/png;base64,Y29udGVudCBvZiBmaWxlLXBuZw==" {
+		t.Fatalf("image part = %#v, want image_url data URL", image)
+	}
+}
+
+func TestResolveReferencesUploadsOncePerChannel(t *testing.T) {
+	upstreamIDs := useMemoryFiles(t, model.RelayFile{Id: "file-doc", UserID: "user-1", Filename: "a.pdf", ContentType: "application/pdf"})
+	uploads := 0
+	target := Target{
+		UserID:    "user-1",
+		ChannelID: "channel-1",
+		Uploader: func(_ context.Context, file model.RelayFile, content []byte) (string, error) {
+			uploads++
+			return "file-provider-1", nil
+		},
+	}
+	raw := `{"input":[{"role":"user","content":[{"type":"input_file","file_id":"file-doc"}]},{"role":"user","content":[{"type":"input_file","file_id":"file-doc"}]}]}`
+
+	payload := resolveForTest(t, raw, target)
+	if part := firstContentPart(t, payload, "input"); part["file_id"] != "file-provider-1" {
+		t.Fatalf("part = %#v, want provider file id", part)
+	}
+	resolveForTest(t, raw, target)
+	if uploads != 1 || upstreamIDs["file-doc@channel-1"] != "file-provider-1" {
+		t.Fatalf("uploads = %d cache = %#v, want one cached upload", uploads, upstreamIDs)
+	}
+}
+
+func TestResolveReferencesInlinesMessagesDocuments(t *testing.T) {
+	useMemoryFiles(t, model.RelayFile{Id: "file-doc", UserID: "user-1", ContentType: "application/pdf"})
+	payload := resolveForTest(t, `{"messages":[{"role":"user","content":[{"type":"document","source":{"type":"file","file_id":"file-doc"}}]}]}`,
+		Target{UserID: "user-1", ChannelID: "channel-1"})
+
+	source := firstContentPart(t, payload, "messages")["source"].(map[string]any)
+	if source["type"] != "base64" || source["media_type"] != "application/pdf" || source["data"] != "Y29udGVudCBvZiBmaWxlLWRvYw==" {
+		t.Fatalf("source = %#v, want base64 document", source)
+	}
+}
+
+func TestResolveReferencesLeavesForeignIDsAlone(t *testing.T) {
+	useMemoryFiles(t, model.RelayFile{Id: "file-doc", UserID: "user-1"})
+	raw := `{"input":[{"role":"user","content":[{"type":"input_file","file_id":"file-doc"}]}]}`
+	resolved, changed, err := ResolveReferences(context.Background(), []byte(raw), Target{UserID: "user-2"})
+	if err != nil || changed || string(resolved) != raw {
+		t.Fatalf("ResolveReferences = %s, %t, %v; want other users' ids untouched", resolved, changed, err)
+	}
+}
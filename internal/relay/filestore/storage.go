@@ -0,0 +1,145 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/yeying-community/router/common/config"
+)
+
+const StorageBackendLocal = "local"
+
+var ErrObjectNotFound = errors.New("stored object not found")
+
+// Storage keeps file content addressed by an opaque key. Implementations must
+// be safe for concurrent use.
+type Storage interface {
+	Name() string
+	Put(ctx context.Context, key string, content io.Reader) (int64, error)
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+type StorageFactory func() (Storage, error)
+
+var (
+	storageFactoriesMu sync.RWMutex
+	storageFactories   = map[string]StorageFactory{
+		StorageBackendLocal: func() (Storage, error) {
+			return NewLocalStorage(config.FilesStorageDir), nil
+		},
+	}
+)
+
+// RegisterStorageBackend makes a backend selectable through
+// relay.files_storage_backend.
+func RegisterStorageBackend(name string, factory StorageFactory) {
+	normalizedName := strings.ToLower(strings.TrimSpace(name))
+	if normalizedName == "" || factory == nil {
+		return
+	}
+	storageFactoriesMu.Lock()
+	defer storageFactoriesMu.Unlock()
+	storageFactories[normalizedName] = factory
+}
+
+// StorageFor returns the backend with the given name, falling back to the
+// configured default when name is empty.
+func StorageFor(name string) (Storage, error) {
+	normalizedName := strings.ToLower(strings.TrimSpace(name))
+	if normalizedName == "" {
+		normalizedName = strings.ToLower(strings.TrimSpace(config.FilesStorageBackend))
+	}
+	if normalizedName == "" {
+		normalizedName = StorageBackendLocal
+	}
+	storageFactoriesMu.RLock()
+	factory, ok := storageFactories[normalizedName]
+	storageFactoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported files storage backend %q", normalizedName)
+	}
+	return factory()
+}
+
+// LocalStorage stores objects as plain files below a root directory.
+type LocalStorage struct {
+	root string
+}
+
+func NewLocalStorage(root string) *LocalStorage {
+	normalizedRoot := strings.TrimSpace(root)
+	if normalizedRoot == "" {
+		normalizedRoot = "./data/files"
+	}
+	return &LocalStorage{root: normalizedRoot}
+}
+
+func (s *LocalStorage) Name() string {
+	return StorageBackendLocal
+}
+
+func (s *LocalStorage) Put(_ context.Context, key string, content io.Reader) (int64, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return 0, err
+	}
+	written, copyErr := io.Copy(tmp, content)
+	closeErr := tmp.Close()
+	if copyErr == nil {
+		copyErr = closeErr
+	}
+	if copyErr != nil {
+		_ = os.Remove(tmp.Name())
+		return written, copyErr
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return written, err
+	}
+	return written, nil
+}
+
+func (s *LocalStorage) Open(_ context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrObjectNotFound
+	}
+	return file, err
+}
+
+func (s *LocalStorage) Delete(_ context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStorage) path(key string) (string, error) {
+	cleaned := filepath.Clean(filepath.FromSlash(strings.TrimSpace(key)))
+	if cleaned == "." || filepath.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return filepath.Join(s.root, cleaned), nil
+}
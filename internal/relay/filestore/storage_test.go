@@ -0,0 +1,43 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorageRoundTrip(t *testing.T) {
+	storage := NewLocalStorage(t.TempDir())
+	ctx := context.Background()
+
+	written, err := storage.Put(ctx, "user-1/file-a", strings.NewReader("hello"))
+	if err != nil || written != 5 {
+		t.Fatalf("Put = %d, %v; want 5 bytes", written, err)
+	}
+	content, err := storage.Open(ctx, "user-1/file-a")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	data, _ := io.ReadAll(content)
+	content.Close()
+	if string(data) != "hello" {
+		t.Fatalf("content = %q, want hello", data)
+	}
+	if err := storage.Delete(ctx, "user-1/file-a"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := storage.Open(ctx, "user-1/file-a"); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("Open after delete err = %v, want ErrObjectNotFound", err)
+	}
+}
+
+func TestLocalStorageRejectsEscapingKeys(t *testing.T) {
+	storage := NewLocalStorage(t.TempDir())
+	for _, key := range []string{"../outside", "/etc/passwd", "", "a/../../b"} {
+		if _, err := storage.Put(context.Background(), key, strings.NewReader("x")); err == nil {
+			t.Fatalf("Put(%q) succeeded, want invalid key error", key)
+		}
+	}
+}
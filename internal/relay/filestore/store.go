@@ -0,0 +1,322 @@
+package filestore
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/yeying-community/router/common/config"
+	"github.com/yeying-community/router/common/graceful"
+	"github.com/yeying-community/router/common/heartbeat"
+	"github.com/yeying-community/router/common/helper"
+	"github.com/yeying-community/router/common/logger"
+	"github.com/yeying-community/router/common/random"
+	"github.com/yeying-community/router/internal/admin/model"
+)
+
+const (
+	FileIDPrefix    = "file-"
+	StatusProcessed = "processed"
+
+	filePruneInterval  = 30 * time.Minute
+	filePruneBatchSize = 200
+)
+
+var (
+	ErrFileNotFound  = errors.New("file not found")
+	ErrFileTooLarge  = errors.New("file exceeds the maximum upload size")
+	ErrQuotaExceeded = errors.New("file storage quota exceeded")
+
+	startFilePruneWorkerOnce sync.Once
+)
+
+var supportedPurposes = map[string]struct{}{
+	"assistants": {},
+	"batch":      {},
+	"fine-tune":  {},
+	"vision":     {},
+	"user_data":  {},
+	"evals":      {},
+}
+
+// Object is the OpenAI file object returned by the Files API.
+type Object struct {
+	ID        string `json:"id"`
+	Object    string `json:"object"`
+	Bytes     int64  `json:"bytes"`
+	CreatedAt int64  `json:"created_at"`
+	ExpiresAt *int64 `json:"expires_at,omitempty"`
+	Filename  string `json:"filename"`
+	Purpose   string `json:"purpose"`
+	Status    string `json:"status"`
+}
+
+func NewObject(row model.RelayFile) Object {
+	object := Object{
+		ID:        row.Id,
+		Object:    "file",
+		Bytes:     row.Bytes,
+		CreatedAt: row.CreatedAt,
+		Filename:  row.Filename,
+		Purpose:   row.Purpose,
+		Status:    row.Status,
+	}
+	if row.ExpiresAt > 0 {
+		expiresAt := row.ExpiresAt
+		object.ExpiresAt = &expiresAt
+	}
+	return object
+}
+
+type UploadRequest struct {
+	UserID      string
+	Filename    string
+	Purpose     string
+	ContentType string
+	// Size is the declared size, or a negative value when unknown.
+	Size                int64
+	ExpiresAfterSeconds int64
+	Content             io.Reader
+}
+
+func ValidPurpose(purpose string) bool {
+	_, ok := supportedPurposes[strings.TrimSpace(purpose)]
+	return ok
+}
+
+func MaxUploadBytes() int64 {
+	sizeMB := config.FilesMaxUploadSizeMB
+	if sizeMB <= 0 {
+		sizeMB = 512
+	}
+	return int64(sizeMB) << 20
+}
+
+func userQuotaBytes() int64 {
+	if config.FilesUserQuotaMB <= 0 {
+		return 0
+	}
+	return int64(config.FilesUserQuotaMB) << 20
+}
+
+// IsLocalFileID reports whether id may be a gateway file id. OpenAI uses the
+// same prefix, so callers still have to look the id up.
+func IsLocalFileID(id string) bool {
+	return strings.HasPrefix(strings.TrimSpace(id), FileIDPrefix)
+}
+
+// Create stores the uploaded content and records it for the user, enforcing
+// the per-file size limit and the per-user storage quota.
+func Create(ctx context.Context, request UploadRequest) (model.RelayFile, error) {
+	userID := strings.TrimSpace(request.UserID)
+	if userID == "" {
+		return model.RelayFile{}, fmt.Errorf("file owner cannot be empty")
+	}
+	maxBytes := MaxUploadBytes()
+	if request.Size > maxBytes {
+		return model.RelayFile{}, ErrFileTooLarge
+	}
+	quotaBytes := userQuotaBytes()
+	if quotaBytes > 0 && request.Size > 0 {
+		// rejects a declared size early; the insert below checks again
+		usedBytes, err := model.SumRelayFileBytesWithDB(model.DB, userID)
+		if err != nil {
+			return model.RelayFile{}, err
+		}
+		if usedBytes+request.Size > quotaBytes {
+			return model.RelayFile{}, ErrQuotaExceeded
+		}
+	}
+	storage, err := StorageFor("")
+	if err != nil {
+		return model.RelayFile{}, err
+	}
+
+	fileID := FileIDPrefix + random.GetUUID()
+	storageKey := userID + "/" + fileID
+	reader := bufio.NewReader(request.Content)
+	contentType := detectContentType(reader, request.ContentType, request.Filename)
+	written, err := storage.Put(ctx, storageKey, io.LimitReader(reader, maxBytes+1))
+	if err != nil {
+		return model.RelayFile{}, err
+	}
+	if written > maxBytes {
+		removeStoredObject(ctx, storage, storageKey)
+		return model.RelayFile{}, ErrFileTooLarge
+	}
+
+	filename := filepath.Base(strings.TrimSpace(request.Filename))
+	if filename == "." || filename == string(filepath.Separator) {
+		filename = fileID
+	}
+	now := helper.GetTimestamp()
+	row := model.RelayFile{
+		Id:             fileID,
+		UserID:         userID,
+		Filename:       filename,
+		Purpose:        strings.TrimSpace(request.Purpose),
+		Bytes:          written,
+		ContentType:    contentType,
+		StorageBackend: storage.Name(),
+		StorageKey:     storageKey,
+		Status:         StatusProcessed,
+		CreatedAt:      now,
+	}
+	if request.ExpiresAfterSeconds > 0 {
+		row.ExpiresAt = now + request.ExpiresAfterSeconds
+	}
+	created, err := model.CreateRelayFileWithinQuotaWithDB(model.DB, row, quotaBytes)
+	if err != nil || !created {
+		removeStoredObject(ctx, storage, storageKey)
+		if err == nil {
+			err = ErrQuotaExceeded
+		}
+		return model.RelayFile{}, err
+	}
+	return row, nil
+}
+
+// Get returns the file when it exists and belongs to userID.
+func Get(fileID string, userID string) (model.RelayFile, error) {
+	row, err := model.GetRelayFileWithDB(model.DB, fileID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.RelayFile{}, ErrFileNotFound
+		}
+		return model.RelayFile{}, err
+	}
+	return row, nil
+}
+
+// List returns one page of the user's files and whether more follow.
+func List(query model.RelayFileListQuery) ([]model.RelayFile, bool, error) {
+	rows, err := model.ListRelayFilesWithDB(model.DB, query)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, ErrFileNotFound
+		}
+		return nil, false, err
+	}
+	limit := query.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	if len(rows) > limit {
+		return rows[:limit], true, nil
+	}
+	return rows, false, nil
+}
+
+func Open(ctx context.Context, row model.RelayFile) (io.ReadCloser, error) {
+	storage, err := StorageFor(row.StorageBackend)
+	if err != nil {
+		return nil, err
+	}
+	content, err := storage.Open(ctx, row.StorageKey)
+	if errors.Is(err, ErrObjectNotFound) {
+		return nil, ErrFileNotFound
+	}
+	return content, err
+}
+
+func ReadAll(ctx context.Context, row model.RelayFile) ([]byte, error) {
+	content, err := Open(ctx, row)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+	return io.ReadAll(content)
+}
+
+func Delete(ctx context.Context, fileID string, userID string) (bool, error) {
+	row, deleted, err := model.DeleteRelayFileWithDB(model.DB, fileID, userID)
+	if err != nil || !deleted {
+		return false, err
+	}
+	if storage, err := StorageFor(row.StorageBackend); err == nil {
+		removeStoredObject(ctx, storage, row.StorageKey)
+	} else {
+		logger.Warnf(ctx, "[files] delete content file_id=%s failed: %s", row.Id, err.Error())
+	}
+	return true, nil
+}
+
+func removeStoredObject(ctx context.Context, storage Storage, key string) {
+	if err := storage.Delete(ctx, key); err != nil {
+		logger.Warnf(ctx, "[files] delete stored object key=%s backend=%s failed: %s", key, storage.Name(), err.Error())
+	}
+}
+
+func detectContentType(reader *bufio.Reader, declared string, filename string) string {
+	if mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(declared)); err == nil && mediaType != "application/octet-stream" {
+		return mediaType
+	}
+	if byExtension := mime.TypeByExtension(strings.ToLower(filepath.Ext(filename))); byExtension != "" {
+		if mediaType, _, err := mime.ParseMediaType(byExtension); err == nil {
+			return mediaType
+		}
+	}
+	head, _ := reader.Peek(512)
+	if len(head) == 0 {
+		return "application/octet-stream"
+	}
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	return mediaType
+}
+
+func StartFilePruneWorker() {
+	startFilePruneWorkerOnce.Do(func() { graceful.Worker(runFilePruneWorker) })
+}
+
+func runFilePruneWorker() {
+	logger.SysLog("[files] prune worker started")
+	ticker := time.NewTicker(filePruneInterval)
+	defer ticker.Stop()
+	for {
+		heartbeat.Beat("file_prune", filePruneInterval)
+		pruneExpiredFiles()
+		select {
+		case <-graceful.Stopping():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func pruneExpiredFiles() {
+	ctx := context.Background()
+	total := 0
+	for {
+		rows, err := model.ListExpiredRelayFilesWithDB(model.DB, helper.GetTimestamp(), filePruneBatchSize)
+		if err != nil {
+			logger.SysWarnf("[files] list expired files failed: %s", err.Error())
+			return
+		}
+		for _, row := range rows {
+			deleted, err := Delete(ctx, row.Id, row.UserID)
+			if err != nil {
+				logger.SysWarnf("[files] prune file_id=%s failed: %s", row.Id, err.Error())
+				return
+			}
+			if deleted {
+				total++
+			}
+		}
+		if len(rows) < filePruneBatchSize {
+			break
+		}
+	}
+	if total > 0 {
+		logger.SysLogf("[files] pruned expired files count=%d", total)
+	}
+}
@@ -0,0 +1,92 @@
+package filestore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/yeying-community/router/common/config"
+	"github.com/yeying-community/router/internal/admin/model"
+)
+
+func useTestFileStore(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=private"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&model.User{}, &model.RelayFile{}, &model.RelayFileUpstream{}); err != nil {
+		t.Fatalf("migrate relay files: %v", err)
+	}
+	for _, userID := range []string{"user-1", "user-2"} {
+		if err := db.Create(&model.User{Id: userID, Username: userID, Password: "password", AccessToken: userID, AffCode: userID}).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+	previousDB := model.DB
+	previousBackend, previousDir := config.FilesStorageBackend, config.FilesStorageDir
+	previousMax, previousQuota := config.FilesMaxUploadSizeMB, config.FilesUserQuotaMB
+	t.Cleanup(func() {
+		model.DB = previousDB
+		config.FilesStorageBackend, config.FilesStorageDir = previousBackend, previousDir
+		config.FilesMaxUploadSizeMB, config.FilesUserQuotaMB = previousMax, previousQuota
+	})
+	model.DB = db
+	config.FilesStorageBackend = StorageBackendLocal
+	config.FilesStorageDir = t.TempDir()
+	config.FilesMaxUploadSizeMB = 1
+	config.FilesUserQuotaMB = 2
+}
+
+func TestCreateStoresContentAndEnforcesLimits(t *testing.T) {
+	useTestFileStore(t)
+	ctx := context.Background()
+	upload := func(userID string, size int) (model.RelayFile, error) {
+		return Create(ctx, UploadRequest{
+			UserID:   userID,
+			Filename: "data.jsonl",
+			Purpose:  "batch",
+			Size:     -1,
+			Content:  bytes.NewReader(bytes.Repeat([]byte("a"), size)),
+		})
+	}
+
+	row, err := upload("user-1", 1<<20)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if row.Bytes != 1<<20 || row.Status != StatusProcessed || row.Filename != "data.jsonl" {
+		t.Fatalf("row = %#v, want processed 1MB file", row)
+	}
+	content, err := ReadAll(ctx, row)
+	if err != nil || len(content) != 1<<20 {
+		t.Fatalf("ReadAll = %d bytes, %v; want stored content", len(content), err)
+	}
+	if _, err := upload("user-1", 1<<20+1); !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("oversized upload err = %v, want ErrFileTooLarge", err)
+	}
+	if _, err := upload("user-1", 1<<20); err != nil {
+		t.Fatalf("second upload within quota: %v", err)
+	}
+	if _, err := upload("user-1", 1); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("upload over quota err = %v, want ErrQuotaExceeded", err)
+	}
+	if _, err := upload("user-2", 1); err != nil {
+		t.Fatalf("other user's quota is separate: %v", err)
+	}
+
+	if _, err := Get(row.Id, "user-2"); !errors.Is(err, ErrFileNotFound) {
+		t.Fatalf("Get by other user err = %v, want ErrFileNotFound", err)
+	}
+	deleted, err := Delete(ctx, row.Id, "user-1")
+	if err != nil || !deleted {
+		t.Fatalf("Delete = %t, %v; want deleted", deleted, err)
+	}
+	if _, err := ReadAll(ctx, row); !errors.Is(err, ErrFileNotFound) {
+		t.Fatalf("ReadAll after delete err = %v, want ErrFileNotFound", err)
+	}
+}
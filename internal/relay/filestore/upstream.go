@@ -0,0 +1,80 @@
+package filestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+
+	"github.com/yeying-community/router/common/client"
+	"github.com/yeying-community/router/internal/admin/model"
+)
+
+const maxUpstreamErrorBodyBytes = 4 << 10
+
+// NewOpenAIUploader uploads files to an OpenAI-compatible POST /v1/files
+// endpoint.
+func NewOpenAIUploader(endpoint string, apiKey string) Uploader {
+	return func(ctx context.Context, file model.RelayFile, content []byte) (string, error) {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		if err := writer.WriteField("purpose", upstreamPurpose(file)); err != nil {
+			return "", err
+		}
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename=%q`, file.Filename))
+		if file.ContentType != "" {
+			header.Set("Content-Type", file.ContentType)
+		}
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return "", err
+		}
+		if _, err := part.Write(content); err != nil {
+			return "", err
+		}
+		if err := writer.Close(); err != nil {
+			return "", err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, body)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			detail, _ := io.ReadAll(io.LimitReader(resp.Body, maxUpstreamErrorBodyBytes))
+			return "", fmt.Errorf("upstream file upload failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(detail)))
+		}
+		uploaded := struct {
+			ID string `json:"id"`
+		}{}
+		if err := json.NewDecoder(resp.Body).Decode(&uploaded); err != nil {
+			return "", fmt.Errorf("decode upstream file object failed: %w", err)
+		}
+		if strings.TrimSpace(uploaded.ID) == "" {
+			return "", fmt.Errorf("upstream file object has no id")
+		}
+		return strings.TrimSpace(uploaded.ID), nil
+	}
+}
+
+func upstreamPurpose(file model.RelayFile) string {
+	switch file.Purpose {
+	case "batch", "fine-tune", "assistants", "vision", "evals":
+		return file.Purpose
+	default:
+		return "user_data"
+	}
+}
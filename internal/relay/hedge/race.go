@@ -0,0 +1,205 @@
+package hedge
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeying-community/router/common/ctxkey"
+)
+
+// ErrLost is returned by the writes of an attempt after another attempt of
+// the same race has started the response.
+var ErrLost = errors.New("hedged attempt lost the race")
+
+// Race arbitrates the downstream response between the attempts of one hedged
+// request. The first attempt to write claims the real writer; every other
+// attempt is canceled and its output discarded.
+type Race struct {
+	mu      sync.Mutex
+	target  gin.ResponseWriter
+	writers []*Writer
+	winner  *Writer
+}
+
+func NewRace(target gin.ResponseWriter) *Race {
+	return &Race{target: target}
+}
+
+// NewWriter registers an attempt. cancel aborts the attempt's upstream
+// request once it has lost.
+func (r *Race) NewWriter(cancel context.CancelFunc) *Writer {
+	w := &Writer{race: r, cancel: cancel, header: http.Header{}}
+	r.mu.Lock()
+	r.writers = append(r.writers, w)
+	r.mu.Unlock()
+	return w
+}
+
+// Winner returns the attempt that started the response, nil while none has.
+func (r *Race) Winner() *Writer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.winner
+}
+
+// Close cancels every attempt still holding its context.
+func (r *Race) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, w := range r.writers {
+		if w.cancel != nil {
+			w.cancel()
+		}
+	}
+}
+
+func (r *Race) claim(w *Writer) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.winner != nil {
+		return r.winner == w
+	}
+	r.winner = w
+	for key, values := range w.header {
+		r.target.Header()[key] = values
+	}
+	if w.status != 0 {
+		r.target.WriteHeader(w.status)
+	}
+	for _, other := range r.writers {
+		if other != w {
+			other.lost = true
+			if other.cancel != nil {
+				other.cancel()
+			}
+		}
+	}
+	return true
+}
+
+func (r *Race) isWinner(w *Writer) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.winner == w
+}
+
+func (r *Race) isLost(w *Writer) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return w.lost
+}
+
+// Writer is the response writer of one hedged attempt. Headers and status are
+// buffered until the attempt writes, which claims the downstream response.
+type Writer struct {
+	race   *Race
+	cancel context.CancelFunc
+	header http.Header
+	status int
+	lost   bool
+}
+
+// Lost reports whether another attempt has started the response.
+func (w *Writer) Lost() bool {
+	return w.race.isLost(w)
+}
+
+func (w *Writer) Header() http.Header {
+	if w.race.isWinner(w) {
+		return w.race.target.Header()
+	}
+	return w.header
+}
+
+func (w *Writer) WriteHeader(code int) {
+	if w.race.isWinner(w) {
+		w.race.target.WriteHeader(code)
+		return
+	}
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *Writer) WriteHeaderNow() {
+	if w.race.claim(w) {
+		w.race.target.WriteHeaderNow()
+	}
+}
+
+func (w *Writer) Write(data []byte) (int, error) {
+	if !w.race.claim(w) {
+		return 0, ErrLost
+	}
+	return w.race.target.Write(data)
+}
+
+func (w *Writer) WriteString(s string) (int, error) {
+	if !w.race.claim(w) {
+		return 0, ErrLost
+	}
+	return w.race.target.WriteString(s)
+}
+
+// Flush commits the response like a write does: adaptors flush once the
+// upstream stream has started.
+func (w *Writer) Flush() {
+	if w.race.claim(w) {
+		w.race.target.Flush()
+	}
+}
+
+func (w *Writer) Status() int {
+	if w.race.isWinner(w) {
+		return w.race.target.Status()
+	}
+	if w.status != 0 {
+		return w.status
+	}
+	return http.StatusOK
+}
+
+func (w *Writer) Size() int {
+	if w.race.isWinner(w) {
+		return w.race.target.Size()
+	}
+	return -1
+}
+
+func (w *Writer) Written() bool {
+	if w.race.isWinner(w) {
+		return w.race.target.Written()
+	}
+	return false
+}
+
+func (w *Writer) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errors.New("hedged response does not support hijacking")
+}
+
+func (w *Writer) CloseNotify() <-chan bool {
+	return w.race.target.CloseNotify()
+}
+
+func (w *Writer) Pusher() http.Pusher {
+	return nil
+}
+
+// Lost reports whether the relay in c is a hedged attempt that lost its race.
+// Such an attempt must not be billed.
+func Lost(c *gin.Context) bool {
+	if c == nil {
+		return false
+	}
+	value, ok := c.Get(ctxkey.RelayHedgeWriter)
+	if !ok {
+		return false
+	}
+	w, ok := value.(*Writer)
+	return ok && w.Lost()
+}
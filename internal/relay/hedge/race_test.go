@@ -0,0 +1,79 @@
+package hedge
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeying-community/router/common/ctxkey"
+)
+
+func TestRaceFirstWriterClaimsResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	race := NewRace(c.Writer)
+
+	primaryCtx, cancelPrimary := context.WithCancel(context.Background())
+	hedgeCtx, cancelHedge := context.WithCancel(context.Background())
+	primary := race.NewWriter(cancelPrimary)
+	hedged := race.NewWriter(cancelHedge)
+
+	primary.Header().Set("X-Attempt", "primary")
+	hedged.Header().Set("X-Attempt", "hedge")
+	hedged.Header().Set("Content-Type", "text/event-stream")
+	hedged.WriteHeader(http.StatusCreated)
+	if recorder.Header().Get("X-Attempt") != "" {
+		t.Fatalf("headers must stay buffered until an attempt writes")
+	}
+
+	if _, err := hedged.Write([]byte("data: 1\n\n")); err != nil {
+		t.Fatalf("winner write: %v", err)
+	}
+	if race.Winner() != hedged {
+		t.Fatalf("hedge attempt should have claimed the response")
+	}
+	if _, err := primary.Write([]byte("data: late\n\n")); !errors.Is(err, ErrLost) {
+		t.Fatalf("loser write err = %v, want ErrLost", err)
+	}
+	primary.Flush()
+	if primaryCtx.Err() == nil {
+		t.Fatalf("loser context should be canceled")
+	}
+	if hedgeCtx.Err() != nil {
+		t.Fatalf("winner context must stay alive")
+	}
+	if !primary.Lost() || hedged.Lost() {
+		t.Fatalf("lost flags = %v/%v, want true/false", primary.Lost(), hedged.Lost())
+	}
+
+	if recorder.Code != http.StatusCreated || recorder.Header().Get("X-Attempt") != "hedge" || recorder.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("response = %d %v, want the winner's status and headers", recorder.Code, recorder.Header())
+	}
+	if recorder.Body.String() != "data: 1\n\n" {
+		t.Fatalf("body = %q, want only the winner's output", recorder.Body.String())
+	}
+}
+
+func TestLostReadsAttemptWriterFromContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	race := NewRace(c.Writer)
+	first := race.NewWriter(nil)
+	second := race.NewWriter(nil)
+
+	attempt, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if Lost(attempt) {
+		t.Fatalf("a relay without hedging is never lost")
+	}
+	attempt.Set(ctxkey.RelayHedgeWriter, second)
+	if _, err := first.WriteString("ok"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if !Lost(attempt) {
+		t.Fatalf("second attempt should be reported lost")
+	}
+}
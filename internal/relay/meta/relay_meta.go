@@ -11,12 +11,14 @@ import (
 	relaychannel "github.com/yeying-community/router/internal/relay/channel"
 	"github.com/yeying-community/router/internal/relay/relaymode"
 	"github.com/yeying-community/router/internal/relay/routeobs"
+	"github.com/yeying-community/router/internal/relay/timing"
 )
 
 type Meta struct {
 	Mode                  int
 	ChannelProtocol       int
 	ChannelId             string
+	ChannelKeyID          string
 	TokenId               string
 	TokenName             string
 	UserId                string
@@ -34,8 +36,12 @@ type Meta struct {
 	APIType  int
 	Config   model.ChannelConfig
 	IsStream bool
-	// OriginModelName is the model name from the raw user request
+	// OriginModelName is the model name from the raw user request, or the
+	// model the router switched it to for an alias or fallback
 	OriginModelName string
+	// ClientModelName is the model the client asked for when the router
+	// switched it; empty otherwise
+	ClientModelName string
 	// ActualModelName is the model name after mapping
 	ActualModelName     string
 	RequestURLPath      string
@@ -49,6 +55,14 @@ type Meta struct {
 	RelayErrorType      string
 	RelayErrorCode      string
 	RelayErrorMessage   string
+	ModerationDecision  string
+	ModerationReason    string
+	// RateLimitScopes are the token and user limits the request counts
+	// against; its tokens are added to their TPM windows after the relay
+	RateLimitScopes []model.RateLimitScope
+	// Timing tracks when the attempt's upstream response arrived; nil
+	// outside the relay pipeline
+	Timing *timing.Tracker
 }
 
 func GetByContext(c *gin.Context) *Meta {
@@ -57,6 +71,7 @@ func GetByContext(c *gin.Context) *Meta {
 		Mode:                  relaymode.GetByPath(c.Request.URL.Path),
 		ChannelProtocol:       c.GetInt(ctxkey.Channel),
 		ChannelId:             c.GetString(ctxkey.ChannelId),
+		ChannelKeyID:          c.GetString(ctxkey.ChannelKeyId),
 		TokenId:               c.GetString(ctxkey.TokenId),
 		TokenName:             c.GetString(ctxkey.TokenName),
 		UserId:                c.GetString(ctxkey.Id),
@@ -66,6 +81,7 @@ func GetByContext(c *gin.Context) *Meta {
 		EntitlementSourceName: c.GetString(ctxkey.EntitlementSourceName),
 		ModelMapping:          c.GetStringMapString(ctxkey.ModelMapping),
 		OriginModelName:       c.GetString(ctxkey.RequestModel),
+		ClientModelName:       c.GetString(ctxkey.ClientRequestModel),
 		BaseURL:               c.GetString(ctxkey.BaseURL),
 		APIKey:                strings.TrimPrefix(c.Request.Header.Get("Authorization"), "Bearer "),
 		RequestURLPath:        normalizedPath,
@@ -78,11 +94,17 @@ func GetByContext(c *gin.Context) *Meta {
 		RelayErrorType:        c.GetString(ctxkey.RelayErrorType),
 		RelayErrorCode:        c.GetString(ctxkey.RelayErrorCode),
 		RelayErrorMessage:     c.GetString(ctxkey.RelayError),
+		ModerationDecision:    c.GetString(ctxkey.ModerationDecision),
+		ModerationReason:      c.GetString(ctxkey.ModerationReason),
+		Timing:                timing.FromContext(c),
 	}
 	cfg, ok := c.Get(ctxkey.Config)
 	if ok {
 		meta.Config = cfg.(model.ChannelConfig)
 	}
+	if scopes, ok := c.Get(ctxkey.RateLimitScopes); ok {
+		meta.RateLimitScopes, _ = scopes.([]model.RateLimitScope)
+	}
 	if policyBaseURL := model.CacheGetChannelModelEndpointAccessPolicyBaseURL(
 		meta.ChannelId,
 		c.Request.URL.Path,
@@ -116,3 +138,16 @@ func GetByContext(c *gin.Context) *Meta {
 	)
 	return &meta
 }
+
+// UpstreamView returns a copy of meta whose Mode is the upstream protocol, so
+// adaptors relay a converted text request the same way as a native one.
+func (m *Meta) UpstreamView() *Meta {
+	if m == nil {
+		return nil
+	}
+	view := *m
+	if m.UpstreamMode != 0 {
+		view.Mode = m.UpstreamMode
+	}
+	return &view
+}
@@ -1,5 +1,9 @@
 package model
 
+// ImageVariationPrompt is sent to providers that emulate /v1/images/variations
+// through image-to-image generation, since they all require a prompt.
+const ImageVariationPrompt = "Create a variation of this image that keeps its subject, composition and style."
+
 type ImageRequest struct {
 	Model          string `json:"model"`
 	Prompt         string `json:"prompt" binding:"required"`
@@ -1,5 +1,7 @@
 package model
 
+import "strings"
+
 type Message struct {
 	Role             string  `json:"role,omitempty"`
 	Content          any     `json:"content,omitempty"`
@@ -7,6 +9,16 @@ type Message struct {
 	Name             *string `json:"name,omitempty"`
 	ToolCalls        []Tool  `json:"tool_calls,omitempty"`
 	ToolCallId       string  `json:"tool_call_id,omitempty"`
+	// ThinkingBlocks carries signed Claude thinking blocks so they can be
+	// replayed on the next turn; reasoning_content alone cannot be verified.
+	ThinkingBlocks []ThinkingBlock `json:"thinking_blocks,omitempty"`
+}
+
+type ThinkingBlock struct {
+	Type      string `json:"type"`
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Data      string `json:"data,omitempty"`
 }
 
 func (m Message) IsStringContent() bool {
@@ -59,8 +71,9 @@ func (m Message) ParseContent() []MessageContent {
 			case ContentTypeText:
 				if subStr, ok := contentMap["text"].(string); ok {
 					contentList = append(contentList, MessageContent{
-						Type: ContentTypeText,
-						Text: subStr,
+						Type:         ContentTypeText,
+						Text:         subStr,
+						CacheControl: contentMap["cache_control"],
 					})
 				}
 			case ContentTypeImageURL:
@@ -70,6 +83,19 @@ func (m Message) ParseContent() []MessageContent {
 						ImageURL: &ImageURL{
 							Url: subObj["url"].(string),
 						},
+						CacheControl: contentMap["cache_control"],
+					})
+				}
+			case ContentTypeFile:
+				if subObj, ok := contentMap["file"].(map[string]any); ok {
+					file := &MessageFile{}
+					file.FileId, _ = subObj["file_id"].(string)
+					file.FileData, _ = subObj["file_data"].(string)
+					file.Filename, _ = subObj["filename"].(string)
+					contentList = append(contentList, MessageContent{
+						Type:         ContentTypeFile,
+						File:         file,
+						CacheControl: contentMap["cache_control"],
 					})
 				}
 			}
@@ -84,8 +110,30 @@ type ImageURL struct {
 	Detail string `json:"detail,omitempty"`
 }
 
+// MessageFile is a chat `file` content part. FileData is a base64 data URL.
+type MessageFile struct {
+	FileId   string `json:"file_id,omitempty"`
+	FileData string `json:"file_data,omitempty"`
+	Filename string `json:"filename,omitempty"`
+}
+
+// InlineData splits FileData into its media type and base64 payload.
+func (f *MessageFile) InlineData() (mediaType string, data string, ok bool) {
+	if f == nil || !strings.HasPrefix(f.FileData, "data:") {
+		return "", "", false
+	}
+	header, data, found := strings.Cut(strings.TrimPrefix(f.FileData, "data:"), ",")
+	if !found || !strings.HasSuffix(header, ";base64") {
+		return "", "", false
+	}
+	return strings.TrimSuffix(header, ";base64"), data, true
+}
+
 type MessageContent struct {
-	Type     string    `json:"type,omitempty"`
-	Text     string    `json:"text"`
-	ImageURL *ImageURL `json:"image_url,omitempty"`
+	Type     string       `json:"type,omitempty"`
+	Text     string       `json:"text"`
+	ImageURL *ImageURL    `json:"image_url,omitempty"`
+	File     *MessageFile `json:"file,omitempty"`
+	// CacheControl is only forwarded to Claude upstreams.
+	CacheControl any `json:"cache_control,omitempty"`
 }
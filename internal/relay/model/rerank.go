@@ -0,0 +1,136 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+type RerankRequest struct {
+	Model           string `json:"model"`
+	Query           string `json:"query"`
+	Documents       []any  `json:"documents"`
+	TopN            int    `json:"top_n,omitempty"`
+	ReturnDocuments *bool  `json:"return_documents,omitempty"`
+	MaxChunksPerDoc int    `json:"max_chunks_per_doc,omitempty"`
+}
+
+// DocumentTexts accepts both plain string documents and {"text": ...}
+// objects, the two shapes clients of Cohere and Jina style APIs send.
+func (r *RerankRequest) DocumentTexts() ([]string, error) {
+	texts := make([]string, 0, len(r.Documents))
+	for i, document := range r.Documents {
+		switch typed := document.(type) {
+		case string:
+			texts = append(texts, typed)
+		case map[string]any:
+			text, ok := typed["text"].(string)
+			if !ok {
+				return nil, fmt.Errorf("documents[%d].text must be a string", i)
+			}
+			texts = append(texts, text)
+		default:
+			return nil, fmt.Errorf("documents[%d] must be a string or an object with text", i)
+		}
+	}
+	return texts, nil
+}
+
+func (r *RerankRequest) Validate() error {
+	if strings.TrimSpace(r.Model) == "" {
+		return fmt.Errorf("model is required")
+	}
+	if strings.TrimSpace(r.Query) == "" {
+		return fmt.Errorf("query is required")
+	}
+	if len(r.Documents) == 0 {
+		return fmt.Errorf("documents must not be empty")
+	}
+	if r.TopN < 0 {
+		return fmt.Errorf("top_n must not be negative")
+	}
+	_, err := r.DocumentTexts()
+	return err
+}
+
+func (r *RerankRequest) ShouldReturnDocuments() bool {
+	return r.ReturnDocuments != nil && *r.ReturnDocuments
+}
+
+type RerankDocument struct {
+	Text string `json:"text"`
+}
+
+type RerankResult struct {
+	Index          int             `json:"index"`
+	RelevanceScore float64         `json:"relevance_score"`
+	Document       *RerankDocument `json:"document,omitempty"`
+}
+
+type RerankBilledUnits struct {
+	SearchUnits  int `json:"search_units,omitempty"`
+	InputTokens  int `json:"input_tokens,omitempty"`
+	OutputTokens int `json:"output_tokens,omitempty"`
+}
+
+type RerankMeta struct {
+	BilledUnits *RerankBilledUnits `json:"billed_units,omitempty"`
+	Tokens      *RerankBilledUnits `json:"tokens,omitempty"`
+}
+
+type RerankUsage struct {
+	PromptTokens int `json:"prompt_tokens,omitempty"`
+	TotalTokens  int `json:"total_tokens"`
+	SearchUnits  int `json:"search_units,omitempty"`
+}
+
+// RerankResponse is the Jina/Cohere compatible body returned by /v1/rerank.
+// Adaptors normalize their upstream answers into it.
+type RerankResponse struct {
+	ID      string         `json:"id,omitempty"`
+	Model   string         `json:"model,omitempty"`
+	Results []RerankResult `json:"results"`
+	Usage   *RerankUsage   `json:"usage,omitempty"`
+	Meta    *RerankMeta    `json:"meta,omitempty"`
+}
+
+// Normalize folds the usage fields the different upstreams report into
+// Usage and Meta.BilledUnits so downstream clients see both shapes.
+func (r *RerankResponse) Normalize() {
+	if r.Results == nil {
+		r.Results = []RerankResult{}
+	}
+	usage := RerankUsage{}
+	if r.Usage != nil {
+		usage = *r.Usage
+	}
+	if r.Meta != nil {
+		for _, units := range []*RerankBilledUnits{r.Meta.BilledUnits, r.Meta.Tokens} {
+			if units == nil {
+				continue
+			}
+			if usage.SearchUnits == 0 {
+				usage.SearchUnits = units.SearchUnits
+			}
+			if usage.TotalTokens == 0 {
+				usage.TotalTokens = units.InputTokens + units.OutputTokens
+			}
+		}
+	}
+	if usage.PromptTokens == 0 {
+		usage.PromptTokens = usage.TotalTokens
+	}
+	if usage.TotalTokens == 0 && usage.SearchUnits == 0 {
+		r.Usage = nil
+	} else {
+		r.Usage = &usage
+	}
+	if usage.SearchUnits > 0 {
+		if r.Meta == nil {
+			r.Meta = &RerankMeta{}
+		}
+		if r.Meta.BilledUnits == nil {
+			r.Meta.BilledUnits = &RerankBilledUnits{}
+		}
+		r.Meta.BilledUnits.SearchUnits = usage.SearchUnits
+	}
+}
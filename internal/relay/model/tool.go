@@ -4,6 +4,8 @@ type Tool struct {
 	Id       string   `json:"id,omitempty"`
 	Type     string   `json:"type,omitempty"` // when splicing claude tools stream messages, it is empty
 	Function Function `json:"function"`
+	// CacheControl is only forwarded to Claude upstreams.
+	CacheControl any `json:"cache_control,omitempty"`
 }
 
 type Function struct {
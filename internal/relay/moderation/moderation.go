@@ -0,0 +1,224 @@
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yeying-community/router/common/client"
+	"github.com/yeying-community/router/common/config"
+	adminmodel "github.com/yeying-community/router/internal/admin/model"
+	"github.com/yeying-community/router/internal/relay/adaptor/openai"
+)
+
+const (
+	SourceKeyword = "keyword"
+	SourcePattern = "pattern"
+	SourceModel   = "model"
+	SourceError   = "error"
+
+	moderationsPath = "/v1/moderations"
+)
+
+// Decision is the outcome of screening one request. Action is one of the
+// adminmodel.ModerationAction* values or adminmodel.ModerationDecisionPass.
+type Decision struct {
+	Action string
+	Source string
+	Reason string
+}
+
+func (d Decision) Blocked() bool {
+	return d.Action == adminmodel.ModerationActionBlock
+}
+
+// Violation reports whether the request counts against the user.
+func (d Decision) Violation() bool {
+	return (d.Action == adminmodel.ModerationActionBlock || d.Action == adminmodel.ModerationActionFlag) && d.Source != SourceError
+}
+
+type modelResult struct {
+	Flagged    bool            `json:"flagged"`
+	Categories map[string]bool `json:"categories"`
+}
+
+type modelResponse struct {
+	Results []modelResult `json:"results"`
+}
+
+// checkWithModelFunc is replaced in tests.
+var checkWithModelFunc = checkWithModel
+
+// Screen runs text through the local keyword and pattern lists first and then
+// through the policy's moderation model, if any.
+func Screen(ctx context.Context, policy adminmodel.ModerationPolicy, group string, text string) Decision {
+	if strings.TrimSpace(text) == "" {
+		return Decision{Action: adminmodel.ModerationDecisionPass}
+	}
+	lowered := strings.ToLower(text)
+	for _, keyword := range policy.KeywordList() {
+		if strings.Contains(lowered, strings.ToLower(keyword)) {
+			return Decision{Action: policy.Action, Source: SourceKeyword, Reason: keyword}
+		}
+	}
+	for _, pattern := range policy.PatternList() {
+		compiled, err := compilePattern(pattern)
+		if err != nil {
+			continue
+		}
+		if compiled.MatchString(text) {
+			return Decision{Action: policy.Action, Source: SourcePattern, Reason: pattern}
+		}
+	}
+	if policy.Model == "" {
+		return Decision{Action: adminmodel.ModerationDecisionPass}
+	}
+	flagged, categories, err := checkWithModelFunc(ctx, policy, group, text)
+	if err != nil {
+		action := adminmodel.ModerationDecisionPass
+		if !config.ModerationFailOpen {
+			action = adminmodel.ModerationActionBlock
+		}
+		return Decision{Action: action, Source: SourceError, Reason: err.Error()}
+	}
+	if !flagged {
+		return Decision{Action: adminmodel.ModerationDecisionPass, Source: SourceModel}
+	}
+	return Decision{Action: policy.Action, Source: SourceModel, Reason: strings.Join(categories, ",")}
+}
+
+var compiledPatterns sync.Map
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if cached, ok := compiledPatterns.Load(pattern); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	compiledPatterns.Store(pattern, compiled)
+	return compiled, nil
+}
+
+func checkWithModel(ctx context.Context, policy adminmodel.ModerationPolicy, group string, text string) (bool, []string, error) {
+	channel, err := resolveModerationChannel(policy, group)
+	if err != nil {
+		return false, nil, err
+	}
+	upstreamModel := policy.Model
+	if mapped, ok := channel.GetModelMapping()[policy.Model]; ok && mapped != "" {
+		upstreamModel = mapped
+	}
+	payload, err := json.Marshal(map[string]any{"model": upstreamModel, "input": text})
+	if err != nil {
+		return false, nil, err
+	}
+	timeout := time.Duration(config.ModerationTimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	baseURL := channel.ResolveAPIBaseURLForModel(moderationsPath, policy.Model)
+	requestURL := openai.GetFullRequestURL(baseURL, moderationsPath, channel.GetChannelProtocol())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, requestURL, bytes.NewReader(payload))
+	if err != nil {
+		return false, nil, err
+	}
+	cfg, _ := channel.LoadConfig()
+	keyID, key := adminmodel.SelectChannelKey(channel, cfg.KeyStrategy)
+	defer adminmodel.BeginChannelKeyRequest(keyID)()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+key)
+	httpClient := client.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			recordModerationKeyOutcome(keyID, 0)
+		}
+		return false, nil, err
+	}
+	defer resp.Body.Close()
+	recordModerationKeyOutcome(keyID, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, nil, fmt.Errorf("moderation model returned status %d", resp.StatusCode)
+	}
+	return parseModelResponse(body)
+}
+
+// recordModerationKeyOutcome feeds the pool key the way a relay attempt does:
+// rejected credentials, rate limits, upstream failures and transport errors
+// count against it, other 4xx do not.
+func recordModerationKeyOutcome(keyID string, statusCode int) {
+	switch {
+	case statusCode == http.StatusOK:
+		adminmodel.RecordChannelKeyOutcome(keyID, true)
+	case statusCode == http.StatusUnauthorized,
+		statusCode == http.StatusForbidden,
+		statusCode == http.StatusTooManyRequests,
+		statusCode == 0,
+		statusCode >= http.StatusInternalServerError:
+		adminmodel.RecordChannelKeyOutcome(keyID, false)
+	}
+}
+
+func resolveModerationChannel(policy adminmodel.ModerationPolicy, group string) (*adminmodel.Channel, error) {
+	if policy.ChannelID != "" {
+		channel, err := adminmodel.CacheGetChannelByID(policy.ChannelID)
+		if err != nil {
+			return nil, fmt.Errorf("moderation channel %s not found", policy.ChannelID)
+		}
+		return channel, nil
+	}
+	channel, err := adminmodel.CacheGetRandomSatisfiedChannelForRequest(group, policy.Model, moderationsPath, false)
+	if err != nil {
+		return nil, fmt.Errorf("no channel available for moderation model %s: %w", policy.Model, err)
+	}
+	return channel, nil
+}
+
+func parseModelResponse(body []byte) (bool, []string, error) {
+	response := modelResponse{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return false, nil, fmt.Errorf("invalid moderation response: %w", err)
+	}
+	if len(response.Results) == 0 {
+		return false, nil, fmt.Errorf("moderation response has no results")
+	}
+	flagged := false
+	categorySet := map[string]struct{}{}
+	for _, result := range response.Results {
+		if !result.Flagged {
+			continue
+		}
+		flagged = true
+		for category, hit := range result.Categories {
+			if hit {
+				categorySet[category] = struct{}{}
+			}
+		}
+	}
+	categories := make([]string, 0, len(categorySet))
+	for category := range categorySet {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	return flagged, categories, nil
+}
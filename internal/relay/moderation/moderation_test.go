@@ -0,0 +1,136 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/yeying-community/router/common/config"
+	adminmodel "github.com/yeying-community/router/internal/admin/model"
+)
+
+func TestExtractTextCollectsPromptFields(t *testing.T) {
+	body := []byte(`{
+		"model": "gpt-4o",
+		"system": "be brief",
+		"messages": [
+			{"role": "user", "content": [
+				{"type": "text", "text": "hello there"},
+				{"type": "image_url", "image_url": {"url": "data:image/png;base64,AAAA"}}
+			]},
+			{"role": "assistant", "content": "hi"}
+		],
+		"tools": [{"type": "function", "function": {"description": "ignored"}}]
+	}`)
+	if got, want := ExtractText(body), "be brief\nhello there\nhi"; got != want {
+		t.Fatalf("ExtractText = %q, want %q", got, want)
+	}
+	if got := ExtractText([]byte("not json")); got != "" {
+		t.Fatalf("ExtractText(non-json) = %q, want empty", got)
+	}
+}
+
+func TestScreenLocalLists(t *testing.T) {
+	policy := adminmodel.ModerationPolicy{
+		Action:   adminmodel.ModerationActionBlock,
+		Keywords: "Forbidden Word",
+		Patterns: `\b\d{4}-\d{4}-\d{4}-\d{4}\b`,
+	}
+	cases := []struct {
+		text      string
+		want      Decision
+		violation bool
+	}{
+		{"say the forbidden word", Decision{Action: "block", Source: SourceKeyword, Reason: "Forbidden Word"}, true},
+		{"card 1234-5678-9012-3456", Decision{Action: "block", Source: SourcePattern, Reason: policy.Patterns}, true},
+		{"nothing to see", Decision{Action: adminmodel.ModerationDecisionPass}, false},
+	}
+	for _, tc := range cases {
+		got := Screen(context.Background(), policy, "default", tc.text)
+		if got != tc.want || got.Violation() != tc.violation {
+			t.Fatalf("Screen(%q) = %#v, want %#v", tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestScreenWithModel(t *testing.T) {
+	previousCheck, previousFailOpen := checkWithModelFunc, config.ModerationFailOpen
+	t.Cleanup(func() {
+		checkWithModelFunc, config.ModerationFailOpen = previousCheck, previousFailOpen
+	})
+	policy := adminmodel.ModerationPolicy{Action: adminmodel.ModerationActionFlag, Model: "omni-moderation-latest"}
+
+	checkWithModelFunc = func(ctx context.Context, policy adminmodel.ModerationPolicy, group string, text string) (bool, []string, error) {
+		return text == "bad", []string{"harassment", "violence"}, nil
+	}
+	if got := Screen(context.Background(), policy, "default", "bad"); got.Action != "flag" || got.Reason != "harassment,violence" || got.Blocked() {
+		t.Fatalf("flagged decision = %#v", got)
+	}
+	if got := Screen(context.Background(), policy, "default", "fine"); got.Action != adminmodel.ModerationDecisionPass || got.Source != SourceModel {
+		t.Fatalf("clean decision = %#v", got)
+	}
+
+	checkWithModelFunc = func(ctx context.Context, policy adminmodel.ModerationPolicy, group string, text string) (bool, []string, error) {
+		return false, nil, errors.New("upstream down")
+	}
+	config.ModerationFailOpen = true
+	if got := Screen(context.Background(), policy, "default", "x"); got.Action != adminmodel.ModerationDecisionPass || got.Source != SourceError {
+		t.Fatalf("fail-open decision = %#v", got)
+	}
+	config.ModerationFailOpen = false
+	if got := Screen(context.Background(), policy, "default", "x"); !got.Blocked() || got.Violation() {
+		t.Fatalf("fail-closed decision = %#v, want blocked without counting a violation", got)
+	}
+}
+
+func TestParseModelResponse(t *testing.T) {
+	flagged, categories, err := parseModelResponse([]byte(`{"results":[{"flagged":true,"categories":{"hate":true,"sexual":false}}]}`))
+	if err != nil || !flagged || len(categories) != 1 || categories[0] != "hate" {
+		t.Fatalf("parseModelResponse = %t %v %v", flagged, categories, err)
+	}
+	if _, _, err := parseModelResponse([]byte(`{"results":[]}`)); err == nil {
+		t.Fatalf("parseModelResponse(empty) should fail")
+	}
+}
+
+func TestRecordModerationKeyOutcomeFeedsKeyBreaker(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=private"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&adminmodel.ChannelKey{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := db.Create(&adminmodel.ChannelKey{Id: "key-1", ChannelID: "channel-1", Key: "sk-pool", Status: adminmodel.ChannelKeyStatusEnabled}).Error; err != nil {
+		t.Fatalf("create key: %v", err)
+	}
+	if err := adminmodel.SyncChannelKeysRuntimeWithDB(db); err != nil {
+		t.Fatalf("sync keys: %v", err)
+	}
+	t.Cleanup(func() {
+		empty, _ := gorm.Open(sqlite.Open("file:"+t.Name()+"-empty?mode=memory&cache=private"), &gorm.Config{})
+		_ = empty.AutoMigrate(&adminmodel.ChannelKey{})
+		_ = adminmodel.SyncChannelKeysRuntimeWithDB(empty)
+	})
+	channel := &adminmodel.Channel{Id: "channel-1", Key: "sk-channel"}
+
+	if keyID, key := adminmodel.SelectChannelKey(channel, ""); keyID != "key-1" || key != "sk-pool" {
+		t.Fatalf("SelectChannelKey() = %q, %q; want the pool key", keyID, key)
+	}
+	for i := 0; i < 5; i++ {
+		recordModerationKeyOutcome("key-1", http.StatusBadRequest)
+	}
+	if keyID, _ := adminmodel.SelectChannelKey(channel, ""); keyID != "key-1" {
+		t.Fatalf("client errors opened the key breaker, got %q", keyID)
+	}
+	for i := 0; i < 3; i++ {
+		recordModerationKeyOutcome("key-1", http.StatusUnauthorized)
+	}
+	if keyID, key := adminmodel.SelectChannelKey(channel, ""); keyID != "" || key != "sk-channel" {
+		t.Fatalf("SelectChannelKey() = %q, %q; want the channel key once the pool key failed", keyID, key)
+	}
+}
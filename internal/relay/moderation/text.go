@@ -0,0 +1,77 @@
+package moderation
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// promptFields are the top-level request fields that carry user content
+// across the OpenAI, Claude, Gemini and rerank request shapes.
+var promptFields = []string{"system", "instructions", "messages", "contents", "prompt", "input", "query", "documents"}
+
+// skippedFields never hold prompt text, or hold payloads such as base64
+// media that would only produce noise for keyword and model checks.
+var skippedFields = map[string]struct{}{
+	"role":          {},
+	"type":          {},
+	"id":            {},
+	"name":          {},
+	"model":         {},
+	"tool_call_id":  {},
+	"call_id":       {},
+	"file_id":       {},
+	"image_url":     {},
+	"input_image":   {},
+	"input_audio":   {},
+	"audio":         {},
+	"url":           {},
+	"data":          {},
+	"inline_data":   {},
+	"file_data":     {},
+	"mime_type":     {},
+	"detail":        {},
+	"signature":     {},
+	"cache_control": {},
+}
+
+// ExtractText returns the prompt text of a JSON relay request, one fragment
+// per line. Bodies that are not JSON objects yield an empty string.
+func ExtractText(body []byte) string {
+	payload := map[string]any{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return ""
+	}
+	fragments := make([]string, 0)
+	for _, field := range promptFields {
+		if value, ok := payload[field]; ok {
+			fragments = collectText(value, fragments)
+		}
+	}
+	return strings.Join(fragments, "\n")
+}
+
+func collectText(value any, fragments []string) []string {
+	switch typed := value.(type) {
+	case string:
+		if text := strings.TrimSpace(typed); text != "" && !strings.HasPrefix(text, "data:") {
+			fragments = append(fragments, text)
+		}
+	case []any:
+		for _, item := range typed {
+			fragments = collectText(item, fragments)
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(typed))
+		for key := range typed {
+			if _, skip := skippedFields[key]; !skip {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fragments = collectText(typed[key], fragments)
+		}
+	}
+	return fragments
+}
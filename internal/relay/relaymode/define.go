@@ -18,4 +18,12 @@ const (
 	Videos
 	Messages
 	Realtime
+	// Gemini* are native Gemini ingress dialects. They only exist at the edge:
+	// requests are relayed as ChatCompletions or Embeddings.
+	GeminiGenerateContent
+	GeminiCountTokens
+	GeminiEmbedContent
+	GeminiBatchEmbedContents
+	Rerank
+	ImagesVariations
 )
@@ -20,6 +20,8 @@ func GetByPath(path string) int {
 		relayMode = Responses
 	} else if strings.HasPrefix(path, "/v1/completions") {
 		relayMode = Completions
+	} else if strings.HasPrefix(path, "/v1/rerank") {
+		relayMode = Rerank
 	} else if strings.HasPrefix(path, "/v1/embeddings") {
 		relayMode = Embeddings
 	} else if strings.HasSuffix(path, "embeddings") {
@@ -30,6 +32,8 @@ func GetByPath(path string) int {
 		relayMode = ImagesGenerations
 	} else if strings.HasPrefix(path, "/v1/images/edits") {
 		relayMode = ImagesEdits
+	} else if strings.HasPrefix(path, "/v1/images/variations") {
+		relayMode = ImagesVariations
 	} else if strings.HasPrefix(path, "/v1/edits") {
 		relayMode = Edits
 	} else if strings.HasPrefix(path, "/v1/audio/speech") {
@@ -41,6 +41,15 @@ func TestGetByPath_ImagesEdits(t *testing.T) {
 	}
 }
 
+func TestGetByPath_ImagesVariations(t *testing.T) {
+	if got := GetByPath("/v1/images/variations"); got != ImagesVariations {
+		t.Fatalf("GetByPath(/v1/images/variations)=%d, want %d", got, ImagesVariations)
+	}
+	if got := GetByPath("/api/v1/public/images/variations"); got != ImagesVariations {
+		t.Fatalf("GetByPath(/api/v1/public/images/variations)=%d, want %d", got, ImagesVariations)
+	}
+}
+
 func TestGetByPath_Audio(t *testing.T) {
 	tests := []struct {
 		path string
@@ -76,3 +85,12 @@ func TestGetByPath_Realtime(t *testing.T) {
 		}
 	}
 }
+
+func TestGetByPath_Rerank(t *testing.T) {
+	if got := GetByPath("/v1/rerank"); got != Rerank {
+		t.Fatalf("GetByPath(/v1/rerank)=%d, want %d", got, Rerank)
+	}
+	if got := GetByPath("/api/v1/public/rerank"); got != Rerank {
+		t.Fatalf("GetByPath(/api/v1/public/rerank)=%d, want %d", got, Rerank)
+	}
+}
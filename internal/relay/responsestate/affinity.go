@@ -0,0 +1,139 @@
+package responsestate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/yeying-community/router/common/config"
+	"github.com/yeying-community/router/common/logger"
+)
+
+const sessionRouteKeyPrefix = "session_affinity:"
+
+// Session affinity key sources, in the order they are tried.
+const (
+	SessionKeySourceHeader = "header"
+	SessionKeySourceUser   = "user"
+	SessionKeySourcePrompt = "prompt"
+)
+
+// DeriveSessionKey returns the affinity key of a request together with the
+// source it was derived from. scope keeps keys of different callers, groups
+// and models apart; an empty key means the request has nothing to pin on.
+func DeriveSessionKey(scope string, headerValue string, raw []byte) (string, string) {
+	source, value := SessionKeySourceHeader, strings.TrimSpace(headerValue)
+	if value == "" {
+		source, value = sessionKeyFromBody(raw)
+	}
+	if value == "" {
+		return "", ""
+	}
+	sum := sha256.Sum256([]byte(strings.TrimSpace(scope) + "|" + source + ":" + value))
+	return hex.EncodeToString(sum[:]), source
+}
+
+// sessionKeyFromBody uses the request's user field, or else a fingerprint of
+// the conversation prefix: the system prompt plus the leading messages up to
+// the first non-system one, which stay identical across turns.
+func sessionKeyFromBody(raw []byte) (string, string) {
+	if len(raw) == 0 {
+		return "", ""
+	}
+	payload := map[string]any{}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return "", ""
+	}
+	if user := strings.TrimSpace(asString(payload["user"])); user != "" {
+		return SessionKeySourceUser, user
+	}
+	prefix := make([]any, 0, 4)
+	for _, key := range []string{"system", "instructions", "systemInstruction", "system_instruction"} {
+		if value, ok := payload[key]; ok && value != nil {
+			prefix = append(prefix, value)
+		}
+	}
+	for _, key := range []string{"messages", "input", "contents"} {
+		switch items := payload[key].(type) {
+		case string:
+			if strings.TrimSpace(items) != "" {
+				prefix = append(prefix, items)
+			}
+		case []any:
+			prefix = append(prefix, leadingConversationItems(items)...)
+		}
+	}
+	if len(prefix) == 0 {
+		return "", ""
+	}
+	encoded, err := json.Marshal(prefix)
+	if err != nil {
+		return "", ""
+	}
+	return SessionKeySourcePrompt, string(encoded)
+}
+
+func leadingConversationItems(items []any) []any {
+	result := make([]any, 0, 2)
+	for _, item := range items {
+		result = append(result, item)
+		if object, ok := item.(map[string]any); ok {
+			role := strings.ToLower(strings.TrimSpace(asString(object["role"])))
+			if role == "system" || role == "developer" {
+				continue
+			}
+		}
+		break
+	}
+	return result
+}
+
+// StoreSessionRoute pins a session to the channel that last served it.
+func StoreSessionRoute(sessionKey string, channelID string) {
+	normalizedSessionKey := strings.TrimSpace(sessionKey)
+	if normalizedSessionKey == "" {
+		return
+	}
+	storeRouteEntry(sessionRouteKey(normalizedSessionKey), sessionRouteKey(normalizedSessionKey), channelID, sessionRouteTTL(), "session route")
+}
+
+func LookupSessionRoute(sessionKey string) (string, bool) {
+	normalizedSessionKey := strings.TrimSpace(sessionKey)
+	if normalizedSessionKey == "" {
+		return "", false
+	}
+	return lookupRouteEntry(sessionRouteKey(normalizedSessionKey), sessionRouteKey(normalizedSessionKey), "session route")
+}
+
+// DeleteSessionRoute drops a pin whose channel can no longer serve the session.
+func DeleteSessionRoute(sessionKey string) {
+	normalizedSessionKey := strings.TrimSpace(sessionKey)
+	if normalizedSessionKey == "" {
+		return
+	}
+	key := sessionRouteKey(normalizedSessionKey)
+	if redisRouteEnabledFunc() {
+		if err := redisDelFunc(key); err != nil && err != redis.Nil {
+			logger.SysError("Redis delete session route error: " + err.Error())
+		}
+		return
+	}
+	routeMu.Lock()
+	delete(routeStore, key)
+	routeMu.Unlock()
+}
+
+func sessionRouteTTL() time.Duration {
+	seconds := config.SessionAffinityTTLSeconds
+	if seconds <= 0 {
+		seconds = 3600
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func sessionRouteKey(sessionKey string) string {
+	return sessionRouteKeyPrefix + sessionKey
+}
@@ -0,0 +1,96 @@
+package responsestate
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeriveSessionKeyPrefersHeaderThenUserThenPrompt(t *testing.T) {
+	firstTurn := []byte(`{"model":"gpt-5","messages":[{"role":"system","content":"You are terse."},{"role":"user","content":"hi"}]}`)
+	secondTurn := []byte(`{"model":"gpt-5","messages":[{"role":"system","content":"You are terse."},{"role":"user","content":"hi"},{"role":"assistant","content":"hello"},{"role":"user","content":"more"}]}`)
+
+	headerKey, source := DeriveSessionKey("user-1|default|gpt-5", " conv-1 ", firstTurn)
+	if headerKey == "" || source != SessionKeySourceHeader {
+		t.Fatalf("header key = (%q, %q), want header source", headerKey, source)
+	}
+	userKey, source := DeriveSessionKey("user-1|default|gpt-5", "", []byte(`{"user":"end-user-7","messages":[]}`))
+	if userKey == "" || source != SessionKeySourceUser {
+		t.Fatalf("user key = (%q, %q), want user source", userKey, source)
+	}
+	firstKey, source := DeriveSessionKey("user-1|default|gpt-5", "", firstTurn)
+	if source != SessionKeySourcePrompt {
+		t.Fatalf("prompt source = %q, want prompt", source)
+	}
+	secondKey, _ := DeriveSessionKey("user-1|default|gpt-5", "", secondTurn)
+	if firstKey == "" || firstKey != secondKey {
+		t.Fatalf("prompt keys differ across turns: %q vs %q", firstKey, secondKey)
+	}
+	otherScopeKey, _ := DeriveSessionKey("user-2|default|gpt-5", "", firstTurn)
+	if otherScopeKey == firstKey {
+		t.Fatal("prompt key is shared across scopes")
+	}
+	if key, _ := DeriveSessionKey("user-1|default|gpt-5", "", []byte(`{"model":"gpt-5"}`)); key != "" {
+		t.Fatalf("key without session hint = %q, want empty", key)
+	}
+}
+
+func TestSessionRouteStoreLookupAndDelete(t *testing.T) {
+	ResetForTest()
+	defer ResetForTest()
+
+	now := time.Unix(100, 0)
+	routeNow = func() time.Time { return now }
+
+	StoreSessionRoute("session-1", "channel-1")
+	StoreRoute("session-1", "channel-2")
+	if channelID, ok := LookupSessionRoute("session-1"); !ok || channelID != "channel-1" {
+		t.Fatalf("LookupSessionRoute = (%q, %v), want channel-1", channelID, ok)
+	}
+	DeleteSessionRoute("session-1")
+	if channelID, ok := LookupSessionRoute("session-1"); ok {
+		t.Fatalf("LookupSessionRoute after delete = %q, want miss", channelID)
+	}
+	if channelID, ok := LookupRoute("session-1"); !ok || channelID != "channel-2" {
+		t.Fatalf("responses route = (%q, %v), want channel-2 untouched", channelID, ok)
+	}
+
+	StoreSessionRoute("session-2", "channel-1")
+	now = now.Add(sessionRouteTTL() + time.Second)
+	if channelID, ok := LookupSessionRoute("session-2"); ok {
+		t.Fatalf("LookupSessionRoute = %q, want expired", channelID)
+	}
+}
+
+func TestSessionRouteUsesRedisWhenEnabled(t *testing.T) {
+	ResetForTest()
+	defer ResetForTest()
+
+	redisStore := map[string]string{}
+	redisRouteEnabledFunc = func() bool { return true }
+	redisSetRouteFunc = func(key string, value string, expiration time.Duration) error {
+		if key != "session_affinity:session-redis" {
+			t.Fatalf("redis key = %q, want session_affinity:session-redis", key)
+		}
+		if expiration != sessionRouteTTL() {
+			t.Fatalf("redis expiration = %v, want %v", expiration, sessionRouteTTL())
+		}
+		redisStore[key] = value
+		return nil
+	}
+	redisGetRouteFunc = func(key string) (string, error) {
+		return redisStore[key], nil
+	}
+	redisDelFunc = func(key string) error {
+		delete(redisStore, key)
+		return nil
+	}
+
+	StoreSessionRoute("session-redis", "channel-9")
+	if channelID, ok := LookupSessionRoute("session-redis"); !ok || channelID != "channel-9" {
+		t.Fatalf("LookupSessionRoute = (%q, %v), want channel-9", channelID, ok)
+	}
+	DeleteSessionRoute("session-redis")
+	if _, ok := redisStore["session_affinity:session-redis"]; ok {
+		t.Fatal("redis session route was not deleted")
+	}
+}
@@ -0,0 +1,97 @@
+package responsestate
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxRecordedBodyBytes = 32 << 20
+
+// Recorder tees the downstream Responses API body so the final response object
+// can be stored once the relay finishes. For streams it keeps the response
+// carried by the terminal response.* event.
+type Recorder struct {
+	gin.ResponseWriter
+	stream   bool
+	body     bytes.Buffer
+	pending  []byte
+	response []byte
+}
+
+func NewRecorder(w gin.ResponseWriter, stream bool) *Recorder {
+	return &Recorder{ResponseWriter: w, stream: stream}
+}
+
+func (r *Recorder) Write(data []byte) (int, error) {
+	r.capture(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *Recorder) WriteString(s string) (int, error) {
+	r.capture([]byte(s))
+	return r.ResponseWriter.WriteString(s)
+}
+
+func (r *Recorder) capture(data []byte) {
+	if !r.stream {
+		if r.body.Len()+len(data) <= maxRecordedBodyBytes {
+			r.body.Write(data)
+		}
+		return
+	}
+	r.pending = append(r.pending, data...)
+	for {
+		index := bytes.IndexByte(r.pending, '\n')
+		if index < 0 {
+			break
+		}
+		r.captureLine(r.pending[:index])
+		r.pending = r.pending[index+1:]
+	}
+	if len(r.pending) > maxRecordedBodyBytes {
+		r.pending = nil
+	}
+}
+
+func (r *Recorder) captureLine(line []byte) {
+	trimmed := strings.TrimSpace(string(line))
+	if !strings.HasPrefix(trimmed, "data:") {
+		return
+	}
+	payload := strings.TrimSpace(strings.TrimPrefix(trimmed, "data:"))
+	if payload == "" || payload == "[DONE]" {
+		return
+	}
+	event := struct {
+		Type     string          `json:"type"`
+		Response json.RawMessage `json:"response"`
+	}{}
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		return
+	}
+	switch event.Type {
+	case "response.completed", "response.incomplete", "response.failed":
+		if len(event.Response) > 0 {
+			r.response = append([]byte(nil), event.Response...)
+		}
+	}
+}
+
+// Response returns the final response object written downstream, or nil when
+// none was observed.
+func (r *Recorder) Response() []byte {
+	if r.stream {
+		if len(r.pending) > 0 {
+			r.captureLine(r.pending)
+			r.pending = nil
+		}
+		return r.response
+	}
+	if r.body.Len() == 0 {
+		return nil
+	}
+	return r.body.Bytes()
+}
@@ -153,13 +153,22 @@ func appendUnique(values []string, value string) []string {
 
 func StoreRoute(responseID string, channelID string) {
 	normalizedResponseID := strings.TrimSpace(responseID)
+	if normalizedResponseID == "" {
+		return
+	}
+	storeRouteEntry(normalizedResponseID, responseRouteKey(normalizedResponseID), channelID, routeTTL, "responses route")
+}
+
+// storeRouteEntry writes one channel binding to Redis when available and to
+// the in-memory store otherwise.
+func storeRouteEntry(memoryKey string, redisKey string, channelID string, ttl time.Duration, kind string) {
 	normalizedChannelID := strings.TrimSpace(channelID)
-	if normalizedResponseID == "" || normalizedChannelID == "" {
+	if memoryKey == "" || normalizedChannelID == "" {
 		return
 	}
 	if redisRouteEnabledFunc() {
-		if err := redisSetRouteFunc(responseRouteKey(normalizedResponseID), normalizedChannelID, routeTTL); err != nil {
-			logger.SysError("Redis set responses route error: " + err.Error())
+		if err := redisSetRouteFunc(redisKey, normalizedChannelID, ttl); err != nil {
+			logger.SysError("Redis set " + kind + " error: " + err.Error())
 		}
 		return
 	}
@@ -170,9 +179,9 @@ func StoreRoute(responseID string, channelID string) {
 		pruneExpiredLocked(now)
 		lastPrunedAt = now
 	}
-	routeStore[normalizedResponseID] = routeEntry{
+	routeStore[memoryKey] = routeEntry{
 		ChannelID: normalizedChannelID,
-		ExpireAt:  now.Add(routeTTL),
+		ExpireAt:  now.Add(ttl),
 	}
 }
 
@@ -187,11 +196,15 @@ func LookupRoute(responseID string) (string, bool) {
 	if normalizedResponseID == "" {
 		return "", false
 	}
+	return lookupRouteEntry(normalizedResponseID, responseRouteKey(normalizedResponseID), "responses route")
+}
+
+func lookupRouteEntry(memoryKey string, redisKey string, kind string) (string, bool) {
 	if redisRouteEnabledFunc() {
-		channelID, err := redisGetRouteFunc(responseRouteKey(normalizedResponseID))
+		channelID, err := redisGetRouteFunc(redisKey)
 		if err != nil {
 			if err != redis.Nil {
-				logger.SysError("Redis get responses route error: " + err.Error())
+				logger.SysError("Redis get " + kind + " error: " + err.Error())
 			}
 			return "", false
 		}
@@ -201,7 +214,7 @@ func LookupRoute(responseID string) (string, bool) {
 		}
 		return channelID, true
 	}
-	return lookupMemoryRoute(normalizedResponseID)
+	return lookupMemoryRoute(memoryKey)
 }
 
 func LookupRoutes(responseIDs []string) (string, bool, bool) {
@@ -254,6 +267,10 @@ func ResetForTest() {
 	}
 	redisSetRouteFunc = common.RedisSet
 	redisGetRouteFunc = common.RedisGet
+	redisDelFunc = common.RedisDel
+	saveStateFunc = saveStateToDB
+	loadStateFunc = loadStateFromDB
+	deleteStateFunc = deleteStateFromDB
 }
 
 func responseRouteKey(responseID string) string {
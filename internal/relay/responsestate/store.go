@@ -0,0 +1,465 @@
+package responsestate
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+
+	"github.com/yeying-community/router/common"
+	"github.com/yeying-community/router/common/config"
+	"github.com/yeying-community/router/common/graceful"
+	"github.com/yeying-community/router/common/heartbeat"
+	"github.com/yeying-community/router/common/helper"
+	"github.com/yeying-community/router/common/logger"
+	"github.com/yeying-community/router/common/random"
+	"github.com/yeying-community/router/internal/admin/model"
+)
+
+const (
+	responseStateKeyPrefix = "responses_state:"
+	responseStateCacheTTL  = time.Hour
+	maxReplayChainLength   = 256
+	statePruneInterval     = 30 * time.Minute
+	statePruneBatchSize    = 500
+)
+
+var ErrStateNotFound = errors.New("response state not found")
+
+// Record is one stored Responses API turn. InputItems holds only the items sent
+// with that request; the full conversation is rebuilt by walking
+// PreviousResponseID.
+type Record struct {
+	ID                 string            `json:"id"`
+	UserID             string            `json:"user_id"`
+	TokenID            string            `json:"token_id,omitempty"`
+	ChannelID          string            `json:"channel_id,omitempty"`
+	Model              string            `json:"model,omitempty"`
+	PreviousResponseID string            `json:"previous_response_id,omitempty"`
+	Status             string            `json:"status,omitempty"`
+	InputItems         []json.RawMessage `json:"input_items"`
+	OutputItems        []json.RawMessage `json:"output_items"`
+	Response           json.RawMessage   `json:"response"`
+	CreatedAt          int64             `json:"created_at"`
+}
+
+var (
+	saveStateFunc   = saveStateToDB
+	loadStateFunc   = loadStateFromDB
+	deleteStateFunc = deleteStateFromDB
+	redisDelFunc    = common.RedisDel
+
+	startStatePruneWorkerOnce sync.Once
+)
+
+func StoreEnabled() bool {
+	return config.ResponsesStateStoreEnabled
+}
+
+func stateTTL() time.Duration {
+	hours := config.ResponsesStateTTLHours
+	if hours <= 0 {
+		hours = 720
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+func stateExpiresAt(createdAt int64) int64 {
+	return time.Unix(createdAt, 0).Add(stateTTL()).Unix()
+}
+
+// stateCacheTTL keeps the Redis copy from outliving the stored record.
+func stateCacheTTL(record Record) time.Duration {
+	remaining := time.Duration(stateExpiresAt(record.CreatedAt)-helper.GetTimestamp()) * time.Second
+	if remaining < responseStateCacheTTL {
+		return remaining
+	}
+	return responseStateCacheTTL
+}
+
+// NewRecord builds a record from the downstream request body and the response
+// object returned to the client. It reports false when the request opted out
+// of storage or the response carries no id.
+func NewRecord(requestBody []byte, responseBody []byte) (Record, bool) {
+	request := map[string]any{}
+	if err := json.Unmarshal(requestBody, &request); err != nil {
+		return Record{}, false
+	}
+	if store, ok := request["store"].(bool); ok && !store {
+		return Record{}, false
+	}
+	response := map[string]any{}
+	if err := json.Unmarshal(responseBody, &response); err != nil {
+		return Record{}, false
+	}
+	responseID := strings.TrimSpace(asString(response["id"]))
+	if responseID == "" {
+		return Record{}, false
+	}
+	record := Record{
+		ID:                 responseID,
+		Model:              strings.TrimSpace(asString(response["model"])),
+		PreviousResponseID: strings.TrimSpace(asString(request["previous_response_id"])),
+		Status:             strings.TrimSpace(asString(response["status"])),
+		InputItems:         normalizeInputItems(request["input"]),
+		Response:           json.RawMessage(responseBody),
+		CreatedAt:          helper.GetTimestamp(),
+	}
+	if output, ok := response["output"].([]any); ok {
+		record.OutputItems = marshalItems(output)
+	}
+	return record, true
+}
+
+func normalizeInputItems(input any) []json.RawMessage {
+	switch typed := input.(type) {
+	case string:
+		if typed == "" {
+			return nil
+		}
+		return marshalItems([]any{map[string]any{
+			"id":      "msg_" + random.GetUUID(),
+			"type":    "message",
+			"role":    "user",
+			"content": []any{map[string]any{"type": "input_text", "text": typed}},
+		}})
+	case []any:
+		items := make([]any, 0, len(typed))
+		for _, item := range typed {
+			object, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			if strings.TrimSpace(asString(object["type"])) == "" {
+				object["type"] = "message"
+			}
+			if strings.TrimSpace(asString(object["id"])) == "" {
+				object["id"] = inputItemIDPrefix(asString(object["type"])) + random.GetUUID()
+			}
+			items = append(items, object)
+		}
+		return marshalItems(items)
+	default:
+		return nil
+	}
+}
+
+func inputItemIDPrefix(itemType string) string {
+	switch itemType {
+	case "function_call":
+		return "fc_"
+	case "function_call_output":
+		return "fco_"
+	default:
+		return "msg_"
+	}
+}
+
+func marshalItems(items []any) []json.RawMessage {
+	result := make([]json.RawMessage, 0, len(items))
+	for _, item := range items {
+		encoded, err := json.Marshal(item)
+		if err != nil {
+			continue
+		}
+		result = append(result, encoded)
+	}
+	return result
+}
+
+func Save(record Record) error {
+	if !StoreEnabled() {
+		return nil
+	}
+	record.ID = strings.TrimSpace(record.ID)
+	if record.ID == "" {
+		return nil
+	}
+	if err := saveStateFunc(record); err != nil {
+		return err
+	}
+	if ttl := stateCacheTTL(record); ttl > 0 && redisRouteEnabledFunc() {
+		if encoded, err := json.Marshal(record); err == nil {
+			if err := redisSetRouteFunc(responseStateKey(record.ID), string(encoded), ttl); err != nil {
+				logger.SysError("Redis set responses state error: " + err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// Load returns the stored record when it exists and belongs to userID. A
+// missing userID matches no record.
+func Load(responseID string, userID string) (Record, error) {
+	normalizedResponseID := strings.TrimSpace(responseID)
+	normalizedUserID := strings.TrimSpace(userID)
+	if normalizedResponseID == "" || normalizedUserID == "" || !StoreEnabled() {
+		return Record{}, ErrStateNotFound
+	}
+	record, err := loadCachedOrStored(normalizedResponseID)
+	if err != nil {
+		return Record{}, err
+	}
+	if record.UserID != normalizedUserID {
+		return Record{}, ErrStateNotFound
+	}
+	return record, nil
+}
+
+func HasState(responseID string, userID string) bool {
+	_, err := Load(responseID, userID)
+	return err == nil
+}
+
+func Delete(responseID string, userID string) (bool, error) {
+	normalizedResponseID := strings.TrimSpace(responseID)
+	if normalizedResponseID == "" {
+		return false, nil
+	}
+	deleted, err := deleteStateFunc(normalizedResponseID, userID)
+	if err != nil {
+		return false, err
+	}
+	if redisRouteEnabledFunc() {
+		if err := redisDelFunc(responseStateKey(normalizedResponseID)); err != nil && err != redis.Nil {
+			logger.SysError("Redis delete responses state error: " + err.Error())
+		}
+	}
+	return deleted, nil
+}
+
+func loadCachedOrStored(responseID string) (Record, error) {
+	if redisRouteEnabledFunc() {
+		cached, err := redisGetRouteFunc(responseStateKey(responseID))
+		if err == nil && cached != "" {
+			record := Record{}
+			if err := json.Unmarshal([]byte(cached), &record); err == nil {
+				return record, nil
+			}
+		} else if err != nil && err != redis.Nil {
+			logger.SysError("Redis get responses state error: " + err.Error())
+		}
+	}
+	return loadStateFunc(responseID)
+}
+
+// ReplayItems rebuilds the conversation that ends with previousResponseID as a
+// flat list of input items that any upstream can accept.
+func ReplayItems(previousResponseID string, userID string) ([]json.RawMessage, error) {
+	items, _, err := replayChain(previousResponseID, userID)
+	return items, err
+}
+
+func replayChain(previousResponseID string, userID string) ([]json.RawMessage, map[string]json.RawMessage, error) {
+	chain := make([]Record, 0, 4)
+	seen := map[string]struct{}{}
+	nextID := strings.TrimSpace(previousResponseID)
+	for nextID != "" {
+		if _, ok := seen[nextID]; ok || len(chain) >= maxReplayChainLength {
+			break
+		}
+		seen[nextID] = struct{}{}
+		record, err := Load(nextID, userID)
+		if err != nil {
+			if len(chain) == 0 {
+				return nil, nil, err
+			}
+			// older turns expired; replay what is still available
+			break
+		}
+		chain = append(chain, record)
+		nextID = record.PreviousResponseID
+	}
+	itemsByID := map[string]json.RawMessage{}
+	items := make([]json.RawMessage, 0)
+	for index := len(chain) - 1; index >= 0; index-- {
+		for _, item := range chain[index].InputItems {
+			items = appendReplayItem(items, itemsByID, item)
+		}
+		for _, item := range chain[index].OutputItems {
+			items = appendReplayItem(items, itemsByID, item)
+		}
+	}
+	return items, itemsByID, nil
+}
+
+func appendReplayItem(items []json.RawMessage, itemsByID map[string]json.RawMessage, raw json.RawMessage) []json.RawMessage {
+	item := map[string]any{}
+	if err := json.Unmarshal(raw, &item); err != nil {
+		return items
+	}
+	itemID := strings.TrimSpace(asString(item["id"]))
+	switch asString(item["type"]) {
+	case "reasoning":
+		// reasoning items are encrypted per provider and cannot be replayed
+		return items
+	case "item_reference":
+		if referenced, ok := itemsByID[itemID]; ok {
+			return append(items, referenced)
+		}
+		return items
+	}
+	delete(item, "id")
+	delete(item, "status")
+	encoded, err := json.Marshal(item)
+	if err != nil {
+		return items
+	}
+	if itemID != "" {
+		itemsByID[itemID] = encoded
+	}
+	return append(items, encoded)
+}
+
+// ExpandRequestBody replaces previous_response_id with the stored conversation
+// so the request no longer depends on upstream state.
+func ExpandRequestBody(raw []byte, userID string) ([]byte, error) {
+	payload := map[string]any{}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, err
+	}
+	previousResponseID := strings.TrimSpace(asString(payload["previous_response_id"]))
+	if previousResponseID == "" {
+		return raw, nil
+	}
+	history, itemsByID, err := replayChain(previousResponseID, userID)
+	if err != nil {
+		return nil, err
+	}
+	input := make([]any, 0, len(history)+1)
+	for _, item := range history {
+		input = append(input, item)
+	}
+	switch current := payload["input"].(type) {
+	case string:
+		if current != "" {
+			input = append(input, map[string]any{"type": "message", "role": "user", "content": current})
+		}
+	case []any:
+		// ids in the new turn may belong to the upstream that created the
+		// previous response, so they are sanitized the same way as history.
+		currentItems := make([]json.RawMessage, 0, len(current))
+		for _, item := range current {
+			encoded, err := json.Marshal(item)
+			if err != nil {
+				continue
+			}
+			currentItems = appendReplayItem(currentItems, itemsByID, encoded)
+		}
+		for _, item := range currentItems {
+			input = append(input, item)
+		}
+	}
+	payload["input"] = input
+	delete(payload, "previous_response_id")
+	return json.Marshal(payload)
+}
+
+func StartStatePruneWorker() {
+	if !StoreEnabled() {
+		return
+	}
+	startStatePruneWorkerOnce.Do(func() { graceful.Worker(runStatePruneWorker) })
+}
+
+func runStatePruneWorker() {
+	logger.SysLog("[responses.state] prune worker started")
+	ticker := time.NewTicker(statePruneInterval)
+	defer ticker.Stop()
+	for {
+		heartbeat.Beat("responses_state_prune", statePruneInterval)
+		pruneExpiredStates()
+		select {
+		case <-graceful.Stopping():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func pruneExpiredStates() {
+	total := int64(0)
+	for {
+		deleted, err := model.DeleteExpiredResponseStatesWithDB(model.DB, helper.GetTimestamp(), statePruneBatchSize)
+		if err != nil {
+			logger.SysWarnf("[responses.state] prune expired states failed: %s", err.Error())
+			return
+		}
+		total += deleted
+		if deleted < statePruneBatchSize {
+			break
+		}
+	}
+	if total > 0 {
+		logger.SysLogf("[responses.state] pruned expired states count=%d", total)
+	}
+}
+
+func saveStateToDB(record Record) error {
+	inputItems, err := json.Marshal(record.InputItems)
+	if err != nil {
+		return err
+	}
+	outputItems, err := json.Marshal(record.OutputItems)
+	if err != nil {
+		return err
+	}
+	return model.UpsertResponseStateWithDB(model.DB, model.ResponseState{
+		ResponseID:         record.ID,
+		UserID:             record.UserID,
+		TokenID:            record.TokenID,
+		ChannelID:          record.ChannelID,
+		Model:              record.Model,
+		PreviousResponseID: record.PreviousResponseID,
+		Status:             record.Status,
+		InputItems:         string(inputItems),
+		OutputItems:        string(outputItems),
+		Response:           string(record.Response),
+		CreatedAt:          record.CreatedAt,
+		ExpiresAt:          stateExpiresAt(record.CreatedAt),
+	})
+}
+
+func loadStateFromDB(responseID string) (Record, error) {
+	row, err := model.GetResponseStateWithDB(model.DB, responseID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return Record{}, ErrStateNotFound
+		}
+		return Record{}, err
+	}
+	record := Record{
+		ID:                 row.ResponseID,
+		UserID:             row.UserID,
+		TokenID:            row.TokenID,
+		ChannelID:          row.ChannelID,
+		Model:              row.Model,
+		PreviousResponseID: row.PreviousResponseID,
+		Status:             row.Status,
+		Response:           json.RawMessage(row.Response),
+		CreatedAt:          row.CreatedAt,
+	}
+	if strings.TrimSpace(row.InputItems) != "" {
+		if err := json.Unmarshal([]byte(row.InputItems), &record.InputItems); err != nil {
+			return Record{}, err
+		}
+	}
+	if strings.TrimSpace(row.OutputItems) != "" {
+		if err := json.Unmarshal([]byte(row.OutputItems), &record.OutputItems); err != nil {
+			return Record{}, err
+		}
+	}
+	return record, nil
+}
+
+func deleteStateFromDB(responseID string, userID string) (bool, error) {
+	return model.DeleteResponseStateWithDB(model.DB, responseID, userID)
+}
+
+func responseStateKey(responseID string) string {
+	return responseStateKeyPrefix + responseID
+}
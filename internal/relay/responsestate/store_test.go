@@ -0,0 +1,180 @@
+package responsestate
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yeying-community/router/common/config"
+	"github.com/yeying-community/router/common/helper"
+)
+
+func useMemoryStateStore(t *testing.T) map[string]Record {
+	t.Helper()
+	ResetForTest()
+	t.Cleanup(ResetForTest)
+	records := map[string]Record{}
+	redisRouteEnabledFunc = func() bool { return false }
+	saveStateFunc = func(record Record) error {
+		records[record.ID] = record
+		return nil
+	}
+	loadStateFunc = func(responseID string) (Record, error) {
+		record, ok := records[responseID]
+		if !ok {
+			return Record{}, ErrStateNotFound
+		}
+		return record, nil
+	}
+	deleteStateFunc = func(responseID string, userID string) (bool, error) {
+		record, ok := records[responseID]
+		if !ok || record.UserID != userID {
+			return false, nil
+		}
+		delete(records, responseID)
+		return true, nil
+	}
+	return records
+}
+
+func saveTurn(t *testing.T, userID string, request string, response string) Record {
+	t.Helper()
+	record, ok := NewRecord([]byte(request), []byte(response))
+	if !ok {
+		t.Fatalf("NewRecord(%s) ok = false, want true", request)
+	}
+	record.UserID = userID
+	if err := Save(record); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	return record
+}
+
+func TestExpandRequestBodyReplaysStoredChain(t *testing.T) {
+	useMemoryStateStore(t)
+
+	saveTurn(t, "user-1",
+		`{"model":"gpt-5","input":"hello"}`,
+		`{"id":"resp_1","status":"completed","output":[{"type":"reasoning","id":"rs_1","summary":[]},{"type":"message","id":"msg_a","role":"assistant","status":"completed","content":[{"type":"output_text","text":"hi"}]}]}`,
+	)
+	saveTurn(t, "user-1",
+		`{"model":"gpt-5","previous_response_id":"resp_1","input":[{"type":"message","role":"user","content":"weather?"}]}`,
+		`{"id":"resp_2","status":"completed","output":[{"type":"function_call","id":"fc_1","call_id":"call_1","name":"weather","arguments":"{}"}]}`,
+	)
+
+	expanded, err := ExpandRequestBody([]byte(`{"model":"gpt-5","previous_response_id":"resp_2","input":[{"type":"function_call_output","call_id":"call_1","output":"sunny"},{"type":"item_reference","id":"msg_a"}]}`), "user-1")
+	if err != nil {
+		t.Fatalf("ExpandRequestBody returned error: %v", err)
+	}
+	payload := map[string]any{}
+	if err := json.Unmarshal(expanded, &payload); err != nil {
+		t.Fatalf("unmarshal expanded body: %v", err)
+	}
+	if _, ok := payload["previous_response_id"]; ok {
+		t.Fatal("previous_response_id should be removed from the replayed body")
+	}
+	input, _ := payload["input"].([]any)
+	wantTypes := []string{"message", "message", "message", "function_call", "function_call_output", "message"}
+	if len(input) != len(wantTypes) {
+		t.Fatalf("input len = %d, want %d: %s", len(input), len(wantTypes), expanded)
+	}
+	for index, want := range wantTypes {
+		item := input[index].(map[string]any)
+		if item["type"] != want {
+			t.Fatalf("input[%d].type = %v, want %s", index, item["type"], want)
+		}
+		if _, ok := item["id"]; ok {
+			t.Fatalf("input[%d] still carries upstream id: %v", index, item)
+		}
+	}
+	if role := input[5].(map[string]any)["role"]; role != "assistant" {
+		t.Fatalf("item_reference resolved role = %v, want assistant", role)
+	}
+}
+
+func TestExpandRequestBodyRejectsOtherUsers(t *testing.T) {
+	useMemoryStateStore(t)
+	saveTurn(t, "user-1", `{"input":"hello"}`, `{"id":"resp_1","output":[]}`)
+
+	if _, err := ExpandRequestBody([]byte(`{"previous_response_id":"resp_1","input":"again"}`), "user-2"); err != ErrStateNotFound {
+		t.Fatalf("ExpandRequestBody error = %v, want ErrStateNotFound", err)
+	}
+	if HasState("resp_1", "user-2") {
+		t.Fatal("HasState for another user = true, want false")
+	}
+	if !HasState("resp_1", "user-1") {
+		t.Fatal("HasState for owner = false, want true")
+	}
+}
+
+func TestLoadRequiresUser(t *testing.T) {
+	useMemoryStateStore(t)
+	saveTurn(t, "user-1", `{"input":"hello"}`, `{"id":"resp_1","output":[]}`)
+
+	if _, err := Load("resp_1", ""); err != ErrStateNotFound {
+		t.Fatalf("Load without user error = %v, want ErrStateNotFound", err)
+	}
+	if _, err := ExpandRequestBody([]byte(`{"previous_response_id":"resp_1","input":"again"}`), " "); err != ErrStateNotFound {
+		t.Fatalf("ExpandRequestBody without user error = %v, want ErrStateNotFound", err)
+	}
+}
+
+func TestSaveCachesNoLongerThanTheStoredRecord(t *testing.T) {
+	useMemoryStateStore(t)
+	previousTTL := config.ResponsesStateTTLHours
+	t.Cleanup(func() { config.ResponsesStateTTLHours = previousTTL })
+	config.ResponsesStateTTLHours = 1
+	cached := map[string]time.Duration{}
+	redisRouteEnabledFunc = func() bool { return true }
+	redisSetRouteFunc = func(key string, value string, expiration time.Duration) error {
+		cached[key] = expiration
+		return nil
+	}
+
+	now := helper.GetTimestamp()
+	for id, createdAt := range map[string]int64{"resp_new": now, "resp_old": now - 50*60, "resp_expired": now - 2*3600} {
+		if err := Save(Record{ID: id, UserID: "user-1", CreatedAt: createdAt}); err != nil {
+			t.Fatalf("Save(%s): %v", id, err)
+		}
+	}
+	if ttl := cached[responseStateKey("resp_new")]; ttl != responseStateCacheTTL {
+		t.Fatalf("new record cache ttl = %v, want %v", ttl, responseStateCacheTTL)
+	}
+	if ttl := cached[responseStateKey("resp_old")]; ttl <= 0 || ttl > 10*time.Minute {
+		t.Fatalf("old record cache ttl = %v, want the 10 minutes left before it expires", ttl)
+	}
+	if _, ok := cached[responseStateKey("resp_expired")]; ok {
+		t.Fatal("expired record was cached")
+	}
+}
+
+func TestNewRecordSkipsStoreFalse(t *testing.T) {
+	if _, ok := NewRecord([]byte(`{"store":false,"input":"hi"}`), []byte(`{"id":"resp_1"}`)); ok {
+		t.Fatal("NewRecord ok = true for store=false, want false")
+	}
+	record, ok := NewRecord([]byte(`{"input":"hi"}`), []byte(`{"id":"resp_1","model":"gpt-5"}`))
+	if !ok {
+		t.Fatal("NewRecord ok = false, want true")
+	}
+	if len(record.InputItems) != 1 || !strings.Contains(string(record.InputItems[0]), `"id":"msg_`) {
+		t.Fatalf("InputItems = %s, want one message item with generated id", record.InputItems)
+	}
+}
+
+func TestRecorderCapturesStreamedResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	recorder := NewRecorder(c.Writer, true)
+
+	_, _ = recorder.WriteString("event: response.output_text.delta\ndata: {\"type\":\"response.output_text.delta\",\"delta\":\"hi\"}\n\n")
+	_, _ = recorder.Write([]byte("event: response.completed\ndata: {\"type\":\"response.completed\",\"response\":{\"id\":\"resp_1\",\"out"))
+	_, _ = recorder.Write([]byte("put\":[]}}\n\n"))
+
+	if got := string(recorder.Response()); got != `{"id":"resp_1","output":[]}` {
+		t.Fatalf("Response() = %s", got)
+	}
+}
@@ -24,6 +24,8 @@ type RouteDecision struct {
 	InitialChannelName  string              `json:"initial_channel_name,omitempty"`
 	FinalChannelID      string              `json:"final_channel_id,omitempty"`
 	FinalChannelName    string              `json:"final_channel_name,omitempty"`
+	RequestedModel      string              `json:"requested_model,omitempty"`
+	FinalModel          string              `json:"final_model,omitempty"`
 }
 
 type FilteredCandidate struct {
@@ -87,6 +89,10 @@ func FinalizedRouteDecisionJSON(c *gin.Context) string {
 	}
 	decision.FinalChannelID = strings.TrimSpace(c.GetString(ctxkey.ChannelId))
 	decision.FinalChannelName = strings.TrimSpace(c.GetString(ctxkey.ChannelName))
+	decision.RequestedModel = strings.TrimSpace(c.GetString(ctxkey.ClientRequestModel))
+	if finalModel := strings.TrimSpace(c.GetString(ctxkey.RequestModel)); finalModel != decision.Model {
+		decision.FinalModel = finalModel
+	}
 	payload, err := json.Marshal(decision)
 	if err != nil {
 		return ""
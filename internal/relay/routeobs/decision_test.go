@@ -41,3 +41,22 @@ func TestFinalizedRouteDecisionJSONKeepsInitialAndRecordsFinalChannel(t *testing
 		t.Fatalf("unexpected filtered candidates: %+v", got.FilteredCandidates)
 	}
 }
+
+func TestFinalizedRouteDecisionJSONRecordsModelHop(t *testing.T) {
+	c, _ := gin.CreateTestContext(nil)
+	SetRouteDecision(c, RouteDecision{
+		Source:           "automatic",
+		Model:            "gpt-4o",
+		InitialChannelID: "channel-1",
+	})
+	c.Set(ctxkey.ClientRequestModel, "ab-test")
+	c.Set(ctxkey.RequestModel, "gpt-4.1")
+
+	var got RouteDecision
+	if err := json.Unmarshal([]byte(FinalizedRouteDecisionJSON(c)), &got); err != nil {
+		t.Fatalf("unmarshal finalized route decision: %v", err)
+	}
+	if got.RequestedModel != "ab-test" || got.Model != "gpt-4o" || got.FinalModel != "gpt-4.1" {
+		t.Fatalf("unexpected model hop: %+v", got)
+	}
+}
@@ -14,6 +14,7 @@ type FallbackAttempt struct {
 	ChannelID   string `json:"channel_id"`
 	ChannelName string `json:"channel_name,omitempty"`
 	Model       string `json:"model,omitempty"`
+	ModelHop    int    `json:"model_hop,omitempty"`
 	Endpoint    string `json:"endpoint,omitempty"`
 	Protocol    string `json:"protocol,omitempty"`
 	Status      int    `json:"status,omitempty"`
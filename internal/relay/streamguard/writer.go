@@ -0,0 +1,316 @@
+package streamguard
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UpstreamError is an error event the upstream sent inside the stream.
+type UpstreamError struct {
+	StatusCode int
+	Message    string
+	Type       string
+	Code       any
+}
+
+// Writer holds back a streamed response until it carries its first content
+// token or grows past a byte limit. Until then nothing reaches the client, so
+// an upstream that fails early can be retried on another channel unnoticed.
+type Writer struct {
+	gin.ResponseWriter
+	limit         int
+	savedHeader   http.Header
+	status        int
+	buffer        bytes.Buffer
+	pending       []byte
+	committed     bool
+	upstreamError *UpstreamError
+
+	bodyMu  sync.Mutex
+	bodyErr error
+}
+
+func New(w gin.ResponseWriter, limit int) *Writer {
+	return &Writer{ResponseWriter: w, limit: limit, savedHeader: w.Header().Clone()}
+}
+
+// WrapBody records read failures of the upstream body, which stream
+// handlers usually only log before closing the stream normally.
+func (w *Writer) WrapBody(body io.ReadCloser) io.ReadCloser {
+	return &bodyReader{ReadCloser: body, writer: w}
+}
+
+func (w *Writer) Write(data []byte) (int, error) {
+	if w.committed {
+		return w.ResponseWriter.Write(data)
+	}
+	w.buffer.Write(data)
+	w.inspect(data)
+	if w.upstreamError == nil && (w.committed || w.buffer.Len() >= w.limit) {
+		if err := w.Commit(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *Writer) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *Writer) WriteHeader(code int) {
+	if w.committed {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *Writer) WriteHeaderNow() {
+	if w.committed {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *Writer) Flush() {
+	if w.committed {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *Writer) Status() int {
+	if w.committed {
+		return w.ResponseWriter.Status()
+	}
+	if w.status != 0 {
+		return w.status
+	}
+	return http.StatusOK
+}
+
+func (w *Writer) Size() int {
+	if w.committed {
+		return w.ResponseWriter.Size()
+	}
+	return -1
+}
+
+func (w *Writer) Written() bool {
+	if w.committed {
+		return w.ResponseWriter.Written()
+	}
+	return false
+}
+
+// Committed reports whether the response has been released to the client.
+func (w *Writer) Committed() bool {
+	return w.committed
+}
+
+// UpstreamError returns the error event seen before the first token.
+func (w *Writer) UpstreamError() *UpstreamError {
+	return w.upstreamError
+}
+
+// BodyError returns the first failure reading the upstream body.
+func (w *Writer) BodyError() error {
+	w.bodyMu.Lock()
+	defer w.bodyMu.Unlock()
+	return w.bodyErr
+}
+
+// Buffered returns the number of bytes held back.
+func (w *Writer) Buffered() int {
+	return w.buffer.Len()
+}
+
+// Commit releases the held-back output; everything written afterwards goes
+// straight to the client.
+func (w *Writer) Commit() error {
+	w.committed = true
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if w.buffer.Len() > 0 {
+		if _, err := w.ResponseWriter.Write(w.buffer.Bytes()); err != nil {
+			return err
+		}
+		w.ResponseWriter.Flush()
+	}
+	w.buffer.Reset()
+	w.pending = nil
+	return nil
+}
+
+// Discard drops the held-back output and the headers the attempt set, so the
+// next attempt starts from a clean response.
+func (w *Writer) Discard() {
+	header := w.ResponseWriter.Header()
+	for key := range header {
+		delete(header, key)
+	}
+	for key, values := range w.savedHeader {
+		header[key] = values
+	}
+	w.status = 0
+	w.buffer.Reset()
+	w.pending = nil
+}
+
+// inspect scans the complete SSE data lines written so far for the first
+// content token or an error event.
+func (w *Writer) inspect(data []byte) {
+	w.pending = append(w.pending, data...)
+	for {
+		index := bytes.IndexByte(w.pending, '\n')
+		if index < 0 {
+			break
+		}
+		line := strings.TrimSpace(string(w.pending[:index]))
+		w.pending = w.pending[index+1:]
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if payload == "" || payload == "[DONE]" {
+			continue
+		}
+		event := map[string]any{}
+		if err := json.Unmarshal([]byte(payload), &event); err != nil {
+			continue
+		}
+		if upstreamError := eventError(event); upstreamError != nil {
+			w.upstreamError = upstreamError
+			return
+		}
+		if eventHasContent(event) {
+			w.committed = true
+			return
+		}
+	}
+}
+
+// eventHasContent recognizes the first generated token in the downstream
+// dialects: Chat Completions and Completions chunks, Messages content block
+// deltas, Responses *.delta events and Gemini candidates.
+func eventHasContent(event map[string]any) bool {
+	eventType, _ := event["type"].(string)
+	switch {
+	case eventType == "content_block_delta":
+		delta, _ := event["delta"].(map[string]any)
+		for _, key := range []string{"text", "partial_json", "thinking"} {
+			if nonEmptyString(delta[key]) {
+				return true
+			}
+		}
+		return false
+	case strings.HasSuffix(eventType, ".delta"):
+		return nonEmptyString(event["delta"])
+	}
+	if choices, ok := event["choices"].([]any); ok {
+		for _, item := range choices {
+			choice, _ := item.(map[string]any)
+			if nonEmptyString(choice["text"]) {
+				return true
+			}
+			delta, _ := choice["delta"].(map[string]any)
+			if nonEmptyString(delta["content"]) || nonEmptyString(delta["reasoning_content"]) {
+				return true
+			}
+			if toolCalls, ok := delta["tool_calls"].([]any); ok && len(toolCalls) > 0 {
+				return true
+			}
+		}
+	}
+	if candidates, ok := event["candidates"].([]any); ok {
+		for _, item := range candidates {
+			candidate, _ := item.(map[string]any)
+			content, _ := candidate["content"].(map[string]any)
+			parts, _ := content["parts"].([]any)
+			for _, part := range parts {
+				object, _ := part.(map[string]any)
+				if nonEmptyString(object["text"]) || object["functionCall"] != nil {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func eventError(event map[string]any) *UpstreamError {
+	raw, ok := event["error"]
+	if !ok || raw == nil {
+		eventType, _ := event["type"].(string)
+		if eventType != "response.failed" {
+			return nil
+		}
+		response, _ := event["response"].(map[string]any)
+		if raw = response["error"]; raw == nil {
+			return &UpstreamError{Message: "upstream response failed"}
+		}
+	}
+	upstreamError := &UpstreamError{}
+	switch value := raw.(type) {
+	case string:
+		upstreamError.Message = value
+	case map[string]any:
+		upstreamError.Message, _ = value["message"].(string)
+		upstreamError.Type, _ = value["type"].(string)
+		upstreamError.Code = value["code"]
+		upstreamError.StatusCode = errorStatusCode(value["status"])
+		if upstreamError.StatusCode == 0 {
+			upstreamError.StatusCode = errorStatusCode(value["code"])
+		}
+	}
+	if strings.TrimSpace(upstreamError.Message) == "" {
+		upstreamError.Message = "upstream stream error"
+	}
+	return upstreamError
+}
+
+func errorStatusCode(value any) int {
+	code := 0
+	switch typed := value.(type) {
+	case float64:
+		code = int(typed)
+	case string:
+		code, _ = strconv.Atoi(strings.TrimSpace(typed))
+	}
+	if code < 400 || code > 599 {
+		return 0
+	}
+	return code
+}
+
+func nonEmptyString(value any) bool {
+	text, ok := value.(string)
+	return ok && text != ""
+}
+
+type bodyReader struct {
+	io.ReadCloser
+	writer *Writer
+}
+
+func (r *bodyReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) {
+		r.writer.bodyMu.Lock()
+		if r.writer.bodyErr == nil {
+			r.writer.bodyErr = err
+		}
+		r.writer.bodyMu.Unlock()
+	}
+	return n, err
+}
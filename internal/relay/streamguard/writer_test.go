@@ -0,0 +1,100 @@
+package streamguard
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newGuardForTest(t *testing.T, limit int) (*Writer, *httptest.ResponseRecorder) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Writer.Header().Set("X-Request-Id", "req-1")
+	return New(c.Writer, limit), recorder
+}
+
+func TestWriterHoldsBackUntilFirstContentToken(t *testing.T) {
+	guard, recorder := newGuardForTest(t, 1<<20)
+	guard.Header().Set("Content-Type", "text/event-stream")
+	_, _ = guard.WriteString(`data: {"choices":[{"delta":{"role":"assistant","content":""}}]}` + "\n\n")
+	guard.Flush()
+	if guard.Committed() || recorder.Body.Len() != 0 {
+		t.Fatalf("role chunk must stay buffered, body = %q", recorder.Body.String())
+	}
+	_, _ = guard.WriteString(`data: {"choices":[{"delta":{"content":"Hi"}}]}` + "\n\n")
+	if !guard.Committed() {
+		t.Fatalf("first content token should commit the stream")
+	}
+	_, _ = guard.WriteString("data: [DONE]\n\n")
+	if !strings.Contains(recorder.Body.String(), `"role":"assistant"`) || !strings.HasSuffix(recorder.Body.String(), "data: [DONE]\n\n") {
+		t.Fatalf("body = %q, want buffered and later output in order", recorder.Body.String())
+	}
+}
+
+func TestWriterDetectsContentAcrossDialects(t *testing.T) {
+	events := []string{
+		`{"type":"content_block_delta","delta":{"type":"text_delta","text":"Hi"}}`,
+		`{"type":"response.output_text.delta","delta":"Hi"}`,
+		`{"choices":[{"text":"Hi"}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0}]}}]}`,
+		`{"candidates":[{"content":{"parts":[{"text":"Hi"}]}}]}`,
+	}
+	for _, event := range events {
+		guard, _ := newGuardForTest(t, 1<<20)
+		_, _ = guard.WriteString("data: " + event + "\n\n")
+		if !guard.Committed() {
+			t.Fatalf("event %s should count as content", event)
+		}
+	}
+}
+
+func TestWriterKeepsErrorEventBeforeFirstTokenAndDiscards(t *testing.T) {
+	guard, recorder := newGuardForTest(t, 8)
+	guard.Header().Set("Content-Type", "text/event-stream")
+	guard.WriteHeader(http.StatusOK)
+	_, _ = guard.WriteString(`data: {"error":{"message":"overloaded","type":"server_error","code":"503"}}` + "\n\n")
+	if guard.Committed() {
+		t.Fatalf("an error event must never be released past the byte limit")
+	}
+	upstreamErr := guard.UpstreamError()
+	if upstreamErr == nil || upstreamErr.StatusCode != http.StatusServiceUnavailable || upstreamErr.Message != "overloaded" {
+		t.Fatalf("upstream error = %#v", upstreamErr)
+	}
+	guard.Discard()
+	if recorder.Body.Len() != 0 || recorder.Header().Get("Content-Type") != "" || recorder.Header().Get("X-Request-Id") != "req-1" {
+		t.Fatalf("discard should restore the original headers, got %v body %q", recorder.Header(), recorder.Body.String())
+	}
+}
+
+func TestWriterCommitsPastByteLimit(t *testing.T) {
+	guard, recorder := newGuardForTest(t, 16)
+	_, _ = guard.WriteString(": keep-alive padding\n")
+	if !guard.Committed() || recorder.Body.String() != ": keep-alive padding\n" {
+		t.Fatalf("output past the limit should be released, body = %q", recorder.Body.String())
+	}
+}
+
+func TestWrapBodyRecordsReadFailure(t *testing.T) {
+	guard, _ := newGuardForTest(t, 1<<20)
+	failure := errors.New("connection reset by peer")
+	body := guard.WrapBody(io.NopCloser(io.MultiReader(strings.NewReader("data: x\n"), &failingReader{err: failure})))
+	_, _ = io.ReadAll(body)
+	if !errors.Is(guard.BodyError(), failure) {
+		t.Fatalf("body error = %v, want %v", guard.BodyError(), failure)
+	}
+}
+
+type failingReader struct {
+	err error
+}
+
+func (r *failingReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,69 @@
+package structuredoutput
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	relaymodel "github.com/yeying-community/router/internal/relay/model"
+)
+
+const (
+	ResponseFormatJSONSchema = "json_schema"
+	ResponseFormatJSONObject = "json_object"
+	DefaultSchemaName        = "json_response"
+)
+
+// Schema returns the JSON schema requested through
+// response_format {type: json_schema}, or nil when none was requested.
+func Schema(format *relaymodel.ResponseFormat) map[string]any {
+	if format == nil || format.Type != ResponseFormatJSONSchema || format.JsonSchema == nil {
+		return nil
+	}
+	return format.JsonSchema.Schema
+}
+
+// SchemaName returns the schema name, falling back to a stable default so it
+// can be used as a tool name.
+func SchemaName(format *relaymodel.ResponseFormat) string {
+	if format == nil || format.JsonSchema == nil {
+		return DefaultSchemaName
+	}
+	name := strings.TrimSpace(format.JsonSchema.Name)
+	if name == "" {
+		return DefaultSchemaName
+	}
+	return name
+}
+
+// DowngradeToJSONObject rewrites a json_schema request for providers that only
+// offer a JSON mode: response_format becomes json_object and the schema is
+// spelled out in the system prompt instead.
+func DowngradeToJSONObject(request *relaymodel.GeneralOpenAIRequest) {
+	if request == nil {
+		return
+	}
+	schema := Schema(request.ResponseFormat)
+	if schema == nil {
+		return
+	}
+	instruction := schemaInstruction(request.ResponseFormat)
+	request.ResponseFormat = &relaymodel.ResponseFormat{Type: ResponseFormatJSONObject}
+	for i, message := range request.Messages {
+		if message.Role != "system" {
+			continue
+		}
+		request.Messages[i].Content = strings.TrimSpace(message.StringContent() + "\n\n" + instruction)
+		return
+	}
+	request.Messages = append([]relaymodel.Message{{Role: "system", Content: instruction}}, request.Messages...)
+}
+
+func schemaInstruction(format *relaymodel.ResponseFormat) string {
+	schema, _ := json.Marshal(format.JsonSchema.Schema)
+	instruction := fmt.Sprintf("Respond only with a JSON object that conforms to the following JSON schema named %q:\n%s", SchemaName(format), schema)
+	if description := strings.TrimSpace(format.JsonSchema.Description); description != "" {
+		instruction += "\nSchema description: " + description
+	}
+	return instruction
+}
@@ -0,0 +1,329 @@
+package structuredoutput
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidationError describes the first place where a document does not match
+// its schema.
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// Validate checks that raw is a JSON document matching schema. It covers the
+// JSON Schema subset accepted by OpenAI structured outputs: types, enum/const,
+// properties/required/additionalProperties, items, anyOf/oneOf/allOf,
+// numeric and length bounds, pattern and local $ref.
+func Validate(schema map[string]any, raw []byte) error {
+	var document any
+	if err := json.Unmarshal(raw, &document); err != nil {
+		return &ValidationError{Message: "output is not valid JSON: " + err.Error()}
+	}
+	v := validator{root: schema}
+	return v.validate(schema, document, "$", 0)
+}
+
+const maxRefDepth = 64
+
+type validator struct {
+	root map[string]any
+}
+
+func (v validator) validate(schema map[string]any, value any, path string, depth int) error {
+	if schema == nil {
+		return nil
+	}
+	if ref, ok := schema["$ref"].(string); ok {
+		if depth >= maxRefDepth {
+			return &ValidationError{Path: path, Message: "schema $ref nesting is too deep"}
+		}
+		resolved, err := v.resolveRef(ref)
+		if err != nil {
+			return &ValidationError{Path: path, Message: err.Error()}
+		}
+		return v.validate(resolved, value, path, depth+1)
+	}
+	if err := v.validateType(schema, value, path); err != nil {
+		return err
+	}
+	if values, ok := schema["enum"].([]any); ok && !containsValue(values, value) {
+		return &ValidationError{Path: path, Message: "value is not one of the allowed enum values"}
+	}
+	if expected, ok := schema["const"]; ok && !reflect.DeepEqual(expected, value) {
+		return &ValidationError{Path: path, Message: "value does not match const"}
+	}
+	if err := v.validateCombinators(schema, value, path, depth); err != nil {
+		return err
+	}
+	switch typed := value.(type) {
+	case map[string]any:
+		return v.validateObject(schema, typed, path, depth)
+	case []any:
+		return v.validateArray(schema, typed, path, depth)
+	case string:
+		return validateString(schema, typed, path)
+	case float64:
+		return validateNumber(schema, typed, path)
+	}
+	return nil
+}
+
+func (v validator) resolveRef(ref string) (map[string]any, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("unsupported schema $ref %q", ref)
+	}
+	var current any = v.root
+	for _, segment := range strings.Split(strings.TrimPrefix(strings.TrimPrefix(ref, "#"), "/"), "/") {
+		if segment == "" {
+			continue
+		}
+		segment = strings.ReplaceAll(strings.ReplaceAll(segment, "~1", "/"), "~0", "~")
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unresolvable schema $ref %q", ref)
+		}
+		current = object[segment]
+	}
+	resolved, ok := current.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("unresolvable schema $ref %q", ref)
+	}
+	return resolved, nil
+}
+
+func (v validator) validateType(schema map[string]any, value any, path string) error {
+	var types []string
+	switch typed := schema["type"].(type) {
+	case string:
+		types = []string{typed}
+	case []any:
+		for _, item := range typed {
+			if name, ok := item.(string); ok {
+				types = append(types, name)
+			}
+		}
+	}
+	if len(types) == 0 {
+		return nil
+	}
+	for _, name := range types {
+		if matchesType(name, value) {
+			return nil
+		}
+	}
+	return &ValidationError{Path: path, Message: fmt.Sprintf("expected %s, got %s", strings.Join(types, " or "), jsonTypeName(value))}
+}
+
+func (v validator) validateCombinators(schema map[string]any, value any, path string, depth int) error {
+	if subschemas, ok := schema["allOf"].([]any); ok {
+		for _, item := range subschemas {
+			if err := v.validate(asSchema(item), value, path, depth); err != nil {
+				return err
+			}
+		}
+	}
+	if subschemas, ok := schema["anyOf"].([]any); ok {
+		var firstErr error
+		matched := false
+		for _, item := range subschemas {
+			err := v.validate(asSchema(item), value, path, depth)
+			if err == nil {
+				matched = true
+				break
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		if !matched {
+			return &ValidationError{Path: path, Message: fmt.Sprintf("value does not match any allowed schema (%v)", firstErr)}
+		}
+	}
+	if subschemas, ok := schema["oneOf"].([]any); ok {
+		matches := 0
+		for _, item := range subschemas {
+			if v.validate(asSchema(item), value, path, depth) == nil {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return &ValidationError{Path: path, Message: fmt.Sprintf("value matches %d schemas in oneOf, want exactly 1", matches)}
+		}
+	}
+	return nil
+}
+
+func (v validator) validateObject(schema map[string]any, object map[string]any, path string, depth int) error {
+	if required, ok := schema["required"].([]any); ok {
+		for _, item := range required {
+			name, _ := item.(string)
+			if _, exists := object[name]; name != "" && !exists {
+				return &ValidationError{Path: path, Message: fmt.Sprintf("missing required property %q", name)}
+			}
+		}
+	}
+	properties, _ := schema["properties"].(map[string]any)
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		childPath := path + "." + key
+		if propertySchema, ok := properties[key]; ok {
+			if err := v.validate(asSchema(propertySchema), object[key], childPath, depth); err != nil {
+				return err
+			}
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				return &ValidationError{Path: path, Message: fmt.Sprintf("unexpected property %q", key)}
+			}
+		case map[string]any:
+			if err := v.validate(additional, object[key], childPath, depth); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v validator) validateArray(schema map[string]any, items []any, path string, depth int) error {
+	if minItems, ok := schemaNumber(schema, "minItems"); ok && float64(len(items)) < minItems {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("expected at least %v items", minItems)}
+	}
+	if maxItems, ok := schemaNumber(schema, "maxItems"); ok && float64(len(items)) > maxItems {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("expected at most %v items", maxItems)}
+	}
+	itemSchema, ok := schema["items"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	for i, item := range items {
+		if err := v.validate(itemSchema, item, fmt.Sprintf("%s[%d]", path, i), depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateString(schema map[string]any, value string, path string) error {
+	length := float64(utf8.RuneCountInString(value))
+	if minLength, ok := schemaNumber(schema, "minLength"); ok && length < minLength {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("expected at least %v characters", minLength)}
+	}
+	if maxLength, ok := schemaNumber(schema, "maxLength"); ok && length > maxLength {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("expected at most %v characters", maxLength)}
+	}
+	if pattern, ok := schema["pattern"].(string); ok && pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err == nil && !re.MatchString(value) {
+			return &ValidationError{Path: path, Message: fmt.Sprintf("value does not match pattern %q", pattern)}
+		}
+	}
+	return nil
+}
+
+func validateNumber(schema map[string]any, value float64, path string) error {
+	if minimum, ok := schemaNumber(schema, "minimum"); ok && value < minimum {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("expected a value >= %v", minimum)}
+	}
+	if maximum, ok := schemaNumber(schema, "maximum"); ok && value > maximum {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("expected a value <= %v", maximum)}
+	}
+	if minimum, ok := schemaNumber(schema, "exclusiveMinimum"); ok && value <= minimum {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("expected a value > %v", minimum)}
+	}
+	if maximum, ok := schemaNumber(schema, "exclusiveMaximum"); ok && value >= maximum {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("expected a value < %v", maximum)}
+	}
+	return nil
+}
+
+func matchesType(name string, value any) bool {
+	switch name {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		number, ok := value.(float64)
+		return ok && number == math.Trunc(number)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	default:
+		return true
+	}
+}
+
+func jsonTypeName(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func containsValue(values []any, value any) bool {
+	for _, item := range values {
+		if reflect.DeepEqual(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func asSchema(value any) map[string]any {
+	schema, _ := value.(map[string]any)
+	return schema
+}
+
+func schemaNumber(schema map[string]any, key string) (float64, bool) {
+	switch value := schema[key].(type) {
+	case float64:
+		return value, true
+	case int:
+		return float64(value), true
+	default:
+		return 0, false
+	}
+}
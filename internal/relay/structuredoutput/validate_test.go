@@ -0,0 +1,82 @@
+package structuredoutput
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	relaymodel "github.com/yeying-community/router/internal/relay/model"
+)
+
+func mustSchema(t *testing.T, raw string) map[string]any {
+	t.Helper()
+	schema := map[string]any{}
+	if err := json.Unmarshal([]byte(raw), &schema); err != nil {
+		t.Fatalf("unmarshal schema: %v", err)
+	}
+	return schema
+}
+
+func TestValidateAcceptsConformingDocument(t *testing.T) {
+	schema := mustSchema(t, `{
+		"type":"object",
+		"properties":{
+			"name":{"type":"string","minLength":1},
+			"age":{"type":"integer","minimum":0},
+			"tags":{"type":"array","items":{"$ref":"#/$defs/tag"}},
+			"nickname":{"type":["string","null"]}
+		},
+		"required":["name","age","tags","nickname"],
+		"additionalProperties":false,
+		"$defs":{"tag":{"type":"string","enum":["a","b"]}}
+	}`)
+
+	if err := Validate(schema, []byte(`{"name":"x","age":3,"tags":["a","b"],"nickname":null}`)); err != nil {
+		t.Fatalf("Validate returned error: %v", err)
+	}
+}
+
+func TestValidateReportsFirstViolation(t *testing.T) {
+	schema := mustSchema(t, `{
+		"type":"object",
+		"properties":{"age":{"type":"integer"},"tags":{"type":"array","items":{"type":"string","enum":["a"]}}},
+		"required":["age"],
+		"additionalProperties":false
+	}`)
+
+	cases := map[string]string{
+		`not json`:                   "not valid JSON",
+		`{}`:                         `missing required property "age"`,
+		`{"age":1.5}`:                "$.age: expected integer",
+		`{"age":1,"extra":true}`:     `unexpected property "extra"`,
+		`{"age":1,"tags":["a","c"]}`: "$.tags[1]: value is not one of the allowed enum values",
+	}
+	for document, want := range cases {
+		err := Validate(schema, []byte(document))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("Validate(%s) error = %v, want %q", document, err, want)
+		}
+	}
+}
+
+func TestDowngradeToJSONObjectMovesSchemaIntoSystemPrompt(t *testing.T) {
+	request := &relaymodel.GeneralOpenAIRequest{
+		Messages: []relaymodel.Message{{Role: "user", Content: "hi"}},
+		ResponseFormat: &relaymodel.ResponseFormat{
+			Type:       ResponseFormatJSONSchema,
+			JsonSchema: &relaymodel.JSONSchema{Name: "person", Schema: map[string]any{"type": "object"}},
+		},
+	}
+
+	DowngradeToJSONObject(request)
+
+	if request.ResponseFormat.Type != ResponseFormatJSONObject || request.ResponseFormat.JsonSchema != nil {
+		t.Fatalf("response_format = %#v, want json_object", request.ResponseFormat)
+	}
+	if len(request.Messages) != 2 || request.Messages[0].Role != "system" {
+		t.Fatalf("messages = %#v, want leading system prompt", request.Messages)
+	}
+	if prompt := request.Messages[0].StringContent(); !strings.Contains(prompt, `"person"`) || !strings.Contains(prompt, `{"type":"object"}`) {
+		t.Fatalf("system prompt = %q, want schema name and body", prompt)
+	}
+}
@@ -0,0 +1,684 @@
+package textconv
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/yeying-community/router/common/random"
+	relaymodel "github.com/yeying-community/router/internal/relay/model"
+	"github.com/yeying-community/router/internal/relay/relaymode"
+)
+
+// Gemini ingress speaks generateContent on the edge and is relayed as chat
+// completions (or embeddings) internally. Field names are accepted in both the
+// camelCase form sent by the GenAI SDKs and the snake_case form of the REST docs.
+
+type geminiInboundRequest struct {
+	Contents               []geminiContent         `json:"contents"`
+	SystemInstruction      *geminiContent          `json:"systemInstruction"`
+	SystemInstructionSnake *geminiContent          `json:"system_instruction"`
+	GenerationConfig       *geminiGenerationConfig `json:"generationConfig"`
+	GenerationConfigSnake  *geminiGenerationConfig `json:"generation_config"`
+	Tools                  []geminiTool            `json:"tools"`
+	ToolConfig             *geminiToolConfig       `json:"toolConfig"`
+	ToolConfigSnake        *geminiToolConfig       `json:"tool_config"`
+	// countTokens may wrap the full request
+	GenerateContentRequest json.RawMessage `json:"generateContentRequest"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiPart struct {
+	Text             string                `json:"text,omitempty"`
+	Thought          bool                  `json:"thought,omitempty"`
+	InlineData       *geminiBlob           `json:"inlineData,omitempty"`
+	InlineDataSnake  *geminiBlob           `json:"inline_data,omitempty"`
+	FileData         *geminiFileData       `json:"fileData,omitempty"`
+	FileDataSnake    *geminiFileData       `json:"file_data,omitempty"`
+	FunctionCall     *geminiFunctionCall   `json:"functionCall,omitempty"`
+	FunctionCallSnk  *geminiFunctionCall   `json:"function_call,omitempty"`
+	FunctionResponse *geminiFunctionResult `json:"functionResponse,omitempty"`
+	FunctionRespSnk  *geminiFunctionResult `json:"function_response,omitempty"`
+}
+
+type geminiBlob struct {
+	MimeType      string `json:"mimeType"`
+	MimeTypeSnake string `json:"mime_type"`
+	Data          string `json:"data"`
+}
+
+type geminiFileData struct {
+	MimeType      string `json:"mimeType"`
+	MimeTypeSnake string `json:"mime_type"`
+	FileURI       string `json:"fileUri"`
+	FileURISnake  string `json:"file_uri"`
+}
+
+type geminiFunctionCall struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+	Args any    `json:"args,omitempty"`
+}
+
+type geminiFunctionResult struct {
+	ID       string `json:"id,omitempty"`
+	Name     string `json:"name"`
+	Response any    `json:"response"`
+}
+
+type geminiGenerationConfig struct {
+	Temperature        *float64 `json:"temperature"`
+	TopP               *float64 `json:"topP"`
+	TopK               float64  `json:"topK"`
+	MaxOutputTokens    int      `json:"maxOutputTokens"`
+	CandidateCount     int      `json:"candidateCount"`
+	StopSequences      []string `json:"stopSequences"`
+	PresencePenalty    *float64 `json:"presencePenalty"`
+	FrequencyPenalty   *float64 `json:"frequencyPenalty"`
+	Seed               float64  `json:"seed"`
+	ResponseMimeType   string   `json:"responseMimeType"`
+	ResponseSchema     any      `json:"responseSchema"`
+	ResponseJSONSchema any      `json:"responseJsonSchema"`
+	ThinkingConfig     *struct {
+		ThinkingBudget *int   `json:"thinkingBudget"`
+		ThinkingLevel  string `json:"thinkingLevel"`
+	} `json:"thinkingConfig"`
+}
+
+type geminiTool struct {
+	FunctionDeclarations      []geminiFunctionDeclaration `json:"functionDeclarations"`
+	FunctionDeclarationsSnake []geminiFunctionDeclaration `json:"function_declarations"`
+}
+
+type geminiFunctionDeclaration struct {
+	Name                 string `json:"name"`
+	Description          string `json:"description,omitempty"`
+	Parameters           any    `json:"parameters,omitempty"`
+	ParametersJSONSchema any    `json:"parametersJsonSchema,omitempty"`
+}
+
+type geminiToolConfig struct {
+	FunctionCallingConfig *struct {
+		Mode                 string   `json:"mode"`
+		AllowedFunctionNames []string `json:"allowedFunctionNames"`
+	} `json:"functionCallingConfig"`
+}
+
+// ChatRequestFromGemini converts a generateContent body into a chat completions
+// request for modelName.
+func ChatRequestFromGemini(raw []byte, modelName string, stream bool) (*relaymodel.GeneralOpenAIRequest, error) {
+	request := &geminiInboundRequest{}
+	if err := json.Unmarshal(raw, request); err != nil {
+		return nil, err
+	}
+	if len(request.GenerateContentRequest) > 0 && len(request.Contents) == 0 {
+		return ChatRequestFromGemini(request.GenerateContentRequest, modelName, stream)
+	}
+	result := &relaymodel.GeneralOpenAIRequest{
+		Model:  strings.TrimSpace(modelName),
+		Stream: stream,
+	}
+	if stream {
+		result.StreamOptions = &relaymodel.StreamOptions{IncludeUsage: true}
+	}
+	systemInstruction := request.SystemInstruction
+	if systemInstruction == nil {
+		systemInstruction = request.SystemInstructionSnake
+	}
+	if systemInstruction != nil {
+		if text := geminiPartsText(systemInstruction.Parts); text != "" {
+			result.Messages = append(result.Messages, relaymodel.Message{Role: "system", Content: text})
+		}
+	}
+	callIDs := map[string][]string{}
+	for _, content := range request.Contents {
+		result.Messages = append(result.Messages, chatMessagesFromGeminiContent(content, callIDs)...)
+	}
+	config := request.GenerationConfig
+	if config == nil {
+		config = request.GenerationConfigSnake
+	}
+	if config != nil {
+		applyGeminiGenerationConfig(result, config)
+	}
+	for _, tool := range request.Tools {
+		declarations := tool.FunctionDeclarations
+		if len(declarations) == 0 {
+			declarations = tool.FunctionDeclarationsSnake
+		}
+		for _, declaration := range declarations {
+			parameters := declaration.Parameters
+			if parameters == nil {
+				parameters = declaration.ParametersJSONSchema
+			}
+			result.Tools = append(result.Tools, relaymodel.Tool{
+				Type: "function",
+				Function: relaymodel.Function{
+					Name:        declaration.Name,
+					Description: declaration.Description,
+					Parameters:  parameters,
+				},
+			})
+		}
+	}
+	toolConfig := request.ToolConfig
+	if toolConfig == nil {
+		toolConfig = request.ToolConfigSnake
+	}
+	if len(result.Tools) > 0 && toolConfig != nil && toolConfig.FunctionCallingConfig != nil {
+		result.ToolChoice = chatToolChoiceFromGemini(toolConfig.FunctionCallingConfig.Mode, toolConfig.FunctionCallingConfig.AllowedFunctionNames)
+	}
+	return result, nil
+}
+
+func chatMessagesFromGeminiContent(content geminiContent, callIDs map[string][]string) []relaymodel.Message {
+	role := "user"
+	if content.Role == "model" {
+		role = "assistant"
+	}
+	result := make([]relaymodel.Message, 0, 1)
+	parts := make([]any, 0, len(content.Parts))
+	var (
+		textBuilder strings.Builder
+		reasoning   strings.Builder
+		toolCalls   []relaymodel.Tool
+	)
+	for _, part := range content.Parts {
+		if call := firstFunctionCall(part.FunctionCall, part.FunctionCallSnk); call != nil {
+			arguments, err := json.Marshal(call.Args)
+			if err != nil || call.Args == nil {
+				arguments = []byte("{}")
+			}
+			id := toolCallID(call.ID)
+			callIDs[call.Name] = append(callIDs[call.Name], id)
+			toolCalls = append(toolCalls, relaymodel.Tool{
+				Id:   id,
+				Type: "function",
+				Function: relaymodel.Function{
+					Name:      call.Name,
+					Arguments: string(arguments),
+				},
+			})
+			continue
+		}
+		if response := firstFunctionResult(part.FunctionResponse, part.FunctionRespSnk); response != nil {
+			// gemini pairs results with calls by name, so pop the oldest
+			// call id issued for that function
+			id := strings.TrimSpace(response.ID)
+			if queue := callIDs[response.Name]; len(queue) > 0 {
+				if id == "" {
+					id = queue[0]
+				}
+				callIDs[response.Name] = queue[1:]
+			}
+			result = append(result, relaymodel.Message{
+				Role:       "tool",
+				ToolCallId: toolCallID(id),
+				Content:    toolResultText(response.Response),
+			})
+			continue
+		}
+		if part.Thought {
+			reasoning.WriteString(part.Text)
+			continue
+		}
+		if part.Text != "" {
+			if role == "assistant" {
+				textBuilder.WriteString(part.Text)
+				continue
+			}
+			parts = append(parts, map[string]any{"type": relaymodel.ContentTypeText, "text": part.Text})
+			continue
+		}
+		if url := geminiPartURL(part); url != "" {
+			parts = append(parts, map[string]any{
+				"type":      relaymodel.ContentTypeImageURL,
+				"image_url": map[string]any{"url": url},
+			})
+		}
+	}
+	if role == "assistant" {
+		if textBuilder.Len() == 0 && len(toolCalls) == 0 && reasoning.Len() == 0 {
+			return result
+		}
+		message := relaymodel.Message{Role: role, ToolCalls: toolCalls}
+		if textBuilder.Len() > 0 {
+			message.Content = textBuilder.String()
+		}
+		if reasoning.Len() > 0 {
+			message.ReasoningContent = reasoning.String()
+		}
+		return append(result, message)
+	}
+	if len(parts) > 0 {
+		result = append(result, relaymodel.Message{Role: role, Content: parts})
+	}
+	return result
+}
+
+func firstFunctionCall(values ...*geminiFunctionCall) *geminiFunctionCall {
+	for _, value := range values {
+		if value != nil {
+			return value
+		}
+	}
+	return nil
+}
+
+func firstFunctionResult(values ...*geminiFunctionResult) *geminiFunctionResult {
+	for _, value := range values {
+		if value != nil {
+			return value
+		}
+	}
+	return nil
+}
+
+func geminiPartURL(part geminiPart) string {
+	blob := part.InlineData
+	if blob == nil {
+		blob = part.InlineDataSnake
+	}
+	if blob != nil && blob.Data != "" {
+		mimeType := blob.MimeType
+		if mimeType == "" {
+			mimeType = blob.MimeTypeSnake
+		}
+		return "data:" + mimeType + ";base64," + blob.Data
+	}
+	file := part.FileData
+	if file == nil {
+		file = part.FileDataSnake
+	}
+	if file != nil {
+		if file.FileURI != "" {
+			return file.FileURI
+		}
+		return file.FileURISnake
+	}
+	return ""
+}
+
+func geminiPartsText(parts []geminiPart) string {
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part.Text != "" && !part.Thought {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+func applyGeminiGenerationConfig(request *relaymodel.GeneralOpenAIRequest, config *geminiGenerationConfig) {
+	request.Temperature = config.Temperature
+	request.TopP = config.TopP
+	request.TopK = int(config.TopK)
+	request.MaxTokens = config.MaxOutputTokens
+	request.PresencePenalty = config.PresencePenalty
+	request.FrequencyPenalty = config.FrequencyPenalty
+	request.Seed = config.Seed
+	if config.CandidateCount > 1 {
+		request.N = config.CandidateCount
+	}
+	if len(config.StopSequences) > 0 {
+		request.Stop = config.StopSequences
+	}
+	if config.ResponseMimeType == "application/json" {
+		schema := config.ResponseJSONSchema
+		if schema == nil {
+			schema = config.ResponseSchema
+		}
+		if object, ok := schema.(map[string]any); ok && len(object) > 0 {
+			request.ResponseFormat = &relaymodel.ResponseFormat{
+				Type:       "json_schema",
+				JsonSchema: &relaymodel.JSONSchema{Name: "response", Schema: object},
+			}
+		} else {
+			request.ResponseFormat = &relaymodel.ResponseFormat{Type: "json_object"}
+		}
+	}
+	if config.ThinkingConfig != nil {
+		effort := strings.ToLower(strings.TrimSpace(config.ThinkingConfig.ThinkingLevel))
+		if effort == "" && config.ThinkingConfig.ThinkingBudget != nil && *config.ThinkingConfig.ThinkingBudget > 0 {
+			effort = reasoningEffortFromBudget(*config.ThinkingConfig.ThinkingBudget)
+		}
+		if effort != "" {
+			request.ReasoningEffort = &effort
+		}
+	}
+}
+
+func chatToolChoiceFromGemini(mode string, allowed []string) any {
+	switch strings.ToUpper(strings.TrimSpace(mode)) {
+	case "NONE":
+		return "none"
+	case "ANY":
+		if len(allowed) == 1 {
+			return map[string]any{
+				"type":     "function",
+				"function": map[string]any{"name": allowed[0]},
+			}
+		}
+		return "required"
+	default:
+		return "auto"
+	}
+}
+
+type geminiResponse struct {
+	Candidates    []geminiCandidate    `json:"candidates"`
+	UsageMetadata *geminiUsageMetadata `json:"usageMetadata,omitempty"`
+	ModelVersion  string               `json:"modelVersion,omitempty"`
+	ResponseID    string               `json:"responseId,omitempty"`
+}
+
+type geminiCandidate struct {
+	Content      geminiOutputContent `json:"content"`
+	FinishReason string              `json:"finishReason,omitempty"`
+	Index        int                 `json:"index"`
+}
+
+type geminiOutputContent struct {
+	Role  string `json:"role"`
+	Parts []any  `json:"parts"`
+}
+
+type geminiUsageMetadata struct {
+	PromptTokenCount        int `json:"promptTokenCount"`
+	CandidatesTokenCount    int `json:"candidatesTokenCount"`
+	TotalTokenCount         int `json:"totalTokenCount"`
+	ThoughtsTokenCount      int `json:"thoughtsTokenCount,omitempty"`
+	CachedContentTokenCount int `json:"cachedContentTokenCount,omitempty"`
+}
+
+func geminiFinishReasonFromChat(reason string) string {
+	switch reason {
+	case "length":
+		return "MAX_TOKENS"
+	case "content_filter":
+		return "SAFETY"
+	default:
+		return "STOP"
+	}
+}
+
+func geminiUsageFromChat(usage *relaymodel.Usage) *geminiUsageMetadata {
+	if usage == nil {
+		return nil
+	}
+	metadata := &geminiUsageMetadata{
+		PromptTokenCount:     usage.PromptTokens,
+		CandidatesTokenCount: usage.CompletionTokens,
+		TotalTokenCount:      usage.TotalTokens,
+	}
+	if usage.CompletionTokensDetails != nil {
+		metadata.ThoughtsTokenCount = usage.CompletionTokensDetails.ReasoningTokens
+	}
+	if usage.PromptTokensDetails != nil {
+		metadata.CachedContentTokenCount = usage.PromptTokensDetails.CachedTokens
+	}
+	if metadata.TotalTokenCount == 0 {
+		metadata.TotalTokenCount = metadata.PromptTokenCount + metadata.CandidatesTokenCount
+	}
+	return metadata
+}
+
+func geminiFunctionCallPart(name string, arguments any) map[string]any {
+	return map[string]any{"functionCall": map[string]any{
+		"name": name,
+		"args": toolArgumentsObject(arguments),
+	}}
+}
+
+func geminiResponseFromCompletion(completion *chatCompletion) *geminiResponse {
+	response := &geminiResponse{
+		Candidates:    make([]geminiCandidate, 0, len(completion.Choices)),
+		UsageMetadata: geminiUsageFromChat(completion.Usage),
+		ModelVersion:  completion.Model,
+		ResponseID:    geminiResponseID(completion.ID),
+	}
+	for _, choice := range completion.Choices {
+		parts := make([]any, 0, 2)
+		if choice.Message.ReasoningContent != "" {
+			parts = append(parts, map[string]any{"text": choice.Message.ReasoningContent, "thought": true})
+		}
+		if choice.Message.Content != nil && *choice.Message.Content != "" {
+			parts = append(parts, map[string]any{"text": *choice.Message.Content})
+		}
+		for _, call := range choice.Message.ToolCalls {
+			parts = append(parts, geminiFunctionCallPart(call.Function.Name, call.Function.Arguments))
+		}
+		response.Candidates = append(response.Candidates, geminiCandidate{
+			Content:      geminiOutputContent{Role: "model", Parts: parts},
+			FinishReason: geminiFinishReasonFromChat(choice.FinishReason),
+			Index:        choice.Index,
+		})
+	}
+	return response
+}
+
+func geminiResponseID(id string) string {
+	if trimmed := strings.TrimSpace(id); trimmed != "" {
+		return trimmed
+	}
+	return random.GetUUID()
+}
+
+type geminiStreamTool struct {
+	name      string
+	arguments strings.Builder
+}
+
+type geminiStreamEncoder struct {
+	id           string
+	model        string
+	tools        map[int]*geminiStreamTool
+	toolOrder    []int
+	finishReason string
+	usage        *relaymodel.Usage
+	closed       bool
+}
+
+func (e *geminiStreamEncoder) chunk(parts []any, finishReason string) map[string]any {
+	candidate := map[string]any{
+		"content": map[string]any{"role": "model", "parts": parts},
+		"index":   0,
+	}
+	if finishReason != "" {
+		candidate["finishReason"] = finishReason
+	}
+	return map[string]any{
+		"candidates":   []any{candidate},
+		"modelVersion": e.model,
+		"responseId":   e.id,
+	}
+}
+
+func (e *geminiStreamEncoder) Encode(event streamEvent) []sseFrame {
+	if e.id == "" {
+		e.id = geminiResponseID(event.ID)
+	}
+	if e.model == "" {
+		e.model = event.Model
+	}
+	switch event.Kind {
+	case streamEventText:
+		return []sseFrame{{Data: e.chunk([]any{map[string]any{"text": event.Text}}, "")}}
+	case streamEventReasoning:
+		return []sseFrame{{Data: e.chunk([]any{map[string]any{"text": event.Text, "thought": true}}, "")}}
+	case streamEventToolStart:
+		if e.tools == nil {
+			e.tools = map[int]*geminiStreamTool{}
+		}
+		if _, ok := e.tools[event.ToolIndex]; !ok {
+			e.toolOrder = append(e.toolOrder, event.ToolIndex)
+		}
+		e.tools[event.ToolIndex] = &geminiStreamTool{name: event.ToolName}
+	case streamEventToolArguments:
+		// gemini streams function calls whole, so arguments are buffered
+		// until the stream closes
+		if tool, ok := e.tools[event.ToolIndex]; ok {
+			tool.arguments.WriteString(event.Text)
+		}
+	case streamEventFinish:
+		e.finishReason = event.FinishReason
+	case streamEventUsage:
+		e.usage = event.Usage
+	case streamEventError:
+		return []sseFrame{{Data: GeminiErrorBody(http.StatusInternalServerError, event.Error)}}
+	}
+	return nil
+}
+
+func (e *geminiStreamEncoder) Close() []sseFrame {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	parts := make([]any, 0, len(e.toolOrder))
+	for _, index := range e.toolOrder {
+		tool := e.tools[index]
+		parts = append(parts, geminiFunctionCallPart(tool.name, tool.arguments.String()))
+	}
+	final := e.chunk(parts, geminiFinishReasonFromChat(e.finishReason))
+	if usage := geminiUsageFromChat(e.usage); usage != nil {
+		final["usageMetadata"] = usage
+	}
+	return []sseFrame{{Data: final}}
+}
+
+type geminiEmbeddingInboundRequest struct {
+	Model                string        `json:"model"`
+	Content              geminiContent `json:"content"`
+	OutputDimensionality int           `json:"outputDimensionality"`
+}
+
+// EmbeddingRequestFromGemini converts embedContent and batchEmbedContents
+// bodies into an OpenAI embeddings request.
+func EmbeddingRequestFromGemini(raw []byte, modelName string, batch bool) (*relaymodel.GeneralOpenAIRequest, error) {
+	requests := make([]geminiEmbeddingInboundRequest, 0, 1)
+	if batch {
+		payload := struct {
+			Requests []geminiEmbeddingInboundRequest `json:"requests"`
+		}{}
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return nil, err
+		}
+		requests = payload.Requests
+	} else {
+		request := geminiEmbeddingInboundRequest{}
+		if err := json.Unmarshal(raw, &request); err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("embedding request has no content")
+	}
+	inputs := make([]any, 0, len(requests))
+	for _, request := range requests {
+		inputs = append(inputs, geminiPartsText(request.Content.Parts))
+	}
+	result := &relaymodel.GeneralOpenAIRequest{
+		Model:      strings.TrimSpace(modelName),
+		Dimensions: requests[0].OutputDimensionality,
+	}
+	if batch {
+		result.Input = inputs
+	} else {
+		result.Input = inputs[0]
+	}
+	return result, nil
+}
+
+func geminiEmbeddingResponse(raw []byte, downstreamMode int) ([]byte, error) {
+	payload := struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+		Error *relaymodel.Error `json:"error"`
+	}{}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, err
+	}
+	if payload.Error != nil && payload.Error.Message != "" {
+		return nil, fmt.Errorf("upstream error: %s", payload.Error.Message)
+	}
+	embeddings := make([]map[string]any, len(payload.Data))
+	for position, item := range payload.Data {
+		index := item.Index
+		if index < 0 || index >= len(embeddings) || embeddings[index] != nil {
+			index = position
+		}
+		embeddings[index] = map[string]any{"values": item.Embedding}
+	}
+	if downstreamMode == relaymode.GeminiBatchEmbedContents {
+		return json.Marshal(map[string]any{"embeddings": embeddings})
+	}
+	if len(embeddings) == 0 {
+		return nil, fmt.Errorf("upstream returned no embedding")
+	}
+	return json.Marshal(map[string]any{"embedding": embeddings[0]})
+}
+
+func isGeminiMode(mode int) bool {
+	switch mode {
+	case relaymode.GeminiGenerateContent, relaymode.GeminiCountTokens, relaymode.GeminiEmbedContent, relaymode.GeminiBatchEmbedContents:
+		return true
+	default:
+		return false
+	}
+}
+
+func geminiErrorStatus(statusCode int) string {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return "INVALID_ARGUMENT"
+	case http.StatusUnauthorized:
+		return "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	case http.StatusGatewayTimeout:
+		return "DEADLINE_EXCEEDED"
+	default:
+		return "INTERNAL"
+	}
+}
+
+// GeminiErrorBody builds a Google API error payload.
+func GeminiErrorBody(statusCode int, message string) map[string]any {
+	return map[string]any{"error": map[string]any{
+		"code":    statusCode,
+		"message": message,
+		"status":  geminiErrorStatus(statusCode),
+	}}
+}
+
+// convertGeminiError rewrites an OpenAI-style error body into the Google API
+// error shape so GenAI SDKs surface the message.
+func convertGeminiError(raw []byte, statusCode int) []byte {
+	payload := struct {
+		Error *relaymodel.Error `json:"error"`
+	}{}
+	message := strings.TrimSpace(string(raw))
+	if err := json.Unmarshal(raw, &payload); err == nil && payload.Error != nil && payload.Error.Message != "" {
+		message = payload.Error.Message
+	}
+	encoded, err := json.Marshal(GeminiErrorBody(statusCode, message))
+	if err != nil {
+		return raw
+	}
+	return encoded
+}
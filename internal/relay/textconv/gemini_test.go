@@ -0,0 +1,144 @@
+package textconv
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yeying-community/router/internal/relay/relaymode"
+)
+
+func TestChatRequestFromGeminiConvertsContentsToolsAndConfig(t *testing.T) {
+	raw := []byte(`{
+		"systemInstruction":{"parts":[{"text":"be brief"}]},
+		"contents":[
+			{"role":"user","parts":[{"text":"weather?"},{"inlineData":{"mimeType":"image/png","data":"AAA"}}]},
+			{"role":"model","parts":[{"functionCall":{"name":"get_weather","args":{"city":"Paris"}}}]},
+			{"role":"user","parts":[{"functionResponse":{"name":"get_weather","response":{"temp":21}}}]}
+		],
+		"tools":[{"functionDeclarations":[{"name":"get_weather","parameters":{"type":"object"}}]}],
+		"toolConfig":{"functionCallingConfig":{"mode":"ANY"}},
+		"generationConfig":{"temperature":0.2,"maxOutputTokens":256,"stopSequences":["END"],"responseMimeType":"application/json"}
+	}`)
+
+	req, err := ChatRequestFromGemini(raw, "gemini-2.5-flash", true)
+	if err != nil {
+		t.Fatalf("ChatRequestFromGemini returned error: %v", err)
+	}
+	if req.Model != "gemini-2.5-flash" || !req.Stream || req.StreamOptions == nil || !req.StreamOptions.IncludeUsage {
+		t.Fatalf("req = %#v, want streaming gemini-2.5-flash with usage", req)
+	}
+	if len(req.Messages) != 4 {
+		t.Fatalf("len(req.Messages) = %d, want 4", len(req.Messages))
+	}
+	if req.Messages[0].Role != "system" || req.Messages[0].StringContent() != "be brief" {
+		t.Fatalf("req.Messages[0] = %#v, want system prompt", req.Messages[0])
+	}
+	parts := req.Messages[1].ParseContent()
+	if len(parts) != 2 || parts[1].ImageURL == nil || parts[1].ImageURL.Url != "data:image/png;base64,AAA" {
+		t.Fatalf("user content = %#v, want text and data URL image", parts)
+	}
+	calls := req.Messages[2].ToolCalls
+	if len(calls) != 1 || calls[0].Function.Arguments != `{"city":"Paris"}` {
+		t.Fatalf("assistant tool calls = %#v, want get_weather call", calls)
+	}
+	if req.Messages[3].Role != "tool" || req.Messages[3].ToolCallId != calls[0].Id {
+		t.Fatalf("req.Messages[3] = %#v, want tool result paired with %s", req.Messages[3], calls[0].Id)
+	}
+	if len(req.Tools) != 1 || req.ToolChoice != "required" {
+		t.Fatalf("tools = %#v choice = %#v, want one tool and required", req.Tools, req.ToolChoice)
+	}
+	if req.MaxTokens != 256 || req.Temperature == nil || *req.Temperature != 0.2 {
+		t.Fatalf("generation config not applied: max_tokens=%d temperature=%v", req.MaxTokens, req.Temperature)
+	}
+	if req.ResponseFormat == nil || req.ResponseFormat.Type != "json_object" {
+		t.Fatalf("req.ResponseFormat = %#v, want json_object", req.ResponseFormat)
+	}
+}
+
+func TestConvertResponseChatToGemini(t *testing.T) {
+	raw := []byte(`{"id":"chatcmpl-1","model":"gpt-5","choices":[{"index":0,"message":{"role":"assistant","content":"hi","tool_calls":[{"id":"call_1","type":"function","function":{"name":"f","arguments":"{\"a\":1}"}}]},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}`)
+
+	converted, err := ConvertResponse(raw, relaymode.ChatCompletions, relaymode.GeminiGenerateContent)
+	if err != nil {
+		t.Fatalf("ConvertResponse returned error: %v", err)
+	}
+	response := geminiResponse{}
+	if err := json.Unmarshal(converted, &response); err != nil {
+		t.Fatalf("unmarshal converted response: %v", err)
+	}
+	if len(response.Candidates) != 1 || len(response.Candidates[0].Content.Parts) != 2 {
+		t.Fatalf("candidates = %s, want one candidate with text and functionCall", converted)
+	}
+	if response.Candidates[0].FinishReason != "STOP" {
+		t.Fatalf("finishReason = %q, want STOP", response.Candidates[0].FinishReason)
+	}
+	if response.UsageMetadata == nil || response.UsageMetadata.TotalTokenCount != 5 {
+		t.Fatalf("usageMetadata = %#v, want total 5", response.UsageMetadata)
+	}
+	if !strings.Contains(string(converted), `"functionCall":{"args":{"a":1},"name":"f"}`) {
+		t.Fatalf("converted = %s, want functionCall part", converted)
+	}
+}
+
+func TestConvertResponseEmbeddingsToGeminiBatch(t *testing.T) {
+	raw := []byte(`{"object":"list","data":[{"index":1,"embedding":[0.3]},{"index":0,"embedding":[0.1,0.2]}]}`)
+
+	converted, err := ConvertResponse(raw, relaymode.Embeddings, relaymode.GeminiBatchEmbedContents)
+	if err != nil {
+		t.Fatalf("ConvertResponse returned error: %v", err)
+	}
+	if string(converted) != `{"embeddings":[{"values":[0.1,0.2]},{"values":[0.3]}]}` {
+		t.Fatalf("converted = %s", converted)
+	}
+}
+
+func TestResponseWriterStreamsChatAsGemini(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	writer := NewResponseWriter(c.Writer, relaymode.ChatCompletions, relaymode.GeminiGenerateContent, true)
+
+	writer.WriteHeader(http.StatusOK)
+	_, _ = writer.WriteString("data: {\"id\":\"chatcmpl-1\",\"model\":\"gpt-5\",\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\n\n")
+	_, _ = writer.WriteString("data: {\"id\":\"chatcmpl-1\",\"choices\":[{\"delta\":{},\"finish_reason\":\"length\"}]}\n\n")
+	_, _ = writer.WriteString("data: {\"id\":\"chatcmpl-1\",\"choices\":[],\"usage\":{\"prompt_tokens\":1,\"completion_tokens\":1,\"total_tokens\":2}}\n\ndata: [DONE]\n\n")
+	if err := writer.Finish(); err != nil {
+		t.Fatalf("Finish returned error: %v", err)
+	}
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, `"parts":[{"text":"Hel"}]`) {
+		t.Fatalf("body = %s, want text chunk", body)
+	}
+	if !strings.Contains(body, `"finishReason":"MAX_TOKENS"`) || !strings.Contains(body, `"totalTokenCount":2`) {
+		t.Fatalf("body = %s, want final chunk with finish reason and usage", body)
+	}
+	if strings.Contains(body, "[DONE]") {
+		t.Fatalf("body = %s, gemini streams have no [DONE] marker", body)
+	}
+}
+
+func TestResponseWriterConvertsErrorsForGemini(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	writer := NewResponseWriter(c.Writer, relaymode.ChatCompletions, relaymode.GeminiGenerateContent, true)
+
+	writer.WriteHeader(http.StatusUnauthorized)
+	_, _ = writer.WriteString(`{"error":{"message":"bad token","type":"one_api_error"}}`)
+	if err := writer.Finish(); err != nil {
+		t.Fatalf("Finish returned error: %v", err)
+	}
+
+	if recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", recorder.Code)
+	}
+	if got := recorder.Body.String(); got != `{"error":{"code":401,"message":"bad token","status":"UNAUTHENTICATED"}}` {
+		t.Fatalf("body = %s", got)
+	}
+}
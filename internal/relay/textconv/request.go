@@ -0,0 +1,1061 @@
+package textconv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	relaymodel "github.com/yeying-community/router/internal/relay/model"
+	"github.com/yeying-community/router/internal/relay/relaymode"
+)
+
+var ErrStatefulResponsesRequest = errors.New("previous_response_id requires a responses upstream")
+
+func Supported(mode int) bool {
+	return mode == relaymode.ChatCompletions || mode == relaymode.Messages || mode == relaymode.Responses
+}
+
+func NeedsConversion(downstreamMode int, upstreamMode int) bool {
+	return downstreamMode != upstreamMode && Supported(downstreamMode) && Supported(upstreamMode)
+}
+
+// ConvertRequest translates a downstream request body into the dialect spoken by
+// the upstream. Chat completions is used as the pivot, and the returned chat
+// request is what adaptors receive when the upstream speaks chat completions.
+func ConvertRequest(raw []byte, downstreamMode int, upstreamMode int, modelName string) ([]byte, *relaymodel.GeneralOpenAIRequest, error) {
+	if !NeedsConversion(downstreamMode, upstreamMode) {
+		return nil, nil, fmt.Errorf("unsupported text conversion: downstream=%d upstream=%d", downstreamMode, upstreamMode)
+	}
+	var (
+		chatRequest *relaymodel.GeneralOpenAIRequest
+		err         error
+	)
+	switch downstreamMode {
+	case relaymode.Messages:
+		chatRequest, err = ChatRequestFromMessages(raw)
+	case relaymode.Responses:
+		chatRequest, err = ChatRequestFromResponses(raw)
+	default:
+		chatRequest = &relaymodel.GeneralOpenAIRequest{}
+		err = json.Unmarshal(raw, chatRequest)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if trimmed := strings.TrimSpace(modelName); trimmed != "" {
+		chatRequest.Model = trimmed
+	}
+	var upstreamBody any
+	switch upstreamMode {
+	case relaymode.Messages:
+		upstreamBody = MessagesRequestFromChat(chatRequest)
+	case relaymode.Responses:
+		upstreamBody = ResponsesRequestFromChat(chatRequest)
+	default:
+		if chatRequest.Stream {
+			chatRequest.StreamOptions = &relaymodel.StreamOptions{IncludeUsage: true}
+		}
+		upstreamBody = chatRequest
+	}
+	encoded, err := json.Marshal(upstreamBody)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encoded, chatRequest, nil
+}
+
+type messagesInboundRequest struct {
+	Model         string                   `json:"model"`
+	Messages      []messagesInboundMessage `json:"messages"`
+	System        json.RawMessage          `json:"system,omitempty"`
+	MaxTokens     int                      `json:"max_tokens,omitempty"`
+	StopSequences []string                 `json:"stop_sequences,omitempty"`
+	Stream        bool                     `json:"stream,omitempty"`
+	Temperature   *float64                 `json:"temperature,omitempty"`
+	TopP          *float64                 `json:"top_p,omitempty"`
+	TopK          int                      `json:"top_k,omitempty"`
+	Tools         []messagesTool           `json:"tools,omitempty"`
+	ToolChoice    *messagesToolChoice      `json:"tool_choice,omitempty"`
+	Thinking      *messagesThinking        `json:"thinking,omitempty"`
+	Metadata      *messagesMetadata        `json:"metadata,omitempty"`
+}
+
+type messagesInboundMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+type messagesRequest struct {
+	Model         string              `json:"model"`
+	Messages      []messagesMessage   `json:"messages"`
+	System        []messagesBlock     `json:"system,omitempty"`
+	MaxTokens     int                 `json:"max_tokens"`
+	StopSequences []string            `json:"stop_sequences,omitempty"`
+	Stream        bool                `json:"stream,omitempty"`
+	Temperature   *float64            `json:"temperature,omitempty"`
+	TopP          *float64            `json:"top_p,omitempty"`
+	TopK          int                 `json:"top_k,omitempty"`
+	Tools         []messagesTool      `json:"tools,omitempty"`
+	ToolChoice    *messagesToolChoice `json:"tool_choice,omitempty"`
+	Thinking      *messagesThinking   `json:"thinking,omitempty"`
+	Metadata      *messagesMetadata   `json:"metadata,omitempty"`
+}
+
+type messagesMessage struct {
+	Role    string          `json:"role"`
+	Content []messagesBlock `json:"content"`
+}
+
+type messagesBlock struct {
+	Type      string          `json:"type"`
+	Text      *string         `json:"text,omitempty"`
+	Source    *messagesSource `json:"source,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     any             `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   any             `json:"content,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+	Thinking  *string         `json:"thinking,omitempty"`
+	Signature string          `json:"signature,omitempty"`
+}
+
+type messagesSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type messagesTool struct {
+	Type        string `json:"type,omitempty"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	InputSchema any    `json:"input_schema,omitempty"`
+}
+
+type messagesToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+type messagesThinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens,omitempty"`
+}
+
+type messagesMetadata struct {
+	UserID string `json:"user_id,omitempty"`
+}
+
+const defaultMessagesMaxTokens = 4096
+
+func ChatRequestFromMessages(raw []byte) (*relaymodel.GeneralOpenAIRequest, error) {
+	request := &messagesInboundRequest{}
+	if err := json.Unmarshal(raw, request); err != nil {
+		return nil, err
+	}
+	result := &relaymodel.GeneralOpenAIRequest{
+		Model:       strings.TrimSpace(request.Model),
+		MaxTokens:   request.MaxTokens,
+		Stream:      request.Stream,
+		Temperature: request.Temperature,
+		TopP:        request.TopP,
+		TopK:        request.TopK,
+	}
+	if len(request.StopSequences) > 0 {
+		result.Stop = request.StopSequences
+	}
+	if request.Metadata != nil {
+		result.User = strings.TrimSpace(request.Metadata.UserID)
+	}
+	if request.Thinking != nil && request.Thinking.Type == "enabled" {
+		effort := reasoningEffortFromBudget(request.Thinking.BudgetTokens)
+		result.ReasoningEffort = &effort
+	}
+	if system := messagesSystemText(request.System); system != "" {
+		result.Messages = append(result.Messages, relaymodel.Message{Role: "system", Content: system})
+	}
+	for _, item := range request.Messages {
+		messages, err := chatMessagesFromMessagesItem(item)
+		if err != nil {
+			return nil, err
+		}
+		result.Messages = append(result.Messages, messages...)
+	}
+	for _, tool := range request.Tools {
+		if tool.Type != "" && tool.Type != "custom" {
+			continue
+		}
+		result.Tools = append(result.Tools, relaymodel.Tool{
+			Type: "function",
+			Function: relaymodel.Function{
+				Name:        strings.TrimSpace(tool.Name),
+				Description: strings.TrimSpace(tool.Description),
+				Parameters:  tool.InputSchema,
+			},
+		})
+	}
+	if request.ToolChoice != nil && len(result.Tools) > 0 {
+		switch request.ToolChoice.Type {
+		case "any":
+			result.ToolChoice = "required"
+		case "none":
+			result.ToolChoice = "none"
+		case "tool":
+			result.ToolChoice = map[string]any{
+				"type":     "function",
+				"function": map[string]any{"name": request.ToolChoice.Name},
+			}
+		default:
+			result.ToolChoice = "auto"
+		}
+	}
+	return result, nil
+}
+
+func messagesSystemText(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return strings.TrimSpace(text)
+	}
+	var blocks []messagesBlock
+	if err := json.Unmarshal(raw, &blocks); err != nil {
+		return ""
+	}
+	parts := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		if block.Type == "text" && block.Text != nil && strings.TrimSpace(*block.Text) != "" {
+			parts = append(parts, *block.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func chatMessagesFromMessagesItem(item messagesInboundMessage) ([]relaymodel.Message, error) {
+	role := strings.TrimSpace(item.Role)
+	var text string
+	if err := json.Unmarshal(item.Content, &text); err == nil {
+		return []relaymodel.Message{{Role: role, Content: text}}, nil
+	}
+	var blocks []messagesBlock
+	if err := json.Unmarshal(item.Content, &blocks); err != nil {
+		return nil, fmt.Errorf("invalid content of %s message: %w", role, err)
+	}
+	result := make([]relaymodel.Message, 0, 1)
+	parts := make([]any, 0, len(blocks))
+	var (
+		textBuilder strings.Builder
+		reasoning   strings.Builder
+		toolCalls   []relaymodel.Tool
+	)
+	for _, block := range blocks {
+		switch block.Type {
+		case "text":
+			if block.Text == nil {
+				continue
+			}
+			if role == "assistant" {
+				textBuilder.WriteString(*block.Text)
+				continue
+			}
+			parts = append(parts, map[string]any{"type": relaymodel.ContentTypeText, "text": *block.Text})
+		case "image", "document":
+			if url := messagesSourceURL(block.Source); url != "" {
+				parts = append(parts, map[string]any{
+					"type":      relaymodel.ContentTypeImageURL,
+					"image_url": map[string]any{"url": url},
+				})
+			}
+		case "thinking":
+			if block.Thinking != nil {
+				reasoning.WriteString(*block.Thinking)
+			}
+		case "tool_use":
+			arguments, err := json.Marshal(block.Input)
+			if err != nil || block.Input == nil {
+				arguments = []byte("{}")
+			}
+			toolCalls = append(toolCalls, relaymodel.Tool{
+				Id:   block.ID,
+				Type: "function",
+				Function: relaymodel.Function{
+					Name:      block.Name,
+					Arguments: string(arguments),
+				},
+			})
+		case "tool_result":
+			result = append(result, relaymodel.Message{
+				Role:       "tool",
+				ToolCallId: block.ToolUseID,
+				Content:    toolResultText(block.Content),
+			})
+		}
+	}
+	if role == "assistant" {
+		if textBuilder.Len() == 0 && len(toolCalls) == 0 && reasoning.Len() == 0 {
+			return result, nil
+		}
+		message := relaymodel.Message{Role: role, ToolCalls: toolCalls}
+		if textBuilder.Len() > 0 {
+			message.Content = textBuilder.String()
+		}
+		if reasoning.Len() > 0 {
+			message.ReasoningContent = reasoning.String()
+		}
+		return append(result, message), nil
+	}
+	if len(parts) > 0 {
+		result = append(result, relaymodel.Message{Role: role, Content: parts})
+	}
+	return result, nil
+}
+
+func messagesSourceURL(source *messagesSource) string {
+	if source == nil {
+		return ""
+	}
+	switch source.Type {
+	case "base64":
+		if source.Data == "" {
+			return ""
+		}
+		return "data:" + source.MediaType + ";base64," + source.Data
+	case "url":
+		return source.URL
+	default:
+		return ""
+	}
+}
+
+func toolResultText(content any) string {
+	switch value := content.(type) {
+	case string:
+		return value
+	case []any:
+		parts := make([]string, 0, len(value))
+		for _, item := range value {
+			block, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			if text, ok := block["text"].(string); ok {
+				parts = append(parts, text)
+			}
+		}
+		return strings.Join(parts, "\n")
+	case nil:
+		return ""
+	default:
+		encoded, _ := json.Marshal(value)
+		return string(encoded)
+	}
+}
+
+func reasoningEffortFromBudget(budget int) string {
+	switch {
+	case budget <= 0:
+		return "medium"
+	case budget <= 2048:
+		return "low"
+	case budget <= 8192:
+		return "medium"
+	default:
+		return "high"
+	}
+}
+
+func budgetFromReasoningEffort(effort string, maxTokens int) int {
+	var budget int
+	switch strings.ToLower(strings.TrimSpace(effort)) {
+	case "minimal", "low":
+		budget = 2048
+	case "high":
+		budget = 16384
+	default:
+		budget = 8192
+	}
+	if maxTokens > 0 && budget >= maxTokens {
+		budget = maxTokens / 2
+	}
+	return budget
+}
+
+func MessagesRequestFromChat(request *relaymodel.GeneralOpenAIRequest) *messagesRequest {
+	result := &messagesRequest{
+		Model:       request.Model,
+		MaxTokens:   chatMaxTokens(request),
+		Stream:      request.Stream,
+		Temperature: request.Temperature,
+		TopP:        request.TopP,
+		TopK:        request.TopK,
+	}
+	if result.MaxTokens <= 0 {
+		result.MaxTokens = defaultMessagesMaxTokens
+	}
+	result.StopSequences = stopSequences(request.Stop)
+	if strings.TrimSpace(request.User) != "" {
+		result.Metadata = &messagesMetadata{UserID: strings.TrimSpace(request.User)}
+	}
+	if request.ReasoningEffort != nil && strings.TrimSpace(*request.ReasoningEffort) != "" && *request.ReasoningEffort != "none" {
+		budget := budgetFromReasoningEffort(*request.ReasoningEffort, result.MaxTokens)
+		if budget >= 1024 {
+			result.Thinking = &messagesThinking{Type: "enabled", BudgetTokens: budget}
+			result.Temperature = nil
+			result.TopP = nil
+			result.TopK = 0
+		}
+	}
+	for _, tool := range request.Tools {
+		name := strings.TrimSpace(tool.Function.Name)
+		if name == "" {
+			continue
+		}
+		schema := tool.Function.Parameters
+		if schema == nil {
+			schema = map[string]any{"type": "object", "properties": map[string]any{}}
+		}
+		result.Tools = append(result.Tools, messagesTool{
+			Name:        name,
+			Description: tool.Function.Description,
+			InputSchema: schema,
+		})
+	}
+	if len(result.Tools) > 0 {
+		result.ToolChoice = messagesToolChoiceFromChat(request.ToolChoice)
+		if result.ToolChoice != nil && result.ToolChoice.Type == "none" {
+			result.Tools = nil
+			result.ToolChoice = nil
+		}
+	}
+	for _, message := range request.Messages {
+		switch message.Role {
+		case "system", "developer":
+			if text := message.StringContent(); text != "" {
+				result.System = append(result.System, messagesBlock{Type: "text", Text: stringPtr(text)})
+			}
+		case "tool":
+			block := messagesBlock{
+				Type:      "tool_result",
+				ToolUseID: message.ToolCallId,
+				Content:   message.StringContent(),
+			}
+			appendMessagesBlocks(result, "user", block)
+		case "assistant":
+			blocks := make([]messagesBlock, 0, 1+len(message.ToolCalls))
+			if reasoning, ok := message.ReasoningContent.(string); ok && reasoning != "" {
+				blocks = append(blocks, messagesBlock{Type: "thinking", Thinking: stringPtr(reasoning)})
+			}
+			if text := message.StringContent(); text != "" {
+				blocks = append(blocks, messagesBlock{Type: "text", Text: stringPtr(text)})
+			}
+			for _, call := range message.ToolCalls {
+				blocks = append(blocks, messagesBlock{
+					Type:  "tool_use",
+					ID:    call.Id,
+					Name:  call.Function.Name,
+					Input: toolArgumentsObject(call.Function.Arguments),
+				})
+			}
+			if len(blocks) > 0 {
+				appendMessagesBlocks(result, "assistant", blocks...)
+			}
+		default:
+			blocks := messagesBlocksFromChatContent(message)
+			if len(blocks) > 0 {
+				appendMessagesBlocks(result, "user", blocks...)
+			}
+		}
+	}
+	return result
+}
+
+// appendMessagesBlocks merges consecutive same-role turns because the messages
+// API requires user and assistant turns to alternate.
+func appendMessagesBlocks(request *messagesRequest, role string, blocks ...messagesBlock) {
+	if count := len(request.Messages); count > 0 && request.Messages[count-1].Role == role {
+		request.Messages[count-1].Content = append(request.Messages[count-1].Content, blocks...)
+		return
+	}
+	request.Messages = append(request.Messages, messagesMessage{Role: role, Content: blocks})
+}
+
+func messagesBlocksFromChatContent(message relaymodel.Message) []messagesBlock {
+	if message.IsStringContent() {
+		text := message.StringContent()
+		if text == "" {
+			return nil
+		}
+		return []messagesBlock{{Type: "text", Text: stringPtr(text)}}
+	}
+	blocks := make([]messagesBlock, 0)
+	for _, part := range message.ParseContent() {
+		switch part.Type {
+		case relaymodel.ContentTypeText:
+			if part.Text != "" {
+				blocks = append(blocks, messagesBlock{Type: "text", Text: stringPtr(part.Text)})
+			}
+		case relaymodel.ContentTypeImageURL:
+			if part.ImageURL == nil || part.ImageURL.Url == "" {
+				continue
+			}
+			blocks = append(blocks, messagesBlock{Type: "image", Source: messagesSourceFromURL(part.ImageURL.Url)})
+		}
+	}
+	return blocks
+}
+
+func messagesSourceFromURL(url string) *messagesSource {
+	if strings.HasPrefix(url, "data:") {
+		header, data, found := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+		if found {
+			return &messagesSource{
+				Type:      "base64",
+				MediaType: strings.TrimSuffix(header, ";base64"),
+				Data:      data,
+			}
+		}
+	}
+	return &messagesSource{Type: "url", URL: url}
+}
+
+func messagesToolChoiceFromChat(choice any) *messagesToolChoice {
+	switch value := choice.(type) {
+	case string:
+		switch value {
+		case "required", "any":
+			return &messagesToolChoice{Type: "any"}
+		case "none":
+			return &messagesToolChoice{Type: "none"}
+		case "auto":
+			return &messagesToolChoice{Type: "auto"}
+		}
+	case map[string]any:
+		if function, ok := value["function"].(map[string]any); ok {
+			if name, ok := function["name"].(string); ok && strings.TrimSpace(name) != "" {
+				return &messagesToolChoice{Type: "tool", Name: strings.TrimSpace(name)}
+			}
+		}
+		if name, ok := value["name"].(string); ok && strings.TrimSpace(name) != "" {
+			return &messagesToolChoice{Type: "tool", Name: strings.TrimSpace(name)}
+		}
+	}
+	return nil
+}
+
+func toolArgumentsObject(arguments any) any {
+	switch value := arguments.(type) {
+	case string:
+		parsed := map[string]any{}
+		if strings.TrimSpace(value) == "" || json.Unmarshal([]byte(value), &parsed) != nil {
+			return map[string]any{}
+		}
+		return parsed
+	case nil:
+		return map[string]any{}
+	default:
+		return value
+	}
+}
+
+func toolArgumentsString(arguments any) string {
+	switch value := arguments.(type) {
+	case string:
+		if strings.TrimSpace(value) == "" {
+			return "{}"
+		}
+		return value
+	case nil:
+		return "{}"
+	default:
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return "{}"
+		}
+		return string(encoded)
+	}
+}
+
+func chatMaxTokens(request *relaymodel.GeneralOpenAIRequest) int {
+	maxTokens := request.MaxTokens
+	if request.MaxCompletionTokens != nil && *request.MaxCompletionTokens > maxTokens {
+		maxTokens = *request.MaxCompletionTokens
+	}
+	if request.MaxOutputTokens != nil && *request.MaxOutputTokens > maxTokens {
+		maxTokens = *request.MaxOutputTokens
+	}
+	return maxTokens
+}
+
+func stopSequences(stop any) []string {
+	switch value := stop.(type) {
+	case string:
+		if value == "" {
+			return nil
+		}
+		return []string{value}
+	case []string:
+		return value
+	case []any:
+		result := make([]string, 0, len(value))
+		for _, item := range value {
+			if text, ok := item.(string); ok && text != "" {
+				result = append(result, text)
+			}
+		}
+		return result
+	default:
+		return nil
+	}
+}
+
+type responsesInboundRequest struct {
+	Model              string              `json:"model"`
+	Input              json.RawMessage     `json:"input,omitempty"`
+	Instructions       string              `json:"instructions,omitempty"`
+	MaxOutputTokens    *int                `json:"max_output_tokens,omitempty"`
+	Temperature        *float64            `json:"temperature,omitempty"`
+	TopP               *float64            `json:"top_p,omitempty"`
+	Stream             bool                `json:"stream,omitempty"`
+	Tools              []responsesTool     `json:"tools,omitempty"`
+	ToolChoice         any                 `json:"tool_choice,omitempty"`
+	ParallelToolCalls  *bool               `json:"parallel_tool_calls,omitempty"`
+	Reasoning          *responsesReasoning `json:"reasoning,omitempty"`
+	Text               *responsesText      `json:"text,omitempty"`
+	User               string              `json:"user,omitempty"`
+	Metadata           any                 `json:"metadata,omitempty"`
+	PreviousResponseID string              `json:"previous_response_id,omitempty"`
+}
+
+type responsesRequest struct {
+	Model             string              `json:"model"`
+	Input             []any               `json:"input"`
+	Instructions      string              `json:"instructions,omitempty"`
+	MaxOutputTokens   *int                `json:"max_output_tokens,omitempty"`
+	Temperature       *float64            `json:"temperature,omitempty"`
+	TopP              *float64            `json:"top_p,omitempty"`
+	Stream            bool                `json:"stream,omitempty"`
+	Tools             []responsesTool     `json:"tools,omitempty"`
+	ToolChoice        any                 `json:"tool_choice,omitempty"`
+	ParallelToolCalls *bool               `json:"parallel_tool_calls,omitempty"`
+	Reasoning         *responsesReasoning `json:"reasoning,omitempty"`
+	Text              *responsesText      `json:"text,omitempty"`
+	User              string              `json:"user,omitempty"`
+	Store             *bool               `json:"store,omitempty"`
+}
+
+type responsesTool struct {
+	Type        string `json:"type"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	Parameters  any    `json:"parameters,omitempty"`
+	Strict      *bool  `json:"strict,omitempty"`
+}
+
+type responsesReasoning struct {
+	Effort  string `json:"effort,omitempty"`
+	Summary string `json:"summary,omitempty"`
+}
+
+type responsesText struct {
+	Format *responsesTextFormat `json:"format,omitempty"`
+}
+
+type responsesTextFormat struct {
+	Type        string `json:"type"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	Schema      any    `json:"schema,omitempty"`
+	Strict      *bool  `json:"strict,omitempty"`
+}
+
+type responsesInputItem struct {
+	Type      string          `json:"type"`
+	Role      string          `json:"role"`
+	Content   json.RawMessage `json:"content"`
+	CallID    string          `json:"call_id"`
+	Name      string          `json:"name"`
+	Arguments string          `json:"arguments"`
+	Output    json.RawMessage `json:"output"`
+	Summary   []struct {
+		Text string `json:"text"`
+	} `json:"summary"`
+}
+
+type responsesContentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	ImageURL string `json:"image_url"`
+	FileData string `json:"file_data"`
+	Filename string `json:"filename"`
+}
+
+func ChatRequestFromResponses(raw []byte) (*relaymodel.GeneralOpenAIRequest, error) {
+	request := &responsesInboundRequest{}
+	if err := json.Unmarshal(raw, request); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(request.PreviousResponseID) != "" {
+		return nil, ErrStatefulResponsesRequest
+	}
+	result := &relaymodel.GeneralOpenAIRequest{
+		Model:            strings.TrimSpace(request.Model),
+		Stream:           request.Stream,
+		Temperature:      request.Temperature,
+		TopP:             request.TopP,
+		User:             request.User,
+		ParallelTooCalls: request.ParallelToolCalls,
+	}
+	if request.MaxOutputTokens != nil {
+		result.MaxTokens = *request.MaxOutputTokens
+	}
+	if request.Reasoning != nil && strings.TrimSpace(request.Reasoning.Effort) != "" {
+		effort := strings.TrimSpace(request.Reasoning.Effort)
+		result.ReasoningEffort = &effort
+	}
+	if request.Text != nil && request.Text.Format != nil {
+		result.ResponseFormat = responseFormatFromResponsesText(request.Text.Format)
+	}
+	if instructions := strings.TrimSpace(request.Instructions); instructions != "" {
+		result.Messages = append(result.Messages, relaymodel.Message{Role: "system", Content: instructions})
+	}
+	messages, err := chatMessagesFromResponsesInput(request.Input)
+	if err != nil {
+		return nil, err
+	}
+	result.Messages = append(result.Messages, messages...)
+	for _, tool := range request.Tools {
+		if tool.Type != "function" {
+			continue
+		}
+		result.Tools = append(result.Tools, relaymodel.Tool{
+			Type: "function",
+			Function: relaymodel.Function{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+				Strict:      tool.Strict,
+			},
+		})
+	}
+	if len(result.Tools) > 0 && request.ToolChoice != nil {
+		result.ToolChoice = chatToolChoiceFromResponses(request.ToolChoice)
+	}
+	return result, nil
+}
+
+func responseFormatFromResponsesText(format *responsesTextFormat) *relaymodel.ResponseFormat {
+	switch format.Type {
+	case "json_schema":
+		schema, _ := format.Schema.(map[string]any)
+		return &relaymodel.ResponseFormat{
+			Type: "json_schema",
+			JsonSchema: &relaymodel.JSONSchema{
+				Name:        format.Name,
+				Description: format.Description,
+				Schema:      schema,
+				Strict:      format.Strict,
+			},
+		}
+	case "json_object":
+		return &relaymodel.ResponseFormat{Type: "json_object"}
+	default:
+		return nil
+	}
+}
+
+func chatToolChoiceFromResponses(choice any) any {
+	switch value := choice.(type) {
+	case string:
+		return value
+	case map[string]any:
+		if name, ok := value["name"].(string); ok && strings.TrimSpace(name) != "" {
+			return map[string]any{
+				"type":     "function",
+				"function": map[string]any{"name": strings.TrimSpace(name)},
+			}
+		}
+	}
+	return "auto"
+}
+
+func chatMessagesFromResponsesInput(raw json.RawMessage) ([]relaymodel.Message, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return []relaymodel.Message{{Role: "user", Content: text}}, nil
+	}
+	var items []responsesInputItem
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("invalid responses input: %w", err)
+	}
+	result := make([]relaymodel.Message, 0, len(items))
+	for _, item := range items {
+		itemType := item.Type
+		if itemType == "" && item.Role != "" {
+			itemType = "message"
+		}
+		switch itemType {
+		case "message":
+			role := item.Role
+			if role == "developer" {
+				role = "system"
+			}
+			message, err := chatMessageFromResponsesContent(role, item.Content)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, message)
+		case "function_call":
+			call := relaymodel.Tool{
+				Id:   item.CallID,
+				Type: "function",
+				Function: relaymodel.Function{
+					Name:      item.Name,
+					Arguments: toolArgumentsString(item.Arguments),
+				},
+			}
+			if count := len(result); count > 0 && result[count-1].Role == "assistant" {
+				result[count-1].ToolCalls = append(result[count-1].ToolCalls, call)
+				continue
+			}
+			result = append(result, relaymodel.Message{Role: "assistant", ToolCalls: []relaymodel.Tool{call}})
+		case "function_call_output":
+			result = append(result, relaymodel.Message{
+				Role:       "tool",
+				ToolCallId: item.CallID,
+				Content:    responsesOutputText(item.Output),
+			})
+		case "reasoning":
+			parts := make([]string, 0, len(item.Summary))
+			for _, summary := range item.Summary {
+				parts = append(parts, summary.Text)
+			}
+			if len(parts) == 0 {
+				continue
+			}
+			result = append(result, relaymodel.Message{Role: "assistant", ReasoningContent: strings.Join(parts, "\n")})
+		}
+	}
+	return mergeAssistantReasoning(result), nil
+}
+
+// mergeAssistantReasoning folds standalone reasoning turns into the assistant
+// turn that follows them, mirroring how chat completions carries reasoning.
+func mergeAssistantReasoning(messages []relaymodel.Message) []relaymodel.Message {
+	result := make([]relaymodel.Message, 0, len(messages))
+	for _, message := range messages {
+		if count := len(result); count > 0 && message.Role == "assistant" {
+			previous := &result[count-1]
+			if previous.Role == "assistant" && previous.Content == nil && len(previous.ToolCalls) == 0 && previous.ReasoningContent != nil {
+				message.ReasoningContent = previous.ReasoningContent
+				result[count-1] = message
+				continue
+			}
+		}
+		result = append(result, message)
+	}
+	return result
+}
+
+func chatMessageFromResponsesContent(role string, raw json.RawMessage) (relaymodel.Message, error) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return relaymodel.Message{Role: role, Content: text}, nil
+	}
+	var parts []responsesContentPart
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return relaymodel.Message{}, fmt.Errorf("invalid content of %s input item: %w", role, err)
+	}
+	if role == "assistant" || role == "system" {
+		var builder strings.Builder
+		for _, part := range parts {
+			builder.WriteString(part.Text)
+		}
+		return relaymodel.Message{Role: role, Content: builder.String()}, nil
+	}
+	content := make([]any, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case "input_text", "output_text", "text":
+			content = append(content, map[string]any{"type": relaymodel.ContentTypeText, "text": part.Text})
+		case "input_image":
+			if part.ImageURL != "" {
+				content = append(content, map[string]any{
+					"type":      relaymodel.ContentTypeImageURL,
+					"image_url": map[string]any{"url": part.ImageURL},
+				})
+			}
+		case "input_file":
+			if part.FileData != "" {
+				content = append(content, map[string]any{
+					"type":      relaymodel.ContentTypeImageURL,
+					"image_url": map[string]any{"url": part.FileData},
+				})
+			}
+		}
+	}
+	return relaymodel.Message{Role: role, Content: content}, nil
+}
+
+func responsesOutputText(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+	var parts []responsesContentPart
+	if err := json.Unmarshal(raw, &parts); err == nil {
+		var builder strings.Builder
+		for _, part := range parts {
+			builder.WriteString(part.Text)
+		}
+		return builder.String()
+	}
+	return string(raw)
+}
+
+func ResponsesRequestFromChat(request *relaymodel.GeneralOpenAIRequest) *responsesRequest {
+	result := &responsesRequest{
+		Model:             request.Model,
+		Input:             make([]any, 0, len(request.Messages)),
+		Stream:            request.Stream,
+		Temperature:       request.Temperature,
+		TopP:              request.TopP,
+		User:              request.User,
+		ParallelToolCalls: request.ParallelTooCalls,
+	}
+	if maxTokens := chatMaxTokens(request); maxTokens > 0 {
+		result.MaxOutputTokens = &maxTokens
+	}
+	if request.ReasoningEffort != nil && strings.TrimSpace(*request.ReasoningEffort) != "" {
+		result.Reasoning = &responsesReasoning{Effort: strings.TrimSpace(*request.ReasoningEffort)}
+	}
+	if format := responsesTextFormatFromChat(request.ResponseFormat); format != nil {
+		result.Text = &responsesText{Format: format}
+	}
+	instructions := make([]string, 0, 1)
+	for _, message := range request.Messages {
+		switch message.Role {
+		case "system", "developer":
+			if text := message.StringContent(); text != "" {
+				instructions = append(instructions, text)
+			}
+		case "tool":
+			result.Input = append(result.Input, map[string]any{
+				"type":    "function_call_output",
+				"call_id": message.ToolCallId,
+				"output":  message.StringContent(),
+			})
+		case "assistant":
+			if text := message.StringContent(); text != "" {
+				result.Input = append(result.Input, map[string]any{
+					"type": "message",
+					"role": "assistant",
+					"content": []any{map[string]any{
+						"type": "output_text",
+						"text": text,
+					}},
+				})
+			}
+			for _, call := range message.ToolCalls {
+				result.Input = append(result.Input, map[string]any{
+					"type":      "function_call",
+					"call_id":   call.Id,
+					"name":      call.Function.Name,
+					"arguments": toolArgumentsString(call.Function.Arguments),
+				})
+			}
+		default:
+			result.Input = append(result.Input, map[string]any{
+				"type":    "message",
+				"role":    "user",
+				"content": responsesContentFromChat(message),
+			})
+		}
+	}
+	result.Instructions = strings.Join(instructions, "\n")
+	for _, tool := range request.Tools {
+		if strings.TrimSpace(tool.Function.Name) == "" {
+			continue
+		}
+		result.Tools = append(result.Tools, responsesTool{
+			Type:        "function",
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			Parameters:  tool.Function.Parameters,
+			Strict:      tool.Function.Strict,
+		})
+	}
+	if len(result.Tools) > 0 && request.ToolChoice != nil {
+		result.ToolChoice = responsesToolChoiceFromChat(request.ToolChoice)
+	}
+	return result
+}
+
+func responsesContentFromChat(message relaymodel.Message) []any {
+	if message.IsStringContent() {
+		return []any{map[string]any{"type": "input_text", "text": message.StringContent()}}
+	}
+	content := make([]any, 0)
+	for _, part := range message.ParseContent() {
+		switch part.Type {
+		case relaymodel.ContentTypeText:
+			content = append(content, map[string]any{"type": "input_text", "text": part.Text})
+		case relaymodel.ContentTypeImageURL:
+			if part.ImageURL != nil && part.ImageURL.Url != "" {
+				content = append(content, map[string]any{"type": "input_image", "image_url": part.ImageURL.Url})
+			}
+		}
+	}
+	return content
+}
+
+func responsesTextFormatFromChat(format *relaymodel.ResponseFormat) *responsesTextFormat {
+	if format == nil {
+		return nil
+	}
+	switch format.Type {
+	case "json_schema":
+		if format.JsonSchema == nil {
+			return nil
+		}
+		return &responsesTextFormat{
+			Type:        "json_schema",
+			Name:        format.JsonSchema.Name,
+			Description: format.JsonSchema.Description,
+			Schema:      format.JsonSchema.Schema,
+			Strict:      format.JsonSchema.Strict,
+		}
+	case "json_object":
+		return &responsesTextFormat{Type: "json_object"}
+	default:
+		return nil
+	}
+}
+
+func responsesToolChoiceFromChat(choice any) any {
+	switch value := choice.(type) {
+	case string:
+		return value
+	case map[string]any:
+		if function, ok := value["function"].(map[string]any); ok {
+			if name, ok := function["name"].(string); ok && strings.TrimSpace(name) != "" {
+				return map[string]any{"type": "function", "name": strings.TrimSpace(name)}
+			}
+		}
+	}
+	return "auto"
+}
+
+func stringPtr(value string) *string {
+	return &value
+}
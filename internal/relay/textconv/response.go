@@ -0,0 +1,534 @@
+package textconv
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/yeying-community/router/common/helper"
+	"github.com/yeying-community/router/common/random"
+	relaymodel "github.com/yeying-community/router/internal/relay/model"
+	"github.com/yeying-community/router/internal/relay/relaymode"
+)
+
+type chatCompletion struct {
+	ID      string            `json:"id"`
+	Object  string            `json:"object"`
+	Created int64             `json:"created"`
+	Model   string            `json:"model"`
+	Choices []chatChoice      `json:"choices"`
+	Usage   *relaymodel.Usage `json:"usage,omitempty"`
+	Error   *relaymodel.Error `json:"error,omitempty"`
+}
+
+type chatChoice struct {
+	Index        int         `json:"index"`
+	Message      chatMessage `json:"message"`
+	FinishReason string      `json:"finish_reason"`
+}
+
+type chatMessage struct {
+	Role             string            `json:"role"`
+	Content          *string           `json:"content"`
+	ReasoningContent string            `json:"reasoning_content,omitempty"`
+	ToolCalls        []relaymodel.Tool `json:"tool_calls,omitempty"`
+}
+
+// ConvertResponse translates a complete, non-streaming upstream response body
+// into the dialect expected by the downstream client.
+func ConvertResponse(raw []byte, upstreamMode int, downstreamMode int) ([]byte, error) {
+	switch {
+	case upstreamMode == downstreamMode:
+		return raw, nil
+	case downstreamMode == relaymode.GeminiEmbedContent || downstreamMode == relaymode.GeminiBatchEmbedContents:
+		return geminiEmbeddingResponse(raw, downstreamMode)
+	}
+	completion, err := decodeCompletion(raw, upstreamMode)
+	if err != nil {
+		return nil, err
+	}
+	var encoded any
+	switch downstreamMode {
+	case relaymode.Messages:
+		encoded = messagesResponseFromCompletion(completion)
+	case relaymode.Responses:
+		encoded = responsesResponseFromCompletion(completion)
+	case relaymode.GeminiGenerateContent:
+		encoded = geminiResponseFromCompletion(completion)
+	default:
+		completion.ID = chatID(completion.ID)
+		completion.Object = "chat.completion"
+		encoded = completion
+	}
+	return json.Marshal(encoded)
+}
+
+func decodeCompletion(raw []byte, upstreamMode int) (*chatCompletion, error) {
+	switch upstreamMode {
+	case relaymode.Messages:
+		return completionFromMessages(raw)
+	case relaymode.Responses:
+		return completionFromResponses(raw)
+	default:
+		completion := &chatCompletion{}
+		if err := json.Unmarshal(raw, completion); err != nil {
+			return nil, err
+		}
+		if completion.Error != nil && completion.Error.Message != "" {
+			return nil, fmt.Errorf("upstream error: %s", completion.Error.Message)
+		}
+		return completion, nil
+	}
+}
+
+type messagesResponse struct {
+	ID           string          `json:"id"`
+	Type         string          `json:"type"`
+	Role         string          `json:"role"`
+	Model        string          `json:"model"`
+	Content      []messagesBlock `json:"content"`
+	StopReason   string          `json:"stop_reason"`
+	StopSequence *string         `json:"stop_sequence"`
+	Usage        messagesUsage   `json:"usage"`
+}
+
+type messagesUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+}
+
+func completionFromMessages(raw []byte) (*chatCompletion, error) {
+	response := &messagesResponse{}
+	if err := json.Unmarshal(raw, response); err != nil {
+		return nil, err
+	}
+	if response.Type == "error" {
+		return nil, fmt.Errorf("upstream error: %s", strings.TrimSpace(string(raw)))
+	}
+	message := chatMessage{Role: "assistant"}
+	var (
+		text      strings.Builder
+		reasoning strings.Builder
+	)
+	for _, block := range response.Content {
+		switch block.Type {
+		case "text":
+			if block.Text != nil {
+				text.WriteString(*block.Text)
+			}
+		case "thinking":
+			if block.Thinking != nil {
+				reasoning.WriteString(*block.Thinking)
+			}
+		case "tool_use":
+			message.ToolCalls = append(message.ToolCalls, relaymodel.Tool{
+				Id:   block.ID,
+				Type: "function",
+				Function: relaymodel.Function{
+					Name:      block.Name,
+					Arguments: toolArgumentsString(block.Input),
+				},
+			})
+		}
+	}
+	message.Content = stringPtr(text.String())
+	message.ReasoningContent = reasoning.String()
+	return &chatCompletion{
+		ID:      response.ID,
+		Object:  "chat.completion",
+		Created: helper.GetTimestamp(),
+		Model:   response.Model,
+		Choices: []chatChoice{{
+			Message:      message,
+			FinishReason: finishReasonFromMessages(response.StopReason),
+		}},
+		Usage: usageFromMessages(response.Usage),
+	}, nil
+}
+
+func usageFromMessages(usage messagesUsage) *relaymodel.Usage {
+	promptTokens := usage.InputTokens + usage.CacheReadInputTokens + usage.CacheCreationInputTokens
+	result := &relaymodel.Usage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: usage.OutputTokens,
+		TotalTokens:      promptTokens + usage.OutputTokens,
+	}
+	if usage.CacheReadInputTokens > 0 || usage.CacheCreationInputTokens > 0 {
+		result.PromptTokensDetails = &relaymodel.PromptTokensDetails{
+			CachedTokens:        usage.CacheReadInputTokens,
+			CacheReadTokens:     usage.CacheReadInputTokens,
+			CacheCreationTokens: usage.CacheCreationInputTokens,
+		}
+	}
+	return result
+}
+
+func messagesUsageFromChat(usage *relaymodel.Usage) messagesUsage {
+	if usage == nil {
+		return messagesUsage{}
+	}
+	result := messagesUsage{
+		InputTokens:  usage.PromptTokens,
+		OutputTokens: usage.CompletionTokens,
+	}
+	if details := usage.PromptTokensDetails; details != nil {
+		cacheRead := details.CacheReadTokens
+		if cacheRead == 0 {
+			cacheRead = details.CachedTokens
+		}
+		result.CacheReadInputTokens = cacheRead
+		result.CacheCreationInputTokens = details.CacheCreationTokens
+		result.InputTokens -= cacheRead + details.CacheCreationTokens
+		if result.InputTokens < 0 {
+			result.InputTokens = 0
+		}
+	}
+	return result
+}
+
+func finishReasonFromMessages(reason string) string {
+	switch reason {
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	case "refusal":
+		return "content_filter"
+	default:
+		return "stop"
+	}
+}
+
+func messagesStopReasonFromChat(reason string) string {
+	switch reason {
+	case "length":
+		return "max_tokens"
+	case "tool_calls", "function_call":
+		return "tool_use"
+	case "content_filter":
+		return "refusal"
+	default:
+		return "end_turn"
+	}
+}
+
+func messagesResponseFromCompletion(completion *chatCompletion) *messagesResponse {
+	response := &messagesResponse{
+		ID:      messagesID(completion.ID),
+		Type:    "message",
+		Role:    "assistant",
+		Model:   completion.Model,
+		Content: make([]messagesBlock, 0, 2),
+		Usage:   messagesUsageFromChat(completion.Usage),
+	}
+	if len(completion.Choices) == 0 {
+		response.StopReason = "end_turn"
+		return response
+	}
+	choice := completion.Choices[0]
+	if choice.Message.ReasoningContent != "" {
+		response.Content = append(response.Content, messagesBlock{Type: "thinking", Thinking: stringPtr(choice.Message.ReasoningContent), Signature: ""})
+	}
+	if choice.Message.Content != nil && *choice.Message.Content != "" {
+		response.Content = append(response.Content, messagesBlock{Type: "text", Text: choice.Message.Content})
+	}
+	for _, call := range choice.Message.ToolCalls {
+		response.Content = append(response.Content, messagesBlock{
+			Type:  "tool_use",
+			ID:    toolCallID(call.Id),
+			Name:  call.Function.Name,
+			Input: toolArgumentsObject(call.Function.Arguments),
+		})
+	}
+	response.StopReason = messagesStopReasonFromChat(choice.FinishReason)
+	if len(choice.Message.ToolCalls) > 0 {
+		response.StopReason = "tool_use"
+	}
+	return response
+}
+
+type responsesResponse struct {
+	ID                string                      `json:"id"`
+	Object            string                      `json:"object"`
+	CreatedAt         int64                       `json:"created_at"`
+	Status            string                      `json:"status"`
+	Model             string                      `json:"model"`
+	Output            []map[string]any            `json:"output"`
+	Usage             *responsesUsage             `json:"usage,omitempty"`
+	IncompleteDetails *responsesIncompleteDetails `json:"incomplete_details"`
+	Error             *relaymodel.Error           `json:"error"`
+}
+
+type responsesIncompleteDetails struct {
+	Reason string `json:"reason"`
+}
+
+type responsesUsage struct {
+	InputTokens         int                           `json:"input_tokens"`
+	InputTokensDetails  *responsesInputTokensDetails  `json:"input_tokens_details,omitempty"`
+	OutputTokens        int                           `json:"output_tokens"`
+	OutputTokensDetails *responsesOutputTokensDetails `json:"output_tokens_details,omitempty"`
+	TotalTokens         int                           `json:"total_tokens"`
+}
+
+type responsesInputTokensDetails struct {
+	CachedTokens int `json:"cached_tokens"`
+}
+
+type responsesOutputTokensDetails struct {
+	ReasoningTokens int `json:"reasoning_tokens"`
+}
+
+type responsesOutputItem struct {
+	Type      string `json:"type"`
+	ID        string `json:"id"`
+	Role      string `json:"role"`
+	CallID    string `json:"call_id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+	Content   []struct {
+		Type    string `json:"type"`
+		Text    string `json:"text"`
+		Refusal string `json:"refusal"`
+	} `json:"content"`
+	Summary []struct {
+		Text string `json:"text"`
+	} `json:"summary"`
+}
+
+type responsesInboundResponse struct {
+	ID                string                      `json:"id"`
+	Model             string                      `json:"model"`
+	CreatedAt         int64                       `json:"created_at"`
+	Status            string                      `json:"status"`
+	Output            []responsesOutputItem       `json:"output"`
+	Usage             *responsesUsage             `json:"usage"`
+	IncompleteDetails *responsesIncompleteDetails `json:"incomplete_details"`
+	Error             *relaymodel.Error           `json:"error"`
+}
+
+func completionFromResponses(raw []byte) (*chatCompletion, error) {
+	response := &responsesInboundResponse{}
+	if err := json.Unmarshal(raw, response); err != nil {
+		return nil, err
+	}
+	if response.Error != nil && response.Error.Message != "" {
+		return nil, fmt.Errorf("upstream error: %s", response.Error.Message)
+	}
+	return completionFromResponsesObject(response), nil
+}
+
+func completionFromResponsesObject(response *responsesInboundResponse) *chatCompletion {
+	message := chatMessage{Role: "assistant"}
+	var (
+		text      strings.Builder
+		reasoning strings.Builder
+	)
+	for _, item := range response.Output {
+		switch item.Type {
+		case "message":
+			for _, part := range item.Content {
+				if part.Type == "output_text" {
+					text.WriteString(part.Text)
+				} else if part.Type == "refusal" {
+					text.WriteString(part.Refusal)
+				}
+			}
+		case "reasoning":
+			for _, summary := range item.Summary {
+				reasoning.WriteString(summary.Text)
+			}
+		case "function_call":
+			message.ToolCalls = append(message.ToolCalls, relaymodel.Tool{
+				Id:   item.CallID,
+				Type: "function",
+				Function: relaymodel.Function{
+					Name:      item.Name,
+					Arguments: toolArgumentsString(item.Arguments),
+				},
+			})
+		}
+	}
+	message.Content = stringPtr(text.String())
+	message.ReasoningContent = reasoning.String()
+	finishReason := "stop"
+	if len(message.ToolCalls) > 0 {
+		finishReason = "tool_calls"
+	} else if response.Status == "incomplete" {
+		finishReason = "length"
+		if response.IncompleteDetails != nil && response.IncompleteDetails.Reason == "content_filter" {
+			finishReason = "content_filter"
+		}
+	}
+	created := response.CreatedAt
+	if created == 0 {
+		created = helper.GetTimestamp()
+	}
+	return &chatCompletion{
+		ID:      response.ID,
+		Object:  "chat.completion",
+		Created: created,
+		Model:   response.Model,
+		Choices: []chatChoice{{
+			Message:      message,
+			FinishReason: finishReason,
+		}},
+		Usage: usageFromResponses(response.Usage),
+	}
+}
+
+func usageFromResponses(usage *responsesUsage) *relaymodel.Usage {
+	if usage == nil {
+		return nil
+	}
+	result := &relaymodel.Usage{
+		PromptTokens:     usage.InputTokens,
+		CompletionTokens: usage.OutputTokens,
+		TotalTokens:      usage.TotalTokens,
+	}
+	if result.TotalTokens == 0 {
+		result.TotalTokens = result.PromptTokens + result.CompletionTokens
+	}
+	if usage.InputTokensDetails != nil && usage.InputTokensDetails.CachedTokens > 0 {
+		result.PromptTokensDetails = &relaymodel.PromptTokensDetails{CachedTokens: usage.InputTokensDetails.CachedTokens}
+	}
+	if usage.OutputTokensDetails != nil && usage.OutputTokensDetails.ReasoningTokens > 0 {
+		result.CompletionTokensDetails = &relaymodel.CompletionTokensDetails{ReasoningTokens: usage.OutputTokensDetails.ReasoningTokens}
+	}
+	return result
+}
+
+func responsesUsageFromChat(usage *relaymodel.Usage) *responsesUsage {
+	if usage == nil {
+		return nil
+	}
+	result := &responsesUsage{
+		InputTokens:         usage.PromptTokens,
+		InputTokensDetails:  &responsesInputTokensDetails{},
+		OutputTokens:        usage.CompletionTokens,
+		OutputTokensDetails: &responsesOutputTokensDetails{},
+		TotalTokens:         usage.TotalTokens,
+	}
+	if result.TotalTokens == 0 {
+		result.TotalTokens = result.InputTokens + result.OutputTokens
+	}
+	if usage.PromptTokensDetails != nil {
+		cached := usage.PromptTokensDetails.CachedTokens
+		if cached == 0 {
+			cached = usage.PromptTokensDetails.CacheReadTokens
+		}
+		result.InputTokensDetails.CachedTokens = cached
+	}
+	if usage.CompletionTokensDetails != nil {
+		result.OutputTokensDetails.ReasoningTokens = usage.CompletionTokensDetails.ReasoningTokens
+	}
+	return result
+}
+
+func responsesResponseFromCompletion(completion *chatCompletion) *responsesResponse {
+	created := completion.Created
+	if created == 0 {
+		created = helper.GetTimestamp()
+	}
+	response := &responsesResponse{
+		ID:        responsesID(completion.ID),
+		Object:    "response",
+		CreatedAt: created,
+		Status:    "completed",
+		Model:     completion.Model,
+		Output:    make([]map[string]any, 0, 2),
+		Usage:     responsesUsageFromChat(completion.Usage),
+	}
+	if len(completion.Choices) == 0 {
+		return response
+	}
+	choice := completion.Choices[0]
+	if choice.Message.ReasoningContent != "" {
+		response.Output = append(response.Output, responsesReasoningItem("rs_"+random.GetUUID(), choice.Message.ReasoningContent))
+	}
+	if choice.Message.Content != nil && *choice.Message.Content != "" {
+		response.Output = append(response.Output, responsesMessageItem("msg_"+random.GetUUID(), *choice.Message.Content, "completed"))
+	}
+	for _, call := range choice.Message.ToolCalls {
+		response.Output = append(response.Output, responsesFunctionCallItem("fc_"+random.GetUUID(), toolCallID(call.Id), call.Function.Name, toolArgumentsString(call.Function.Arguments), "completed"))
+	}
+	switch choice.FinishReason {
+	case "length":
+		response.Status = "incomplete"
+		response.IncompleteDetails = &responsesIncompleteDetails{Reason: "max_output_tokens"}
+	case "content_filter":
+		response.Status = "incomplete"
+		response.IncompleteDetails = &responsesIncompleteDetails{Reason: "content_filter"}
+	}
+	return response
+}
+
+func responsesMessageItem(id string, text string, status string) map[string]any {
+	return map[string]any{
+		"type":   "message",
+		"id":     id,
+		"status": status,
+		"role":   "assistant",
+		"content": []any{map[string]any{
+			"type":        "output_text",
+			"text":        text,
+			"annotations": []any{},
+		}},
+	}
+}
+
+func responsesFunctionCallItem(id string, callID string, name string, arguments string, status string) map[string]any {
+	return map[string]any{
+		"type":      "function_call",
+		"id":        id,
+		"status":    status,
+		"call_id":   callID,
+		"name":      name,
+		"arguments": arguments,
+	}
+}
+
+func responsesReasoningItem(id string, text string) map[string]any {
+	summary := []any{}
+	if text != "" {
+		summary = append(summary, map[string]any{"type": "summary_text", "text": text})
+	}
+	return map[string]any{
+		"type":    "reasoning",
+		"id":      id,
+		"summary": summary,
+	}
+}
+
+func messagesID(id string) string {
+	trimmed := strings.TrimSpace(id)
+	if strings.HasPrefix(trimmed, "msg_") {
+		return trimmed
+	}
+	return "msg_" + random.GetUUID()
+}
+
+func responsesID(id string) string {
+	trimmed := strings.TrimSpace(id)
+	if strings.HasPrefix(trimmed, "resp_") {
+		return trimmed
+	}
+	return "resp_" + random.GetUUID()
+}
+
+func chatID(id string) string {
+	trimmed := strings.TrimSpace(id)
+	if strings.HasPrefix(trimmed, "chatcmpl-") {
+		return trimmed
+	}
+	return "chatcmpl-" + random.GetUUID()
+}
+
+func toolCallID(id string) string {
+	if trimmed := strings.TrimSpace(id); trimmed != "" {
+		return trimmed
+	}
+	return "call_" + random.GetUUID()
+}
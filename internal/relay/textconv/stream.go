@@ -0,0 +1,788 @@
+package textconv
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/yeying-community/router/common/helper"
+	"github.com/yeying-community/router/common/random"
+	relaymodel "github.com/yeying-community/router/internal/relay/model"
+	"github.com/yeying-community/router/internal/relay/relaymode"
+)
+
+type streamEventKind int
+
+const (
+	streamEventStart streamEventKind = iota + 1
+	streamEventText
+	streamEventReasoning
+	streamEventToolStart
+	streamEventToolArguments
+	streamEventFinish
+	streamEventUsage
+	streamEventError
+)
+
+// streamEvent is the protocol-neutral unit emitted by stream decoders and
+// consumed by stream encoders.
+type streamEvent struct {
+	Kind         streamEventKind
+	ID           string
+	Model        string
+	Text         string
+	ToolIndex    int
+	ToolCallID   string
+	ToolName     string
+	FinishReason string
+	Usage        *relaymodel.Usage
+	Error        string
+}
+
+type streamDecoder interface {
+	Decode(event string, data string) []streamEvent
+}
+
+type streamEncoder interface {
+	Encode(event streamEvent) []sseFrame
+	Close() []sseFrame
+}
+
+type sseFrame struct {
+	Event string
+	Data  any
+}
+
+func newStreamDecoder(mode int) streamDecoder {
+	switch mode {
+	case relaymode.Messages:
+		return &messagesStreamDecoder{}
+	case relaymode.Responses:
+		return &responsesStreamDecoder{}
+	default:
+		return &chatStreamDecoder{}
+	}
+}
+
+func newStreamEncoder(mode int) streamEncoder {
+	switch mode {
+	case relaymode.Messages:
+		return &messagesStreamEncoder{}
+	case relaymode.Responses:
+		return &responsesStreamEncoder{}
+	case relaymode.GeminiGenerateContent:
+		return &geminiStreamEncoder{}
+	default:
+		return &chatStreamEncoder{}
+	}
+}
+
+type chatStreamChunk struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta struct {
+			Content          *string `json:"content"`
+			ReasoningContent *string `json:"reasoning_content"`
+			Reasoning        *string `json:"reasoning"`
+			ToolCalls        []struct {
+				Index    *int   `json:"index"`
+				ID       string `json:"id"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *relaymodel.Usage `json:"usage"`
+	Error *relaymodel.Error `json:"error"`
+}
+
+type chatStreamDecoder struct {
+	started bool
+}
+
+func (d *chatStreamDecoder) Decode(_ string, data string) []streamEvent {
+	if data == "[DONE]" {
+		return nil
+	}
+	chunk := chatStreamChunk{}
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return nil
+	}
+	if chunk.Error != nil && chunk.Error.Message != "" {
+		return []streamEvent{{Kind: streamEventError, Error: chunk.Error.Message}}
+	}
+	events := make([]streamEvent, 0, 2)
+	if !d.started {
+		d.started = true
+		events = append(events, streamEvent{Kind: streamEventStart, ID: chunk.ID, Model: chunk.Model})
+	}
+	for _, choice := range chunk.Choices {
+		delta := choice.Delta
+		if delta.ReasoningContent != nil && *delta.ReasoningContent != "" {
+			events = append(events, streamEvent{Kind: streamEventReasoning, Text: *delta.ReasoningContent})
+		} else if delta.Reasoning != nil && *delta.Reasoning != "" {
+			events = append(events, streamEvent{Kind: streamEventReasoning, Text: *delta.Reasoning})
+		}
+		if delta.Content != nil && *delta.Content != "" {
+			events = append(events, streamEvent{Kind: streamEventText, Text: *delta.Content})
+		}
+		for position, call := range delta.ToolCalls {
+			index := position
+			if call.Index != nil {
+				index = *call.Index
+			}
+			if call.ID != "" || call.Function.Name != "" {
+				events = append(events, streamEvent{
+					Kind:       streamEventToolStart,
+					ToolIndex:  index,
+					ToolCallID: call.ID,
+					ToolName:   call.Function.Name,
+				})
+			}
+			if call.Function.Arguments != "" {
+				events = append(events, streamEvent{Kind: streamEventToolArguments, ToolIndex: index, Text: call.Function.Arguments})
+			}
+		}
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			events = append(events, streamEvent{Kind: streamEventFinish, FinishReason: *choice.FinishReason})
+		}
+	}
+	if chunk.Usage != nil && (chunk.Usage.TotalTokens > 0 || chunk.Usage.PromptTokens > 0 || chunk.Usage.CompletionTokens > 0) {
+		events = append(events, streamEvent{Kind: streamEventUsage, Usage: chunk.Usage})
+	}
+	return events
+}
+
+type messagesStreamPayload struct {
+	Type    string `json:"type"`
+	Index   int    `json:"index"`
+	Message *struct {
+		ID    string        `json:"id"`
+		Model string        `json:"model"`
+		Usage messagesUsage `json:"usage"`
+	} `json:"message"`
+	ContentBlock *messagesBlock `json:"content_block"`
+	Delta        *struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		Thinking    string `json:"thinking"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage *messagesUsage `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type messagesStreamDecoder struct {
+	usage      messagesUsage
+	toolBlocks map[int]int
+	toolCount  int
+}
+
+func (d *messagesStreamDecoder) Decode(_ string, data string) []streamEvent {
+	payload := messagesStreamPayload{}
+	if err := json.Unmarshal([]byte(data), &payload); err != nil {
+		return nil
+	}
+	switch payload.Type {
+	case "message_start":
+		if payload.Message == nil {
+			return nil
+		}
+		d.usage = payload.Message.Usage
+		return []streamEvent{{Kind: streamEventStart, ID: payload.Message.ID, Model: payload.Message.Model}}
+	case "content_block_start":
+		if payload.ContentBlock == nil {
+			return nil
+		}
+		switch payload.ContentBlock.Type {
+		case "tool_use":
+			if d.toolBlocks == nil {
+				d.toolBlocks = map[int]int{}
+			}
+			d.toolBlocks[payload.Index] = d.toolCount
+			d.toolCount++
+			return []streamEvent{{
+				Kind:       streamEventToolStart,
+				ToolIndex:  d.toolBlocks[payload.Index],
+				ToolCallID: payload.ContentBlock.ID,
+				ToolName:   payload.ContentBlock.Name,
+			}}
+		case "text":
+			if payload.ContentBlock.Text != nil && *payload.ContentBlock.Text != "" {
+				return []streamEvent{{Kind: streamEventText, Text: *payload.ContentBlock.Text}}
+			}
+		}
+	case "content_block_delta":
+		if payload.Delta == nil {
+			return nil
+		}
+		switch payload.Delta.Type {
+		case "text_delta":
+			return []streamEvent{{Kind: streamEventText, Text: payload.Delta.Text}}
+		case "thinking_delta":
+			return []streamEvent{{Kind: streamEventReasoning, Text: payload.Delta.Thinking}}
+		case "input_json_delta":
+			if payload.Delta.PartialJSON == "" {
+				return nil
+			}
+			return []streamEvent{{Kind: streamEventToolArguments, ToolIndex: d.toolBlocks[payload.Index], Text: payload.Delta.PartialJSON}}
+		}
+	case "message_delta":
+		events := make([]streamEvent, 0, 2)
+		if payload.Delta != nil && payload.Delta.StopReason != "" {
+			events = append(events, streamEvent{Kind: streamEventFinish, FinishReason: finishReasonFromMessages(payload.Delta.StopReason)})
+		}
+		if payload.Usage != nil {
+			if payload.Usage.InputTokens > 0 {
+				d.usage.InputTokens = payload.Usage.InputTokens
+			}
+			if payload.Usage.CacheReadInputTokens > 0 {
+				d.usage.CacheReadInputTokens = payload.Usage.CacheReadInputTokens
+			}
+			if payload.Usage.CacheCreationInputTokens > 0 {
+				d.usage.CacheCreationInputTokens = payload.Usage.CacheCreationInputTokens
+			}
+			d.usage.OutputTokens = payload.Usage.OutputTokens
+			events = append(events, streamEvent{Kind: streamEventUsage, Usage: usageFromMessages(d.usage)})
+		}
+		return events
+	case "error":
+		message := "upstream stream error"
+		if payload.Error != nil && payload.Error.Message != "" {
+			message = payload.Error.Message
+		}
+		return []streamEvent{{Kind: streamEventError, Error: message}}
+	}
+	return nil
+}
+
+type responsesStreamPayload struct {
+	Type        string                    `json:"type"`
+	OutputIndex int                       `json:"output_index"`
+	Delta       string                    `json:"delta"`
+	Item        *responsesOutputItem      `json:"item"`
+	Response    *responsesInboundResponse `json:"response"`
+}
+
+type responsesStreamDecoder struct {
+	toolItems map[int]int
+	toolCount int
+	finished  bool
+}
+
+func (d *responsesStreamDecoder) Decode(event string, data string) []streamEvent {
+	if data == "[DONE]" {
+		return nil
+	}
+	payload := responsesStreamPayload{}
+	if err := json.Unmarshal([]byte(data), &payload); err != nil {
+		return nil
+	}
+	eventType := payload.Type
+	if eventType == "" {
+		eventType = event
+	}
+	switch eventType {
+	case "response.created":
+		if payload.Response == nil {
+			return nil
+		}
+		return []streamEvent{{Kind: streamEventStart, ID: payload.Response.ID, Model: payload.Response.Model}}
+	case "response.output_text.delta", "response.refusal.delta":
+		return []streamEvent{{Kind: streamEventText, Text: payload.Delta}}
+	case "response.reasoning_summary_text.delta", "response.reasoning_text.delta":
+		return []streamEvent{{Kind: streamEventReasoning, Text: payload.Delta}}
+	case "response.output_item.added":
+		if payload.Item == nil || payload.Item.Type != "function_call" {
+			return nil
+		}
+		if d.toolItems == nil {
+			d.toolItems = map[int]int{}
+		}
+		d.toolItems[payload.OutputIndex] = d.toolCount
+		d.toolCount++
+		return []streamEvent{{
+			Kind:       streamEventToolStart,
+			ToolIndex:  d.toolItems[payload.OutputIndex],
+			ToolCallID: payload.Item.CallID,
+			ToolName:   payload.Item.Name,
+		}}
+	case "response.function_call_arguments.delta":
+		if payload.Delta == "" {
+			return nil
+		}
+		return []streamEvent{{Kind: streamEventToolArguments, ToolIndex: d.toolItems[payload.OutputIndex], Text: payload.Delta}}
+	case "response.completed", "response.incomplete":
+		if payload.Response == nil || d.finished {
+			return nil
+		}
+		d.finished = true
+		completion := completionFromResponsesObject(payload.Response)
+		events := []streamEvent{{Kind: streamEventFinish, FinishReason: completion.Choices[0].FinishReason}}
+		if completion.Usage != nil {
+			events = append(events, streamEvent{Kind: streamEventUsage, Usage: completion.Usage})
+		}
+		return events
+	case "response.failed", "error":
+		message := "upstream stream error"
+		if payload.Response != nil && payload.Response.Error != nil && payload.Response.Error.Message != "" {
+			message = payload.Response.Error.Message
+		}
+		return []streamEvent{{Kind: streamEventError, Error: message}}
+	}
+	return nil
+}
+
+type chatStreamEncoder struct {
+	id           string
+	model        string
+	created      int64
+	roleSent     bool
+	toolIndexes  map[int]int
+	finishReason string
+	usage        *relaymodel.Usage
+	closed       bool
+}
+
+func (e *chatStreamEncoder) chunk(delta map[string]any, finishReason *string) map[string]any {
+	if !e.roleSent {
+		delta["role"] = "assistant"
+		e.roleSent = true
+	}
+	return map[string]any{
+		"id":      e.id,
+		"object":  "chat.completion.chunk",
+		"created": e.created,
+		"model":   e.model,
+		"choices": []any{map[string]any{
+			"index":         0,
+			"delta":         delta,
+			"finish_reason": finishReason,
+		}},
+	}
+}
+
+func (e *chatStreamEncoder) ensureStarted() {
+	if e.id == "" {
+		e.id = chatID("")
+	}
+	if e.created == 0 {
+		e.created = helper.GetTimestamp()
+	}
+}
+
+func (e *chatStreamEncoder) Encode(event streamEvent) []sseFrame {
+	e.ensureStarted()
+	switch event.Kind {
+	case streamEventStart:
+		e.id = chatID(event.ID)
+		e.model = event.Model
+	case streamEventText:
+		return []sseFrame{{Data: e.chunk(map[string]any{"content": event.Text}, nil)}}
+	case streamEventReasoning:
+		return []sseFrame{{Data: e.chunk(map[string]any{"reasoning_content": event.Text}, nil)}}
+	case streamEventToolStart:
+		if e.toolIndexes == nil {
+			e.toolIndexes = map[int]int{}
+		}
+		index, ok := e.toolIndexes[event.ToolIndex]
+		if !ok {
+			index = len(e.toolIndexes)
+			e.toolIndexes[event.ToolIndex] = index
+		}
+		return []sseFrame{{Data: e.chunk(map[string]any{"tool_calls": []any{map[string]any{
+			"index":    index,
+			"id":       toolCallID(event.ToolCallID),
+			"type":     "function",
+			"function": map[string]any{"name": event.ToolName, "arguments": ""},
+		}}}, nil)}}
+	case streamEventToolArguments:
+		return []sseFrame{{Data: e.chunk(map[string]any{"tool_calls": []any{map[string]any{
+			"index":    e.toolIndexes[event.ToolIndex],
+			"function": map[string]any{"arguments": event.Text},
+		}}}, nil)}}
+	case streamEventFinish:
+		e.finishReason = event.FinishReason
+	case streamEventUsage:
+		e.usage = event.Usage
+	case streamEventError:
+		return []sseFrame{{Data: map[string]any{"error": map[string]any{"message": event.Error, "type": "upstream_error"}}}}
+	}
+	return nil
+}
+
+func (e *chatStreamEncoder) Close() []sseFrame {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	e.ensureStarted()
+	finishReason := e.finishReason
+	if finishReason == "" {
+		finishReason = "stop"
+	}
+	frames := []sseFrame{{Data: e.chunk(map[string]any{}, &finishReason)}}
+	if e.usage != nil {
+		frames = append(frames, sseFrame{Data: map[string]any{
+			"id":      e.id,
+			"object":  "chat.completion.chunk",
+			"created": e.created,
+			"model":   e.model,
+			"choices": []any{},
+			"usage":   e.usage,
+		}})
+	}
+	return append(frames, sseFrame{Data: "[DONE]"})
+}
+
+type messagesStreamEncoder struct {
+	started      bool
+	id           string
+	model        string
+	blockIndex   int
+	openBlock    string
+	toolBlocks   map[int]int
+	openTool     int
+	stopReason   string
+	usage        *relaymodel.Usage
+	closed       bool
+	sawToolCalls bool
+}
+
+func (e *messagesStreamEncoder) start(id string, model string) []sseFrame {
+	if e.started {
+		return nil
+	}
+	e.started = true
+	e.id = messagesID(id)
+	e.model = model
+	e.blockIndex = -1
+	return []sseFrame{{Event: "message_start", Data: map[string]any{
+		"type": "message_start",
+		"message": map[string]any{
+			"id":            e.id,
+			"type":          "message",
+			"role":          "assistant",
+			"model":         e.model,
+			"content":       []any{},
+			"stop_reason":   nil,
+			"stop_sequence": nil,
+			"usage":         map[string]any{"input_tokens": 0, "output_tokens": 0},
+		},
+	}}}
+}
+
+func (e *messagesStreamEncoder) closeBlock() []sseFrame {
+	if e.openBlock == "" {
+		return nil
+	}
+	e.openBlock = ""
+	return []sseFrame{{Event: "content_block_stop", Data: map[string]any{"type": "content_block_stop", "index": e.blockIndex}}}
+}
+
+func (e *messagesStreamEncoder) openContentBlock(blockType string, block map[string]any) []sseFrame {
+	frames := e.closeBlock()
+	e.blockIndex++
+	e.openBlock = blockType
+	return append(frames, sseFrame{Event: "content_block_start", Data: map[string]any{
+		"type":          "content_block_start",
+		"index":         e.blockIndex,
+		"content_block": block,
+	}})
+}
+
+func (e *messagesStreamEncoder) delta(delta map[string]any) sseFrame {
+	return sseFrame{Event: "content_block_delta", Data: map[string]any{
+		"type":  "content_block_delta",
+		"index": e.blockIndex,
+		"delta": delta,
+	}}
+}
+
+func (e *messagesStreamEncoder) Encode(event streamEvent) []sseFrame {
+	frames := e.start(event.ID, event.Model)
+	switch event.Kind {
+	case streamEventText:
+		if e.openBlock != "text" {
+			frames = append(frames, e.openContentBlock("text", map[string]any{"type": "text", "text": ""})...)
+		}
+		frames = append(frames, e.delta(map[string]any{"type": "text_delta", "text": event.Text}))
+	case streamEventReasoning:
+		if e.openBlock != "thinking" {
+			frames = append(frames, e.openContentBlock("thinking", map[string]any{"type": "thinking", "thinking": ""})...)
+		}
+		frames = append(frames, e.delta(map[string]any{"type": "thinking_delta", "thinking": event.Text}))
+	case streamEventToolStart:
+		e.sawToolCalls = true
+		frames = append(frames, e.openContentBlock("tool_use", map[string]any{
+			"type":  "tool_use",
+			"id":    toolCallID(event.ToolCallID),
+			"name":  event.ToolName,
+			"input": map[string]any{},
+		})...)
+		if e.toolBlocks == nil {
+			e.toolBlocks = map[int]int{}
+		}
+		e.toolBlocks[event.ToolIndex] = e.blockIndex
+		e.openTool = event.ToolIndex
+	case streamEventToolArguments:
+		blockIndex, ok := e.toolBlocks[event.ToolIndex]
+		if !ok {
+			return frames
+		}
+		frames = append(frames, sseFrame{Event: "content_block_delta", Data: map[string]any{
+			"type":  "content_block_delta",
+			"index": blockIndex,
+			"delta": map[string]any{"type": "input_json_delta", "partial_json": event.Text},
+		}})
+	case streamEventFinish:
+		e.stopReason = messagesStopReasonFromChat(event.FinishReason)
+	case streamEventUsage:
+		e.usage = event.Usage
+	case streamEventError:
+		frames = append(frames, sseFrame{Event: "error", Data: map[string]any{
+			"type":  "error",
+			"error": map[string]any{"type": "api_error", "message": event.Error},
+		}})
+	}
+	return frames
+}
+
+func (e *messagesStreamEncoder) Close() []sseFrame {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	frames := e.start("", "")
+	frames = append(frames, e.closeBlock()...)
+	stopReason := e.stopReason
+	if stopReason == "" {
+		stopReason = "end_turn"
+	}
+	if e.sawToolCalls && stopReason == "end_turn" {
+		stopReason = "tool_use"
+	}
+	usage := messagesUsageFromChat(e.usage)
+	frames = append(frames, sseFrame{Event: "message_delta", Data: map[string]any{
+		"type":  "message_delta",
+		"delta": map[string]any{"stop_reason": stopReason, "stop_sequence": nil},
+		"usage": usage,
+	}})
+	return append(frames, sseFrame{Event: "message_stop", Data: map[string]any{"type": "message_stop"}})
+}
+
+type responsesStreamItem struct {
+	kind        string
+	id          string
+	outputIndex int
+	callID      string
+	name        string
+	text        strings.Builder
+}
+
+type responsesStreamEncoder struct {
+	started      bool
+	id           string
+	model        string
+	createdAt    int64
+	sequence     int
+	items        []*responsesStreamItem
+	open         *responsesStreamItem
+	toolItems    map[int]*responsesStreamItem
+	finishReason string
+	usage        *relaymodel.Usage
+	closed       bool
+}
+
+func (e *responsesStreamEncoder) frame(eventType string, data map[string]any) sseFrame {
+	data["type"] = eventType
+	data["sequence_number"] = e.sequence
+	e.sequence++
+	return sseFrame{Event: eventType, Data: data}
+}
+
+func (e *responsesStreamEncoder) snapshot(status string) map[string]any {
+	output := make([]any, 0, len(e.items))
+	for _, item := range e.items {
+		output = append(output, e.itemObject(item, "completed"))
+	}
+	return map[string]any{
+		"id":         e.id,
+		"object":     "response",
+		"created_at": e.createdAt,
+		"status":     status,
+		"model":      e.model,
+		"output":     output,
+	}
+}
+
+func (e *responsesStreamEncoder) itemObject(item *responsesStreamItem, status string) map[string]any {
+	switch item.kind {
+	case "function_call":
+		return responsesFunctionCallItem(item.id, item.callID, item.name, item.text.String(), status)
+	case "reasoning":
+		return responsesReasoningItem(item.id, item.text.String())
+	default:
+		return responsesMessageItem(item.id, item.text.String(), status)
+	}
+}
+
+func (e *responsesStreamEncoder) start(id string, model string) []sseFrame {
+	if e.started {
+		return nil
+	}
+	e.started = true
+	e.id = responsesID(id)
+	e.model = model
+	e.createdAt = helper.GetTimestamp()
+	return []sseFrame{
+		e.frame("response.created", map[string]any{"response": e.snapshot("in_progress")}),
+		e.frame("response.in_progress", map[string]any{"response": e.snapshot("in_progress")}),
+	}
+}
+
+func (e *responsesStreamEncoder) closeItem() []sseFrame {
+	item := e.open
+	if item == nil {
+		return nil
+	}
+	e.open = nil
+	frames := make([]sseFrame, 0, 4)
+	switch item.kind {
+	case "message":
+		frames = append(frames,
+			e.frame("response.output_text.done", map[string]any{
+				"item_id": item.id, "output_index": item.outputIndex, "content_index": 0, "text": item.text.String(),
+			}),
+			e.frame("response.content_part.done", map[string]any{
+				"item_id": item.id, "output_index": item.outputIndex, "content_index": 0,
+				"part": map[string]any{"type": "output_text", "text": item.text.String(), "annotations": []any{}},
+			}),
+		)
+	case "reasoning":
+		frames = append(frames,
+			e.frame("response.reasoning_summary_text.done", map[string]any{
+				"item_id": item.id, "output_index": item.outputIndex, "summary_index": 0, "text": item.text.String(),
+			}),
+			e.frame("response.reasoning_summary_part.done", map[string]any{
+				"item_id": item.id, "output_index": item.outputIndex, "summary_index": 0,
+				"part": map[string]any{"type": "summary_text", "text": item.text.String()},
+			}),
+		)
+	case "function_call":
+		frames = append(frames, e.frame("response.function_call_arguments.done", map[string]any{
+			"item_id": item.id, "output_index": item.outputIndex, "arguments": item.text.String(),
+		}))
+	}
+	return append(frames, e.frame("response.output_item.done", map[string]any{
+		"output_index": item.outputIndex,
+		"item":         e.itemObject(item, "completed"),
+	}))
+}
+
+func (e *responsesStreamEncoder) openItem(item *responsesStreamItem) []sseFrame {
+	frames := e.closeItem()
+	item.outputIndex = len(e.items)
+	e.items = append(e.items, item)
+	e.open = item
+	frames = append(frames, e.frame("response.output_item.added", map[string]any{
+		"output_index": item.outputIndex,
+		"item":         e.itemObject(item, "in_progress"),
+	}))
+	switch item.kind {
+	case "message":
+		frames = append(frames, e.frame("response.content_part.added", map[string]any{
+			"item_id": item.id, "output_index": item.outputIndex, "content_index": 0,
+			"part": map[string]any{"type": "output_text", "text": "", "annotations": []any{}},
+		}))
+	case "reasoning":
+		frames = append(frames, e.frame("response.reasoning_summary_part.added", map[string]any{
+			"item_id": item.id, "output_index": item.outputIndex, "summary_index": 0,
+			"part": map[string]any{"type": "summary_text", "text": ""},
+		}))
+	}
+	return frames
+}
+
+func (e *responsesStreamEncoder) Encode(event streamEvent) []sseFrame {
+	frames := e.start(event.ID, event.Model)
+	switch event.Kind {
+	case streamEventText:
+		if e.open == nil || e.open.kind != "message" {
+			frames = append(frames, e.openItem(&responsesStreamItem{kind: "message", id: "msg_" + random.GetUUID()})...)
+		}
+		e.open.text.WriteString(event.Text)
+		frames = append(frames, e.frame("response.output_text.delta", map[string]any{
+			"item_id": e.open.id, "output_index": e.open.outputIndex, "content_index": 0, "delta": event.Text,
+		}))
+	case streamEventReasoning:
+		if e.open == nil || e.open.kind != "reasoning" {
+			frames = append(frames, e.openItem(&responsesStreamItem{kind: "reasoning", id: "rs_" + random.GetUUID()})...)
+		}
+		e.open.text.WriteString(event.Text)
+		frames = append(frames, e.frame("response.reasoning_summary_text.delta", map[string]any{
+			"item_id": e.open.id, "output_index": e.open.outputIndex, "summary_index": 0, "delta": event.Text,
+		}))
+	case streamEventToolStart:
+		item := &responsesStreamItem{
+			kind:   "function_call",
+			id:     "fc_" + random.GetUUID(),
+			callID: toolCallID(event.ToolCallID),
+			name:   event.ToolName,
+		}
+		if e.toolItems == nil {
+			e.toolItems = map[int]*responsesStreamItem{}
+		}
+		e.toolItems[event.ToolIndex] = item
+		frames = append(frames, e.openItem(item)...)
+	case streamEventToolArguments:
+		item, ok := e.toolItems[event.ToolIndex]
+		if !ok {
+			return frames
+		}
+		item.text.WriteString(event.Text)
+		frames = append(frames, e.frame("response.function_call_arguments.delta", map[string]any{
+			"item_id": item.id, "output_index": item.outputIndex, "delta": event.Text,
+		}))
+	case streamEventFinish:
+		e.finishReason = event.FinishReason
+	case streamEventUsage:
+		e.usage = event.Usage
+	case streamEventError:
+		frames = append(frames, e.frame("error", map[string]any{"code": "upstream_error", "message": event.Error}))
+	}
+	return frames
+}
+
+func (e *responsesStreamEncoder) Close() []sseFrame {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	frames := e.start("", "")
+	frames = append(frames, e.closeItem()...)
+	status := "completed"
+	eventType := "response.completed"
+	var incomplete any
+	switch e.finishReason {
+	case "length":
+		status, eventType = "incomplete", "response.incomplete"
+		incomplete = map[string]any{"reason": "max_output_tokens"}
+	case "content_filter":
+		status, eventType = "incomplete", "response.incomplete"
+		incomplete = map[string]any{"reason": "content_filter"}
+	}
+	response := e.snapshot(status)
+	response["incomplete_details"] = incomplete
+	if usage := responsesUsageFromChat(e.usage); usage != nil {
+		response["usage"] = usage
+	}
+	return append(frames, e.frame(eventType, map[string]any{"response": response}))
+}
@@ -0,0 +1,313 @@
+package textconv
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yeying-community/router/internal/relay/relaymode"
+)
+
+func TestChatRequestFromMessagesConvertsToolsImagesAndThinking(t *testing.T) {
+	raw := []byte(`{
+		"model":"claude-sonnet-4-6",
+		"system":"be brief",
+		"max_tokens":2048,
+		"thinking":{"type":"enabled","budget_tokens":8000},
+		"tools":[{"name":"get_weather","description":"weather","input_schema":{"type":"object"}}],
+		"tool_choice":{"type":"any"},
+		"messages":[
+			{"role":"user","content":[{"type":"text","text":"look"},{"type":"image","source":{"type":"base64","media_type":"image/png","data":"AAA"}}]},
+			{"role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Paris"}}]},
+			{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"sunny"}]}
+		]
+	}`)
+
+	req, err := ChatRequestFromMessages(raw)
+	if err != nil {
+		t.Fatalf("ChatRequestFromMessages returned error: %v", err)
+	}
+	if len(req.Messages) != 4 {
+		t.Fatalf("len(req.Messages) = %d, want 4", len(req.Messages))
+	}
+	if req.Messages[0].Role != "system" || req.Messages[0].StringContent() != "be brief" {
+		t.Fatalf("req.Messages[0] = %#v, want system prompt", req.Messages[0])
+	}
+	parts := req.Messages[1].ParseContent()
+	if len(parts) != 2 || parts[1].ImageURL == nil || parts[1].ImageURL.Url != "data:image/png;base64,AAA" {
+		t.Fatalf("user content = %#v, want text and data URL image", parts)
+	}
+	if len(req.Messages[2].ToolCalls) != 1 || req.Messages[2].ToolCalls[0].Function.Arguments != `{"city":"Paris"}` {
+		t.Fatalf("assistant tool calls = %#v, want get_weather call", req.Messages[2].ToolCalls)
+	}
+	if req.Messages[3].Role != "tool" || req.Messages[3].ToolCallId != "toolu_1" {
+		t.Fatalf("req.Messages[3] = %#v, want tool result", req.Messages[3])
+	}
+	if len(req.Tools) != 1 || req.ToolChoice != "required" {
+		t.Fatalf("tools = %#v choice = %#v, want one tool and required", req.Tools, req.ToolChoice)
+	}
+	if req.ReasoningEffort == nil || *req.ReasoningEffort == "" {
+		t.Fatalf("req.ReasoningEffort = %v, want effort derived from thinking budget", req.ReasoningEffort)
+	}
+}
+
+func TestConvertRequestChatToMessagesMergesToolResults(t *testing.T) {
+	raw := []byte(`{
+		"model":"gpt-4.1",
+		"messages":[
+			{"role":"system","content":"sys"},
+			{"role":"user","content":"weather?"},
+			{"role":"assistant","content":null,"tool_calls":[
+				{"id":"call_1","type":"function","function":{"name":"a","arguments":"{}"}},
+				{"id":"call_2","type":"function","function":{"name":"b","arguments":"{\"x\":1}"}}
+			]},
+			{"role":"tool","tool_call_id":"call_1","content":"one"},
+			{"role":"tool","tool_call_id":"call_2","content":"two"}
+		],
+		"tools":[{"type":"function","function":{"name":"a","parameters":{"type":"object"}}}]
+	}`)
+
+	body, _, err := ConvertRequest(raw, relaymode.ChatCompletions, relaymode.Messages, "claude-sonnet-4-6")
+	if err != nil {
+		t.Fatalf("ConvertRequest returned error: %v", err)
+	}
+	payload := struct {
+		Model     string `json:"model"`
+		MaxTokens int    `json:"max_tokens"`
+		Messages  []struct {
+			Role    string           `json:"role"`
+			Content []map[string]any `json:"content"`
+		} `json:"messages"`
+	}{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("json.Unmarshal returned error: %v", err)
+	}
+	if payload.Model != "claude-sonnet-4-6" || payload.MaxTokens == 0 {
+		t.Fatalf("payload = %s, want mapped model and default max_tokens", body)
+	}
+	if len(payload.Messages) != 3 {
+		t.Fatalf("len(payload.Messages) = %d, want 3: %s", len(payload.Messages), body)
+	}
+	last := payload.Messages[2]
+	if last.Role != "user" || len(last.Content) != 2 || last.Content[0]["type"] != "tool_result" {
+		t.Fatalf("last message = %#v, want merged tool_result blocks", last)
+	}
+}
+
+func TestChatRequestFromResponsesRejectsPreviousResponseID(t *testing.T) {
+	_, err := ChatRequestFromResponses([]byte(`{"model":"gpt-4.1","input":"hi","previous_response_id":"resp_1"}`))
+	if !errors.Is(err, ErrStatefulResponsesRequest) {
+		t.Fatalf("err = %v, want ErrStatefulResponsesRequest", err)
+	}
+}
+
+func TestConvertResponseMessagesToChatKeepsCacheUsage(t *testing.T) {
+	raw := []byte(`{
+		"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-6",
+		"content":[{"type":"thinking","thinking":"hmm","signature":"sig"},{"type":"text","text":"hi"},{"type":"tool_use","id":"toolu_1","name":"f","input":{"a":1}}],
+		"stop_reason":"tool_use",
+		"usage":{"input_tokens":10,"output_tokens":5,"cache_read_input_tokens":20,"cache_creation_input_tokens":3}
+	}`)
+
+	converted, err := ConvertResponse(raw, relaymode.Messages, relaymode.ChatCompletions)
+	if err != nil {
+		t.Fatalf("ConvertResponse returned error: %v", err)
+	}
+	completion := chatCompletion{}
+	if err := json.Unmarshal(converted, &completion); err != nil {
+		t.Fatalf("json.Unmarshal returned error: %v", err)
+	}
+	choice := completion.Choices[0]
+	if choice.FinishReason != "tool_calls" || choice.Message.ReasoningContent != "hmm" || len(choice.Message.ToolCalls) != 1 {
+		t.Fatalf("choice = %#v, want reasoning, tool call and tool_calls finish", choice)
+	}
+	if completion.Usage == nil || completion.Usage.PromptTokens != 33 || completion.Usage.CompletionTokens != 5 {
+		t.Fatalf("usage = %#v, want prompt=33 completion=5", completion.Usage)
+	}
+	if completion.Usage.PromptTokensDetails == nil || completion.Usage.PromptTokensDetails.CachedTokens != 20 {
+		t.Fatalf("prompt details = %#v, want cached=20", completion.Usage.PromptTokensDetails)
+	}
+}
+
+func TestConvertResponseChatToResponses(t *testing.T) {
+	raw := []byte(`{
+		"id":"chatcmpl-1","object":"chat.completion","created":1,"model":"gpt-4.1",
+		"choices":[{"index":0,"message":{"role":"assistant","content":"done"},"finish_reason":"length"}],
+		"usage":{"prompt_tokens":7,"completion_tokens":3,"total_tokens":10}
+	}`)
+
+	converted, err := ConvertResponse(raw, relaymode.ChatCompletions, relaymode.Responses)
+	if err != nil {
+		t.Fatalf("ConvertResponse returned error: %v", err)
+	}
+	payload := map[string]any{}
+	if err := json.Unmarshal(converted, &payload); err != nil {
+		t.Fatalf("json.Unmarshal returned error: %v", err)
+	}
+	if payload["object"] != "response" || payload["status"] != "incomplete" {
+		t.Fatalf("payload = %s, want incomplete response object", converted)
+	}
+	usage, _ := payload["usage"].(map[string]any)
+	if usage["input_tokens"] != float64(7) || usage["output_tokens"] != float64(3) {
+		t.Fatalf("usage = %#v, want input=7 output=3", usage)
+	}
+}
+
+func newTestWriter(t *testing.T, upstreamMode int, downstreamMode int, stream bool) (*ResponseWriter, *httptest.ResponseRecorder) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	return NewResponseWriter(c.Writer, upstreamMode, downstreamMode, stream), recorder
+}
+
+func TestResponseWriterConvertsChatStreamToMessages(t *testing.T) {
+	writer, recorder := newTestWriter(t, relaymode.ChatCompletions, relaymode.Messages, true)
+	writer.WriteHeader(http.StatusOK)
+	upstream := strings.Join([]string{
+		`data: {"id":"chatcmpl-1","model":"gpt-4.1","choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}]}`,
+		``,
+		`data: {"id":"chatcmpl-1","model":"gpt-4.1","choices":[{"index":0,"delta":{"content":"lo"}}]}`,
+		``,
+		`data: {"id":"chatcmpl-1","model":"gpt-4.1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"f","arguments":""}}]}}]}`,
+		``,
+		`data: {"id":"chatcmpl-1","model":"gpt-4.1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"a\":1}"}}]},"finish_reason":"tool_calls"}]}`,
+		``,
+		`data: {"id":"chatcmpl-1","model":"gpt-4.1","choices":[],"usage":{"prompt_tokens":4,"completion_tokens":6,"total_tokens":10}}`,
+		``,
+		`data: [DONE]`,
+		``,
+	}, "\n")
+	// split the payload mid-line to exercise buffering
+	_, _ = writer.Write([]byte(upstream[:37]))
+	_, _ = writer.Write([]byte(upstream[37:]))
+	if err := writer.Finish(); err != nil {
+		t.Fatalf("Finish returned error: %v", err)
+	}
+
+	body := recorder.Body.String()
+	for _, want := range []string{
+		"event: message_start",
+		`"text":"Hel","type":"text_delta"`,
+		`"type":"tool_use"`,
+		`"partial_json":"{\"a\":1}"`,
+		`"stop_reason":"tool_use"`,
+		`"output_tokens":6`,
+		"event: message_stop",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("converted stream missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "[DONE]") {
+		t.Fatalf("converted messages stream should not contain [DONE]:\n%s", body)
+	}
+}
+
+func TestResponseWriterConvertsMessagesStreamToChat(t *testing.T) {
+	writer, recorder := newTestWriter(t, relaymode.Messages, relaymode.ChatCompletions, true)
+	upstream := strings.Join([]string{
+		`event: message_start`,
+		`data: {"type":"message_start","message":{"id":"msg_1","model":"claude-sonnet-4-6","usage":{"input_tokens":12,"output_tokens":1}}}`,
+		``,
+		`event: content_block_start`,
+		`data: {"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+		``,
+		`event: content_block_delta`,
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"plan"}}`,
+		``,
+		`event: content_block_start`,
+		`data: {"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`,
+		``,
+		`event: content_block_delta`,
+		`data: {"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"answer"}}`,
+		``,
+		`event: message_delta`,
+		`data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":8}}`,
+		``,
+		`event: message_stop`,
+		`data: {"type":"message_stop"}`,
+		``,
+	}, "\n")
+	_, _ = writer.Write([]byte(upstream))
+	if err := writer.Finish(); err != nil {
+		t.Fatalf("Finish returned error: %v", err)
+	}
+
+	body := recorder.Body.String()
+	for _, want := range []string{
+		`"reasoning_content":"plan"`,
+		`"content":"answer"`,
+		`"finish_reason":"stop"`,
+		`"prompt_tokens":12`,
+		`"completion_tokens":8`,
+		"data: [DONE]",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("converted stream missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "event:") {
+		t.Fatalf("chat stream should not contain named events:\n%s", body)
+	}
+}
+
+func TestResponseWriterConvertsChatStreamToResponses(t *testing.T) {
+	writer, recorder := newTestWriter(t, relaymode.ChatCompletions, relaymode.Responses, true)
+	upstream := strings.Join([]string{
+		`data: {"id":"chatcmpl-1","model":"gpt-4.1","choices":[{"index":0,"delta":{"content":"hi"},"finish_reason":"stop"}]}`,
+		``,
+		`data: {"id":"chatcmpl-1","model":"gpt-4.1","choices":[],"usage":{"prompt_tokens":2,"completion_tokens":1,"total_tokens":3}}`,
+		``,
+		`data: [DONE]`,
+		``,
+	}, "\n")
+	_, _ = writer.Write([]byte(upstream))
+	if err := writer.Finish(); err != nil {
+		t.Fatalf("Finish returned error: %v", err)
+	}
+
+	body := recorder.Body.String()
+	for _, want := range []string{
+		"event: response.created",
+		"event: response.output_item.added",
+		`"type":"response.output_text.delta"`,
+		"event: response.output_text.done",
+		"event: response.completed",
+		`"input_tokens":2`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("converted stream missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestResponseWriterConvertsBufferedResponse(t *testing.T) {
+	writer, recorder := newTestWriter(t, relaymode.Responses, relaymode.ChatCompletions, false)
+	writer.Header().Set("Content-Length", "999")
+	writer.WriteHeader(http.StatusOK)
+	_, _ = writer.Write([]byte(`{"id":"resp_1","object":"response","model":"gpt-4.1","status":"completed",`))
+	_, _ = writer.Write([]byte(`"output":[{"type":"message","role":"assistant","content":[{"type":"output_text","text":"ok"}]}],"usage":{"input_tokens":3,"output_tokens":1,"total_tokens":4}}`))
+	if err := writer.Finish(); err != nil {
+		t.Fatalf("Finish returned error: %v", err)
+	}
+	if err := writer.Finish(); err != nil {
+		t.Fatalf("second Finish returned error: %v", err)
+	}
+
+	if recorder.Header().Get("Content-Length") != "" {
+		t.Fatalf("Content-Length = %q, want removed", recorder.Header().Get("Content-Length"))
+	}
+	completion := chatCompletion{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &completion); err != nil {
+		t.Fatalf("json.Unmarshal returned error: %v: %s", err, recorder.Body.String())
+	}
+	if completion.Object != "chat.completion" || completion.Choices[0].Message.Content == nil || *completion.Choices[0].Message.Content != "ok" {
+		t.Fatalf("completion = %s, want chat completion with text ok", recorder.Body.String())
+	}
+}
@@ -0,0 +1,173 @@
+package textconv
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeying-community/router/common/logger"
+)
+
+// ResponseWriter wraps the downstream gin writer so adaptors can keep writing
+// the upstream protocol while the client receives its own protocol.
+type ResponseWriter struct {
+	gin.ResponseWriter
+	upstreamMode   int
+	downstreamMode int
+	stream         bool
+	status         int
+	buffer         bytes.Buffer
+	event          string
+	decoder        streamDecoder
+	encoder        streamEncoder
+	wroteStream    bool
+	finished       bool
+}
+
+func NewResponseWriter(w gin.ResponseWriter, upstreamMode int, downstreamMode int, stream bool) *ResponseWriter {
+	return &ResponseWriter{
+		ResponseWriter: w,
+		upstreamMode:   upstreamMode,
+		downstreamMode: downstreamMode,
+		stream:         stream,
+		status:         http.StatusOK,
+		decoder:        newStreamDecoder(upstreamMode),
+		encoder:        newStreamEncoder(downstreamMode),
+	}
+}
+
+func (w *ResponseWriter) WriteHeader(code int) {
+	w.status = code
+	w.Header().Del("Content-Length")
+	if code >= http.StatusBadRequest && !w.wroteStream {
+		// errors are plain JSON even on streaming requests
+		w.stream = false
+	}
+	if w.stream {
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *ResponseWriter) Status() int {
+	return w.status
+}
+
+func (w *ResponseWriter) WriteHeaderNow() {
+	if w.stream {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *ResponseWriter) Write(data []byte) (int, error) {
+	if w.finished {
+		return w.ResponseWriter.Write(data)
+	}
+	w.buffer.Write(data)
+	if w.stream {
+		w.drainLines()
+	}
+	return len(data), nil
+}
+
+func (w *ResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *ResponseWriter) Flush() {
+	if w.stream {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *ResponseWriter) drainLines() {
+	for {
+		index := bytes.IndexByte(w.buffer.Bytes(), '\n')
+		if index < 0 {
+			return
+		}
+		line := string(w.buffer.Next(index + 1))
+		w.handleLine(strings.TrimRight(line, "\r\n"))
+	}
+}
+
+func (w *ResponseWriter) handleLine(line string) {
+	switch {
+	case line == "":
+		w.event = ""
+	case strings.HasPrefix(line, "event:"):
+		w.event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+	case strings.HasPrefix(line, "data:"):
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "" {
+			return
+		}
+		for _, event := range w.decoder.Decode(w.event, data) {
+			w.writeFrames(w.encoder.Encode(event))
+		}
+	}
+}
+
+func (w *ResponseWriter) writeFrames(frames []sseFrame) {
+	if len(frames) == 0 {
+		return
+	}
+	var out bytes.Buffer
+	for _, frame := range frames {
+		if frame.Event != "" {
+			out.WriteString("event: " + frame.Event + "\n")
+		}
+		if text, ok := frame.Data.(string); ok {
+			out.WriteString("data: " + text + "\n\n")
+			continue
+		}
+		payload, err := json.Marshal(frame.Data)
+		if err != nil {
+			logger.SysError("error marshalling converted stream frame: " + err.Error())
+			continue
+		}
+		out.WriteString("data: ")
+		out.Write(payload)
+		out.WriteString("\n\n")
+	}
+	w.wroteStream = true
+	_, _ = w.ResponseWriter.Write(out.Bytes())
+	w.ResponseWriter.Flush()
+}
+
+// Finish flushes whatever is still buffered in the downstream protocol. It is
+// safe to call more than once.
+func (w *ResponseWriter) Finish() error {
+	if w.finished {
+		return nil
+	}
+	w.finished = true
+	if w.stream {
+		if w.buffer.Len() > 0 {
+			w.handleLine(strings.TrimRight(w.buffer.String(), "\r\n"))
+			w.buffer.Reset()
+		}
+		if w.wroteStream {
+			w.writeFrames(w.encoder.Close())
+		}
+		return nil
+	}
+	body := w.buffer.Bytes()
+	if w.status >= http.StatusBadRequest && isGeminiMode(w.downstreamMode) {
+		body = convertGeminiError(body, w.status)
+		w.Header().Set("Content-Type", "application/json")
+	} else if w.status < http.StatusBadRequest && len(body) > 0 {
+		converted, err := ConvertResponse(body, w.upstreamMode, w.downstreamMode)
+		if err != nil {
+			logger.SysError("error converting upstream response: " + err.Error())
+		} else {
+			body = converted
+			w.Header().Set("Content-Type", "application/json")
+		}
+	}
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(w.status)
+	_, err := w.ResponseWriter.Write(body)
+	return err
+}
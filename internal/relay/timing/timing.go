@@ -0,0 +1,114 @@
+package timing
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeying-community/router/common/ctxkey"
+)
+
+// Tracker records when one upstream attempt reached each stage. All marks
+// after the first are ignored, so handlers can mark on every chunk.
+type Tracker struct {
+	mu           sync.Mutex
+	requestAt    time.Time
+	firstByteAt  time.Time
+	firstTokenAt time.Time
+	finishedAt   time.Time
+}
+
+// Stats are the tracker's durations, relative to when the upstream request
+// was sent, so channels can be compared regardless of local processing.
+type Stats struct {
+	FirstByteMs           int64
+	FirstTokenMs          int64
+	UpstreamLatencyMs     int64
+	OutputTokensPerSecond float64
+}
+
+// Begin starts a tracker for a new attempt on c, replacing the previous
+// attempt's.
+func Begin(c *gin.Context) *Tracker {
+	tracker := &Tracker{}
+	if c != nil {
+		c.Set(ctxkey.RelayTiming, tracker)
+	}
+	return tracker
+}
+
+func FromContext(c *gin.Context) *Tracker {
+	if c == nil {
+		return nil
+	}
+	value, ok := c.Get(ctxkey.RelayTiming)
+	if !ok {
+		return nil
+	}
+	tracker, _ := value.(*Tracker)
+	return tracker
+}
+
+// MarkRequestSent is called right before the upstream request goes out.
+func MarkRequestSent(c *gin.Context) {
+	FromContext(c).mark(func(t *Tracker) *time.Time { return &t.requestAt })
+}
+
+// MarkFirstByte is called once the upstream response headers arrived.
+func MarkFirstByte(c *gin.Context) {
+	FromContext(c).mark(func(t *Tracker) *time.Time { return &t.firstByteAt })
+}
+
+// MarkFirstToken is called by stream handlers when a chunk carries generated
+// content, reasoning or tool call output, not just a role or usage.
+func MarkFirstToken(c *gin.Context) {
+	FromContext(c).mark(func(t *Tracker) *time.Time { return &t.firstTokenAt })
+}
+
+// MarkFinished is called once the upstream response was fully relayed.
+func MarkFinished(c *gin.Context) {
+	FromContext(c).mark(func(t *Tracker) *time.Time { return &t.finishedAt })
+}
+
+func (t *Tracker) mark(field func(*Tracker) *time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if at := field(t); at.IsZero() {
+		*at = time.Now()
+	}
+}
+
+// Stats computes the durations. An attempt that was never marked finished is
+// taken as finishing now; throughput is measured from the first token, or
+// from the request for non-stream responses.
+func (t *Tracker) Stats(completionTokens int) Stats {
+	if t == nil {
+		return Stats{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.requestAt.IsZero() {
+		return Stats{}
+	}
+	finishedAt := t.finishedAt
+	if finishedAt.IsZero() {
+		finishedAt = time.Now()
+	}
+	stats := Stats{UpstreamLatencyMs: finishedAt.Sub(t.requestAt).Milliseconds()}
+	if !t.firstByteAt.IsZero() {
+		stats.FirstByteMs = t.firstByteAt.Sub(t.requestAt).Milliseconds()
+	}
+	generationStart := t.requestAt
+	if !t.firstTokenAt.IsZero() {
+		stats.FirstTokenMs = t.firstTokenAt.Sub(t.requestAt).Milliseconds()
+		generationStart = t.firstTokenAt
+	}
+	if generation := finishedAt.Sub(generationStart); completionTokens > 0 && generation >= 10*time.Millisecond {
+		stats.OutputTokensPerSecond = math.Round(float64(completionTokens)/generation.Seconds()*100) / 100
+	}
+	return stats
+}
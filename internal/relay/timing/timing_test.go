@@ -0,0 +1,54 @@
+package timing
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTrackerKeepsFirstMarks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	MarkFirstToken(c) // no tracker yet: ignored
+	tracker := Begin(c)
+	base := time.Now()
+	tracker.requestAt = base
+	tracker.firstByteAt = base.Add(200 * time.Millisecond)
+	tracker.firstTokenAt = base.Add(500 * time.Millisecond)
+	tracker.finishedAt = base.Add(2500 * time.Millisecond)
+	MarkFirstByte(c)
+	MarkFirstToken(c)
+	MarkFinished(c)
+
+	stats := FromContext(c).Stats(100)
+	if stats.FirstByteMs != 200 || stats.FirstTokenMs != 500 || stats.UpstreamLatencyMs != 2500 {
+		t.Fatalf("stats = %+v, want 200/500/2500 ms", stats)
+	}
+	if stats.OutputTokensPerSecond != 50 {
+		t.Fatalf("throughput = %v, want 50 tokens/s after the first token", stats.OutputTokensPerSecond)
+	}
+}
+
+func TestTrackerWithoutRequestReportsNothing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	tracker := Begin(c)
+	MarkFirstToken(c)
+	if stats := tracker.Stats(10); stats != (Stats{}) {
+		t.Fatalf("stats = %+v, want zero when the request was never sent", stats)
+	}
+	var missing *Tracker
+	if stats := missing.Stats(10); stats != (Stats{}) {
+		t.Fatalf("nil tracker stats = %+v", stats)
+	}
+
+	tracker = Begin(c)
+	tracker.requestAt = time.Now().Add(-time.Second)
+	tracker.finishedAt = tracker.requestAt.Add(time.Second)
+	stats := tracker.Stats(20)
+	if stats.FirstTokenMs != 0 || stats.OutputTokensPerSecond != 20 {
+		t.Fatalf("stats = %+v, want throughput over the whole request without a first token", stats)
+	}
+}
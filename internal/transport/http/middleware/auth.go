@@ -18,8 +18,10 @@ import (
 	"github.com/yeying-community/router/common/logger"
 	"github.com/yeying-community/router/common/network"
 	"github.com/yeying-community/router/common/random"
+	"github.com/yeying-community/router/common/tracing"
 	"github.com/yeying-community/router/internal/admin/model"
 	"github.com/yeying-community/router/internal/relay/responsestate"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var (
@@ -120,6 +122,12 @@ func hydrateResponsesRelayContext(c *gin.Context) {
 	if len(state.ItemIDs) > 0 {
 		c.Set(ctxkey.ResponsesItemIDs, state.ItemIDs)
 	}
+	// a previous response stored by the router can be replayed on any channel,
+	// so the request no longer depends on the upstream that created it.
+	if state.PreviousResponseID != "" && responsestate.HasState(state.PreviousResponseID, c.GetString(ctxkey.Id)) {
+		c.Set(ctxkey.ResponsesLocalState, true)
+		return
+	}
 	if state.PreviousResponseID != "" || state.HasToolOutput {
 		c.Set(ctxkey.ResponsesStatefulRequest, true)
 	}
@@ -297,6 +305,8 @@ func RootAuth() func(c *gin.Context) {
 
 func TokenAuth() func(c *gin.Context) {
 	return func(c *gin.Context) {
+		span, endSpan := tracing.StartRequestSpan(c, "relay.auth")
+		defer endSpan()
 		ctx := c.Request.Context()
 		rawAuth := resolveTokenAuthorization(c)
 		if rawAuth == "" {
@@ -323,6 +333,8 @@ func TokenAuth() func(c *gin.Context) {
 			c.Set(ctxkey.Id, token.UserId)
 			c.Set(ctxkey.TokenId, token.Id)
 			c.Set(ctxkey.TokenName, token.Name)
+			c.Set(ctxkey.TokenRPMLimit, token.RPMLimit)
+			c.Set(ctxkey.TokenTPMLimit, token.TPMLimit)
 		}
 		if err != nil {
 			logger.Loginf(c.Request.Context(), "token auth failed: %v", err)
@@ -381,6 +393,11 @@ func TokenAuth() func(c *gin.Context) {
 		}
 
 		logger.Debugf(c.Request.Context(), "[login] token auth success user=%s tokenId=%s", token.UserId, token.Id)
+		span.SetAttributes(
+			attribute.String("router.user_id", token.UserId),
+			attribute.String("router.token_id", token.Id),
+		)
+		endSpan()
 
 		c.Next()
 	}
@@ -58,6 +58,7 @@ func CORS() gin.HandlerFunc {
 		"Origin",
 		"X-Requested-With",
 		"X-Request-Id",
+		"X-Goog-Api-Key",
 	}
 	return cors.New(corsConfig)
 }
@@ -2,16 +2,20 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"math/rand"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/yeying-community/router/common/ctxkey"
 	"github.com/yeying-community/router/common/logger"
+	"github.com/yeying-community/router/common/tracing"
 	"github.com/yeying-community/router/internal/admin/model"
+	"github.com/yeying-community/router/internal/relay/capability"
 	relaychannel "github.com/yeying-community/router/internal/relay/channel"
 	"github.com/yeying-community/router/internal/relay/responsestate"
 	"github.com/yeying-community/router/internal/relay/routeobs"
@@ -21,10 +25,13 @@ type ModelRequest struct {
 	Model string `json:"model" form:"model"`
 }
 
-func pickChannelByPriority(channels []*model.Channel, ignoreFirstPriority bool) *model.Channel {
+func pickChannelByPriority(channels []*model.Channel, strategy string, ignoreFirstPriority bool) *model.Channel {
 	if len(channels) == 0 {
 		return nil
 	}
+	if strategy == model.ChannelSelectionStrategyCostOptimized {
+		return model.SelectCostOrderedChannel(channels, ignoreFirstPriority)
+	}
 	endIdx := len(channels)
 	firstPriority := channels[0].GetPriority()
 	if firstPriority > 0 {
@@ -42,7 +49,7 @@ func pickChannelByPriority(channels []*model.Channel, ignoreFirstPriority bool)
 	if len(targets) == 0 {
 		return nil
 	}
-	return targets[rand.Intn(len(targets))]
+	return model.SelectChannelInTier(strategy, targets)
 }
 
 func channelIDInList(channels []*model.Channel, channelID string) bool {
@@ -105,6 +112,10 @@ func selectPinnedResponsesChannel(c *gin.Context, userGroup string, requestModel
 		lookupIDs = append(lookupIDs, previousResponseID)
 	}
 	channelID, ok, conflict := responsestate.LookupRoutes(lookupIDs)
+	if conflict && c.GetBool(ctxkey.ResponsesLocalState) {
+		logger.RelayInfof(c.Request.Context(), "DISTRIBUTE decision=miss reason=responses_route_replay_local user_id=%s group=%s response_id=%s endpoint=%s", c.GetString(ctxkey.Id), userGroup, previousResponseID, requestPath)
+		return nil, false
+	}
 	if conflict {
 		logger.RelayWarnf(c.Request.Context(), "DISTRIBUTE decision=state_conflict reason=responses_route_multiple_channels user_id=%s group=%s response_id=%s item_ids=%s endpoint=%s", c.GetString(ctxkey.Id), userGroup, previousResponseID, strings.Join(itemIDs, ","), requestPath)
 		return nil, true
@@ -182,6 +193,7 @@ func selectEntitlementChannelForRequest(ctx context.Context, c *gin.Context, use
 	}
 	var lastStats model.ChannelCandidateStats
 	var lastErr error
+	limitSaturated, otherwiseUnavailable := false, false
 	for _, candidate := range candidateSources {
 		groupID := strings.TrimSpace(candidate.groupID)
 		if groupID == "" {
@@ -190,35 +202,139 @@ func selectEntitlementChannelForRequest(ctx context.Context, c *gin.Context, use
 		if pinnedChannel, ok := selectPinnedResponsesChannel(c, groupID, requestModel, requestPath); ok {
 			return pinnedChannel, groupID, candidate.source, nil
 		}
-		candidates, stats, err := model.CacheListSatisfiedChannelsForRequestWithStats(groupID, requestModel, requestPath)
+		candidates, stats, err := model.CacheListSatisfiedChannelsForRequestWithStats(groupID, requestModel, requestPath, RequestFeatures(c))
 		lastStats = stats
 		if err != nil {
 			lastErr = err
-			logger.RelayWarnf(ctx, "DISTRIBUTE decision=skip reason=list_candidates_failed user_id=%s group=%s model=%s endpoint=%s listed_candidates=%d endpoint_filtered_candidates=%d error=%q", userID, groupID, requestModel, requestPath, stats.ListedCount, stats.EndpointFilteredCount, err.Error())
+			otherwiseUnavailable = true
+			logger.RelayWarnf(ctx, "DISTRIBUTE decision=skip reason=list_candidates_failed user_id=%s group=%s model=%s endpoint=%s listed_candidates=%d endpoint_filtered_candidates=%d capability_filtered_candidates=%d margin_guard_filtered_candidates=%d error=%q", userID, groupID, requestModel, requestPath, stats.ListedCount, stats.EndpointFilteredCount, stats.CapabilityFilteredCount, stats.MarginGuardFilteredCount, err.Error())
 			continue
 		}
-		channel := pickChannelByPriority(candidates, false)
+		sessionKey, sessionSource := sessionAffinityKey(c, userID, groupID, requestModel)
+		if channel, ok := selectSessionAffinityChannel(c, sessionKey, sessionSource, userID, groupID, requestModel, requestPath, candidates); ok {
+			c.Set(ctxkey.SessionAffinityKey, sessionKey)
+			recordRouteDecision(c, "session_affinity", groupID, requestModel, requestPath, candidates, stats.FilteredCandidates, channel, "pinned_"+sessionSource)
+			return channel, groupID, candidate.source, nil
+		}
+		strategy := model.ResolveGroupChannelSelectionStrategy(groupID)
+		channel := pickChannelByPriority(candidates, strategy, false)
 		if channel == nil {
-			logger.RelayWarnf(ctx, "DISTRIBUTE decision=skip reason=no_available_channel user_id=%s group=%s model=%s endpoint=%s listed_candidates=%d endpoint_filtered_candidates=%d", userID, groupID, requestModel, requestPath, stats.ListedCount, stats.EndpointFilteredCount)
+			if stats.AllLimitSaturated() {
+				limitSaturated = true
+			} else {
+				otherwiseUnavailable = true
+			}
+			logger.RelayWarnf(ctx, "DISTRIBUTE decision=skip reason=no_available_channel user_id=%s group=%s model=%s endpoint=%s listed_candidates=%d endpoint_filtered_candidates=%d capability_filtered_candidates=%d margin_guard_filtered_candidates=%d limit_filtered_candidates=%d features=%s", userID, groupID, requestModel, requestPath, stats.ListedCount, stats.EndpointFilteredCount, stats.CapabilityFilteredCount, stats.MarginGuardFilteredCount, stats.LimitFilteredCount, strings.Join(RequestFeatures(c).Names(), ","))
 			continue
 		}
-		recordRouteDecision(c, "automatic", groupID, requestModel, requestPath, candidates, stats.FilteredCandidates, channel, "priority_random")
+		if sessionKey != "" {
+			c.Set(ctxkey.SessionAffinityKey, sessionKey)
+		}
+		recordRouteDecision(c, "automatic", groupID, requestModel, requestPath, candidates, stats.FilteredCandidates, channel, "priority_"+strategy)
 		return channel, groupID, candidate.source, nil
 	}
 	message := fmt.Sprintf("当前权益下对于模型 %s 无可用渠道", requestModel)
 	if strings.TrimSpace(initialGroup) != "" {
 		message = fmt.Sprintf("当前分组 %s 下对于模型 %s 无可用渠道", initialGroup, requestModel)
 	}
+	if limitSaturated && !otherwiseUnavailable {
+		return nil, "", nil, &channelLimitSaturatedError{message: message}
+	}
 	if lastErr != nil {
-		logger.RelayErrorf(ctx, "DISTRIBUTE decision=abort reason=no_entitlement_channel user_id=%s group=%s model=%s endpoint=%s listed_candidates=%d endpoint_filtered_candidates=%d message=%q error=%q", userID, initialGroup, requestModel, requestPath, lastStats.ListedCount, lastStats.EndpointFilteredCount, message, lastErr.Error())
+		logger.RelayErrorf(ctx, "DISTRIBUTE decision=abort reason=no_entitlement_channel user_id=%s group=%s model=%s endpoint=%s listed_candidates=%d endpoint_filtered_candidates=%d capability_filtered_candidates=%d margin_guard_filtered_candidates=%d message=%q error=%q", userID, initialGroup, requestModel, requestPath, lastStats.ListedCount, lastStats.EndpointFilteredCount, lastStats.CapabilityFilteredCount, lastStats.MarginGuardFilteredCount, message, lastErr.Error())
 	} else {
 		logger.RelayErrorf(ctx, "DISTRIBUTE decision=abort reason=no_entitlement_channel user_id=%s group=%s model=%s endpoint=%s message=%q", userID, initialGroup, requestModel, requestPath, message)
 	}
 	return nil, "", nil, fmt.Errorf("%s", message)
 }
 
+// selectChannelWaitingForLimits selects a channel for the request, falling
+// back to other models when none can serve it, and queues while every
+// candidate channel is saturated. The request is aborted when the wait fails.
+func selectChannelWaitingForLimits(c *gin.Context, userID string, initialGroup string, initialSource *model.UserEntitlementSource, requestModel string) (*model.Channel, string, *model.UserEntitlementSource, string, error) {
+	ctx := c.Request.Context()
+	var limitWaiter *model.ChannelLimitWaiter
+	for {
+		channel, group, source, err := selectEntitlementChannelForRequest(ctx, c, userID, initialGroup, initialSource, requestModel)
+		if err != nil && !strings.HasPrefix(err.Error(), "state_incompatible: ") {
+			if fallbackChannel, fallbackModel, ok := selectFallbackModelChannel(c, userID, initialGroup, requestModel); ok {
+				return fallbackChannel, initialGroup, initialSource, fallbackModel, nil
+			}
+		}
+		var saturatedErr *channelLimitSaturatedError
+		if !errors.As(err, &saturatedErr) {
+			return channel, group, source, requestModel, err
+		}
+		if limitWaiter == nil {
+			priority := 0
+			if initialSource != nil {
+				priority = initialSource.Priority
+			}
+			limitWaiter = model.NewChannelLimitWaiter(priority)
+		}
+		if !waitForChannelLimit(c, limitWaiter, userID, initialGroup, requestModel) {
+			return nil, initialGroup, initialSource, requestModel, err
+		}
+	}
+}
+
+// channelLimitSaturatedError reports that every candidate channel was only
+// left out because its limits are exhausted, so the request may wait.
+type channelLimitSaturatedError struct {
+	message string
+}
+
+func (e *channelLimitSaturatedError) Error() string {
+	return e.message
+}
+
+// waitForChannelLimit queues the request until a saturated channel may have
+// room again. It returns false after aborting the request when the queue is
+// full or the wait timed out.
+func waitForChannelLimit(c *gin.Context, waiter *model.ChannelLimitWaiter, userID string, groupID string, requestModel string) bool {
+	ctx := c.Request.Context()
+	startedAt := time.Now()
+	err := waiter.Wait(ctx)
+	if err == nil {
+		logger.RelayInfof(ctx, "DISTRIBUTE decision=dequeue reason=channel_limit_saturated user_id=%s group=%s model=%s endpoint=%s waited_ms=%d", userID, groupID, requestModel, c.Request.URL.Path, time.Since(startedAt).Milliseconds())
+		return true
+	}
+	reason, message := "channel_limit_queue_timeout", "渠道繁忙，排队等待超时，请稍后重试"
+	switch {
+	case errors.Is(err, model.ErrChannelLimitQueueFull):
+		reason, message = "channel_limit_queue_full", "渠道繁忙，排队请求已满，请稍后重试"
+	case ctx.Err() != nil:
+		reason = "client_aborted"
+	}
+	logger.RelayWarnf(ctx, "DISTRIBUTE decision=abort reason=%s user_id=%s group=%s model=%s endpoint=%s waited_ms=%d", reason, userID, groupID, requestModel, c.Request.URL.Path, time.Since(startedAt).Milliseconds())
+	c.Set(ctxkey.RelayErrorCode, reason)
+	abortWithMessage(c, http.StatusTooManyRequests, message)
+	return false
+}
+
+// RequestFeatures detects the capability-relevant traits of the JSON body read
+// by TokenAuth, once per request, so distribution and relay retries filter
+// channels the same way. Bodies that were not buffered are never read here.
+func RequestFeatures(c *gin.Context) capability.Features {
+	if cached, ok := c.Get(ctxkey.RequestFeatures); ok {
+		if features, ok := cached.(capability.Features); ok {
+			return features
+		}
+	}
+	features := capability.Features{}
+	if strings.Contains(c.ContentType(), "json") {
+		if body, ok := c.Get(ctxkey.KeyRequestBody); ok {
+			if raw, ok := body.([]byte); ok {
+				features = capability.Detect(raw)
+			}
+		}
+	}
+	c.Set(ctxkey.RequestFeatures, features)
+	return features
+}
+
 func responseStateConflict(c *gin.Context) bool {
-	if c == nil {
+	if c == nil || c.GetBool(ctxkey.ResponsesLocalState) {
 		return false
 	}
 	ids := responseItemIDsFromContext(c)
@@ -234,9 +350,11 @@ func responseStateConflict(c *gin.Context) bool {
 
 func Distribute() func(c *gin.Context) {
 	return func(c *gin.Context) {
+		span, endSpan := tracing.StartRequestSpan(c, "relay.distribute")
+		defer endSpan()
 		ctx := c.Request.Context()
 		userId := c.GetString(ctxkey.Id)
-		requestModel := c.GetString(ctxkey.RequestModel)
+		requestModel := resolveRequestModelAlias(ctx, c, userId, c.GetString(ctxkey.RequestModel))
 		userGroup, entitlementSource, groupErr := model.ResolveUserEntitlementGroupForModel(ctx, userId, requestModel)
 		if groupErr != nil {
 			logger.RelayWarnf(ctx, "DISTRIBUTE decision=abort reason=entitlement_group_missing user_id=%s model=%s endpoint=%s error=%q", userId, requestModel, c.Request.URL.Path, groupErr.Error())
@@ -249,6 +367,9 @@ func Distribute() func(c *gin.Context) {
 			c.Set(ctxkey.EntitlementSourceId, entitlementSource.SourceID)
 			c.Set(ctxkey.EntitlementSourceName, entitlementSource.SourceName)
 		}
+		if !applyRelayRateLimits(c, userId, userGroup, entitlementSource) {
+			return
+		}
 		var channel *model.Channel
 		var err error
 		channelId, ok := c.Get(ctxkey.SpecificChannelId)
@@ -267,7 +388,11 @@ func Distribute() func(c *gin.Context) {
 			}
 			recordRouteDecision(c, "specific_channel", userGroup, requestModel, c.Request.URL.Path, []*model.Channel{channel}, nil, channel, "explicit")
 		} else {
-			if channel, userGroup, entitlementSource, err = selectEntitlementChannelForRequest(ctx, c, userId, userGroup, entitlementSource, requestModel); err != nil {
+			channel, userGroup, entitlementSource, requestModel, err = selectChannelWaitingForLimits(c, userId, userGroup, entitlementSource, requestModel)
+			if c.IsAborted() {
+				return
+			}
+			if err != nil {
 				statusCode := http.StatusServiceUnavailable
 				message := err.Error()
 				if strings.HasPrefix(message, "state_incompatible: ") {
@@ -288,6 +413,12 @@ func Distribute() func(c *gin.Context) {
 		}
 		logger.Debugf(ctx, "user id %s, user group: %s, request model: %s, using channel #%s", userId, userGroup, requestModel, channel.Id)
 		SetupContextForSelectedChannel(c, channel, requestModel)
+		span.SetAttributes(
+			attribute.String("router.group", userGroup),
+			attribute.String("router.model", requestModel),
+			attribute.String("router.channel_id", channel.Id),
+		)
+		endSpan()
 		c.Next()
 	}
 }
@@ -311,9 +442,11 @@ func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, mode
 	}
 	c.Set(ctxkey.ModelMapping, mapping)
 	c.Set(ctxkey.OriginalModel, modelName) // for retry
-	c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", channel.Key))
-	c.Set(ctxkey.BaseURL, channel.ResolveAPIBaseURL(""))
 	cfg, _ := channel.LoadConfig()
+	keyID, key := model.SelectChannelKey(channel, cfg.KeyStrategy)
+	c.Set(ctxkey.ChannelKeyId, keyID)
+	c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", key))
+	c.Set(ctxkey.BaseURL, channel.ResolveAPIBaseURL(""))
 	// Some protocol-specific fields are still persisted in channel.other.
 	if channel.Other != nil {
 		switch channelProtocol {
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeying-community/router/common"
+	"github.com/yeying-community/router/common/config"
+	"github.com/yeying-community/router/internal/admin/model"
+	"github.com/yeying-community/router/internal/relay/capability"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func useDistributorTestChannels(t *testing.T, channelIDs ...string) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=private"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(
+		&model.Channel{},
+		&model.ChannelModel{},
+		&model.ChannelModelEndpoint{},
+		&model.ChannelModelEndpointTestResult{},
+		&model.ChannelModelEndpointPolicy{},
+		&model.ChannelModelPriceComponent{},
+		&model.GroupModelChannel{},
+		&model.ChannelLimit{},
+	); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	previousDB, previousMemoryCache, previousRedis := model.DB, config.MemoryCacheEnabled, common.RedisEnabled
+	model.DB, config.MemoryCacheEnabled, common.RedisEnabled = db, true, false
+	t.Cleanup(func() {
+		db.Where("1 = 1").Delete(&model.ChannelLimit{})
+		_ = model.SyncChannelLimitsRuntimeWithDB(db)
+		model.DB, config.MemoryCacheEnabled, common.RedisEnabled = previousDB, previousMemoryCache, previousRedis
+	})
+	for _, channelID := range channelIDs {
+		if err := db.Create(&model.Channel{Id: channelID, Name: channelID, Protocol: "openai", Status: model.ChannelStatusEnabled}).Error; err != nil {
+			t.Fatalf("create channel: %v", err)
+		}
+		if err := db.Create(&model.GroupModelChannel{Group: "default", Model: "gpt-4o", ChannelId: channelID}).Error; err != nil {
+			t.Fatalf("create route: %v", err)
+		}
+		if _, err := model.SaveChannelLimitWithDB(db, model.ChannelLimit{ChannelID: channelID, MaxConcurrency: 1, Enabled: true}); err != nil {
+			t.Fatalf("save channel limit: %v", err)
+		}
+	}
+	if err := model.SyncChannelLimitsRuntimeWithDB(db); err != nil {
+		t.Fatalf("sync channel limits: %v", err)
+	}
+	model.InitChannelCache()
+}
+
+func TestSelectChannelWaitingForLimitsQueuesUntilAChannelIsReleased(t *testing.T) {
+	useDistributorTestChannels(t, "channel-1", "channel-2")
+	var releases []func()
+	for _, channelID := range []string{"channel-1", "channel-2"} {
+		release, _, ok := model.AcquireChannelLimit(channelID, "gpt-4o")
+		if !ok {
+			t.Fatalf("acquire %s: saturated before the test started", channelID)
+		}
+		releases = append(releases, release)
+	}
+	defer func() {
+		for _, release := range releases[1:] {
+			release()
+		}
+	}()
+
+	_, stats, err := model.CacheListSatisfiedChannelsForRequestWithStats("default", "gpt-4o", "/v1/moderations", capability.Features{})
+	if err != nil || stats.LimitFilteredCount != 2 || stats.CandidateCount != 0 || !stats.AllLimitSaturated() {
+		t.Fatalf("stats = %+v, err = %v, want both channels dropped as saturated", stats, err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/v1/moderations", nil)
+	selected := make(chan *model.Channel, 1)
+	go func() {
+		channel, _, _, _, err := selectChannelWaitingForLimits(c, "user-1", "default", nil, "gpt-4o")
+		if err != nil {
+			t.Errorf("select channel: %v", err)
+		}
+		selected <- channel
+	}()
+
+	select {
+	case channel := <-selected:
+		t.Fatalf("selected %v while every channel is saturated, want the request queued", channel)
+	case <-time.After(100 * time.Millisecond):
+	}
+	releases[0]()
+	select {
+	case channel := <-selected:
+		if channel == nil || channel.Id != "channel-1" {
+			t.Fatalf("selected %v, want the released channel-1", channel)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("queued request was not released")
+	}
+	if c.IsAborted() {
+		t.Fatal("released request was aborted")
+	}
+}
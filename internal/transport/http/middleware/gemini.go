@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yeying-community/router/common"
+	"github.com/yeying-community/router/common/ctxkey"
+	"github.com/yeying-community/router/common/logger"
+	relaymodel "github.com/yeying-community/router/internal/relay/model"
+	"github.com/yeying-community/router/internal/relay/relaymode"
+	"github.com/yeying-community/router/internal/relay/textconv"
+)
+
+const (
+	GeminiActionGenerateContent       = "generateContent"
+	GeminiActionStreamGenerateContent = "streamGenerateContent"
+	GeminiActionCountTokens           = "countTokens"
+	GeminiActionEmbedContent          = "embedContent"
+	GeminiActionBatchEmbedContents    = "batchEmbedContents"
+)
+
+type geminiIngressRoute struct {
+	mode   int
+	path   string
+	stream bool
+}
+
+var geminiIngressRoutes = map[string]geminiIngressRoute{
+	GeminiActionGenerateContent:       {mode: relaymode.GeminiGenerateContent, path: "/v1/chat/completions"},
+	GeminiActionStreamGenerateContent: {mode: relaymode.GeminiGenerateContent, path: "/v1/chat/completions", stream: true},
+	GeminiActionCountTokens:           {mode: relaymode.GeminiCountTokens, path: "/v1/chat/completions"},
+	GeminiActionEmbedContent:          {mode: relaymode.GeminiEmbedContent, path: "/v1/embeddings"},
+	GeminiActionBatchEmbedContents:    {mode: relaymode.GeminiBatchEmbedContents, path: "/v1/embeddings"},
+}
+
+// GeminiIngress accepts native Gemini API calls
+// (/v1beta/models/{model}:{action}) and rewrites them into the OpenAI request
+// they are relayed as, so auth, distribution, billing and fallback behave
+// exactly like the OpenAI routes. Responses and errors are converted back to
+// the Gemini shape on the way out.
+func GeminiIngress() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		modelName, action := parseGeminiModelAction(c.Param("model"))
+		route, ok := geminiIngressRoutes[action]
+		if !ok || modelName == "" {
+			abortWithGeminiError(c, http.StatusNotFound, fmt.Sprintf("unsupported gemini method: %s", strings.TrimPrefix(c.Param("model"), "/")))
+			return
+		}
+		if strings.TrimSpace(c.GetHeader("Authorization")) == "" {
+			if key := geminiAPIKey(c); key != "" {
+				c.Request.Header.Set("Authorization", "Bearer "+key)
+			}
+		}
+		rawBody, err := common.GetRequestBody(c)
+		if err != nil {
+			abortWithGeminiError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		var converted *relaymodel.GeneralOpenAIRequest
+		switch route.mode {
+		case relaymode.GeminiEmbedContent, relaymode.GeminiBatchEmbedContents:
+			converted, err = textconv.EmbeddingRequestFromGemini(rawBody, modelName, route.mode == relaymode.GeminiBatchEmbedContents)
+		default:
+			converted, err = textconv.ChatRequestFromGemini(rawBody, modelName, route.stream)
+		}
+		if err != nil {
+			abortWithGeminiError(c, http.StatusBadRequest, "invalid gemini request: "+err.Error())
+			return
+		}
+		body, err := json.Marshal(converted)
+		if err != nil {
+			abortWithGeminiError(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.Set(ctxkey.KeyRequestBody, body)
+		c.Set(ctxkey.GeminiAction, action)
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Request.ContentLength = int64(len(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Request.URL.Path = route.path
+		logger.RelayInfof(c.Request.Context(), "GEMINI_INGRESS action=%s model=%s relay_path=%s stream=%t", action, modelName, route.path, route.stream)
+
+		upstreamMode := relaymode.GetByPath(route.path)
+		if route.mode == relaymode.GeminiCountTokens {
+			upstreamMode = route.mode
+		}
+		writer := textconv.NewResponseWriter(c.Writer, upstreamMode, route.mode, route.stream)
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+		if err := writer.Finish(); err != nil {
+			logger.Errorf(c.Request.Context(), "write gemini response failed: %s", err.Error())
+		}
+	}
+}
+
+func parseGeminiModelAction(value string) (string, string) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "/")
+	index := strings.LastIndex(value, ":")
+	if index < 0 {
+		return value, ""
+	}
+	return strings.TrimSpace(value[:index]), strings.TrimSpace(value[index+1:])
+}
+
+func geminiAPIKey(c *gin.Context) string {
+	if key := strings.TrimSpace(c.GetHeader("x-goog-api-key")); key != "" {
+		return key
+	}
+	return strings.TrimSpace(c.Query("key"))
+}
+
+func abortWithGeminiError(c *gin.Context, statusCode int, message string) {
+	c.JSON(statusCode, textconv.GeminiErrorBody(statusCode, message))
+	c.Abort()
+}
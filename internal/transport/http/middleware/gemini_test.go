@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yeying-community/router/common"
+)
+
+func TestGeminiIngressRelaysGenerateContentAsChat(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	var (
+		relayPath string
+		relayBody string
+		relayAuth string
+	)
+	engine.POST("/v1beta/models/:model", GeminiIngress(), func(c *gin.Context) {
+		relayPath = c.Request.URL.Path
+		relayAuth = c.GetHeader("Authorization")
+		body, _ := common.GetRequestBody(c)
+		relayBody = string(body)
+		c.Data(http.StatusOK, "application/json", []byte(`{"id":"chatcmpl-1","model":"gpt-5","choices":[{"index":0,"message":{"role":"assistant","content":"pong"},"finish_reason":"stop"}]}`))
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1beta/models/gemini-2.5-flash:generateContent?key=sk-test", bytes.NewBufferString(`{"contents":[{"role":"user","parts":[{"text":"ping"}]}]}`))
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, req)
+
+	if relayPath != "/v1/chat/completions" {
+		t.Fatalf("relay path = %q, want /v1/chat/completions", relayPath)
+	}
+	if relayAuth != "Bearer sk-test" {
+		t.Fatalf("Authorization = %q, want key query param", relayAuth)
+	}
+	if !strings.Contains(relayBody, `"model":"gemini-2.5-flash"`) || !strings.Contains(relayBody, `"content":[{"text":"ping","type":"text"}]`) {
+		t.Fatalf("relay body = %s, want chat request", relayBody)
+	}
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"parts":[{"text":"pong"}]`) {
+		t.Fatalf("response = %d %s, want gemini candidate", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestGeminiIngressRejectsUnknownMethod(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/v1beta/models/:model", GeminiIngress(), func(c *gin.Context) {
+		t.Fatal("unexpected relay for unsupported method")
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1beta/models/gemini-2.5-flash:predict", bytes.NewBufferString(`{}`))
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusNotFound || !strings.Contains(recorder.Body.String(), `"status":"NOT_FOUND"`) {
+		t.Fatalf("response = %d %s, want gemini NOT_FOUND error", recorder.Code, recorder.Body.String())
+	}
+}
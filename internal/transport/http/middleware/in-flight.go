@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/yeying-community/router/common/graceful"
+)
+
+// InFlight keeps shutdown waiting until the request's handler returned, which
+// for realtime websockets is when the session closed.
+func InFlight() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		done := graceful.BeginRequest()
+		defer done()
+		c.Next()
+	}
+}
@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeying-community/router/common"
+	"github.com/yeying-community/router/common/ctxkey"
+	"github.com/yeying-community/router/common/logger"
+	"github.com/yeying-community/router/internal/admin/model"
+)
+
+// RewriteRequestModel switches the buffered JSON request to modelName so the
+// relay and its billing see the model actually served. The model the client
+// asked for is kept in ctxkey.ClientRequestModel. Bodies without a top-level
+// model field, e.g. multipart uploads or Gemini paths, cannot be rewritten.
+func RewriteRequestModel(c *gin.Context, modelName string) bool {
+	normalizedModel := strings.TrimSpace(modelName)
+	if c == nil || normalizedModel == "" || !strings.Contains(c.ContentType(), "json") {
+		return false
+	}
+	raw, err := common.GetRequestBody(c)
+	if err != nil || len(raw) == 0 {
+		return false
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return false
+	}
+	if _, ok := fields["model"]; !ok {
+		return false
+	}
+	encodedModel, err := json.Marshal(normalizedModel)
+	if err != nil {
+		return false
+	}
+	fields["model"] = encodedModel
+	updated, err := json.Marshal(fields)
+	if err != nil {
+		return false
+	}
+	if c.GetString(ctxkey.ClientRequestModel) == "" {
+		c.Set(ctxkey.ClientRequestModel, c.GetString(ctxkey.RequestModel))
+	}
+	c.Set(ctxkey.KeyRequestBody, updated)
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(updated))
+	c.Request.ContentLength = int64(len(updated))
+	c.Set(ctxkey.RequestModel, normalizedModel)
+	return true
+}
+
+// ModelAllowedForToken applies the token's model whitelist to a model the
+// router substitutes for the requested one.
+func ModelAllowedForToken(c *gin.Context, modelName string) bool {
+	available := c.GetString(ctxkey.AvailableModels)
+	return available == "" || isModelInList(modelName, available)
+}
+
+// resolveRequestModelAlias replaces a virtual model with one of its targets.
+// The alias is looked up in the group that entitles the user to it, or in the
+// user's default group when the alias is not itself an entitled model.
+func resolveRequestModelAlias(ctx context.Context, c *gin.Context, userID string, requestModel string) string {
+	if strings.TrimSpace(requestModel) == "" || !model.ModelAliasConfigured(requestModel) {
+		return requestModel
+	}
+	groupID, _, err := model.ResolveUserEntitlementGroupForModel(ctx, userID, requestModel)
+	if err != nil {
+		if groupID, _, err = model.ResolveUserEntitlementGroupForModel(ctx, userID, ""); err != nil {
+			return requestModel
+		}
+	}
+	target, ok := model.ResolveModelAlias(groupID, requestModel)
+	if !ok {
+		return requestModel
+	}
+	if !ModelAllowedForToken(c, target) {
+		logger.RelayWarnf(ctx, "DISTRIBUTE decision=skip reason=alias_target_not_allowed user_id=%s group=%s alias=%s target=%s", userID, groupID, requestModel, target)
+		return requestModel
+	}
+	if !RewriteRequestModel(c, target) {
+		logger.RelayWarnf(ctx, "DISTRIBUTE decision=skip reason=alias_body_not_rewritable user_id=%s group=%s alias=%s target=%s", userID, groupID, requestModel, target)
+		return requestModel
+	}
+	logger.RelayInfof(ctx, "DISTRIBUTE decision=alias user_id=%s group=%s alias=%s target=%s", userID, groupID, requestModel, target)
+	return target
+}
+
+// selectFallbackModelChannel walks the group's fallback chain of fromModel when
+// no channel can serve it, and switches the request to the first fallback
+// model that has an available channel.
+func selectFallbackModelChannel(c *gin.Context, userID string, groupID string, fromModel string) (*model.Channel, string, bool) {
+	ctx := c.Request.Context()
+	requestPath := c.Request.URL.Path
+	for _, next := range model.ResolveModelFallbacks(groupID, fromModel) {
+		if !ModelAllowedForToken(c, next) {
+			continue
+		}
+		candidates, stats, err := model.CacheListSatisfiedChannelsForRequestWithStats(groupID, next, requestPath, RequestFeatures(c))
+		if err != nil {
+			continue
+		}
+		strategy := model.ResolveGroupChannelSelectionStrategy(groupID)
+		channel := pickChannelByPriority(candidates, strategy, false)
+		if channel == nil {
+			continue
+		}
+		if !RewriteRequestModel(c, next) {
+			logger.RelayWarnf(ctx, "DISTRIBUTE decision=skip reason=fallback_body_not_rewritable user_id=%s group=%s from_model=%s to_model=%s endpoint=%s", userID, groupID, fromModel, next, requestPath)
+			return nil, "", false
+		}
+		c.Set(ctxkey.ModelFallbackSource, fromModel)
+		c.Set(ctxkey.RelayModelHop, 1)
+		logger.RelayWarnf(ctx, "DISTRIBUTE decision=model_fallback user_id=%s group=%s from_model=%s to_model=%s channel_id=%s endpoint=%s", userID, groupID, fromModel, next, channel.Id, requestPath)
+		recordRouteDecision(c, "model_fallback", groupID, next, requestPath, candidates, stats.FilteredCandidates, channel, "priority_"+strategy)
+		return channel, next, true
+	}
+	return nil, "", false
+}
+
+// RemainingFallbackModels returns the fallback models not yet tried for the
+// request, following the chain of the model the client originally resolved
+// to even after Distribute has already hopped along it.
+func RemainingFallbackModels(c *gin.Context, groupID string, currentModel string) []string {
+	source := strings.TrimSpace(c.GetString(ctxkey.ModelFallbackSource))
+	if source == "" {
+		source = currentModel
+	}
+	chain := model.ResolveModelFallbacks(groupID, source)
+	for index, item := range chain {
+		if item == currentModel {
+			return chain[index+1:]
+		}
+	}
+	return chain
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeying-community/router/common"
+	"github.com/yeying-community/router/common/ctxkey"
+)
+
+func newModelRouteTestContext(t *testing.T, contentType string, body string) *gin.Context {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", contentType)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = req
+	c.Set(ctxkey.RequestModel, "gpt-4o")
+	return c
+}
+
+func TestRewriteRequestModelSwitchesBodyAndKeepsClientModel(t *testing.T) {
+	c := newModelRouteTestContext(t, "application/json", `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}],"stream":true}`)
+
+	if !RewriteRequestModel(c, "gpt-4.1") {
+		t.Fatal("RewriteRequestModel = false, want true")
+	}
+	if !RewriteRequestModel(c, "claude-sonnet") {
+		t.Fatal("second RewriteRequestModel = false, want true")
+	}
+	raw, err := common.GetRequestBody(c)
+	if err != nil {
+		t.Fatalf("GetRequestBody: %v", err)
+	}
+	payload := map[string]any{}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		t.Fatalf("unmarshal rewritten body: %v", err)
+	}
+	if payload["model"] != "claude-sonnet" || payload["stream"] != true {
+		t.Fatalf("rewritten body = %s", raw)
+	}
+	streamed, _ := io.ReadAll(c.Request.Body)
+	if !bytes.Equal(streamed, raw) {
+		t.Fatalf("request body = %s, want %s", streamed, raw)
+	}
+	if c.GetString(ctxkey.RequestModel) != "claude-sonnet" || c.GetString(ctxkey.ClientRequestModel) != "gpt-4o" {
+		t.Fatalf("request model = %q, client model = %q", c.GetString(ctxkey.RequestModel), c.GetString(ctxkey.ClientRequestModel))
+	}
+}
+
+func TestRewriteRequestModelRejectsBodiesWithoutModel(t *testing.T) {
+	for _, c := range []*gin.Context{
+		newModelRouteTestContext(t, "application/json", `{"contents":[]}`),
+		newModelRouteTestContext(t, "multipart/form-data; boundary=x", "--x--"),
+	} {
+		if RewriteRequestModel(c, "gpt-4.1") {
+			t.Fatalf("RewriteRequestModel(%q) = true, want false", c.ContentType())
+		}
+		if c.GetString(ctxkey.RequestModel) != "gpt-4o" {
+			t.Fatalf("request model changed to %q", c.GetString(ctxkey.RequestModel))
+		}
+	}
+}
+
+func TestModelAllowedForToken(t *testing.T) {
+	c := newModelRouteTestContext(t, "application/json", `{}`)
+	if !ModelAllowedForToken(c, "gpt-4.1") {
+		t.Fatal("unrestricted token should allow any model")
+	}
+	c.Set(ctxkey.AvailableModels, "gpt-4o,claude-sonnet")
+	if ModelAllowedForToken(c, "gpt-4.1") || !ModelAllowedForToken(c, "claude-sonnet") {
+		t.Fatal("token whitelist not applied")
+	}
+}
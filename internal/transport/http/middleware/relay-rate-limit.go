@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yeying-community/router/common/ctxkey"
+	"github.com/yeying-community/router/common/logger"
+	"github.com/yeying-community/router/internal/admin/model"
+)
+
+// relayRateLimitScopes collects the RPM/TPM limits of the calling token and,
+// from its package or group, of the user.
+func relayRateLimitScopes(c *gin.Context, userID string, groupID string, source *model.UserEntitlementSource) []model.RateLimitScope {
+	scopes := make([]model.RateLimitScope, 0, 2)
+	if tokenID := c.GetString(ctxkey.TokenId); tokenID != "" {
+		scope := model.RateLimitScope{
+			Key: "token:" + tokenID,
+			RPM: c.GetInt(ctxkey.TokenRPMLimit),
+			TPM: c.GetInt(ctxkey.TokenTPMLimit),
+		}
+		if scope.RPM > 0 || scope.TPM > 0 {
+			scopes = append(scopes, scope)
+		}
+	}
+	if userID != "" {
+		sourceType, sourceID := "", ""
+		if source != nil {
+			sourceType, sourceID = source.SourceType, source.SourceID
+		}
+		rpm, tpm := model.ResolveUserRateLimit(groupID, sourceType, sourceID)
+		if rpm > 0 || tpm > 0 {
+			scopes = append(scopes, model.RateLimitScope{Key: "user:" + userID, RPM: rpm, TPM: tpm})
+		}
+	}
+	return scopes
+}
+
+// applyRelayRateLimits counts the request against the token and user limits
+// and sets the x-ratelimit-* headers OpenAI clients read. Rejected requests
+// get 429 with retry-after; the return value reports whether to continue.
+func applyRelayRateLimits(c *gin.Context, userID string, groupID string, source *model.UserEntitlementSource) bool {
+	scopes := relayRateLimitScopes(c, userID, groupID, source)
+	if len(scopes) == 0 {
+		return true
+	}
+	decision := model.TakeRateLimits(scopes)
+	setRateLimitHeaders(c, decision)
+	if decision.Allowed {
+		c.Set(ctxkey.RateLimitScopes, scopes)
+		return true
+	}
+	retryAfter := int(math.Ceil(decision.RetryAfter.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Header("retry-after", strconv.Itoa(retryAfter))
+	logger.RelayWarnf(c.Request.Context(), "DISTRIBUTE decision=abort reason=rate_limit_exceeded limit=%s user_id=%s group=%s endpoint=%s retry_after=%d", decision.Reason, userID, groupID, c.Request.URL.Path, retryAfter)
+	message := fmt.Sprintf("请求频率超过每分钟请求数限制，请在 %d 秒后重试", retryAfter)
+	if decision.Reason == model.RateLimitReasonTokens {
+		message = fmt.Sprintf("请求频率超过每分钟 Token 数限制，请在 %d 秒后重试", retryAfter)
+	}
+	c.Set(ctxkey.RelayErrorType, decision.Reason)
+	c.Set(ctxkey.RelayErrorCode, "rate_limit_exceeded")
+	abortWithMessage(c, http.StatusTooManyRequests, message)
+	return false
+}
+
+func setRateLimitHeaders(c *gin.Context, decision model.RateLimitDecision) {
+	if usage := decision.Requests; usage != nil {
+		c.Header("x-ratelimit-limit-requests", strconv.Itoa(usage.Limit))
+		c.Header("x-ratelimit-remaining-requests", strconv.Itoa(usage.Remaining))
+		c.Header("x-ratelimit-reset-requests", formatRateLimitReset(usage.Reset))
+	}
+	if usage := decision.Tokens; usage != nil {
+		c.Header("x-ratelimit-limit-tokens", strconv.Itoa(usage.Limit))
+		c.Header("x-ratelimit-remaining-tokens", strconv.Itoa(usage.Remaining))
+		c.Header("x-ratelimit-reset-tokens", formatRateLimitReset(usage.Reset))
+	}
+}
+
+// formatRateLimitReset renders durations the way OpenAI does, e.g. "6m0s",
+// "1s" or "120ms".
+func formatRateLimitReset(d time.Duration) string {
+	if d <= 0 {
+		return "0s"
+	}
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(time.Second).String()
+}
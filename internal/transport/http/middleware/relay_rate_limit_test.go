@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeying-community/router/common"
+	"github.com/yeying-community/router/common/ctxkey"
+)
+
+func TestApplyRelayRateLimitsSetsOpenAIHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	previousRedisEnabled := common.RedisEnabled
+	common.RedisEnabled = false
+	t.Cleanup(func() { common.RedisEnabled = previousRedisEnabled })
+
+	newContext := func() (*gin.Context, *httptest.ResponseRecorder) {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+		c.Set(ctxkey.TokenId, "rate-limit-test-token")
+		c.Set(ctxkey.TokenRPMLimit, 1)
+		c.Set(ctxkey.TokenTPMLimit, 5000)
+		return c, recorder
+	}
+
+	c, recorder := newContext()
+	if !applyRelayRateLimits(c, "", "", nil) {
+		t.Fatal("first request should be allowed")
+	}
+	header := recorder.Header()
+	if header.Get("x-ratelimit-limit-requests") != "1" || header.Get("x-ratelimit-remaining-requests") != "0" || header.Get("x-ratelimit-reset-requests") != "1m0s" {
+		t.Fatalf("request headers = %v", header)
+	}
+	if header.Get("x-ratelimit-limit-tokens") != "5000" || header.Get("x-ratelimit-remaining-tokens") != "5000" || header.Get("x-ratelimit-reset-tokens") != "0s" {
+		t.Fatalf("token headers = %v", header)
+	}
+	if _, ok := c.Get(ctxkey.RateLimitScopes); !ok {
+		t.Fatal("allowed request should carry its scopes for TPM accounting")
+	}
+
+	c, recorder = newContext()
+	if applyRelayRateLimits(c, "", "", nil) {
+		t.Fatal("second request should be rate limited")
+	}
+	if recorder.Code != http.StatusTooManyRequests || recorder.Header().Get("retry-after") == "" {
+		t.Fatalf("status = %d, retry-after = %q", recorder.Code, recorder.Header().Get("retry-after"))
+	}
+	if c.GetString(ctxkey.RelayErrorCode) != "rate_limit_exceeded" || c.GetString(ctxkey.RelayErrorType) != "requests" {
+		t.Fatalf("error = %s/%s", c.GetString(ctxkey.RelayErrorType), c.GetString(ctxkey.RelayErrorCode))
+	}
+}
@@ -1,12 +1,17 @@
 package middleware
 
 import (
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/yeying-community/router/common/config"
 	"github.com/yeying-community/router/common/ctxkey"
 	"github.com/yeying-community/router/common/logger"
+	"github.com/yeying-community/router/internal/admin/model"
+	"github.com/yeying-community/router/internal/metrics"
 	relaychannel "github.com/yeying-community/router/internal/relay/channel"
 	relaylogging "github.com/yeying-community/router/internal/relay/logging"
 	"github.com/yeying-community/router/internal/relay/relaymode"
@@ -23,9 +28,18 @@ func RelayLogger() gin.HandlerFunc {
 			String("ua", c.Request.UserAgent())
 		logger.RelayInfof(c.Request.Context(), begin.Build())
 
+		var firstByte *firstByteWriter
+		if config.PrometheusEnabled {
+			firstByte = &firstByteWriter{ResponseWriter: c.Writer}
+			c.Writer = firstByte
+		}
+
 		c.Next()
 
 		status := c.Writer.Status()
+		if firstByte != nil {
+			observeRelayMetrics(c, status, startedAt, firstByte)
+		}
 		end := relaylogging.NewFields("END").
 			String("method", c.Request.Method).
 			String("path", c.Request.URL.Path).
@@ -63,6 +77,62 @@ func RelayLogger() gin.HandlerFunc {
 	}
 }
 
+// firstByteWriter notes when the response first reached the client. Stream
+// guards and converters wrap it, so for a held-back stream this is when the
+// first token was released.
+type firstByteWriter struct {
+	gin.ResponseWriter
+	at time.Time
+}
+
+func (w *firstByteWriter) Write(data []byte) (int, error) {
+	if w.at.IsZero() && len(data) > 0 {
+		w.at = time.Now()
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *firstByteWriter) WriteString(data string) (int, error) {
+	if w.at.IsZero() && len(data) > 0 {
+		w.at = time.Now()
+	}
+	return w.ResponseWriter.WriteString(data)
+}
+
+func observeRelayMetrics(c *gin.Context, status int, startedAt time.Time, firstByte *firstByteWriter) {
+	observation := metrics.RelayObservation{
+		Model:    relayMetricsModel(c),
+		Endpoint: relayModeName(c.Request.URL.Path),
+		Channel:  c.GetString(ctxkey.ChannelId),
+		Group:    c.GetString(ctxkey.Group),
+		Status:   strconv.Itoa(status),
+		Duration: time.Since(startedAt),
+		Retries:  c.GetInt(ctxkey.RelayRetryCount),
+	}
+	if !firstByte.at.IsZero() && strings.HasPrefix(firstByte.Header().Get("Content-Type"), "text/event-stream") {
+		observation.FirstByte = firstByte.at.Sub(startedAt)
+	}
+	metrics.ObserveRelay(observation)
+}
+
+// relayMetricsModel returns the model once distribution resolved it to a
+// configured one. The client may send any name, and every distinct label value
+// would add series that are never removed.
+func relayMetricsModel(c *gin.Context) string {
+	modelName := c.GetString(ctxkey.OriginalModel)
+	if modelName == "" || c.GetString(ctxkey.ChannelId) == "" {
+		return ""
+	}
+	if _, ok := c.Get(ctxkey.SpecificChannelId); ok {
+		value, _ := c.Get(ctxkey.ChannelModelConfigs)
+		configs, _ := value.([]model.ChannelModel)
+		if _, ok := model.FindSelectedChannelModelConfig(configs, modelName); !ok {
+			return ""
+		}
+	}
+	return modelName
+}
+
 func relayModeName(path string) string {
 	switch relaymode.GetByPath(path) {
 	case relaymode.ChatCompletions:
@@ -73,12 +143,16 @@ func relayModeName(path string) string {
 		return "completions"
 	case relaymode.Embeddings:
 		return "embeddings"
+	case relaymode.Rerank:
+		return "rerank"
 	case relaymode.Moderations:
 		return "moderations"
 	case relaymode.ImagesGenerations:
 		return "images_generations"
 	case relaymode.ImagesEdits:
 		return "images_edits"
+	case relaymode.ImagesVariations:
+		return "images_variations"
 	case relaymode.Edits:
 		return "edits"
 	case relaymode.AudioSpeech:
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeying-community/router/common/ctxkey"
+	"github.com/yeying-community/router/internal/admin/model"
+)
+
+func TestRelayMetricsModelOnlyLabelsResolvedModels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newContext := func() *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+		c.Set(ctxkey.RequestModel, "made-up-model")
+		return c
+	}
+
+	if got := relayMetricsModel(newContext()); got != "" {
+		t.Fatalf("undistributed request model = %q, want empty", got)
+	}
+
+	c := newContext()
+	c.Set(ctxkey.ChannelId, "channel-1")
+	c.Set(ctxkey.OriginalModel, "gpt-4o")
+	if got := relayMetricsModel(c); got != "gpt-4o" {
+		t.Fatalf("distributed request model = %q, want gpt-4o", got)
+	}
+
+	c = newContext()
+	c.Set(ctxkey.SpecificChannelId, "channel-1")
+	c.Set(ctxkey.ChannelId, "channel-1")
+	c.Set(ctxkey.OriginalModel, "made-up-model")
+	c.Set(ctxkey.ChannelModelConfigs, []model.ChannelModel{{Model: "gpt-4o", Selected: true}})
+	if got := relayMetricsModel(c); got != "" {
+		t.Fatalf("specific channel request for an unconfigured model = %q, want empty", got)
+	}
+	c.Set(ctxkey.OriginalModel, "gpt-4o")
+	if got := relayMetricsModel(c); got != "gpt-4o" {
+		t.Fatalf("specific channel request model = %q, want gpt-4o", got)
+	}
+}
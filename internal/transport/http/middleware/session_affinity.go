@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeying-community/router/common/config"
+	"github.com/yeying-community/router/common/ctxkey"
+	"github.com/yeying-community/router/common/logger"
+	"github.com/yeying-community/router/internal/admin/model"
+	"github.com/yeying-community/router/internal/relay/responsestate"
+)
+
+// sessionAffinityKey derives the sticky-routing key of the request within one
+// user, group and model. It is empty when affinity is disabled or the request
+// carries no session hint.
+func sessionAffinityKey(c *gin.Context, userID string, groupID string, requestModel string) (string, string) {
+	if !config.SessionAffinityEnabled || c == nil {
+		return "", ""
+	}
+	headerValue := ""
+	if header := strings.TrimSpace(config.SessionAffinityHeader); header != "" {
+		headerValue = c.GetHeader(header)
+	}
+	var raw []byte
+	if strings.Contains(c.ContentType(), "json") {
+		if body, ok := c.Get(ctxkey.KeyRequestBody); ok {
+			raw, _ = body.([]byte)
+		}
+	}
+	scope := strings.Join([]string{strings.TrimSpace(userID), strings.TrimSpace(groupID), strings.TrimSpace(requestModel)}, "|")
+	return responsestate.DeriveSessionKey(scope, headerValue, raw)
+}
+
+// selectSessionAffinityChannel returns the channel the session is pinned to
+// when it is still among the eligible candidates and healthy. Stale pins are
+// dropped so the next successful attempt re-pins the session.
+func selectSessionAffinityChannel(c *gin.Context, sessionKey string, source string, userID string, groupID string, requestModel string, requestPath string, candidates []*model.Channel) (*model.Channel, bool) {
+	if sessionKey == "" {
+		return nil, false
+	}
+	channelID, ok := responsestate.LookupSessionRoute(sessionKey)
+	if !ok {
+		return nil, false
+	}
+	var channel *model.Channel
+	for _, candidate := range candidates {
+		if candidate != nil && candidate.Id == channelID {
+			channel = candidate
+			break
+		}
+	}
+	if channel == nil {
+		logger.RelayWarnf(c.Request.Context(), "DISTRIBUTE decision=miss reason=session_route_not_eligible user_id=%s group=%s channel_id=%s model=%s endpoint=%s session_source=%s", userID, groupID, channelID, requestModel, requestPath, source)
+		responsestate.DeleteSessionRoute(sessionKey)
+		return nil, false
+	}
+	if model.GetChannelSelectionStat(channelID).Unhealthy() {
+		logger.RelayWarnf(c.Request.Context(), "DISTRIBUTE decision=miss reason=session_route_unhealthy user_id=%s group=%s channel_id=%s model=%s endpoint=%s session_source=%s", userID, groupID, channelID, requestModel, requestPath, source)
+		responsestate.DeleteSessionRoute(sessionKey)
+		return nil, false
+	}
+	logger.RelayInfof(c.Request.Context(), "DISTRIBUTE decision=pin reason=session_route_match user_id=%s group=%s channel_id=%s model=%s endpoint=%s session_source=%s", userID, groupID, channelID, requestModel, requestPath, source)
+	return channel, true
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeying-community/router/common/config"
+	"github.com/yeying-community/router/common/ctxkey"
+	"github.com/yeying-community/router/internal/admin/model"
+	"github.com/yeying-community/router/internal/relay/responsestate"
+)
+
+func newSessionAffinityTestContext(t *testing.T, body string) *gin.Context {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	previousEnabled := config.SessionAffinityEnabled
+	config.SessionAffinityEnabled = true
+	responsestate.ResetForTest()
+	t.Cleanup(func() {
+		config.SessionAffinityEnabled = previousEnabled
+		responsestate.ResetForTest()
+	})
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = req
+	c.Set(ctxkey.KeyRequestBody, []byte(body))
+	return c
+}
+
+func TestSelectSessionAffinityChannelFollowsHealthyPin(t *testing.T) {
+	c := newSessionAffinityTestContext(t, `{"model":"gpt-5","user":"end-user-1","messages":[{"role":"user","content":"hi"}]}`)
+	key, source := sessionAffinityKey(c, "user-1", "default", "gpt-5")
+	if key == "" || source != responsestate.SessionKeySourceUser {
+		t.Fatalf("session key = (%q, %q), want user source", key, source)
+	}
+	candidates := []*model.Channel{{Id: "channel-a"}, {Id: "channel-b"}}
+
+	if _, ok := selectSessionAffinityChannel(c, key, source, "user-1", "default", "gpt-5", "/v1/chat/completions", candidates); ok {
+		t.Fatal("unpinned session should not select a channel")
+	}
+	responsestate.StoreSessionRoute(key, "channel-b")
+	channel, ok := selectSessionAffinityChannel(c, key, source, "user-1", "default", "gpt-5", "/v1/chat/completions", candidates)
+	if !ok || channel.Id != "channel-b" {
+		t.Fatalf("pinned channel = %+v, want channel-b", channel)
+	}
+	if _, ok := selectSessionAffinityChannel(c, key, source, "user-1", "default", "gpt-5", "/v1/chat/completions", candidates[:1]); ok {
+		t.Fatal("pin outside the candidate list should fall back")
+	}
+	if _, ok := responsestate.LookupSessionRoute(key); ok {
+		t.Fatal("ineligible pin was not cleared")
+	}
+}
+
+func TestSelectSessionAffinityChannelSkipsUnhealthyPin(t *testing.T) {
+	c := newSessionAffinityTestContext(t, `{"model":"gpt-5","messages":[{"role":"user","content":"hi"}]}`)
+	c.Request.Header.Set(config.SessionAffinityHeader, "conv-unhealthy")
+	key, source := sessionAffinityKey(c, "user-1", "default", "gpt-5")
+	if source != responsestate.SessionKeySourceHeader {
+		t.Fatalf("session source = %q, want header", source)
+	}
+	responsestate.StoreSessionRoute(key, "channel-flaky-affinity")
+	for i := 0; i < 10; i++ {
+		model.RecordChannelOutcome("channel-flaky-affinity", false)
+	}
+	candidates := []*model.Channel{{Id: "channel-flaky-affinity"}, {Id: "channel-ok"}}
+	if _, ok := selectSessionAffinityChannel(c, key, source, "user-1", "default", "gpt-5", "/v1/chat/completions", candidates); ok {
+		t.Fatal("unhealthy pin should fall back to normal selection")
+	}
+}
+
+func TestSessionAffinityKeyDisabled(t *testing.T) {
+	c := newSessionAffinityTestContext(t, `{"user":"end-user-1"}`)
+	config.SessionAffinityEnabled = false
+	if key, _ := sessionAffinityKey(c, "user-1", "default", "gpt-5"); key != "" {
+		t.Fatalf("session key with affinity disabled = %q, want empty", key)
+	}
+}
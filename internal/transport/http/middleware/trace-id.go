@@ -4,14 +4,18 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/yeying-community/router/common/helper"
+	"github.com/yeying-community/router/common/tracing"
 )
 
 func TraceID() func(c *gin.Context) {
 	return func(c *gin.Context) {
 		id := resolveTraceID(c)
 		c.Set(helper.TraceIDKey, id)
+		trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("router.trace_id", id))
 		ctx := helper.SetTraceID(c.Request.Context(), id)
 		c.Request = c.Request.WithContext(ctx)
 		c.Header(helper.TraceIDKey, id)
@@ -22,6 +26,7 @@ func TraceID() func(c *gin.Context) {
 func resolveTraceID(c *gin.Context) string {
 	for _, candidate := range []string{
 		strings.TrimSpace(c.GetHeader(helper.TraceIDKey)),
+		tracing.TraceID(c.Request.Context()),
 		parseTraceParent(strings.TrimSpace(c.GetHeader(helper.TraceParentHeader))),
 		strings.TrimSpace(c.GetHeader(helper.XRequestIDHeader)),
 	} {
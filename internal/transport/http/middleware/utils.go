@@ -43,12 +43,16 @@ func normalizeRelayPath(path string) string {
 }
 
 func getRequestModel(c *gin.Context) (string, error) {
+	path := normalizeRelayPath(c.Request.URL.Path)
+	if strings.HasPrefix(path, "/v1/files") {
+		// file uploads carry no model and are streamed by the handler
+		return "", nil
+	}
 	var modelRequest ModelRequest
 	err := common.UnmarshalBodyReusable(c, &modelRequest)
 	if err != nil {
 		return "", fmt.Errorf("common.UnmarshalBodyReusable failed: %w", err)
 	}
-	path := normalizeRelayPath(c.Request.URL.Path)
 	if strings.HasPrefix(path, "/v1/moderations") {
 		if modelRequest.Model == "" {
 			modelRequest.Model = "text-moderation-stable"
@@ -69,6 +73,13 @@ func getRequestModel(c *gin.Context) (string, error) {
 			modelRequest.Model = modelValue
 		}
 	}
+	if strings.HasPrefix(path, "/v1/images/variations") && modelRequest.Model == "" {
+		if modelValue := strings.TrimSpace(c.PostForm("model")); modelValue != "" {
+			modelRequest.Model = modelValue
+		} else {
+			modelRequest.Model = "dall-e-2"
+		}
+	}
 	if strings.HasPrefix(path, "/v1/audio/transcriptions") || strings.HasPrefix(path, "/v1/audio/translations") {
 		if modelRequest.Model == "" {
 			modelRequest.Model = "whisper-1"
@@ -8,11 +8,15 @@ import (
 	auth "github.com/yeying-community/router/internal/admin/controller/auth"
 	adminbilling "github.com/yeying-community/router/internal/admin/controller/billing"
 	channel "github.com/yeying-community/router/internal/admin/controller/channel"
+	channellimit "github.com/yeying-community/router/internal/admin/controller/channellimit"
 	dashboard "github.com/yeying-community/router/internal/admin/controller/dashboard"
 	entitlement "github.com/yeying-community/router/internal/admin/controller/entitlement"
 	flow "github.com/yeying-community/router/internal/admin/controller/flow"
 	group "github.com/yeying-community/router/internal/admin/controller/group"
+	hedgepolicy "github.com/yeying-community/router/internal/admin/controller/hedgepolicy"
 	log "github.com/yeying-community/router/internal/admin/controller/log"
+	modelroute "github.com/yeying-community/router/internal/admin/controller/modelroute"
+	moderation "github.com/yeying-community/router/internal/admin/controller/moderation"
 	option "github.com/yeying-community/router/internal/admin/controller/option"
 	plan "github.com/yeying-community/router/internal/admin/controller/plan"
 	task "github.com/yeying-community/router/internal/admin/controller/task"
@@ -156,6 +160,33 @@ func SetApiRouter(engine *gin.Engine) {
 		publicModelsRouter.GET("/:model", admin.RetrieveModel)
 	}
 
+	publicResponsesRouter := engine.Group("/api/v1/public/responses")
+	publicResponsesRouter.Use(middleware.TokenAuth())
+	{
+		publicResponsesRouter.GET("/:id", admin.RetrieveResponse)
+		publicResponsesRouter.GET("/:id/input_items", admin.ListResponseInputItems)
+		publicResponsesRouter.DELETE("/:id", admin.DeleteResponse)
+	}
+
+	publicFilesRouter := engine.Group("/api/v1/public/files")
+	publicFilesRouter.Use(middleware.TokenAuth())
+	{
+		publicFilesRouter.GET("", admin.ListFiles)
+		publicFilesRouter.POST("", admin.UploadFile)
+		publicFilesRouter.GET("/:id", admin.RetrieveFile)
+		publicFilesRouter.DELETE("/:id", admin.DeleteFile)
+		publicFilesRouter.GET("/:id/content", admin.RetrieveFileContent)
+	}
+
+	publicBatchesRouter := engine.Group("/api/v1/public/batches")
+	publicBatchesRouter.Use(middleware.TokenAuth())
+	{
+		publicBatchesRouter.GET("", admin.ListBatches)
+		publicBatchesRouter.POST("", admin.CreateBatch)
+		publicBatchesRouter.GET("/:id", admin.RetrieveBatch)
+		publicBatchesRouter.POST("/:id/cancel", admin.CancelBatch)
+	}
+
 	publicRelayRouter := engine.Group("/api/v1/public")
 	publicRelayRouter.Use(middleware.RelayLogger(), middleware.TokenAuth(), middleware.Distribute())
 	{
@@ -166,9 +197,10 @@ func SetApiRouter(engine *gin.Engine) {
 		publicRelayRouter.POST("/edits", admin.Relay)
 		publicRelayRouter.POST("/images/generations", admin.Relay)
 		publicRelayRouter.POST("/images/edits", admin.Relay)
-		publicRelayRouter.POST("/images/variations", admin.RelayNotImplemented)
+		publicRelayRouter.POST("/images/variations", admin.Relay)
 		publicRelayRouter.POST("/embeddings", admin.Relay)
 		publicRelayRouter.POST("/engines/:model/embeddings", admin.Relay)
+		publicRelayRouter.POST("/rerank", admin.Relay)
 		publicRelayRouter.POST("/audio/transcriptions", admin.Relay)
 		publicRelayRouter.POST("/audio/translations", admin.Relay)
 		publicRelayRouter.POST("/audio/speech", admin.Relay)
@@ -179,11 +211,6 @@ func SetApiRouter(engine *gin.Engine) {
 		publicRelayRouter.POST("/realtime/calls", admin.Relay)
 		publicRelayRouter.POST("/videos", admin.Relay)
 		publicRelayRouter.GET("/videos/:id", admin.Relay)
-		publicRelayRouter.GET("/files", admin.RelayNotImplemented)
-		publicRelayRouter.POST("/files", admin.RelayNotImplemented)
-		publicRelayRouter.DELETE("/files/:id", admin.RelayNotImplemented)
-		publicRelayRouter.GET("/files/:id", admin.RelayNotImplemented)
-		publicRelayRouter.GET("/files/:id/content", admin.RelayNotImplemented)
 		publicRelayRouter.POST("/fine_tuning/jobs", admin.RelayNotImplemented)
 		publicRelayRouter.GET("/fine_tuning/jobs", admin.RelayNotImplemented)
 		publicRelayRouter.GET("/fine_tuning/jobs/:id", admin.RelayNotImplemented)
@@ -309,6 +336,10 @@ func SetApiRouter(engine *gin.Engine) {
 			adminChannelRoute.PUT("/:id/endpoints", channel.UpdateChannelEndpoint)
 			adminChannelRoute.PUT("/:id/policies", channel.UpdateChannelEndpointPolicy)
 			adminChannelRoute.DELETE("/:id/policies/:policy_id", channel.DeleteChannelEndpointPolicy)
+			adminChannelRoute.GET("/:id/keys", channel.GetChannelKeys)
+			adminChannelRoute.POST("/:id/keys", channel.AddChannelKeys)
+			adminChannelRoute.PUT("/:id/keys/:key_id", channel.UpdateChannelKey)
+			adminChannelRoute.DELETE("/:id/keys/:key_id", channel.RetireChannelKey)
 			adminChannelRoute.POST("/:id/tests", channel.TestChannelModels)
 			adminChannelRoute.POST("/", channel.AddChannel)
 			adminChannelRoute.PUT("/", channel.UpdateChannel)
@@ -392,10 +423,43 @@ func SetApiRouter(engine *gin.Engine) {
 			adminLogRoute.GET("/stat", log.GetLogsStat)
 			adminLogRoute.GET("/options", log.GetLogFilterOptions)
 			adminLogRoute.GET("/route/anomalies", log.GetRouteAnomalies)
+			adminLogRoute.GET("/latency", log.GetRelayLatencyStats)
 			adminLogRoute.GET("/search", log.SearchAllLogs)
 			adminLogRoute.GET("/:id", log.GetLog)
 		}
 
+		adminModerationRoute := adminRouter.Group("/moderation")
+		adminModerationRoute.Use(middleware.AdminAuth())
+		{
+			adminModerationRoute.GET("/policies", moderation.GetModerationPolicies)
+			adminModerationRoute.PUT("/policies", moderation.SaveModerationPolicy)
+			adminModerationRoute.DELETE("/policies/:id", moderation.DeleteModerationPolicy)
+		}
+
+		adminModelRouteRoute := adminRouter.Group("/model-route")
+		adminModelRouteRoute.Use(middleware.AdminAuth())
+		{
+			adminModelRouteRoute.GET("/", modelroute.GetModelRoutes)
+			adminModelRouteRoute.PUT("/", modelroute.SaveModelRoute)
+			adminModelRouteRoute.DELETE("/:id", modelroute.DeleteModelRoute)
+		}
+
+		adminHedgePolicyRoute := adminRouter.Group("/hedge-policy")
+		adminHedgePolicyRoute.Use(middleware.AdminAuth())
+		{
+			adminHedgePolicyRoute.GET("/", hedgepolicy.GetHedgePolicies)
+			adminHedgePolicyRoute.PUT("/", hedgepolicy.SaveHedgePolicy)
+			adminHedgePolicyRoute.DELETE("/:id", hedgepolicy.DeleteHedgePolicy)
+		}
+
+		adminChannelLimitRoute := adminRouter.Group("/channel-limit")
+		adminChannelLimitRoute.Use(middleware.AdminAuth())
+		{
+			adminChannelLimitRoute.GET("/", channellimit.GetChannelLimits)
+			adminChannelLimitRoute.PUT("/", channellimit.SaveChannelLimit)
+			adminChannelLimitRoute.DELETE("/:id", channellimit.DeleteChannelLimit)
+		}
+
 		adminGroupRoute := adminRouter.Group("/group")
 		adminGroupRoute.Use(middleware.AdminAuth())
 		{
@@ -11,6 +11,8 @@ import (
 	"github.com/yeying-community/router/common"
 	"github.com/yeying-community/router/common/config"
 	"github.com/yeying-community/router/common/logger"
+	"github.com/yeying-community/router/internal/admin/controller/health"
+	"github.com/yeying-community/router/internal/metrics"
 	"github.com/yeying-community/router/internal/transport/http/middleware"
 )
 
@@ -22,7 +24,17 @@ func SetRouter(engine *gin.Engine, buildFS embed.FS) {
 
 	engine.Use(middleware.CORS())
 
+	engine.GET("/healthz", health.GetLiveness)
+	engine.GET("/readyz", health.GetReadiness)
 	SetApiRouter(engine)
+	if config.PrometheusEnabled && config.PrometheusListenAddr == "" {
+		// the public port only serves metrics to scrapers holding the token
+		if strings.TrimSpace(config.PrometheusAuthToken) == "" {
+			logger.SysError("prometheus.auth_token is empty, /metrics is not mounted on the public port; set a token or prometheus.listen_addr")
+		} else {
+			engine.GET("/metrics", gin.WrapH(metrics.Handler()))
+		}
+	}
 	if common.DisableOpenAICompat {
 		logger.SysLog("OpenAI-compatible routes disabled via feature.disable_openai_compat")
 	} else {
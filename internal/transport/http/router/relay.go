@@ -17,6 +17,39 @@ func SetRelayRouter(engine *gin.Engine) {
 		modelsRouter.GET("/:model", controller.RetrieveModel)
 	}
 
+	responsesRouter := engine.Group("/v1/responses")
+	responsesRouter.Use(middleware.TokenAuth())
+	{
+		responsesRouter.GET("/:id", controller.RetrieveResponse)
+		responsesRouter.GET("/:id/input_items", controller.ListResponseInputItems)
+		responsesRouter.DELETE("/:id", controller.DeleteResponse)
+	}
+
+	filesRouter := engine.Group("/v1/files")
+	filesRouter.Use(middleware.TokenAuth())
+	{
+		filesRouter.GET("", controller.ListFiles)
+		filesRouter.POST("", controller.UploadFile)
+		filesRouter.GET("/:id", controller.RetrieveFile)
+		filesRouter.DELETE("/:id", controller.DeleteFile)
+		filesRouter.GET("/:id/content", controller.RetrieveFileContent)
+	}
+
+	batchesRouter := engine.Group("/v1/batches")
+	batchesRouter.Use(middleware.TokenAuth())
+	{
+		batchesRouter.GET("", controller.ListBatches)
+		batchesRouter.POST("", controller.CreateBatch)
+		batchesRouter.GET("/:id", controller.RetrieveBatch)
+		batchesRouter.POST("/:id/cancel", controller.CancelBatch)
+	}
+
+	geminiRouter := engine.Group("/v1beta")
+	geminiRouter.Use(middleware.GeminiIngress(), middleware.RelayLogger(), middleware.TokenAuth(), middleware.Distribute())
+	{
+		geminiRouter.POST("/models/:model", controller.RelayGemini)
+	}
+
 	relayV1Router := engine.Group("/v1")
 	relayV1Router.Use(middleware.RelayLogger(), middleware.TokenAuth(), middleware.Distribute())
 	{
@@ -27,9 +60,10 @@ func SetRelayRouter(engine *gin.Engine) {
 		relayV1Router.POST("/edits", controller.Relay)
 		relayV1Router.POST("/images/generations", controller.Relay)
 		relayV1Router.POST("/images/edits", controller.Relay)
-		relayV1Router.POST("/images/variations", controller.RelayNotImplemented)
+		relayV1Router.POST("/images/variations", controller.Relay)
 		relayV1Router.POST("/embeddings", controller.Relay)
 		relayV1Router.POST("/engines/:model/embeddings", controller.Relay)
+		relayV1Router.POST("/rerank", controller.Relay)
 		relayV1Router.POST("/audio/transcriptions", controller.Relay)
 		relayV1Router.POST("/audio/translations", controller.Relay)
 		relayV1Router.POST("/audio/speech", controller.Relay)
@@ -40,11 +74,6 @@ func SetRelayRouter(engine *gin.Engine) {
 		relayV1Router.POST("/realtime/calls", controller.Relay)
 		relayV1Router.POST("/videos", controller.Relay)
 		relayV1Router.GET("/videos/:id", controller.Relay)
-		relayV1Router.GET("/files", controller.RelayNotImplemented)
-		relayV1Router.POST("/files", controller.RelayNotImplemented)
-		relayV1Router.DELETE("/files/:id", controller.RelayNotImplemented)
-		relayV1Router.GET("/files/:id", controller.RelayNotImplemented)
-		relayV1Router.GET("/files/:id/content", controller.RelayNotImplemented)
 		relayV1Router.POST("/fine_tuning/jobs", controller.RelayNotImplemented)
 		relayV1Router.GET("/fine_tuning/jobs", controller.RelayNotImplemented)
 		relayV1Router.GET("/fine_tuning/jobs/:id", controller.RelayNotImplemented)
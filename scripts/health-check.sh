@@ -379,32 +379,34 @@ check_process() {
 }
 
 check_http_liveness() {
-  local url="$BASE_URL/api/v1/public/status"
+  local url="$BASE_URL/healthz"
   if http_get "$url"; then
     if [[ "$HTTP_STATUS" == "200" ]]; then
-      set_check "PASS" "status endpoint is reachable"
+      set_check "PASS" "liveness endpoint returned status=ok"
     else
-      set_check "FAIL" "status endpoint returned HTTP $HTTP_STATUS"
+      set_check "FAIL" "liveness endpoint returned HTTP $HTTP_STATUS: $(printf '%s' "$HTTP_BODY" | head -c 300)"
     fi
   else
-    set_check "FAIL" "status endpoint is unreachable"
+    set_check "FAIL" "liveness endpoint is unreachable"
   fi
 }
 
 check_readiness_endpoint() {
-  local url="$BASE_URL/api/v1/public/status"
+  local url="$BASE_URL/readyz"
   if ! http_get "$url"; then
     set_check "FAIL" "readiness endpoint is unreachable"
     return 0
   fi
   if [[ "$HTTP_STATUS" != "200" ]]; then
-    set_check "FAIL" "readiness endpoint returned HTTP $HTTP_STATUS"
+    set_check "FAIL" "readiness endpoint returned HTTP $HTTP_STATUS: $(printf '%s' "$HTTP_BODY" | head -c 300)"
     return 0
   fi
-  if printf '%s' "$HTTP_BODY" | grep -Eq '"success"[[:space:]]*:[[:space:]]*true'; then
-    set_check "PASS" "readiness endpoint returned success=true"
+  if printf '%s' "$HTTP_BODY" | grep -Eq '^[{]"status"[[:space:]]*:[[:space:]]*"degraded"'; then
+    set_check "WARN" "readiness endpoint returned status=degraded: $(printf '%s' "$HTTP_BODY" | head -c 300)"
+  elif printf '%s' "$HTTP_BODY" | grep -Eq '"status"[[:space:]]*:[[:space:]]*"ok"'; then
+    set_check "PASS" "readiness endpoint returned status=ok"
   else
-    set_check "FAIL" "readiness endpoint did not return success=true"
+    set_check "FAIL" "readiness endpoint did not return status=ok"
   fi
 }
 
@@ -8,6 +8,8 @@ log_dir="${ROUTER_LOG_DIR:-$root_dir/logs}"
 pid_dir="$root_dir/run"
 pid_file="$pid_dir/router.pid"
 port="${ROUTER_PORT:-3011}"
+# must cover server.shutdown_drain_delay_seconds + server.shutdown_timeout_seconds
+stop_timeout="${ROUTER_STOP_TIMEOUT:-45}"
 
 ensure_dirs() {
   mkdir -p "$log_dir" "$pid_dir"
@@ -60,7 +62,7 @@ stop() {
   fi
 
   kill "$pid"
-  for _ in {1..50}; do
+  for ((i = 0; i < stop_timeout * 5; i++)); do
     if ! kill -0 "$pid" 2>/dev/null; then
       break
     fi
@@ -44,6 +44,7 @@ const PROVIDER_ENDPOINT_SORT_ORDER = {
   '/v1/images/edits': 50,
   '/v1/batches': 60,
   '/v1/embeddings': 65,
+  '/v1/rerank': 67,
   '/v1/audio/speech': 70,
   '/v1/realtime': 80,
   '/v1/videos': 90,
@@ -190,6 +191,9 @@ const inferModelType = (model) => {
   if (typeof model !== 'string') return 'text';
   const lower = model.trim().toLowerCase();
   if (!lower) return 'text';
+  if (lower.includes('rerank')) {
+    return 'rerank';
+  }
   if (
     lower.includes('embedding') ||
     lower.startsWith('text-embedding')
@@ -228,6 +232,15 @@ const inferModelType = (model) => {
 const defaultPriceUnitByType = (type, modelName) => {
   if (type === 'image') return 'per_image';
   if (type === 'video') return 'per_video';
+  if (type === 'rerank') {
+    if (
+      typeof modelName === 'string' &&
+      modelName.trim().toLowerCase().startsWith('rerank-')
+    ) {
+      return 'per_search_unit';
+    }
+    return 'per_1k_tokens';
+  }
   if (type === 'audio') {
     if (
       typeof modelName === 'string' &&
@@ -268,20 +281,29 @@ function normalizeProviderModelType(value, model) {
     normalized === 'audio' ||
     normalized === 'image' ||
     normalized === 'video' ||
-    normalized === 'embedding'
+    normalized === 'embedding' ||
+    normalized === 'rerank'
   ) {
     return normalized;
   }
   return inferModelType(model);
 }
 
-const BASE_MODEL_TAGS = ['text', 'image', 'audio', 'video', 'embedding'];
+const BASE_MODEL_TAGS = [
+  'text',
+  'image',
+  'audio',
+  'video',
+  'embedding',
+  'rerank',
+];
 const PROVIDER_MODEL_TAG_ORDER = [
   'text',
   'image',
   'audio',
   'video',
   'embedding',
+  'rerank',
   'tool_calling',
   'reasoning',
   'vision',
@@ -332,6 +354,9 @@ const normalizeProviderEndpoint = (endpoint) => {
   if (normalized.startsWith('/v1/embeddings')) {
     return '/v1/embeddings';
   }
+  if (normalized.startsWith('/v1/rerank')) {
+    return '/v1/rerank';
+  }
   if (normalized.startsWith('/v1/audio/')) {
     return '/v1/audio/speech';
   }
@@ -360,6 +385,8 @@ const isProviderEndpointAllowedForType = (type, endpoint) => {
       return endpoint === '/v1/videos';
     case 'embedding':
       return endpoint === '/v1/embeddings';
+    case 'rerank':
+      return endpoint === '/v1/rerank';
     case 'text':
     default:
       return ['/v1/chat/completions', '/v1/responses', '/v1/messages'].includes(
@@ -635,6 +662,7 @@ const PROVIDER_ENDPOINT_OPTIONS = [
     value: '/v1/embeddings',
     text: '/v1/embeddings',
   },
+  { key: '/v1/rerank', value: '/v1/rerank', text: '/v1/rerank' },
   {
     key: '/v1/audio/speech',
     value: '/v1/audio/speech',
@@ -659,6 +687,12 @@ const PRICE_UNIT_OPTIONS = [
   { key: 'per_second', value: 'per_second', text: 'per_second' },
   { key: 'per_request', value: 'per_request', text: 'per_request' },
   { key: 'per_task', value: 'per_task', text: 'per_task' },
+  {
+    key: 'per_search_unit',
+    value: 'per_search_unit',
+    text: 'per_search_unit',
+  },
+  { key: 'per_document', value: 'per_document', text: 'per_document' },
 ];
 
 const PRICE_COMPONENT_OPTIONS = [
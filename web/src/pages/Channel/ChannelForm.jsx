@@ -343,6 +343,7 @@ const normalizeChannelModelType = (value) => {
     case 'audio':
     case 'video':
     case 'embedding':
+    case 'rerank':
       return normalized;
     default:
       return 'text';
@@ -362,6 +363,8 @@ const defaultChannelModelEndpoint = (type, protocol) => {
       return '/v1/videos';
     case 'embedding':
       return '/v1/embeddings';
+    case 'rerank':
+      return '/v1/rerank';
     default:
       switch (normalizeChannelProtocol(protocol)) {
         case 'anthropic':
@@ -419,6 +422,9 @@ const normalizeChannelModelEndpoint = (type, value, protocol) => {
         return defaultChannelModelEndpoint(normalizedType, protocol);
     }
   }
+  if (normalizedType === 'rerank') {
+    return '/v1/rerank';
+  }
   return defaultChannelModelEndpoint(normalizedType, protocol);
 };
 
@@ -514,6 +520,7 @@ const CHANNEL_MODEL_TYPE_OPTIONS = [
   { key: 'audio', value: 'audio', text: 'audio' },
   { key: 'video', value: 'video', text: 'video' },
   { key: 'embedding', value: 'embedding', text: 'embedding' },
+  { key: 'rerank', value: 'rerank', text: 'rerank' },
 ];
 
 const PROVIDER_MODEL_TAG_OPTIONS = [
@@ -522,6 +529,7 @@ const PROVIDER_MODEL_TAG_OPTIONS = [
   'audio',
   'video',
   'embedding',
+  'rerank',
   'tool_calling',
   'reasoning',
   'vision',
@@ -537,7 +545,7 @@ const providerModelTypeFromTags = (tags) => {
     : [];
   for (const item of values) {
     const tag = (item || '').toString().trim().toLowerCase();
-    if (['text', 'image', 'audio', 'video', 'embedding'].includes(tag)) {
+    if (['text', 'image', 'audio', 'video', 'embedding', 'rerank'].includes(tag)) {
       return tag;
     }
   }
@@ -559,6 +567,10 @@ const EMBEDDING_MODEL_ENDPOINT_OPTIONS = [
   { key: 'embeddings', value: '/v1/embeddings', text: '/v1/embeddings' },
 ];
 
+const RERANK_MODEL_ENDPOINT_OPTIONS = [
+  { key: 'rerank', value: '/v1/rerank', text: '/v1/rerank' },
+];
+
 const IMAGE_MODEL_ENDPOINT_OPTIONS = [
   { key: 'responses', value: '/v1/responses', text: '/v1/responses' },
   {
@@ -578,6 +590,7 @@ const CHANNEL_ENDPOINT_SORT_ORDER = {
   '/v1/images/edits': 50,
   '/v1/batches': 60,
   '/v1/embeddings': 65,
+  '/v1/rerank': 67,
   '/v1/audio/speech': 70,
   '/v1/realtime': 80,
   '/v1/videos': 90,
@@ -594,6 +607,9 @@ const endpointOptionsForModelType = (type) => {
   if (normalizedType === 'embedding') {
     return EMBEDDING_MODEL_ENDPOINT_OPTIONS;
   }
+  if (normalizedType === 'rerank') {
+    return RERANK_MODEL_ENDPOINT_OPTIONS;
+  }
   if (normalizedType === 'text') {
     return TEXT_MODEL_ENDPOINT_OPTIONS;
   }
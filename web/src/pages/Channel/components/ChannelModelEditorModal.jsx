@@ -21,6 +21,12 @@ const priceUnitOptions = [
   { key: 'per_second', value: 'per_second', text: 'per_second' },
   { key: 'per_request', value: 'per_request', text: 'per_request' },
   { key: 'per_task', value: 'per_task', text: 'per_task' },
+  {
+    key: 'per_search_unit',
+    value: 'per_search_unit',
+    text: 'per_search_unit',
+  },
+  { key: 'per_document', value: 'per_document', text: 'per_document' },
 ];
 
 const CHANNEL_MODEL_EDITOR_PRICING_COLUMN_WIDTHS = {